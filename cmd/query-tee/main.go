@@ -6,6 +6,7 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"os"
@@ -25,6 +26,7 @@ type Config struct {
 	ServerMetricsPort int
 	LogLevel          log.Level
 	ProxyConfig       querytee.ProxyConfig
+	ReplayConfig      querytee.ReplayConfig
 	PathPrefix        string
 }
 
@@ -35,6 +37,7 @@ func main() {
 	flag.StringVar(&cfg.PathPrefix, "server.path-prefix", "", "Path prefix for API paths (query-tee will accept Prometheus API calls at <prefix>/api/v1/...). Example: -server.path-prefix=/prometheus")
 	cfg.LogLevel.RegisterFlags(flag.CommandLine)
 	cfg.ProxyConfig.RegisterFlags(flag.CommandLine)
+	cfg.ReplayConfig.RegisterFlags(flag.CommandLine)
 
 	// Parse CLI arguments.
 	if err := flagext.ParseFlagsWithoutArguments(flag.CommandLine); err != nil {
@@ -55,6 +58,18 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Replay the recorded requests, instead of running the proxy, if requested.
+	if cfg.ReplayConfig.File != "" {
+		if err := runReplay(cfg, registry); err != nil {
+			level.Error(util_log.Logger).Log("msg", "Unable to replay the recorded requests", "err", err.Error())
+			util_log.Flush()
+			os.Exit(1)
+		}
+
+		util_log.Flush()
+		return
+	}
+
 	// Run the proxy.
 	proxy, err := querytee.NewProxy(cfg.ProxyConfig, util_log.Logger, mimirReadRoutes(cfg), registry)
 	if err != nil {
@@ -72,6 +87,20 @@ func main() {
 	proxy.Await()
 }
 
+func runReplay(cfg Config, registry *prometheus.Registry) error {
+	replayer, err := querytee.NewReplayer(cfg.ReplayConfig, cfg.ProxyConfig, mimirReadRoutes(cfg), util_log.Logger, registry)
+	if err != nil {
+		return err
+	}
+
+	report, err := replayer.Run(context.Background())
+	if err != nil {
+		return err
+	}
+
+	return report.WriteFile(cfg.ReplayConfig.ReportFile, cfg.ReplayConfig.ReportFormat)
+}
+
 func mimirReadRoutes(cfg Config) []querytee.Route {
 	prefix := cfg.PathPrefix
 
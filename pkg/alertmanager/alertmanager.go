@@ -100,6 +100,7 @@ type Alertmanager struct {
 	persister       *statePersister
 	nflog           *nflog.Log
 	silences        *silence.Silences
+	history         *notificationHistory
 	marker          types.Marker
 	alerts          *mem.Alerts
 	dispatcher      *dispatch.Dispatcher
@@ -210,7 +211,7 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		am.wg.Done()
 	}()
 
-	c := am.state.AddState("nfl:"+cfg.UserID, am.nflog, am.registry)
+	c := am.state.AddState(notificationLogStateKeyPrefix+cfg.UserID, am.nflog, am.registry)
 	am.nflog.SetBroadcast(c.Broadcast)
 
 	am.marker = types.NewMarker(am.registry)
@@ -226,9 +227,17 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		return nil, fmt.Errorf("failed to create silences: %v", err)
 	}
 
-	c = am.state.AddState("sil:"+cfg.UserID, am.silences, am.registry)
+	c = am.state.AddState(silencesStateKeyPrefix+cfg.UserID, am.silences, am.registry)
 	am.silences.SetBroadcast(c.Broadcast)
 
+	// The notification history is replicated only for the tenants which have it enabled, so that
+	// replicas which don't know about it never receive its state.
+	if cfg.Limits != nil && cfg.Limits.AlertmanagerMaxNotificationHistoryEntries(cfg.UserID) > 0 {
+		am.history = newNotificationHistory(cfg.UserID, cfg.Limits, cfg.Retention, log.With(am.logger, "component", "notification_history"))
+		c = am.state.AddState(notificationHistoryStateKeyPrefix+cfg.UserID, am.history, am.registry)
+		am.history.SetBroadcast(c.Broadcast)
+	}
+
 	// State replication needs to be started after the state keys are defined.
 	if err := am.state.StartAsync(context.Background()); err != nil {
 		return nil, errors.Wrap(err, "failed to start ring-based replication service")
@@ -298,6 +307,14 @@ func New(cfg *Config, reg *prometheus.Registry) (*Alertmanager, error) {
 		am.mux.Handle(a, http.NotFoundHandler())
 	}
 
+	if am.history != nil {
+		am.mux.Handle(path.Join(am.cfg.ExternalURL.Path, "/api/v1/notifications/history"), am.history)
+	} else {
+		am.mux.Handle(path.Join(am.cfg.ExternalURL.Path, "/api/v1/notifications/history"), http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+			http.Error(w, "the notification history is disabled for the tenant", http.StatusNotFound)
+		}))
+	}
+
 	am.dispatcherMetrics = dispatch.NewDispatcherMetrics(true, am.registry)
 
 	//TODO: From this point onward, the alertmanager _might_ receive requests - we need to make sure we've settled and are ready.
@@ -371,7 +388,12 @@ func (am *Alertmanager) ApplyConfig(userID string, conf *config.Config, rawCfg s
 				integration: integrationName,
 			}
 
-			return newRateLimitedNotifier(notifier, rl, 10*time.Second, am.rateLimitedNotifications.WithLabelValues(integrationName))
+			notifier = newRateLimitedNotifier(notifier, rl, 10*time.Second, am.rateLimitedNotifications.WithLabelValues(integrationName))
+		}
+
+		if am.history != nil {
+			// Rate-limited notifications are recorded in the history too.
+			notifier = newNotificationHistoryNotifier(notifier, integrationName, am.history)
 		}
 		return notifier
 	})
@@ -23,6 +23,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	prefix := "alertmanager-storage."
 
 	cfg.StorageBackendConfig.ExtraBackends = []string{local.Name}
+	// Alertmanager configs are stored at alerts/<tenant> and state at alertmanager/<tenant>/...
+	cfg.Encryption.TenantPrefixes = []string{"alerts", "alertmanager"}
 	cfg.Local.RegisterFlagsWithPrefix(prefix, f)
 	cfg.RegisterFlagsWithPrefixAndDefaultDirectory(prefix, "alertmanager", f)
 }
@@ -224,6 +224,9 @@ type Limits interface {
 	// AlertmanagerMaxAlertsSizeBytes returns total max size of alerts that tenant can have active at the same time. 0 = no limit.
 	// Size of the alert is computed from alert labels, annotations and generator URL.
 	AlertmanagerMaxAlertsSizeBytes(tenant string) int
+
+	// AlertmanagerMaxNotificationHistoryEntries returns max number of entries of the notification history of a tenant. 0 = disabled.
+	AlertmanagerMaxNotificationHistoryEntries(tenant string) int
 }
 
 // A MultitenantAlertmanager manages Alertmanager instances for multiple
@@ -958,8 +961,12 @@ func (am *MultitenantAlertmanager) ReadFullStateForUser(ctx context.Context, use
 	}
 
 	// We should only query state from other replicas, and not our own state.
-	addrs := replicationSet.GetAddressesWithout(am.ringLifecycler.GetInstanceAddr())
+	return am.readFullStateFromReplicas(ctx, userID, replicationSet.GetAddressesWithout(am.ringLifecycler.GetInstanceAddr()))
+}
 
+// readFullStateFromReplicas attempts to read the full state for user from each of the input replicas. It considers it a
+// success if state is obtained from at least one replica.
+func (am *MultitenantAlertmanager) readFullStateFromReplicas(ctx context.Context, userID string, addrs []string) ([]*clusterpb.FullState, error) {
 	var (
 		resultsMtx sync.Mutex
 		results    []*clusterpb.FullState
@@ -967,7 +974,7 @@ func (am *MultitenantAlertmanager) ReadFullStateForUser(ctx context.Context, use
 	)
 
 	// Note that the jobs swallow the errors - this is because we want to give each replica a chance to respond.
-	err = concurrency.ForEachJob(ctx, len(addrs), len(addrs), func(ctx context.Context, idx int) error {
+	err := concurrency.ForEachJob(ctx, len(addrs), len(addrs), func(ctx context.Context, idx int) error {
 		addr := addrs[idx]
 		level.Debug(am.logger).Log("msg", "contacting replica for full state", "user", userID, "addr", addr)
 
@@ -2354,6 +2354,7 @@ type mockAlertManagerLimits struct {
 	maxDispatcherAggregationGroups int
 	maxAlertsCount                 int
 	maxAlertsSizeBytes             int
+	maxNotificationHistoryEntries  int
 }
 
 func (m *mockAlertManagerLimits) AlertmanagerMaxConfigSize(string) int {
@@ -2395,3 +2396,7 @@ func (m *mockAlertManagerLimits) AlertmanagerMaxAlertsCount(_ string) int {
 func (m *mockAlertManagerLimits) AlertmanagerMaxAlertsSizeBytes(_ string) int {
 	return m.maxAlertsSizeBytes
 }
+
+func (m *mockAlertManagerLimits) AlertmanagerMaxNotificationHistoryEntries(_ string) int {
+	return m.maxNotificationHistoryEntries
+}
@@ -0,0 +1,314 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+const (
+	notificationOutcomeSuccess = "success"
+	notificationOutcomeFailure = "failure"
+)
+
+// NotificationHistoryEntry records a single attempt to send a notification through an integration.
+type NotificationHistoryEntry struct {
+	Timestamp   time.Time `json:"timestamp"`
+	Receiver    string    `json:"receiver"`
+	Integration string    `json:"integration"`
+	GroupKey    string    `json:"group_key"`
+	// Alerts contains the fingerprints of the alerts included in the notification.
+	Alerts  []string `json:"alerts"`
+	Outcome string   `json:"outcome"`
+	Error   string   `json:"error,omitempty"`
+	// Retries is the number of failed attempts to send the same notification before this one.
+	Retries int `json:"retries"`
+}
+
+func (e NotificationHistoryEntry) key() string {
+	return fmt.Sprintf("%d/%s/%s/%s/%d", e.Timestamp.UnixNano(), e.Receiver, e.Integration, e.GroupKey, e.Retries)
+}
+
+// notificationHistory is a bounded history of the notifications sent by the Alertmanager of a tenant.
+// It implements cluster.State, so that the history is replicated and persisted with the rest of the
+// tenant's state.
+type notificationHistory struct {
+	userID    string
+	limits    Limits
+	logger    log.Logger
+	retention time.Duration
+	now       func() time.Time
+
+	mtx       sync.Mutex
+	entries   []NotificationHistoryEntry // Sorted by timestamp.
+	keys      map[string]struct{}        // Keys of the entries.
+	broadcast func([]byte)
+}
+
+func newNotificationHistory(userID string, limits Limits, retention time.Duration, logger log.Logger) *notificationHistory {
+	return &notificationHistory{
+		userID:    userID,
+		limits:    limits,
+		logger:    logger,
+		retention: retention,
+		now:       time.Now,
+		keys:      map[string]struct{}{},
+		broadcast: func([]byte) {},
+	}
+}
+
+// SetBroadcast sets the function used to replicate new entries to the other replicas.
+func (h *notificationHistory) SetBroadcast(f func([]byte)) {
+	h.mtx.Lock()
+	h.broadcast = f
+	h.mtx.Unlock()
+}
+
+func (h *notificationHistory) maxEntries() int {
+	if h.limits == nil {
+		return 0
+	}
+	return h.limits.AlertmanagerMaxNotificationHistoryEntries(h.userID)
+}
+
+// record adds the entry to the history and broadcasts it to the other replicas.
+func (h *notificationHistory) record(e NotificationHistoryEntry) {
+	if h.maxEntries() <= 0 {
+		return
+	}
+
+	b, err := json.Marshal([]NotificationHistoryEntry{e})
+	if err != nil {
+		return
+	}
+
+	h.mtx.Lock()
+	h.merge([]NotificationHistoryEntry{e})
+	broadcast := h.broadcast
+	h.mtx.Unlock()
+
+	broadcast(b)
+}
+
+// merge inserts the entries which are not already in the history, keeping the history sorted by timestamp,
+// and then drops the entries exceeding the retention or the configured max number of entries.
+// Must be called with the lock held.
+func (h *notificationHistory) merge(entries []NotificationHistoryEntry) {
+	for _, e := range entries {
+		k := e.key()
+		if _, ok := h.keys[k]; ok {
+			continue
+		}
+		h.keys[k] = struct{}{}
+
+		// Entries are usually recorded in order, so they're appended in the common case.
+		i := len(h.entries)
+		if i > 0 && e.Timestamp.Before(h.entries[i-1].Timestamp) {
+			i = sort.Search(len(h.entries), func(i int) bool {
+				return h.entries[i].Timestamp.After(e.Timestamp)
+			})
+		}
+		h.entries = slices.Insert(h.entries, i, e)
+	}
+
+	drop := 0
+	if h.retention > 0 {
+		minTime := h.now().Add(-h.retention)
+		drop = sort.Search(len(h.entries), func(i int) bool {
+			return !h.entries[i].Timestamp.Before(minTime)
+		})
+	}
+	if maxEntries := h.maxEntries(); len(h.entries)-drop > maxEntries {
+		drop = len(h.entries) - maxEntries
+	}
+
+	for _, e := range h.entries[:drop] {
+		delete(h.keys, e.key())
+	}
+	h.entries = h.entries[drop:]
+}
+
+// MarshalBinary implements cluster.State.
+func (h *notificationHistory) MarshalBinary() ([]byte, error) {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	entries := h.entries
+	if entries == nil {
+		entries = []NotificationHistoryEntry{}
+	}
+	return json.Marshal(entries)
+}
+
+// Merge implements cluster.State.
+func (h *notificationHistory) Merge(b []byte) error {
+	var entries []NotificationHistoryEntry
+	if err := json.Unmarshal(b, &entries); err != nil {
+		return err
+	}
+
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	h.merge(entries)
+	return nil
+}
+
+// notificationHistoryFilter selects the entries of the history returned by the API.
+type notificationHistoryFilter struct {
+	receiver    string
+	integration string
+	outcome     string
+	limit       int
+}
+
+func (f notificationHistoryFilter) matches(e NotificationHistoryEntry) bool {
+	return (f.receiver == "" || f.receiver == e.Receiver) &&
+		(f.integration == "" || f.integration == e.Integration) &&
+		(f.outcome == "" || f.outcome == e.Outcome)
+}
+
+// query returns the entries matching the filter, most recent first.
+func (h *notificationHistory) query(f notificationHistoryFilter) []NotificationHistoryEntry {
+	h.mtx.Lock()
+	defer h.mtx.Unlock()
+
+	res := []NotificationHistoryEntry{}
+	for i := len(h.entries) - 1; i >= 0; i-- {
+		if f.limit > 0 && len(res) >= f.limit {
+			break
+		}
+		if f.matches(h.entries[i]) {
+			res = append(res, h.entries[i])
+		}
+	}
+	return res
+}
+
+// ServeHTTP serves the notification history of the tenant.
+func (h *notificationHistory) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f := notificationHistoryFilter{
+		receiver:    r.FormValue("receiver"),
+		integration: r.FormValue("integration"),
+		outcome:     r.FormValue("outcome"),
+	}
+
+	if f.outcome != "" && f.outcome != notificationOutcomeSuccess && f.outcome != notificationOutcomeFailure {
+		http.Error(w, fmt.Sprintf("invalid outcome %q, supported values are %q and %q", f.outcome, notificationOutcomeSuccess, notificationOutcomeFailure), http.StatusBadRequest)
+		return
+	}
+
+	if limit := r.FormValue("limit"); limit != "" {
+		var err error
+		if f.limit, err = strconv.Atoi(limit); err != nil || f.limit < 0 {
+			http.Error(w, fmt.Sprintf("invalid limit %q", limit), http.StatusBadRequest)
+			return
+		}
+	}
+
+	res := struct {
+		Status string                     `json:"status"`
+		Data   []NotificationHistoryEntry `json:"data"`
+	}{
+		Status: "success",
+		Data:   h.query(f),
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(res); err != nil {
+		level.Error(util_log.WithContext(r.Context(), h.logger)).Log("msg", "failed to write notification history", "err", err)
+	}
+}
+
+// notificationHistoryNotifier records every attempt to send a notification through the upstream
+// notifier in the notification history.
+type notificationHistoryNotifier struct {
+	upstream    notify.Notifier
+	integration string
+	history     *notificationHistory
+
+	mtx sync.Mutex
+	// Failed attempts of the notifications which are being retried, by group key.
+	pending map[string]pendingNotification
+}
+
+type pendingNotification struct {
+	failures int
+	deadline time.Time
+}
+
+func newNotificationHistoryNotifier(upstream notify.Notifier, integration string, history *notificationHistory) *notificationHistoryNotifier {
+	return &notificationHistoryNotifier{
+		upstream:    upstream,
+		integration: integration,
+		history:     history,
+		pending:     map[string]pendingNotification{},
+	}
+}
+
+func (n *notificationHistoryNotifier) Notify(ctx context.Context, alerts ...*types.Alert) (bool, error) {
+	retry, err := n.upstream.Notify(ctx, alerts...)
+
+	receiver, _ := notify.ReceiverName(ctx)
+	groupKey, _ := notify.GroupKey(ctx)
+	now := n.history.now()
+
+	e := NotificationHistoryEntry{
+		Timestamp:   now,
+		Receiver:    receiver,
+		Integration: n.integration,
+		GroupKey:    groupKey,
+		Alerts:      make([]string, 0, len(alerts)),
+		Outcome:     notificationOutcomeSuccess,
+		Retries:     n.trackAttempt(ctx, groupKey, now, retry && err != nil),
+	}
+	for _, a := range alerts {
+		e.Alerts = append(e.Alerts, a.Fingerprint().String())
+	}
+	if err != nil {
+		e.Outcome = notificationOutcomeFailure
+		e.Error = err.Error()
+	}
+
+	n.history.record(e)
+	return retry, err
+}
+
+// trackAttempt returns the number of previous failed attempts to send the notification with the given
+// group key, and keeps track of the failed attempt if the notification is going to be retried.
+func (n *notificationHistoryNotifier) trackAttempt(ctx context.Context, groupKey string, now time.Time, willRetry bool) int {
+	n.mtx.Lock()
+	defer n.mtx.Unlock()
+
+	// Forget about the notifications which are no longer retried.
+	for k, p := range n.pending {
+		if now.After(p.deadline) {
+			delete(n.pending, k)
+		}
+	}
+
+	failures := n.pending[groupKey].failures
+	deadline, ok := ctx.Deadline()
+	if !willRetry || !ok {
+		delete(n.pending, groupKey)
+		return failures
+	}
+
+	n.pending[groupKey] = pendingNotification{failures: failures + 1, deadline: deadline}
+	return failures
+}
@@ -0,0 +1,267 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/alertmanager/notify"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNotificationHistory_MergeAndQuery(t *testing.T) {
+	now := time.Now().UTC()
+	limits := &mockAlertManagerLimits{maxNotificationHistoryEntries: 3}
+
+	h := newNotificationHistory("user", limits, time.Hour, log.NewNopLogger())
+	h.now = func() time.Time { return now }
+
+	var broadcasted [][]byte
+	h.SetBroadcast(func(b []byte) { broadcasted = append(broadcasted, b) })
+
+	first := NotificationHistoryEntry{Timestamp: now.Add(-3 * time.Minute), Receiver: "team-a", Integration: "email", GroupKey: "a", Outcome: notificationOutcomeSuccess}
+	second := NotificationHistoryEntry{Timestamp: now.Add(-2 * time.Minute), Receiver: "team-b", Integration: "webhook", GroupKey: "b", Outcome: notificationOutcomeFailure, Error: "failed"}
+	h.record(second)
+	h.record(first)
+	require.Len(t, broadcasted, 2)
+
+	// Merging the state of another replica adds the missing entries only, and drops the expired ones.
+	third := NotificationHistoryEntry{Timestamp: now.Add(-time.Minute), Receiver: "team-a", Integration: "webhook", GroupKey: "c", Outcome: notificationOutcomeFailure, Error: "failed", Retries: 1}
+	expired := NotificationHistoryEntry{Timestamp: now.Add(-2 * time.Hour), Receiver: "team-a", Integration: "email", GroupKey: "d", Outcome: notificationOutcomeSuccess}
+	b, err := json.Marshal([]NotificationHistoryEntry{first, third, expired})
+	require.NoError(t, err)
+	require.NoError(t, h.Merge(b))
+
+	assert.Equal(t, []NotificationHistoryEntry{third, second, first}, h.query(notificationHistoryFilter{}))
+	assert.Equal(t, []NotificationHistoryEntry{third, first}, h.query(notificationHistoryFilter{receiver: "team-a"}))
+	assert.Equal(t, []NotificationHistoryEntry{third}, h.query(notificationHistoryFilter{receiver: "team-a", integration: "webhook"}))
+	assert.Equal(t, []NotificationHistoryEntry{third, second}, h.query(notificationHistoryFilter{outcome: notificationOutcomeFailure}))
+	assert.Equal(t, []NotificationHistoryEntry{third}, h.query(notificationHistoryFilter{limit: 1}))
+
+	// The full state of a replica contains all the entries.
+	full, err := h.MarshalBinary()
+	require.NoError(t, err)
+	other := newNotificationHistory("user", limits, time.Hour, log.NewNopLogger())
+	require.NoError(t, other.Merge(full))
+	assert.Equal(t, h.query(notificationHistoryFilter{}), other.query(notificationHistoryFilter{}))
+
+	// The oldest entries are dropped when the history exceeds the limit.
+	fourth := NotificationHistoryEntry{Timestamp: now, Receiver: "team-b", Integration: "email", GroupKey: "e", Outcome: notificationOutcomeSuccess}
+	h.record(fourth)
+	assert.Equal(t, []NotificationHistoryEntry{fourth, third, second}, h.query(notificationHistoryFilter{}))
+
+	// Nothing is recorded when the history is disabled.
+	limits.maxNotificationHistoryEntries = 0
+	broadcasted = nil
+	h.record(NotificationHistoryEntry{Timestamp: now, Receiver: "team-b", Integration: "email", GroupKey: "f"})
+	assert.Empty(t, broadcasted)
+}
+
+func TestNotificationHistory_MergeOutOfOrder(t *testing.T) {
+	now := time.Now().UTC()
+	h := newNotificationHistory("user", &mockAlertManagerLimits{maxNotificationHistoryEntries: 2}, time.Hour, log.NewNopLogger())
+	h.now = func() time.Time { return now }
+
+	entries := make([]NotificationHistoryEntry, 4)
+	for i := range entries {
+		entries[i] = NotificationHistoryEntry{Timestamp: now.Add(time.Duration(i-len(entries)) * time.Minute), Receiver: "team-a", Integration: "email", GroupKey: strconv.Itoa(i)}
+	}
+
+	// Entries received out of order are inserted in order, and the oldest ones are dropped.
+	for _, i := range []int{2, 0, 3, 1} {
+		b, err := json.Marshal([]NotificationHistoryEntry{entries[i]})
+		require.NoError(t, err)
+		require.NoError(t, h.Merge(b))
+	}
+	assert.Equal(t, []NotificationHistoryEntry{entries[3], entries[2]}, h.query(notificationHistoryFilter{}))
+	assert.Len(t, h.keys, 2)
+
+	// Merging an entry which is already in the history doesn't duplicate it.
+	b, err := json.Marshal(entries[2:])
+	require.NoError(t, err)
+	require.NoError(t, h.Merge(b))
+	assert.Equal(t, []NotificationHistoryEntry{entries[3], entries[2]}, h.query(notificationHistoryFilter{}))
+}
+
+func TestNotificationHistory_ServeHTTP(t *testing.T) {
+	now := time.Now().UTC()
+	h := newNotificationHistory("user", &mockAlertManagerLimits{maxNotificationHistoryEntries: 10}, time.Hour, log.NewNopLogger())
+	h.record(NotificationHistoryEntry{Timestamp: now.Add(-time.Minute), Receiver: "team-a", Integration: "email", GroupKey: "a", Alerts: []string{"1"}, Outcome: notificationOutcomeSuccess})
+	h.record(NotificationHistoryEntry{Timestamp: now, Receiver: "team-a", Integration: "email", GroupKey: "a", Alerts: []string{"1"}, Outcome: notificationOutcomeFailure, Error: "failed"})
+
+	tests := map[string]struct {
+		query        string
+		expectedCode int
+		expectedBody string
+	}{
+		"no filters": {
+			expectedCode: http.StatusOK,
+			expectedBody: `{"status": "success", "data": [
+				{"timestamp": "` + now.Format(time.RFC3339Nano) + `", "receiver": "team-a", "integration": "email", "group_key": "a", "alerts": ["1"], "outcome": "failure", "error": "failed", "retries": 0},
+				{"timestamp": "` + now.Add(-time.Minute).Format(time.RFC3339Nano) + `", "receiver": "team-a", "integration": "email", "group_key": "a", "alerts": ["1"], "outcome": "success", "retries": 0}
+			]}`,
+		},
+		"no matching entries": {
+			query:        "?receiver=team-b",
+			expectedCode: http.StatusOK,
+			expectedBody: `{"status": "success", "data": []}`,
+		},
+		"invalid outcome": {
+			query:        "?outcome=unknown",
+			expectedCode: http.StatusBadRequest,
+		},
+		"invalid limit": {
+			query:        "?limit=-1",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/alertmanager/api/v1/notifications/history"+tc.query, nil))
+			require.Equal(t, tc.expectedCode, w.Code, w.Body.String())
+			if tc.expectedBody != "" {
+				assert.JSONEq(t, tc.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestAlertmanager_NotificationHistory(t *testing.T) {
+	am, err := New(&Config{
+		UserID:            "user",
+		Logger:            log.NewNopLogger(),
+		Limits:            &mockAlertManagerLimits{maxNotificationHistoryEntries: 10},
+		TenantDataDir:     t.TempDir(),
+		ExternalURL:       &url.URL{Path: "/am"},
+		ShardingEnabled:   true,
+		Store:             prepareInMemoryAlertStore(),
+		Replicator:        &stubReplicator{},
+		ReplicationFactor: 1,
+		PersisterConfig:   PersisterConfig{Interval: time.Hour},
+	}, prometheus.NewPedanticRegistry())
+	require.NoError(t, err)
+	defer am.StopAndWait()
+
+	am.history.record(NotificationHistoryEntry{Timestamp: time.Now(), Receiver: "team-a", Integration: "email", GroupKey: "a", Outcome: notificationOutcomeSuccess})
+
+	// The history is part of the replicated state.
+	fs, err := am.state.GetFullState()
+	require.NoError(t, err)
+	keys := make([]string, 0, len(fs.Parts))
+	for _, p := range fs.Parts {
+		keys = append(keys, p.Key)
+	}
+	assert.ElementsMatch(t, []string{"nfl:user", "sil:user", "nhi:user"}, keys)
+
+	w := httptest.NewRecorder()
+	am.mux.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/am/api/v1/notifications/history", nil))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"receiver":"team-a"`)
+}
+
+func TestNotificationHistoryNotifier(t *testing.T) {
+	h := newNotificationHistory("user", &mockAlertManagerLimits{maxNotificationHistoryEntries: 10}, time.Hour, log.NewNopLogger())
+	upstream := &notifierMock{}
+	n := newNotificationHistoryNotifier(upstream, "webhook", h)
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+	ctx = notify.WithReceiverName(ctx, "team-a")
+	ctx = notify.WithGroupKey(ctx, "group")
+
+	alert := &types.Alert{Alert: model.Alert{Labels: model.LabelSet{"alertname": "test"}}}
+
+	// Two failed attempts followed by a successful one.
+	upstream.retry, upstream.err = true, errors.New("temporary failure")
+	for i := 0; i < 2; i++ {
+		retry, err := n.Notify(ctx, alert)
+		assert.True(t, retry)
+		assert.Error(t, err)
+	}
+	upstream.retry, upstream.err = false, nil
+	_, err := n.Notify(ctx, alert)
+	require.NoError(t, err)
+
+	// The next notification of the same group isn't a retry.
+	_, err = n.Notify(ctx, alert)
+	require.NoError(t, err)
+
+	entries := h.query(notificationHistoryFilter{})
+	require.Len(t, entries, 4)
+
+	expectedOutcomes := []string{notificationOutcomeSuccess, notificationOutcomeSuccess, notificationOutcomeFailure, notificationOutcomeFailure}
+	expectedRetries := []int{0, 2, 1, 0}
+	for i, e := range entries {
+		assert.Equal(t, "team-a", e.Receiver)
+		assert.Equal(t, "webhook", e.Integration)
+		assert.Equal(t, "group", e.GroupKey)
+		assert.Equal(t, []string{alert.Fingerprint().String()}, e.Alerts)
+		assert.Equal(t, expectedOutcomes[i], e.Outcome)
+		assert.Equal(t, expectedRetries[i], e.Retries)
+	}
+	assert.Equal(t, "temporary failure", entries[3].Error)
+	assert.Empty(t, n.pending)
+}
+
+type notifierMock struct {
+	retry bool
+	err   error
+}
+
+func (n *notifierMock) Notify(context.Context, ...*types.Alert) (bool, error) {
+	return n.retry, n.err
+}
+
+func TestAlertmanager_NotificationHistoryRegistration(t *testing.T) {
+	for name, maxEntries := range map[string]int{"enabled": 10, "disabled": 0} {
+		t.Run(name, func(t *testing.T) {
+			am, err := New(&Config{
+				UserID:            "user",
+				Logger:            log.NewNopLogger(),
+				Limits:            &mockAlertManagerLimits{maxNotificationHistoryEntries: maxEntries},
+				TenantDataDir:     t.TempDir(),
+				ExternalURL:       &url.URL{Path: "/am"},
+				ShardingEnabled:   true,
+				Store:             prepareInMemoryAlertStore(),
+				Replicator:        &stubReplicator{},
+				ReplicationFactor: 1,
+				PersisterConfig:   PersisterConfig{Interval: time.Hour},
+			}, prometheus.NewPedanticRegistry())
+			require.NoError(t, err)
+			defer am.StopAndWait()
+
+			fs, err := am.state.GetFullState()
+			require.NoError(t, err)
+			var keys []string
+			for _, p := range fs.Parts {
+				keys = append(keys, p.Key)
+			}
+
+			rec := httptest.NewRecorder()
+			am.mux.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/am/api/v1/notifications/history", nil))
+
+			if maxEntries > 0 {
+				assert.Contains(t, keys, notificationHistoryStateKeyPrefix+"user")
+				assert.Equal(t, http.StatusOK, rec.Code)
+			} else {
+				assert.NotContains(t, keys, notificationHistoryStateKeyPrefix+"user")
+				assert.Equal(t, http.StatusNotFound, rec.Code)
+			}
+		})
+	}
+}
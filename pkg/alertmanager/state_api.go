@@ -0,0 +1,449 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"context"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/ring"
+	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
+	"github.com/grafana/regexp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+
+	"github.com/grafana/mimir/pkg/alertmanager/alertmanagerpb"
+	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+const (
+	// Prefixes of the keys of the replicated state parts. The keys are suffixed by the tenant ID.
+	notificationLogStateKeyPrefix = "nfl:"
+	silencesStateKeyPrefix        = "sil:"
+	// The notification history is not part of the exported state.
+	notificationHistoryStateKeyPrefix = "nhi:"
+
+	stateFormatJSON     = "json"
+	stateFormatProtobuf = "protobuf"
+
+	errReadingState    = "unable to read the Alertmanager state"
+	errDecodingState   = "unable to decode the Alertmanager state"
+	errValidatingState = "error validating Alertmanager state"
+	errImportingState  = "unable to import the Alertmanager state"
+)
+
+// maxUserStatePayloadSize is the maximum size of the body of a state import request.
+const maxUserStatePayloadSize = 16 << 20
+
+var errStateUserNotFound = errors.New("the Alertmanager is not running for the tenant, upload the Alertmanager configuration before importing the state")
+
+// UserState is the state of the Alertmanager of a tenant: its silences and notification log.
+type UserState struct {
+	Silences        []*silencepb.MeshSilence `json:"silences"`
+	NotificationLog []*nflogpb.MeshEntry     `json:"notification_log"`
+}
+
+// GetUserState exports the Alertmanager state of the tenant. The state is merged from all the replicas
+// running the tenant's Alertmanager, or read from the storage if no replica is running it.
+func (am *MultitenantAlertmanager) GetUserState(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	format, err := parseStateFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	state, err := am.readUserState(r.Context(), userID)
+	if err != nil {
+		if errors.Is(err, alertspb.ErrNotFound) {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		level.Error(logger).Log("msg", errReadingState, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingState, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	var payload []byte
+	switch format {
+	case stateFormatProtobuf:
+		var fs *clusterpb.FullState
+		fs, err = state.toFullState(userID)
+		if err == nil {
+			payload, err = (&alertspb.FullStateDesc{State: fs}).Marshal()
+		}
+		w.Header().Set("Content-Type", "application/x-protobuf")
+	default:
+		payload, err = json.Marshal(state)
+		w.Header().Set("Content-Type", "application/json")
+	}
+	if err != nil {
+		level.Error(logger).Log("msg", errReadingState, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errReadingState, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := w.Write(payload); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+}
+
+// SetUserState imports the Alertmanager state in the request body into all the replicas running the tenant's
+// Alertmanager. The imported state is merged with the current one: the silences and notification log entries
+// replace the existing ones only if they've been updated more recently.
+func (am *MultitenantAlertmanager) SetUserState(w http.ResponseWriter, r *http.Request) {
+	logger := util_log.WithContext(r.Context(), am.logger)
+	userID, err := tenant.TenantID(r.Context())
+	if err != nil {
+		level.Error(logger).Log("msg", errNoOrgID, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errNoOrgID, err.Error()), http.StatusUnauthorized)
+		return
+	}
+
+	format, err := parseStateFormat(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxUserStatePayloadSize))
+	if err != nil {
+		level.Error(logger).Log("msg", errDecodingState, "err", err.Error())
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("the Alertmanager state exceeds the maximum size of %d bytes", maxUserStatePayloadSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		http.Error(w, fmt.Sprintf("%s: %s", errDecodingState, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	state, err := decodeUserState(payload, format)
+	if err != nil {
+		level.Warn(logger).Log("msg", errDecodingState, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errDecodingState, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if err := state.validate(); err != nil {
+		level.Warn(logger).Log("msg", errValidatingState, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errValidatingState, err.Error()), http.StatusBadRequest)
+		return
+	}
+
+	if err := am.importUserState(r.Context(), userID, mergeUserStates(state)); err != nil {
+		if errors.Is(err, errStateUserNotFound) {
+			http.Error(w, err.Error(), http.StatusPreconditionFailed)
+			return
+		}
+		level.Error(logger).Log("msg", errImportingState, "err", err.Error())
+		http.Error(w, fmt.Sprintf("%s: %s", errImportingState, err.Error()), http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+func parseStateFormat(r *http.Request) (string, error) {
+	switch format := r.URL.Query().Get("format"); format {
+	case "", stateFormatJSON:
+		return stateFormatJSON, nil
+	case stateFormatProtobuf:
+		return stateFormatProtobuf, nil
+	default:
+		return "", fmt.Errorf("unsupported format %q, supported formats are %q and %q", format, stateFormatJSON, stateFormatProtobuf)
+	}
+}
+
+// readUserState reads the state of the tenant's Alertmanager from all its replicas, falling back to the
+// state persisted in the storage if no replica is running it.
+func (am *MultitenantAlertmanager) readUserState(ctx context.Context, userID string) (UserState, error) {
+	replicationSet, err := am.ring.Get(shardByUser(userID), RingOp, nil, nil, nil)
+	if err != nil {
+		return UserState{}, err
+	}
+
+	fullStates, err := am.readFullStateFromReplicas(ctx, userID, replicationSet.GetAddresses())
+	if errors.Is(err, errAllReplicasUserNotFound) {
+		fullStateDesc, err := am.store.GetFullState(ctx, userID)
+		if err != nil {
+			return UserState{}, err
+		}
+		fullStates = []*clusterpb.FullState{fullStateDesc.State}
+	} else if err != nil {
+		return UserState{}, err
+	}
+
+	var states []UserState
+	for _, fs := range fullStates {
+		if fs == nil {
+			continue
+		}
+		state, err := newUserStateFromParts(fs.Parts)
+		if err != nil {
+			return UserState{}, err
+		}
+		states = append(states, state)
+	}
+
+	return mergeUserStates(states...), nil
+}
+
+// importUserState merges the state into all the replicas running the tenant's Alertmanager.
+func (am *MultitenantAlertmanager) importUserState(ctx context.Context, userID string, state UserState) error {
+	fs, err := state.toFullState(userID)
+	if err != nil {
+		return err
+	}
+
+	return ring.DoBatchWithOptions(ctx, RingOp, am.ring, []uint32{shardByUser(userID)}, func(desc ring.InstanceDesc, _ []int) error {
+		c, err := am.alertmanagerClientsPool.GetClientFor(desc.GetAddr())
+		if err != nil {
+			return err
+		}
+
+		for i := range fs.Parts {
+			resp, err := c.UpdateState(user.InjectOrgID(ctx, userID), &fs.Parts[i])
+			if err != nil {
+				return err
+			}
+
+			switch resp.Status {
+			case alertmanagerpb.USER_NOT_FOUND:
+				return errStateUserNotFound
+			case alertmanagerpb.MERGE_ERROR:
+				return fmt.Errorf("failed to merge state for key %s in %s: %s", fs.Parts[i].Key, desc.GetAddr(), resp.Error)
+			}
+		}
+		return nil
+	}, ring.DoBatchOptions{})
+}
+
+// decodeUserState decodes the state in the input format. The protobuf format is the same used to persist the
+// state in the storage, and the state parts may belong to any tenant.
+func decodeUserState(payload []byte, format string) (UserState, error) {
+	if format == stateFormatJSON {
+		var state UserState
+		err := json.Unmarshal(payload, &state)
+		return state, err
+	}
+
+	var fullStateDesc alertspb.FullStateDesc
+	if err := fullStateDesc.Unmarshal(payload); err != nil {
+		return UserState{}, err
+	}
+	if fullStateDesc.State == nil {
+		return UserState{}, nil
+	}
+	return newUserStateFromParts(fullStateDesc.State.Parts)
+}
+
+// newUserStateFromParts decodes the silences and notification log entries from the replicated state parts.
+func newUserStateFromParts(parts []clusterpb.Part) (UserState, error) {
+	var state UserState
+
+	for _, p := range parts {
+		var err error
+		switch {
+		case strings.HasPrefix(p.Key, silencesStateKeyPrefix):
+			err = forEachDelimitedMessage(p.Data, func(b []byte) error {
+				var e silencepb.MeshSilence
+				if err := e.Unmarshal(b); err != nil {
+					return err
+				}
+				state.Silences = append(state.Silences, &e)
+				return nil
+			})
+		case strings.HasPrefix(p.Key, notificationLogStateKeyPrefix):
+			err = forEachDelimitedMessage(p.Data, func(b []byte) error {
+				var e nflogpb.MeshEntry
+				if err := e.Unmarshal(b); err != nil {
+					return err
+				}
+				state.NotificationLog = append(state.NotificationLog, &e)
+				return nil
+			})
+		case strings.HasPrefix(p.Key, notificationHistoryStateKeyPrefix):
+			continue
+		default:
+			err = errors.New("unknown key")
+		}
+		if err != nil {
+			return UserState{}, errors.Wrapf(err, "failed to decode state for key: %v", p.Key)
+		}
+	}
+
+	return state, nil
+}
+
+// toFullState encodes the state into the replicated state parts of the tenant.
+func (s UserState) toFullState(userID string) (*clusterpb.FullState, error) {
+	var silences, notificationLog []byte
+	var err error
+
+	for _, e := range s.Silences {
+		if silences, err = appendDelimitedMessage(silences, e); err != nil {
+			return nil, err
+		}
+	}
+	for _, e := range s.NotificationLog {
+		if notificationLog, err = appendDelimitedMessage(notificationLog, e); err != nil {
+			return nil, err
+		}
+	}
+
+	return &clusterpb.FullState{Parts: []clusterpb.Part{
+		{Key: notificationLogStateKeyPrefix + userID, Data: notificationLog},
+		{Key: silencesStateKeyPrefix + userID, Data: silences},
+	}}, nil
+}
+
+func (s UserState) validate() error {
+	for i, e := range s.Silences {
+		if e == nil || e.Silence == nil {
+			return fmt.Errorf("silence #%d is empty", i)
+		}
+
+		sil := e.Silence
+		switch {
+		case sil.Id == "":
+			return fmt.Errorf("silence #%d has no ID", i)
+		case len(sil.Matchers) == 0:
+			return fmt.Errorf("silence %s has no matchers", sil.Id)
+		case sil.StartsAt.IsZero() || sil.EndsAt.IsZero():
+			return fmt.Errorf("silence %s has no start or end time", sil.Id)
+		case sil.EndsAt.Before(sil.StartsAt):
+			return fmt.Errorf("silence %s ends before it starts", sil.Id)
+		case e.ExpiresAt.IsZero():
+			return fmt.Errorf("silence %s has no expiration time", sil.Id)
+		}
+
+		for _, m := range sil.Matchers {
+			if m == nil || m.Name == "" {
+				return fmt.Errorf("silence %s has a matcher with an empty label name", sil.Id)
+			}
+			if m.Type == silencepb.Matcher_REGEXP || m.Type == silencepb.Matcher_NOT_REGEXP {
+				if _, err := regexp.Compile("^(?:" + m.Pattern + ")$"); err != nil {
+					return fmt.Errorf("silence %s has an invalid regular expression matcher: %w", sil.Id, err)
+				}
+			}
+		}
+	}
+
+	for i, e := range s.NotificationLog {
+		switch {
+		case e == nil || e.Entry == nil:
+			return fmt.Errorf("notification log entry #%d is empty", i)
+		case len(e.Entry.GroupKey) == 0:
+			return fmt.Errorf("notification log entry #%d has no group key", i)
+		case e.Entry.Receiver == nil:
+			return fmt.Errorf("notification log entry #%d has no receiver", i)
+		case e.Entry.Timestamp.IsZero():
+			return fmt.Errorf("notification log entry #%d has no timestamp", i)
+		case e.ExpiresAt.IsZero():
+			return fmt.Errorf("notification log entry #%d has no expiration time", i)
+		}
+	}
+
+	return nil
+}
+
+// mergeUserStates merges the input states with the same semantics used by the Alertmanager to merge the replicated
+// state: for each silence and notification log entry, the most recently updated one is kept.
+func mergeUserStates(states ...UserState) UserState {
+	silences := map[string]*silencepb.MeshSilence{}
+	notificationLog := map[string]*nflogpb.MeshEntry{}
+
+	for _, state := range states {
+		for _, e := range state.Silences {
+			if e == nil || e.Silence == nil {
+				continue
+			}
+			if prev, ok := silences[e.Silence.Id]; !ok || prev.Silence.UpdatedAt.Before(e.Silence.UpdatedAt) {
+				silences[e.Silence.Id] = e
+			}
+		}
+
+		for _, e := range state.NotificationLog {
+			if e == nil || e.Entry == nil || e.Entry.Receiver == nil {
+				continue
+			}
+			key := notificationLogEntryKey(e.Entry)
+			if prev, ok := notificationLog[key]; !ok || prev.Entry.Timestamp.Before(e.Entry.Timestamp) {
+				notificationLog[key] = e
+			}
+		}
+	}
+
+	merged := UserState{
+		Silences:        make([]*silencepb.MeshSilence, 0, len(silences)),
+		NotificationLog: make([]*nflogpb.MeshEntry, 0, len(notificationLog)),
+	}
+	for _, e := range silences {
+		merged.Silences = append(merged.Silences, e)
+	}
+	for _, e := range notificationLog {
+		merged.NotificationLog = append(merged.NotificationLog, e)
+	}
+
+	// Sort the entries to have a stable output.
+	sort.Slice(merged.Silences, func(i, j int) bool {
+		return merged.Silences[i].Silence.Id < merged.Silences[j].Silence.Id
+	})
+	sort.Slice(merged.NotificationLog, func(i, j int) bool {
+		return notificationLogEntryKey(merged.NotificationLog[i].Entry) < notificationLogEntryKey(merged.NotificationLog[j].Entry)
+	})
+
+	return merged
+}
+
+// notificationLogEntryKey returns the key identifying a notification log entry, the same used by the Alertmanager.
+func notificationLogEntryKey(e *nflogpb.Entry) string {
+	return fmt.Sprintf("%s:%s/%s/%d", e.GroupKey, e.Receiver.GroupName, e.Receiver.Integration, e.Receiver.Idx)
+}
+
+// forEachDelimitedMessage calls f for each of the varint length-delimited messages in data, which is the
+// encoding used by the Alertmanager for the silences and notification log state.
+func forEachDelimitedMessage(data []byte, f func([]byte) error) error {
+	for len(data) > 0 {
+		size, n := binary.Uvarint(data)
+		if n <= 0 || size > uint64(len(data)-n) {
+			return errors.New("invalid length-delimited message")
+		}
+
+		end := n + int(size)
+		if err := f(data[n:end]); err != nil {
+			return err
+		}
+		data = data[end:]
+	}
+	return nil
+}
+
+func appendDelimitedMessage(buf []byte, m interface{ Marshal() ([]byte, error) }) ([]byte, error) {
+	b, err := m.Marshal()
+	if err != nil {
+		return nil, err
+	}
+	buf = binary.AppendUvarint(buf, uint64(len(b)))
+	return append(buf, b...), nil
+}
@@ -0,0 +1,242 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package alertmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/alertmanager/cluster/clusterpb"
+	"github.com/prometheus/alertmanager/nflog/nflogpb"
+	"github.com/prometheus/alertmanager/pkg/labels"
+	"github.com/prometheus/alertmanager/silence/silencepb"
+	"github.com/prometheus/alertmanager/types"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/alertmanager/alertspb"
+)
+
+func TestMultitenantAlertmanager_UserState(t *testing.T) {
+	ctx := context.Background()
+	store := prepareInMemoryAlertStore()
+	for _, userID := range []string{"user-1", "user-2"} {
+		require.NoError(t, store.SetAlertConfig(ctx, alertspb.AlertConfigDesc{
+			User:      userID,
+			RawConfig: simpleConfigOne,
+			Templates: []*alertspb.TemplateDesc{},
+		}))
+	}
+
+	cfg := mockAlertmanagerConfig(t)
+	am := setupSingleMultitenantAlertmanager(t, cfg, store, nil, log.NewNopLogger(), prometheus.NewPedanticRegistry())
+
+	// Create a silence for user-1.
+	silence := types.Silence{
+		Matchers: labels.Matchers{{Name: "instance", Value: "prometheus-one"}},
+		Comment:  "Created for a test case.",
+		StartsAt: time.Now(),
+		EndsAt:   time.Now().Add(time.Hour),
+	}
+	data, err := json.Marshal(silence)
+	require.NoError(t, err)
+
+	req := httptest.NewRequest(http.MethodPost, cfg.ExternalURL.String()+"/api/v2/silences", bytes.NewReader(data))
+	req.Header.Set("content-type", "application/json")
+	w := httptest.NewRecorder()
+	am.serveRequest(w, req.WithContext(user.InjectOrgID(req.Context(), "user-1")))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	exportState := func(userID, format string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/multitenant_alertmanager/state?format="+format, nil)
+		w := httptest.NewRecorder()
+		am.GetUserState(w, req.WithContext(user.InjectOrgID(req.Context(), userID)))
+		return w
+	}
+	importState := func(userID, format string, payload []byte) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/multitenant_alertmanager/state?format="+format, bytes.NewReader(payload))
+		w := httptest.NewRecorder()
+		am.SetUserState(w, req.WithContext(user.InjectOrgID(req.Context(), userID)))
+		return w
+	}
+
+	// Export the state of user-1 as JSON.
+	w = exportState("user-1", "json")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+
+	var exported UserState
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &exported))
+	require.Len(t, exported.Silences, 1)
+	assert.Equal(t, "Created for a test case.", exported.Silences[0].Silence.Comment)
+	assert.Empty(t, exported.NotificationLog)
+
+	// Export the state of user-1 as protobuf.
+	w = exportState("user-1", "protobuf")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(t, "application/x-protobuf", w.Header().Get("Content-Type"))
+	protobufState := w.Body.Bytes()
+
+	decoded, err := decodeUserState(protobufState, stateFormatProtobuf)
+	require.NoError(t, err)
+	assert.Equal(t, exported.Silences[0].Silence.Id, decoded.Silences[0].Silence.Id)
+
+	// The state of user-2 is empty.
+	w = exportState("user-2", "json")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.JSONEq(t, `{"silences": [], "notification_log": []}`, w.Body.String())
+
+	// Import the protobuf state of user-1 into user-2.
+	w = importState("user-2", "protobuf", protobufState)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = exportState("user-2", "json")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	var imported UserState
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &imported))
+	require.Len(t, imported.Silences, 1)
+	assert.Equal(t, exported.Silences[0].Silence.Id, imported.Silences[0].Silence.Id)
+
+	// The silence is active in the Alertmanager of user-2.
+	req = httptest.NewRequest(http.MethodGet, cfg.ExternalURL.String()+"/api/v2/silence/"+exported.Silences[0].Silence.Id, nil)
+	w = httptest.NewRecorder()
+	am.serveRequest(w, req.WithContext(user.InjectOrgID(req.Context(), "user-2")))
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	assert.Contains(t, w.Body.String(), `"state":"active"`)
+
+	// Importing an older version of the silence doesn't overwrite the current one.
+	older := *exported.Silences[0].Silence
+	older.Comment = "Older version."
+	older.UpdatedAt = older.UpdatedAt.Add(-time.Minute)
+	payload, err := json.Marshal(UserState{Silences: []*silencepb.MeshSilence{{Silence: &older, ExpiresAt: exported.Silences[0].ExpiresAt}}})
+	require.NoError(t, err)
+
+	w = importState("user-2", "json", payload)
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+
+	w = exportState("user-2", "json")
+	require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &imported))
+	require.Len(t, imported.Silences, 1)
+	assert.Equal(t, "Created for a test case.", imported.Silences[0].Silence.Comment)
+
+	t.Run("unsupported format", func(t *testing.T) {
+		w := exportState("user-1", "yaml")
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), `unsupported format "yaml"`)
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		w := importState("user-2", "json", []byte(`{"silences": [{"silence": {"id": "a"}}]}`))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "silence a has no matchers")
+
+		w = importState("user-2", "json", []byte(`{"silences": 1}`))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), errDecodingState)
+
+		w = importState("user-2", "protobuf", []byte(`invalid`))
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), errDecodingState)
+	})
+
+	t.Run("state too big", func(t *testing.T) {
+		w := importState("user-2", "json", []byte(`{"silences": []}`+strings.Repeat(" ", maxUserStatePayloadSize)))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, w.Code)
+		assert.Contains(t, w.Body.String(), "exceeds the maximum size")
+	})
+
+	t.Run("tenant without Alertmanager", func(t *testing.T) {
+		w := importState("user-3", "protobuf", protobufState)
+		assert.Equal(t, http.StatusPreconditionFailed, w.Code)
+		assert.Contains(t, w.Body.String(), errStateUserNotFound.Error())
+
+		// The state is read from the storage when no replica is running the tenant's Alertmanager.
+		w = exportState("user-3", "json")
+		assert.Equal(t, http.StatusNotFound, w.Code)
+
+		fs, err := exported.toFullState("user-3")
+		require.NoError(t, err)
+		require.NoError(t, store.SetFullState(ctx, "user-3", alertspb.FullStateDesc{State: fs}))
+
+		w = exportState("user-3", "json")
+		require.Equal(t, http.StatusOK, w.Code, w.Body.String())
+		var stored UserState
+		require.NoError(t, json.Unmarshal(w.Body.Bytes(), &stored))
+		require.Len(t, stored.Silences, 1)
+		assert.Equal(t, exported.Silences[0].Silence.Id, stored.Silences[0].Silence.Id)
+	})
+}
+
+func TestMergeUserStates(t *testing.T) {
+	now := time.Now().UTC()
+	receiver := &nflogpb.Receiver{GroupName: "group", Integration: "email"}
+
+	first := UserState{
+		Silences: []*silencepb.MeshSilence{
+			{Silence: &silencepb.Silence{Id: "b", Comment: "old", UpdatedAt: now}},
+			{Silence: &silencepb.Silence{Id: "a", Comment: "only", UpdatedAt: now}},
+		},
+		NotificationLog: []*nflogpb.MeshEntry{
+			{Entry: &nflogpb.Entry{GroupKey: []byte("key"), Receiver: receiver, Timestamp: now.Add(time.Minute)}},
+		},
+	}
+	second := UserState{
+		Silences: []*silencepb.MeshSilence{
+			{Silence: &silencepb.Silence{Id: "b", Comment: "new", UpdatedAt: now.Add(time.Minute)}},
+		},
+		NotificationLog: []*nflogpb.MeshEntry{
+			{Entry: &nflogpb.Entry{GroupKey: []byte("key"), Receiver: receiver, Timestamp: now}},
+			{Entry: &nflogpb.Entry{GroupKey: []byte("other"), Receiver: receiver, Timestamp: now}},
+		},
+	}
+
+	merged := mergeUserStates(first, second)
+
+	assert.Equal(t, []*silencepb.MeshSilence{first.Silences[1], second.Silences[0]}, merged.Silences)
+	assert.Equal(t, []*nflogpb.MeshEntry{first.NotificationLog[0], second.NotificationLog[1]}, merged.NotificationLog)
+}
+
+func TestUserState_FullStateRoundTrip(t *testing.T) {
+	now := time.Now().UTC()
+	state := UserState{
+		Silences: []*silencepb.MeshSilence{
+			{Silence: &silencepb.Silence{Id: "a", Matchers: []*silencepb.Matcher{{Name: "job", Pattern: "test"}}, StartsAt: now, EndsAt: now, UpdatedAt: now}, ExpiresAt: now},
+			{Silence: &silencepb.Silence{Id: "b", Matchers: []*silencepb.Matcher{{Name: "job", Pattern: "test"}}, StartsAt: now, EndsAt: now, UpdatedAt: now}, ExpiresAt: now},
+		},
+		NotificationLog: []*nflogpb.MeshEntry{
+			{Entry: &nflogpb.Entry{GroupKey: []byte("key"), Receiver: &nflogpb.Receiver{GroupName: "group"}, Timestamp: now}, ExpiresAt: now},
+		},
+	}
+	require.NoError(t, state.validate())
+
+	fs, err := state.toFullState("user")
+	require.NoError(t, err)
+	require.Len(t, fs.Parts, 2)
+	assert.Equal(t, "nfl:user", fs.Parts[0].Key)
+	assert.Equal(t, "sil:user", fs.Parts[1].Key)
+
+	decoded, err := newUserStateFromParts(fs.Parts)
+	require.NoError(t, err)
+	assert.Equal(t, state, decoded)
+
+	// The notification history is not part of the exported state.
+	decoded, err = newUserStateFromParts(append(fs.Parts, clusterpb.Part{Key: "nhi:user", Data: []byte("[]")}))
+	require.NoError(t, err)
+	assert.Equal(t, state, decoded)
+
+	_, err = newUserStateFromParts([]clusterpb.Part{{Key: "unknown:user"}})
+	assert.EqualError(t, err, "failed to decode state for key: unknown:user: unknown key")
+
+	_, err = newUserStateFromParts([]clusterpb.Part{{Key: "sil:user", Data: []byte{10, 1}}})
+	assert.True(t, err != nil && strings.Contains(err.Error(), "invalid length-delimited message"), err)
+}
@@ -7,7 +7,6 @@ package alertmanager
 
 import (
 	"context"
-	"fmt"
 	"sync"
 	"time"
 
@@ -154,8 +153,10 @@ func (s *state) MergePartialState(p *clusterpb.Part) error {
 	defer s.mtx.Unlock()
 	st, ok := s.states[p.Key]
 	if !ok {
-		s.partialStateMergesFailed.WithLabelValues(p.Key).Inc()
-		return fmt.Errorf("key not found while merging")
+		// The key can be unknown if the state has been enabled only on some replicas, for example the
+		// notification history, so it is skipped instead of failing the merge.
+		level.Debug(s.logger).Log("msg", "skipping partial state of unknown key", "key", p.Key)
+		return nil
 	}
 
 	if err := st.Merge(p.Data); err != nil {
@@ -273,7 +274,7 @@ func (s *state) mergeFullStates(fs []*clusterpb.FullState) error {
 
 			st, ok := s.states[p.Key]
 			if !ok {
-				level.Error(s.logger).Log("msg", "key not found while merging full state", "key", p.Key)
+				level.Debug(s.logger).Log("msg", "skipping full state part of unknown key", "key", p.Key)
 				continue
 			}
 
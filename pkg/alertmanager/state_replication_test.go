@@ -439,3 +439,24 @@ func TestStateReplication_GetFullState(t *testing.T) {
 		})
 	}
 }
+
+func TestStateReplication_MergePartialState(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	s := newReplicatedStates("user-1", 1, nil, nil, log.NewNopLogger(), reg)
+
+	key1State := &fakeState{}
+	s.AddState("key1", key1State, reg)
+
+	require.NoError(t, s.MergePartialState(&clusterpb.Part{Key: "key1", Data: []byte("Datum1")}))
+	assert.Equal(t, [][]byte{[]byte("Datum1")}, key1State.merges)
+
+	// Parts of unknown keys, for example of a state enabled only on some replicas, are skipped.
+	require.NoError(t, s.MergePartialState(&clusterpb.Part{Key: "unknown", Data: []byte("Datum2")}))
+	assert.Equal(t, [][]byte{[]byte("Datum1")}, key1State.merges)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP alertmanager_partial_state_merges_failed_total Number of times we have failed to merge a partial state received for a key.
+		# TYPE alertmanager_partial_state_merges_failed_total counter
+		alertmanager_partial_state_merges_failed_total{key="key1"} 0
+	`), "alertmanager_partial_state_merges_failed_total"))
+}
@@ -202,6 +202,7 @@ func NewQuerierHandler(
 	metadataSupplier querier.MetadataSupplier,
 	engine *promql.Engine,
 	distributor Distributor,
+	blocksFinder querier.BlocksFinder,
 	reg prometheus.Registerer,
 	logger log.Logger,
 	limits *validation.Overrides,
@@ -302,6 +303,7 @@ func NewQuerierHandler(
 	metadataQueryStats := usagestats.NewRequestsMiddleware("querier_metadata_query_requests")
 	cardinalityQueryStats := usagestats.NewRequestsMiddleware("querier_cardinality_query_requests")
 	formattingQueryStats := usagestats.NewRequestsMiddleware("querier_formatting_requests")
+	exportQueryStats := usagestats.NewRequestsMiddleware("querier_export_requests")
 
 	// TODO(gotjosh): This custom handler is temporary until we're able to vendor the changes in:
 	// https://github.com/prometheus/prometheus/pull/7125/files
@@ -316,7 +318,9 @@ func NewQuerierHandler(
 	router.Path(path.Join(prefix, "/api/v1/cardinality/label_names")).Methods("GET", "POST").Handler(cardinalityQueryStats.Wrap(querier.LabelNamesCardinalityHandler(distributor, limits)))
 	router.Path(path.Join(prefix, "/api/v1/cardinality/label_values")).Methods("GET", "POST").Handler(cardinalityQueryStats.Wrap(querier.LabelValuesCardinalityHandler(distributor, limits)))
 	router.Path(path.Join(prefix, "/api/v1/cardinality/active_series")).Methods("GET", "POST").Handler(cardinalityQueryStats.Wrap(querier.ActiveSeriesCardinalityHandler(distributor, limits)))
+	router.Path(path.Join(prefix, "/api/v1/cardinality/series_growth")).Methods("GET", "POST").Handler(cardinalityQueryStats.Wrap(querier.SeriesGrowthCardinalityHandler(distributor, queryable, blocksFinder, limits)))
 	router.Path(path.Join(prefix, "/api/v1/format_query")).Methods("GET", "POST").Handler(formattingQueryStats.Wrap(promRouter))
+	router.Path(path.Join(prefix, "/api/v1/export")).Methods("GET", "POST").Handler(exportQueryStats.Wrap(querier.ExportHandler(queryable, limits, logger)))
 
 	// Track execution time.
 	return stats.NewWallTimeMiddleware().Wrap(router)
@@ -8,6 +8,7 @@ import (
 	"net/url"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/pkg/errors"
 	"github.com/prometheus/common/model"
@@ -29,6 +30,8 @@ const (
 	defaultLimit       = 20
 	defaultCountMethod = InMemoryMethod
 
+	maxSeriesGrowthWindows = 10
+
 	stringParamSeparator = rune(0)
 	stringValueSeparator = rune(1)
 )
@@ -320,3 +323,124 @@ func (r *ActiveSeriesRequest) String() string {
 
 	return b.String()
 }
+
+var defaultSeriesGrowthWindows = []time.Duration{24 * time.Hour, 7 * 24 * time.Hour}
+
+type SeriesGrowthRequest struct {
+	Matchers   []*labels.Matcher
+	Windows    []time.Duration
+	LabelNames []model.LabelName
+	Limit      int
+}
+
+// DecodeSeriesGrowthRequest decodes the input http.Request into a SeriesGrowthRequest.
+// The input http.Request can either be a GET or POST with URL-encoded parameters.
+func DecodeSeriesGrowthRequest(r *http.Request) (*SeriesGrowthRequest, error) {
+	if err := r.ParseForm(); err != nil {
+		return nil, err
+	}
+
+	return DecodeSeriesGrowthRequestFromValues(r.Form)
+}
+
+// DecodeSeriesGrowthRequestFromValues is like DecodeSeriesGrowthRequest but takes url.Values in input.
+func DecodeSeriesGrowthRequestFromValues(values url.Values) (*SeriesGrowthRequest, error) {
+	var (
+		parsed = &SeriesGrowthRequest{}
+		err    error
+	)
+
+	// The selector is required, because the series are looked up over the windows.
+	if !values.Has("selector") {
+		return nil, fmt.Errorf("missing 'selector' parameter")
+	}
+
+	parsed.Matchers, err = extractSelector(values)
+	if err != nil {
+		return nil, err
+	}
+
+	parsed.Windows, err = extractWindows(values)
+	if err != nil {
+		return nil, err
+	}
+
+	// Label names are optional for this request.
+	if len(values["label_names[]"]) > 0 {
+		parsed.LabelNames, err = extractLabelNames(values)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	parsed.Limit, err = extractLimit(values)
+	if err != nil {
+		return nil, err
+	}
+
+	return parsed, nil
+}
+
+// extractWindows parses and validates request param `windows[]` if it's defined, otherwise returns the default windows.
+// The order of the windows is preserved, because the first one is used to sort the response.
+func extractWindows(values url.Values) ([]time.Duration, error) {
+	windowsParams := values["windows[]"]
+	if len(windowsParams) == 0 {
+		return defaultSeriesGrowthWindows, nil
+	}
+	if len(windowsParams) > maxSeriesGrowthWindows {
+		return nil, fmt.Errorf("'windows[]' param cannot have more than '%v' values", maxSeriesGrowthWindows)
+	}
+
+	windows := make([]time.Duration, 0, len(windowsParams))
+	for _, windowParam := range windowsParams {
+		window, err := model.ParseDuration(windowParam)
+		if err != nil || window <= 0 {
+			return nil, fmt.Errorf("invalid 'windows[]' param '%v'", windowParam)
+		}
+		if slices.Contains(windows, time.Duration(window)) {
+			return nil, fmt.Errorf("duplicated 'windows[]' param '%v'", windowParam)
+		}
+		windows = append(windows, time.Duration(window))
+	}
+
+	return windows, nil
+}
+
+// String returns a full representation of the request. The returned string can be
+// used to uniquely identify the request.
+func (r *SeriesGrowthRequest) String() string {
+	b := strings.Builder{}
+
+	// Add matchers.
+	for idx, matcher := range r.Matchers {
+		if idx > 0 {
+			b.WriteRune(stringValueSeparator)
+		}
+		b.WriteString(matcher.String())
+	}
+
+	// Add windows.
+	b.WriteRune(stringParamSeparator)
+	for idx, window := range r.Windows {
+		if idx > 0 {
+			b.WriteRune(stringValueSeparator)
+		}
+		b.WriteString(model.Duration(window).String())
+	}
+
+	// Add label names.
+	b.WriteRune(stringParamSeparator)
+	for idx, name := range r.LabelNames {
+		if idx > 0 {
+			b.WriteRune(stringValueSeparator)
+		}
+		b.WriteString(string(name))
+	}
+
+	// Add limit.
+	b.WriteRune(stringParamSeparator)
+	b.WriteString(strconv.Itoa(r.Limit))
+
+	return b.String()
+}
@@ -7,6 +7,7 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
@@ -147,3 +148,87 @@ func TestActiveSeriesRequest_String(t *testing.T) {
 
 	assert.Equal(t, "first=\"1\"\x01second!=\"2\"", req.String())
 }
+
+func TestDecodeSeriesGrowthRequest(t *testing.T) {
+	var (
+		params = url.Values{
+			"selector":      []string{`{second!="2",first="1"}`},
+			"windows[]":     []string{"1d", "1h"},
+			"label_names[]": []string{"pod", "job"},
+			"limit":         []string{"100"},
+		}
+
+		expected = &SeriesGrowthRequest{
+			Matchers: []*labels.Matcher{
+				labels.MustNewMatcher(labels.MatchEqual, "first", "1"),
+				labels.MustNewMatcher(labels.MatchNotEqual, "second", "2"),
+			},
+			Windows:    []time.Duration{24 * time.Hour, time.Hour},
+			LabelNames: []model.LabelName{"job", "pod"},
+			Limit:      100,
+		}
+	)
+
+	t.Run("DecodeSeriesGrowthRequest() GET request", func(t *testing.T) {
+		req, err := http.NewRequest("GET", "http://localhost?"+params.Encode(), nil)
+		require.NoError(t, err)
+
+		actual, err := DecodeSeriesGrowthRequest(req)
+		require.NoError(t, err)
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("DecodeSeriesGrowthRequest() POST request", func(t *testing.T) {
+		req, err := http.NewRequest("POST", "http://localhost/", strings.NewReader(params.Encode()))
+		require.NoError(t, err)
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+		actual, err := DecodeSeriesGrowthRequest(req)
+		require.NoError(t, err)
+
+		assert.Equal(t, expected, actual)
+	})
+
+	t.Run("DecodeSeriesGrowthRequestFromValues() with default values", func(t *testing.T) {
+		actual, err := DecodeSeriesGrowthRequestFromValues(url.Values{"selector": []string{`{job="test"}`}})
+		require.NoError(t, err)
+
+		assert.Equal(t, &SeriesGrowthRequest{
+			Matchers: []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "job", "test")},
+			Windows:  []time.Duration{24 * time.Hour, 7 * 24 * time.Hour},
+			Limit:    defaultLimit,
+		}, actual)
+	})
+
+	for name, values := range map[string]url.Values{
+		"invalid window":      {"selector": []string{`{job="test"}`}, "windows[]": []string{"1x"}},
+		"negative window":     {"selector": []string{`{job="test"}`}, "windows[]": []string{"-1h"}},
+		"zero window":         {"selector": []string{`{job="test"}`}, "windows[]": []string{"0s"}},
+		"duplicated window":   {"selector": []string{`{job="test"}`}, "windows[]": []string{"1h", "60m"}},
+		"too many windows":    {"selector": []string{`{job="test"}`}, "windows[]": []string{"1h", "2h", "3h", "4h", "5h", "6h", "7h", "8h", "9h", "10h", "11h"}},
+		"invalid label name":  {"selector": []string{`{job="test"}`}, "label_names[]": []string{"foo-bar"}},
+		"invalid selector":    {"selector": []string{"{"}},
+		"missing selector":    {"windows[]": []string{"1h"}},
+		"limit out of bounds": {"selector": []string{`{job="test"}`}, "limit": []string{"1000"}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			_, err := DecodeSeriesGrowthRequestFromValues(values)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func TestSeriesGrowthRequest_String(t *testing.T) {
+	req := &SeriesGrowthRequest{
+		Matchers: []*labels.Matcher{
+			labels.MustNewMatcher(labels.MatchEqual, "first", "1"),
+			labels.MustNewMatcher(labels.MatchNotEqual, "second", "2"),
+		},
+		Windows:    []time.Duration{time.Hour, 24 * time.Hour},
+		LabelNames: []model.LabelName{"foo", "bar"},
+		Limit:      100,
+	}
+
+	assert.Equal(t, "first=\"1\"\x01second!=\"2\"\x001h\x011d\x00foo\x01bar\x00100", req.String())
+}
@@ -34,6 +34,7 @@ import (
 	mimir_testutil "github.com/grafana/mimir/pkg/storage/tsdb/testutil"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/test"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 type testBlocksCleanerOptions struct {
@@ -1035,6 +1036,10 @@ type mockConfigProvider struct {
 	userPartialBlockDelay        map[string]time.Duration
 	userPartialBlockDelayInvalid map[string]bool
 	verifyChunks                 map[string]bool
+	seriesDeletionEnabled        map[string]bool
+	downsample5mAfter            map[string]time.Duration
+	downsample1hAfter            map[string]time.Duration
+	retentionRules               map[string][]*validation.RetentionRule
 }
 
 func newMockConfigProvider() *mockConfigProvider {
@@ -1048,6 +1053,10 @@ func newMockConfigProvider() *mockConfigProvider {
 		userPartialBlockDelay:        make(map[string]time.Duration),
 		userPartialBlockDelayInvalid: make(map[string]bool),
 		verifyChunks:                 make(map[string]bool),
+		seriesDeletionEnabled:        make(map[string]bool),
+		downsample5mAfter:            make(map[string]time.Duration),
+		downsample1hAfter:            make(map[string]time.Duration),
+		retentionRules:               make(map[string][]*validation.RetentionRule),
 	}
 }
 
@@ -1099,6 +1108,22 @@ func (m *mockConfigProvider) CompactorBlockUploadMaxBlockSizeBytes(user string)
 	return m.blockUploadMaxBlockSizeBytes[user]
 }
 
+func (m *mockConfigProvider) CompactorSeriesDeletionEnabled(tenantID string) bool {
+	return m.seriesDeletionEnabled[tenantID]
+}
+
+func (m *mockConfigProvider) CompactorDownsample5mAfter(user string) time.Duration {
+	return m.downsample5mAfter[user]
+}
+
+func (m *mockConfigProvider) CompactorDownsample1hAfter(user string) time.Duration {
+	return m.downsample1hAfter[user]
+}
+
+func (m *mockConfigProvider) CompactorRetentionRules(user string) []*validation.RetentionRule {
+	return m.retentionRules[user]
+}
+
 func (m *mockConfigProvider) S3SSEType(string) string {
 	return ""
 }
@@ -269,6 +269,15 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 		return false, nil, errors.Wrap(err, "create compaction job dir")
 	}
 
+	if job.Downsampling() {
+		downsampledID, err := c.runDownsamplingJob(ctx, jobLogger, job, subDir)
+		if err != nil {
+			return false, nil, err
+		}
+		// The block may now be downsampled to a coarser resolution.
+		return true, []ulid.ULID{downsampledID}, nil
+	}
+
 	toCompact, err := c.planner.Plan(ctx, job.metasByMinTime)
 	if err != nil {
 		return false, nil, errors.Wrap(err, "plan compaction")
@@ -286,6 +295,12 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 
 	level.Info(jobLogger).Log("msg", "compaction available and planned; downloading blocks", "blocks", len(toCompact), "plan", fmt.Sprintf("%v", toCompact))
 
+	// All tombstones overlapping the compacted time range are applied to the source blocks (unless
+	// already applied in the past), so all of them are applied to the resulting blocks.
+	// NOTE: Block intervals are half-open: [MinTime, MaxTime).
+	appliedTombstones := job.Tombstones().Overlapping(toCompactMinTime.UnixMilli(), toCompactMaxTime.UnixMilli()-1)
+	tombstonesApplied := atomic.NewBool(false)
+
 	// Once we have a plan we need to download the actual data.
 	downloadBegin := time.Now()
 
@@ -320,6 +335,14 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 		if err := stats.OutOfOrderLabelsErr(); err != nil {
 			return errors.Wrapf(err, "block id %s", meta.ULID)
 		}
+
+		if pending := pendingTombstones(meta, appliedTombstones); len(pending) > 0 {
+			level.Info(jobLogger).Log("msg", "applying series deletion tombstones to block", "block", meta.ULID, "tombstones", fmt.Sprintf("%v", pending.GetIDs()))
+			if err := applyTombstones(ctx, jobLogger, bdir, pending); err != nil {
+				return err
+			}
+			tombstonesApplied.Store(true)
+		}
 		return nil
 	})
 	if err != nil {
@@ -351,7 +374,8 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 		// Prometheus compactor found that the compacted block would have no samples.
 		level.Info(jobLogger).Log("msg", "compacted block would have no samples, deleting source blocks", "blocks", fmt.Sprintf("%v", blocksToCompactDirs))
 		for _, meta := range toCompact {
-			if meta.Stats.NumSamples == 0 {
+			// When tombstones have been applied, the source blocks may have samples which have all been deleted.
+			if meta.Stats.NumSamples == 0 || tombstonesApplied.Load() {
 				if err := deleteBlock(c.bkt, meta.ULID, filepath.Join(subDir, meta.ULID.String()), jobLogger, c.metrics.blocksMarkedForDeletion); err != nil {
 					level.Warn(jobLogger).Log("msg", "failed to mark for deletion an empty block found during compaction", "block", meta.ULID, "err", err)
 				}
@@ -367,9 +391,13 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 	uploadBegin := time.Now()
 	uploadedBlocks := atomic.NewInt64(0)
 
-	if err = verifyCompactedBlocksTimeRanges(compIDs, toCompactMinTime.UnixMilli(), toCompactMaxTime.UnixMilli(), subDir); err != nil {
-		level.Warn(jobLogger).Log("msg", "compacted blocks verification failed", "err", err)
-		c.metrics.compactionBlocksVerificationFailed.Inc()
+	// Deleted samples may be at the edges of the source blocks time range, so the verification is skipped
+	// when tombstones have been applied.
+	if !tombstonesApplied.Load() {
+		if err = verifyCompactedBlocksTimeRanges(compIDs, toCompactMinTime.UnixMilli(), toCompactMaxTime.UnixMilli(), subDir); err != nil {
+			level.Warn(jobLogger).Log("msg", "compacted blocks verification failed", "err", err)
+			c.metrics.compactionBlocksVerificationFailed.Inc()
+		}
 	}
 
 	blocksToUpload := convertCompactionResultToForEachJobs(compIDs, job.UseSplitting(), jobLogger)
@@ -387,10 +415,11 @@ func (c *BucketCompactor) runCompactionJob(ctx context.Context, job *Job) (shoul
 		}
 
 		newMeta, err := block.InjectThanosMeta(jobLogger, bdir, block.ThanosMeta{
-			Labels:       newLabels,
-			Downsample:   block.ThanosDownsample{Resolution: job.Resolution()},
-			Source:       block.CompactorSource,
-			SegmentFiles: block.GetSegmentFiles(bdir),
+			Labels:            newLabels,
+			Downsample:        block.ThanosDownsample{Resolution: job.Resolution()},
+			Source:            block.CompactorSource,
+			SegmentFiles:      block.GetSegmentFiles(bdir),
+			AppliedTombstones: appliedTombstones.GetIDs(),
 		}, nil)
 		if err != nil {
 			return errors.Wrapf(err, "failed to finalize the block %s", bdir)
@@ -645,6 +674,7 @@ type BucketCompactorMetrics struct {
 	blocksMarkedForDeletion            prometheus.Counter
 	blocksMarkedForNoCompact           *prometheus.CounterVec
 	blocksMaxTimeDelta                 prometheus.Histogram
+	blocksDownsampled                  *prometheus.CounterVec
 }
 
 // NewBucketCompactorMetrics makes a new BucketCompactorMetrics.
@@ -680,6 +710,10 @@ func NewBucketCompactorMetrics(blocksMarkedForDeletion prometheus.Counter, reg p
 			Help:    "Difference between now and the max time of a block being compacted in seconds.",
 			Buckets: prometheus.LinearBuckets(86400, 43200, 8), // 1 to 5 days, in 12 hour intervals
 		}),
+		blocksDownsampled: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_compactor_blocks_downsampled_total",
+			Help: "Total number of blocks downsampled, by resolution.",
+		}, []string{"resolution"}),
 	}
 	bcm.blocksMarkedForNoCompact.WithLabelValues(block.OutOfOrderChunksNoCompactReason).Add(0)
 	bcm.blocksMarkedForNoCompact.WithLabelValues(block.CriticalNoCompactReason).Add(0)
@@ -972,6 +1006,10 @@ func (c *BucketCompactor) blockMaxTimeDeltas(now time.Time, jobs []*Job) []float
 	var out []float64
 
 	for _, j := range jobs {
+		// Downsampled blocks are old by design, so they're not tracked.
+		if j.Downsampling() {
+			continue
+		}
 		for _, m := range j.Metas() {
 			out = append(out, now.Sub(time.UnixMilli(m.MaxTime)).Seconds())
 		}
@@ -35,6 +35,7 @@ import (
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
 	"github.com/grafana/mimir/pkg/util"
 	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
 )
 
 const (
@@ -216,6 +217,19 @@ type ConfigProvider interface {
 
 	// CompactorBlockUploadMaxBlockSizeBytes returns the maximum size in bytes of a block that is allowed to be uploaded or validated for a given user.
 	CompactorBlockUploadMaxBlockSizeBytes(userID string) int64
+
+	// CompactorSeriesDeletionEnabled returns whether the series deletion API is enabled for a given tenant,
+	// and whether the compactor should apply its series deletion tombstones.
+	CompactorSeriesDeletionEnabled(tenantID string) bool
+
+	// CompactorDownsample5mAfter returns the age after which blocks are downsampled to 5 minutes for a given user. 0 = disabled.
+	CompactorDownsample5mAfter(userID string) time.Duration
+
+	// CompactorDownsample1hAfter returns the age after which blocks are downsampled to 1 hour for a given user. 0 = disabled.
+	CompactorDownsample1hAfter(userID string) time.Duration
+
+	// CompactorRetentionRules returns the retention rules of the series matching a selector for a given user.
+	CompactorRetentionRules(userID string) []*validation.RetentionRule
 }
 
 // MultitenantCompactor is a multi-tenant TSDB blocks compactor based on Thanos.
@@ -759,10 +773,26 @@ func (c *MultitenantCompactor) compactUser(ctx context.Context, userID string) e
 		return errors.Wrap(err, "failed to create syncer")
 	}
 
+	var tombstones mimir_tsdb.Tombstones
+	if c.cfgProvider.CompactorSeriesDeletionEnabled(userID) {
+		tombstones, err = mimir_tsdb.ReadTombstones(ctx, userBucket, userLogger)
+		if err != nil {
+			return errors.Wrap(err, "failed to read series deletion tombstones")
+		}
+	}
+	retention, err := retentionTombstones(c.cfgProvider.CompactorRetentionRules(userID), time.Now())
+	if err != nil {
+		return errors.Wrap(err, "failed to parse retention rules")
+	}
+
+	grouper := c.blocksGrouperFactory(ctx, c.compactorCfg, c.cfgProvider, userID, userLogger, reg)
+	grouper = newTombstonesGrouper(grouper, userID, tombstones, retention)
+	grouper = newDownsamplingGrouper(grouper, userID, c.cfgProvider.CompactorDownsample5mAfter(userID), c.cfgProvider.CompactorDownsample1hAfter(userID))
+
 	compactor, err := NewBucketCompactor(
 		userLogger,
 		syncer,
-		c.blocksGrouperFactory(ctx, c.compactorCfg, c.cfgProvider, userID, userLogger, reg),
+		grouper,
 		c.blocksPlanner,
 		c.blocksCompactor,
 		path.Join(c.compactorCfg.DataDir, "compact"),
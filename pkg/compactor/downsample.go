@@ -0,0 +1,223 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+// downsamplingResolutions are the resolutions blocks are downsampled to, from the finest.
+var downsamplingResolutions = []int64{block.Resolution5m, block.Resolution1h}
+
+// downsamplingGrouper wraps a Grouper to downsample blocks. A downsampling job is created for each raw
+// block and resolution, if the block is older than the tenant's threshold for the resolution, it hasn't been
+// downsampled to the resolution yet, and it's not part of any other job, so it's not going to be compacted
+// any further. Blocks are downsampled to 1 hour from their 5 minutes downsampled block, when there's one.
+type downsamplingGrouper struct {
+	Grouper
+
+	userID string
+	// thresholds are the min age of the blocks to downsample, by resolution. Resolutions without
+	// a threshold are not enabled.
+	thresholds map[int64]time.Duration
+	now        func() time.Time
+}
+
+func newDownsamplingGrouper(grouper Grouper, userID string, downsample5mAfter, downsample1hAfter time.Duration) Grouper {
+	thresholds := map[int64]time.Duration{}
+	if downsample5mAfter > 0 {
+		thresholds[block.Resolution5m] = downsample5mAfter
+	}
+	if downsample1hAfter > 0 {
+		thresholds[block.Resolution1h] = downsample1hAfter
+	}
+	if len(thresholds) == 0 {
+		return grouper
+	}
+
+	return &downsamplingGrouper{
+		Grouper:    grouper,
+		userID:     userID,
+		thresholds: thresholds,
+		now:        time.Now,
+	}
+}
+
+// Groups implements Grouper.
+func (g *downsamplingGrouper) Groups(blocks map[ulid.ULID]*block.Meta) ([]*Job, error) {
+	jobs, err := g.Grouper.Groups(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	inJobs := map[ulid.ULID]struct{}{}
+	for _, job := range jobs {
+		for _, id := range job.IDs() {
+			inJobs[id] = struct{}{}
+		}
+	}
+
+	// Index the downsampled blocks by time range, to look up the ones of each raw block.
+	downsampled := map[downsampledBlockKey][]*block.Meta{}
+	for _, meta := range blocks {
+		if meta.Thanos.Downsample.Resolution != block.ResolutionRaw {
+			key := downsampledBlockKey{resolution: meta.Thanos.Downsample.Resolution, minTime: meta.MinTime, maxTime: meta.MaxTime}
+			downsampled[key] = append(downsampled[key], meta)
+		}
+	}
+
+	now := g.now()
+	var downsamplingJobs []*Job
+	for id, meta := range blocks {
+		if meta.Thanos.Downsample.Resolution != block.ResolutionRaw {
+			continue
+		}
+		if _, ok := inJobs[id]; ok {
+			continue
+		}
+
+		age := now.Sub(time.UnixMilli(meta.MaxTime))
+		for _, resolution := range downsamplingResolutions {
+			if !g.due(resolution, age) || findDownsampledBlock(downsampled, meta, resolution) != nil {
+				continue
+			}
+
+			// Blocks are downsampled to 1 hour from their 5 minutes downsampled block, so if the block
+			// is due to be downsampled to 5 minutes too, it's downsampled to 1 hour once that's done.
+			source := meta
+			if resolution == block.Resolution1h {
+				finer := findDownsampledBlock(downsampled, meta, block.Resolution5m)
+				if finer == nil && g.due(block.Resolution5m, age) {
+					continue
+				}
+				if finer != nil {
+					if _, ok := inJobs[finer.ULID]; !ok {
+						source = finer
+					}
+				}
+			}
+
+			job := NewDownsamplingJob(
+				g.userID,
+				fmt.Sprintf("%s-downsample-%d-%s", DefaultGroupKey(meta.Thanos), resolution, id.String()),
+				source,
+				resolution,
+				fmt.Sprintf("%s-%d", id.String(), resolution),
+			)
+			downsamplingJobs = append(downsamplingJobs, job)
+		}
+	}
+
+	// Keep the output stable, given blocks are iterated in random order.
+	sort.Slice(downsamplingJobs, func(i, j int) bool {
+		return downsamplingJobs[i].Key() < downsamplingJobs[j].Key()
+	})
+
+	return append(jobs, downsamplingJobs...), nil
+}
+
+// due returns whether blocks of the given age are due to be downsampled to the resolution.
+func (g *downsamplingGrouper) due(resolution int64, age time.Duration) bool {
+	threshold, enabled := g.thresholds[resolution]
+	return enabled && age >= threshold
+}
+
+// findDownsampledBlock returns the block holding the data of the raw block downsampled to the resolution,
+// or nil if there's none. A block downsampled after the raw block got new data, for example because of a
+// late upload, doesn't hold all the source blocks of the raw block, and so it's not returned.
+func findDownsampledBlock(downsampled map[downsampledBlockKey][]*block.Meta, raw *block.Meta, resolution int64) *block.Meta {
+	var found *block.Meta
+	for _, meta := range downsampled[downsampledBlockKey{resolution: resolution, minTime: raw.MinTime, maxTime: raw.MaxTime}] {
+		if !labels.Equal(labels.FromMap(meta.Thanos.Labels), labels.FromMap(raw.Thanos.Labels)) {
+			continue
+		}
+		if !containsAllSources(meta.Compaction.Sources, raw.Compaction.Sources) {
+			continue
+		}
+		// There may be multiple downsampled blocks until the outdated ones are deduplicated,
+		// so the most recent one is returned to keep the output stable.
+		if found == nil || meta.ULID.Compare(found.ULID) > 0 {
+			found = meta
+		}
+	}
+	return found
+}
+
+type downsampledBlockKey struct {
+	resolution       int64
+	minTime, maxTime int64
+}
+
+func containsAllSources(sources, wanted []ulid.ULID) bool {
+	set := make(map[ulid.ULID]struct{}, len(sources))
+	for _, id := range sources {
+		set[id] = struct{}{}
+	}
+	for _, id := range wanted {
+		if _, ok := set[id]; !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// runDownsamplingJob downsamples the block of the job to the job resolution, and uploads the downsampled block
+// into the bucket the block was retrieved from. Unlike compacted blocks, the source block isn't marked for
+// deletion, because it's still needed by queries which can't be answered from the downsampled data.
+func (c *BucketCompactor) runDownsamplingJob(ctx context.Context, jobLogger log.Logger, job *Job, subDir string) (ulid.ULID, error) {
+	meta := job.Metas()[0]
+	bdir := filepath.Join(subDir, meta.ULID.String())
+	jobLogger = log.With(jobLogger, "block", meta.ULID, "resolution", formatResolution(job.Resolution()))
+
+	downloadBegin := time.Now()
+	if err := block.Download(ctx, jobLogger, c.bkt, meta.ULID, bdir); err != nil {
+		return ulid.ULID{}, errors.Wrapf(err, "download block %s", meta.ULID)
+	}
+
+	elapsed := time.Since(downloadBegin)
+	level.Info(jobLogger).Log("msg", "downloaded block; downsampling block", "duration", elapsed, "duration_ms", elapsed.Milliseconds())
+
+	downsamplingBegin := time.Now()
+	newMeta, err := block.Downsample(ctx, jobLogger, bdir, subDir, job.Resolution())
+	if err != nil {
+		return ulid.ULID{}, errors.Wrapf(err, "downsample block %s", meta.ULID)
+	}
+
+	elapsed = time.Since(downsamplingBegin)
+	level.Info(jobLogger).Log("msg", "downsampled block", "result_block", newMeta.ULID, "duration", elapsed, "duration_ms", elapsed.Milliseconds())
+
+	newDir := filepath.Join(subDir, newMeta.ULID.String())
+
+	// Ensure the downsampled block is valid.
+	if err := block.VerifyBlock(ctx, jobLogger, newDir, newMeta.MinTime, newMeta.MaxTime, false); err != nil {
+		return ulid.ULID{}, errors.Wrapf(err, "invalid result block %s", newDir)
+	}
+
+	uploadBegin := time.Now()
+	if err := block.Upload(ctx, jobLogger, c.bkt, newDir, nil); err != nil {
+		return ulid.ULID{}, errors.Wrapf(err, "upload of %s failed", newMeta.ULID)
+	}
+	c.metrics.blocksDownsampled.WithLabelValues(formatResolution(job.Resolution())).Inc()
+
+	elapsed = time.Since(uploadBegin)
+	level.Info(jobLogger).Log("msg", "uploaded downsampled block", "result_block", newMeta.ULID, "duration", elapsed, "duration_ms", elapsed.Milliseconds())
+	return newMeta.ULID, nil
+}
+
+// formatResolution returns the resolution, in milliseconds, formatted as a duration.
+func formatResolution(resolution int64) string {
+	return model.Duration(time.Duration(resolution) * time.Millisecond).String()
+}
@@ -0,0 +1,199 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+func TestMultitenantCompactor_ShouldDownsampleOldBlocks(t *testing.T) {
+	const (
+		userID     = "user-1"
+		numSeries  = 10
+		blockRange = 2 * time.Hour
+	)
+
+	blockRangeMillis := blockRange.Milliseconds()
+
+	storageDir := t.TempDir()
+	fetcherDir := t.TempDir()
+
+	storageCfg := mimir_tsdb.BlocksStorageConfig{}
+	flagext.DefaultValues(&storageCfg)
+	storageCfg.Bucket.Backend = bucket.Filesystem
+	storageCfg.Bucket.Filesystem.Directory = storageDir
+
+	compactorCfg := prepareConfig(t)
+	compactorCfg.DataDir = t.TempDir()
+	compactorCfg.BlockRanges = mimir_tsdb.DurationList{blockRange}
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.downsample5mAfter[userID] = 24 * time.Hour
+	cfgProvider.downsample1hAfter[userID] = 48 * time.Hour
+
+	logger := log.NewLogfmtLogger(os.Stdout)
+	reg := prometheus.NewPedanticRegistry()
+	ctx := context.Background()
+
+	bucketClient, err := bucket.NewClient(ctx, storageCfg.Bucket, "test", logger, nil)
+	require.NoError(t, err)
+
+	// Create an old block, due to be downsampled to both resolutions, and a recent one which is not.
+	oldBlockID := createTSDBBlock(t, bucketClient, userID, blockRangeMillis, 2*blockRangeMillis, numSeries, nil)
+	recentMinT := time.Now().Add(-3 * blockRange).Truncate(blockRange).UnixMilli()
+	recentBlockID := createTSDBBlock(t, bucketClient, userID, recentMinT, recentMinT+blockRangeMillis, numSeries, nil)
+
+	c, err := NewMultitenantCompactor(compactorCfg, storageCfg, cfgProvider, logger, reg)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	// Wait until the first compaction run completed.
+	test.Poll(t, 15*time.Second, nil, func() interface{} {
+		return testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_compactor_runs_completed_total Total number of compaction runs successfully completed.
+			# TYPE cortex_compactor_runs_completed_total counter
+			cortex_compactor_runs_completed_total 1
+		`), "cortex_compactor_runs_completed_total")
+	})
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_compactor_blocks_downsampled_total Total number of blocks downsampled, by resolution.
+		# TYPE cortex_compactor_blocks_downsampled_total counter
+		cortex_compactor_blocks_downsampled_total{resolution="1h"} 1
+		cortex_compactor_blocks_downsampled_total{resolution="5m"} 1
+	`), "cortex_compactor_blocks_downsampled_total"))
+
+	// List back any (non deleted) block from the storage.
+	userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+	fetcher, err := block.NewMetaFetcher(logger, 1, userBucket, fetcherDir, reg, nil)
+	require.NoError(t, err)
+	metas, partials, err := fetcher.FetchWithoutMarkedForDeletion(ctx)
+	require.NoError(t, err)
+	require.Empty(t, partials)
+
+	// The source blocks are kept, and the old block has been downsampled to both resolutions.
+	require.Len(t, metas, 4)
+	require.Contains(t, metas, oldBlockID)
+	require.Contains(t, metas, recentBlockID)
+
+	byResolution := map[int64]*block.Meta{}
+	for _, meta := range metas {
+		if meta.Thanos.Downsample.Resolution != block.ResolutionRaw {
+			byResolution[meta.Thanos.Downsample.Resolution] = meta
+		}
+	}
+	require.Len(t, byResolution, 2)
+
+	for _, resolution := range []int64{block.Resolution5m, block.Resolution1h} {
+		meta := byResolution[resolution]
+		require.NotNil(t, meta)
+		assert.Equal(t, metas[oldBlockID].MinTime, meta.MinTime)
+		assert.Equal(t, metas[oldBlockID].MaxTime, meta.MaxTime)
+		assert.Equal(t, []ulid.ULID{oldBlockID}, meta.Compaction.Sources)
+		// Each series of the source block has been downsampled to a series for each aggregate.
+		assert.Equal(t, uint64(numSeries*len(block.Aggregations)), meta.Stats.NumSeries)
+	}
+}
+
+func TestDownsamplingGrouper(t *testing.T) {
+	const userID = "user-1"
+
+	now := time.Now()
+	oldMaxT := now.Add(-72 * time.Hour).UnixMilli()
+	midMaxT := now.Add(-36 * time.Hour).UnixMilli()
+
+	blockMeta := func(id ulid.ULID, minT, maxT, resolution int64, sources ...ulid.ULID) *block.Meta {
+		if len(sources) == 0 {
+			sources = []ulid.ULID{id}
+		}
+		return &block.Meta{
+			BlockMeta: tsdb.BlockMeta{ULID: id, MinTime: minT, MaxTime: maxT, Version: block.TSDBVersion1, Compaction: tsdb.BlockMetaCompaction{Sources: sources}},
+			Thanos:    block.ThanosMeta{Downsample: block.ThanosDownsample{Resolution: resolution}},
+		}
+	}
+
+	var (
+		oldBlock        = ulid.MustNew(1, nil)
+		oldBlock5m      = ulid.MustNew(2, nil)
+		midBlock        = ulid.MustNew(3, nil)
+		compactedBlock  = ulid.MustNew(4, nil)
+		outdatedBlock   = ulid.MustNew(5, nil)
+		outdatedBlock5m = ulid.MustNew(6, nil)
+		lateSource      = ulid.MustNew(7, nil)
+	)
+
+	metas := map[ulid.ULID]*block.Meta{
+		// Downsampled to 5m, and due to be downsampled to 1h.
+		oldBlock:   blockMeta(oldBlock, oldMaxT-10, oldMaxT, block.ResolutionRaw),
+		oldBlock5m: blockMeta(oldBlock5m, oldMaxT-10, oldMaxT, block.Resolution5m, oldBlock),
+		// Due to be downsampled to 5m only.
+		midBlock: blockMeta(midBlock, midMaxT-10, midMaxT, block.ResolutionRaw),
+		// Due to be downsampled, but compacted by another job.
+		compactedBlock: blockMeta(compactedBlock, oldMaxT-20, oldMaxT-10, block.ResolutionRaw),
+		// Downsampled to 5m before getting data from a late source block, so it's due to be downsampled again.
+		// Since the 5m block is outdated, it's not downsampled to 1h yet.
+		outdatedBlock:   blockMeta(outdatedBlock, oldMaxT, oldMaxT+10, block.ResolutionRaw, outdatedBlock, lateSource),
+		outdatedBlock5m: blockMeta(outdatedBlock5m, oldMaxT, oldMaxT+10, block.Resolution5m, outdatedBlock),
+	}
+
+	upstream := grouperFunc(func(blocks map[ulid.ULID]*block.Meta) ([]*Job, error) {
+		job := NewJob(userID, "job-1", labels.EmptyLabels(), 0, false, 0, "")
+		require.NoError(t, job.AppendMeta(blocks[compactedBlock]))
+		return []*Job{job}, nil
+	})
+
+	jobs, err := newDownsamplingGrouper(upstream, userID, 24*time.Hour, 48*time.Hour).Groups(metas)
+	require.NoError(t, err)
+
+	type jobSummary struct {
+		source     ulid.ULID
+		resolution int64
+	}
+	var actual []jobSummary
+	for _, job := range jobs[1:] {
+		assert.True(t, job.Downsampling())
+		assert.Equal(t, userID, job.UserID())
+		require.Len(t, job.Metas(), 1)
+		actual = append(actual, jobSummary{source: job.Metas()[0].ULID, resolution: job.Resolution()})
+	}
+
+	assert.Equal(t, "job-1", jobs[0].Key())
+	assert.False(t, jobs[0].Downsampling())
+	assert.ElementsMatch(t, []jobSummary{
+		{source: oldBlock5m, resolution: block.Resolution1h},
+		{source: midBlock, resolution: block.Resolution5m},
+		{source: outdatedBlock, resolution: block.Resolution5m},
+	}, actual)
+}
+
+func TestDownsamplingGrouper_ShouldReturnTheWrappedGrouperIfDisabled(t *testing.T) {
+	upstream := grouperFunc(func(map[ulid.ULID]*block.Meta) ([]*Job, error) {
+		return nil, nil
+	})
+
+	_, ok := newDownsamplingGrouper(upstream, "user-1", 0, 0).(grouperFunc)
+	assert.True(t, ok)
+}
@@ -15,6 +15,7 @@ import (
 	"github.com/prometheus/prometheus/model/labels"
 	"github.com/thanos-io/objstore"
 
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
 )
 
@@ -31,6 +32,12 @@ type Job struct {
 
 	// The number of shards to split compacted block into. Not used if splitting is disabled.
 	splitNumShards uint32
+
+	// Series deletion tombstones overlapping the job time range.
+	tombstones mimir_tsdb.Tombstones
+
+	// Whether the job downsamples its block to the job resolution, rather than compacting blocks.
+	downsampling bool
 }
 
 // NewJob returns a new compaction Job.
@@ -46,6 +53,19 @@ func NewJob(userID string, key string, lset labels.Labels, resolution int64, use
 	}
 }
 
+// NewDownsamplingJob returns a new Job which downsamples the block to the resolution.
+func NewDownsamplingJob(userID string, key string, meta *block.Meta, resolution int64, shardingKey string) *Job {
+	return &Job{
+		userID:         userID,
+		key:            key,
+		labels:         labels.FromMap(meta.Thanos.Labels),
+		resolution:     resolution,
+		metasByMinTime: []*block.Meta{meta},
+		shardingKey:    shardingKey,
+		downsampling:   true,
+	}
+}
+
 // UserID returns the user/tenant to which this job belongs to.
 func (job *Job) UserID() string {
 	return job.userID
@@ -128,11 +148,17 @@ func (job *Job) Labels() labels.Labels {
 	return job.labels
 }
 
-// Resolution returns the common downsampling resolution of blocks in the job.
+// Resolution returns the downsampling resolution of the output block(s) of the job. It's the common
+// resolution of blocks in the job, unless the job is a downsampling job.
 func (job *Job) Resolution() int64 {
 	return job.resolution
 }
 
+// Downsampling returns whether the job downsamples its block to the job resolution, rather than compacting blocks.
+func (job *Job) Downsampling() bool {
+	return job.downsampling
+}
+
 // UseSplitting returns whether blocks should be split into multiple shards when compacted.
 func (job *Job) UseSplitting() bool {
 	return job.useSplitting
@@ -143,6 +169,11 @@ func (job *Job) SplittingShards() uint32 {
 	return job.splitNumShards
 }
 
+// Tombstones returns the series deletion tombstones which should be applied when running the job.
+func (job *Job) Tombstones() mimir_tsdb.Tombstones {
+	return job.tombstones
+}
+
 // ShardingKey returns the key used to shard this job across multiple instances.
 func (job *Job) ShardingKey() string {
 	return job.shardingKey
@@ -0,0 +1,99 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"net/http"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/util"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+// DeleteSeries creates a tombstone requesting the deletion of all the samples of series matching
+// the input selectors within the input time range. Matching samples are filtered out at query
+// time right away, and physically removed from the storage by the compactor.
+func (c *MultitenantCompactor) DeleteSeries(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if !c.cfgProvider.CompactorSeriesDeletionEnabled(userID) {
+		http.Error(w, "series deletion is disabled", http.StatusBadRequest)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	now := time.Now()
+	start, err := util.ParseTimeParam(r, "start", 0)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	end, err := util.ParseTimeParam(r, "end", now.UnixMilli())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	tombstone, err := mimir_tsdb.NewTombstone(r.Form["match[]"], start, end, now)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	logger := util_log.WithUserID(userID, c.logger)
+	if err := mimir_tsdb.WriteTombstone(ctx, c.bucketClient, userID, c.cfgProvider, tombstone); err != nil {
+		level.Error(logger).Log("msg", "failed to write series deletion tombstone", "tombstone", tombstone.String(), "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	level.Info(logger).Log("msg", "series deletion tombstone created", "tombstone", tombstone.String())
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type SeriesDeletionsResponse struct {
+	TenantID   string                `json:"tenant_id"`
+	Tombstones mimir_tsdb.Tombstones `json:"tombstones"`
+}
+
+// ListSeriesDeletions returns all series deletion tombstones of the tenant.
+func (c *MultitenantCompactor) ListSeriesDeletions(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	userBucket := bucket.NewUserBucketClient(userID, c.bucketClient, c.cfgProvider)
+	tombstones, err := mimir_tsdb.ReadTombstones(ctx, userBucket, util_log.WithUserID(userID, c.logger))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	sort.Slice(tombstones, func(i, j int) bool {
+		return tombstones[i].CreationTime < tombstones[j].CreationTime
+	})
+
+	util.WriteJSONResponse(w, SeriesDeletionsResponse{
+		TenantID:   userID,
+		Tombstones: tombstones,
+	})
+}
@@ -0,0 +1,112 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+)
+
+func TestDeleteSeries(t *testing.T) {
+	const userID = "user-1"
+
+	bkt := objstore.NewInMemBucket()
+	cfgProvider := newMockConfigProvider()
+	cfg := prepareConfig(t)
+	c, _, _, _, _ := prepareWithConfigProvider(t, cfg, bkt, cfgProvider)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(stopServiceFn(t, c))
+
+	newRequest := func(orgID string, form url.Values) *http.Request {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/admin/tsdb/delete_series", strings.NewReader(form.Encode()))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		if orgID != "" {
+			req = req.WithContext(user.InjectOrgID(req.Context(), orgID))
+		}
+		return req
+	}
+
+	validForm := url.Values{"match[]": {`{__name__="up", job="test"}`}, "start": {"10"}, "end": {"20"}}
+
+	t.Run("should fail without a tenant", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		c.DeleteSeries(resp, newRequest("", validForm))
+		require.Equal(t, http.StatusUnauthorized, resp.Code)
+	})
+
+	t.Run("should fail if series deletion is disabled for the tenant", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		c.DeleteSeries(resp, newRequest(userID, validForm))
+		require.Equal(t, http.StatusBadRequest, resp.Code)
+		require.Empty(t, bkt.Objects())
+	})
+
+	cfgProvider.seriesDeletionEnabled[userID] = true
+
+	t.Run("should fail on invalid input", func(t *testing.T) {
+		for name, form := range map[string]url.Values{
+			"no selectors":       {"start": {"10"}, "end": {"20"}},
+			"invalid selector":   {"match[]": {`{job=~"`}},
+			"invalid time range": {"match[]": {`{job="test"}`}, "start": {"20"}, "end": {"10"}},
+			"invalid start time": {"match[]": {`{job="test"}`}, "start": {"invalid"}},
+		} {
+			t.Run(name, func(t *testing.T) {
+				resp := httptest.NewRecorder()
+				c.DeleteSeries(resp, newRequest(userID, form))
+				require.Equal(t, http.StatusBadRequest, resp.Code)
+			})
+		}
+		require.Empty(t, bkt.Objects())
+	})
+
+	t.Run("should create a tombstone", func(t *testing.T) {
+		resp := httptest.NewRecorder()
+		c.DeleteSeries(resp, newRequest(userID, validForm))
+		require.Equal(t, http.StatusNoContent, resp.Code)
+
+		tombstones, err := mimir_tsdb.ReadTombstones(context.Background(), bucket.NewUserBucketClient(userID, bkt, nil), c.logger)
+		require.NoError(t, err)
+		require.Len(t, tombstones, 1)
+		assert.Equal(t, []string{`{__name__="up", job="test"}`}, tombstones[0].Selectors)
+		assert.Equal(t, int64(10000), tombstones[0].MinTime)
+		assert.Equal(t, int64(20000), tombstones[0].MaxTime)
+
+		// Submitting the same request again doesn't create another tombstone.
+		resp = httptest.NewRecorder()
+		c.DeleteSeries(resp, newRequest(userID, validForm))
+		require.Equal(t, http.StatusNoContent, resp.Code)
+
+		tombstones, err = mimir_tsdb.ReadTombstones(context.Background(), bucket.NewUserBucketClient(userID, bkt, nil), c.logger)
+		require.NoError(t, err)
+		require.Len(t, tombstones, 1)
+	})
+
+	t.Run("should list tombstones", func(t *testing.T) {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/tsdb/delete_series", nil)
+		req = req.WithContext(user.InjectOrgID(req.Context(), userID))
+
+		resp := httptest.NewRecorder()
+		c.ListSeriesDeletions(resp, req)
+		require.Equal(t, http.StatusOK, resp.Code)
+
+		res := SeriesDeletionsResponse{}
+		require.NoError(t, json.Unmarshal(resp.Body.Bytes(), &res))
+		assert.Equal(t, userID, res.TenantID)
+		require.Len(t, res.Tombstones, 1)
+		assert.Equal(t, []string{`{__name__="up", job="test"}`}, res.Tombstones[0].Selectors)
+	})
+}
@@ -0,0 +1,193 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"golang.org/x/exp/slices"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+// pendingTombstones returns the tombstones overlapping the block time range which haven't been
+// applied to the block yet.
+func pendingTombstones(meta *block.Meta, tombstones mimir_tsdb.Tombstones) mimir_tsdb.Tombstones {
+	var pending mimir_tsdb.Tombstones
+
+	// NOTE: Block intervals are half-open: [MinTime, MaxTime).
+	for _, t := range tombstones.Overlapping(meta.MinTime, meta.MaxTime-1) {
+		if !slices.Contains(meta.Thanos.AppliedTombstones, t.ID) {
+			pending = append(pending, t)
+		}
+	}
+
+	return pending
+}
+
+// applyTombstones writes the deletion intervals of the input tombstones to the local block
+// in bdir, so that the deleted samples are removed once the block gets compacted.
+func applyTombstones(ctx context.Context, logger log.Logger, bdir string, tombstones mimir_tsdb.Tombstones) (err error) {
+	b, err := tsdb.OpenBlock(logger, bdir, nil)
+	if err != nil {
+		return errors.Wrapf(err, "open block %s", bdir)
+	}
+	defer func() {
+		if closeErr := b.Close(); err == nil && closeErr != nil {
+			err = errors.Wrapf(closeErr, "close block %s", bdir)
+		}
+	}()
+
+	for _, t := range tombstones {
+		selectors, err := t.ParseSelectors()
+		if err != nil {
+			return err
+		}
+
+		for _, matchers := range selectors {
+			if err := b.Delete(ctx, t.MinTime, t.MaxTime, matchers...); err != nil {
+				return errors.Wrapf(err, "apply tombstone %s to block %s", t.ID, bdir)
+			}
+		}
+	}
+
+	return nil
+}
+
+// retentionTombstones returns a tombstone for each retention rule, deleting the series matching the rule
+// selector up to the rule retention period before now. The ID of the tombstones only depends on the rule,
+// so that the rule is applied once to each block, while the deleted time range moves forward over time.
+// Retention tombstones are never written to the storage.
+func retentionTombstones(rules []*validation.RetentionRule, now time.Time) (mimir_tsdb.Tombstones, error) {
+	var out mimir_tsdb.Tombstones
+	for _, rule := range rules {
+		period := time.Duration(rule.Period)
+		t, err := mimir_tsdb.NewTombstone([]string{rule.Selector}, math.MinInt64, now.Add(-period).UnixMilli(), now)
+		if err != nil {
+			return nil, errors.Wrapf(err, "retention rule %q", rule.Selector)
+		}
+
+		h := fnv.New64a()
+		_, _ = fmt.Fprintf(h, "%s:%d", t.Selectors[0], period.Milliseconds())
+		t.ID = fmt.Sprintf("retention-%016x", h.Sum64())
+
+		out = append(out, t)
+	}
+	return out, nil
+}
+
+// tombstonesGrouper wraps a Grouper to honor series deletion tombstones. Each job gets the tombstones
+// overlapping its time range, and an additional job is created for each block with pending tombstones
+// which is not part of any compaction job, so that the block gets rewritten without the deleted data.
+//
+// Retention tombstones are only applied to the jobs and blocks whose whole time range they cover, so that
+// a block is rewritten once all the data of the series matching the retention rules has expired.
+type tombstonesGrouper struct {
+	Grouper
+
+	userID     string
+	tombstones mimir_tsdb.Tombstones
+	retention  mimir_tsdb.Tombstones
+}
+
+func newTombstonesGrouper(grouper Grouper, userID string, tombstones, retention mimir_tsdb.Tombstones) Grouper {
+	if len(tombstones) == 0 && len(retention) == 0 {
+		return grouper
+	}
+
+	return &tombstonesGrouper{
+		Grouper:    grouper,
+		userID:     userID,
+		tombstones: tombstones,
+		retention:  retention,
+	}
+}
+
+// Groups implements Grouper.
+func (g *tombstonesGrouper) Groups(blocks map[ulid.ULID]*block.Meta) ([]*Job, error) {
+	jobs, err := g.Grouper.Groups(blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	rewriteJobs, err := tombstonesRewriteJobs(g.userID, blocks, jobs, g.applicableTombstones)
+	if err != nil {
+		return nil, err
+	}
+	jobs = append(jobs, rewriteJobs...)
+
+	for _, job := range jobs {
+		// NOTE: Block intervals are half-open: [MinTime, MaxTime).
+		job.tombstones = g.applicableTombstones(job.MinTime(), job.MaxTime()-1)
+	}
+
+	return jobs, nil
+}
+
+// applicableTombstones returns the tombstones to apply to the input range (both included).
+func (g *tombstonesGrouper) applicableTombstones(minT, maxT int64) mimir_tsdb.Tombstones {
+	out := g.tombstones.Overlapping(minT, maxT)
+	for _, t := range g.retention {
+		if t.Covers(minT, maxT) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// tombstonesRewriteJobs returns a job for each block with pending tombstones which is not already
+// part of any compaction job. Such jobs compact a single block, which is rewritten without the deleted data.
+// applicable returns the tombstones to apply to a time range (both included).
+func tombstonesRewriteJobs(userID string, metas map[ulid.ULID]*block.Meta, jobs []*Job, applicable func(minT, maxT int64) mimir_tsdb.Tombstones) ([]*Job, error) {
+	inJobs := map[ulid.ULID]struct{}{}
+	for _, job := range jobs {
+		for _, id := range job.IDs() {
+			inJobs[id] = struct{}{}
+		}
+	}
+
+	var out []*Job
+	for id, meta := range metas {
+		if _, ok := inJobs[id]; ok {
+			continue
+		}
+		// NOTE: Block intervals are half-open: [MinTime, MaxTime).
+		if len(pendingTombstones(meta, applicable(meta.MinTime, meta.MaxTime-1))) == 0 {
+			continue
+		}
+
+		job := NewJob(
+			userID,
+			fmt.Sprintf("%s-rewrite-%s", DefaultGroupKey(meta.Thanos), id.String()),
+			labels.FromMap(meta.Thanos.Labels),
+			meta.Thanos.Downsample.Resolution,
+			false,
+			0,
+			id.String(),
+		)
+		if err := job.AppendMeta(meta); err != nil {
+			return nil, errors.Wrap(err, "add block to tombstones rewrite job")
+		}
+
+		out = append(out, job)
+	}
+
+	// Keep the output stable, given metas are iterated in random order.
+	sort.Slice(out, func(i, j int) bool {
+		return out[i].Key() < out[j].Key()
+	})
+
+	return out, nil
+}
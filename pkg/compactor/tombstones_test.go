@@ -0,0 +1,325 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package compactor
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/test"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestMultitenantCompactor_ShouldRewriteBlocksWithPendingTombstones(t *testing.T) {
+	const (
+		userID     = "user-1"
+		numSeries  = 10
+		blockRange = 2 * time.Hour
+	)
+
+	blockRangeMillis := blockRange.Milliseconds()
+
+	workDir := t.TempDir()
+	storageDir := t.TempDir()
+	fetcherDir := t.TempDir()
+
+	storageCfg := mimir_tsdb.BlocksStorageConfig{}
+	flagext.DefaultValues(&storageCfg)
+	storageCfg.Bucket.Backend = bucket.Filesystem
+	storageCfg.Bucket.Filesystem.Directory = storageDir
+
+	compactorCfg := prepareConfig(t)
+	compactorCfg.DataDir = workDir
+	compactorCfg.BlockRanges = mimir_tsdb.DurationList{blockRange}
+
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.seriesDeletionEnabled[userID] = true
+
+	logger := log.NewLogfmtLogger(os.Stdout)
+	reg := prometheus.NewPedanticRegistry()
+	ctx := context.Background()
+
+	bucketClient, err := bucket.NewClient(ctx, storageCfg.Bucket, "test", logger, nil)
+	require.NoError(t, err)
+
+	// Create a TSDB block in the storage. The block already covers the largest compaction
+	// range, so it would not be compacted if it wasn't for the tombstone.
+	blockID := createTSDBBlock(t, bucketClient, userID, blockRangeMillis, 2*blockRangeMillis, numSeries, nil)
+
+	// Delete two series.
+	tombstone, err := mimir_tsdb.NewTombstone([]string{`{series_id=~"1|2"}`}, 0, 3*blockRangeMillis, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, mimir_tsdb.WriteTombstone(ctx, bucketClient, userID, nil, tombstone))
+
+	c, err := NewMultitenantCompactor(compactorCfg, storageCfg, cfgProvider, logger, reg)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	// Wait until the first compaction run completed.
+	test.Poll(t, 15*time.Second, nil, func() interface{} {
+		return testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_compactor_runs_completed_total Total number of compaction runs successfully completed.
+			# TYPE cortex_compactor_runs_completed_total counter
+			cortex_compactor_runs_completed_total 1
+		`), "cortex_compactor_runs_completed_total")
+	})
+
+	// List back any (non deleted) block from the storage.
+	userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+	fetcher, err := block.NewMetaFetcher(logger, 1, userBucket, fetcherDir, reg, nil)
+	require.NoError(t, err)
+	metas, partials, err := fetcher.FetchWithoutMarkedForDeletion(ctx)
+	require.NoError(t, err)
+	require.Empty(t, partials)
+
+	// Ensure the input block has been rewritten.
+	actualMetas := convertMetasMapToSlice(metas)
+	require.Len(t, actualMetas, 1)
+	actualMeta := actualMetas[0]
+	assert.NotEqual(t, blockID, actualMeta.ULID)
+	assert.Equal(t, []ulid.ULID{blockID}, actualMeta.Compaction.Sources)
+	assert.Equal(t, []string{tombstone.ID}, actualMeta.Thanos.AppliedTombstones)
+	assert.Equal(t, uint64(numSeries-2), actualMeta.Stats.NumSeries)
+
+	// Ensure the deleted series are not in the block anymore.
+	b, err := tsdb.OpenBlock(logger, filepath.Join(storageDir, userID, actualMeta.ULID.String()), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, b.Close()) })
+
+	indexReader, err := b.Index()
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, indexReader.Close()) })
+
+	values, err := indexReader.SortedLabelValues(ctx, "series_id")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"0", "3", "4", "5", "6", "7", "8", "9"}, values)
+}
+
+func TestTombstonesGrouper(t *testing.T) {
+	const userID = "user-1"
+
+	block1 := ulid.MustNew(1, nil)
+	block2 := ulid.MustNew(2, nil)
+	block3 := ulid.MustNew(3, nil)
+
+	metas := map[ulid.ULID]*block.Meta{
+		block1: {BlockMeta: tsdb.BlockMeta{ULID: block1, MinTime: 0, MaxTime: 10, Version: block.TSDBVersion1}},
+		block2: {BlockMeta: tsdb.BlockMeta{ULID: block2, MinTime: 10, MaxTime: 20, Version: block.TSDBVersion1}},
+		block3: {BlockMeta: tsdb.BlockMeta{ULID: block3, MinTime: 20, MaxTime: 30, Version: block.TSDBVersion1}},
+	}
+
+	// The tombstone has already been applied to block3.
+	tombstone, err := mimir_tsdb.NewTombstone([]string{`{job="test"}`}, 5, 25, time.Now())
+	require.NoError(t, err)
+	metas[block3].Thanos.AppliedTombstones = []string{tombstone.ID}
+
+	// The wrapped grouper compacts block1 only.
+	upstream := grouperFunc(func(blocks map[ulid.ULID]*block.Meta) ([]*Job, error) {
+		job := NewJob(userID, "job-1", labels.EmptyLabels(), 0, false, 0, "")
+		require.NoError(t, job.AppendMeta(blocks[block1]))
+		return []*Job{job}, nil
+	})
+
+	jobs, err := newTombstonesGrouper(upstream, userID, mimir_tsdb.Tombstones{tombstone}, nil).Groups(metas)
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+
+	assert.Equal(t, "job-1", jobs[0].Key())
+	assert.Equal(t, mimir_tsdb.Tombstones{tombstone}, jobs[0].Tombstones())
+
+	// block2 has pending tombstones and is not compacted by any job, so it gets rewritten.
+	assert.Equal(t, userID, jobs[1].UserID())
+	assert.Equal(t, []ulid.ULID{block2}, jobs[1].IDs())
+	assert.Equal(t, mimir_tsdb.Tombstones{tombstone}, jobs[1].Tombstones())
+}
+
+func TestTombstonesGrouper_ShouldReturnTheWrappedGrouperWithoutTombstones(t *testing.T) {
+	upstream := grouperFunc(func(map[ulid.ULID]*block.Meta) ([]*Job, error) {
+		return nil, nil
+	})
+
+	_, ok := newTombstonesGrouper(upstream, "user-1", nil, nil).(grouperFunc)
+	assert.True(t, ok)
+}
+
+func TestMultitenantCompactor_ShouldRewriteBlocksWithExpiredSeries(t *testing.T) {
+	const (
+		userID     = "user-1"
+		numSeries  = 10
+		blockRange = 2 * time.Hour
+	)
+
+	blockRangeMillis := blockRange.Milliseconds()
+
+	storageDir := t.TempDir()
+	fetcherDir := t.TempDir()
+
+	storageCfg := mimir_tsdb.BlocksStorageConfig{}
+	flagext.DefaultValues(&storageCfg)
+	storageCfg.Bucket.Backend = bucket.Filesystem
+	storageCfg.Bucket.Filesystem.Directory = storageDir
+
+	compactorCfg := prepareConfig(t)
+	compactorCfg.DataDir = t.TempDir()
+	compactorCfg.BlockRanges = mimir_tsdb.DurationList{blockRange}
+
+	// Series deletion doesn't need to be enabled for the retention rules to be applied.
+	cfgProvider := newMockConfigProvider()
+	cfgProvider.retentionRules[userID] = []*validation.RetentionRule{
+		{Selector: `{series_id=~"1|2"}`, Period: model.Duration(24 * time.Hour)},
+	}
+
+	logger := log.NewLogfmtLogger(os.Stdout)
+	reg := prometheus.NewPedanticRegistry()
+	ctx := context.Background()
+
+	bucketClient, err := bucket.NewClient(ctx, storageCfg.Bucket, "test", logger, nil)
+	require.NoError(t, err)
+
+	// Create an old block, whose series matching the retention rule have expired, and a recent one.
+	oldBlockID := createTSDBBlock(t, bucketClient, userID, blockRangeMillis, 2*blockRangeMillis, numSeries, nil)
+	recentMinT := time.Now().Add(-3 * blockRange).Truncate(blockRange).UnixMilli()
+	recentBlockID := createTSDBBlock(t, bucketClient, userID, recentMinT, recentMinT+blockRangeMillis, numSeries, nil)
+
+	c, err := NewMultitenantCompactor(compactorCfg, storageCfg, cfgProvider, logger, reg)
+	require.NoError(t, err)
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), c))
+	})
+
+	// Wait until the first compaction run completed.
+	test.Poll(t, 15*time.Second, nil, func() interface{} {
+		return testutil.GatherAndCompare(reg, strings.NewReader(`
+			# HELP cortex_compactor_runs_completed_total Total number of compaction runs successfully completed.
+			# TYPE cortex_compactor_runs_completed_total counter
+			cortex_compactor_runs_completed_total 1
+		`), "cortex_compactor_runs_completed_total")
+	})
+
+	// List back any (non deleted) block from the storage.
+	userBucket := bucket.NewUserBucketClient(userID, bucketClient, nil)
+	fetcher, err := block.NewMetaFetcher(logger, 1, userBucket, fetcherDir, reg, nil)
+	require.NoError(t, err)
+	metas, partials, err := fetcher.FetchWithoutMarkedForDeletion(ctx)
+	require.NoError(t, err)
+	require.Empty(t, partials)
+
+	// Ensure the old block has been rewritten without the expired series, while the recent one is untouched.
+	require.Len(t, metas, 2)
+	require.NotContains(t, metas, oldBlockID)
+	require.Contains(t, metas, recentBlockID)
+	assert.Equal(t, uint64(numSeries), metas[recentBlockID].Stats.NumSeries)
+	assert.Empty(t, metas[recentBlockID].Thanos.AppliedTombstones)
+
+	retention, err := retentionTombstones(cfgProvider.retentionRules[userID], time.Now())
+	require.NoError(t, err)
+
+	for id, meta := range metas {
+		if id == recentBlockID {
+			continue
+		}
+		assert.Equal(t, []ulid.ULID{oldBlockID}, meta.Compaction.Sources)
+		assert.Equal(t, retention.GetIDs(), meta.Thanos.AppliedTombstones)
+		assert.Equal(t, uint64(numSeries-2), meta.Stats.NumSeries)
+	}
+}
+
+func TestTombstonesGrouper_RetentionTombstones(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		inJob            = ulid.MustNew(1, nil)
+		expired          = ulid.MustNew(2, nil)
+		partiallyExpired = ulid.MustNew(3, nil)
+		alreadyApplied   = ulid.MustNew(4, nil)
+	)
+
+	retention, err := retentionTombstones([]*validation.RetentionRule{{Selector: `{env="dev"}`, Period: model.Duration(time.Hour)}}, time.UnixMilli(25).Add(time.Hour))
+	require.NoError(t, err)
+	require.Len(t, retention, 1)
+
+	metas := map[ulid.ULID]*block.Meta{
+		inJob:            {BlockMeta: tsdb.BlockMeta{ULID: inJob, MinTime: 0, MaxTime: 10, Version: block.TSDBVersion1}},
+		expired:          {BlockMeta: tsdb.BlockMeta{ULID: expired, MinTime: 10, MaxTime: 20, Version: block.TSDBVersion1}},
+		partiallyExpired: {BlockMeta: tsdb.BlockMeta{ULID: partiallyExpired, MinTime: 20, MaxTime: 30, Version: block.TSDBVersion1}},
+		alreadyApplied:   {BlockMeta: tsdb.BlockMeta{ULID: alreadyApplied, MinTime: 0, MaxTime: 20, Version: block.TSDBVersion1}},
+	}
+	metas[alreadyApplied].Thanos.AppliedTombstones = retention.GetIDs()
+
+	upstream := grouperFunc(func(blocks map[ulid.ULID]*block.Meta) ([]*Job, error) {
+		job1 := NewJob(userID, "job-1", labels.EmptyLabels(), 0, false, 0, "")
+		require.NoError(t, job1.AppendMeta(blocks[inJob]))
+		job2 := NewJob(userID, "job-2", labels.EmptyLabels(), 0, false, 0, "")
+		require.NoError(t, job2.AppendMeta(blocks[partiallyExpired]))
+		return []*Job{job1, job2}, nil
+	})
+
+	jobs, err := newTombstonesGrouper(upstream, userID, nil, retention).Groups(metas)
+	require.NoError(t, err)
+	require.Len(t, jobs, 3)
+
+	// Retention tombstones are only applied to the jobs whose whole time range has expired.
+	assert.Equal(t, "job-1", jobs[0].Key())
+	assert.Equal(t, retention, jobs[0].Tombstones())
+	assert.Equal(t, "job-2", jobs[1].Key())
+	assert.Empty(t, jobs[1].Tombstones())
+
+	// The expired block is not compacted by any job, so it gets rewritten.
+	assert.Equal(t, []ulid.ULID{expired}, jobs[2].IDs())
+	assert.Equal(t, retention, jobs[2].Tombstones())
+}
+
+func TestRetentionTombstones(t *testing.T) {
+	now := time.Now()
+	rules := []*validation.RetentionRule{
+		{Selector: `{env="dev"}`, Period: model.Duration(14 * 24 * time.Hour)},
+		{Selector: `{env = "dev"}`, Period: model.Duration(7 * 24 * time.Hour)},
+	}
+
+	actual, err := retentionTombstones(rules, now)
+	require.NoError(t, err)
+	require.Len(t, actual, 2)
+
+	assert.Equal(t, []string{`{env="dev"}`}, actual[0].Selectors)
+	assert.Equal(t, now.Add(-14*24*time.Hour).UnixMilli(), actual[0].MaxTime)
+	assert.Equal(t, now.Add(-7*24*time.Hour).UnixMilli(), actual[1].MaxTime)
+	assert.True(t, actual[0].Covers(0, now.Add(-15*24*time.Hour).UnixMilli()))
+
+	// The ID only depends on the rule, and not on the time the tombstone is computed.
+	assert.NotEqual(t, actual[0].ID, actual[1].ID)
+	later, err := retentionTombstones(rules, now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, actual.GetIDs(), later.GetIDs())
+
+	_, err = retentionTombstones([]*validation.RetentionRule{{Selector: `{env=`, Period: model.Duration(time.Hour)}}, now)
+	assert.Error(t, err)
+}
+
+type grouperFunc func(blocks map[ulid.ULID]*block.Meta) ([]*Job, error)
+
+func (f grouperFunc) Groups(blocks map[ulid.ULID]*block.Meta) ([]*Job, error) {
+	return f(blocks)
+}
@@ -104,6 +104,9 @@ type Distributor struct {
 	// For handling HA replicas.
 	HATracker *haTracker
 
+	// For queuing write requests while ingesters are unavailable. Nil if disabled.
+	replayBuffer *replayBuffer
+
 	// Per-user rate limiters.
 	requestRateLimiter   *limiter.RateLimiter
 	ingestionRateLimiter *limiter.RateLimiter
@@ -170,8 +173,9 @@ type Distributor struct {
 type Config struct {
 	PoolConfig PoolConfig `yaml:"pool"`
 
-	RetryConfig     RetryConfig     `yaml:"retry_after_header"`
-	HATrackerConfig HATrackerConfig `yaml:"ha_tracker"`
+	RetryConfig        RetryConfig        `yaml:"retry_after_header"`
+	HATrackerConfig    HATrackerConfig    `yaml:"ha_tracker"`
+	ReplayBufferConfig ReplayBufferConfig `yaml:"replay_buffer"`
 
 	MaxRecvMsgSize int           `yaml:"max_recv_msg_size" category:"advanced"`
 	RemoteTimeout  time.Duration `yaml:"remote_timeout" category:"advanced"`
@@ -219,6 +223,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	cfg.HATrackerConfig.RegisterFlags(f)
 	cfg.DistributorRing.RegisterFlags(f, logger)
 	cfg.RetryConfig.RegisterFlags(f)
+	cfg.ReplayBufferConfig.RegisterFlags(f)
 
 	f.IntVar(&cfg.MaxRecvMsgSize, "distributor.max-recv-msg-size", 100<<20, "Max message size in bytes that the distributors will accept for incoming push requests to the remote write API. If exceeded, the request will be rejected.")
 	f.DurationVar(&cfg.RemoteTimeout, "distributor.remote-timeout", 2*time.Second, "Timeout for downstream ingesters.")
@@ -238,6 +243,9 @@ func (cfg *Config) Validate(limits validation.Limits) error {
 	if err := cfg.HATrackerConfig.Validate(); err != nil {
 		return err
 	}
+	if err := cfg.ReplayBufferConfig.Validate(); err != nil {
+		return err
+	}
 	return cfg.RetryConfig.Validate()
 }
 
@@ -460,6 +468,18 @@ func New(cfg Config, clientConfig ingester_client.Config, limits *validation.Ove
 
 	subservices = append(subservices, d.ingesterPool, d.activeUsers)
 
+	if cfg.ReplayBufferConfig.Enabled {
+		if cfg.IngestStorageConfig.Enabled {
+			return nil, errReplayBufferWithIngestStorage
+		}
+
+		// Replayed requests are rate limited with their own limiter, because they have already been
+		// accounted against the ingestion rate limit when received.
+		replayRateLimiter := limiter.NewRateLimiter(newMultipliedRateStrategy(ingestionRateStrategy, cfg.ReplayBufferConfig.ReplayRateMultiplier), 10*time.Second)
+		d.replayBuffer = newReplayBuffer(cfg.ReplayBufferConfig, limits, d.replayBufferedRequest, replayRateLimiter, reg, log)
+		subservices = append(subservices, d.replayBuffer)
+	}
+
 	if cfg.ReusableIngesterPushWorkers > 0 {
 		wp := concurrency.NewReusableGoroutinesPool(cfg.ReusableIngesterPushWorkers)
 		d.ingesterDoBatchPushWorkers = wp.Go
@@ -597,8 +617,9 @@ func (d *Distributor) stopping(_ error) error {
 
 // Returns a boolean that indicates whether or not we want to remove the replica label going forward,
 // and an error that indicates whether we want to accept samples based on the cluster/replica found in ts.
-// nil for the error means accept the sample.
-func (d *Distributor) checkSample(ctx context.Context, userID, cluster, replica string) (removeReplicaLabel bool, _ error) {
+// nil for the error means accept the sample. The number of samples in the request and their latest timestamp
+// are tracked by the HA tracker to compare the replicas freshness.
+func (d *Distributor) checkSample(ctx context.Context, userID, cluster, replica string, numSamples int, latestSampleTs int64) (removeReplicaLabel bool, _ error) {
 	// If the sample doesn't have either HA label, accept it.
 	// At the moment we want to accept these samples by default.
 	if cluster == "" || replica == "" {
@@ -610,9 +631,12 @@ func (d *Distributor) checkSample(ctx context.Context, userID, cluster, replica
 		return false, nil
 	}
 
+	now := time.Now()
+	d.HATracker.updateFreshness(userID, cluster, replica, numSamples, latestSampleTs, now)
+
 	// At this point we know we have both HA labels, we should lookup
 	// the cluster/instance here to see if we want to accept this sample.
-	err := d.HATracker.checkReplica(ctx, userID, cluster, replica, time.Now())
+	err := d.HATracker.checkReplica(ctx, userID, cluster, replica, now)
 	// checkReplica would have returned an error if there was a real error talking to Consul,
 	// or if the replica is not the currently elected replica.
 	if err != nil { // Don't accept the sample.
@@ -733,12 +757,23 @@ func (d *Distributor) prePushHaDedupeMiddleware(next PushFunc) PushFunc {
 		}
 
 		numSamples := 0
+		latestSampleTs := int64(0)
+		trackFreshness := d.HATracker.cfg.FreshnessFailoverEnabled
 		group := d.activeGroups.UpdateActiveGroupTimestamp(userID, validation.GroupLabel(d.limits, userID, req.Timeseries), time.Now())
 		for _, ts := range req.Timeseries {
 			numSamples += len(ts.Samples) + len(ts.Histograms)
+
+			if trackFreshness {
+				for _, s := range ts.Samples {
+					latestSampleTs = max(latestSampleTs, s.TimestampMs)
+				}
+				for _, h := range ts.Histograms {
+					latestSampleTs = max(latestSampleTs, h.Timestamp)
+				}
+			}
 		}
 
-		removeReplica, err := d.checkSample(ctx, userID, cluster, replica)
+		removeReplica, err := d.checkSample(ctx, userID, cluster, replica, numSamples, latestSampleTs)
 		if err != nil {
 			if errors.As(err, &replicasDidNotMatchError{}) {
 				// These samples have been deduped.
@@ -1294,6 +1329,68 @@ func (d *Distributor) push(ctx context.Context, pushReq *Request) error {
 		ctx = ingester_client.WithSlabPool(ctx, slabPool)
 	}
 
+	// The stats are computed before sending the request, because its buffers can be
+	// reused as soon as it has been sent, possibly before send returns.
+	written := newWrittenStats(req)
+
+	if d.replayBuffer != nil && d.replayBuffer.enabled(userID) {
+		cleanupInDefer = false
+		err = d.pushWithReplayBuffer(ctx, userID, req, pushReq)
+	} else {
+		// we must not re-use buffers now until all DoBatch goroutines have finished,
+		// so set this flag false and pass cleanup() to DoBatch.
+		cleanupInDefer = false
+		err = d.send(ctx, userID, req, pushReq.CleanUp)
+	}
+
+	if err == nil {
+		pushReq.written = written
+	}
+	return err
+}
+
+// pushWithReplayBuffer sends the input request to ingesters and, if it fails because ingesters are unavailable,
+// queues it in the replay buffer instead of returning the error. To preserve the order of the writes, requests
+// are queued right away while the tenant has previous requests waiting to be replayed.
+func (d *Distributor) pushWithReplayBuffer(ctx context.Context, userID string, req *mimirpb.WriteRequest, pushReq *Request) error {
+	if d.replayBuffer.queued(userID) {
+		// The request can't be sent to ingesters before the queued ones, otherwise the order of the writes
+		// wouldn't be preserved, so it fails if it can't be queued too.
+		err := d.replayBuffer.append(userID, req)
+		pushReq.CleanUp()
+		if err != nil {
+			return errors.Wrap(err, "the write request can't be queued in the replay buffer after the write requests waiting to be replayed")
+		}
+		return nil
+	}
+
+	// The request buffers are released once both all DoBatch goroutines have finished
+	// and we're done appending the request to the replay buffer.
+	refs := atomic.NewInt32(2)
+	release := func() {
+		if refs.Dec() == 0 {
+			pushReq.CleanUp()
+		}
+	}
+	defer release()
+
+	err := d.send(ctx, userID, req, release)
+	if err == nil || isClientError(err) || errors.Is(err, context.Canceled) {
+		return err
+	}
+
+	if appendErr := d.replayBuffer.append(userID, req); appendErr != nil {
+		if !errors.Is(appendErr, errReplayBufferFull) {
+			level.Warn(d.log).Log("msg", "failed to append write request to the replay buffer", "user", userID, "err", appendErr)
+		}
+		return err
+	}
+	return nil
+}
+
+// send sends the input request to ingesters, or to the ingest storage if enabled. The cleanup function
+// is called once all requests have completed, possibly after send returned.
+func (d *Distributor) send(ctx context.Context, userID string, req *mimirpb.WriteRequest, cleanup func()) error {
 	// Use an independent context to make sure all ingesters get samples even if we return early.
 	// It will still take a while to calculate which ingester gets which series,
 	// so we'll start the remote timeout once the first callback is called.
@@ -1306,10 +1403,7 @@ func (d *Distributor) push(ctx context.Context, pushReq *Request) error {
 	// Get a subring if tenant has shuffle shard size configured.
 	subRing := d.ingestersRing.ShuffleShard(userID, d.limits.IngestionTenantShardSize(userID))
 
-	// we must not re-use buffers now until all DoBatch goroutines have finished,
-	// so set this flag false and pass cleanup() to DoBatch.
-	cleanupInDefer = false
-	err = ring.DoBatchWithOptions(ctx, ring.WriteNoExtend, subRing, keys,
+	err := ring.DoBatchWithOptions(ctx, ring.WriteNoExtend, subRing, keys,
 		func(ingester ring.InstanceDesc, indexes []int) error {
 			req := req.ForIndexes(indexes, initialMetadataIndex)
 
@@ -1330,7 +1424,7 @@ func (d *Distributor) push(ctx context.Context, pushReq *Request) error {
 		},
 		ring.DoBatchOptions{
 			Cleanup: func() {
-				pushReq.CleanUp()
+				cleanup()
 				_, cancel := remoteRequestContext()
 				cancel()
 			},
@@ -1043,6 +1043,71 @@ func TestDistributor_PushHAInstances(t *testing.T) {
 	}
 }
 
+func TestDistributor_PushShouldRecordWrittenStatsAfterFiltering(t *testing.T) {
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	tests := map[string]struct {
+		acceptedReplica        string
+		testReplica            string
+		relabelConfigs         []*relabel.Config
+		expectedErr            bool
+		expectedWrittenSamples int
+	}{
+		"should record all samples when pushed by the elected replica": {
+			acceptedReplica:        "instance0",
+			testReplica:            "instance0",
+			expectedWrittenSamples: 5,
+		},
+		"should record no samples when deduplicated because pushed by a non-elected replica": {
+			acceptedReplica:        "instance2",
+			testReplica:            "instance0",
+			expectedErr:            true,
+			expectedWrittenSamples: 0,
+		},
+		"should not record the samples of the series dropped by relabeling": {
+			acceptedReplica: "instance0",
+			testReplica:     "instance0",
+			relabelConfigs: []*relabel.Config{{
+				SourceLabels: []model.LabelName{"sample"},
+				Action:       relabel.Drop,
+				Regex:        relabel.MustNewRegexp("[01]"),
+			}},
+			expectedWrittenSamples: 3,
+		},
+	}
+
+	for testName, tc := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var limits validation.Limits
+			flagext.DefaultValues(&limits)
+			limits.AcceptHASamples = true
+			limits.MetricRelabelConfigs = tc.relabelConfigs
+
+			ds, _, _ := prepare(t, prepConfig{
+				numIngesters:    3,
+				happyIngesters:  3,
+				numDistributors: 1,
+				limits:          &limits,
+				enableTracker:   true,
+			})
+			d := ds[0]
+
+			require.NoError(t, d.HATracker.checkReplica(ctx, "user", "cluster0", tc.acceptedReplica, time.Now()))
+
+			pushReq := NewParsedRequest(makeWriteRequestForGenerators(5, labelSetGenWithReplicaAndCluster(tc.testReplica, "cluster0"), nil, nil))
+			err := d.PushWithMiddlewares(ctx, pushReq)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+
+			// Each generated series has a float sample and a histogram sample.
+			assert.Equal(t, writtenStats{samples: tc.expectedWrittenSamples, histograms: tc.expectedWrittenSamples}, pushReq.written)
+		})
+	}
+}
+
 func TestDistributor_PushQuery(t *testing.T) {
 	ctx := user.InjectOrgID(context.Background(), "user")
 	nameMatcher := mustEqualMatcher(model.MetricNameLabel, "foo")
@@ -33,6 +33,18 @@ var (
 	errNegativeUpdateTimeoutJitterMax = errors.New("HA tracker max update timeout jitter shouldn't be negative")
 	errInvalidFailoverTimeout         = "HA Tracker failover timeout (%v) must be at least 1s greater than update timeout - max jitter (%v)"
 	errMemberlistUnsupported          = errors.New("memberlist is not supported by the HA tracker since gossip propagation is too slow for HA purposes")
+	errInvalidFreshnessWindow         = "HA tracker freshness window (%v) must be greater than or equal to the update timeout (%v)"
+	errInvalidFreshnessMaxLag         = errors.New("HA tracker freshness max lag shouldn't be negative")
+	errInvalidFreshnessMinSamples     = errors.New("HA tracker freshness min samples ratio must be between 0 and 1")
+	errFreshnessFailoverNoPolicy      = errors.New("HA tracker freshness failover requires the freshness max lag or the freshness min samples ratio to be set")
+)
+
+const (
+	// Number of buckets the freshness window is split into.
+	freshnessBuckets = 6
+
+	freshnessFailoverReasonLag     = "lag"
+	freshnessFailoverReasonSamples = "samples"
 )
 
 type haTrackerLimits interface {
@@ -66,6 +78,13 @@ type HATrackerConfig struct {
 	// more than this duration
 	FailoverTimeout time.Duration `yaml:"ha_tracker_failover_timeout" category:"advanced"`
 
+	// Fail over from an elected replica which is still sending samples, but falls behind
+	// the other replicas of the same cluster.
+	FreshnessFailoverEnabled bool          `yaml:"ha_tracker_freshness_failover_enabled" category:"experimental"`
+	FreshnessWindow          time.Duration `yaml:"ha_tracker_freshness_window" category:"experimental"`
+	FreshnessMaxLag          time.Duration `yaml:"ha_tracker_freshness_max_lag" category:"experimental"`
+	FreshnessMinSamplesRatio float64       `yaml:"ha_tracker_freshness_min_samples_ratio" category:"experimental"`
+
 	KVStore kv.Config `yaml:"kvstore" doc:"description=Backend storage to use for the ring. Please be aware that memberlist is not supported by the HA tracker since gossip propagation is too slow for HA purposes."`
 }
 
@@ -75,6 +94,10 @@ func (cfg *HATrackerConfig) RegisterFlags(f *flag.FlagSet) {
 	f.DurationVar(&cfg.UpdateTimeout, "distributor.ha-tracker.update-timeout", 15*time.Second, "Update the timestamp in the KV store for a given cluster/replica only after this amount of time has passed since the current stored timestamp.")
 	f.DurationVar(&cfg.UpdateTimeoutJitterMax, "distributor.ha-tracker.update-timeout-jitter-max", 5*time.Second, "Maximum jitter applied to the update timeout, in order to spread the HA heartbeats over time.")
 	f.DurationVar(&cfg.FailoverTimeout, "distributor.ha-tracker.failover-timeout", 30*time.Second, "If we don't receive any samples from the accepted replica for a cluster in this amount of time we will failover to the next replica we receive a sample from. This value must be greater than the update timeout")
+	f.BoolVar(&cfg.FreshnessFailoverEnabled, "distributor.ha-tracker.freshness-failover-enabled", false, "Enable the failover from the elected replica to another replica of the same cluster when the elected replica falls behind, comparing the number of samples and the latest sample timestamp received by the distributor from each replica over the freshness window.")
+	f.DurationVar(&cfg.FreshnessWindow, "distributor.ha-tracker.freshness-window", time.Minute, "The sliding window over which the samples received from each replica are compared. This value must be greater than or equal to the update timeout.")
+	f.DurationVar(&cfg.FreshnessMaxLag, "distributor.ha-tracker.freshness-max-lag", 30*time.Second, "Fail over when the latest sample timestamp of the elected replica is behind the latest sample timestamp of another replica by more than this amount of time. 0 to disable.")
+	f.Float64Var(&cfg.FreshnessMinSamplesRatio, "distributor.ha-tracker.freshness-min-samples-ratio", 0.5, "Fail over when the number of samples received from the elected replica in the freshness window is less than this ratio of the number of samples received from another replica. 0 to disable.")
 
 	// We want the ability to use different Consul instances for the ring and
 	// for HA cluster tracking. We also customize the default keys prefix, in
@@ -98,6 +121,21 @@ func (cfg *HATrackerConfig) Validate() error {
 		return errMemberlistUnsupported
 	}
 
+	if cfg.FreshnessFailoverEnabled {
+		if cfg.FreshnessWindow < cfg.UpdateTimeout {
+			return fmt.Errorf(errInvalidFreshnessWindow, cfg.FreshnessWindow, cfg.UpdateTimeout)
+		}
+		if cfg.FreshnessMaxLag < 0 {
+			return errInvalidFreshnessMaxLag
+		}
+		if cfg.FreshnessMinSamplesRatio < 0 || cfg.FreshnessMinSamplesRatio > 1 {
+			return errInvalidFreshnessMinSamples
+		}
+		if cfg.FreshnessMaxLag == 0 && cfg.FreshnessMinSamplesRatio == 0 {
+			return errFreshnessFailoverNoPolicy
+		}
+	}
+
 	return nil
 }
 
@@ -123,6 +161,7 @@ type haTracker struct {
 	electedReplicaTimestamp       *prometheus.GaugeVec
 	electedReplicaPropagationTime prometheus.Histogram
 	kvCASCalls                    *prometheus.CounterVec
+	freshnessFailovers            *prometheus.CounterVec
 
 	cleanupRuns               prometheus.Counter
 	replicasMarkedForDeletion prometheus.Counter
@@ -136,6 +175,66 @@ type haClusterInfo struct {
 	electedLastSeenTimestamp    int64
 	nonElectedLastSeenReplica   string
 	nonElectedLastSeenTimestamp int64
+
+	// The following fields are only used when the freshness failover is enabled.
+	electedAt              int64                        // When the current replica was elected, from the KVStore.
+	replicasFreshness      map[string]*replicaFreshness // Samples received from each replica, by replica name.
+	lastFailoverReason     string                       // Reason of the last freshness failover done by this distributor.
+	lastFailoverReasonTime int64
+}
+
+// replicaFreshness tracks the samples received from a replica over a sliding window,
+// split into buckets by the time the samples have been received.
+type replicaFreshness struct {
+	buckets  [freshnessBuckets]freshnessBucket
+	lastSeen int64
+}
+
+type freshnessBucket struct {
+	start          int64
+	samples        int64
+	latestSampleTs int64
+}
+
+func (f *replicaFreshness) add(now time.Time, window time.Duration, samples int, latestSampleTs int64) {
+	nowMs := timestamp.FromTime(now)
+	width := window.Milliseconds() / freshnessBuckets
+	if width <= 0 {
+		width = 1
+	}
+
+	start := nowMs - nowMs%width
+	b := &f.buckets[(start/width)%freshnessBuckets]
+	if b.start != start {
+		*b = freshnessBucket{start: start}
+	}
+
+	b.samples += int64(samples)
+	if latestSampleTs > b.latestSampleTs {
+		b.latestSampleTs = latestSampleTs
+	}
+	f.lastSeen = nowMs
+}
+
+// stats returns the number of samples and the latest sample timestamp received in the window.
+func (f *replicaFreshness) stats(now time.Time, window time.Duration) (samples, latestSampleTs int64) {
+	if f == nil {
+		return 0, 0
+	}
+
+	minStart := timestamp.FromTime(now.Add(-window))
+	width := window.Milliseconds() / freshnessBuckets
+	for _, b := range f.buckets {
+		if b.start+width <= minStart {
+			continue
+		}
+
+		samples += b.samples
+		if b.latestSampleTs > latestSampleTs {
+			latestSampleTs = b.latestSampleTs
+		}
+	}
+	return samples, latestSampleTs
 }
 
 // newHATracker returns a new HA cluster tracker using either Consul
@@ -170,6 +269,10 @@ func newHATracker(cfg HATrackerConfig, limits haTrackerLimits, reg prometheus.Re
 			Name: "cortex_ha_tracker_kv_store_cas_total",
 			Help: "The total number of CAS calls to the KV store for a user ID/cluster.",
 		}, []string{"user", "cluster"}),
+		freshnessFailovers: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ha_tracker_freshness_failovers_total",
+			Help: "The total number of times the elected replica has been changed by this distributor because it was falling behind another replica, by reason.",
+		}, []string{"user", "cluster", "reason"}),
 
 		cleanupRuns: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Name: "cortex_ha_tracker_replicas_cleanup_started_total",
@@ -302,6 +405,13 @@ func (h *haTracker) updateKVStoreAll(ctx context.Context, now time.Time) {
 	// the Go language allows this: https://golang.org/ref/spec#For_range note 3.
 	for userID, clusters := range h.clusters {
 		for cluster, entry := range clusters {
+			if candidate, reason, description := h.freshnessFailoverCandidate(entry, now); candidate != "" {
+				// The elected replica is falling behind: fail over regardless of the timeouts.
+				h.electedLock.RUnlock()
+				h.freshnessFailover(ctx, userID, cluster, entry, candidate, reason, description, now)
+				h.electedLock.RLock()
+				continue
+			}
 			if h.withinUpdateTimeout(now, entry.elected.ReceivedAt) {
 				continue // Some other process updated it recently; nothing to do.
 			}
@@ -462,6 +572,7 @@ func (h *haTracker) updateCache(userID, cluster string, desc *ReplicaDesc) {
 	}
 	if desc.Replica != entry.elected.Replica {
 		h.electedReplicaChanges.WithLabelValues(userID, cluster).Inc()
+		entry.electedAt = desc.ReceivedAt
 	}
 	entry.elected = *desc
 	h.electedReplicaTimestamp.WithLabelValues(userID, cluster).Set(float64(desc.ReceivedAt / 1000))
@@ -525,4 +636,128 @@ func (h *haTracker) cleanupHATrackerMetricsForUser(userID string) {
 	h.electedReplicaChanges.DeletePartialMatch(filter)
 	h.electedReplicaTimestamp.DeletePartialMatch(filter)
 	h.kvCASCalls.DeletePartialMatch(filter)
+	h.freshnessFailovers.DeletePartialMatch(filter)
+}
+
+// updateFreshness records the number of samples and the latest sample timestamp received from the replica,
+// to compare the replicas of the cluster when the freshness failover is enabled.
+func (h *haTracker) updateFreshness(userID, cluster, replica string, samples int, latestSampleTs int64, now time.Time) {
+	if !h.cfg.EnableHATracker || !h.cfg.FreshnessFailoverEnabled {
+		return
+	}
+
+	h.electedLock.Lock()
+	defer h.electedLock.Unlock()
+
+	entry := h.clusters[userID][cluster]
+	if entry == nil {
+		// The cluster will be tracked once the replica gets elected.
+		return
+	}
+
+	if entry.replicasFreshness == nil {
+		entry.replicasFreshness = map[string]*replicaFreshness{}
+	}
+
+	// Stop tracking replicas which haven't sent any sample in the window.
+	for name, f := range entry.replicasFreshness {
+		if name != replica && now.Sub(timestamp.Time(f.lastSeen)) > h.cfg.FreshnessWindow {
+			delete(entry.replicasFreshness, name)
+		}
+	}
+
+	f := entry.replicasFreshness[replica]
+	if f == nil {
+		f = &replicaFreshness{}
+		entry.replicasFreshness[replica] = f
+	}
+	f.add(now, h.cfg.FreshnessWindow, samples, latestSampleTs)
+}
+
+// freshnessFailoverCandidate returns the replica to fail over to if the elected replica is falling behind it,
+// along with the reason and the description of the decision. Returns an empty candidate if the elected replica
+// should be kept. Must be called with electedLock held.
+func (h *haTracker) freshnessFailoverCandidate(entry *haClusterInfo, now time.Time) (candidate, reason, description string) {
+	if !h.cfg.FreshnessFailoverEnabled || len(entry.replicasFreshness) < 2 {
+		return "", "", ""
+	}
+
+	// Give the elected replica the time to fill the window before comparing it.
+	if now.Sub(timestamp.Time(entry.electedAt)) < h.cfg.FreshnessWindow {
+		return "", "", ""
+	}
+
+	electedSamples, electedLatestTs := entry.replicasFreshness[entry.elected.Replica].stats(now, h.cfg.FreshnessWindow)
+
+	// Pick the replica which sent the most samples, among the ones seen recently.
+	var bestSamples, bestLatestTs int64
+	for name, f := range entry.replicasFreshness {
+		if name == entry.elected.Replica || !h.withinUpdateTimeout(now, f.lastSeen) {
+			continue
+		}
+
+		samples, latestTs := f.stats(now, h.cfg.FreshnessWindow)
+		if samples > bestSamples || (samples == bestSamples && samples > 0 && latestTs > bestLatestTs) {
+			candidate, bestSamples, bestLatestTs = name, samples, latestTs
+		}
+	}
+
+	if candidate == "" {
+		return "", "", ""
+	}
+
+	if h.cfg.FreshnessMaxLag > 0 && electedSamples > 0 && bestLatestTs-electedLatestTs > h.cfg.FreshnessMaxLag.Milliseconds() {
+		lag := time.Duration(bestLatestTs-electedLatestTs) * time.Millisecond
+		return candidate, freshnessFailoverReasonLag, fmt.Sprintf("the latest sample of replica %s was %s behind the latest sample of replica %s", entry.elected.Replica, lag, candidate)
+	}
+
+	if h.cfg.FreshnessMinSamplesRatio > 0 && float64(electedSamples) < h.cfg.FreshnessMinSamplesRatio*float64(bestSamples) {
+		return candidate, freshnessFailoverReasonSamples, fmt.Sprintf("replica %s sent %d samples in the last %s, less than %.0f%% of the %d samples sent by replica %s", entry.elected.Replica, electedSamples, h.cfg.FreshnessWindow, h.cfg.FreshnessMinSamplesRatio*100, bestSamples, candidate)
+	}
+
+	return "", "", ""
+}
+
+// freshnessFailover elects the candidate replica in the KVStore, unless the elected replica has changed in the meanwhile.
+func (h *haTracker) freshnessFailover(ctx context.Context, userID, cluster string, entry *haClusterInfo, candidate, reason, description string, now time.Time) {
+	h.electedLock.RLock()
+	previous := entry.elected.Replica
+	h.electedLock.RUnlock()
+
+	key := fmt.Sprintf("%s/%s", userID, cluster)
+	var desc *ReplicaDesc
+	err := h.client.CAS(ctx, key, func(in interface{}) (out interface{}, retry bool, err error) {
+		current, ok := in.(*ReplicaDesc)
+		if !ok || current == nil || current.DeletedAt > 0 || current.Replica != previous {
+			// Someone else has changed the elected replica.
+			desc = nil
+			return nil, false, nil
+		}
+
+		desc = &ReplicaDesc{
+			Replica:    candidate,
+			ReceivedAt: timestamp.FromTime(now),
+			DeletedAt:  0,
+		}
+		return desc, true, nil
+	})
+	h.kvCASCalls.WithLabelValues(userID, cluster).Inc()
+	if err != nil {
+		level.Error(h.logger).Log("msg", "failed to update KVStore", "err", err)
+		return
+	}
+	if desc == nil {
+		return
+	}
+
+	level.Info(h.logger).Log("msg", "HA tracker failed over to a fresher replica", "user", userID, "cluster", cluster, "previous", previous, "elected", candidate, "reason", description)
+	h.freshnessFailovers.WithLabelValues(userID, cluster, reason).Inc()
+
+	h.electedLock.Lock()
+	defer h.electedLock.Unlock()
+	if h.clusters[userID][cluster] == entry {
+		h.updateCache(userID, cluster, desc)
+	}
+	entry.lastFailoverReason = description
+	entry.lastFailoverReasonTime = timestamp.FromTime(now)
 }
@@ -24,6 +24,9 @@ var haTrackerStatusPageTemplate = template.Must(template.New("ha-tracker").Parse
 type haTrackerStatusPageContents struct {
 	Elected []haTrackerReplica `json:"elected"`
 	Now     time.Time          `json:"now"`
+
+	FreshnessFailoverEnabled bool          `json:"freshnessFailoverEnabled"`
+	FreshnessWindow          time.Duration `json:"freshnessWindow"`
 }
 
 type haTrackerReplica struct {
@@ -33,23 +36,58 @@ type haTrackerReplica struct {
 	ElectedAt    time.Time     `json:"electedAt"`
 	UpdateTime   time.Duration `json:"updateDuration"`
 	FailoverTime time.Duration `json:"failoverDuration"`
+
+	// Only populated when the freshness failover is enabled.
+	Replicas           []haTrackerReplicaFreshness `json:"replicas,omitempty"`
+	FailoverReason     string                      `json:"failoverReason,omitempty"`
+	FailoverReasonTime time.Time                   `json:"failoverReasonTime,omitempty"`
+}
+
+type haTrackerReplicaFreshness struct {
+	Replica        string    `json:"replica"`
+	Samples        int64     `json:"samples"`
+	LatestSampleAt time.Time `json:"latestSampleAt"`
+	LastSeenAt     time.Time `json:"lastSeenAt"`
 }
 
 func (h *haTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	now := time.Now()
 	h.electedLock.RLock()
 
 	var electedReplicas []haTrackerReplica
 	for userID, clusters := range h.clusters {
 		for cluster, entry := range clusters {
 			desc := &entry.elected
-			electedReplicas = append(electedReplicas, haTrackerReplica{
+			replica := haTrackerReplica{
 				UserID:       userID,
 				Cluster:      cluster,
 				Replica:      desc.Replica,
 				ElectedAt:    timestamp.Time(desc.ReceivedAt),
 				UpdateTime:   time.Until(timestamp.Time(desc.ReceivedAt).Add(h.cfg.UpdateTimeout)),
 				FailoverTime: time.Until(timestamp.Time(desc.ReceivedAt).Add(h.cfg.FailoverTimeout)),
-			})
+			}
+
+			if h.cfg.FreshnessFailoverEnabled {
+				for name, f := range entry.replicasFreshness {
+					samples, latestSampleTs := f.stats(now, h.cfg.FreshnessWindow)
+					replica.Replicas = append(replica.Replicas, haTrackerReplicaFreshness{
+						Replica:        name,
+						Samples:        samples,
+						LatestSampleAt: timestamp.Time(latestSampleTs),
+						LastSeenAt:     timestamp.Time(f.lastSeen),
+					})
+				}
+				sort.Slice(replica.Replicas, func(i, j int) bool {
+					return replica.Replicas[i].Replica < replica.Replicas[j].Replica
+				})
+
+				if entry.lastFailoverReason != "" {
+					replica.FailoverReason = entry.lastFailoverReason
+					replica.FailoverReasonTime = timestamp.Time(entry.lastFailoverReasonTime)
+				}
+			}
+
+			electedReplicas = append(electedReplicas, replica)
 		}
 	}
 	h.electedLock.RUnlock()
@@ -65,7 +103,9 @@ func (h *haTracker) ServeHTTP(w http.ResponseWriter, req *http.Request) {
 	})
 
 	util.RenderHTTPResponse(w, haTrackerStatusPageContents{
-		Elected: electedReplicas,
-		Now:     time.Now(),
+		Elected:                  electedReplicas,
+		Now:                      now,
+		FreshnessFailoverEnabled: h.cfg.FreshnessFailoverEnabled,
+		FreshnessWindow:          h.cfg.FreshnessWindow,
 	}, haTrackerStatusPageTemplate, req)
 }
@@ -125,6 +125,71 @@ func TestHATrackerConfig_Validate(t *testing.T) {
 			}(),
 			expectedErr: errMemberlistUnsupported,
 		},
+		"should pass if freshness failover is enabled with default config": {
+			cfg: func() HATrackerConfig {
+				cfg := HATrackerConfig{}
+				flagext.DefaultValues(&cfg)
+				cfg.FreshnessFailoverEnabled = true
+
+				return cfg
+			}(),
+			expectedErr: nil,
+		},
+		"should fail if freshness window is < update timeout": {
+			cfg: func() HATrackerConfig {
+				cfg := HATrackerConfig{}
+				flagext.DefaultValues(&cfg)
+				cfg.FreshnessFailoverEnabled = true
+				cfg.FreshnessWindow = 10 * time.Second
+
+				return cfg
+			}(),
+			expectedErr: fmt.Errorf(errInvalidFreshnessWindow, 10*time.Second, 15*time.Second),
+		},
+		"should fail if freshness max lag is negative": {
+			cfg: func() HATrackerConfig {
+				cfg := HATrackerConfig{}
+				flagext.DefaultValues(&cfg)
+				cfg.FreshnessFailoverEnabled = true
+				cfg.FreshnessMaxLag = -1
+
+				return cfg
+			}(),
+			expectedErr: errInvalidFreshnessMaxLag,
+		},
+		"should fail if freshness min samples ratio is > 1": {
+			cfg: func() HATrackerConfig {
+				cfg := HATrackerConfig{}
+				flagext.DefaultValues(&cfg)
+				cfg.FreshnessFailoverEnabled = true
+				cfg.FreshnessMinSamplesRatio = 1.5
+
+				return cfg
+			}(),
+			expectedErr: errInvalidFreshnessMinSamples,
+		},
+		"should fail if freshness failover is enabled without any policy": {
+			cfg: func() HATrackerConfig {
+				cfg := HATrackerConfig{}
+				flagext.DefaultValues(&cfg)
+				cfg.FreshnessFailoverEnabled = true
+				cfg.FreshnessMaxLag = 0
+				cfg.FreshnessMinSamplesRatio = 0
+
+				return cfg
+			}(),
+			expectedErr: errFreshnessFailoverNoPolicy,
+		},
+		"should pass if freshness failover is disabled with invalid freshness config": {
+			cfg: func() HATrackerConfig {
+				cfg := HATrackerConfig{}
+				flagext.DefaultValues(&cfg)
+				cfg.FreshnessWindow = 0
+
+				return cfg
+			}(),
+			expectedErr: nil,
+		},
 	}
 
 	for testName, testData := range tests {
@@ -659,6 +724,153 @@ func (l trackerLimits) MaxHAClusters(_ string) int {
 	return l.maxClusters
 }
 
+func TestHATracker_FreshnessFailover(t *testing.T) {
+	const (
+		cluster = "cluster"
+		window  = time.Minute
+	)
+
+	type push struct {
+		replica  string
+		samples  int
+		sampleTs time.Duration // Relative to the evaluation time.
+	}
+
+	tests := map[string]struct {
+		electedFor      time.Duration
+		pushes          []push
+		expectedReplica string
+		expectedReason  string
+	}{
+		"should keep the elected replica if it's as fresh as the other replicas": {
+			electedFor: 2 * window,
+			pushes: []push{
+				{replica: "r1", samples: 100, sampleTs: -time.Second},
+				{replica: "r2", samples: 100, sampleTs: -time.Second},
+			},
+			expectedReplica: "r1",
+		},
+		"should fail over if the latest sample of the elected replica is behind by more than the max lag": {
+			electedFor: 2 * window,
+			pushes: []push{
+				{replica: "r1", samples: 100, sampleTs: -2 * time.Minute},
+				{replica: "r2", samples: 100, sampleTs: -time.Second},
+			},
+			expectedReplica: "r2",
+			expectedReason:  freshnessFailoverReasonLag,
+		},
+		"should fail over if the elected replica sent less samples than the min samples ratio": {
+			electedFor: 2 * window,
+			pushes: []push{
+				{replica: "r1", samples: 20, sampleTs: -time.Second},
+				{replica: "r2", samples: 100, sampleTs: -time.Second},
+			},
+			expectedReplica: "r2",
+			expectedReason:  freshnessFailoverReasonSamples,
+		},
+		"should fail over to the replica which sent the most samples": {
+			electedFor: 2 * window,
+			pushes: []push{
+				{replica: "r1", samples: 20, sampleTs: -time.Second},
+				{replica: "r2", samples: 50, sampleTs: -time.Second},
+				{replica: "r3", samples: 100, sampleTs: -time.Second},
+			},
+			expectedReplica: "r3",
+			expectedReason:  freshnessFailoverReasonSamples,
+		},
+		"should keep the elected replica if it's been elected for less than the freshness window": {
+			electedFor: window / 2,
+			pushes: []push{
+				{replica: "r1", samples: 20, sampleTs: -2 * time.Minute},
+				{replica: "r2", samples: 100, sampleTs: -time.Second},
+			},
+			expectedReplica: "r1",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			// The in-memory KV store is shared across tests, so we use a different tenant for each test case.
+			userID := strings.ReplaceAll(testName, " ", "-")
+
+			reg := prometheus.NewPedanticRegistry()
+			c, err := newHATracker(HATrackerConfig{
+				EnableHATracker:          true,
+				KVStore:                  kv.Config{Store: "inmemory"},
+				UpdateTimeout:            15 * time.Second,
+				UpdateTimeoutJitterMax:   0,
+				FailoverTimeout:          30 * time.Second,
+				FreshnessFailoverEnabled: true,
+				FreshnessWindow:          window,
+				FreshnessMaxLag:          30 * time.Second,
+				FreshnessMinSamplesRatio: 0.5,
+			}, trackerLimits{maxClusters: 100}, reg, log.NewNopLogger())
+			require.NoError(t, err)
+			require.NoError(t, services.StartAndAwaitRunning(context.Background(), c))
+			defer services.StopAndAwaitTerminated(context.Background(), c) //nolint:errcheck
+
+			now := time.Now().Truncate(time.Millisecond)
+			electedAt := now.Add(-testData.electedFor)
+
+			// Elect the first replica.
+			require.NoError(t, c.checkReplica(context.Background(), userID, cluster, "r1", electedAt))
+
+			// Receive samples from all replicas, split in two pushes over the window.
+			for _, ts := range []time.Time{now.Add(-window / 2), now.Add(-time.Second)} {
+				for _, p := range testData.pushes {
+					c.updateFreshness(userID, cluster, p.replica, p.samples/2, timestamp.FromTime(now.Add(p.sampleTs)), ts)
+					_ = c.checkReplica(context.Background(), userID, cluster, p.replica, ts)
+				}
+			}
+
+			c.updateKVStoreAll(context.Background(), now)
+			checkReplicaTimestamp(t, time.Second, c, userID, cluster, testData.expectedReplica, now)
+
+			c.electedLock.RLock()
+			reason := c.clusters[userID][cluster].lastFailoverReason
+			c.electedLock.RUnlock()
+
+			if testData.expectedReason == "" {
+				assert.Empty(t, reason)
+				assert.Equal(t, 0, testutil.CollectAndCount(c.freshnessFailovers))
+				return
+			}
+
+			assert.NotEmpty(t, reason)
+			assert.Equal(t, float64(1), testutil.ToFloat64(c.freshnessFailovers.WithLabelValues(userID, cluster, testData.expectedReason)))
+
+			// Samples from the previously elected replica should now be rejected.
+			assert.Error(t, c.checkReplica(context.Background(), userID, cluster, "r1", now))
+			assert.NoError(t, c.checkReplica(context.Background(), userID, cluster, testData.expectedReplica, now))
+		})
+	}
+}
+
+func TestReplicaFreshness(t *testing.T) {
+	const window = time.Minute
+
+	now := time.Now()
+	f := &replicaFreshness{}
+
+	f.add(now.Add(-2*window), window, 1000, 1)
+	f.add(now.Add(-window/2), window, 10, 2)
+	f.add(now.Add(-time.Second), window, 20, 4)
+	f.add(now, window, 30, 3)
+
+	// The samples received before the window should be ignored.
+	samples, latestSampleTs := f.stats(now, window)
+	assert.Equal(t, int64(60), samples)
+	assert.Equal(t, int64(4), latestSampleTs)
+
+	samples, latestSampleTs = f.stats(now.Add(2*window), window)
+	assert.Equal(t, int64(0), samples)
+	assert.Equal(t, int64(0), latestSampleTs)
+
+	samples, latestSampleTs = (*replicaFreshness)(nil).stats(now, window)
+	assert.Equal(t, int64(0), samples)
+	assert.Equal(t, int64(0), latestSampleTs)
+}
+
 func TestHATracker_MetricsCleanup(t *testing.T) {
 	reg := prometheus.NewPedanticRegistry()
 	tr, err := newHATracker(HATrackerConfig{EnableHATracker: false}, nil, reg, log.NewNopLogger())
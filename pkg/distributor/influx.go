@@ -0,0 +1,490 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/middleware"
+	"github.com/grafana/dskit/tenant"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	influxParseError = "influx_parse_error"
+)
+
+// InfluxHandler is an http.Handler accepting InfluxDB line protocol write requests.
+func InfluxHandler(
+	maxRecvMsgSize int,
+	sourceIPs *middleware.SourceIPExtractor,
+	allowSkipLabelNameValidation bool,
+	limits *validation.Overrides,
+	retryCfg RetryConfig,
+	reg prometheus.Registerer,
+	push PushFunc,
+	logger log.Logger,
+) http.Handler {
+	discardedDueToInfluxParseError := validation.DiscardedSamplesCounter(reg, influxParseError)
+
+	return handler(maxRecvMsgSize, sourceIPs, allowSkipLabelNameValidation, limits, retryCfg, push, logger, func(ctx context.Context, r *http.Request, maxRecvMsgSize int, buffers *util.RequestBuffers, req *mimirpb.PreallocWriteRequest, logger log.Logger) error {
+		contentEncoding := r.Header.Get("Content-Encoding")
+		if contentEncoding != "" && contentEncoding != "gzip" {
+			return httpgrpc.Errorf(http.StatusUnsupportedMediaType, "unsupported compression: %s. Only \"gzip\" or no compression supported", contentEncoding)
+		}
+
+		precision, err := parseInfluxPrecision(r.URL.Query().Get("precision"))
+		if err != nil {
+			return err
+		}
+
+		if r.ContentLength > int64(maxRecvMsgSize) {
+			return httpgrpc.Errorf(http.StatusRequestEntityTooLarge, distributorMaxWriteMessageSizeErr{
+				actual: int(r.ContentLength),
+				limit:  maxRecvMsgSize,
+			}.Error())
+		}
+
+		spanLogger, ctx := spanlogger.NewWithLogger(ctx, logger, "Distributor.InfluxHandler.decodeAndConvert")
+		defer spanLogger.Span.Finish()
+
+		spanLogger.SetTag("content_encoding", contentEncoding)
+		spanLogger.SetTag("content_length", r.ContentLength)
+
+		body, err := readInfluxBody(r, contentEncoding, maxRecvMsgSize, buffers)
+		if err != nil {
+			return err
+		}
+
+		tenantID, err := tenant.TenantID(ctx)
+		if err != nil {
+			return err
+		}
+
+		conv := influxConverter{
+			nameSeparator:  limits.InfluxMetricNameSeparator(tenantID),
+			valueFieldName: limits.InfluxValueFieldName(tenantID),
+			precision:      precision,
+			now:            time.Now(),
+		}
+
+		metrics, err := conv.linesToTimeseries(tenantID, body, discardedDueToInfluxParseError, spanLogger)
+		if err != nil {
+			return err
+		}
+
+		level.Debug(spanLogger).Log("msg", "Influx line protocol to Prometheus conversion complete", "metric_count", len(metrics))
+
+		req.Timeseries = metrics
+		return nil
+	})
+}
+
+func readInfluxBody(r *http.Request, contentEncoding string, maxRecvMsgSize int, buffers *util.RequestBuffers) ([]byte, error) {
+	sz := int(r.ContentLength)
+	if sz > 0 {
+		// Extra space guarantees no reallocation
+		sz += bytes.MinRead
+	}
+	buf := buffers.Get(sz)
+
+	var reader io.Reader = r.Body
+	if contentEncoding == "gzip" {
+		gzReader, err := gzip.NewReader(reader)
+		if err != nil {
+			return nil, errors.Wrap(err, "create gzip reader")
+		}
+		defer gzReader.Close()
+		reader = gzReader
+	}
+
+	reader = http.MaxBytesReader(nil, io.NopCloser(reader), int64(maxRecvMsgSize))
+	if _, err := buf.ReadFrom(reader); err != nil {
+		if util.IsRequestBodyTooLarge(err) {
+			return nil, httpgrpc.Errorf(http.StatusRequestEntityTooLarge, distributorMaxWriteMessageSizeErr{
+				actual: -1,
+				limit:  maxRecvMsgSize,
+			}.Error())
+		}
+
+		return nil, errors.Wrap(err, "read write request")
+	}
+
+	return buf.Bytes(), nil
+}
+
+// parseInfluxPrecision returns the duration of a timestamp unit for the input precision.
+// Both InfluxDB v1 and v2 precision values are supported.
+func parseInfluxPrecision(precision string) (time.Duration, error) {
+	switch precision {
+	case "", "ns", "n":
+		return time.Nanosecond, nil
+	case "us", "u":
+		return time.Microsecond, nil
+	case "ms":
+		return time.Millisecond, nil
+	case "s":
+		return time.Second, nil
+	default:
+		return 0, httpgrpc.Errorf(http.StatusBadRequest, "unsupported precision: %s, supported: [ns, us, ms, s]", precision)
+	}
+}
+
+type influxConverter struct {
+	// nameSeparator is the separator between the measurement and field names in the metric name.
+	nameSeparator string
+
+	// valueFieldName is the name of the field whose metric name is the measurement name only.
+	valueFieldName string
+
+	// precision is the unit of the timestamps in the request.
+	precision time.Duration
+
+	// now is the timestamp used for lines without timestamp.
+	now time.Time
+}
+
+// linesToTimeseries converts the input line protocol lines into time series, one for each numeric field.
+// Lines failing to parse are skipped, unless no line can be parsed at all.
+func (c influxConverter) linesToTimeseries(tenantID string, body []byte, discardedDueToInfluxParseError *prometheus.CounterVec, logger log.Logger) ([]mimirpb.PreallocTimeseries, error) {
+	var (
+		parseErrs []string
+		dropped   int
+	)
+
+	timeseries := mimirpb.PreallocTimeseriesSliceFromPool()
+
+	for lineNum, line := range bytes.Split(body, []byte("\n")) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 || line[0] == '#' {
+			continue
+		}
+
+		point, err := parseInfluxLine(string(line))
+		if err != nil {
+			dropped++
+			parseErrs = append(parseErrs, fmt.Sprintf("line %d: %s", lineNum+1, err.Error()))
+			continue
+		}
+
+		timeseries, err = c.appendPoint(timeseries, point)
+		if err != nil {
+			dropped++
+			parseErrs = append(parseErrs, fmt.Sprintf("line %d: %s", lineNum+1, err.Error()))
+		}
+	}
+
+	if dropped > 0 {
+		discardedDueToInfluxParseError.WithLabelValues(tenantID, "").Add(float64(dropped)) // Group is empty here as lines couldn't be parsed
+
+		errMsg := strings.Join(parseErrs, "; ")
+		if len(errMsg) > maxErrMsgLen {
+			errMsg = errMsg[:maxErrMsgLen]
+		}
+
+		if len(timeseries) == 0 {
+			mimirpb.ReuseSlice(timeseries)
+			return nil, errors.New(errMsg)
+		}
+
+		level.Warn(logger).Log("msg", "Influx line protocol parse error", "err", errMsg)
+	}
+
+	return timeseries, nil
+}
+
+// appendPoint appends a time series for each numeric field of the input point. It returns an error,
+// without appending any time series, if the point tags don't map to distinct label names.
+func (c influxConverter) appendPoint(timeseries []mimirpb.PreallocTimeseries, point influxPoint) ([]mimirpb.PreallocTimeseries, error) {
+	tagLabels := make([]mimirpb.LabelAdapter, 0, len(point.tags))
+	for _, tag := range point.tags {
+		name := sanitizeInfluxName(tag.key, false)
+		if name == model.MetricNameLabel {
+			return timeseries, fmt.Errorf("invalid tag %q: the %s label name is reserved", tag.key, model.MetricNameLabel)
+		}
+		tagLabels = append(tagLabels, mimirpb.LabelAdapter{Name: name, Value: tag.value})
+	}
+	sort.Slice(tagLabels, func(i, j int) bool {
+		return tagLabels[i].Name < tagLabels[j].Name
+	})
+	for i := 1; i < len(tagLabels); i++ {
+		if tagLabels[i].Name == tagLabels[i-1].Name {
+			return timeseries, fmt.Errorf("duplicate tag: more than one tag maps to the label name %q", tagLabels[i].Name)
+		}
+	}
+
+	timestampMs := c.now.UnixMilli()
+	if point.hasTimestamp {
+		timestampMs = point.timestamp * int64(c.precision) / int64(time.Millisecond)
+	}
+
+	measurement := sanitizeInfluxName(point.measurement, true)
+
+	for _, field := range point.fields {
+		if !field.numeric {
+			// Only numeric fields can be stored as samples.
+			continue
+		}
+
+		metricName := measurement
+		if field.key != c.valueFieldName {
+			metricName = measurement + c.nameSeparator + sanitizeInfluxName(field.key, true)
+		}
+
+		labels := make([]mimirpb.LabelAdapter, 0, len(tagLabels)+1)
+		labels = append(labels, mimirpb.LabelAdapter{Name: model.MetricNameLabel, Value: metricName})
+		labels = append(labels, tagLabels...)
+		sort.Slice(labels, func(i, j int) bool {
+			return labels[i].Name < labels[j].Name
+		})
+
+		ts := mimirpb.TimeseriesFromPool()
+		ts.Labels = append(ts.Labels, labels...)
+		ts.Samples = append(ts.Samples, mimirpb.Sample{TimestampMs: timestampMs, Value: field.value})
+
+		timeseries = append(timeseries, mimirpb.PreallocTimeseries{TimeSeries: ts})
+	}
+
+	return timeseries, nil
+}
+
+// sanitizeInfluxName replaces all characters which are not allowed in a Prometheus metric name
+// (when allowColons is true) or label name with underscores.
+func sanitizeInfluxName(name string, allowColons bool) string {
+	if name == "" {
+		return "_"
+	}
+
+	valid := func(i int, r rune) bool {
+		return (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || r == '_' ||
+			(allowColons && r == ':') || (i > 0 && r >= '0' && r <= '9')
+	}
+
+	var sb strings.Builder
+	sb.Grow(len(name))
+	for i, r := range name {
+		if valid(i, r) {
+			sb.WriteRune(r)
+		} else if i == 0 && r >= '0' && r <= '9' {
+			sb.WriteRune('_')
+			sb.WriteRune(r)
+		} else {
+			sb.WriteRune('_')
+		}
+	}
+	return sb.String()
+}
+
+type influxTag struct {
+	key, value string
+}
+
+type influxField struct {
+	key string
+
+	// value is the field value, set only if the field is numeric (float, integer, unsigned or boolean).
+	value   float64
+	numeric bool
+}
+
+// influxPoint is a single parsed line of the InfluxDB line protocol.
+type influxPoint struct {
+	measurement  string
+	tags         []influxTag
+	fields       []influxField
+	timestamp    int64
+	hasTimestamp bool
+}
+
+// parseInfluxLine parses a single line of the InfluxDB line protocol:
+//
+//	<measurement>[,<tag_key>=<tag_value>...] <field_key>=<field_value>[,<field_key>=<field_value>...] [<timestamp>]
+func parseInfluxLine(line string) (influxPoint, error) {
+	var (
+		point influxPoint
+		pos   int
+		stop  byte
+	)
+
+	point.measurement, pos, stop = scanInfluxToken(line, 0, ", ")
+	if point.measurement == "" {
+		return point, errors.New("missing measurement")
+	}
+
+	// Tags.
+	for stop == ',' {
+		var tag influxTag
+
+		tag.key, pos, stop = scanInfluxToken(line, pos, "=")
+		if stop != '=' || tag.key == "" {
+			return point, errors.New("invalid tag: missing tag key")
+		}
+		tag.value, pos, stop = scanInfluxToken(line, pos, ", ")
+		if tag.value == "" {
+			return point, fmt.Errorf("invalid tag %q: missing tag value", tag.key)
+		}
+
+		point.tags = append(point.tags, tag)
+	}
+
+	if stop != ' ' {
+		return point, errors.New("missing fields")
+	}
+	pos = skipInfluxSpaces(line, pos)
+
+	// Fields.
+	for {
+		var (
+			field influxField
+			raw   string
+			err   error
+		)
+
+		field.key, pos, stop = scanInfluxToken(line, pos, "=")
+		if stop != '=' || field.key == "" {
+			return point, errors.New("invalid field: missing field key")
+		}
+
+		if pos < len(line) && line[pos] == '"' {
+			// String field values are parsed to validate the line, but can't be converted to samples.
+			pos, err = skipInfluxString(line, pos)
+			if err != nil {
+				return point, fmt.Errorf("invalid field %q: %w", field.key, err)
+			}
+			stop = 0
+			if pos < len(line) {
+				stop = line[pos]
+				pos++
+			}
+		} else {
+			raw, pos, stop = scanInfluxToken(line, pos, ", ")
+			field.value, err = parseInfluxFieldValue(raw)
+			if err != nil {
+				return point, fmt.Errorf("invalid field %q: %w", field.key, err)
+			}
+			field.numeric = true
+		}
+
+		point.fields = append(point.fields, field)
+
+		if stop != ',' {
+			break
+		}
+	}
+
+	if stop != 0 && stop != ' ' {
+		return point, fmt.Errorf("unexpected character %q after fields", stop)
+	}
+
+	// Timestamp.
+	if rest := strings.TrimSpace(line[pos:]); rest != "" {
+		ts, err := strconv.ParseInt(rest, 10, 64)
+		if err != nil {
+			return point, fmt.Errorf("invalid timestamp %q", rest)
+		}
+		point.timestamp = ts
+		point.hasTimestamp = true
+	}
+
+	return point, nil
+}
+
+// scanInfluxToken reads from line starting at pos, until any of the unescaped stop characters is found.
+// It returns the unescaped token, the position after the stop character, and the stop character
+// found (0 if the end of the line has been reached).
+func scanInfluxToken(line string, pos int, stops string) (string, int, byte) {
+	var sb strings.Builder
+
+	for pos < len(line) {
+		ch := line[pos]
+
+		// Backslash escapes commas, equal signs and spaces.
+		if ch == '\\' && pos+1 < len(line) && strings.IndexByte(",= ", line[pos+1]) >= 0 {
+			sb.WriteByte(line[pos+1])
+			pos += 2
+			continue
+		}
+
+		if strings.IndexByte(stops, ch) >= 0 {
+			return sb.String(), pos + 1, ch
+		}
+
+		sb.WriteByte(ch)
+		pos++
+	}
+
+	return sb.String(), pos, 0
+}
+
+// skipInfluxString skips the double-quoted string starting at pos, and returns the position after it.
+func skipInfluxString(line string, pos int) (int, error) {
+	for pos++; pos < len(line); pos++ {
+		switch line[pos] {
+		case '\\':
+			pos++
+		case '"':
+			return pos + 1, nil
+		}
+	}
+	return pos, errors.New("unterminated string value")
+}
+
+func skipInfluxSpaces(line string, pos int) int {
+	for pos < len(line) && line[pos] == ' ' {
+		pos++
+	}
+	return pos
+}
+
+// parseInfluxFieldValue parses a non-string field value.
+func parseInfluxFieldValue(raw string) (float64, error) {
+	switch raw {
+	case "":
+		return 0, errors.New("missing field value")
+	case "t", "T", "true", "True", "TRUE":
+		return 1, nil
+	case "f", "F", "false", "False", "FALSE":
+		return 0, nil
+	}
+
+	switch raw[len(raw)-1] {
+	case 'i':
+		v, err := strconv.ParseInt(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid integer value %q", raw)
+		}
+		return float64(v), nil
+	case 'u':
+		v, err := strconv.ParseUint(raw[:len(raw)-1], 10, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid unsigned integer value %q", raw)
+		}
+		return float64(v), nil
+	}
+
+	v, err := strconv.ParseFloat(raw, 64)
+	if err != nil || math.IsNaN(v) || math.IsInf(v, 0) {
+		return 0, fmt.Errorf("invalid float value %q", raw)
+	}
+	return v, nil
+}
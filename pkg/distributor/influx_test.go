@@ -0,0 +1,299 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestParseInfluxLine(t *testing.T) {
+	tests := map[string]struct {
+		line          string
+		expected      influxPoint
+		expectedError string
+	}{
+		"measurement and single field": {
+			line: "cpu value=1.5",
+			expected: influxPoint{
+				measurement: "cpu",
+				fields:      []influxField{{key: "value", value: 1.5, numeric: true}},
+			},
+		},
+		"tags, multiple field types and timestamp": {
+			line: `cpu,host=a,region=eu usage=1i,free=2u,up=t,down=F,desc="a \"quoted\", string" 1700000000000000000`,
+			expected: influxPoint{
+				measurement: "cpu",
+				tags:        []influxTag{{key: "host", value: "a"}, {key: "region", value: "eu"}},
+				fields: []influxField{
+					{key: "usage", value: 1, numeric: true},
+					{key: "free", value: 2, numeric: true},
+					{key: "up", value: 1, numeric: true},
+					{key: "down", value: 0, numeric: true},
+					{key: "desc"},
+				},
+				timestamp:    1700000000000000000,
+				hasTimestamp: true,
+			},
+		},
+		"escaped characters": {
+			line: `disk\ io,path=/var\,log,key\=x=y read\ bytes=10 5`,
+			expected: influxPoint{
+				measurement:  "disk io",
+				tags:         []influxTag{{key: "path", value: "/var,log"}, {key: "key=x", value: "y"}},
+				fields:       []influxField{{key: "read bytes", value: 10, numeric: true}},
+				timestamp:    5,
+				hasTimestamp: true,
+			},
+		},
+		"missing fields": {
+			line:          "cpu,host=a",
+			expectedError: "missing fields",
+		},
+		"missing tag value": {
+			line:          "cpu,host= value=1",
+			expectedError: `invalid tag "host": missing tag value`,
+		},
+		"invalid field value": {
+			line:          "cpu value=abc",
+			expectedError: `invalid field "value": invalid float value "abc"`,
+		},
+		"invalid integer field value": {
+			line:          "cpu value=1.5i",
+			expectedError: `invalid field "value": invalid integer value "1.5i"`,
+		},
+		"unterminated string": {
+			line:          `cpu desc="abc`,
+			expectedError: `invalid field "desc": unterminated string value`,
+		},
+		"invalid timestamp": {
+			line:          "cpu value=1 abc",
+			expectedError: `invalid timestamp "abc"`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actual, err := parseInfluxLine(testData.line)
+			if testData.expectedError != "" {
+				require.EqualError(t, err, testData.expectedError)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestSanitizeInfluxName(t *testing.T) {
+	assert.Equal(t, "cpu_usage", sanitizeInfluxName("cpu.usage", true))
+	assert.Equal(t, "node:cpu", sanitizeInfluxName("node:cpu", true))
+	assert.Equal(t, "node_cpu", sanitizeInfluxName("node:cpu", false))
+	assert.Equal(t, "_1xx", sanitizeInfluxName("1xx", false))
+	assert.Equal(t, "_", sanitizeInfluxName("", false))
+}
+
+func TestInfluxHandler(t *testing.T) {
+	const tenantID = "test"
+
+	now := time.Now()
+
+	tests := map[string]struct {
+		body                  string
+		query                 string
+		compress              bool
+		maxMsgSize            int
+		tenantLimits          func(*validation.Limits)
+		expectedStatusCode    int
+		expectedSeries        []mimirpb.TimeSeries
+		expectedDiscarded     int
+		expectRecentTimestamp bool
+	}{
+		"should convert each numeric field to a series": {
+			body: strings.Join([]string{
+				"# comment",
+				"cpu,host=a value=1,usage=0.5,desc=\"skipped\" 1700000000000000000",
+				"",
+				"mem,host=b free=10i 1700000001000000000",
+			}, "\n"),
+			expectedStatusCode: http.StatusOK,
+			expectedSeries: []mimirpb.TimeSeries{
+				makeInfluxSeries("cpu", "a", 1700000000000, 1),
+				makeInfluxSeries("cpu_usage", "a", 1700000000000, 0.5),
+				makeInfluxSeries("mem_free", "b", 1700000001000, 10),
+			},
+		},
+		"should honor the precision query parameter": {
+			body:               "cpu,host=a value=1 1700000000",
+			query:              "?precision=s",
+			expectedStatusCode: http.StatusOK,
+			expectedSeries: []mimirpb.TimeSeries{
+				makeInfluxSeries("cpu", "a", 1700000000000, 1),
+			},
+		},
+		"should honor the per-tenant naming configuration": {
+			body: "cpu,host=a value=1,usage=2 1700000000000000000",
+			tenantLimits: func(l *validation.Limits) {
+				l.InfluxMetricNameSeparator = ":"
+				l.InfluxValueFieldName = "usage"
+			},
+			expectedStatusCode: http.StatusOK,
+			expectedSeries: []mimirpb.TimeSeries{
+				makeInfluxSeries("cpu:value", "a", 1700000000000, 1),
+				makeInfluxSeries("cpu", "a", 1700000000000, 2),
+			},
+		},
+		"should support gzip compression": {
+			body:               "cpu,host=a value=1 1700000000000000000",
+			compress:           true,
+			expectedStatusCode: http.StatusOK,
+			expectedSeries: []mimirpb.TimeSeries{
+				makeInfluxSeries("cpu", "a", 1700000000000, 1),
+			},
+		},
+		"should skip lines failing to parse": {
+			body:               "cpu,host=a value=1 1700000000000000000\ncpu,host=b value=x\ncpu,host=c",
+			expectedStatusCode: http.StatusOK,
+			expectedSeries: []mimirpb.TimeSeries{
+				makeInfluxSeries("cpu", "a", 1700000000000, 1),
+			},
+			expectedDiscarded: 2,
+		},
+		"should skip lines with tags mapping to the metric name or to duplicate label names": {
+			body: strings.Join([]string{
+				"cpu,host=a value=1 1700000000000000000",
+				"cpu,host=b,__name__=x value=1 1700000000000000000",
+				"cpu,host=c,my-tag=x,my.tag=y value=1 1700000000000000000",
+			}, "\n"),
+			expectedStatusCode: http.StatusOK,
+			expectedSeries: []mimirpb.TimeSeries{
+				makeInfluxSeries("cpu", "a", 1700000000000, 1),
+			},
+			expectedDiscarded: 2,
+		},
+		"should use the current time for lines without timestamp": {
+			body:                  "cpu,host=a value=1",
+			expectedStatusCode:    http.StatusOK,
+			expectRecentTimestamp: true,
+		},
+		"should fail if no line can be parsed": {
+			body:               "cpu,host=a value=x",
+			expectedStatusCode: http.StatusBadRequest,
+			expectedDiscarded:  1,
+		},
+		"should fail on unsupported precision": {
+			body:               "cpu,host=a value=1",
+			query:              "?precision=h",
+			expectedStatusCode: http.StatusBadRequest,
+		},
+		"should fail if the request is too big": {
+			body:               "cpu,host=a value=1 1700000000000000000",
+			maxMsgSize:         10,
+			expectedStatusCode: http.StatusRequestEntityTooLarge,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			defaults := validation.Limits{}
+			flagext.DefaultValues(&defaults)
+
+			tenantLimits := defaults
+			if testData.tenantLimits != nil {
+				testData.tenantLimits(&tenantLimits)
+			}
+
+			limits, err := validation.NewOverrides(defaults, validation.NewMockTenantLimits(map[string]*validation.Limits{tenantID: &tenantLimits}))
+			require.NoError(t, err)
+
+			maxMsgSize := testData.maxMsgSize
+			if maxMsgSize == 0 {
+				maxMsgSize = 100000
+			}
+
+			pushed := false
+			reg := prometheus.NewPedanticRegistry()
+			handler := InfluxHandler(maxMsgSize, nil, false, limits, RetryConfig{}, reg, func(_ context.Context, pushReq *Request) error {
+				request, err := pushReq.WriteRequest()
+				if err != nil {
+					return err
+				}
+				defer pushReq.CleanUp()
+				pushed = true
+
+				if testData.expectRecentTimestamp {
+					require.Len(t, request.Timeseries, 1)
+					require.Len(t, request.Timeseries[0].Samples, 1)
+					assert.GreaterOrEqual(t, request.Timeseries[0].Samples[0].TimestampMs, now.UnixMilli())
+					return nil
+				}
+
+				actual := make([]mimirpb.TimeSeries, 0, len(request.Timeseries))
+				for _, ts := range request.Timeseries {
+					actual = append(actual, mimirpb.TimeSeries{Labels: ts.Labels, Samples: ts.Samples})
+				}
+				assert.Equal(t, testData.expectedSeries, actual)
+				return nil
+			}, log.NewNopLogger())
+
+			body := []byte(testData.body)
+			if testData.compress {
+				var b bytes.Buffer
+				gz := gzip.NewWriter(&b)
+				_, err := gz.Write(body)
+				require.NoError(t, err)
+				require.NoError(t, gz.Close())
+				body = b.Bytes()
+			}
+
+			req := httptest.NewRequest(http.MethodPost, "/api/v1/push/influx/write"+testData.query, bytes.NewReader(body))
+			req.Header.Set("X-Scope-OrgID", tenantID)
+			if testData.compress {
+				req.Header.Set("Content-Encoding", "gzip")
+			}
+			req = req.WithContext(user.InjectOrgID(req.Context(), tenantID))
+
+			resp := httptest.NewRecorder()
+			handler.ServeHTTP(resp, req)
+
+			assert.Equal(t, testData.expectedStatusCode, resp.Code)
+			assert.Equal(t, testData.expectedStatusCode == http.StatusOK, pushed)
+
+			expectedMetrics := ""
+			if testData.expectedDiscarded > 0 {
+				expectedMetrics = fmt.Sprintf(`
+					# HELP cortex_discarded_samples_total The total number of samples that were discarded.
+					# TYPE cortex_discarded_samples_total counter
+					cortex_discarded_samples_total{group="",reason="influx_parse_error",user="test"} %d
+				`, testData.expectedDiscarded)
+			}
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expectedMetrics), "cortex_discarded_samples_total"))
+		})
+	}
+}
+
+func makeInfluxSeries(name, host string, timestampMs int64, value float64) mimirpb.TimeSeries {
+	return mimirpb.TimeSeries{
+		Labels:  []mimirpb.LabelAdapter{{Name: "__name__", Value: name}, {Name: "host", Value: host}},
+		Samples: []mimirpb.Sample{{TimestampMs: timestampMs, Value: value}},
+	}
+}
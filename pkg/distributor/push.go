@@ -12,12 +12,14 @@ import (
 	"flag"
 	"fmt"
 	"math/rand"
+	"mime"
 	"net/http"
 	"strconv"
 	"sync"
 
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
+	"github.com/gogo/protobuf/proto"
 	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/httpgrpc/server"
 	"github.com/grafana/dskit/middleware"
@@ -86,7 +88,24 @@ func Handler(
 	logger log.Logger,
 ) http.Handler {
 	return handler(maxRecvMsgSize, sourceIPs, allowSkipLabelNameValidation, limits, retryCfg, push, logger, func(ctx context.Context, r *http.Request, maxRecvMsgSize int, buffers *util.RequestBuffers, req *mimirpb.PreallocWriteRequest, _ log.Logger) error {
-		err := util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), maxRecvMsgSize, buffers, req, util.RawSnappy)
+		version, err := remoteWriteVersionFromRequest(r)
+		if err != nil {
+			return err
+		}
+
+		var msg proto.Message = req
+		if version == remoteWriteVersion2 {
+			tenantID, err := tenant.TenantID(ctx)
+			if err != nil {
+				return err
+			}
+			msg = &mimirpb.PreallocWriteRequestRW2{
+				PreallocWriteRequest:          req,
+				CreatedTimestampZeroIngestion: limits.CreatedTimestampZeroIngestionEnabled(tenantID),
+			}
+		}
+
+		err = util.ParseProtoReader(ctx, r.Body, int(r.ContentLength), maxRecvMsgSize, buffers, msg, util.RawSnappy)
 		if errors.Is(err, util.MsgSizeTooLargeErr{}) {
 			err = distributorMaxWriteMessageSizeErr{actual: int(r.ContentLength), limit: maxRecvMsgSize}
 		}
@@ -152,7 +171,16 @@ func handler(
 			return &req.WriteRequest, cleanup, nil
 		}
 		req := newRequest(supplier)
-		if err := push(ctx, req); err != nil {
+		err := push(ctx, req)
+
+		// Remote-write 2.0 clients expect the number of written samples to be returned in the response headers.
+		// They're the ones actually written, after deduplication, relabeling and validation, which could be
+		// less than the ones in the request even if the request succeeded, or more than zero if it failed.
+		if version, _ := remoteWriteVersionFromRequest(r); version == remoteWriteVersion2 {
+			req.written.setHeaders(w.Header())
+		}
+
+		if err != nil {
 			if errors.Is(err, context.Canceled) {
 				http.Error(w, err.Error(), statusClientClosedRequest)
 				level.Warn(logger).Log("msg", "push request canceled", "err", err)
@@ -172,10 +200,67 @@ func handler(
 			}
 			addHeaders(w, err, r, code, retryCfg)
 			http.Error(w, msg, code)
+			return
 		}
 	})
 }
 
+type remoteWriteVersion int
+
+const (
+	remoteWriteVersion1 remoteWriteVersion = iota
+	remoteWriteVersion2
+)
+
+const (
+	remoteWriteProtoV1 = "prometheus.WriteRequest"
+	remoteWriteProtoV2 = "io.prometheus.write.v2.Request"
+
+	writtenSamplesHeader    = "X-Prometheus-Remote-Write-Samples-Written"
+	writtenHistogramsHeader = "X-Prometheus-Remote-Write-Histograms-Written"
+	writtenExemplarsHeader  = "X-Prometheus-Remote-Write-Exemplars-Written"
+)
+
+// remoteWriteVersionFromRequest negotiates the remote-write protocol version from the Content-Type header
+// of the request. Requests without a protobuf Content-Type are considered remote-write 1.0 requests, for
+// backwards compatibility with clients not setting it.
+func remoteWriteVersionFromRequest(r *http.Request) (remoteWriteVersion, error) {
+	mediaType, params, err := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if err != nil || mediaType != "application/x-protobuf" {
+		return remoteWriteVersion1, nil
+	}
+
+	switch params["proto"] {
+	case "", remoteWriteProtoV1:
+		return remoteWriteVersion1, nil
+	case remoteWriteProtoV2:
+		return remoteWriteVersion2, nil
+	default:
+		return remoteWriteVersion1, httpgrpc.Errorf(http.StatusUnsupportedMediaType, "unsupported remote-write protobuf message %q, supported: [%s, %s]", params["proto"], remoteWriteProtoV1, remoteWriteProtoV2)
+	}
+}
+
+// writtenStats holds the number of samples, histograms and exemplars in a write request.
+type writtenStats struct {
+	samples, histograms, exemplars int
+}
+
+func newWrittenStats(req *mimirpb.WriteRequest) writtenStats {
+	var stats writtenStats
+	for _, ts := range req.Timeseries {
+		stats.samples += len(ts.Samples)
+		stats.histograms += len(ts.Histograms)
+		stats.exemplars += len(ts.Exemplars)
+	}
+	return stats
+}
+
+func (s writtenStats) setHeaders(h http.Header) {
+	h.Set(writtenSamplesHeader, strconv.Itoa(s.samples))
+	h.Set(writtenHistogramsHeader, strconv.Itoa(s.histograms))
+	h.Set(writtenExemplarsHeader, strconv.Itoa(s.exemplars))
+}
+
 func calculateRetryAfter(retryAttemptHeader string, baseSeconds int, maxBackoffExponent int) string {
 	retryAttempt, err := strconv.Atoi(retryAttemptHeader)
 	// If retry-attempt is not valid, set it to default 1
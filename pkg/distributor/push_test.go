@@ -12,6 +12,7 @@ import (
 	"errors"
 	"fmt"
 	"io"
+	"math"
 	"net/http"
 	"net/http/httptest"
 	"strconv"
@@ -34,6 +35,7 @@ import (
 	"go.opentelemetry.io/collector/pdata/pmetric"
 	"go.opentelemetry.io/collector/pdata/pmetric/pmetricotlp"
 	"google.golang.org/grpc/codes"
+	"google.golang.org/protobuf/encoding/protowire"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/util"
@@ -49,6 +51,141 @@ func TestHandler_remoteWrite(t *testing.T) {
 	assert.Equal(t, 200, resp.Code)
 }
 
+func TestHandler_remoteWrite2(t *testing.T) {
+	const tenantID = "test"
+
+	// Series with a sample, an exemplar, metadata and created timestamp, encoded as a remote-write 2.0 request.
+	var exemplar []byte
+	exemplar = protowire.AppendTag(exemplar, 1, protowire.BytesType)
+	exemplar = protowire.AppendBytes(exemplar, protowire.AppendVarint(protowire.AppendVarint(nil, 3), 4))
+	exemplar = protowire.AppendTag(exemplar, 2, protowire.Fixed64Type)
+	exemplar = protowire.AppendFixed64(exemplar, math.Float64bits(1))
+	exemplar = protowire.AppendTag(exemplar, 3, protowire.VarintType)
+	exemplar = protowire.AppendVarint(exemplar, 2000)
+
+	var sample []byte
+	sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(5))
+	sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, 2000)
+
+	var metadata []byte
+	metadata = protowire.AppendTag(metadata, 1, protowire.VarintType)
+	metadata = protowire.AppendVarint(metadata, uint64(mimirpb.COUNTER))
+	metadata = protowire.AppendTag(metadata, 3, protowire.VarintType)
+	metadata = protowire.AppendVarint(metadata, 5)
+
+	var series []byte
+	series = protowire.AppendTag(series, 1, protowire.BytesType)
+	series = protowire.AppendBytes(series, protowire.AppendVarint(protowire.AppendVarint(nil, 1), 2))
+	series = protowire.AppendTag(series, 2, protowire.BytesType)
+	series = protowire.AppendBytes(series, sample)
+	series = protowire.AppendTag(series, 4, protowire.BytesType)
+	series = protowire.AppendBytes(series, exemplar)
+	series = protowire.AppendTag(series, 5, protowire.BytesType)
+	series = protowire.AppendBytes(series, metadata)
+	series = protowire.AppendTag(series, 6, protowire.VarintType)
+	series = protowire.AppendVarint(series, 1000)
+
+	var body []byte
+	for _, symbol := range []string{"", "__name__", "foo", "trace_id", "abc", "Help text."} {
+		body = protowire.AppendTag(body, 4, protowire.BytesType)
+		body = protowire.AppendString(body, symbol)
+	}
+	body = protowire.AppendTag(body, 5, protowire.BytesType)
+	body = protowire.AppendBytes(body, series)
+
+	tests := map[string]struct {
+		contentType                   string
+		createdTimestampZeroIngestion bool
+		dropSeries                    bool
+		expectedCode                  int
+		expectedSamples               []mimirpb.Sample
+		expectedWrittenSamples        int
+		expectedWrittenExemplars      int
+	}{
+		"should decode a remote-write 2.0 request": {
+			contentType:              "application/x-protobuf;proto=io.prometheus.write.v2.Request",
+			expectedCode:             http.StatusOK,
+			expectedSamples:          []mimirpb.Sample{{TimestampMs: 2000, Value: 5}},
+			expectedWrittenSamples:   1,
+			expectedWrittenExemplars: 1,
+		},
+		"should ingest a zero sample at the created timestamp if enabled for the tenant": {
+			contentType:                   "application/x-protobuf;proto=io.prometheus.write.v2.Request",
+			createdTimestampZeroIngestion: true,
+			expectedCode:                  http.StatusOK,
+			expectedSamples:               []mimirpb.Sample{{TimestampMs: 1000, Value: 0}, {TimestampMs: 2000, Value: 5}},
+			expectedWrittenSamples:        2,
+			expectedWrittenExemplars:      1,
+		},
+		"should report the samples actually written rather than the ones received": {
+			contentType:     "application/x-protobuf;proto=io.prometheus.write.v2.Request",
+			dropSeries:      true,
+			expectedCode:    http.StatusOK,
+			expectedSamples: []mimirpb.Sample{{TimestampMs: 2000, Value: 5}},
+		},
+		"should reject an unsupported protobuf message": {
+			contentType:  "application/x-protobuf;proto=io.prometheus.write.v3.Request",
+			expectedCode: http.StatusUnsupportedMediaType,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			tenantLimits := &validation.Limits{}
+			flagext.DefaultValues(tenantLimits)
+			tenantLimits.CreatedTimestampZeroIngestionEnabled = testData.createdTimestampZeroIngestion
+			limits, err := validation.NewOverrides(validation.Limits{}, validation.NewMockTenantLimits(map[string]*validation.Limits{tenantID: tenantLimits}))
+			require.NoError(t, err)
+
+			req := createRequest(t, body)
+			req.Header.Set("Content-Type", testData.contentType)
+			req.Header.Set("X-Prometheus-Remote-Write-Version", "2.0.0")
+			req = req.WithContext(user.InjectOrgID(req.Context(), tenantID))
+
+			resp := httptest.NewRecorder()
+			handler := Handler(100000, nil, false, limits, RetryConfig{}, func(_ context.Context, pushReq *Request) error {
+				request, err := pushReq.WriteRequest()
+				if err != nil {
+					return err
+				}
+				t.Cleanup(pushReq.CleanUp)
+
+				require.Len(t, request.Timeseries, 1)
+				assert.Equal(t, []mimirpb.LabelAdapter{{Name: "__name__", Value: "foo"}}, request.Timeseries[0].Labels)
+				assert.Equal(t, testData.expectedSamples, request.Timeseries[0].Samples)
+				assert.Equal(t, []mimirpb.Exemplar{{Labels: []mimirpb.LabelAdapter{{Name: "trace_id", Value: "abc"}}, Value: 1, TimestampMs: 2000}}, request.Timeseries[0].Exemplars)
+				assert.Equal(t, []*mimirpb.MetricMetadata{{Type: mimirpb.COUNTER, MetricFamilyName: "foo", Help: "Help text."}}, request.Metadata)
+
+				// The distributor records the written stats once the request has been filtered and pushed.
+				if !testData.dropSeries {
+					pushReq.written = newWrittenStats(request)
+				}
+				return nil
+			}, log.NewNopLogger())
+			handler.ServeHTTP(resp, req)
+
+			require.Equal(t, testData.expectedCode, resp.Code)
+			if testData.expectedCode == http.StatusOK {
+				assert.Equal(t, strconv.Itoa(testData.expectedWrittenSamples), resp.Header().Get("X-Prometheus-Remote-Write-Samples-Written"))
+				assert.Equal(t, "0", resp.Header().Get("X-Prometheus-Remote-Write-Histograms-Written"))
+				assert.Equal(t, strconv.Itoa(testData.expectedWrittenExemplars), resp.Header().Get("X-Prometheus-Remote-Write-Exemplars-Written"))
+			}
+		})
+	}
+}
+
+func TestHandler_remoteWrite1ShouldNotReturnWrittenHeaders(t *testing.T) {
+	req := createRequest(t, createPrometheusRemoteWriteProtobuf(t))
+	req.Header.Set("Content-Type", "application/x-protobuf;proto=prometheus.WriteRequest")
+	resp := httptest.NewRecorder()
+	handler := Handler(100000, nil, false, nil, RetryConfig{}, verifyWritePushFunc(t, mimirpb.API), log.NewNopLogger())
+	handler.ServeHTTP(resp, req)
+	assert.Equal(t, 200, resp.Code)
+	assert.Empty(t, resp.Header().Get("X-Prometheus-Remote-Write-Samples-Written"))
+}
+
 func TestOTelMetricsToMetadata(t *testing.T) {
 	otelMetrics := pmetric.NewMetrics()
 	rs := otelMetrics.ResourceMetrics().AppendEmpty()
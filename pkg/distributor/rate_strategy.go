@@ -86,6 +86,39 @@ func (s *globalStrategy) Burst(tenantID string) int {
 	return s.baseStrategy.Burst(tenantID)
 }
 
+type multipliedStrategy struct {
+	baseStrategy limiter.RateLimiterStrategy
+	multiplier   float64
+}
+
+// newMultipliedRateStrategy returns a strategy whose limit and burst are the ones of the base strategy
+// multiplied by the input multiplier.
+func newMultipliedRateStrategy(baseStrategy limiter.RateLimiterStrategy, multiplier float64) limiter.RateLimiterStrategy {
+	return &multipliedStrategy{
+		baseStrategy: baseStrategy,
+		multiplier:   multiplier,
+	}
+}
+
+func (s *multipliedStrategy) Limit(tenantID string) float64 {
+	limit := s.baseStrategy.Limit(tenantID)
+
+	if limit == float64(rate.Inf) {
+		return limit
+	}
+	return limit * s.multiplier
+}
+
+func (s *multipliedStrategy) Burst(tenantID string) int {
+	burst := float64(s.baseStrategy.Burst(tenantID)) * s.multiplier
+
+	// If the burst * multiplier is too large we want to set it to the max possible burst value
+	if burst >= math.MaxInt {
+		return math.MaxInt
+	}
+	return int(math.Ceil(burst))
+}
+
 type requestRateStrategy struct {
 	limits *validation.Overrides
 }
@@ -85,6 +85,37 @@ func TestIngestionRateStrategy(t *testing.T) {
 		assert.Equal(t, strategy.Limit("test"), math.MaxFloat64)
 		assert.Equal(t, strategy.Burst("test"), math.MaxInt)
 	})
+	t.Run("multiplied rate limiter should multiply the limit and the burst of the base strategy", func(t *testing.T) {
+		overrides, err := validation.NewOverrides(validation.Limits{
+			IngestionRate:      float64(1000),
+			IngestionBurstSize: 10000,
+		}, nil)
+		require.NoError(t, err)
+
+		mockRing := newReadLifecyclerMock()
+		mockRing.On("HealthyInstancesCount").Return(2)
+
+		strategy := newMultipliedRateStrategy(newGlobalRateStrategyWithBurstFactor(overrides, mockRing), 1.5)
+		assert.Equal(t, strategy.Limit("test"), float64(750))
+		assert.Equal(t, strategy.Burst("test"), 15000)
+	})
+	t.Run("multiplied rate limiter should keep unlimited settings and cap the burst to max int", func(t *testing.T) {
+		strategy := newMultipliedRateStrategy(newInfiniteRateStrategy(), 2)
+		assert.Equal(t, strategy.Limit("test"), float64(rate.Inf))
+		assert.Equal(t, strategy.Burst("test"), 0)
+
+		overrides, err := validation.NewOverrides(validation.Limits{
+			IngestionRate:      float64(1000),
+			IngestionBurstSize: math.MaxInt,
+		}, nil)
+		require.NoError(t, err)
+
+		mockRing := newReadLifecyclerMock()
+		mockRing.On("HealthyInstancesCount").Return(1)
+
+		strategy = newMultipliedRateStrategy(newGlobalRateStrategyWithBurstFactor(overrides, mockRing), 2)
+		assert.Equal(t, strategy.Burst("test"), math.MaxInt)
+	})
 }
 
 type readLifecyclerMock struct {
@@ -0,0 +1,873 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/limiter"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+)
+
+const (
+	// replayBufferRecordHeaderSize is the size of the header of each record on disk:
+	// the payload length (4 bytes) and the CRC32 checksum of the payload (4 bytes).
+	replayBufferRecordHeaderSize = 8
+
+	// replayBufferMaxSegmentSize is the size after which a new segment file is started.
+	// Segments are deleted once all their records have been replayed.
+	replayBufferMaxSegmentSize    = 16 << 20
+	replayBufferSegmentNameFormat = "%08d"
+
+	replayBufferDiscardReasonClientError = "client_error"
+	replayBufferDiscardReasonCorrupted   = "corrupted"
+)
+
+var (
+	errReplayBufferDirMissing        = errors.New("the replay buffer directory must be set when the replay buffer is enabled")
+	errReplayBufferInvalidInterval   = errors.New("the replay buffer replay interval must be greater than 0")
+	errReplayBufferInvalidMultiplier = errors.New("the replay buffer replay rate multiplier must be greater than 0")
+	errReplayBufferWithIngestStorage = errors.New("the replay buffer can't be enabled when the ingest storage is enabled")
+	errReplayBufferFull              = errors.New("the tenant replay buffer is full")
+	errReplayBufferStopped           = errors.New("the replay buffer is stopped")
+	errReplayBufferCorruptedRecord   = errors.New("corrupted replay buffer record")
+	errTenantReplayQueueClosed       = errors.New("tenant replay queue closed")
+
+	replayBufferCastagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+)
+
+type ReplayBufferConfig struct {
+	Enabled              bool          `yaml:"enabled" category:"experimental"`
+	Dir                  string        `yaml:"dir" category:"experimental"`
+	ReplayInterval       time.Duration `yaml:"replay_interval" category:"experimental"`
+	ReplayRateMultiplier float64       `yaml:"replay_rate_multiplier" category:"experimental"`
+}
+
+// RegisterFlags adds the flags required to config this to the given FlagSet.
+func (cfg *ReplayBufferConfig) RegisterFlags(f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, "distributor.replay-buffer.enabled", false, "Enable the replay buffer. When enabled, write requests failing because ingesters are unavailable are queued on the local disk, up to -distributor.replay-buffer.max-bytes-per-tenant, and replayed in order once ingesters are available again, instead of returning an error to the client.")
+	f.StringVar(&cfg.Dir, "distributor.replay-buffer.dir", "./replay-buffer/", "Directory where the replay buffer stores the queued write requests.")
+	f.DurationVar(&cfg.ReplayInterval, "distributor.replay-buffer.replay-interval", 5*time.Second, "How frequently the replay buffer tries to replay the queued write requests to ingesters while they are unavailable. Once ingesters are available again, the queued write requests are replayed continuously.")
+	f.Float64Var(&cfg.ReplayRateMultiplier, "distributor.replay-buffer.replay-rate-multiplier", 2, "Multiplier applied to the tenant ingestion rate limit and burst when replaying the queued write requests. Replayed requests are rate limited independently from the received ones, and a multiplier greater than 1 allows to drain the replay buffer while the tenant keeps writing at its ingestion rate limit.")
+}
+
+func (cfg *ReplayBufferConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+
+	if cfg.Dir == "" {
+		return errReplayBufferDirMissing
+	}
+	if cfg.ReplayInterval <= 0 {
+		return errReplayBufferInvalidInterval
+	}
+	if cfg.ReplayRateMultiplier <= 0 {
+		return errReplayBufferInvalidMultiplier
+	}
+	return nil
+}
+
+type replayBufferLimits interface {
+	ReplayBufferMaxBytesPerTenant(userID string) int
+}
+
+// replayFunc sends a queued write request to ingesters.
+type replayFunc func(ctx context.Context, userID string, req *mimirpb.WriteRequest) error
+
+// replayBuffer is a durable, disk-backed, per-tenant queue of write requests which failed because ingesters
+// were unavailable. Queued requests are replayed in order, respecting the tenant ingestion rate limit, once
+// ingesters are available again. Requests are replayed at least once: requests replayed right before a
+// restart of the distributor could be replayed again after the restart.
+type replayBuffer struct {
+	services.Service
+
+	cfg         ReplayBufferConfig
+	limits      replayBufferLimits
+	replay      replayFunc
+	rateLimiter *limiter.RateLimiter
+	logger      log.Logger
+
+	tenantsMtx sync.Mutex
+	tenants    map[string]*tenantReplayQueue
+	stopped    bool
+
+	queuedBytes       *prometheus.GaugeVec
+	queuedRequests    *prometheus.GaugeVec
+	appendedRequests  *prometheus.CounterVec
+	rejectedRequests  *prometheus.CounterVec
+	replayedRequests  *prometheus.CounterVec
+	discardedRequests *prometheus.CounterVec
+	replayFailures    *prometheus.CounterVec
+}
+
+func newReplayBuffer(cfg ReplayBufferConfig, limits replayBufferLimits, replay replayFunc, rateLimiter *limiter.RateLimiter, reg prometheus.Registerer, logger log.Logger) *replayBuffer {
+	b := &replayBuffer{
+		cfg:         cfg,
+		limits:      limits,
+		replay:      replay,
+		rateLimiter: rateLimiter,
+		logger:      logger,
+		tenants:     map[string]*tenantReplayQueue{},
+
+		queuedBytes: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_distributor_replay_buffer_queued_bytes",
+			Help: "Size in bytes of the write requests queued in the replay buffer.",
+		}, []string{"user"}),
+		queuedRequests: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_distributor_replay_buffer_queued_requests",
+			Help: "Number of write requests queued in the replay buffer.",
+		}, []string{"user"}),
+		appendedRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_replay_buffer_appended_requests_total",
+			Help: "Total number of write requests appended to the replay buffer.",
+		}, []string{"user"}),
+		rejectedRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_replay_buffer_rejected_requests_total",
+			Help: "Total number of write requests not appended to the replay buffer because the tenant replay buffer is full.",
+		}, []string{"user"}),
+		replayedRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_replay_buffer_replayed_requests_total",
+			Help: "Total number of write requests successfully replayed from the replay buffer to ingesters.",
+		}, []string{"user"}),
+		discardedRequests: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_replay_buffer_discarded_requests_total",
+			Help: "Total number of write requests removed from the replay buffer without being ingested.",
+		}, []string{"user", "reason"}),
+		replayFailures: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_distributor_replay_buffer_replay_failures_total",
+			Help: "Total number of failed attempts to replay a write request from the replay buffer. The write request is kept in the replay buffer and retried later.",
+		}, []string{"user"}),
+	}
+
+	b.Service = services.NewBasicService(b.starting, b.running, b.stopping)
+	return b
+}
+
+func (b *replayBuffer) starting(_ context.Context) error {
+	if err := os.MkdirAll(b.cfg.Dir, 0o750); err != nil {
+		return errors.Wrap(err, "creating replay buffer directory")
+	}
+
+	entries, err := os.ReadDir(b.cfg.Dir)
+	if err != nil {
+		return errors.Wrap(err, "reading replay buffer directory")
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		userID := entry.Name()
+		q, err := openTenantReplayQueue(filepath.Join(b.cfg.Dir, userID), b.logger)
+		if err != nil {
+			return errors.Wrapf(err, "opening replay buffer of tenant %s", userID)
+		}
+		if removed, err := q.removeIfEmpty(); err != nil {
+			return errors.Wrapf(err, "removing empty replay buffer of tenant %s", userID)
+		} else if removed {
+			continue
+		}
+
+		b.tenants[userID] = q
+		b.updateMetrics(userID, q)
+
+		bytes, requests := q.stats()
+		level.Info(b.logger).Log("msg", "found write requests queued in the replay buffer", "user", userID, "requests", requests, "bytes", bytes)
+	}
+
+	return nil
+}
+
+func (b *replayBuffer) running(ctx context.Context) error {
+	timer := time.NewTimer(b.cfg.ReplayInterval)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-timer.C:
+		}
+
+		// Keep replaying as long as progress is made, instead of waiting for the next interval,
+		// so that the queued requests are drained as fast as the rate limit allows once ingesters
+		// are available again.
+		replayed, rateLimitedFor := b.replayQueued(ctx)
+		switch {
+		case replayed > 0:
+			timer.Reset(0)
+		case rateLimitedFor > 0:
+			timer.Reset(rateLimitedFor)
+		default:
+			timer.Reset(b.cfg.ReplayInterval)
+		}
+	}
+}
+
+// replayQueued replays the write requests queued for all tenants. It returns the number of write requests
+// removed from the replay buffer and, if any tenant has been rate limited, the shortest time after which
+// a rate limited tenant can replay again.
+func (b *replayBuffer) replayQueued(ctx context.Context) (replayed int, rateLimitedFor time.Duration) {
+	for _, userID := range b.queuedUsers() {
+		if ctx.Err() != nil {
+			return
+		}
+		userReplayed, userRateLimitedFor := b.replayUser(ctx, userID)
+		replayed += userReplayed
+		if userRateLimitedFor > 0 && (rateLimitedFor == 0 || userRateLimitedFor < rateLimitedFor) {
+			rateLimitedFor = userRateLimitedFor
+		}
+	}
+	return
+}
+
+func (b *replayBuffer) stopping(_ error) error {
+	b.tenantsMtx.Lock()
+	defer b.tenantsMtx.Unlock()
+
+	b.stopped = true
+	for userID, q := range b.tenants {
+		if err := q.close(); err != nil {
+			level.Warn(b.logger).Log("msg", "failed to close replay buffer", "user", userID, "err", err)
+		}
+	}
+	return nil
+}
+
+// enabled returns whether write requests of the input tenant can be queued in the replay buffer.
+func (b *replayBuffer) enabled(userID string) bool {
+	return b.limits.ReplayBufferMaxBytesPerTenant(userID) > 0
+}
+
+// queued returns whether the input tenant has write requests waiting to be replayed.
+func (b *replayBuffer) queued(userID string) bool {
+	b.tenantsMtx.Lock()
+	defer b.tenantsMtx.Unlock()
+
+	_, ok := b.tenants[userID]
+	return ok
+}
+
+func (b *replayBuffer) queuedUsers() []string {
+	b.tenantsMtx.Lock()
+	defer b.tenantsMtx.Unlock()
+
+	users := make([]string, 0, len(b.tenants))
+	for userID := range b.tenants {
+		users = append(users, userID)
+	}
+	sort.Strings(users)
+	return users
+}
+
+// append queues the input write request at the end of the tenant replay buffer. It returns errReplayBufferFull
+// if queuing the request would exceed the tenant replay buffer size limit.
+func (b *replayBuffer) append(userID string, req *mimirpb.WriteRequest) error {
+	payload, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+
+	maxBytes := int64(b.limits.ReplayBufferMaxBytesPerTenant(userID))
+
+	for {
+		q, err := b.getOrCreateQueue(userID)
+		if err != nil {
+			return err
+		}
+
+		err = q.append(payload, maxBytes)
+		if errors.Is(err, errTenantReplayQueueClosed) {
+			// The queue has been drained and removed in the meanwhile, so we retry with a new one.
+			continue
+		}
+
+		b.removeIfEmpty(userID, q)
+		if errors.Is(err, errReplayBufferFull) {
+			b.rejectedRequests.WithLabelValues(userID).Inc()
+			return err
+		}
+		if err != nil {
+			return errors.Wrap(err, "appending write request to the replay buffer")
+		}
+
+		b.appendedRequests.WithLabelValues(userID).Inc()
+		b.updateMetrics(userID, q)
+		return nil
+	}
+}
+
+func (b *replayBuffer) getOrCreateQueue(userID string) (*tenantReplayQueue, error) {
+	b.tenantsMtx.Lock()
+	defer b.tenantsMtx.Unlock()
+
+	if b.stopped {
+		return nil, errReplayBufferStopped
+	}
+	if q, ok := b.tenants[userID]; ok {
+		return q, nil
+	}
+
+	q, err := openTenantReplayQueue(filepath.Join(b.cfg.Dir, userID), b.logger)
+	if err != nil {
+		return nil, errors.Wrap(err, "creating replay buffer")
+	}
+	b.tenants[userID] = q
+	return q, nil
+}
+
+// removeIfEmpty removes the input tenant queue if it has no more write requests waiting to be replayed.
+func (b *replayBuffer) removeIfEmpty(userID string, q *tenantReplayQueue) {
+	b.tenantsMtx.Lock()
+	defer b.tenantsMtx.Unlock()
+
+	if b.stopped || b.tenants[userID] != q {
+		return
+	}
+
+	removed, err := q.removeIfEmpty()
+	if err != nil {
+		level.Warn(b.logger).Log("msg", "failed to remove empty replay buffer", "user", userID, "err", err)
+	}
+	if removed {
+		delete(b.tenants, userID)
+		b.queuedBytes.DeleteLabelValues(userID)
+		b.queuedRequests.DeleteLabelValues(userID)
+	}
+}
+
+// replayUser replays the write requests queued for the input tenant, in order, until the queue is drained,
+// the tenant ingestion rate limit is reached or a request fails because ingesters are still unavailable.
+// It returns the number of write requests removed from the queue and, if the tenant has been rate limited,
+// the time after which the next request can be replayed.
+func (b *replayBuffer) replayUser(ctx context.Context, userID string) (removed int, rateLimitedFor time.Duration) {
+	b.tenantsMtx.Lock()
+	q, ok := b.tenants[userID]
+	b.tenantsMtx.Unlock()
+	if !ok {
+		return 0, 0
+	}
+
+	defer b.removeIfEmpty(userID, q)
+	defer b.updateMetrics(userID, q)
+
+	for ctx.Err() == nil {
+		payload, size, err := q.peek()
+		if errors.Is(err, io.EOF) {
+			return removed, 0
+		}
+		if errors.Is(err, errReplayBufferCorruptedRecord) {
+			level.Warn(b.logger).Log("msg", "discarding corrupted write request from the replay buffer", "user", userID, "err", err)
+			dropped, err := q.skipCorruptedRecord()
+			b.discardedRequests.WithLabelValues(userID, replayBufferDiscardReasonCorrupted).Add(float64(dropped))
+			removed += dropped
+			if err != nil {
+				level.Warn(b.logger).Log("msg", "failed to remove corrupted write request from the replay buffer", "user", userID, "err", err)
+				return removed, 0
+			}
+			continue
+		}
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "failed to read write request from the replay buffer", "user", userID, "err", err)
+			return removed, 0
+		}
+
+		var req mimirpb.WriteRequest
+		if err := req.Unmarshal(payload); err != nil {
+			// The record passed the checksum, so only this write request is discarded.
+			level.Warn(b.logger).Log("msg", "discarding corrupted write request from the replay buffer", "user", userID, "err", err)
+			if err := q.consume(size); err != nil {
+				level.Warn(b.logger).Log("msg", "failed to remove replayed replay buffer segment", "user", userID, "err", err)
+			}
+			b.discardedRequests.WithLabelValues(userID, replayBufferDiscardReasonCorrupted).Inc()
+			removed++
+			continue
+		}
+
+		// Respect the tenant ingestion rate limit. Requests larger than the burst are replayed once the whole burst is available.
+		now := time.Now()
+		n := min(writeRequestSize(&req), b.rateLimiter.Burst(now, userID))
+		if !b.rateLimiter.AllowN(now, userID, n) {
+			return removed, b.rateLimitedFor(now, userID, n)
+		}
+
+		err = b.replay(user.InjectOrgID(ctx, userID), userID, &req)
+		if err != nil && !isClientError(err) {
+			level.Debug(b.logger).Log("msg", "failed to replay write request from the replay buffer, will retry later", "user", userID, "err", err)
+			b.replayFailures.WithLabelValues(userID).Inc()
+			return removed, 0
+		}
+
+		if consumeErr := q.consume(size); consumeErr != nil {
+			level.Warn(b.logger).Log("msg", "failed to remove replayed replay buffer segment", "user", userID, "err", consumeErr)
+		}
+		removed++
+
+		if err != nil {
+			level.Warn(b.logger).Log("msg", "discarded write request from the replay buffer because rejected by ingesters", "user", userID, "err", err)
+			b.discardedRequests.WithLabelValues(userID, replayBufferDiscardReasonClientError).Inc()
+		} else {
+			b.replayedRequests.WithLabelValues(userID).Inc()
+		}
+		b.updateMetrics(userID, q)
+	}
+	return removed, 0
+}
+
+// rateLimitedFor returns how long it takes, at most, for the rate limiter to allow n more tokens for the input
+// tenant. The returned time is capped to the replay interval.
+func (b *replayBuffer) rateLimitedFor(now time.Time, userID string, n int) time.Duration {
+	seconds := float64(n) / b.rateLimiter.Limit(now, userID)
+	if seconds <= 0 || math.IsNaN(seconds) || seconds >= b.cfg.ReplayInterval.Seconds() {
+		return b.cfg.ReplayInterval
+	}
+	return max(time.Duration(seconds*float64(time.Second)), time.Millisecond)
+}
+
+func (b *replayBuffer) updateMetrics(userID string, q *tenantReplayQueue) {
+	bytes, requests := q.stats()
+	b.queuedBytes.WithLabelValues(userID).Set(float64(bytes))
+	b.queuedRequests.WithLabelValues(userID).Set(float64(requests))
+}
+
+// writeRequestSize returns the number of samples, exemplars and metadata in the input request,
+// which is what the ingestion rate limit is applied to.
+func writeRequestSize(req *mimirpb.WriteRequest) int {
+	n := len(req.Metadata)
+	for _, ts := range req.Timeseries {
+		n += len(ts.Samples) + len(ts.Histograms) + len(ts.Exemplars)
+	}
+	return n
+}
+
+// tenantReplayQueue is the on-disk queue of a single tenant. Records are appended to the last segment file of
+// the tenant directory, and read from the first one.
+type tenantReplayQueue struct {
+	mtx    sync.Mutex
+	dir    string
+	logger log.Logger
+	closed bool
+
+	// Segments in order. The last one is the head, where new records are appended.
+	segments []*replaySegment
+	head     *os.File
+
+	// Offset of the next record to replay in the first segment.
+	readOffset int64
+
+	bytes    int64
+	requests int
+}
+
+type replaySegment struct {
+	id int
+
+	// Number of bytes written to the segment.
+	size int64
+
+	// Number of bytes and records not replayed yet.
+	queuedBytes    int64
+	queuedRequests int
+}
+
+// openTenantReplayQueue opens the queue stored in the input directory, creating it if it doesn't exist.
+// Records which fail the checksum are kept as a single record until the next valid record, and discarded
+// when replayed. Trailing invalid bytes, like a partially written record after a crash, are removed.
+func openTenantReplayQueue(dir string, logger log.Logger) (*tenantReplayQueue, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &tenantReplayQueue{dir: dir, logger: logger}
+	for _, entry := range entries {
+		id, err := strconv.Atoi(entry.Name())
+		if err != nil || entry.IsDir() {
+			continue
+		}
+		q.segments = append(q.segments, &replaySegment{id: id})
+	}
+	sort.Slice(q.segments, func(i, j int) bool {
+		return q.segments[i].id < q.segments[j].id
+	})
+
+	for _, segment := range q.segments {
+		if err := q.recoverSegment(segment); err != nil {
+			return nil, err
+		}
+		q.bytes += segment.queuedBytes
+		q.requests += segment.queuedRequests
+	}
+
+	return q, nil
+}
+
+func (q *tenantReplayQueue) segmentPath(id int) string {
+	return filepath.Join(q.dir, fmt.Sprintf(replayBufferSegmentNameFormat, id))
+}
+
+func (q *tenantReplayQueue) recoverSegment(segment *replaySegment) error {
+	content, err := os.ReadFile(q.segmentPath(segment.id))
+	if err != nil {
+		return err
+	}
+
+	for segment.size < int64(len(content)) {
+		size := int64(len(content)) - segment.size
+		if _, recordSize, ok := decodeReplayBufferRecord(content[segment.size:]); ok {
+			size = recordSize
+		} else if next := nextValidReplayBufferRecord(content, segment.size+1); next < 0 {
+			level.Warn(q.logger).Log("msg", "truncating corrupted replay buffer segment", "segment", q.segmentPath(segment.id), "offset", segment.size)
+			return os.Truncate(q.segmentPath(segment.id), segment.size)
+		} else {
+			// The corrupted bytes are kept as a single record, which is discarded when replayed.
+			level.Warn(q.logger).Log("msg", "found corrupted record in replay buffer segment", "segment", q.segmentPath(segment.id), "offset", segment.size, "next_record_offset", next)
+			size = next - segment.size
+		}
+
+		segment.size += size
+		segment.queuedBytes += size
+		segment.queuedRequests++
+	}
+	return nil
+}
+
+func (q *tenantReplayQueue) append(payload []byte, maxBytes int64) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.closed {
+		return errTenantReplayQueueClosed
+	}
+
+	size := int64(replayBufferRecordHeaderSize + len(payload))
+	if q.bytes+size > maxBytes {
+		return errReplayBufferFull
+	}
+
+	if q.head == nil || q.segments[len(q.segments)-1].size >= replayBufferMaxSegmentSize {
+		if err := q.openHead(); err != nil {
+			return err
+		}
+	}
+
+	segment := q.segments[len(q.segments)-1]
+	record := encodeReplayBufferRecord(payload)
+	if _, err := q.head.Write(record); err != nil {
+		// Remove the partially written record, if any, so that following records can be read back.
+		_ = q.head.Truncate(segment.size)
+		return err
+	}
+	if err := q.head.Sync(); err != nil {
+		_ = q.head.Truncate(segment.size)
+		return err
+	}
+
+	segment.size += size
+	segment.queuedBytes += size
+	segment.queuedRequests++
+	q.bytes += size
+	q.requests++
+	return nil
+}
+
+// openHead opens the segment where new records are appended. The last existing segment is reused
+// if it's smaller than the max segment size, otherwise a new segment is created.
+func (q *tenantReplayQueue) openHead() error {
+	if q.head != nil {
+		if err := q.head.Close(); err != nil {
+			return err
+		}
+		q.head = nil
+	}
+
+	if len(q.segments) == 0 || q.segments[len(q.segments)-1].size >= replayBufferMaxSegmentSize {
+		id := 0
+		if len(q.segments) > 0 {
+			id = q.segments[len(q.segments)-1].id + 1
+		}
+		q.segments = append(q.segments, &replaySegment{id: id})
+		if len(q.segments) == 1 {
+			q.readOffset = 0
+		}
+	}
+
+	head, err := os.OpenFile(q.segmentPath(q.segments[len(q.segments)-1].id), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	q.head = head
+	return nil
+}
+
+// peek returns the payload and the size of the next record to replay, or io.EOF if the queue is empty.
+func (q *tenantReplayQueue) peek() ([]byte, int64, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.requests == 0 {
+		return nil, 0, io.EOF
+	}
+
+	// Skip the segments already replayed but not removed yet because they were the head.
+	for len(q.segments) > 1 && q.segments[0].queuedRequests == 0 {
+		if err := q.removeFirstSegment(); err != nil {
+			return nil, 0, err
+		}
+	}
+
+	file, err := os.Open(q.segmentPath(q.segments[0].id))
+	if err != nil {
+		return nil, 0, err
+	}
+	defer file.Close()
+
+	if _, err := file.Seek(q.readOffset, io.SeekStart); err != nil {
+		return nil, 0, err
+	}
+
+	payload, err := readReplayBufferRecord(bufio.NewReader(file), q.segments[0].size-q.readOffset)
+	if err != nil {
+		return nil, 0, errors.Wrap(errReplayBufferCorruptedRecord, err.Error())
+	}
+	return payload, int64(replayBufferRecordHeaderSize + len(payload)), nil
+}
+
+// consume removes the record of the input size at the beginning of the queue.
+func (q *tenantReplayQueue) consume(size int64) error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return q.consumeLocked(size)
+}
+
+func (q *tenantReplayQueue) consumeLocked(size int64) error {
+	segment := q.segments[0]
+	segment.queuedBytes -= size
+	segment.queuedRequests--
+	q.bytes -= size
+	q.requests--
+	q.readOffset += size
+
+	// Delete the first segment once fully replayed, unless it's the head.
+	if segment.queuedRequests == 0 && len(q.segments) > 1 {
+		return q.removeFirstSegment()
+	}
+	return nil
+}
+
+// skipCorruptedRecord removes the corrupted record at the beginning of the queue, and returns the number
+// of removed records. The following records are found again by looking for the next record passing the
+// checksum in the same segment. If there's none, all the remaining records of the segment are removed.
+func (q *tenantReplayQueue) skipCorruptedRecord() (int, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.segments[0].queuedRequests > 1 {
+		next, err := q.nextValidRecordOffset()
+		if err != nil {
+			return 0, err
+		}
+		if next > 0 {
+			return 1, q.consumeLocked(next)
+		}
+	}
+	return q.dropFirstSegmentLocked()
+}
+
+// nextValidRecordOffset returns the offset, relative to the read offset, of the first valid record following
+// the one at the read offset in the first segment, or -1 if there's none.
+func (q *tenantReplayQueue) nextValidRecordOffset() (int64, error) {
+	segment := q.segments[0]
+
+	file, err := os.Open(q.segmentPath(segment.id))
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	content := make([]byte, segment.size-q.readOffset)
+	if _, err := io.ReadFull(io.NewSectionReader(file, q.readOffset, int64(len(content))), content); err != nil {
+		return 0, err
+	}
+
+	// Records are usually corrupted in their payload, in which case the length in the header is still correct.
+	if len(content) >= replayBufferRecordHeaderSize {
+		end := replayBufferRecordHeaderSize + int64(binary.BigEndian.Uint32(content[0:4]))
+		if end < int64(len(content)) {
+			if _, _, ok := decodeReplayBufferRecord(content[end:]); ok {
+				return end, nil
+			}
+		}
+	}
+	return nextValidReplayBufferRecord(content, 1), nil
+}
+
+// dropFirstSegmentLocked removes all records of the first segment, regardless they have been replayed or not,
+// and returns the number of removed records.
+func (q *tenantReplayQueue) dropFirstSegmentLocked() (int, error) {
+	segment := q.segments[0]
+	dropped := segment.queuedRequests
+	q.bytes -= segment.queuedBytes
+	q.requests -= segment.queuedRequests
+	segment.queuedBytes = 0
+	segment.queuedRequests = 0
+
+	if len(q.segments) == 1 {
+		// The first segment is the head: close it, so that a new segment is created on next append.
+		if q.head != nil {
+			if err := q.head.Close(); err != nil {
+				return dropped, err
+			}
+			q.head = nil
+		}
+		segment.size = replayBufferMaxSegmentSize
+		q.readOffset = 0
+		return dropped, nil
+	}
+	return dropped, q.removeFirstSegment()
+}
+
+func (q *tenantReplayQueue) removeFirstSegment() error {
+	if err := os.Remove(q.segmentPath(q.segments[0].id)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	q.segments = q.segments[1:]
+	q.readOffset = 0
+	return nil
+}
+
+func (q *tenantReplayQueue) stats() (bytes int64, requests int) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	return q.bytes, q.requests
+}
+
+// removeIfEmpty closes the queue and deletes its directory if it has no more records to replay.
+func (q *tenantReplayQueue) removeIfEmpty() (bool, error) {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	if q.requests > 0 {
+		return false, nil
+	}
+
+	q.closed = true
+	if q.head != nil {
+		_ = q.head.Close()
+		q.head = nil
+	}
+	return true, os.RemoveAll(q.dir)
+}
+
+func (q *tenantReplayQueue) close() error {
+	q.mtx.Lock()
+	defer q.mtx.Unlock()
+
+	q.closed = true
+	if q.head == nil {
+		return nil
+	}
+	err := q.head.Close()
+	q.head = nil
+	return err
+}
+
+func encodeReplayBufferRecord(payload []byte) []byte {
+	record := make([]byte, replayBufferRecordHeaderSize+len(payload))
+	binary.BigEndian.PutUint32(record[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(record[4:8], crc32.Checksum(payload, replayBufferCastagnoliTable))
+	copy(record[replayBufferRecordHeaderSize:], payload)
+	return record
+}
+
+// readReplayBufferRecord reads the payload of the next record from the input reader, whose record can't be
+// larger than maxSize. It returns io.EOF if there are no more records, and an error if the record is truncated
+// or corrupted.
+func readReplayBufferRecord(r io.Reader, maxSize int64) ([]byte, error) {
+	header := make([]byte, replayBufferRecordHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil {
+		if errors.Is(err, io.EOF) {
+			return nil, io.EOF
+		}
+		return nil, errors.Wrap(err, "reading record header")
+	}
+
+	length := binary.BigEndian.Uint32(header[0:4])
+	if int64(length) > maxSize-replayBufferRecordHeaderSize {
+		return nil, fmt.Errorf("invalid record length %d", length)
+	}
+
+	payload := make([]byte, length)
+	if _, err := io.ReadFull(r, payload); err != nil {
+		return nil, errors.Wrap(err, "reading record payload")
+	}
+	if crc32.Checksum(payload, replayBufferCastagnoliTable) != binary.BigEndian.Uint32(header[4:8]) {
+		return nil, errors.New("record checksum mismatch")
+	}
+	return payload, nil
+}
+
+// decodeReplayBufferRecord decodes the record at the beginning of the input buffer, and returns its payload
+// and its size. It returns false if the buffer doesn't begin with a complete record passing the checksum.
+func decodeReplayBufferRecord(buf []byte) ([]byte, int64, bool) {
+	if len(buf) < replayBufferRecordHeaderSize {
+		return nil, 0, false
+	}
+
+	size := replayBufferRecordHeaderSize + int64(binary.BigEndian.Uint32(buf[0:4]))
+	if size > int64(len(buf)) {
+		return nil, 0, false
+	}
+
+	payload := buf[replayBufferRecordHeaderSize:size]
+	if crc32.Checksum(payload, replayBufferCastagnoliTable) != binary.BigEndian.Uint32(buf[4:8]) {
+		return nil, 0, false
+	}
+	return payload, size, true
+}
+
+// nextValidReplayBufferRecord returns the offset of the first non-empty record passing the checksum in the input
+// buffer, starting the search from the input offset, or -1 if there's none. Empty records are skipped because
+// a sequence of zero bytes would be decoded as valid empty records.
+func nextValidReplayBufferRecord(buf []byte, from int64) int64 {
+	for offset := from; offset+replayBufferRecordHeaderSize < int64(len(buf)); offset++ {
+		if _, size, ok := decodeReplayBufferRecord(buf[offset:]); ok && size > replayBufferRecordHeaderSize {
+			return offset
+		}
+	}
+	return -1
+}
+
+// replayBufferedRequest sends a write request queued in the replay buffer to ingesters.
+func (d *Distributor) replayBufferedRequest(ctx context.Context, userID string, req *mimirpb.WriteRequest) error {
+	return d.send(ctx, userID, req, func() {})
+}
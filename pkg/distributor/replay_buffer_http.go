@@ -0,0 +1,68 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	_ "embed" // Used to embed html template
+	"html/template"
+	"net/http"
+	"time"
+
+	"github.com/grafana/mimir/pkg/util"
+)
+
+//go:embed replay_buffer_status.gohtml
+var replayBufferStatusPageHTML string
+var replayBufferStatusPageTemplate = template.Must(template.New("replay-buffer").Parse(replayBufferStatusPageHTML))
+
+type replayBufferStatusPageContents struct {
+	Enabled bool                 `json:"enabled"`
+	Now     time.Time            `json:"now"`
+	Tenants []replayBufferTenant `json:"tenants"`
+}
+
+type replayBufferTenant struct {
+	UserID         string `json:"userID"`
+	QueuedBytes    int64  `json:"queuedBytes"`
+	QueuedRequests int    `json:"queuedRequests"`
+	MaxBytes       int    `json:"maxBytes"`
+}
+
+// ReplayBufferHandler shows the write requests queued in the replay buffer for each tenant.
+func (d *Distributor) ReplayBufferHandler(w http.ResponseWriter, r *http.Request) {
+	contents := replayBufferStatusPageContents{
+		Enabled: d.replayBuffer != nil,
+		Now:     time.Now(),
+	}
+
+	if d.replayBuffer != nil {
+		contents.Tenants = d.replayBuffer.status()
+	}
+
+	util.RenderHTTPResponse(w, contents, replayBufferStatusPageTemplate, r)
+}
+
+// status returns the write requests queued for each tenant, sorted by tenant ID.
+func (b *replayBuffer) status() []replayBufferTenant {
+	users := b.queuedUsers()
+	tenants := make([]replayBufferTenant, 0, len(users))
+
+	b.tenantsMtx.Lock()
+	defer b.tenantsMtx.Unlock()
+
+	for _, userID := range users {
+		q, ok := b.tenants[userID]
+		if !ok {
+			continue
+		}
+
+		bytes, requests := q.stats()
+		tenants = append(tenants, replayBufferTenant{
+			UserID:         userID,
+			QueuedBytes:    bytes,
+			QueuedRequests: requests,
+			MaxBytes:       b.limits.ReplayBufferMaxBytesPerTenant(userID),
+		})
+	}
+	return tenants
+}
@@ -0,0 +1,593 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package distributor
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/httpgrpc"
+	"github.com/grafana/dskit/limiter"
+	"github.com/grafana/dskit/services"
+	"github.com/grafana/dskit/user"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestReplayBufferConfig_Validate(t *testing.T) {
+	tests := map[string]struct {
+		cfg         ReplayBufferConfig
+		expectedErr error
+	}{
+		"should pass when disabled": {
+			cfg: ReplayBufferConfig{},
+		},
+		"should pass with valid config": {
+			cfg: ReplayBufferConfig{Enabled: true, Dir: "./replay-buffer", ReplayInterval: time.Second, ReplayRateMultiplier: 2},
+		},
+		"should fail without directory": {
+			cfg:         ReplayBufferConfig{Enabled: true, ReplayInterval: time.Second, ReplayRateMultiplier: 2},
+			expectedErr: errReplayBufferDirMissing,
+		},
+		"should fail with invalid replay interval": {
+			cfg:         ReplayBufferConfig{Enabled: true, Dir: "./replay-buffer", ReplayRateMultiplier: 2},
+			expectedErr: errReplayBufferInvalidInterval,
+		},
+		"should fail with invalid replay rate multiplier": {
+			cfg:         ReplayBufferConfig{Enabled: true, Dir: "./replay-buffer", ReplayInterval: time.Second},
+			expectedErr: errReplayBufferInvalidMultiplier,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.expectedErr, testData.cfg.Validate())
+		})
+	}
+}
+
+func TestReplayBuffer_AppendAndReplay(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		replayer = &replayBufferTestReplayer{err: errFail}
+		reg      = prometheus.NewPedanticRegistry()
+		b, dir   = prepareReplayBuffer(t, 1024*1024, replayer, reg)
+	)
+
+	assert.False(t, b.queued(userID))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.append(userID, makeWriteRequest(int64(i), 1, 0, false, false)))
+	}
+	assert.True(t, b.queued(userID))
+	assert.DirExists(t, filepath.Join(dir, userID))
+
+	// Ingesters are still unavailable: requests are kept in the replay buffer.
+	replayed, rateLimitedFor := b.replayQueued(context.Background())
+	assert.Equal(t, 0, replayed)
+	assert.Zero(t, rateLimitedFor)
+	assert.Len(t, replayer.replayed(), 1)
+	assert.True(t, b.queued(userID))
+
+	status := b.status()
+	require.Len(t, status, 1)
+	assert.Equal(t, userID, status[0].UserID)
+	assert.Equal(t, 3, status[0].QueuedRequests)
+	assert.Equal(t, 1024*1024, status[0].MaxBytes)
+
+	// Ingesters are available again: all requests are replayed in order and the replay buffer is removed.
+	replayer.setErr(nil)
+	replayed, rateLimitedFor = b.replayQueued(context.Background())
+	assert.Equal(t, 3, replayed)
+	assert.Zero(t, rateLimitedFor)
+
+	requests := replayer.replayed()
+	require.Len(t, requests, 4)
+	for i, req := range requests[1:] {
+		assert.Equal(t, int64(i), req.Timeseries[0].Samples[0].TimestampMs)
+	}
+	assert.False(t, b.queued(userID))
+	assert.NoDirExists(t, filepath.Join(dir, userID))
+	assert.Empty(t, b.status())
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_distributor_replay_buffer_appended_requests_total Total number of write requests appended to the replay buffer.
+		# TYPE cortex_distributor_replay_buffer_appended_requests_total counter
+		cortex_distributor_replay_buffer_appended_requests_total{user="user-1"} 3
+
+		# HELP cortex_distributor_replay_buffer_replayed_requests_total Total number of write requests successfully replayed from the replay buffer to ingesters.
+		# TYPE cortex_distributor_replay_buffer_replayed_requests_total counter
+		cortex_distributor_replay_buffer_replayed_requests_total{user="user-1"} 3
+
+		# HELP cortex_distributor_replay_buffer_replay_failures_total Total number of failed attempts to replay a write request from the replay buffer. The write request is kept in the replay buffer and retried later.
+		# TYPE cortex_distributor_replay_buffer_replay_failures_total counter
+		cortex_distributor_replay_buffer_replay_failures_total{user="user-1"} 1
+	`),
+		"cortex_distributor_replay_buffer_queued_bytes",
+		"cortex_distributor_replay_buffer_queued_requests",
+		"cortex_distributor_replay_buffer_appended_requests_total",
+		"cortex_distributor_replay_buffer_replayed_requests_total",
+		"cortex_distributor_replay_buffer_replay_failures_total",
+	))
+}
+
+func TestReplayBuffer_ShouldRejectRequestsOverTheTenantLimit(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		req     = makeWriteRequest(0, 10, 0, false, false)
+		size    = replayBufferRecordHeaderSize + req.Size()
+		reg     = prometheus.NewPedanticRegistry()
+		b, _    = prepareReplayBuffer(t, 2*size, &replayBufferTestReplayer{}, reg)
+		metrics = []string{"cortex_distributor_replay_buffer_queued_bytes", "cortex_distributor_replay_buffer_rejected_requests_total"}
+	)
+
+	require.NoError(t, b.append(userID, req))
+	require.NoError(t, b.append(userID, req))
+	require.ErrorIs(t, b.append(userID, req), errReplayBufferFull)
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_distributor_replay_buffer_queued_bytes Size in bytes of the write requests queued in the replay buffer.
+		# TYPE cortex_distributor_replay_buffer_queued_bytes gauge
+		cortex_distributor_replay_buffer_queued_bytes{user="user-1"} `+strconv.Itoa(2*size)+`
+
+		# HELP cortex_distributor_replay_buffer_rejected_requests_total Total number of write requests not appended to the replay buffer because the tenant replay buffer is full.
+		# TYPE cortex_distributor_replay_buffer_rejected_requests_total counter
+		cortex_distributor_replay_buffer_rejected_requests_total{user="user-1"} 1
+	`), metrics...))
+
+	// An empty tenant replay buffer is not kept when the request doesn't fit in it.
+	require.ErrorIs(t, b.append("user-2", makeWriteRequest(0, 100, 0, false, false)), errReplayBufferFull)
+	assert.False(t, b.queued("user-2"))
+}
+
+func TestReplayBuffer_ShouldDiscardRequestsRejectedByIngesters(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		replayer = &replayBufferTestReplayer{err: httpgrpc.Errorf(400, "out of order sample")}
+		reg      = prometheus.NewPedanticRegistry()
+		b, _     = prepareReplayBuffer(t, 1024*1024, replayer, reg)
+	)
+
+	require.NoError(t, b.append(userID, makeWriteRequest(0, 1, 0, false, false)))
+	require.NoError(t, b.append(userID, makeWriteRequest(1, 1, 0, false, false)))
+	b.replayQueued(context.Background())
+
+	assert.Len(t, replayer.replayed(), 2)
+	assert.False(t, b.queued(userID))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_distributor_replay_buffer_discarded_requests_total Total number of write requests removed from the replay buffer without being ingested.
+		# TYPE cortex_distributor_replay_buffer_discarded_requests_total counter
+		cortex_distributor_replay_buffer_discarded_requests_total{reason="client_error",user="user-1"} 2
+	`), "cortex_distributor_replay_buffer_discarded_requests_total"))
+}
+
+func TestReplayBuffer_ShouldRespectTheIngestionRateLimit(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		replayer = &replayBufferTestReplayer{}
+		b, _     = prepareReplayBuffer(t, 1024*1024, replayer, nil)
+	)
+
+	b.rateLimiter = limiter.NewRateLimiter(&replayBufferTestRateStrategy{limit: 0.0001, burst: 15}, time.Minute)
+
+	// Each request is accounted as 10 samples, so only the first one is replayed.
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.append(userID, makeWriteRequest(int64(i), 10, 0, false, false)))
+	}
+	replayed, rateLimitedFor := b.replayQueued(context.Background())
+	assert.Equal(t, 1, replayed)
+	assert.Equal(t, time.Hour, rateLimitedFor, "the time to wait is capped to the replay interval")
+	assert.Len(t, replayer.replayed(), 1)
+	assert.Equal(t, 2, b.status()[0].QueuedRequests)
+
+	// Requests larger than the burst are replayed once the whole burst is available.
+	b.rateLimiter = limiter.NewRateLimiter(&replayBufferTestRateStrategy{limit: 0.0001, burst: 5}, time.Minute)
+	b.replayQueued(context.Background())
+	assert.Len(t, replayer.replayed(), 2)
+	assert.Equal(t, 1, b.status()[0].QueuedRequests)
+}
+
+func TestReplayBuffer_ShouldRecoverQueuedRequestsOnRestart(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		replayer = &replayBufferTestReplayer{err: errFail}
+		dir      = t.TempDir()
+		limits   = replayBufferTestLimits(1024 * 1024)
+	)
+
+	b := newReplayBuffer(ReplayBufferConfig{Enabled: true, Dir: dir, ReplayInterval: time.Hour, ReplayRateMultiplier: 1}, limits, replayer.replay, limiter.NewRateLimiter(newInfiniteRateStrategy(), time.Minute), nil, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), b))
+
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.append(userID, makeWriteRequest(int64(i), 1, 0, false, false)))
+	}
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), b))
+	require.ErrorIs(t, b.append(userID, makeWriteRequest(0, 1, 0, false, false)), errReplayBufferStopped)
+
+	// Simulate a partially written record, like after a crash.
+	segment := filepath.Join(dir, userID, "00000000")
+	file, err := os.OpenFile(segment, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = file.Write(encodeReplayBufferRecord([]byte("partial"))[:10])
+	require.NoError(t, err)
+	require.NoError(t, file.Close())
+
+	// Create an empty tenant directory, which is expected to be removed on startup.
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "user-2"), 0o750))
+
+	replayer.setErr(nil)
+	b = newReplayBuffer(ReplayBufferConfig{Enabled: true, Dir: dir, ReplayInterval: time.Hour, ReplayRateMultiplier: 1}, limits, replayer.replay, limiter.NewRateLimiter(newInfiniteRateStrategy(), time.Minute), nil, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), b))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), b))
+	})
+
+	assert.True(t, b.queued(userID))
+	assert.False(t, b.queued("user-2"))
+	assert.NoDirExists(t, filepath.Join(dir, "user-2"))
+
+	// Appending after the recovered requests must keep them readable.
+	require.NoError(t, b.append(userID, makeWriteRequest(3, 1, 0, false, false)))
+
+	b.replayQueued(context.Background())
+
+	replayed := replayer.replayed()
+	require.Len(t, replayed, 4)
+	for i, req := range replayed {
+		assert.Equal(t, int64(i), req.Timeseries[0].Samples[0].TimestampMs)
+	}
+	assert.False(t, b.queued(userID))
+}
+
+func TestReplayBuffer_ShouldDiscardCorruptedRecords(t *testing.T) {
+	const userID = "user-1"
+
+	tests := map[string]struct {
+		corrupt func(data []byte)
+	}{
+		"corrupted payload": {
+			corrupt: func(data []byte) {
+				data[replayBufferRecordHeaderSize] ^= 0xff
+			},
+		},
+		"corrupted length": {
+			corrupt: func(data []byte) {
+				data[2] ^= 0xff
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				replayer = &replayBufferTestReplayer{}
+				reg      = prometheus.NewPedanticRegistry()
+				b, dir   = prepareReplayBuffer(t, 1024*1024, replayer, reg)
+			)
+
+			for i := 0; i < 3; i++ {
+				require.NoError(t, b.append(userID, makeWriteRequest(int64(i), 1, 0, false, false)))
+			}
+
+			// Corrupt the first record.
+			segment := filepath.Join(dir, userID, "00000000")
+			data, err := os.ReadFile(segment)
+			require.NoError(t, err)
+			testData.corrupt(data)
+			require.NoError(t, os.WriteFile(segment, data, 0o640))
+
+			// Only the corrupted record is discarded, and the following ones are replayed.
+			replayed, _ := b.replayQueued(context.Background())
+			assert.Equal(t, 3, replayed)
+			require.Len(t, replayer.replayed(), 2)
+			for i, req := range replayer.replayed() {
+				assert.Equal(t, int64(i+1), req.Timeseries[0].Samples[0].TimestampMs)
+			}
+			assert.False(t, b.queued(userID))
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_distributor_replay_buffer_discarded_requests_total Total number of write requests removed from the replay buffer without being ingested.
+				# TYPE cortex_distributor_replay_buffer_discarded_requests_total counter
+				cortex_distributor_replay_buffer_discarded_requests_total{reason="corrupted",user="user-1"} 1
+			`), "cortex_distributor_replay_buffer_discarded_requests_total"))
+
+			// The replay buffer keeps working after corrupted records have been discarded.
+			require.NoError(t, b.append(userID, makeWriteRequest(3, 1, 0, false, false)))
+			b.replayQueued(context.Background())
+			require.Len(t, replayer.replayed(), 3)
+			assert.Equal(t, int64(3), replayer.replayed()[2].Timeseries[0].Samples[0].TimestampMs)
+		})
+	}
+}
+
+func TestReplayBuffer_ShouldDiscardTheWholeSegmentWhenNoValidRecordFollowsTheCorruptedOne(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		replayer = &replayBufferTestReplayer{}
+		reg      = prometheus.NewPedanticRegistry()
+		b, dir   = prepareReplayBuffer(t, 1024*1024, replayer, reg)
+	)
+
+	for i := 0; i < 2; i++ {
+		require.NoError(t, b.append(userID, makeWriteRequest(int64(i), 1, 0, false, false)))
+	}
+
+	// Corrupt both records.
+	segment := filepath.Join(dir, userID, "00000000")
+	data, err := os.ReadFile(segment)
+	require.NoError(t, err)
+	for i := range data {
+		data[i] ^= 0xff
+	}
+	require.NoError(t, os.WriteFile(segment, data, 0o640))
+
+	b.replayQueued(context.Background())
+	assert.Empty(t, replayer.replayed())
+	assert.False(t, b.queued(userID))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_distributor_replay_buffer_discarded_requests_total Total number of write requests removed from the replay buffer without being ingested.
+		# TYPE cortex_distributor_replay_buffer_discarded_requests_total counter
+		cortex_distributor_replay_buffer_discarded_requests_total{reason="corrupted",user="user-1"} 2
+	`), "cortex_distributor_replay_buffer_discarded_requests_total"))
+}
+
+func TestReplayBuffer_ShouldSkipCorruptedRecordsOnRestart(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		replayer = &replayBufferTestReplayer{err: errFail}
+		dir      = t.TempDir()
+		limits   = replayBufferTestLimits(1024 * 1024)
+		cfg      = ReplayBufferConfig{Enabled: true, Dir: dir, ReplayInterval: time.Hour, ReplayRateMultiplier: 1}
+	)
+
+	b := newReplayBuffer(cfg, limits, replayer.replay, limiter.NewRateLimiter(newInfiniteRateStrategy(), time.Minute), nil, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), b))
+	for i := 0; i < 3; i++ {
+		require.NoError(t, b.append(userID, makeWriteRequest(int64(i), 1, 0, false, false)))
+	}
+	require.NoError(t, services.StopAndAwaitTerminated(context.Background(), b))
+
+	// Corrupt the length of the second record, so that its end can't be found.
+	segment := filepath.Join(dir, userID, "00000000")
+	data, err := os.ReadFile(segment)
+	require.NoError(t, err)
+	second := replayBufferRecordHeaderSize + int(binary.BigEndian.Uint32(data[0:4]))
+	data[second+1] ^= 0xff
+	require.NoError(t, os.WriteFile(segment, data, 0o640))
+
+	reg := prometheus.NewPedanticRegistry()
+	replayer.setErr(nil)
+	b = newReplayBuffer(cfg, limits, replayer.replay, limiter.NewRateLimiter(newInfiniteRateStrategy(), time.Minute), reg, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), b))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), b))
+	})
+
+	// The records following the corrupted one are recovered.
+	assert.Equal(t, 3, b.status()[0].QueuedRequests)
+
+	b.replayQueued(context.Background())
+	require.Len(t, replayer.replayed(), 2)
+	assert.Equal(t, int64(0), replayer.replayed()[0].Timeseries[0].Samples[0].TimestampMs)
+	assert.Equal(t, int64(2), replayer.replayed()[1].Timeseries[0].Samples[0].TimestampMs)
+	assert.False(t, b.queued(userID))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_distributor_replay_buffer_discarded_requests_total Total number of write requests removed from the replay buffer without being ingested.
+		# TYPE cortex_distributor_replay_buffer_discarded_requests_total counter
+		cortex_distributor_replay_buffer_discarded_requests_total{reason="corrupted",user="user-1"} 1
+	`), "cortex_distributor_replay_buffer_discarded_requests_total"))
+}
+
+func TestReplayBuffer_ShouldReplayContinuouslyOnceIngestersAreAvailable(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		replayer = &replayBufferTestReplayer{}
+		cfg      = ReplayBufferConfig{Enabled: true, Dir: t.TempDir(), ReplayInterval: 200 * time.Millisecond, ReplayRateMultiplier: 1}
+	)
+
+	// The rate limit allows to replay one request at a time.
+	rateLimiter := limiter.NewRateLimiter(&replayBufferTestRateStrategy{limit: 50, burst: 1}, time.Minute)
+	b := newReplayBuffer(cfg, replayBufferTestLimits(1024*1024), replayer.replay, rateLimiter, nil, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), b))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), b))
+	})
+
+	for i := 0; i < 5; i++ {
+		require.NoError(t, b.append(userID, makeWriteRequest(int64(i), 1, 0, false, false)))
+	}
+
+	// Requests are replayed as fast as the rate limit allows, instead of once per replay interval,
+	// which would take 5 replay intervals.
+	require.Eventually(t, func() bool {
+		return !b.queued(userID)
+	}, 700*time.Millisecond, 10*time.Millisecond)
+	assert.Len(t, replayer.replayed(), 5)
+}
+
+func TestDistributor_PushWithReplayBuffer(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.ReplayBufferMaxBytesPerTenant = 1024 * 1024
+
+	ds, ingesters, _ := prepare(t, prepConfig{
+		numIngesters:    3,
+		happyIngesters:  1,
+		numDistributors: 1,
+		limits:          limits,
+		configure: func(cfg *Config) {
+			cfg.ReplayBufferConfig = ReplayBufferConfig{Enabled: true, Dir: t.TempDir(), ReplayInterval: time.Hour, ReplayRateMultiplier: 1}
+		},
+	})
+	d := ds[0]
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	// The quorum can't be reached, so requests are queued in the replay buffer.
+	_, err := d.Push(ctx, makeWriteRequest(0, 5, 0, false, false, "foo"))
+	require.NoError(t, err)
+	assert.True(t, d.replayBuffer.queued("user"))
+
+	// While requests are queued, new requests are queued after them even if the quorum is reached.
+	setMockIngestersHappy(ingesters, true)
+	_, err = d.Push(ctx, makeWriteRequest(10, 5, 0, false, false, "bar"))
+	require.NoError(t, err)
+	assert.Equal(t, 2, d.replayBuffer.status()[0].QueuedRequests)
+
+	d.replayBuffer.replayQueued(context.Background())
+	assert.False(t, d.replayBuffer.queued("user"))
+
+	// The push to the last ingester could complete after the quorum has been reached.
+	for i := range ingesters {
+		require.Eventually(t, func() bool {
+			return len(ingesters[i].series()) == 10
+		}, time.Second, 10*time.Millisecond)
+	}
+}
+
+func TestDistributor_PushWithReplayBuffer_ShouldReturnErrorWhenFull(t *testing.T) {
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.ReplayBufferMaxBytesPerTenant = 10
+
+	ds, _, _ := prepare(t, prepConfig{
+		numIngesters:    3,
+		happyIngesters:  1,
+		numDistributors: 1,
+		limits:          limits,
+		configure: func(cfg *Config) {
+			cfg.ReplayBufferConfig = ReplayBufferConfig{Enabled: true, Dir: t.TempDir(), ReplayInterval: time.Hour, ReplayRateMultiplier: 1}
+		},
+	})
+
+	_, err := ds[0].Push(user.InjectOrgID(context.Background(), "user"), makeWriteRequest(0, 5, 0, false, false))
+	require.Error(t, err)
+	assert.False(t, ds[0].replayBuffer.queued("user"))
+}
+
+func TestDistributor_PushWithReplayBuffer_ShouldReturnErrorWhenQueuedAndFull(t *testing.T) {
+	first := makeWriteRequest(0, 5, 0, false, false, "foo")
+
+	limits := &validation.Limits{}
+	flagext.DefaultValues(limits)
+	limits.ReplayBufferMaxBytesPerTenant = replayBufferRecordHeaderSize + first.Size()
+
+	ds, ingesters, _ := prepare(t, prepConfig{
+		numIngesters:    3,
+		happyIngesters:  1,
+		numDistributors: 1,
+		limits:          limits,
+		configure: func(cfg *Config) {
+			cfg.ReplayBufferConfig = ReplayBufferConfig{Enabled: true, Dir: t.TempDir(), ReplayInterval: time.Hour, ReplayRateMultiplier: 1}
+		},
+	})
+	d := ds[0]
+	ctx := user.InjectOrgID(context.Background(), "user")
+
+	_, err := d.Push(ctx, first)
+	require.NoError(t, err)
+	require.True(t, d.replayBuffer.queued("user"))
+
+	// The request can't be queued after the previous one, and it's not sent to ingesters
+	// even if the quorum could be reached, to preserve the order of the writes.
+	setMockIngestersHappy(ingesters, true)
+	_, err = d.Push(ctx, makeWriteRequest(10, 5, 0, false, false, "bar"))
+	require.ErrorContains(t, err, errReplayBufferFull.Error())
+	assert.Equal(t, 1, d.replayBuffer.status()[0].QueuedRequests)
+
+	for i := range ingesters {
+		assert.LessOrEqual(t, len(ingesters[i].series()), 5)
+	}
+}
+
+func prepareReplayBuffer(t *testing.T, maxBytes int, replayer *replayBufferTestReplayer, reg prometheus.Registerer) (*replayBuffer, string) {
+	dir := t.TempDir()
+	cfg := ReplayBufferConfig{Enabled: true, Dir: dir, ReplayInterval: time.Hour, ReplayRateMultiplier: 1}
+
+	b := newReplayBuffer(cfg, replayBufferTestLimits(maxBytes), replayer.replay, limiter.NewRateLimiter(newInfiniteRateStrategy(), time.Minute), reg, log.NewNopLogger())
+	require.NoError(t, services.StartAndAwaitRunning(context.Background(), b))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(context.Background(), b))
+	})
+
+	return b, dir
+}
+
+func setMockIngestersHappy(ingesters []mockIngester, happy bool) {
+	for i := range ingesters {
+		ingesters[i].Lock()
+		ingesters[i].happy = happy
+		ingesters[i].Unlock()
+	}
+}
+
+type replayBufferTestLimits int
+
+func (l replayBufferTestLimits) ReplayBufferMaxBytesPerTenant(string) int {
+	return int(l)
+}
+
+type replayBufferTestRateStrategy struct {
+	limit float64
+	burst int
+}
+
+func (s *replayBufferTestRateStrategy) Limit(string) float64 { return s.limit }
+func (s *replayBufferTestRateStrategy) Burst(string) int     { return s.burst }
+
+type replayBufferTestReplayer struct {
+	mtx      sync.Mutex
+	err      error
+	requests []*mimirpb.WriteRequest
+}
+
+func (r *replayBufferTestReplayer) replay(ctx context.Context, userID string, req *mimirpb.WriteRequest) error {
+	if orgID, err := user.ExtractOrgID(ctx); err != nil || orgID != userID {
+		return errors.New("unexpected tenant in the context")
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.requests = append(r.requests, req)
+	return r.err
+}
+
+func (r *replayBufferTestReplayer) setErr(err error) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.err = err
+}
+
+func (r *replayBufferTestReplayer) replayed() []*mimirpb.WriteRequest {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	return append([]*mimirpb.WriteRequest(nil), r.requests...)
+}
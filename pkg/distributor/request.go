@@ -22,6 +22,10 @@ type Request struct {
 
 	request *mimirpb.WriteRequest
 	err     error
+
+	// written holds the number of samples, histograms and exemplars written to the storage,
+	// once the request has been deduplicated, relabeled, validated and successfully pushed.
+	written writtenStats
 }
 
 func newRequest(p supplierFunc) *Request {
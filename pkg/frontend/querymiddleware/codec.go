@@ -406,6 +406,14 @@ func (c prometheusCodec) EncodeRequest(ctx context.Context, r Request) (*http.Re
 		req.Header.Add(api.ReadConsistencyHeader, consistency)
 	}
 
+	if api.IsDeprioritizedQuery(ctx) {
+		req.Header.Set(api.DeprioritizedQueryHeader, "true")
+	}
+
+	if class, ok := api.QueryPriorityClassFromContext(ctx); ok {
+		req.Header.Set(api.QueryPriorityClassHeader, class)
+	}
+
 	return req.WithContext(ctx), nil
 }
 
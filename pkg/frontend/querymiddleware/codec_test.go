@@ -141,6 +141,34 @@ func TestPrometheusCodec_EncodeRequest_ReadConsistency(t *testing.T) {
 	}
 }
 
+func TestPrometheusCodec_EncodeRequest_DeprioritizedQuery(t *testing.T) {
+	codec := NewPrometheusCodec(prometheus.NewPedanticRegistry(), formatProtobuf)
+
+	encodedRequest, err := codec.EncodeRequest(context.Background(), &PrometheusInstantQueryRequest{})
+	require.NoError(t, err)
+	require.Empty(t, encodedRequest.Header.Get(api.DeprioritizedQueryHeader))
+
+	encodedRequest, err = codec.EncodeRequest(api.ContextWithDeprioritizedQuery(context.Background()), &PrometheusInstantQueryRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "true", encodedRequest.Header.Get(api.DeprioritizedQueryHeader))
+}
+
+func TestPrometheusCodec_EncodeRequest_QueryPriorityClass(t *testing.T) {
+	codec := NewPrometheusCodec(prometheus.NewPedanticRegistry(), formatProtobuf)
+
+	encodedRequest, err := codec.EncodeRequest(context.Background(), &PrometheusInstantQueryRequest{})
+	require.NoError(t, err)
+	require.Empty(t, encodedRequest.Header.Get(api.QueryPriorityClassHeader))
+
+	encodedRequest, err = codec.EncodeRequest(api.ContextWithQueryPriorityClass(context.Background(), "unknown"), &PrometheusInstantQueryRequest{})
+	require.NoError(t, err)
+	require.Empty(t, encodedRequest.Header.Get(api.QueryPriorityClassHeader))
+
+	encodedRequest, err = codec.EncodeRequest(api.ContextWithQueryPriorityClass(context.Background(), api.QueryPriorityClassAlerting), &PrometheusInstantQueryRequest{})
+	require.NoError(t, err)
+	require.Equal(t, api.QueryPriorityClassAlerting, encodedRequest.Header.Get(api.QueryPriorityClassHeader))
+}
+
 func TestPrometheusCodec_EncodeResponse_ContentNegotiation(t *testing.T) {
 	testResponse := &PrometheusResponse{
 		Status:    statusError,
@@ -25,6 +25,13 @@ func newMaxQueryExpressionSizeBytesError(actualSizeBytes, maxQuerySizeBytes int)
 	))
 }
 
+func newMaxQueryCostError(estimatedCost uint64, maxQueryCost int) error {
+	return apierror.New(apierror.TypeBadData, globalerror.MaxQueryCost.MessageWithPerTenantLimitConfig(
+		fmt.Sprintf("the estimated query cost exceeds the limit (estimated cost: %d, limit: %d)", estimatedCost, maxQueryCost),
+		validation.MaxQueryCostFlag,
+	))
+}
+
 func newQueryBlockedError() error {
 	return apierror.New(apierror.TypeBadData, globalerror.QueryBlocked.Message("the request has been blocked by the cluster administrator"))
 }
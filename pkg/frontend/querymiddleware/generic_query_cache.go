@@ -197,5 +197,15 @@ func generateGenericQueryRequestCacheKey(tenantIDs []string, req *GenericQueryCa
 }
 
 func isGenericQueryResponseCacheable(res *http.Response) bool {
-	return res.StatusCode >= 200 && res.StatusCode < 300
+	if res.StatusCode < 200 || res.StatusCode >= 300 {
+		return false
+	}
+
+	// Honor the downstream asking to not store the response.
+	for _, v := range res.Header.Values(cacheControlHeader) {
+		if v == noStoreValue {
+			return false
+		}
+	}
+	return true
 }
@@ -93,6 +93,20 @@ func testGenericQueryCacheRoundTrip(t *testing.T, newRoundTripper newGenericQuer
 			expectedLookupFromCache:  false,
 			expectedStoredToCache:    false,
 		},
+		"should not store the response in the cache if the downstream asked to not store it": {
+			cacheTTL: time.Minute,
+			downstreamRes: func() *http.Response {
+				res := downstreamRes(200, []byte(`{content:"fresh"}`))()
+				res.Header.Set("Cache-Control", "no-store")
+				return res
+			},
+			expectedStatusCode:       200,
+			expectedHeader:           http.Header{"Content-Type": []string{"application/json"}, "Cache-Control": []string{"no-store"}},
+			expectedBody:             []byte(`{content:"fresh"}`),
+			expectedDownstreamCalled: true,
+			expectedLookupFromCache:  true,
+			expectedStoredToCache:    false,
+		},
 		"should not store the response in the cache if the downstream returned a 4xx status code": {
 			cacheTTL:                 time.Minute,
 			downstreamRes:            downstreamRes(400, []byte(`{error:"400"}`)),
@@ -173,6 +187,8 @@ func testGenericQueryCacheRoundTrip(t *testing.T, newRoundTripper newGenericQuer
 								userID: {
 									resultsCacheTTLForCardinalityQuery: testData.cacheTTL,
 									resultsCacheTTLForLabelsQuery:      testData.cacheTTL,
+									resultsCacheTTL:                    testData.cacheTTL,
+									resultsCacheInstantQueryAlignment:  time.Minute,
 								},
 							},
 						}
@@ -0,0 +1,174 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/cache"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	instantQueryCachePrefix = "qi:"
+)
+
+// instantQueryCacheMiddleware caches the results of instant queries whose evaluation timestamp
+// is aligned to the per-tenant configured resolution. The caching is delegated to the generic
+// query cache, so the responses are stored encoded by the codec.
+type instantQueryCacheMiddleware struct {
+	next    Handler
+	codec   Codec
+	cache   cache.Cache
+	ttl     *instantQueryTTL
+	keyer   *instantQueryCacheKeyer
+	metrics *resultsCacheMetrics
+	logger  log.Logger
+}
+
+// newInstantQueryCacheMiddleware returns a Middleware caching the results of instant queries. It's expected
+// to be injected after the limits middleware, so that the limits are enforced on cached queries too.
+func newInstantQueryCacheMiddleware(cache cache.Cache, codec Codec, limits Limits, logger log.Logger, reg prometheus.Registerer) Middleware {
+	metrics := newResultsCacheMetrics("query_instant", reg)
+
+	return MiddlewareFunc(func(next Handler) Handler {
+		return &instantQueryCacheMiddleware{
+			next:  next,
+			codec: codec,
+			cache: cache,
+			ttl:   &instantQueryTTL{limits: limits},
+			keyer: &instantQueryCacheKeyer{
+				limits:  limits,
+				blocker: &queryBlockerMiddleware{limits: limits, logger: logger},
+				logger:  logger,
+			},
+			metrics: metrics,
+			logger:  logger,
+		}
+	})
+}
+
+func (c *instantQueryCacheMiddleware) Do(ctx context.Context, req Request) (Response, error) {
+	instantReq, ok := req.(*PrometheusInstantQueryRequest)
+	if !ok {
+		return c.next.Do(ctx, req)
+	}
+
+	httpReq, err := c.codec.EncodeRequest(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// The response returned by the downstream, if the query has not been served from the cache.
+	var downstreamRes Response
+
+	downstream := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		res, err := c.next.Do(r.Context(), req)
+		if err != nil {
+			return nil, err
+		}
+		downstreamRes = res
+
+		httpRes, err := c.codec.EncodeResponse(r.Context(), r, res)
+		if err != nil {
+			return nil, err
+		}
+
+		// The generic query cache only looks at the HTTP status code, which is always successful once encoded.
+		if promRes, ok := res.(*PrometheusResponse); !ok || promRes.Status != statusSuccess || !isResponseCachable(res, c.logger) {
+			httpRes.Header.Set(cacheControlHeader, noStoreValue)
+		}
+		return httpRes, nil
+	})
+
+	cacheKey := func(ctx context.Context, _ string, _ url.Values) (*GenericQueryCacheKey, error) {
+		key, err := c.keyer.cacheKey(ctx, instantReq)
+		if err != nil {
+			return nil, err
+		}
+		return &GenericQueryCacheKey{CacheKey: key, CacheKeyPrefix: instantQueryCachePrefix}, nil
+	}
+
+	httpRes, err := newGenericQueryCacheRoundTripper(c.cache, cacheKey, c.ttl, downstream, c.logger, c.metrics).RoundTrip(httpReq)
+	if err != nil {
+		return nil, err
+	}
+	if downstreamRes != nil {
+		return downstreamRes, nil
+	}
+
+	// The query has been served from the cache.
+	return c.codec.DecodeResponse(ctx, httpRes, req, c.logger)
+}
+
+type instantQueryTTL struct {
+	limits Limits
+}
+
+func (c *instantQueryTTL) ttl(userID string) time.Duration {
+	if c.limits.ResultsCacheInstantQueryAlignment(userID) <= 0 {
+		return 0
+	}
+	return c.limits.ResultsCacheTTL(userID)
+}
+
+type instantQueryCacheKeyer struct {
+	limits  Limits
+	blocker *queryBlockerMiddleware
+	logger  log.Logger
+
+	// now returns the current time. Overridden in tests.
+	now func() time.Time
+}
+
+// cacheKey returns the cache key of an instant query, or ErrUnsupportedRequest if the query results can't be cached.
+func (k *instantQueryCacheKeyer) cacheKey(ctx context.Context, req *PrometheusInstantQueryRequest) (string, error) {
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	// The timestamp must be aligned to the resolution of each tenant. Queries without the evaluation
+	// timestamp are evaluated at the current time, so they're rejected by the max cache freshness check below.
+	ts := req.GetTime()
+	for _, tenantID := range tenantIDs {
+		alignment := k.limits.ResultsCacheInstantQueryAlignment(tenantID).Milliseconds()
+		if alignment <= 0 || ts%alignment != 0 {
+			return "", ErrUnsupportedRequest
+		}
+	}
+
+	// Do not cache the results of queries evaluated at a time more recent than the max cache freshness,
+	// or falling into the out-of-order time window, because they may still change.
+	now := time.Now()
+	if k.now != nil {
+		now = k.now()
+	}
+	maxCacheFreshness := validation.MaxDurationPerTenant(tenantIDs, k.limits.MaxCacheFreshness)
+	outOfOrderTimeWindow := validation.MaxDurationPerTenant(tenantIDs, k.limits.OutOfOrderTimeWindow)
+	maxCacheTime := now.Add(-max(maxCacheFreshness, outOfOrderTimeWindow)).UnixMilli()
+	if ts > maxCacheTime {
+		return "", ErrUnsupportedRequest
+	}
+
+	if !areEvaluationTimeModifiersCachable(req, maxCacheTime, k.logger) {
+		return "", ErrUnsupportedRequest
+	}
+
+	// Blocked queries are rejected downstream, so they must never be served from the cache.
+	for _, tenantID := range tenantIDs {
+		if k.blocker.isBlocked(tenantID, req) {
+			return "", ErrUnsupportedRequest
+		}
+	}
+
+	return fmt.Sprintf("%s%c%d", req.GetQuery(), stringParamSeparator, ts), nil
+}
@@ -0,0 +1,218 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/cache"
+	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestInstantQueryCacheMiddleware_Do(t *testing.T) {
+	const query = `sum(rate(metric[5m]))`
+	ts := time.Date(2023, 7, 5, 1, 0, 0, 0, time.UTC).UnixMilli()
+
+	successRes := &PrometheusResponse{
+		Status: statusSuccess,
+		Data: &PrometheusData{
+			ResultType: "vector",
+			Result:     []SampleStream{{Labels: []mimirpb.LabelAdapter{{Name: "a", Value: "1"}}, Samples: []mimirpb.Sample{{TimestampMs: ts, Value: 1}}}},
+		},
+	}
+
+	tests := map[string]struct {
+		limits             mockLimits
+		req                Request
+		downstreamRes      *PrometheusResponse
+		expectedDownstream int
+		expectedCacheHits  int
+	}{
+		"should serve the second request from the cache": {
+			limits:             mockLimits{resultsCacheInstantQueryAlignment: time.Minute, resultsCacheTTL: time.Hour},
+			req:                &PrometheusInstantQueryRequest{Path: "/prometheus/api/v1/query", Time: ts, Query: query},
+			downstreamRes:      successRes,
+			expectedDownstream: 1,
+			expectedCacheHits:  1,
+		},
+		"should not cache if disabled for the request": {
+			limits:             mockLimits{resultsCacheInstantQueryAlignment: time.Minute, resultsCacheTTL: time.Hour},
+			req:                &PrometheusInstantQueryRequest{Path: "/prometheus/api/v1/query", Time: ts, Query: query, Options: Options{CacheDisabled: true}},
+			downstreamRes:      successRes,
+			expectedDownstream: 2,
+		},
+		"should not cache if disabled for the tenant": {
+			limits:             mockLimits{resultsCacheTTL: time.Hour},
+			req:                &PrometheusInstantQueryRequest{Path: "/prometheus/api/v1/query", Time: ts, Query: query},
+			downstreamRes:      successRes,
+			expectedDownstream: 2,
+		},
+		"should not cache a query with a timestamp not aligned to the resolution": {
+			limits:             mockLimits{resultsCacheInstantQueryAlignment: time.Minute, resultsCacheTTL: time.Hour},
+			req:                &PrometheusInstantQueryRequest{Path: "/prometheus/api/v1/query", Time: ts + 1000, Query: query},
+			downstreamRes:      successRes,
+			expectedDownstream: 2,
+		},
+		"should not cache an unsuccessful response": {
+			limits:             mockLimits{resultsCacheInstantQueryAlignment: time.Minute, resultsCacheTTL: time.Hour},
+			req:                &PrometheusInstantQueryRequest{Path: "/prometheus/api/v1/query", Time: ts, Query: query},
+			downstreamRes:      &PrometheusResponse{Status: statusError, ErrorType: "execution", Error: "failed"},
+			expectedDownstream: 2,
+		},
+		"should not cache a response with the Cache-Control: no-store header": {
+			limits: mockLimits{resultsCacheInstantQueryAlignment: time.Minute, resultsCacheTTL: time.Hour},
+			req:    &PrometheusInstantQueryRequest{Path: "/prometheus/api/v1/query", Time: ts, Query: query},
+			downstreamRes: &PrometheusResponse{
+				Status:  statusSuccess,
+				Data:    successRes.Data,
+				Headers: []*PrometheusResponseHeader{{Name: cacheControlHeader, Values: []string{noStoreValue}}},
+			},
+			expectedDownstream: 2,
+		},
+		"should not cache range queries": {
+			limits:             mockLimits{resultsCacheInstantQueryAlignment: time.Minute, resultsCacheTTL: time.Hour},
+			req:                &PrometheusRangeQueryRequest{Path: "/prometheus/api/v1/query_range", Start: ts, End: ts, Step: 60000, Query: query},
+			downstreamRes:      successRes,
+			expectedDownstream: 2,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				downstreamCalls = 0
+				cacheBackend    = cache.NewInstrumentedMockCache()
+				reg             = prometheus.NewPedanticRegistry()
+				ctx             = user.InjectOrgID(context.Background(), "user-1")
+			)
+
+			downstream := HandlerFunc(func(context.Context, Request) (Response, error) {
+				downstreamCalls++
+				return testData.downstreamRes, nil
+			})
+
+			mw := newInstantQueryCacheMiddleware(cacheBackend, newTestPrometheusCodec(), testData.limits, log.NewNopLogger(), reg).Wrap(downstream)
+
+			for i := 0; i < 2; i++ {
+				res, err := mw.Do(ctx, testData.req)
+				require.NoError(t, err)
+
+				// The response served from the cache has been encoded by the codec, so we only compare its content.
+				require.IsType(t, &PrometheusResponse{}, res)
+				assert.Equal(t, testData.downstreamRes.Status, res.(*PrometheusResponse).Status)
+				assert.Equal(t, testData.downstreamRes.Data, res.(*PrometheusResponse).Data)
+			}
+
+			assert.Equal(t, testData.expectedDownstream, downstreamCalls)
+			assert.Equal(t, float64(testData.expectedCacheHits), promtest.ToFloat64(mw.(*instantQueryCacheMiddleware).metrics.cacheHits))
+
+			if testData.expectedCacheHits > 0 {
+				expectedKey := instantQueryCachePrefix + cacheHashKey("user-1:"+query+"\x00"+strconv.FormatInt(ts, 10))
+				assert.Contains(t, cacheBackend.Fetch(ctx, []string{expectedKey}), expectedKey)
+			}
+		})
+	}
+}
+
+func TestInstantQueryCacheKeyer_CacheKey(t *testing.T) {
+	now := time.Date(2023, 7, 5, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]struct {
+		tenants          []string
+		limits           map[string]mockLimits
+		req              *PrometheusInstantQueryRequest
+		expectedCacheKey string
+		expectedErr      error
+	}{
+		"should return the cache key of a query with aligned timestamp": {
+			req:              &PrometheusInstantQueryRequest{Query: "up", Time: 1688554800000},
+			expectedCacheKey: "up\x001688554800000",
+		},
+		"should not cache a query evaluated at the current time": {
+			limits:      map[string]mockLimits{"user-1": {resultsCacheInstantQueryAlignment: time.Minute, maxCacheFreshness: time.Minute}},
+			req:         &PrometheusInstantQueryRequest{Query: "up", Time: now.UnixMilli()},
+			expectedErr: ErrUnsupportedRequest,
+		},
+		"should not cache a query with a timestamp not aligned to the resolution": {
+			req:         &PrometheusInstantQueryRequest{Query: "up", Time: 1688554830000},
+			expectedErr: ErrUnsupportedRequest,
+		},
+		"should not cache a query if disabled for the tenant": {
+			limits:      map[string]mockLimits{"user-1": {}},
+			req:         &PrometheusInstantQueryRequest{Query: "up", Time: 1688554800000},
+			expectedErr: ErrUnsupportedRequest,
+		},
+		"should not cache a query with a timestamp more recent than the max cache freshness": {
+			limits:      map[string]mockLimits{"user-1": {resultsCacheInstantQueryAlignment: time.Minute, maxCacheFreshness: 2 * time.Hour}},
+			req:         &PrometheusInstantQueryRequest{Query: "up", Time: 1688554800000},
+			expectedErr: ErrUnsupportedRequest,
+		},
+		"should not cache a query with a timestamp in the out-of-order time window": {
+			limits:      map[string]mockLimits{"user-1": {resultsCacheInstantQueryAlignment: time.Minute, outOfOrderTimeWindow: 2 * time.Hour}},
+			req:         &PrometheusInstantQueryRequest{Query: "up", Time: 1688554800000},
+			expectedErr: ErrUnsupportedRequest,
+		},
+		"should not cache a query with a @ modifier after the evaluation timestamp": {
+			req:         &PrometheusInstantQueryRequest{Query: "up @ 1688554860", Time: 1688554800000},
+			expectedErr: ErrUnsupportedRequest,
+		},
+		"should not cache a blocked query": {
+			limits:      map[string]mockLimits{"user-1": {resultsCacheInstantQueryAlignment: time.Minute, blockedQueries: []*validation.BlockedQuery{{Pattern: "up"}}}},
+			req:         &PrometheusInstantQueryRequest{Query: "up", Time: 1688554800000},
+			expectedErr: ErrUnsupportedRequest,
+		},
+		"should require the timestamp to be aligned to the resolution of each tenant": {
+			tenants: []string{"user-1", "user-2"},
+			limits: map[string]mockLimits{
+				"user-1": {resultsCacheInstantQueryAlignment: time.Minute},
+				"user-2": {resultsCacheInstantQueryAlignment: time.Hour},
+			},
+			req:         &PrometheusInstantQueryRequest{Query: "up", Time: 1688554860000},
+			expectedErr: ErrUnsupportedRequest,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			tenants := testData.tenants
+			if len(tenants) == 0 {
+				tenants = []string{"user-1"}
+			}
+
+			limits := testData.limits
+			if limits == nil {
+				limits = map[string]mockLimits{"user-1": {resultsCacheInstantQueryAlignment: time.Minute}}
+			}
+
+			keyer := &instantQueryCacheKeyer{
+				limits:  multiTenantMockLimits{byTenant: limits},
+				blocker: &queryBlockerMiddleware{limits: multiTenantMockLimits{byTenant: limits}, logger: log.NewNopLogger()},
+				logger:  log.NewNopLogger(),
+				now:     func() time.Time { return now },
+			}
+
+			ctx := user.InjectOrgID(context.Background(), tenant.JoinTenantIDs(tenants))
+			actual, err := keyer.cacheKey(ctx, testData.req)
+			if testData.expectedErr != nil {
+				require.ErrorIs(t, err, testData.expectedErr)
+				assert.Empty(t, actual)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedCacheKey, actual)
+		})
+	}
+}
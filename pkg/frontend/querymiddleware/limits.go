@@ -46,6 +46,20 @@ type Limits interface {
 	// query may be. 0 means "unlimited".
 	MaxQueryExpressionSizeBytes(userID string) int
 
+	// MaxQueryCost returns the max estimated cost of a query. 0 means "unlimited".
+	MaxQueryCost(userID string) int
+
+	// QueryCostDeprioritizationThreshold returns the estimated query cost above which
+	// queries are deprioritized in the query-scheduler. 0 to disable.
+	QueryCostDeprioritizationThreshold(userID string) int
+
+	// QueryPriorityClassesAllowed returns the query priority classes the tenant's clients are allowed to set.
+	QueryPriorityClassesAllowed(userID string) []string
+
+	// CardinalityAnalysisEnabled returns whether the cardinality analysis endpoints are enabled,
+	// which are required to estimate the query cost.
+	CardinalityAnalysisEnabled(userID string) bool
+
 	// MaxCacheFreshness returns the period after which results are cacheable,
 	// to prevent caching of very recent results.
 	MaxCacheFreshness(userID string) time.Duration
@@ -98,6 +112,10 @@ type Limits interface {
 	// ResultsCacheForUnalignedQueryEnabled returns whether to cache results for queries that are not step-aligned
 	ResultsCacheForUnalignedQueryEnabled(userID string) bool
 
+	// ResultsCacheInstantQueryAlignment returns the resolution instant queries timestamp must be aligned to
+	// for their results to be cached. 0 means instant queries are not cached.
+	ResultsCacheInstantQueryAlignment(userID string) time.Duration
+
 	// BlockedQueries returns the blocked queries.
 	BlockedQueries(userID string) []*validation.BlockedQuery
 
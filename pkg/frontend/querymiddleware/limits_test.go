@@ -370,6 +370,22 @@ func (m multiTenantMockLimits) MaxQueryExpressionSizeBytes(userID string) int {
 	return m.byTenant[userID].maxQueryExpressionSizeBytes
 }
 
+func (m multiTenantMockLimits) MaxQueryCost(userID string) int {
+	return m.byTenant[userID].maxQueryCost
+}
+
+func (m multiTenantMockLimits) QueryCostDeprioritizationThreshold(userID string) int {
+	return m.byTenant[userID].queryCostDeprioritizationThreshold
+}
+
+func (m multiTenantMockLimits) QueryPriorityClassesAllowed(userID string) []string {
+	return m.byTenant[userID].queryPriorityClassesAllowed
+}
+
+func (m multiTenantMockLimits) CardinalityAnalysisEnabled(userID string) bool {
+	return m.byTenant[userID].cardinalityAnalysisEnabled
+}
+
 func (m multiTenantMockLimits) MaxQueryParallelism(userID string) int {
 	return m.byTenant[userID].maxQueryParallelism
 }
@@ -426,6 +442,10 @@ func (m multiTenantMockLimits) ResultsCacheForUnalignedQueryEnabled(userID strin
 	return m.byTenant[userID].resultsCacheForUnalignedQueryEnabled
 }
 
+func (m multiTenantMockLimits) ResultsCacheInstantQueryAlignment(userID string) time.Duration {
+	return m.byTenant[userID].resultsCacheInstantQueryAlignment
+}
+
 func (m multiTenantMockLimits) BlockedQueries(userID string) []*validation.BlockedQuery {
 	return m.byTenant[userID].blockedQueries
 }
@@ -451,6 +471,10 @@ type mockLimits struct {
 	maxQueryLength                       time.Duration
 	maxTotalQueryLength                  time.Duration
 	maxQueryExpressionSizeBytes          int
+	maxQueryCost                         int
+	queryCostDeprioritizationThreshold   int
+	queryPriorityClassesAllowed          []string
+	cardinalityAnalysisEnabled           bool
 	maxCacheFreshness                    time.Duration
 	maxQueryParallelism                  int
 	maxShardedQueries                    int
@@ -467,6 +491,7 @@ type mockLimits struct {
 	resultsCacheTTLForCardinalityQuery   time.Duration
 	resultsCacheTTLForLabelsQuery        time.Duration
 	resultsCacheForUnalignedQueryEnabled bool
+	resultsCacheInstantQueryAlignment    time.Duration
 	blockedQueries                       []*validation.BlockedQuery
 	alignQueriesWithStep                 bool
 	queryIngestersWithin                 time.Duration
@@ -487,6 +512,22 @@ func (m mockLimits) MaxQueryExpressionSizeBytes(string) int {
 	return m.maxQueryExpressionSizeBytes
 }
 
+func (m mockLimits) MaxQueryCost(string) int {
+	return m.maxQueryCost
+}
+
+func (m mockLimits) QueryPriorityClassesAllowed(string) []string {
+	return m.queryPriorityClassesAllowed
+}
+
+func (m mockLimits) QueryCostDeprioritizationThreshold(string) int {
+	return m.queryCostDeprioritizationThreshold
+}
+
+func (m mockLimits) CardinalityAnalysisEnabled(string) bool {
+	return m.cardinalityAnalysisEnabled
+}
+
 func (m mockLimits) MaxQueryParallelism(string) int {
 	if m.maxQueryParallelism == 0 {
 		return 14 // Flag default.
@@ -550,6 +591,10 @@ func (m mockLimits) ResultsCacheForUnalignedQueryEnabled(string) bool {
 	return m.resultsCacheForUnalignedQueryEnabled
 }
 
+func (m mockLimits) ResultsCacheInstantQueryAlignment(string) time.Duration {
+	return m.resultsCacheInstantQueryAlignment
+}
+
 func (m mockLimits) CreationGracePeriod(string) time.Duration {
 	return m.creationGracePeriod
 }
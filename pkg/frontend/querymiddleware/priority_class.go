@@ -0,0 +1,49 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"net/http"
+
+	"github.com/grafana/dskit/tenant"
+	"golang.org/x/exp/slices"
+
+	"github.com/grafana/mimir/pkg/querier/api"
+)
+
+// newQueryPriorityClassRoundTripper returns a http.RoundTripper honoring the query priority class requested
+// by the client via the X-Mimir-Query-Priority-Class header, if it's allowed for all the queried tenants.
+// The priority class is propagated to the query-scheduler, which enqueues the query in the subqueue
+// of the priority class.
+func newQueryPriorityClassRoundTripper(next http.RoundTripper, limits Limits) http.RoundTripper {
+	return RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		ctx := r.Context()
+
+		// The priority class set by internal callers, like the ruler, is always honored.
+		if _, ok := api.QueryPriorityClassFromContext(ctx); ok {
+			return next.RoundTrip(r)
+		}
+
+		class, ok := api.RequestedQueryPriorityClassFromContext(ctx)
+		if !ok {
+			return next.RoundTrip(r)
+		}
+
+		tenantIDs, err := tenant.TenantIDs(ctx)
+		if err != nil {
+			return next.RoundTrip(r)
+		}
+
+		for _, tenantID := range tenantIDs {
+			if !slices.Contains(limits.QueryPriorityClassesAllowed(tenantID), class) {
+				return next.RoundTrip(r)
+			}
+		}
+
+		// The priority class is set both in the context, for the requests encoded by the codec,
+		// and in the header, for the requests forwarded as-is.
+		r = r.Clone(api.ContextWithQueryPriorityClass(ctx, class))
+		r.Header.Set(api.QueryPriorityClassHeader, class)
+		return next.RoundTrip(r)
+	})
+}
@@ -0,0 +1,137 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/grafana/dskit/user"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/querier/api"
+)
+
+func TestQueryPriorityClassRoundTripper(t *testing.T) {
+	allowAll := []string{api.QueryPriorityClassDashboard, api.QueryPriorityClassAdHoc, api.QueryPriorityClassExport}
+
+	tests := map[string]struct {
+		tenants       string
+		header        string
+		limits        map[string]mockLimits
+		expectedClass string
+	}{
+		"should honor the dashboard priority class if allowed for the tenant": {
+			tenants:       "user-1",
+			header:        api.QueryPriorityClassDashboard,
+			limits:        map[string]mockLimits{"user-1": {queryPriorityClassesAllowed: allowAll}},
+			expectedClass: api.QueryPriorityClassDashboard,
+		},
+		"should honor the ad-hoc priority class if allowed for the tenant": {
+			tenants:       "user-1",
+			header:        api.QueryPriorityClassAdHoc,
+			limits:        map[string]mockLimits{"user-1": {queryPriorityClassesAllowed: allowAll}},
+			expectedClass: api.QueryPriorityClassAdHoc,
+		},
+		"should honor the export priority class if allowed for the tenant": {
+			tenants:       "user-1",
+			header:        api.QueryPriorityClassExport,
+			limits:        map[string]mockLimits{"user-1": {queryPriorityClassesAllowed: allowAll}},
+			expectedClass: api.QueryPriorityClassExport,
+		},
+		"should ignore a priority class not allowed for the tenant": {
+			tenants: "user-1",
+			header:  api.QueryPriorityClassAlerting,
+			limits:  map[string]mockLimits{"user-1": {queryPriorityClassesAllowed: allowAll}},
+		},
+		"should ignore the priority class if the tenant is not allowed to set any": {
+			tenants: "user-1",
+			header:  api.QueryPriorityClassDashboard,
+			limits:  map[string]mockLimits{"user-1": {}},
+		},
+		"should honor the priority class of a cross-tenant query if allowed for all the tenants": {
+			tenants: "user-1|user-2",
+			header:  api.QueryPriorityClassExport,
+			limits: map[string]mockLimits{
+				"user-1": {queryPriorityClassesAllowed: allowAll},
+				"user-2": {queryPriorityClassesAllowed: []string{api.QueryPriorityClassExport}},
+			},
+			expectedClass: api.QueryPriorityClassExport,
+		},
+		"should ignore the priority class of a cross-tenant query if not allowed for one of the tenants": {
+			tenants: "user-1|user-2",
+			header:  api.QueryPriorityClassDashboard,
+			limits: map[string]mockLimits{
+				"user-1": {queryPriorityClassesAllowed: allowAll},
+				"user-2": {queryPriorityClassesAllowed: []string{api.QueryPriorityClassExport}},
+			},
+		},
+		"should not set any priority class if not requested": {
+			tenants: "user-1",
+			limits:  map[string]mockLimits{"user-1": {queryPriorityClassesAllowed: allowAll}},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				actualClass   string
+				actualClassOK bool
+				actualHeader  string
+			)
+			next := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				actualClass, actualClassOK = api.QueryPriorityClassFromContext(r.Context())
+				actualHeader = r.Header.Get(api.QueryPriorityClassHeader)
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+			rt := newQueryPriorityClassRoundTripper(next, multiTenantMockLimits{byTenant: testData.limits})
+
+			// The request is received via HTTP, so the priority class header goes through the API middleware first.
+			handler := api.QueryPriorityClassMiddleware().Wrap(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				res, err := rt.RoundTrip(r)
+				require.NoError(t, err)
+				require.NoError(t, res.Body.Close())
+				w.WriteHeader(res.StatusCode)
+			}))
+
+			req := httptest.NewRequest(http.MethodGet, "/prometheus/api/v1/query?query=up", nil)
+			req = req.WithContext(user.InjectOrgID(req.Context(), testData.tenants))
+			if testData.header != "" {
+				req.Header.Set(api.QueryPriorityClassHeader, testData.header)
+			}
+
+			res := httptest.NewRecorder()
+			handler.ServeHTTP(res, req)
+			require.Equal(t, http.StatusOK, res.Code)
+
+			if testData.expectedClass != "" {
+				assert.True(t, actualClassOK)
+				assert.Equal(t, testData.expectedClass, actualClass)
+			} else {
+				assert.False(t, actualClassOK)
+			}
+			assert.Equal(t, testData.expectedClass, actualHeader)
+		})
+	}
+}
+
+func TestQueryPriorityClassRoundTripper_ShouldHonorThePriorityClassOfInternalCallers(t *testing.T) {
+	var actualClass string
+	next := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		actualClass, _ = api.QueryPriorityClassFromContext(r.Context())
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+	rt := newQueryPriorityClassRoundTripper(next, mockLimits{})
+
+	req := httptest.NewRequest(http.MethodGet, "/prometheus/api/v1/query?query=up", nil)
+	req = req.WithContext(api.ContextWithQueryPriorityClass(user.InjectOrgID(req.Context(), "user-1"), api.QueryPriorityClassAlerting))
+
+	res, err := rt.RoundTrip(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	assert.Equal(t, api.QueryPriorityClassAlerting, actualClass)
+}
@@ -0,0 +1,360 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/concurrency"
+	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cardinality"
+	"github.com/grafana/mimir/pkg/querier/api"
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/util/spanlogger"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	// queryCostEstimationConcurrency is the max number of concurrent requests issued to estimate the cost of a query.
+	queryCostEstimationConcurrency = 8
+
+	// queryCostSeriesCountCacheSize is the max number of series counts, by tenant and selector, kept in memory.
+	queryCostSeriesCountCacheSize = 10000
+
+	// queryCostSeriesCountCacheTTL is how long the series count of a selector is reused before looking it up again.
+	queryCostSeriesCountCacheTTL = time.Minute
+)
+
+var errCardinalityAnalysisDisabled = fmt.Errorf("the query cost can't be estimated because the cardinality analysis is disabled for the tenant")
+
+// BlocksFinder finds the blocks of a tenant in the long-term storage.
+type BlocksFinder interface {
+	// GetBlocks returns known blocks for userID containing samples within the range minT
+	// and maxT (milliseconds, both included).
+	GetBlocks(ctx context.Context, userID string, minT, maxT int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error)
+}
+
+// queryCost is the estimated cost of a query.
+type queryCost struct {
+	// series is the number of in-memory series matching the query selectors in ingesters.
+	series uint64
+
+	// blocks is the number of blocks queried from the long-term storage.
+	blocks uint64
+
+	// cost is the number of series multiplied by the number of data sources queried,
+	// which are the ingesters and each block in the long-term storage.
+	cost uint64
+}
+
+type queryCostMetrics struct {
+	estimatedCost      prometheus.Histogram
+	estimationFailures prometheus.Counter
+	expensiveQueries   *prometheus.CounterVec
+}
+
+func newQueryCostMetrics(reg prometheus.Registerer) *queryCostMetrics {
+	return &queryCostMetrics{
+		estimatedCost: promauto.With(reg).NewHistogram(prometheus.HistogramOpts{
+			Name:    "cortex_query_frontend_estimated_query_cost",
+			Help:    "Estimated cost of the queries received by the query-frontend.",
+			Buckets: prometheus.ExponentialBuckets(100, 4, 10),
+		}),
+		estimationFailures: promauto.With(reg).NewCounter(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_query_cost_estimation_failures_total",
+			Help: "Total number of queries whose cost could not be estimated.",
+		}),
+		expensiveQueries: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_query_frontend_expensive_queries_total",
+			Help: "Total number of queries whose estimated cost exceeds the tenant budget, by the action taken.",
+		}, []string{"user", "action"}),
+	}
+}
+
+// queryCostRoundTripper estimates the cost of range and instant queries before running them,
+// and enforces the per-tenant query cost budgets.
+type queryCostRoundTripper struct {
+	next         http.RoundTripper
+	downstream   http.RoundTripper
+	codec        Codec
+	engine       *promql.Engine
+	limits       Limits
+	blocksFinder BlocksFinder
+	logger       log.Logger
+	metrics      *queryCostMetrics
+
+	// seriesCounts caches the number of series matching a selector, keyed by tenant and selector.
+	seriesCounts *lru.Cache[string, cachedSeriesCount]
+
+	// now returns the current time. Overridden in tests.
+	now func() time.Time
+}
+
+type cachedSeriesCount struct {
+	count   uint64
+	expires time.Time
+}
+
+// newQueryCostRoundTripper returns a http.RoundTripper estimating the cost of queries and rejecting or
+// deprioritizing the ones exceeding the tenant budget. Only the queries of tenants with a query cost limit
+// are estimated. The number of series is estimated issuing cardinality requests to downstream, and cached
+// for a short period, while the number of blocks is looked up via blocksFinder, if not nil.
+func newQueryCostRoundTripper(next, downstream http.RoundTripper, codec Codec, engine *promql.Engine, limits Limits, blocksFinder BlocksFinder, logger log.Logger, metrics *queryCostMetrics) http.RoundTripper {
+	// The cache size is a positive constant, so creating the cache can't fail.
+	seriesCounts, _ := lru.New[string, cachedSeriesCount](queryCostSeriesCountCacheSize)
+
+	return &queryCostRoundTripper{
+		next:         next,
+		downstream:   downstream,
+		codec:        codec,
+		engine:       engine,
+		limits:       limits,
+		blocksFinder: blocksFinder,
+		logger:       logger,
+		metrics:      metrics,
+		seriesCounts: seriesCounts,
+		now:          time.Now,
+	}
+}
+
+func (q *queryCostRoundTripper) RoundTrip(r *http.Request) (*http.Response, error) {
+	spanLog, ctx := spanlogger.NewWithLogger(r.Context(), q.logger, "queryCostRoundTripper.RoundTrip")
+	defer spanLog.Finish()
+
+	tenantIDs, err := tenant.TenantIDs(ctx)
+	if err != nil {
+		return q.next.RoundTrip(r)
+	}
+
+	// The cost is estimated only for tenants with a query cost limit, because it issues
+	// additional requests for each query.
+	maxCost := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, q.limits.MaxQueryCost)
+	threshold := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, q.limits.QueryCostDeprioritizationThreshold)
+	if maxCost <= 0 && threshold <= 0 {
+		return q.next.RoundTrip(r)
+	}
+
+	req, err := q.codec.DecodeRequest(ctx, r)
+	if err != nil {
+		// Let the downstream handle the invalid request.
+		return q.next.RoundTrip(r)
+	}
+
+	cost, err := q.estimate(ctx, r.URL.Path, tenantIDs, req)
+	if err != nil {
+		q.metrics.estimationFailures.Inc()
+		level.Warn(spanLog).Log("msg", "failed to estimate the query cost", "query", req.GetQuery(), "err", err)
+
+		// The max query cost can't be enforced without the cardinality analysis (e.g. it's disabled for
+		// one of the tenants of a cross-tenant query), so the query is rejected instead of bypassing the limit.
+		if maxCost > 0 && errors.Is(err, errCardinalityAnalysisDisabled) {
+			return nil, apierror.New(apierror.TypeBadData, err.Error())
+		}
+
+		// Otherwise the query cost estimation is best-effort, so the query is executed anyway.
+		return q.next.RoundTrip(r)
+	}
+
+	q.metrics.estimatedCost.Observe(float64(cost.cost))
+	spanLog.DebugLog("msg", "estimated query cost", "cost", cost.cost, "series", cost.series, "blocks", cost.blocks)
+	if details := QueryDetailsFromContext(ctx); details != nil {
+		details.EstimatedQueryCost = cost.cost
+	}
+
+	userID := tenant.JoinTenantIDs(tenantIDs)
+	if maxCost > 0 && cost.cost > uint64(maxCost) {
+		q.metrics.expensiveQueries.WithLabelValues(userID, "rejected").Inc()
+		return nil, newMaxQueryCostError(cost.cost, maxCost)
+	}
+
+	if threshold > 0 && cost.cost > uint64(threshold) {
+		q.metrics.expensiveQueries.WithLabelValues(userID, "deprioritized").Inc()
+		r = r.WithContext(api.ContextWithDeprioritizedQuery(r.Context()))
+	}
+
+	return q.next.RoundTrip(r)
+}
+
+// estimate returns the estimated cost of the query, summed across all tenants.
+func (q *queryCostRoundTripper) estimate(ctx context.Context, path string, tenantIDs []string, req Request) (queryCost, error) {
+	query, err := newQuery(ctx, req, q.engine, queryStatsErrQueryable)
+	if err != nil {
+		return queryCost{}, err
+	}
+	defer query.Close()
+
+	evalStmt, ok := query.Statement().(*parser.EvalStmt)
+	if !ok {
+		return queryCost{}, fmt.Errorf("unexpected statement type %T", query.Statement())
+	}
+
+	selectors := querySelectors(evalStmt.Expr)
+	if len(selectors) == 0 {
+		// Nothing to fetch from the storage.
+		return queryCost{}, nil
+	}
+	minT, maxT := promql.FindMinMaxTime(evalStmt)
+
+	var total queryCost
+	for _, tenantID := range tenantIDs {
+		if !q.limits.CardinalityAnalysisEnabled(tenantID) {
+			return queryCost{}, errCardinalityAnalysisDisabled
+		}
+
+		tenantCtx := user.InjectOrgID(ctx, tenantID)
+
+		series, err := q.countSeries(tenantCtx, tenantID, path, selectors)
+		if err != nil {
+			return queryCost{}, err
+		}
+
+		var blocks uint64
+		if q.blocksFinder != nil {
+			found, _, err := q.blocksFinder.GetBlocks(tenantCtx, tenantID, minT, maxT)
+			if err != nil {
+				return queryCost{}, err
+			}
+			blocks = uint64(len(found))
+		}
+
+		total.series += series
+		total.blocks += blocks
+		total.cost += series * (1 + blocks)
+	}
+
+	return total, nil
+}
+
+// countSeries returns the number of in-memory series matching the selectors, issuing a label values
+// cardinality request to downstream for each selector whose count is not cached. The request context
+// must hold the single input tenant.
+func (q *queryCostRoundTripper) countSeries(ctx context.Context, tenantID, path string, selectors []string) (uint64, error) {
+	counts := make([]uint64, len(selectors))
+
+	err := concurrency.ForEachJob(ctx, len(selectors), queryCostEstimationConcurrency, func(ctx context.Context, idx int) error {
+		cacheKey := tenantID + string(stringParamSeparator) + selectors[idx]
+		if cached, ok := q.seriesCounts.Get(cacheKey); ok && q.now().Before(cached.expires) {
+			counts[idx] = cached.count
+			return nil
+		}
+
+		count, err := q.countSeriesForSelector(ctx, path, selectors[idx])
+		if err != nil {
+			return err
+		}
+
+		counts[idx] = count
+		q.seriesCounts.Add(cacheKey, cachedSeriesCount{count: count, expires: q.now().Add(queryCostSeriesCountCacheTTL)})
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	var total uint64
+	for _, count := range counts {
+		total += count
+	}
+	return total, nil
+}
+
+func (q *queryCostRoundTripper) countSeriesForSelector(ctx context.Context, path string, selector string) (uint64, error) {
+	// The cardinality request stats must not be accounted to the query.
+	_, ctx = stats.ContextWithEmptyStats(ctx)
+
+	u := &url.URL{
+		Path: cardinalityPathPrefix(path) + cardinalityLabelValuesPathSuffix,
+		RawQuery: url.Values{
+			"label_names[]": []string{labels.MetricName},
+			"selector":      []string{selector},
+			"count_method":  []string{string(cardinality.InMemoryMethod)},
+			"limit":         []string{"0"},
+		}.Encode(),
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), http.NoBody)
+	if err != nil {
+		return 0, err
+	}
+	req.RequestURI = u.String() // This is what the httpgrpc code looks at.
+
+	if err := user.InjectOrgIDIntoHTTPRequest(ctx, req); err != nil {
+		return 0, err
+	}
+
+	res, err := q.downstream.RoundTrip(req)
+	if err != nil {
+		return 0, err
+	}
+	defer func() { _ = res.Body.Close() }()
+
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return 0, err
+	}
+	if res.StatusCode != http.StatusOK {
+		return 0, fmt.Errorf("cardinality request failed with status code %d: %s", res.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	var cardinalityRes api.LabelValuesCardinalityResponse
+	if err := json.Unmarshal(body, &cardinalityRes); err != nil {
+		return 0, fmt.Errorf("failed to decode cardinality response: %w", err)
+	}
+
+	var series uint64
+	for _, item := range cardinalityRes.Labels {
+		if item.LabelName == labels.MetricName {
+			series += item.SeriesCount
+		}
+	}
+	return series, nil
+}
+
+// querySelectors returns the distinct series selectors of the query, sorted.
+func querySelectors(expr parser.Expr) []string {
+	unique := map[string]struct{}{}
+	for _, matchers := range parser.ExtractSelectors(expr) {
+		formatted := make([]string, 0, len(matchers))
+		for _, m := range matchers {
+			formatted = append(formatted, m.String())
+		}
+		sort.Strings(formatted)
+		unique["{"+strings.Join(formatted, ",")+"}"] = struct{}{}
+	}
+
+	selectors := make([]string, 0, len(unique))
+	for selector := range unique {
+		selectors = append(selectors, selector)
+	}
+	sort.Strings(selectors)
+	return selectors
+}
+
+// cardinalityPathPrefix returns the API prefix of the range or instant query path.
+func cardinalityPathPrefix(path string) string {
+	if IsRangeQuery(path) {
+		return strings.TrimSuffix(path, queryRangePathSuffix)
+	}
+	return strings.TrimSuffix(path, instantQueryPathSuffix)
+}
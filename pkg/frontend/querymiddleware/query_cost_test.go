@@ -0,0 +1,315 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querymiddleware
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/tenant"
+	"github.com/grafana/dskit/user"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	apierror "github.com/grafana/mimir/pkg/api/error"
+	"github.com/grafana/mimir/pkg/cardinality"
+	"github.com/grafana/mimir/pkg/querier/api"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+)
+
+func TestQueryCostRoundTripper(t *testing.T) {
+	// Number of in-memory series matching each selector, by tenant.
+	seriesBySelector := map[string]map[string]uint64{
+		"user-1": {`{__name__="metric_a"}`: 10, `{__name__="metric_b",job="test"}`: 5},
+		"user-2": {`{__name__="metric_a"}`: 20},
+	}
+	blocksByTenant := map[string]int{"user-1": 3, "user-2": 1}
+
+	tests := map[string]struct {
+		tenants                   []string
+		path                      string
+		query                     string
+		limits                    map[string]mockLimits
+		cardinalityStatusCode     int
+		withoutBlocksFinder       bool
+		expectedCost              uint64
+		expectedErr               string
+		expectedDeprioritized     bool
+		expectedFailures          int
+		expectedMetrics           string
+		expectedNoCardinalityReqs bool
+	}{
+		"should estimate the cost of a range query": {
+			path:         "/prometheus/api/v1/query_range",
+			query:        `sum(rate(metric_a[5m])) / sum(rate(metric_b{job="test"}[5m]))`,
+			expectedCost: (10 + 5) * (1 + 3),
+		},
+		"should estimate the cost of an instant query": {
+			path:         "/prometheus/api/v1/query",
+			query:        `metric_a`,
+			expectedCost: 10 * (1 + 3),
+		},
+		"should count the same selector once": {
+			path:         "/prometheus/api/v1/query",
+			query:        `metric_a + metric_a offset 1h`,
+			expectedCost: 10 * (1 + 3),
+		},
+		"should estimate the cost based on ingesters only if the blocks finder is not configured": {
+			path:                "/prometheus/api/v1/query",
+			query:               `metric_a`,
+			withoutBlocksFinder: true,
+			expectedCost:        10,
+		},
+		"should sum the cost across tenants": {
+			tenants:      []string{"user-1", "user-2"},
+			path:         "/prometheus/api/v1/query",
+			query:        `metric_a`,
+			expectedCost: 10*(1+3) + 20*(1+1),
+		},
+		"should not estimate a cost for queries without selectors": {
+			path:  "/prometheus/api/v1/query",
+			query: `vector(1)`,
+		},
+		"should reject a query exceeding the max query cost": {
+			path:        "/prometheus/api/v1/query",
+			query:       `metric_a`,
+			limits:      map[string]mockLimits{"user-1": {maxQueryCost: 39, cardinalityAnalysisEnabled: true}},
+			expectedErr: "the estimated query cost exceeds the limit (estimated cost: 40, limit: 39)",
+			expectedMetrics: `
+				# HELP cortex_query_frontend_expensive_queries_total Total number of queries whose estimated cost exceeds the tenant budget, by the action taken.
+				# TYPE cortex_query_frontend_expensive_queries_total counter
+				cortex_query_frontend_expensive_queries_total{action="rejected",user="user-1"} 1
+			`,
+		},
+		"should not reject a query matching the max query cost": {
+			path:         "/prometheus/api/v1/query",
+			query:        `metric_a`,
+			limits:       map[string]mockLimits{"user-1": {maxQueryCost: 40, cardinalityAnalysisEnabled: true}},
+			expectedCost: 40,
+		},
+		"should deprioritize a query exceeding the deprioritization threshold": {
+			path:                  "/prometheus/api/v1/query",
+			query:                 `metric_a`,
+			limits:                map[string]mockLimits{"user-1": {maxQueryCost: 100, queryCostDeprioritizationThreshold: 20, cardinalityAnalysisEnabled: true}},
+			expectedCost:          40,
+			expectedDeprioritized: true,
+			expectedMetrics: `
+				# HELP cortex_query_frontend_expensive_queries_total Total number of queries whose estimated cost exceeds the tenant budget, by the action taken.
+				# TYPE cortex_query_frontend_expensive_queries_total counter
+				cortex_query_frontend_expensive_queries_total{action="deprioritized",user="user-1"} 1
+			`,
+		},
+		"should run the query if the cost can't be estimated": {
+			path:                  "/prometheus/api/v1/query",
+			query:                 `metric_a`,
+			limits:                map[string]mockLimits{"user-1": {maxQueryCost: 1, cardinalityAnalysisEnabled: true}},
+			cardinalityStatusCode: http.StatusBadRequest,
+			expectedFailures:      1,
+		},
+		"should not estimate the cost if the tenant has no query cost limits": {
+			path:                      "/prometheus/api/v1/query",
+			query:                     `metric_a`,
+			limits:                    map[string]mockLimits{"user-1": {cardinalityAnalysisEnabled: true}},
+			expectedNoCardinalityReqs: true,
+		},
+		"should reject the query if the cardinality analysis is disabled for the tenant with a max query cost": {
+			path:                      "/prometheus/api/v1/query",
+			query:                     `metric_a`,
+			limits:                    map[string]mockLimits{"user-1": {maxQueryCost: 1}},
+			expectedErr:               errCardinalityAnalysisDisabled.Error(),
+			expectedFailures:          1,
+			expectedNoCardinalityReqs: true,
+		},
+		"should reject a cross-tenant query if the cardinality analysis is disabled for one of the tenants": {
+			tenants: []string{"user-1", "user-2"},
+			path:    "/prometheus/api/v1/query",
+			query:   `metric_a`,
+			limits: map[string]mockLimits{
+				"user-1": {maxQueryCost: 1000, cardinalityAnalysisEnabled: true},
+				"user-2": {},
+			},
+			expectedErr:      errCardinalityAnalysisDisabled.Error(),
+			expectedFailures: 1,
+		},
+		"should run the query if the cardinality analysis is disabled for the tenant with a deprioritization threshold only": {
+			path:                      "/prometheus/api/v1/query",
+			query:                     `metric_a`,
+			limits:                    map[string]mockLimits{"user-1": {queryCostDeprioritizationThreshold: 1}},
+			expectedFailures:          1,
+			expectedNoCardinalityReqs: true,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			tenants := testData.tenants
+			if len(tenants) == 0 {
+				tenants = []string{"user-1"}
+			}
+
+			limits := testData.limits
+			if limits == nil {
+				limits = map[string]mockLimits{
+					"user-1": {maxQueryCost: 1000, cardinalityAnalysisEnabled: true},
+					"user-2": {maxQueryCost: 1000, cardinalityAnalysisEnabled: true},
+				}
+			}
+
+			cardinalityReqs := atomic.NewInt64(0)
+			downstream := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				cardinalityReqs.Inc()
+				assert.Equal(t, "/prometheus"+cardinalityLabelValuesPathSuffix, r.URL.Path)
+				assert.Equal(t, []string{"__name__"}, r.URL.Query()["label_names[]"])
+				assert.Equal(t, "inmemory", r.URL.Query().Get("count_method"))
+				assert.Equal(t, "0", r.URL.Query().Get("limit"))
+
+				// The request must be accepted by the cardinality API.
+				_, err := cardinality.DecodeLabelValuesRequestFromValues(r.URL.Query())
+				assert.NoError(t, err)
+
+				tenantID, err := tenant.TenantID(r.Context())
+				assert.NoError(t, err)
+				assert.Equal(t, tenantID, r.Header.Get(user.OrgIDHeaderName))
+
+				if testData.cardinalityStatusCode != 0 {
+					return &http.Response{StatusCode: testData.cardinalityStatusCode, Body: io.NopCloser(strings.NewReader("cardinality analysis is disabled"))}, nil
+				}
+
+				series := seriesBySelector[tenantID][r.URL.Query().Get("selector")]
+				body := fmt.Sprintf(`{"series_count_total":1000,"labels":[{"label_name":"__name__","label_values_count":1,"series_count":%d,"cardinality":[]}]}`, series)
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+			})
+
+			var (
+				nextCalled        bool
+				nextDeprioritized bool
+			)
+			next := RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+				nextCalled = true
+				nextDeprioritized = api.IsDeprioritizedQuery(r.Context())
+				return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+			})
+
+			var blocksFinder BlocksFinder
+			if !testData.withoutBlocksFinder {
+				blocksFinder = &mockBlocksFinder{blocksByTenant: blocksByTenant}
+			}
+
+			reg := prometheus.NewPedanticRegistry()
+			metrics := newQueryCostMetrics(reg)
+			rt := newQueryCostRoundTripper(next, downstream, newTestPrometheusCodec(), newEngine(), multiTenantMockLimits{byTenant: limits}, blocksFinder, log.NewNopLogger(), metrics)
+
+			values := url.Values{"query": []string{testData.query}}
+			if IsRangeQuery(testData.path) {
+				values.Set("start", "1700000000")
+				values.Set("end", "1700003600")
+				values.Set("step", "60")
+			} else {
+				values.Set("time", "1700000000")
+			}
+
+			req, err := http.NewRequest(http.MethodGet, testData.path+"?"+values.Encode(), nil)
+			require.NoError(t, err)
+			details, ctx := ContextWithEmptyDetails(user.InjectOrgID(context.Background(), tenant.JoinTenantIDs(tenants)))
+			req = req.WithContext(ctx)
+
+			res, err := rt.RoundTrip(req)
+			if testData.expectedErr != "" {
+				require.Error(t, err)
+				assert.Contains(t, err.Error(), testData.expectedErr)
+				assert.True(t, apierror.IsAPIError(err))
+				assert.False(t, nextCalled)
+			} else {
+				require.NoError(t, err)
+				require.NoError(t, res.Body.Close())
+				assert.True(t, nextCalled)
+				assert.Equal(t, testData.expectedCost, details.EstimatedQueryCost)
+			}
+			assert.Equal(t, testData.expectedDeprioritized, nextDeprioritized)
+
+			if testData.expectedNoCardinalityReqs {
+				assert.Zero(t, cardinalityReqs.Load())
+			}
+
+			assert.Equal(t, float64(testData.expectedFailures), testutil.ToFloat64(metrics.estimationFailures))
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(testData.expectedMetrics), "cortex_query_frontend_expensive_queries_total"))
+		})
+	}
+}
+
+func TestQueryCostRoundTripper_ShouldCacheSeriesCounts(t *testing.T) {
+	cardinalityReqs := atomic.NewInt64(0)
+	downstream := RoundTripFunc(func(*http.Request) (*http.Response, error) {
+		cardinalityReqs.Inc()
+		body := `{"series_count_total":1000,"labels":[{"label_name":"__name__","label_values_count":1,"series_count":10,"cardinality":[]}]}`
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(body))}, nil
+	})
+	next := RoundTripFunc(func(*http.Request) (*http.Response, error) {
+		return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(""))}, nil
+	})
+
+	limits := mockLimits{maxQueryCost: 1000, cardinalityAnalysisEnabled: true}
+	rt := newQueryCostRoundTripper(next, downstream, newTestPrometheusCodec(), newEngine(), limits, nil, log.NewNopLogger(), newQueryCostMetrics(nil)).(*queryCostRoundTripper)
+
+	now := time.Now()
+	rt.now = func() time.Time { return now }
+
+	runQuery := func() {
+		req, err := http.NewRequest(http.MethodGet, "/prometheus/api/v1/query?query=metric_a&time=1700000000", nil)
+		require.NoError(t, err)
+		details, ctx := ContextWithEmptyDetails(user.InjectOrgID(context.Background(), "user-1"))
+
+		res, err := rt.RoundTrip(req.WithContext(ctx))
+		require.NoError(t, err)
+		require.NoError(t, res.Body.Close())
+		assert.Equal(t, uint64(10), details.EstimatedQueryCost)
+	}
+
+	runQuery()
+	runQuery()
+	assert.Equal(t, int64(1), cardinalityReqs.Load())
+
+	// The series count should be looked up again once expired.
+	now = now.Add(queryCostSeriesCountCacheTTL)
+	runQuery()
+	assert.Equal(t, int64(2), cardinalityReqs.Load())
+}
+
+func TestQuerySelectors(t *testing.T) {
+	expr, err := parser.ParseExpr(`sum(rate(metric{job="a",env=~"prod|dev"}[5m])) + on() count({__name__="metric",env=~"prod|dev",job="a"}) or up`)
+	require.NoError(t, err)
+
+	assert.Equal(t, []string{
+		`{__name__="metric",env=~"prod|dev",job="a"}`,
+		`{__name__="up"}`,
+	}, querySelectors(expr))
+}
+
+type mockBlocksFinder struct {
+	mtx            sync.Mutex
+	blocksByTenant map[string]int
+}
+
+func (m *mockBlocksFinder) GetBlocks(_ context.Context, userID string, _, _ int64) (bucketindex.Blocks, map[ulid.ULID]*bucketindex.BlockDeletionMark, error) {
+	m.mtx.Lock()
+	defer m.mtx.Unlock()
+
+	blocks := make(bucketindex.Blocks, 0, m.blocksByTenant[userID])
+	for i := 0; i < m.blocksByTenant[userID]; i++ {
+		blocks = append(blocks, &bucketindex.Block{ID: ulid.MustNew(uint64(i), nil)})
+	}
+	return blocks, nil, nil
+}
@@ -61,11 +61,16 @@ type Config struct {
 	DeprecatedCacheUnalignedRequests bool          `yaml:"cache_unaligned_requests" category:"advanced" doc:"hidden"` // Deprecated: Deprecated in Mimir 2.10.0, remove in Mimir 2.12.0 (https://github.com/grafana/mimir/issues/5253)
 	TargetSeriesPerShard             uint64        `yaml:"query_sharding_target_series_per_shard" category:"advanced"`
 	ShardActiveSeriesQueries         bool          `yaml:"shard_active_series_queries" category:"experimental"`
+	QueryCostEstimationEnabled       bool          `yaml:"query_cost_estimation_enabled" category:"experimental"`
 
 	// CacheKeyGenerator allows to inject a CacheKeyGenerator to use for generating cache keys.
 	// If nil, the querymiddleware package uses a DefaultCacheKeyGenerator with SplitQueriesByInterval.
 	CacheKeyGenerator CacheKeyGenerator `yaml:"-"`
 
+	// BlocksFinder allows to inject a BlocksFinder used to look up the blocks queried from the long-term
+	// storage when estimating the query cost. If nil, the query cost is estimated based on ingesters only.
+	BlocksFinder BlocksFinder `yaml:"-"`
+
 	QueryResultResponseFormat string `yaml:"query_result_response_format"`
 }
 
@@ -79,6 +84,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	f.Uint64Var(&cfg.TargetSeriesPerShard, "query-frontend.query-sharding-target-series-per-shard", 0, "How many series a single sharded partial query should load at most. This is not a strict requirement guaranteed to be honoured by query sharding, but a hint given to the query sharding when the query execution is initially planned. 0 to disable cardinality-based hints.")
 	f.StringVar(&cfg.QueryResultResponseFormat, "query-frontend.query-result-response-format", formatProtobuf, fmt.Sprintf("Format to use when retrieving query results from queriers. Supported values: %s", strings.Join(allFormats, ", ")))
 	f.BoolVar(&cfg.ShardActiveSeriesQueries, "query-frontend.shard-active-series-queries", false, "True to enable sharding of active series queries.")
+	f.BoolVar(&cfg.QueryCostEstimationEnabled, "query-frontend.query-cost-estimation-enabled", false, "True to estimate the cost of range and instant queries before running them, based on the number of in-memory series in ingesters matching the query selectors and the number of queried blocks in the bucket index. The cost is estimated only for the queries of tenants with a query cost limit configured, and the number of series matching each selector is cached for 1 minute. The estimated cost is reported in the query stats and used to enforce the per-tenant query cost limits. Requires cardinality analysis to be enabled for the tenant.")
 	cfg.ResultsCacheConfig.RegisterFlags(f)
 
 	// The query-frontend.cache-unaligned-requests flag has been moved to the limits.go file
@@ -221,6 +227,11 @@ func newQueryTripperware(
 	queryBlockerMiddleware := newQueryBlockerMiddleware(limits, log, registerer)
 	queryStatsMiddleware := newQueryStatsMiddleware(registerer, engine)
 
+	var costMetrics *queryCostMetrics
+	if cfg.QueryCostEstimationEnabled {
+		costMetrics = newQueryCostMetrics(registerer)
+	}
+
 	queryRangeMiddleware := []Middleware{
 		// Track query range statistics. Added first before any subsequent middleware modifies the request.
 		queryStatsMiddleware,
@@ -271,9 +282,19 @@ func newQueryTripperware(
 		// Track query range statistics. Added first before any subsequent middleware modifies the request.
 		queryStatsMiddleware,
 		newLimitsMiddleware(limits, log),
+	}
+
+	// Inject the instant query results cache after the limits middleware, so that the limits
+	// are enforced on queries served from the cache too.
+	if cfg.CacheResults {
+		queryInstantMiddleware = append(queryInstantMiddleware, newInstrumentMiddleware("instant_query_results_cache", metrics), newInstantQueryCacheMiddleware(c, codec, limits, log, registerer))
+	}
+
+	queryInstantMiddleware = append(
+		queryInstantMiddleware,
 		newSplitInstantQueryByIntervalMiddleware(limits, log, engine, registerer),
 		queryBlockerMiddleware,
-	}
+	)
 
 	if cfg.ShardedQueries {
 		// Inject the cardinality estimation middleware after time-based splitting and
@@ -337,11 +358,19 @@ func newQueryTripperware(
 			labels = newLabelsQueryCacheRoundTripper(c, cacheKeyGenerator, limits, labels, log, registerer)
 		}
 
+		// Inject the query cost estimation. The series matching the query are counted issuing
+		// cardinality requests, which are cached like the ones received by the query-frontend.
+		if cfg.QueryCostEstimationEnabled {
+			queryrange = newQueryCostRoundTripper(queryrange, cardinality, codec, engine, limits, cfg.BlocksFinder, log, costMetrics)
+			instant = newQueryCostRoundTripper(instant, cardinality, codec, engine, limits, cfg.BlocksFinder, log, costMetrics)
+		}
+
 		if cfg.ShardActiveSeriesQueries {
 			activeSeries = newShardActiveSeriesMiddleware(activeSeries, limits, log)
 		}
 
-		return RoundTripFunc(func(r *http.Request) (*http.Response, error) {
+		// Honor the query priority class requested by the clients of the tenants allowed to.
+		return newQueryPriorityClassRoundTripper(RoundTripFunc(func(r *http.Request) (*http.Response, error) {
 			switch {
 			case IsRangeQuery(r.URL.Path):
 				return queryrange.RoundTrip(r)
@@ -356,7 +385,7 @@ func newQueryTripperware(
 			default:
 				return next.RoundTrip(r)
 			}
-		})
+		}), limits)
 	}, nil
 }
 
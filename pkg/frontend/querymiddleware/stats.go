@@ -145,6 +145,10 @@ type QueryDetails struct {
 
 	ResultsCacheMissBytes int
 	ResultsCacheHitBytes  int
+
+	// EstimatedQueryCost is the cost of the query estimated before running it.
+	// It's zero-valued if the query cost estimation is disabled or failed.
+	EstimatedQueryCost uint64
 }
 
 type contextKey int
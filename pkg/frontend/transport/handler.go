@@ -301,6 +301,9 @@ func (f *Handler) reportQueryStats(r *http.Request, queryString url.Values, quer
 			"results_cache_hit_bytes", details.ResultsCacheHitBytes,
 			"results_cache_miss_bytes", details.ResultsCacheMissBytes,
 		)
+		if details.EstimatedQueryCost > 0 {
+			logMessage = append(logMessage, "estimated_query_cost", details.EstimatedQueryCost)
+		}
 		if consistency, ok := querierapi.ReadConsistencyFromContext(r.Context()); ok {
 			logMessage = append(logMessage, "read_consistency", consistency)
 		}
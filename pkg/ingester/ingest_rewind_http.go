@@ -0,0 +1,89 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ingester
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/go-kit/log/level"
+
+	"github.com/grafana/mimir/pkg/storage/ingest"
+	"github.com/grafana/mimir/pkg/util"
+)
+
+const (
+	rewindOffsetParam    = "offset"
+	rewindTimestampParam = "timestamp"
+	rewindDryRunParam    = "dry_run"
+)
+
+// IngestRewindHandler consumes again the records of the partition owned by the ingester, from the
+// requested offset or timestamp up until the last consumed record, and replays them into the TSDB.
+// The replay can be restricted to some tenants with the "tenant" parameter, and the "dry_run"
+// parameter only reports how many records would be consumed again.
+func (i *Ingester) IngestRewindHandler(w http.ResponseWriter, r *http.Request) {
+	if i.ingestReader == nil {
+		http.Error(w, "ingest storage is not enabled", http.StatusNotFound)
+		return
+	}
+
+	if err := r.ParseForm(); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	req, err := parseIngestRewindRequest(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	res, err := i.ingestReader.Rewind(r.Context(), req)
+	if errors.Is(err, ingest.ErrRewindInProgress) {
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	}
+	if err != nil {
+		level.Error(i.logger).Log("msg", "failed to rewind the ingest storage partition", "err", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	util.WriteJSONResponse(w, res)
+}
+
+func parseIngestRewindRequest(r *http.Request) (ingest.RewindRequest, error) {
+	req := ingest.RewindRequest{Tenants: r.Form[tenantParam]}
+
+	offset, timestamp := r.FormValue(rewindOffsetParam), r.FormValue(rewindTimestampParam)
+	if (offset == "") == (timestamp == "") {
+		return req, fmt.Errorf("exactly one of the %q and %q parameters must be set", rewindOffsetParam, rewindTimestampParam)
+	}
+
+	if offset != "" {
+		var err error
+		if req.FromOffset, err = strconv.ParseInt(offset, 10, 64); err != nil || req.FromOffset < 0 {
+			return req, fmt.Errorf("invalid %s %q", rewindOffsetParam, offset)
+		}
+	}
+
+	if timestamp != "" {
+		ms, err := util.ParseTime(timestamp)
+		if err != nil {
+			return req, fmt.Errorf("invalid %s %q", rewindTimestampParam, timestamp)
+		}
+		req.FromTime = util.TimeFromMillis(ms)
+	}
+
+	if dryRun := r.FormValue(rewindDryRunParam); dryRun != "" {
+		var err error
+		if req.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			return req, fmt.Errorf("invalid %s %q", rewindDryRunParam, dryRun)
+		}
+	}
+
+	return req, nil
+}
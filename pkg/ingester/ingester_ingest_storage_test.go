@@ -4,8 +4,11 @@ package ingester
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"sync"
 	"testing"
 	"time"
@@ -135,6 +138,107 @@ func TestIngester_QueryStream_IngestStorageReadConsistency(t *testing.T) {
 	}
 }
 
+func TestIngester_IngestRewindHandler(t *testing.T) {
+	var (
+		cfg    = defaultIngesterTestConfig(t)
+		limits = defaultLimitsTestConfig()
+		ctx    = context.Background()
+	)
+
+	overrides, err := validation.NewOverrides(limits, nil)
+	require.NoError(t, err)
+	ingester, _ := createTestIngesterWithIngestStorage(t, &cfg, overrides, prometheus.NewRegistry())
+
+	require.NoError(t, services.StartAndAwaitRunning(ctx, ingester))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, ingester))
+	})
+
+	// Write a series and wait until it has been consumed by the ingester.
+	writer := ingest.NewWriter(cfg.IngestStorageConfig.KafkaConfig, log.NewNopLogger(), nil)
+	require.NoError(t, services.StartAndAwaitRunning(ctx, writer))
+	t.Cleanup(func() {
+		require.NoError(t, services.StopAndAwaitTerminated(ctx, writer))
+	})
+
+	partitionID, err := ingest.IngesterPartition(cfg.IngesterRing.InstanceID)
+	require.NoError(t, err)
+
+	series := mimirpb.PreallocTimeseries{
+		TimeSeries: &mimirpb.TimeSeries{
+			Labels:  mimirpb.FromLabelsToLabelAdapters(labels.FromStrings(labels.MetricName, "series_1")),
+			Samples: []mimirpb.Sample{{TimestampMs: 1000, Value: 10}},
+		},
+	}
+	require.NoError(t, writer.WriteSync(ctx, partitionID, userID, &mimirpb.WriteRequest{Timeseries: []mimirpb.PreallocTimeseries{series}, Source: mimirpb.API}))
+	require.NoError(t, ingester.ingestReader.WaitReadConsistency(ctx))
+
+	tests := map[string]struct {
+		query          string
+		expectedStatus int
+		expectedResult ingest.RewindResult
+	}{
+		"dry run": {
+			query:          "offset=0&dry_run=true",
+			expectedStatus: http.StatusOK,
+			expectedResult: ingest.RewindResult{Partition: partitionID, DryRun: true, StartOffset: 0, EndOffset: 0, RecordsRead: 1, RecordsReplayed: 1},
+		},
+		"replay the records of another tenant": {
+			query:          "offset=0&tenant=another",
+			expectedStatus: http.StatusOK,
+			expectedResult: ingest.RewindResult{Partition: partitionID, StartOffset: 0, EndOffset: 0, RecordsRead: 1, RecordsReplayed: 0},
+		},
+		"replay samples already in the TSDB": {
+			query:          "offset=0&tenant=" + userID,
+			expectedStatus: http.StatusOK,
+			expectedResult: ingest.RewindResult{Partition: partitionID, StartOffset: 0, EndOffset: 0, RecordsRead: 1, RecordsReplayed: 1},
+		},
+		"missing offset and timestamp": {
+			expectedStatus: http.StatusBadRequest,
+		},
+		"both offset and timestamp": {
+			query:          "offset=0&timestamp=1",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid offset": {
+			query:          "offset=-1",
+			expectedStatus: http.StatusBadRequest,
+		},
+		"invalid dry run": {
+			query:          "offset=0&dry_run=maybe",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range tests {
+		t.Run(name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			ingester.IngestRewindHandler(rec, httptest.NewRequest(http.MethodPost, "/ingester/ingest/rewind?"+tc.query, nil))
+			require.Equal(t, tc.expectedStatus, rec.Code, rec.Body.String())
+
+			if tc.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var res ingest.RewindResult
+			require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &res))
+			assert.Equal(t, tc.expectedResult, res)
+		})
+	}
+
+	// The series is still queryable after being replayed.
+	queryRes, _, err := runTestQuery(user.InjectOrgID(ctx, userID), t, ingester, labels.MatchEqual, labels.MetricName, "series_1")
+	require.NoError(t, err)
+	require.Len(t, queryRes, 1)
+	assert.Len(t, queryRes[0].Values, 1)
+
+	t.Run("ingest storage disabled", func(t *testing.T) {
+		rec := httptest.NewRecorder()
+		(&Ingester{}).IngestRewindHandler(rec, httptest.NewRequest(http.MethodPost, "/ingester/ingest/rewind?offset=0", nil))
+		assert.Equal(t, http.StatusNotFound, rec.Code)
+	})
+}
+
 func createTestIngesterWithIngestStorage(t testing.TB, ingesterCfg *Config, overrides *validation.Overrides, reg prometheus.Registerer) (*Ingester, *kfake.Cluster) {
 	var (
 		dataDir   = t.TempDir()
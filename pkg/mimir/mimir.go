@@ -414,6 +414,22 @@ func (c *Config) validateFilesystemPaths(logger log.Logger) error {
 			cfgValue:   c.BlocksStorage.BucketStore.SyncDir,
 			checkValue: c.BlocksStorage.BucketStore.SyncDir,
 		})
+
+		if c.BlocksStorage.BucketStore.IndexCache.Backend == tsdb.IndexCacheBackendDisk {
+			paths = append(paths, pathConfig{
+				name:       "bucket store index cache directory",
+				cfgValue:   c.BlocksStorage.BucketStore.IndexCache.Disk.Dir,
+				checkValue: c.BlocksStorage.BucketStore.IndexCache.Disk.Dir,
+			})
+		}
+
+		if c.BlocksStorage.BucketStore.ChunksCache.Disk.Enabled {
+			paths = append(paths, pathConfig{
+				name:       "bucket store chunks cache directory",
+				cfgValue:   c.BlocksStorage.BucketStore.ChunksCache.Disk.Dir,
+				checkValue: c.BlocksStorage.BucketStore.ChunksCache.Disk.Dir,
+			})
+		}
 	}
 
 	// Compactor.
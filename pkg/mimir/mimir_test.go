@@ -587,6 +587,25 @@ func TestConfig_validateFilesystemPaths(t *testing.T) {
 				cfg.Compactor.DataDir = "/path/to/data/compactor"
 			},
 		},
+		"should fail if store-gateway sync directory and on-disk index cache directory overlap": {
+			setup: func(cfg *Config) {
+				cfg.Target = flagext.StringSliceCSV{StoreGateway}
+				cfg.BlocksStorage.BucketStore.SyncDir = "/path/to/data"
+				cfg.BlocksStorage.BucketStore.IndexCache.Backend = tsdb.IndexCacheBackendDisk
+				cfg.BlocksStorage.BucketStore.IndexCache.Disk.Dir = "/path/to/data/index-cache"
+			},
+			expectedErr: `the configured bucket store sync directory "/path/to/data" cannot overlap with the configured bucket store index cache directory "/path/to/data/index-cache"`,
+		},
+		"should fail if store-gateway on-disk index cache and chunks cache directories overlap": {
+			setup: func(cfg *Config) {
+				cfg.Target = flagext.StringSliceCSV{StoreGateway}
+				cfg.BlocksStorage.BucketStore.IndexCache.Backend = tsdb.IndexCacheBackendDisk
+				cfg.BlocksStorage.BucketStore.IndexCache.Disk.Dir = "/path/to/cache"
+				cfg.BlocksStorage.BucketStore.ChunksCache.Disk.Enabled = true
+				cfg.BlocksStorage.BucketStore.ChunksCache.Disk.Dir = "/path/to/cache/chunks"
+			},
+			expectedErr: `the configured bucket store index cache directory "/path/to/cache" cannot overlap with the configured bucket store chunks cache directory "/path/to/cache/chunks"`,
+		},
 		"should fail if tsdb directory and blocks storage filesystem directory overlap": {
 			setup: func(cfg *Config) {
 				cfg.Target = flagext.StringSliceCSV{Ingester}
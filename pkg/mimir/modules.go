@@ -50,6 +50,7 @@ import (
 	"github.com/grafana/mimir/pkg/ruler"
 	"github.com/grafana/mimir/pkg/scheduler"
 	"github.com/grafana/mimir/pkg/storage/bucket"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/storegateway"
 	"github.com/grafana/mimir/pkg/storegateway/indexheader"
 	"github.com/grafana/mimir/pkg/usagestats"
@@ -207,6 +208,11 @@ func (t *Mimir) initVault() (services.Service, error) {
 	t.Cfg.BlocksStorage.BucketStore.MetadataCache.BackendConfig.Redis.TLS.Reader = t.Vault
 	t.Cfg.Frontend.QueryMiddleware.ResultsCacheConfig.BackendConfig.Redis.TLS.Reader = t.Vault
 
+	// Update Configs - Object storage encryption
+	t.Cfg.BlocksStorage.Bucket.Encryption.Reader = t.Vault
+	t.Cfg.RulerStorage.Encryption.Reader = t.Vault
+	t.Cfg.AlertmanagerStorage.Encryption.Reader = t.Vault
+
 	// Update Configs - GRPC Clients
 	t.Cfg.IngesterClient.GRPCClientConfig.TLS.Reader = t.Vault
 	t.Cfg.Worker.QueryFrontendGRPCClientConfig.TLS.Reader = t.Vault
@@ -564,6 +570,11 @@ func (t *Mimir) initQuerier() (serv services.Service, err error) {
 
 	// Create an internal HTTP handler that is configured with the Prometheus API routes and points
 	// to a Prometheus API struct instantiated with the Mimir Queryable.
+	var blocksFinder querier.BlocksFinder
+	if storeQueryable, ok := t.StoreQueryable.(*querier.BlocksStoreQueryable); ok {
+		blocksFinder = storeQueryable.BlocksFinder()
+	}
+
 	internalQuerierRouter := api.NewQuerierHandler(
 		t.Cfg.API,
 		t.QuerierQueryable,
@@ -571,6 +582,7 @@ func (t *Mimir) initQuerier() (serv services.Service, err error) {
 		t.MetadataSupplier,
 		t.QuerierEngine,
 		t.Distributor,
+		blocksFinder,
 		t.Registerer,
 		util_log.Logger,
 		t.Overrides,
@@ -690,6 +702,29 @@ func (t *Mimir) initQueryFrontendTripperware() (serv services.Service, err error
 
 	engineOpts, engineExperimentalFunctionsEnabled := engine.NewPromQLEngineOptions(t.Cfg.Querier.EngineConfig, t.ActivityTracker, util_log.Logger, promqlEngineRegisterer)
 
+	// The query cost estimation looks up the queried blocks in the bucket index. The bucket index may be
+	// encrypted, so the encryption key is read from Vault, if enabled, like in the other components.
+	if t.Cfg.Frontend.QueryMiddleware.QueryCostEstimationEnabled {
+		bucketClient, err := bucket.NewClient(context.Background(), t.Cfg.BlocksStorage.Bucket, "query-frontend", util_log.Logger, t.Registerer)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to create bucket client")
+		}
+
+		blocksFinder := querier.NewBucketIndexBlocksFinder(querier.BucketIndexBlocksFinderConfig{
+			IndexLoader: bucketindex.LoaderConfig{
+				CheckInterval:         time.Minute,
+				UpdateOnStaleInterval: t.Cfg.BlocksStorage.BucketStore.SyncInterval,
+				UpdateOnErrorInterval: t.Cfg.BlocksStorage.BucketStore.BucketIndex.UpdateOnErrorInterval,
+				IdleTimeout:           t.Cfg.BlocksStorage.BucketStore.BucketIndex.IdleTimeout,
+			},
+			MaxStalePeriod:           t.Cfg.BlocksStorage.BucketStore.BucketIndex.MaxStalePeriod,
+			IgnoreDeletionMarksDelay: t.Cfg.BlocksStorage.BucketStore.IgnoreDeletionMarksDelay,
+		}, bucketClient, t.Overrides, util_log.Logger, prometheus.WrapRegistererWith(prometheus.Labels{"component": "query-frontend"}, t.Registerer))
+
+		t.Cfg.Frontend.QueryMiddleware.BlocksFinder = blocksFinder
+		serv = blocksFinder
+	}
+
 	tripperware, err := querymiddleware.NewTripperware(
 		t.Cfg.Frontend.QueryMiddleware,
 		util_log.Logger,
@@ -705,7 +740,7 @@ func (t *Mimir) initQueryFrontendTripperware() (serv services.Service, err error
 	}
 
 	t.QueryFrontendTripperware = tripperware
-	return nil, nil
+	return serv, nil
 }
 
 func (t *Mimir) initQueryFrontend() (serv services.Service, err error) {
@@ -1041,6 +1076,8 @@ func (t *Mimir) setupModuleManager() error {
 	deps := map[string][]string{
 		Server:                   {ActivityTracker, SanityCheck, UsageStats},
 		API:                      {Server},
+		SanityCheck:              {Vault},
+		UsageStats:               {Vault},
 		MemberlistKV:             {API, Vault},
 		RuntimeConfig:            {API},
 		IngesterRing:             {API, RuntimeConfig, MemberlistKV, Vault},
@@ -1053,12 +1090,12 @@ func (t *Mimir) setupModuleManager() error {
 		Flusher:                  {Overrides, API},
 		Queryable:                {Overrides, DistributorService, IngesterRing, API, StoreQueryable, MemberlistKV},
 		Querier:                  {TenantFederation, Vault},
-		StoreQueryable:           {Overrides, MemberlistKV},
-		QueryFrontendTripperware: {API, Overrides},
+		StoreQueryable:           {Overrides, MemberlistKV, Vault},
+		QueryFrontendTripperware: {API, Overrides, Vault},
 		QueryFrontend:            {QueryFrontendTripperware, MemberlistKV, Vault},
 		QueryScheduler:           {API, Overrides, MemberlistKV, Vault},
 		Ruler:                    {DistributorService, StoreQueryable, RulerStorage, Vault},
-		RulerStorage:             {Overrides},
+		RulerStorage:             {Overrides, Vault},
 		AlertManager:             {API, MemberlistKV, Overrides, Vault},
 		Compactor:                {API, MemberlistKV, Overrides, Vault},
 		StoreGateway:             {API, Overrides, MemberlistKV, Vault},
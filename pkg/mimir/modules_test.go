@@ -338,6 +338,11 @@ func TestInitVault(t *testing.T) {
 	require.NotNil(t, mimir.Cfg.BlocksStorage.BucketStore.MetadataCache.BackendConfig.Redis.TLS.Reader)
 	require.NotNil(t, mimir.Cfg.Frontend.QueryMiddleware.ResultsCacheConfig.BackendConfig.Redis.TLS.Reader)
 
+	// Check object storage encryption
+	require.NotNil(t, mimir.Cfg.BlocksStorage.Bucket.Encryption.Reader)
+	require.NotNil(t, mimir.Cfg.RulerStorage.Encryption.Reader)
+	require.NotNil(t, mimir.Cfg.AlertmanagerStorage.Encryption.Reader)
+
 	// Check GRPC Clients
 	require.NotNil(t, mimir.Cfg.IngesterClient.GRPCClientConfig.TLS.Reader)
 	require.NotNil(t, mimir.Cfg.Worker.QueryFrontendGRPCClientConfig.TLS.Reader)
@@ -0,0 +1,511 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimirpb
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/prometheus/common/model"
+)
+
+// Field numbers of the Prometheus remote-write 2.0 messages (io.prometheus.write.v2).
+// See https://prometheus.io/docs/specs/remote_write_spec_2_0/.
+const (
+	rw2RequestSymbols    = 4
+	rw2RequestTimeseries = 5
+
+	rw2SeriesLabelsRefs       = 1
+	rw2SeriesSamples          = 2
+	rw2SeriesHistograms       = 3
+	rw2SeriesExemplars        = 4
+	rw2SeriesMetadata         = 5
+	rw2SeriesCreatedTimestamp = 6
+
+	rw2SampleValue     = 1
+	rw2SampleTimestamp = 2
+
+	rw2ExemplarLabelsRefs = 1
+	rw2ExemplarValue      = 2
+	rw2ExemplarTimestamp  = 3
+
+	rw2MetadataType    = 1
+	rw2MetadataHelpRef = 3
+	rw2MetadataUnitRef = 4
+)
+
+const (
+	wireVarint  = 0
+	wireFixed64 = 1
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+// PreallocWriteRequestRW2 decodes a Prometheus remote-write 2.0 request straight into the wrapped PreallocWriteRequest.
+// Label references are resolved against the request symbols table without copying strings: all labels referencing
+// the same symbol share the same yoloString backed by the input buffer, which must therefore be retained until the
+// request is not used anymore, like for the remote-write 1.0 request.
+type PreallocWriteRequestRW2 struct {
+	*PreallocWriteRequest
+
+	// CreatedTimestampZeroIngestion enables ingesting a zero sample at the created timestamp of each series,
+	// if earlier than the first sample of the series.
+	CreatedTimestampZeroIngestion bool
+
+	symbols    []string
+	labelsRefs []uint32
+}
+
+// Unmarshal implements proto.Unmarshaler.
+func (p *PreallocWriteRequestRW2) Unmarshal(dAtA []byte) error {
+	p.Timeseries = PreallocTimeseriesSliceFromPool()
+	p.symbols = p.symbols[:0]
+
+	// Symbols can be encoded after the series referencing them, so they're read first.
+	d := rw2Decoder{data: dAtA}
+	for !d.done() {
+		fieldNum, wireType, err := d.field()
+		if err != nil {
+			return err
+		}
+		if fieldNum != rw2RequestSymbols {
+			if err := d.skip(wireType); err != nil {
+				return err
+			}
+			continue
+		}
+		symbol, err := d.bytes(wireType)
+		if err != nil {
+			return err
+		}
+		p.symbols = append(p.symbols, yoloString(symbol))
+	}
+
+	if len(p.symbols) > 0 && p.symbols[0] != "" {
+		return fmt.Errorf("proto: remote-write 2.0 request: first symbol must be an empty string")
+	}
+
+	d = rw2Decoder{data: dAtA}
+	for !d.done() {
+		fieldNum, wireType, err := d.field()
+		if err != nil {
+			return err
+		}
+		if fieldNum != rw2RequestTimeseries {
+			if err := d.skip(wireType); err != nil {
+				return err
+			}
+			continue
+		}
+		series, err := d.bytes(wireType)
+		if err != nil {
+			return err
+		}
+		if err := p.unmarshalTimeseries(series); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (p *PreallocWriteRequestRW2) unmarshalTimeseries(dAtA []byte) error {
+	var (
+		ts               = TimeseriesFromPool()
+		labelsRefs       = p.labelsRefs[:0]
+		metadata         *MetricMetadata
+		createdTimestamp int64
+	)
+
+	// Append the series before decoding it, so that it is returned to the pool on cleanup even on failure.
+	p.Timeseries = append(p.Timeseries, PreallocTimeseries{TimeSeries: ts})
+
+	d := rw2Decoder{data: dAtA}
+	for !d.done() {
+		fieldNum, wireType, err := d.field()
+		if err != nil {
+			return err
+		}
+
+		switch fieldNum {
+		case rw2SeriesLabelsRefs:
+			if labelsRefs, err = d.uint32s(wireType, labelsRefs); err != nil {
+				return err
+			}
+		case rw2SeriesSamples:
+			msg, err := d.bytes(wireType)
+			if err != nil {
+				return err
+			}
+			sample, err := unmarshalRW2Sample(msg)
+			if err != nil {
+				return err
+			}
+			ts.Samples = append(ts.Samples, sample)
+		case rw2SeriesHistograms:
+			msg, err := d.bytes(wireType)
+			if err != nil {
+				return err
+			}
+			// The remote-write 2.0 histogram is wire compatible with our own.
+			var h Histogram
+			if err := h.Unmarshal(msg); err != nil {
+				return err
+			}
+			ts.Histograms = append(ts.Histograms, h)
+		case rw2SeriesExemplars:
+			msg, err := d.bytes(wireType)
+			if err != nil {
+				return err
+			}
+			exemplar, err := p.unmarshalExemplar(msg)
+			if err != nil {
+				return err
+			}
+			ts.Exemplars = append(ts.Exemplars, exemplar)
+		case rw2SeriesMetadata:
+			msg, err := d.bytes(wireType)
+			if err != nil {
+				return err
+			}
+			if metadata, err = p.unmarshalMetadata(msg); err != nil {
+				return err
+			}
+		case rw2SeriesCreatedTimestamp:
+			v, err := d.varint(wireType)
+			if err != nil {
+				return err
+			}
+			createdTimestamp = int64(v)
+		default:
+			if err := d.skip(wireType); err != nil {
+				return err
+			}
+		}
+	}
+
+	var err error
+	p.labelsRefs = labelsRefs
+	if ts.Labels, err = p.appendLabels(ts.Labels, labelsRefs); err != nil {
+		return err
+	}
+
+	if metadata != nil {
+		for _, l := range ts.Labels {
+			if l.Name == model.MetricNameLabel {
+				metadata.MetricFamilyName = l.Value
+				break
+			}
+		}
+		p.Metadata = append(p.Metadata, metadata)
+	}
+
+	if p.CreatedTimestampZeroIngestion && createdTimestamp > 0 {
+		ingestCreatedTimestampZero(ts, createdTimestamp)
+	}
+
+	return nil
+}
+
+// appendLabels appends to dst the labels referenced by refs, which is a list of name and value symbol references pairs.
+func (p *PreallocWriteRequestRW2) appendLabels(dst []LabelAdapter, refs []uint32) ([]LabelAdapter, error) {
+	if len(refs)%2 != 0 {
+		return nil, fmt.Errorf("proto: remote-write 2.0 request: odd number of label references")
+	}
+
+	for i := 0; i < len(refs); i += 2 {
+		name, err := p.symbol(refs[i])
+		if err != nil {
+			return nil, err
+		}
+		value, err := p.symbol(refs[i+1])
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, LabelAdapter{Name: name, Value: value})
+	}
+	return dst, nil
+}
+
+func (p *PreallocWriteRequestRW2) symbol(ref uint32) (string, error) {
+	if int(ref) >= len(p.symbols) {
+		return "", fmt.Errorf("proto: remote-write 2.0 request: symbol reference %d out of range, symbols table has %d entries", ref, len(p.symbols))
+	}
+	return p.symbols[ref], nil
+}
+
+func (p *PreallocWriteRequestRW2) unmarshalExemplar(dAtA []byte) (Exemplar, error) {
+	var (
+		e          Exemplar
+		labelsRefs []uint32
+	)
+
+	d := rw2Decoder{data: dAtA}
+	for !d.done() {
+		fieldNum, wireType, err := d.field()
+		if err != nil {
+			return e, err
+		}
+
+		switch fieldNum {
+		case rw2ExemplarLabelsRefs:
+			if labelsRefs, err = d.uint32s(wireType, labelsRefs); err != nil {
+				return e, err
+			}
+		case rw2ExemplarValue:
+			if e.Value, err = d.double(wireType); err != nil {
+				return e, err
+			}
+		case rw2ExemplarTimestamp:
+			v, err := d.varint(wireType)
+			if err != nil {
+				return e, err
+			}
+			e.TimestampMs = int64(v)
+		default:
+			if err := d.skip(wireType); err != nil {
+				return e, err
+			}
+		}
+	}
+
+	var err error
+	e.Labels, err = p.appendLabels(nil, labelsRefs)
+	return e, err
+}
+
+func (p *PreallocWriteRequestRW2) unmarshalMetadata(dAtA []byte) (*MetricMetadata, error) {
+	m := &MetricMetadata{}
+
+	d := rw2Decoder{data: dAtA}
+	for !d.done() {
+		fieldNum, wireType, err := d.field()
+		if err != nil {
+			return nil, err
+		}
+
+		switch fieldNum {
+		case rw2MetadataType:
+			v, err := d.varint(wireType)
+			if err != nil {
+				return nil, err
+			}
+			// The remote-write 2.0 metric types have the same values as ours.
+			m.Type = MetricMetadata_MetricType(v)
+		case rw2MetadataHelpRef, rw2MetadataUnitRef:
+			v, err := d.varint(wireType)
+			if err != nil {
+				return nil, err
+			}
+			s, err := p.symbol(uint32(v))
+			if err != nil {
+				return nil, err
+			}
+			if fieldNum == rw2MetadataHelpRef {
+				m.Help = s
+			} else {
+				m.Unit = s
+			}
+		default:
+			if err := d.skip(wireType); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if m.Type == UNKNOWN && m.Help == "" && m.Unit == "" {
+		return nil, nil
+	}
+	return m, nil
+}
+
+func unmarshalRW2Sample(dAtA []byte) (Sample, error) {
+	var s Sample
+
+	d := rw2Decoder{data: dAtA}
+	for !d.done() {
+		fieldNum, wireType, err := d.field()
+		if err != nil {
+			return s, err
+		}
+
+		switch fieldNum {
+		case rw2SampleValue:
+			if s.Value, err = d.double(wireType); err != nil {
+				return s, err
+			}
+		case rw2SampleTimestamp:
+			v, err := d.varint(wireType)
+			if err != nil {
+				return s, err
+			}
+			s.TimestampMs = int64(v)
+		default:
+			if err := d.skip(wireType); err != nil {
+				return s, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// ingestCreatedTimestampZero prepends a zero sample (or histogram) at the created timestamp to the series,
+// if the created timestamp is earlier than the first sample (or histogram) of the series.
+func ingestCreatedTimestampZero(ts *TimeSeries, createdTimestamp int64) {
+	if len(ts.Samples) > 0 && createdTimestamp < ts.Samples[0].TimestampMs {
+		ts.Samples = append(ts.Samples, Sample{})
+		copy(ts.Samples[1:], ts.Samples)
+		ts.Samples[0] = Sample{TimestampMs: createdTimestamp}
+	}
+
+	if len(ts.Histograms) > 0 && createdTimestamp < ts.Histograms[0].Timestamp {
+		first := ts.Histograms[0]
+		zero := Histogram{
+			Schema:        first.Schema,
+			ZeroThreshold: first.ZeroThreshold,
+			Timestamp:     createdTimestamp,
+		}
+		if first.IsFloatHistogram() {
+			zero.Count = &Histogram_CountFloat{}
+			zero.ZeroCount = &Histogram_ZeroCountFloat{}
+		} else {
+			zero.Count = &Histogram_CountInt{}
+			zero.ZeroCount = &Histogram_ZeroCountInt{}
+		}
+
+		ts.Histograms = append(ts.Histograms, Histogram{})
+		copy(ts.Histograms[1:], ts.Histograms)
+		ts.Histograms[0] = zero
+	}
+}
+
+// rw2Decoder is a minimal protobuf wire format decoder.
+type rw2Decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *rw2Decoder) done() bool {
+	return d.pos >= len(d.data)
+}
+
+func (d *rw2Decoder) field() (int, int, error) {
+	v, err := d.rawVarint()
+	if err != nil {
+		return 0, 0, err
+	}
+	fieldNum, wireType := int(v>>3), int(v&0x7)
+	if fieldNum <= 0 {
+		return 0, 0, fmt.Errorf("proto: illegal tag %d (wire type %d)", fieldNum, wireType)
+	}
+	return fieldNum, wireType, nil
+}
+
+func (d *rw2Decoder) rawVarint() (uint64, error) {
+	var v uint64
+	for shift := uint(0); ; shift += 7 {
+		if shift >= 64 {
+			return 0, ErrIntOverflowMimir
+		}
+		if d.pos >= len(d.data) {
+			return 0, io.ErrUnexpectedEOF
+		}
+		b := d.data[d.pos]
+		d.pos++
+		v |= uint64(b&0x7F) << shift
+		if b < 0x80 {
+			return v, nil
+		}
+	}
+}
+
+func (d *rw2Decoder) varint(wireType int) (uint64, error) {
+	if wireType != wireVarint {
+		return 0, fmt.Errorf("proto: wrong wireType = %d for varint field", wireType)
+	}
+	return d.rawVarint()
+}
+
+func (d *rw2Decoder) double(wireType int) (float64, error) {
+	if wireType != wireFixed64 {
+		return 0, fmt.Errorf("proto: wrong wireType = %d for double field", wireType)
+	}
+	if d.pos+8 > len(d.data) {
+		return 0, io.ErrUnexpectedEOF
+	}
+	v := math.Float64frombits(binary.LittleEndian.Uint64(d.data[d.pos:]))
+	d.pos += 8
+	return v, nil
+}
+
+func (d *rw2Decoder) bytes(wireType int) ([]byte, error) {
+	if wireType != wireBytes {
+		return nil, fmt.Errorf("proto: wrong wireType = %d for length-delimited field", wireType)
+	}
+	l, err := d.rawVarint()
+	if err != nil {
+		return nil, err
+	}
+	end := d.pos + int(l)
+	if int(l) < 0 || end < d.pos {
+		return nil, ErrInvalidLengthMimir
+	}
+	if end > len(d.data) {
+		return nil, io.ErrUnexpectedEOF
+	}
+	b := d.data[d.pos:end]
+	d.pos = end
+	return b, nil
+}
+
+// uint32s appends to dst the values of a repeated uint32 field, which can be either packed or not.
+func (d *rw2Decoder) uint32s(wireType int, dst []uint32) ([]uint32, error) {
+	if wireType == wireVarint {
+		v, err := d.rawVarint()
+		if err != nil {
+			return nil, err
+		}
+		return append(dst, uint32(v)), nil
+	}
+
+	packed, err := d.bytes(wireType)
+	if err != nil {
+		return nil, err
+	}
+	pd := rw2Decoder{data: packed}
+	for !pd.done() {
+		v, err := pd.rawVarint()
+		if err != nil {
+			return nil, err
+		}
+		dst = append(dst, uint32(v))
+	}
+	return dst, nil
+}
+
+func (d *rw2Decoder) skip(wireType int) error {
+	switch wireType {
+	case wireVarint:
+		_, err := d.rawVarint()
+		return err
+	case wireFixed64:
+		if d.pos+8 > len(d.data) {
+			return io.ErrUnexpectedEOF
+		}
+		d.pos += 8
+		return nil
+	case wireBytes:
+		_, err := d.bytes(wireType)
+		return err
+	case wireFixed32:
+		if d.pos+4 > len(d.data) {
+			return io.ErrUnexpectedEOF
+		}
+		d.pos += 4
+		return nil
+	default:
+		return fmt.Errorf("proto: illegal wireType %d", wireType)
+	}
+}
@@ -0,0 +1,173 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package mimirpb
+
+import (
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+func TestPreallocWriteRequestRW2_Unmarshal(t *testing.T) {
+	histogram := Histogram{
+		Count:          &Histogram_CountInt{CountInt: 3},
+		Sum:            10,
+		Schema:         1,
+		ZeroThreshold:  0.001,
+		ZeroCount:      &Histogram_ZeroCountInt{ZeroCountInt: 1},
+		PositiveSpans:  []BucketSpan{{Offset: 0, Length: 2}},
+		PositiveDeltas: []int64{1, 0},
+		Timestamp:      2000,
+	}
+	histogramBytes, err := histogram.Marshal()
+	require.NoError(t, err)
+
+	series1 := appendPackedRefs(nil, rw2SeriesLabelsRefs, 1, 2, 3, 4)
+	series1 = appendRW2Sample(series1, 1000, 1.5)
+	series1 = appendRW2Sample(series1, 2000, 2.5)
+	series1 = protowire.AppendTag(series1, rw2SeriesExemplars, protowire.BytesType)
+	series1 = protowire.AppendBytes(series1, appendRW2Exemplar(nil, 1500, 7, 5, 6))
+	series1 = protowire.AppendTag(series1, rw2SeriesMetadata, protowire.BytesType)
+	series1 = protowire.AppendBytes(series1, appendRW2Metadata(nil, COUNTER, 7, 8))
+	series1 = protowire.AppendTag(series1, rw2SeriesCreatedTimestamp, protowire.VarintType)
+	series1 = protowire.AppendVarint(series1, 500)
+
+	// Unpacked label references.
+	var series2 []byte
+	for _, ref := range []uint64{1, 9, 3, 4} {
+		series2 = protowire.AppendTag(series2, rw2SeriesLabelsRefs, protowire.VarintType)
+		series2 = protowire.AppendVarint(series2, ref)
+	}
+	series2 = protowire.AppendTag(series2, rw2SeriesHistograms, protowire.BytesType)
+	series2 = protowire.AppendBytes(series2, histogramBytes)
+	series2 = protowire.AppendTag(series2, rw2SeriesCreatedTimestamp, protowire.VarintType)
+	series2 = protowire.AppendVarint(series2, 500)
+
+	// Symbols are intentionally encoded after the series referencing them.
+	var body []byte
+	body = appendRW2Series(body, series1)
+	body = appendRW2Series(body, series2)
+	body = appendRW2Symbols(body, "", "__name__", "http_requests_total", "job", "test", "trace_id", "abc", "Total requests.", "requests", "request_duration_seconds")
+
+	t.Run("should decode the request", func(t *testing.T) {
+		req := &PreallocWriteRequestRW2{PreallocWriteRequest: &PreallocWriteRequest{}}
+		require.NoError(t, req.Unmarshal(body))
+		t.Cleanup(func() { ReuseSlice(req.Timeseries) })
+
+		require.Len(t, req.Timeseries, 2)
+
+		assert.Equal(t, []LabelAdapter{{Name: "__name__", Value: "http_requests_total"}, {Name: "job", Value: "test"}}, req.Timeseries[0].Labels)
+		assert.Equal(t, []Sample{{TimestampMs: 1000, Value: 1.5}, {TimestampMs: 2000, Value: 2.5}}, req.Timeseries[0].Samples)
+		assert.Equal(t, []Exemplar{{Labels: []LabelAdapter{{Name: "trace_id", Value: "abc"}}, Value: 7, TimestampMs: 1500}}, req.Timeseries[0].Exemplars)
+
+		assert.Equal(t, []LabelAdapter{{Name: "__name__", Value: "request_duration_seconds"}, {Name: "job", Value: "test"}}, req.Timeseries[1].Labels)
+		assert.Empty(t, req.Timeseries[1].Samples)
+		assert.Equal(t, []Histogram{histogram}, req.Timeseries[1].Histograms)
+
+		assert.Equal(t, []*MetricMetadata{{Type: COUNTER, MetricFamilyName: "http_requests_total", Help: "Total requests.", Unit: "requests"}}, req.Metadata)
+	})
+
+	t.Run("should ingest a zero sample at the created timestamp if enabled", func(t *testing.T) {
+		req := &PreallocWriteRequestRW2{PreallocWriteRequest: &PreallocWriteRequest{}, CreatedTimestampZeroIngestion: true}
+		require.NoError(t, req.Unmarshal(body))
+		t.Cleanup(func() { ReuseSlice(req.Timeseries) })
+
+		require.Len(t, req.Timeseries, 2)
+		assert.Equal(t, []Sample{{TimestampMs: 500, Value: 0}, {TimestampMs: 1000, Value: 1.5}, {TimestampMs: 2000, Value: 2.5}}, req.Timeseries[0].Samples)
+
+		require.Len(t, req.Timeseries[1].Histograms, 2)
+		zero := req.Timeseries[1].Histograms[0]
+		assert.Equal(t, int64(500), zero.Timestamp)
+		assert.Equal(t, uint64(0), zero.GetCountInt())
+		assert.Equal(t, histogram.Schema, zero.Schema)
+		assert.Equal(t, histogram, req.Timeseries[1].Histograms[1])
+	})
+}
+
+func TestPreallocWriteRequestRW2_Unmarshal_ShouldFailOnInvalidInput(t *testing.T) {
+	tests := map[string]struct {
+		body          []byte
+		expectedError string
+	}{
+		"first symbol not empty": {
+			body:          appendRW2Symbols(nil, "__name__", "up"),
+			expectedError: "first symbol must be an empty string",
+		},
+		"symbol reference out of range": {
+			body:          appendRW2Symbols(appendRW2Series(nil, appendPackedRefs(nil, rw2SeriesLabelsRefs, 1, 5)), "", "__name__", "up"),
+			expectedError: "symbol reference 5 out of range",
+		},
+		"odd number of label references": {
+			body:          appendRW2Symbols(appendRW2Series(nil, appendPackedRefs(nil, rw2SeriesLabelsRefs, 1, 2, 1)), "", "__name__", "up"),
+			expectedError: "odd number of label references",
+		},
+		"truncated input": {
+			body:          appendRW2Series(nil, appendPackedRefs(nil, rw2SeriesLabelsRefs, 1, 2))[:4],
+			expectedError: "unexpected EOF",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			req := &PreallocWriteRequestRW2{PreallocWriteRequest: &PreallocWriteRequest{}}
+			err := req.Unmarshal(testData.body)
+			ReuseSlice(req.Timeseries)
+
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), testData.expectedError)
+		})
+	}
+}
+
+func appendRW2Symbols(b []byte, symbols ...string) []byte {
+	for _, s := range symbols {
+		b = protowire.AppendTag(b, rw2RequestSymbols, protowire.BytesType)
+		b = protowire.AppendString(b, s)
+	}
+	return b
+}
+
+func appendRW2Series(b, series []byte) []byte {
+	b = protowire.AppendTag(b, rw2RequestTimeseries, protowire.BytesType)
+	return protowire.AppendBytes(b, series)
+}
+
+func appendPackedRefs(b []byte, fieldNum protowire.Number, refs ...uint64) []byte {
+	var packed []byte
+	for _, ref := range refs {
+		packed = protowire.AppendVarint(packed, ref)
+	}
+	b = protowire.AppendTag(b, fieldNum, protowire.BytesType)
+	return protowire.AppendBytes(b, packed)
+}
+
+func appendRW2Sample(b []byte, ts int64, value float64) []byte {
+	var sample []byte
+	sample = protowire.AppendTag(sample, rw2SampleValue, protowire.Fixed64Type)
+	sample = protowire.AppendFixed64(sample, math.Float64bits(value))
+	sample = protowire.AppendTag(sample, rw2SampleTimestamp, protowire.VarintType)
+	sample = protowire.AppendVarint(sample, uint64(ts))
+
+	b = protowire.AppendTag(b, rw2SeriesSamples, protowire.BytesType)
+	return protowire.AppendBytes(b, sample)
+}
+
+func appendRW2Exemplar(b []byte, ts int64, value float64, labelsRefs ...uint64) []byte {
+	b = appendPackedRefs(b, rw2ExemplarLabelsRefs, labelsRefs...)
+	b = protowire.AppendTag(b, rw2ExemplarValue, protowire.Fixed64Type)
+	b = protowire.AppendFixed64(b, math.Float64bits(value))
+	b = protowire.AppendTag(b, rw2ExemplarTimestamp, protowire.VarintType)
+	return protowire.AppendVarint(b, uint64(ts))
+}
+
+func appendRW2Metadata(b []byte, metricType MetricMetadata_MetricType, helpRef, unitRef uint64) []byte {
+	b = protowire.AppendTag(b, rw2MetadataType, protowire.VarintType)
+	b = protowire.AppendVarint(b, uint64(metricType))
+	b = protowire.AppendTag(b, rw2MetadataHelpRef, protowire.VarintType)
+	b = protowire.AppendVarint(b, helpRef)
+	b = protowire.AppendTag(b, rw2MetadataUnitRef, protowire.VarintType)
+	return protowire.AppendVarint(b, unitRef)
+}
@@ -9,13 +9,17 @@ import (
 	"bytes"
 	"context"
 	"io"
+	"net/url"
 
 	"github.com/pkg/errors"
 	log "github.com/sirupsen/logrus"
 	"gopkg.in/yaml.v3"
 )
 
-const alertmanagerAPIPath = "/api/v1/alerts"
+const (
+	alertmanagerAPIPath      = "/api/v1/alerts"
+	alertmanagerStateAPIPath = "/multitenant_alertmanager/state"
+)
 
 type configCompat struct {
 	TemplateFiles      map[string]string `yaml:"template_files"`
@@ -80,3 +84,32 @@ func (r *MimirClient) GetAlertmanagerConfig(ctx context.Context) (string, map[st
 
 	return compat.AlertmanagerConfig, compat.TemplateFiles, nil
 }
+
+// GetAlertmanagerState retrieves the Alertmanager state (silences and notification log) of the tenant,
+// encoded in the input format.
+func (r *MimirClient) GetAlertmanagerState(ctx context.Context, format string) ([]byte, error) {
+	res, err := r.doRequest(ctx, alertmanagerStatePath(format), "GET", nil, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	return io.ReadAll(res.Body)
+}
+
+// SetAlertmanagerState imports the Alertmanager state, encoded in the input format, into the tenant's
+// Alertmanager. The state is merged with the current one.
+func (r *MimirClient) SetAlertmanagerState(ctx context.Context, format string, state []byte) error {
+	res, err := r.doRequest(ctx, alertmanagerStatePath(format), "POST", bytes.NewBuffer(state), int64(len(state)))
+	if err != nil {
+		return err
+	}
+
+	res.Body.Close()
+
+	return nil
+}
+
+func alertmanagerStatePath(format string) string {
+	return alertmanagerStateAPIPath + "?" + url.Values{"format": []string{format}}.Encode()
+}
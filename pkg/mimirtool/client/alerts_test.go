@@ -0,0 +1,45 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package client
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMimirClient_AlertmanagerState(t *testing.T) {
+	var stored []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/multitenant_alertmanager/state", r.URL.Path)
+		assert.Equal(t, "protobuf", r.URL.Query().Get("format"))
+		assert.Equal(t, "my-id", r.Header.Get("X-Scope-OrgID"))
+
+		switch r.Method {
+		case http.MethodGet:
+			_, _ = w.Write(stored)
+		case http.MethodPost:
+			body, err := io.ReadAll(r.Body)
+			require.NoError(t, err)
+			stored = body
+		}
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address: ts.URL,
+		ID:      "my-id",
+	})
+	require.NoError(t, err)
+
+	require.NoError(t, client.SetAlertmanagerState(context.Background(), "protobuf", []byte("state")))
+
+	state, err := client.GetAlertmanagerState(context.Background(), "protobuf")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("state"), state)
+}
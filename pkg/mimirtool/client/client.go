@@ -11,6 +11,7 @@ import (
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
 	"strings"
 	"time"
 
@@ -117,6 +118,28 @@ func (r *MimirClient) Query(ctx context.Context, query string) (*http.Response,
 	return res, nil
 }
 
+// QueryRange executes a PromQL range query against the Mimir cluster.
+func (r *MimirClient) QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*http.Response, error) {
+	params := url.Values{
+		"query": []string{query},
+		"start": []string{formatTime(start)},
+		"end":   []string{formatTime(end)},
+		"step":  []string{strconv.FormatFloat(step.Seconds(), 'f', -1, 64)},
+	}
+
+	res, err := r.doRequest(ctx, "/prometheus/api/v1/query_range?"+params.Encode(), "GET", nil, -1)
+	if err != nil {
+		return nil, err
+	}
+
+	return res, nil
+}
+
+// formatTime formats t as a Unix timestamp in seconds, with millisecond precision.
+func formatTime(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixMilli())/1000, 'f', -1, 64)
+}
+
 func (r *MimirClient) doRequest(ctx context.Context, path, method string, payload io.Reader, contentLength int64) (*http.Response, error) {
 	req, err := buildRequest(ctx, path, method, *r.endpoint, payload, contentLength)
 	if err != nil {
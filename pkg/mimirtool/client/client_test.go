@@ -8,9 +8,12 @@ package client
 import (
 	"bytes"
 	"context"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"net/url"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -100,3 +103,31 @@ func TestBuildURL(t *testing.T) {
 	}
 
 }
+
+func TestMimirClient_QueryRange(t *testing.T) {
+	requestCh := make(chan *http.Request, 1)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestCh <- r
+		fmt.Fprintln(w, `{"status":"success","data":{"resultType":"matrix","result":[]}}`)
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{Address: ts.URL, ID: "my-id"})
+	require.NoError(t, err)
+
+	start := time.UnixMilli(1672531200500)
+	res, err := client.QueryRange(context.Background(), `sum by (job) (up{job=~"a|b"})`, start, start.Add(time.Hour), 30*time.Second)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+
+	req := <-requestCh
+	require.Equal(t, "/prometheus/api/v1/query_range", req.URL.Path)
+	require.Equal(t, url.Values{
+		"query": []string{`sum by (job) (up{job=~"a|b"})`},
+		"start": []string{"1672531200.5"},
+		"end":   []string{"1672534800.5"},
+		"step":  []string{"30"},
+	}, req.URL.Query())
+	require.Equal(t, "my-id", req.Header.Get("X-Scope-OrgID"))
+}
@@ -8,6 +8,7 @@ package client
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"net/url"
@@ -17,6 +18,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+	"github.com/grafana/mimir/pkg/ruler/ruletest"
 )
 
 // CreateRuleGroup creates a new rule group
@@ -129,3 +131,36 @@ func (r *MimirClient) DeleteNamespace(ctx context.Context, namespace string) err
 
 	return nil
 }
+
+// TestRules runs the rules unit tests of the test file against the rule groups included in it
+func (r *MimirClient) TestRules(ctx context.Context, file ruletest.TestFile) (*ruletest.Report, error) {
+	payload, err := yaml.Marshal(&file)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := r.doRequest(ctx, "/ruler/test", "POST", bytes.NewBuffer(payload), int64(len(payload)))
+	if err != nil {
+		return nil, err
+	}
+
+	defer res.Body.Close()
+	body, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var result struct {
+		Status string          `json:"status"`
+		Data   ruletest.Report `json:"data"`
+	}
+	if err := json.Unmarshal(body, &result); err != nil {
+		log.WithFields(log.Fields{
+			"body": string(body),
+		}).Debugln("failed to unmarshal rules test report from response")
+
+		return nil, errors.Wrap(err, "unable to unmarshal response")
+	}
+
+	return &result.Data, nil
+}
@@ -7,12 +7,20 @@ package client
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
+	"io"
 	"net/http"
 	"net/http/httptest"
 	"testing"
 
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/ruler/ruletest"
 )
 
 func TestMimirClient_X(t *testing.T) {
@@ -78,3 +86,54 @@ func TestMimirClient_X(t *testing.T) {
 	}
 
 }
+
+func TestMimirClient_TestRules(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/ruler/test", r.URL.Path)
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "my-id", r.Header.Get("X-Scope-OrgID"))
+
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var file ruletest.TestFile
+		require.NoError(t, yaml.Unmarshal(body, &file))
+
+		report, err := ruletest.Run(r.Context(), file, log.NewNopLogger())
+		require.NoError(t, err)
+		require.NoError(t, json.NewEncoder(w).Encode(map[string]interface{}{"status": "success", "data": report}))
+	}))
+	defer ts.Close()
+
+	client, err := New(Config{
+		Address: ts.URL,
+		ID:      "my-id",
+	})
+	require.NoError(t, err)
+
+	var groups []rulefmt.RuleGroup
+	require.NoError(t, yaml.Unmarshal([]byte(`
+- name: group
+  rules:
+    - record: job:up:sum
+      expr: sum by (job) (up)
+`), &groups))
+
+	report, err := client.TestRules(context.Background(), ruletest.TestFile{
+		Groups: groups,
+		Tests: []ruletest.TestGroup{{
+			Name:        "sum",
+			InputSeries: []ruletest.Series{{Series: `up{job="test", instance="a"}`, Values: "1 1 1"}, {Series: `up{job="test", instance="b"}`, Values: "0 1 1"}},
+			PromqlExprTests: []ruletest.PromqlTestCase{
+				{Expr: "job:up:sum", ExpSamples: []ruletest.Sample{{Labels: `job:up:sum{job="test"}`, Value: 1}}},
+				{Expr: "job:up:sum", ExpSamples: []ruletest.Sample{{Labels: `job:up:sum{job="test"}`, Value: 3}}},
+			},
+		}},
+	})
+	require.NoError(t, err)
+	assert.False(t, report.Passed)
+	require.Len(t, report.Tests, 1)
+	assert.Equal(t, []string{
+		`expr: "job:up:sum", time: 0s, exp: [{__name__="job:up:sum", job="test"} 3], got: [{__name__="job:up:sum", job="test"} 1]`,
+	}, report.Tests[0].Errors)
+}
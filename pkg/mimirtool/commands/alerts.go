@@ -41,6 +41,8 @@ type AlertmanagerCommand struct {
 	DisableColor           bool
 	ValidateOnly           bool
 	OutputDir              string
+	StateFormat            string
+	StateFile              string
 
 	cli *client.MimirClient
 }
@@ -82,7 +84,18 @@ func (a *AlertmanagerCommand) Register(app *kingpin.Application, envVars EnvVarN
 	loadalertCmd.Arg("config", "Alertmanager configuration file to load").Required().StringVar(&a.AlertmanagerConfigFile)
 	loadalertCmd.Arg("template-files", "The template files to load").ExistingFilesVar(&a.TemplateFiles)
 
-	for _, cmd := range []*kingpin.CmdClause{getAlertsCmd, deleteCmd, loadalertCmd} {
+	stateCmd := alertCmd.Command("state", "Export and import the Alertmanager state (silences and notification log) of a tenant.")
+	exportStateCmd := stateCmd.Command("export", "Export the Alertmanager state of the tenant from the Grafana Mimir Alertmanager.").Action(a.exportState)
+	exportStateCmd.Flag("output-file", "The file where the state is written to. If not set, the state is printed to the console.").StringVar(&a.StateFile)
+
+	importStateCmd := stateCmd.Command("import", "Import an Alertmanager state into the Grafana Mimir Alertmanager of the tenant, merging it with the current state.").Action(a.importState)
+	importStateCmd.Arg("state-file", "The file of the state to import.").Required().ExistingFileVar(&a.StateFile)
+
+	for _, cmd := range []*kingpin.CmdClause{exportStateCmd, importStateCmd} {
+		cmd.Flag("format", "The format of the state: <json|protobuf>. The protobuf format is the same used to persist the state in the object storage.").Default("json").EnumVar(&a.StateFormat, "json", "protobuf")
+	}
+
+	for _, cmd := range []*kingpin.CmdClause{getAlertsCmd, deleteCmd, loadalertCmd, exportStateCmd, importStateCmd} {
 		cmd.Flag("address", "Address of the Grafana Mimir cluster; alternatively, set "+envVars.Address+".").Envar(envVars.Address).Required().StringVar(&a.ClientConfig.Address)
 		cmd.Flag("id", "Grafana Mimir tenant ID; alternatively, set "+envVars.TenantID+". Used for X-Scope-OrgID HTTP header. Also used for basic auth if --user is not provided.").Envar(envVars.TenantID).Required().StringVar(&a.ClientConfig.ID)
 	}
@@ -195,6 +208,27 @@ func (a *AlertmanagerCommand) loadConfig(_ *kingpin.ParseContext) error {
 	return a.cli.CreateAlertmanagerConfig(context.Background(), cfg, templates)
 }
 
+func (a *AlertmanagerCommand) exportState(_ *kingpin.ParseContext) error {
+	state, err := a.cli.GetAlertmanagerState(context.Background(), a.StateFormat)
+	if err != nil {
+		return err
+	}
+
+	if a.StateFile == "" {
+		_, err = os.Stdout.Write(state)
+		return err
+	}
+	return os.WriteFile(a.StateFile, state, os.FileMode(0o600))
+}
+
+func (a *AlertmanagerCommand) importState(_ *kingpin.ParseContext) error {
+	state, err := os.ReadFile(a.StateFile)
+	if err != nil {
+		return err
+	}
+	return a.cli.SetAlertmanagerState(context.Background(), a.StateFormat, state)
+}
+
 func (a *AlertmanagerCommand) deleteConfig(_ *kingpin.ParseContext) error {
 	err := a.cli.DeleteAlermanagerConfig(context.Background())
 	if err != nil && !errors.Is(err, client.ErrResourceNotFound) {
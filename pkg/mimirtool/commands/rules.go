@@ -13,6 +13,7 @@ import (
 	"reflect"
 	"regexp" //lint:ignore faillint Required by kingpin for regexp flags
 	"strings"
+	"time"
 
 	"github.com/alecthomas/kingpin/v2"
 	"github.com/grafana/dskit/concurrency"
@@ -64,7 +65,9 @@ type ruleCommandClient interface {
 type RuleCommand struct {
 	ClientConfig client.Config
 
-	cli ruleCommandClient
+	cli         ruleCommandClient
+	backfillCli ruleBackfillClient
+	testCli     ruleTestClient
 
 	// Backend type (cortex | loki)
 	Backend string
@@ -109,6 +112,17 @@ type RuleCommand struct {
 	// Diff Rules Config
 	Verbose bool
 
+	// Backfill Rules Config
+	BackfillStart         string
+	BackfillEnd           string
+	BackfillEvalInterval  time.Duration
+	BackfillBlockDuration time.Duration
+	BackfillOutputDir     string
+	BackfillSleepTime     time.Duration
+
+	// Test Rules Config
+	RuleTestFiles []string
+
 	// Metrics.
 	ruleLoadTimestamp        prometheus.Gauge
 	ruleLoadSuccessTimestamp prometheus.Gauge
@@ -156,9 +170,15 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames, re
 	deleteNamespaceCmd := rulesCmd.
 		Command("delete-namespace", "Delete a namespace from the ruler.").
 		Action(r.deleteNamespace)
+	backfillRulesCmd := rulesCmd.
+		Command("backfill", "Evaluate recording rules over a past time range against a Grafana Mimir cluster, and upload the results as TSDB blocks.").
+		Action(r.backfillRules)
+	testRulesCmd := rulesCmd.
+		Command("test", "Run rules unit tests, in the same format used by promtool, against a Grafana Mimir cluster.").
+		Action(r.testRules)
 
 	// Require Mimir cluster address and tenant ID on all these commands
-	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd, deleteNamespaceCmd} {
+	for _, c := range []*kingpin.CmdClause{listCmd, printRulesCmd, getRuleGroupCmd, deleteRuleGroupCmd, loadRulesCmd, diffRulesCmd, syncRulesCmd, deleteNamespaceCmd, backfillRulesCmd, testRulesCmd} {
 		c.Flag("address", "Address of the Grafana Mimir cluster; alternatively, set "+envVars.Address+".").
 			Envar(envVars.Address).
 			Required().
@@ -279,6 +299,29 @@ func (r *RuleCommand) Register(app *kingpin.Application, envVars EnvVarNames, re
 	// Delete Namespace Command
 	deleteNamespaceCmd.Arg("namespace", "Namespace to delete.").Required().StringVar(&r.Namespace)
 
+	// Backfill Command
+	now := time.Now()
+	backfillRulesCmd.Arg("rule-files", "The rule files to backfill.").ExistingFilesVar(&r.RuleFilesList)
+	backfillRulesCmd.Flag("rule-files", "The rule files to backfill. Flag can be reused to load multiple files.").StringVar(&r.RuleFiles)
+	backfillRulesCmd.Flag(
+		"rule-dirs",
+		"Comma separated list of paths to directories containing rules yaml files. Each file in a directory with a .yml or .yaml suffix will be parsed.",
+	).StringVar(&r.RuleFilesPath)
+	backfillRulesCmd.Flag("start", "Start of the time range to backfill, in RFC3339 format.").Required().StringVar(&r.BackfillStart)
+	backfillRulesCmd.Flag("end", "End of the time range to backfill, in RFC3339 format. It should be before the recording rules were loaded into the ruler, so that the backfilled samples don't overlap with the samples recorded by the ruler.").
+		Default(now.Format(time.RFC3339)).
+		StringVar(&r.BackfillEnd)
+	backfillRulesCmd.Flag("eval-interval", "Evaluation interval of the rule groups which don't configure one.").Default("1m").DurationVar(&r.BackfillEvalInterval)
+	backfillRulesCmd.Flag("block-duration", "Time range of the TSDB blocks to create.").Default("2h").DurationVar(&r.BackfillBlockDuration)
+	backfillRulesCmd.Flag("output-dir", "Path to the folder where to store the TSDB blocks. If not set, a new directory in $TEMP is created and removed when the command completes.").
+		Default("").
+		StringVar(&r.BackfillOutputDir)
+	backfillRulesCmd.Flag("sleep-time", "How long to sleep between checking state of block upload after uploading all files for the block.").
+		Default("20s").
+		DurationVar(&r.BackfillSleepTime)
+
+	// Test Command
+	testRulesCmd.Arg("test-files", "The rules unit test files to run.").Required().ExistingFilesVar(&r.RuleTestFiles)
 }
 
 func (r *RuleCommand) setup(_ *kingpin.ParseContext, reg prometheus.Registerer) error {
@@ -298,6 +341,8 @@ func (r *RuleCommand) setup(_ *kingpin.ParseContext, reg prometheus.Registerer)
 		return err
 	}
 	r.cli = cli
+	r.backfillCli = cli
+	r.testCli = cli
 
 	return nil
 }
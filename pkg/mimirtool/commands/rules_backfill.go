@@ -0,0 +1,297 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/alecthomas/kingpin/v2"
+	gokitlog "github.com/go-kit/log"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
+	"github.com/grafana/mimir/pkg/mimirtool/rules/rwrulefmt"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+// ruleBackfillClient defines the interface that should be implemented by the API client used to
+// backfill recording rules. This is useful for testing purposes.
+type ruleBackfillClient interface {
+	// QueryRange executes a PromQL range query.
+	QueryRange(ctx context.Context, query string, start, end time.Time, step time.Duration) (*http.Response, error)
+
+	// Backfill uploads TSDB blocks through the block-upload API.
+	Backfill(ctx context.Context, blocks []string, sleepTime time.Duration) error
+}
+
+type rangeQueryResult struct {
+	Status string         `json:"status"`
+	Data   rangeQueryData `json:"data"`
+}
+
+type rangeQueryData struct {
+	ResultType string       `json:"resultType"`
+	Result     model.Matrix `json:"result"`
+}
+
+func (r *RuleCommand) backfillRules(_ *kingpin.ParseContext) error {
+	if err := r.setupArgs(); err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, invalid arguments")
+	}
+
+	start, err := time.Parse(time.RFC3339, r.BackfillStart)
+	if err != nil {
+		return fmt.Errorf("error parsing start: '%s' value: %w", r.BackfillStart, err)
+	}
+	end, err := time.Parse(time.RFC3339, r.BackfillEnd)
+	if err != nil {
+		return fmt.Errorf("error parsing end: '%s' value: %w", r.BackfillEnd, err)
+	}
+	if !end.After(start) {
+		return errors.New("backfill operation unsuccessful, the end must be after the start")
+	}
+	if r.BackfillEvalInterval <= 0 {
+		return errors.New("backfill operation unsuccessful, the evaluation interval must be greater than 0")
+	}
+	if r.BackfillBlockDuration <= 0 {
+		return errors.New("backfill operation unsuccessful, the block duration must be greater than 0")
+	}
+
+	nss, err := rules.ParseFiles(r.Backend, r.RuleFilesList)
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, unable to parse rules files")
+	}
+
+	outputDir := r.BackfillOutputDir
+	if outputDir == "" {
+		outputDir, err = os.MkdirTemp("", "mimirtool-rules-backfill")
+		if err != nil {
+			return err
+		}
+		defer func() {
+			if err := os.RemoveAll(outputDir); err != nil {
+				log.WithError(err).WithField("path", outputDir).Warn("failed to remove the blocks directory")
+			}
+		}()
+	} else if err := os.MkdirAll(outputDir, 0755); err != nil {
+		return err
+	}
+	log.Infof("Store TSDB blocks in '%s'", outputDir)
+
+	backfiller := &ruleBackfiller{
+		cli:           r.backfillCli,
+		start:         start,
+		end:           end,
+		evalInterval:  r.BackfillEvalInterval,
+		blockDuration: r.BackfillBlockDuration,
+		outputDir:     outputDir,
+	}
+
+	ctx := context.Background()
+	blocks, err := backfiller.createBlocks(ctx, nss)
+	if err != nil {
+		return errors.Wrap(err, "backfill operation unsuccessful, unable to create blocks")
+	}
+	if len(blocks) == 0 {
+		log.Info("the recording rules returned no samples, nothing to backfill")
+		return nil
+	}
+
+	return r.backfillCli.Backfill(ctx, blocks, r.BackfillSleepTime)
+}
+
+// ruleBackfiller evaluates recording rules over a past time range by running range queries,
+// and writes the results into TSDB blocks.
+type ruleBackfiller struct {
+	cli        ruleBackfillClient
+	start, end time.Time
+	// evalInterval is the evaluation interval of the rule groups which don't configure one.
+	evalInterval  time.Duration
+	blockDuration time.Duration
+	outputDir     string
+}
+
+// createBlocks writes the results of the recording rules of the namespaces into TSDB blocks
+// aligned to the block duration, and returns the directories of the written blocks.
+func (b *ruleBackfiller) createBlocks(ctx context.Context, nss map[string]rules.RuleNamespace) ([]string, error) {
+	names := make([]string, 0, len(nss))
+	for name := range nss {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var groups []rwrulefmt.RuleGroup
+	for _, name := range names {
+		for _, group := range nss[name].Groups {
+			for _, rule := range group.Rules {
+				if rule.Alert.Value != "" {
+					log.WithFields(log.Fields{
+						"alert":     rule.Alert.Value,
+						"group":     group.Name,
+						"namespace": name,
+					}).Warn("skipping alerting rule, only recording rules are backfilled")
+				}
+			}
+			groups = append(groups, group)
+		}
+	}
+
+	blockMillis := b.blockDuration.Milliseconds()
+	var blocks []string
+	for blockStart := blockMillis * (b.start.UnixMilli() / blockMillis); blockStart <= b.end.UnixMilli(); blockStart += blockMillis {
+		blockDir, err := b.createBlock(ctx, groups, blockStart, blockStart+blockMillis)
+		if err != nil {
+			return nil, err
+		}
+		if blockDir != "" {
+			blocks = append(blocks, blockDir)
+		}
+	}
+	return blocks, nil
+}
+
+// createBlock writes the results of the recording rules between mint and maxt, maxt excluded, into a
+// TSDB block, and returns the block directory. No block is written if the rules returned no samples.
+func (b *ruleBackfiller) createBlock(ctx context.Context, groups []rwrulefmt.RuleGroup, mint, maxt int64) (_ string, returnErr error) {
+	// The head of the block writer only accepts samples not older than half its chunk range before
+	// the latest appended sample. The results of each rule span the whole block, so the chunk range
+	// is twice the block duration to accept the samples of the rules appended after the first one.
+	w, err := tsdb.NewBlockWriter(gokitlog.NewNopLogger(), b.outputDir, 2*(maxt-mint))
+	if err != nil {
+		return "", errors.Wrap(err, "block writer")
+	}
+	defer func() {
+		if err := w.Close(); err != nil && returnErr == nil {
+			returnErr = errors.Wrap(err, "close block writer")
+		}
+	}()
+
+	samples := 0
+	for _, group := range groups {
+		interval := time.Duration(group.Interval)
+		if interval == 0 {
+			interval = b.evalInterval
+		}
+
+		for _, rule := range group.Rules {
+			if rule.Record.Value == "" {
+				continue
+			}
+
+			n, err := b.backfillRule(ctx, w.Appender(ctx), rule, interval, mint, maxt)
+			if err != nil {
+				return "", errors.Wrapf(err, "backfill recording rule %q of group %q", rule.Record.Value, group.Name)
+			}
+			samples += n
+		}
+	}
+	if samples == 0 {
+		return "", nil
+	}
+
+	id, err := w.Flush(ctx)
+	if err != nil {
+		return "", errors.Wrap(err, "flush")
+	}
+	blockDir := filepath.Join(b.outputDir, id.String())
+
+	// The block-upload API rejects blocks with external labels, so none is set.
+	if _, err := block.InjectThanosMeta(gokitlog.NewNopLogger(), blockDir, block.ThanosMeta{
+		Version: block.ThanosVersion1,
+		Labels:  map[string]string{},
+	}, nil); err != nil {
+		return "", errors.Wrap(err, "write block meta")
+	}
+
+	log.WithFields(log.Fields{
+		"block":   id.String(),
+		"mint":    model.Time(mint).Time().Format(time.RFC3339),
+		"maxt":    model.Time(maxt).Time().Format(time.RFC3339),
+		"samples": samples,
+	}).Info("created block")
+	return blockDir, nil
+}
+
+// backfillRule evaluates the recording rule between mint and maxt, maxt excluded, at timestamps aligned
+// to the evaluation interval, and appends the resulting samples. It returns the number of appended samples.
+func (b *ruleBackfiller) backfillRule(ctx context.Context, app storage.Appender, rule rulefmt.RuleNode, interval time.Duration, mint, maxt int64) (_ int, returnErr error) {
+	defer func() {
+		if returnErr != nil {
+			_ = app.Rollback()
+		}
+	}()
+
+	step := interval.Milliseconds()
+	first := max(mint, b.start.UnixMilli())
+	first = step * ((first + step - 1) / step)
+	last := min(maxt-1, b.end.UnixMilli())
+	if first > last {
+		return 0, nil
+	}
+
+	res, err := b.cli.QueryRange(ctx, rule.Expr.Value, time.UnixMilli(first), time.UnixMilli(last), interval)
+	if err != nil {
+		return 0, err
+	}
+	defer res.Body.Close()
+
+	var result rangeQueryResult
+	if err := json.NewDecoder(res.Body).Decode(&result); err != nil {
+		return 0, errors.Wrap(err, "decode query response")
+	}
+	if result.Data.ResultType != model.ValMatrix.String() {
+		return 0, fmt.Errorf("unexpected query result type %q", result.Data.ResultType)
+	}
+
+	samples, skippedHistograms := 0, 0
+	for _, stream := range result.Data.Result {
+		lbls := recordingRuleLabels(rule, stream.Metric)
+		for _, s := range stream.Values {
+			if _, err := app.Append(0, lbls, int64(s.Timestamp), float64(s.Value)); err != nil {
+				return 0, errors.Wrapf(err, "add sample for series %s at %s", lbls, s.Timestamp.Time().Format(time.RFC3339))
+			}
+			samples++
+		}
+		skippedHistograms += len(stream.Histograms)
+	}
+	if skippedHistograms > 0 {
+		log.WithFields(log.Fields{
+			"record":  rule.Record.Value,
+			"samples": skippedHistograms,
+		}).Warn("skipping native histogram samples, only float samples are backfilled")
+	}
+
+	if err := app.Commit(); err != nil {
+		return 0, errors.Wrap(err, "commit")
+	}
+	return samples, nil
+}
+
+// recordingRuleLabels returns the labels of the series recorded by the rule for a series of the rule expression result.
+func recordingRuleLabels(rule rulefmt.RuleNode, metric model.Metric) labels.Labels {
+	builder := labels.NewScratchBuilder(len(metric))
+	for name, value := range metric {
+		builder.Add(string(name), string(value))
+	}
+	builder.Sort()
+
+	lb := labels.NewBuilder(builder.Labels())
+	lb.Set(labels.MetricName, rule.Record.Value)
+	for name, value := range rule.Labels {
+		lb.Set(name, value)
+	}
+	return lb.Labels()
+}
@@ -0,0 +1,250 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+)
+
+func TestRuleCommand_backfillRules(t *testing.T) {
+	const ruleFile = `
+groups:
+  - name: group-1
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+        labels:
+          source: backfill
+      - alert: UpIsDown
+        expr: up == 0
+  - name: group-2
+    interval: 5m
+    rules:
+      - record: instance:requests:rate5m
+        expr: rate(requests[5m])
+`
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(ruleFile), 0o600))
+
+	cli := &ruleBackfillClientMock{series: map[string][]model.Metric{
+		`sum by (job) (up)`: {
+			{"job": "a"},
+			{"job": "b", "source": "overridden"},
+		},
+		`rate(requests[5m])`: {
+			{"instance": "i1"},
+		},
+	}}
+
+	outputDir := t.TempDir()
+	cmd := &RuleCommand{
+		backfillCli:           cli,
+		Backend:               rules.MimirBackend,
+		RuleFilesList:         []string{file},
+		BackfillStart:         "2023-01-01T00:30:00Z",
+		BackfillEnd:           "2023-01-01T03:00:00Z",
+		BackfillEvalInterval:  time.Minute,
+		BackfillBlockDuration: 2 * time.Hour,
+		BackfillOutputDir:     outputDir,
+	}
+	require.NoError(t, cmd.backfillRules(nil))
+
+	// Alerting rules are not evaluated.
+	for _, q := range cli.queries {
+		assert.NotEqual(t, "up == 0", q.query)
+	}
+
+	// One block is created for each block duration.
+	require.Len(t, cli.backfilledBlocks, 2)
+
+	start := mustParseRFC3339(t, "2023-01-01T00:30:00Z")
+	end := mustParseRFC3339(t, "2023-01-01T03:00:00Z")
+	blockBoundary := mustParseRFC3339(t, "2023-01-01T02:00:00Z")
+
+	actual := map[string][]int64{}
+	for i, blockDir := range cli.backfilledBlocks {
+		assert.Equal(t, outputDir, filepath.Dir(blockDir))
+
+		meta, err := block.ReadMetaFromDir(blockDir)
+		require.NoError(t, err)
+		assert.Empty(t, meta.Thanos.Labels)
+		assert.Equal(t, block.ThanosVersion1, meta.Thanos.Version)
+		if i == 0 {
+			assert.Equal(t, start, meta.MinTime)
+			assert.LessOrEqual(t, meta.MaxTime, blockBoundary)
+		} else {
+			assert.Equal(t, blockBoundary, meta.MinTime)
+			assert.Equal(t, end+1, meta.MaxTime)
+		}
+
+		for series, timestamps := range readBlockSamples(t, blockDir) {
+			actual[series] = append(actual[series], timestamps...)
+		}
+	}
+
+	expectedTimestamps := func(interval time.Duration) []int64 {
+		var timestamps []int64
+		for ts := start; ts <= end; ts += interval.Milliseconds() {
+			timestamps = append(timestamps, ts)
+		}
+		return timestamps
+	}
+	assert.Equal(t, map[string][]int64{
+		`{__name__="job:up:sum", job="a", source="backfill"}`:  expectedTimestamps(time.Minute),
+		`{__name__="job:up:sum", job="b", source="backfill"}`:  expectedTimestamps(time.Minute),
+		`{__name__="instance:requests:rate5m", instance="i1"}`: expectedTimestamps(5 * time.Minute),
+	}, actual)
+}
+
+func TestRuleCommand_backfillRules_NoSamples(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, os.WriteFile(file, []byte(`
+groups:
+  - name: group-1
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+`), 0o600))
+
+	cli := &ruleBackfillClientMock{}
+	cmd := &RuleCommand{
+		backfillCli:           cli,
+		Backend:               rules.MimirBackend,
+		RuleFilesList:         []string{file},
+		BackfillStart:         "2023-01-01T00:30:00Z",
+		BackfillEnd:           "2023-01-01T03:00:00Z",
+		BackfillEvalInterval:  time.Minute,
+		BackfillBlockDuration: 2 * time.Hour,
+	}
+	require.NoError(t, cmd.backfillRules(nil))
+	assert.Len(t, cli.queries, 2)
+	assert.False(t, cli.backfillCalled)
+}
+
+func TestRuleCommand_backfillRules_InvalidArguments(t *testing.T) {
+	for name, cmd := range map[string]RuleCommand{
+		"invalid start":          {BackfillStart: "yesterday", BackfillEnd: "2023-01-01T03:00:00Z", BackfillEvalInterval: time.Minute, BackfillBlockDuration: time.Hour},
+		"end before start":       {BackfillStart: "2023-01-01T03:00:00Z", BackfillEnd: "2023-01-01T00:00:00Z", BackfillEvalInterval: time.Minute, BackfillBlockDuration: time.Hour},
+		"zero eval interval":     {BackfillStart: "2023-01-01T00:00:00Z", BackfillEnd: "2023-01-01T03:00:00Z", BackfillBlockDuration: time.Hour},
+		"zero block duration":    {BackfillStart: "2023-01-01T00:00:00Z", BackfillEnd: "2023-01-01T03:00:00Z", BackfillEvalInterval: time.Minute},
+		"invalid end":            {BackfillStart: "2023-01-01T00:00:00Z", BackfillEnd: "now", BackfillEvalInterval: time.Minute, BackfillBlockDuration: time.Hour},
+		"negative eval interval": {BackfillStart: "2023-01-01T00:00:00Z", BackfillEnd: "2023-01-01T03:00:00Z", BackfillEvalInterval: -time.Minute, BackfillBlockDuration: time.Hour},
+	} {
+		t.Run(name, func(t *testing.T) {
+			cli := &ruleBackfillClientMock{}
+			cmd.backfillCli = cli
+			cmd.Backend = rules.MimirBackend
+			assert.Error(t, cmd.backfillRules(nil))
+			assert.Empty(t, cli.queries)
+		})
+	}
+}
+
+func TestRecordingRuleLabels(t *testing.T) {
+	rule := rulefmt.RuleNode{
+		Record: yaml.Node{Value: "job:up:sum"},
+		Labels: map[string]string{"source": "backfill", "env": ""},
+	}
+
+	assert.Equal(t,
+		labels.FromStrings(labels.MetricName, "job:up:sum", "job", "a", "source", "backfill"),
+		recordingRuleLabels(rule, model.Metric{model.MetricNameLabel: "up", "job": "a", "source": "other", "env": "prod"}),
+	)
+}
+
+func mustParseRFC3339(t *testing.T, value string) int64 {
+	ts, err := time.Parse(time.RFC3339, value)
+	require.NoError(t, err)
+	return ts.UnixMilli()
+}
+
+// readBlockSamples returns the timestamps of the samples of each series in the block, checking that
+// the value of each sample is its timestamp in seconds.
+func readBlockSamples(t *testing.T, blockDir string) map[string][]int64 {
+	b, err := tsdb.OpenBlock(nil, blockDir, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, b.Close()) })
+
+	q, err := tsdb.NewBlockQuerier(b, b.MinTime(), b.MaxTime())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, q.Close()) })
+
+	samples := map[string][]int64{}
+	set := q.Select(context.Background(), true, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".+"))
+	for set.Next() {
+		series := set.At()
+		it := series.Iterator(nil)
+		for it.Next() == chunkenc.ValFloat {
+			ts, v := it.At()
+			assert.Equal(t, float64(ts)/1000, v)
+			samples[series.Labels().String()] = append(samples[series.Labels().String()], ts)
+		}
+		require.NoError(t, it.Err())
+	}
+	require.NoError(t, set.Err())
+	return samples
+}
+
+type rangeQuery struct {
+	query      string
+	start, end time.Time
+	step       time.Duration
+}
+
+// ruleBackfillClientMock returns, for each query, a sample for each of its series at each step, whose value
+// is the timestamp in seconds.
+type ruleBackfillClientMock struct {
+	series map[string][]model.Metric
+
+	queries          []rangeQuery
+	backfillCalled   bool
+	backfilledBlocks []string
+}
+
+func (m *ruleBackfillClientMock) QueryRange(_ context.Context, query string, start, end time.Time, step time.Duration) (*http.Response, error) {
+	m.queries = append(m.queries, rangeQuery{query: query, start: start, end: end, step: step})
+
+	result := rangeQueryResult{Status: "success", Data: rangeQueryData{ResultType: "matrix", Result: model.Matrix{}}}
+	for _, metric := range m.series[query] {
+		stream := &model.SampleStream{Metric: metric}
+		for ts := start; !ts.After(end); ts = ts.Add(step) {
+			stream.Values = append(stream.Values, model.SamplePair{
+				Timestamp: model.TimeFromUnixNano(ts.UnixNano()),
+				Value:     model.SampleValue(float64(ts.UnixMilli()) / 1000),
+			})
+		}
+		result.Data.Result = append(result.Data.Result, stream)
+	}
+
+	body, err := json.Marshal(result)
+	if err != nil {
+		return nil, err
+	}
+	return &http.Response{StatusCode: http.StatusOK, Body: io.NopCloser(bytes.NewReader(body))}, nil
+}
+
+func (m *ruleBackfillClientMock) Backfill(_ context.Context, blocks []string, _ time.Duration) error {
+	m.backfillCalled = true
+	m.backfilledBlocks = blocks
+	return nil
+}
@@ -0,0 +1,133 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/alecthomas/kingpin/v2"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"gopkg.in/yaml.v3"
+
+	"github.com/grafana/mimir/pkg/mimirtool/rules"
+	"github.com/grafana/mimir/pkg/ruler/ruletest"
+)
+
+// ruleTestClient defines the interface that should be implemented by the API client used to
+// run rules unit tests. This is useful for testing purposes.
+type ruleTestClient interface {
+	// TestRules runs the rules unit tests of the test file in the ruler.
+	TestRules(ctx context.Context, file ruletest.TestFile) (*ruletest.Report, error)
+}
+
+// ruleTestFile is a rules unit test file in the format used by "promtool test rules".
+type ruleTestFile struct {
+	RuleFiles          []string             `yaml:"rule_files"`
+	EvaluationInterval model.Duration       `yaml:"evaluation_interval,omitempty"`
+	GroupEvalOrder     []string             `yaml:"group_eval_order,omitempty"`
+	Tests              []ruletest.TestGroup `yaml:"tests"`
+}
+
+func (r *RuleCommand) testRules(_ *kingpin.ParseContext) error {
+	passed, err := runRuleTests(context.Background(), r.testCli, r.RuleTestFiles, os.Stdout)
+	if err != nil {
+		return errors.Wrap(err, "test operation unsuccessful")
+	}
+	if !passed {
+		return errors.New("test operation unsuccessful, some tests failed")
+	}
+	return nil
+}
+
+// runRuleTests runs the rules unit tests of the test files in the ruler, writes their results to out,
+// and returns whether all the tests passed.
+func runRuleTests(ctx context.Context, cli ruleTestClient, testFiles []string, out io.Writer) (bool, error) {
+	passed := true
+
+	for _, testFile := range testFiles {
+		fmt.Fprintln(out, "Unit Testing:", testFile)
+
+		file, err := loadRuleTestFile(testFile)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to load test file %s", testFile)
+		}
+
+		report, err := cli.TestRules(ctx, file)
+		if err != nil {
+			return false, errors.Wrapf(err, "unable to run the tests of %s", testFile)
+		}
+
+		if report.Passed {
+			fmt.Fprintln(out, "  SUCCESS")
+			continue
+		}
+
+		passed = false
+		fmt.Fprintln(out, "  FAILED:")
+		for _, test := range report.Tests {
+			if test.Passed {
+				continue
+			}
+			fmt.Fprintf(out, "    %s:\n", test.Name)
+			for _, testErr := range test.Errors {
+				fmt.Fprintf(out, "      %s\n", testErr)
+			}
+		}
+	}
+
+	return passed, nil
+}
+
+// loadRuleTestFile reads a promtool rules unit test file, and includes the rule groups of the rule files
+// it references, which are resolved relatively to the directory of the test file.
+func loadRuleTestFile(path string) (ruletest.TestFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return ruletest.TestFile{}, err
+	}
+
+	var testFile ruleTestFile
+	if err := yaml.Unmarshal(content, &testFile); err != nil {
+		return ruletest.TestFile{}, err
+	}
+
+	file := ruletest.TestFile{
+		EvaluationInterval: testFile.EvaluationInterval,
+		GroupEvalOrder:     testFile.GroupEvalOrder,
+		Tests:              testFile.Tests,
+	}
+
+	for _, pattern := range testFile.RuleFiles {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(filepath.Dir(path), pattern)
+		}
+
+		ruleFiles, err := filepath.Glob(pattern)
+		if err != nil {
+			return ruletest.TestFile{}, err
+		}
+		if len(ruleFiles) == 0 {
+			return ruletest.TestFile{}, fmt.Errorf("no rule files match %s", pattern)
+		}
+
+		for _, ruleFile := range ruleFiles {
+			nss, errs := rules.Parse(ruleFile)
+			if len(errs) > 0 {
+				return ruletest.TestFile{}, errors.Wrapf(errs[0], "unable to parse rule file %s", ruleFile)
+			}
+
+			for _, ns := range nss {
+				for _, group := range ns.Groups {
+					file.Groups = append(file.Groups, group.RuleGroup)
+				}
+			}
+		}
+	}
+
+	return file, nil
+}
@@ -0,0 +1,113 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package commands
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/ruler/ruletest"
+)
+
+func TestRunRuleTests(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, "rules"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rules", "first.yaml"), []byte(`
+groups:
+  - name: group-1
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+`), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "rules", "second.yaml"), []byte(`
+groups:
+  - name: group-2
+    rules:
+      - alert: UpIsDown
+        expr: up == 0
+`), 0644))
+
+	testFile := filepath.Join(dir, "test.yaml")
+	require.NoError(t, os.WriteFile(testFile, []byte(`
+rule_files:
+  - rules/*.yaml
+evaluation_interval: 30s
+group_eval_order: [group-2, group-1]
+tests:
+  - name: up
+    interval: 1m
+    input_series:
+      - series: 'up{job="test"}'
+        values: '1 1 0'
+    alert_rule_test:
+      - alertname: UpIsDown
+        eval_time: 2m
+        exp_alerts:
+          - exp_labels:
+              job: test
+`), 0644))
+
+	t.Run("passing tests", func(t *testing.T) {
+		cli := &ruleTestClientMock{report: ruletest.Report{Passed: true, Tests: []ruletest.TestResult{{Name: "up", Passed: true}}}}
+
+		out := &bytes.Buffer{}
+		passed, err := runRuleTests(context.Background(), cli, []string{testFile}, out)
+		require.NoError(t, err)
+		assert.True(t, passed)
+		assert.Equal(t, "Unit Testing: "+testFile+"\n  SUCCESS\n", out.String())
+
+		// The rule groups of the referenced rule files are included in the test file, in order.
+		require.Len(t, cli.files, 1)
+		file := cli.files[0]
+		require.Len(t, file.Groups, 2)
+		assert.Equal(t, "group-1", file.Groups[0].Name)
+		assert.Equal(t, "group-2", file.Groups[1].Name)
+		assert.Equal(t, model.Duration(30*time.Second), file.EvaluationInterval)
+		assert.Equal(t, []string{"group-2", "group-1"}, file.GroupEvalOrder)
+		require.Len(t, file.Tests, 1)
+		assert.Equal(t, "up", file.Tests[0].Name)
+		assert.Equal(t, []ruletest.Series{{Series: `up{job="test"}`, Values: "1 1 0"}}, file.Tests[0].InputSeries)
+	})
+
+	t.Run("failing tests", func(t *testing.T) {
+		cli := &ruleTestClientMock{report: ruletest.Report{Tests: []ruletest.TestResult{
+			{Name: "up", Errors: []string{"first error", "second error"}},
+			{Name: "other", Passed: true},
+		}}}
+
+		out := &bytes.Buffer{}
+		passed, err := runRuleTests(context.Background(), cli, []string{testFile}, out)
+		require.NoError(t, err)
+		assert.False(t, passed)
+		assert.Equal(t, "Unit Testing: "+testFile+"\n  FAILED:\n    up:\n      first error\n      second error\n", out.String())
+	})
+
+	t.Run("missing rule files", func(t *testing.T) {
+		invalidTestFile := filepath.Join(dir, "invalid.yaml")
+		require.NoError(t, os.WriteFile(invalidTestFile, []byte(`
+rule_files:
+  - missing.yaml
+`), 0644))
+
+		_, err := runRuleTests(context.Background(), &ruleTestClientMock{}, []string{invalidTestFile}, &bytes.Buffer{})
+		assert.ErrorContains(t, err, "no rule files match")
+	})
+}
+
+type ruleTestClientMock struct {
+	report ruletest.Report
+	files  []ruletest.TestFile
+}
+
+func (c *ruleTestClientMock) TestRules(_ context.Context, file ruletest.TestFile) (*ruletest.Report, error) {
+	c.files = append(c.files, file)
+	return &c.report, nil
+}
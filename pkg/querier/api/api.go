@@ -32,6 +32,42 @@ type LabelNamesCardinalityItem struct {
 	LabelValuesCount int    `json:"label_values_count"`
 }
 
+type SeriesGrowthCardinalityResponse struct {
+	SeriesCountTotal         uint64                     `json:"series_count_total"`
+	InMemorySeriesCountTotal uint64                     `json:"in_memory_series_count_total"`
+	Windows                  []string                   `json:"windows"`
+	BlocksSeriesCount        []SeriesGrowthBlocksWindow `json:"blocks_series_count,omitempty"`
+	Metrics                  []MetricSeriesGrowth       `json:"metrics"`
+	LabelValuesChurn         []LabelValueChurnItem      `json:"label_values_churn"`
+}
+
+type SeriesGrowthBlocksWindow struct {
+	Window      string `json:"window"`
+	SeriesCount uint64 `json:"series_count"`
+}
+
+type MetricSeriesGrowth struct {
+	MetricName          string               `json:"metric_name"`
+	SeriesCount         uint64               `json:"series_count"`
+	InMemorySeriesCount uint64               `json:"in_memory_series_count"`
+	ChurnedSeriesCount  uint64               `json:"churned_series_count"`
+	Windows             []SeriesGrowthWindow `json:"windows"`
+}
+
+type SeriesGrowthWindow struct {
+	Window      string `json:"window"`
+	SeriesCount uint64 `json:"series_count"`
+	Growth      int64  `json:"growth"`
+}
+
+type LabelValueChurnItem struct {
+	LabelName           string `json:"label_name"`
+	LabelValue          string `json:"label_value"`
+	SeriesCount         uint64 `json:"series_count"`
+	InMemorySeriesCount uint64 `json:"in_memory_series_count"`
+	ChurnedSeriesCount  uint64 `json:"churned_series_count"`
+}
+
 type ActiveSeriesResponse struct {
 	Data []labels.Labels `json:"data"`
 }
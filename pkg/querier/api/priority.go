@@ -0,0 +1,119 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package api
+
+import (
+	"context"
+	"net/http"
+	"slices"
+
+	"github.com/grafana/dskit/middleware"
+	"google.golang.org/grpc"
+)
+
+const (
+	// DeprioritizedQueryHeader is set on query requests which should be enqueued in the query-scheduler
+	// with a lower priority than the other queries of the same tenant.
+	DeprioritizedQueryHeader = "X-Mimir-Query-Deprioritized"
+
+	// QueryPriorityClassHeader is set by internal callers, like the ruler, and by the clients of the tenants
+	// allowed to, to classify the origin of their queries. The query-scheduler dequeues the queries of a tenant
+	// with weighted fairness across the priority classes.
+	QueryPriorityClassHeader = "X-Mimir-Query-Priority-Class"
+
+	// QueryPriorityClassAlerting is the priority class of queries evaluating alerting and recording rules.
+	QueryPriorityClassAlerting = "alerting"
+
+	// QueryPriorityClassDashboard is the priority class of queries issued by dashboards.
+	QueryPriorityClassDashboard = "dashboard"
+
+	// QueryPriorityClassAdHoc is the priority class of ad-hoc exploratory queries.
+	QueryPriorityClassAdHoc = "ad-hoc"
+
+	// QueryPriorityClassExport is the priority class of queries exporting data through the API.
+	QueryPriorityClassExport = "export"
+)
+
+var QueryPriorityClasses = []string{QueryPriorityClassAlerting, QueryPriorityClassDashboard, QueryPriorityClassAdHoc, QueryPriorityClassExport}
+
+func IsValidQueryPriorityClass(class string) bool {
+	return slices.Contains(QueryPriorityClasses, class)
+}
+
+const (
+	deprioritizedContextKey               contextKey = 2
+	queryPriorityClassContextKey          contextKey = 3
+	requestedQueryPriorityClassContextKey contextKey = 4
+)
+
+// ContextWithDeprioritizedQuery returns a new context marking the query as deprioritized.
+// It can be checked with IsDeprioritizedQuery.
+func ContextWithDeprioritizedQuery(parent context.Context) context.Context {
+	return context.WithValue(parent, deprioritizedContextKey, true)
+}
+
+// IsDeprioritizedQuery returns whether the query has been marked as deprioritized via ContextWithDeprioritizedQuery.
+func IsDeprioritizedQuery(ctx context.Context) bool {
+	deprioritized, _ := ctx.Value(deprioritizedContextKey).(bool)
+	return deprioritized
+}
+
+// ContextWithQueryPriorityClass returns a new context with the given query priority class.
+// The priority class can be retrieved with QueryPriorityClassFromContext.
+func ContextWithQueryPriorityClass(parent context.Context, class string) context.Context {
+	return context.WithValue(parent, queryPriorityClassContextKey, class)
+}
+
+// QueryPriorityClassFromContext returns the query priority class from the context if set via ContextWithQueryPriorityClass.
+// The second return value is true if the priority class was found in the context and is valid.
+func QueryPriorityClassFromContext(ctx context.Context) (string, bool) {
+	class, _ := ctx.Value(queryPriorityClassContextKey).(string)
+	return class, IsValidQueryPriorityClass(class)
+}
+
+// ContextWithRequestedQueryPriorityClass returns a new context with the query priority class requested by a client.
+// The requested priority class can be retrieved with RequestedQueryPriorityClassFromContext.
+func ContextWithRequestedQueryPriorityClass(parent context.Context, class string) context.Context {
+	return context.WithValue(parent, requestedQueryPriorityClassContextKey, class)
+}
+
+// RequestedQueryPriorityClassFromContext returns the query priority class requested by a client, if set via
+// ContextWithRequestedQueryPriorityClass. The requested priority class must not be honored unless the client
+// is allowed to set it. The second return value is true if the priority class was found in the context and is valid.
+func RequestedQueryPriorityClassFromContext(ctx context.Context) (string, bool) {
+	class, _ := ctx.Value(requestedQueryPriorityClassContextKey).(string)
+	return class, IsValidQueryPriorityClass(class)
+}
+
+// QueryPriorityClassMiddleware takes the query priority class from the X-Mimir-Query-Priority-Class header and sets it
+// in the context. It can be retrieved with QueryPriorityClassFromContext.
+//
+// The header is trusted only on requests received via HTTP over gRPC, which are issued by internal callers like
+// the ruler. The header is removed from any other request, and the priority class is set in the context as requested,
+// so that it's honored only for the tenants allowed to prioritize their own queries. It can be retrieved with
+// RequestedQueryPriorityClassFromContext.
+func QueryPriorityClassMiddleware() middleware.Interface {
+	return middleware.Func(func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !isHTTPOverGRPCRequest(r) {
+				if c := r.Header.Get(QueryPriorityClassHeader); c != "" {
+					ctx := r.Context()
+					if IsValidQueryPriorityClass(c) {
+						ctx = ContextWithRequestedQueryPriorityClass(ctx, c)
+					}
+					r = r.Clone(ctx)
+					r.Header.Del(QueryPriorityClassHeader)
+				}
+			} else if c := r.Header.Get(QueryPriorityClassHeader); IsValidQueryPriorityClass(c) {
+				r = r.WithContext(ContextWithQueryPriorityClass(r.Context(), c))
+			}
+			next.ServeHTTP(w, r)
+		})
+	})
+}
+
+// isHTTPOverGRPCRequest returns whether the request has been received by the gRPC server, through httpgrpc.
+func isHTTPOverGRPCRequest(r *http.Request) bool {
+	_, ok := grpc.Method(r.Context())
+	return ok
+}
@@ -0,0 +1,88 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+func TestQueryPriorityClassMiddleware(t *testing.T) {
+	tests := map[string]struct {
+		overGRPC        bool
+		header          string
+		expectedClass   string
+		expectedHeader  string
+		expectedInCtxOK bool
+
+		expectedRequestedClass string
+	}{
+		"should honor the priority class of requests received via HTTP over gRPC": {
+			overGRPC:        true,
+			header:          QueryPriorityClassAlerting,
+			expectedClass:   QueryPriorityClassAlerting,
+			expectedHeader:  QueryPriorityClassAlerting,
+			expectedInCtxOK: true,
+		},
+		"should ignore an invalid priority class of requests received via HTTP over gRPC": {
+			overGRPC:       true,
+			header:         "invalid",
+			expectedHeader: "invalid",
+		},
+		"should strip the priority class of requests received via HTTP and keep it as requested": {
+			header:                 QueryPriorityClassDashboard,
+			expectedRequestedClass: QueryPriorityClassDashboard,
+		},
+		"should strip an invalid priority class of requests received via HTTP": {
+			header: "invalid",
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			var (
+				actualClass          string
+				actualOK             bool
+				actualHeader         string
+				actualRequestedClass string
+			)
+			handler := QueryPriorityClassMiddleware().Wrap(http.HandlerFunc(func(_ http.ResponseWriter, r *http.Request) {
+				actualClass, actualOK = QueryPriorityClassFromContext(r.Context())
+				actualRequestedClass, _ = RequestedQueryPriorityClassFromContext(r.Context())
+				actualHeader = r.Header.Get(QueryPriorityClassHeader)
+			}))
+
+			req, err := http.NewRequest(http.MethodGet, "/prometheus/api/v1/query", nil)
+			require.NoError(t, err)
+			req.Header.Set(QueryPriorityClassHeader, testData.header)
+			if testData.overGRPC {
+				req = req.WithContext(grpc.NewContextWithServerTransportStream(req.Context(), &mockServerTransportStream{}))
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), req)
+
+			assert.Equal(t, testData.expectedInCtxOK, actualOK)
+			if testData.expectedInCtxOK {
+				assert.Equal(t, testData.expectedClass, actualClass)
+			}
+			assert.Equal(t, testData.expectedHeader, actualHeader)
+			assert.Equal(t, testData.expectedRequestedClass, actualRequestedClass)
+
+			// The input request should not be modified.
+			assert.Equal(t, testData.header, req.Header.Get(QueryPriorityClassHeader))
+		})
+	}
+}
+
+type mockServerTransportStream struct{}
+
+func (*mockServerTransportStream) Method() string               { return "/httpgrpc.HTTP/Handle" }
+func (*mockServerTransportStream) SetHeader(metadata.MD) error  { return nil }
+func (*mockServerTransportStream) SendHeader(metadata.MD) error { return nil }
+func (*mockServerTransportStream) SetTrailer(metadata.MD) error { return nil }
@@ -18,6 +18,7 @@ import (
 	"github.com/thanos-io/objstore"
 
 	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/util/globalerror"
 )
@@ -118,3 +119,20 @@ func (f *BucketIndexBlocksFinder) GetBlocks(ctx context.Context, userID string,
 func newBucketIndexTooOldError(updatedAt time.Time, maxStalePeriod time.Duration) error {
 	return errors.New(globalerror.BucketIndexTooOld.Message(fmt.Sprintf("the bucket index is too old. It was last updated at %s, which exceeds the maximum allowed staleness period of %v", updatedAt.UTC().Format(time.RFC3339Nano), maxStalePeriod)))
 }
+
+// GetTombstones implements TombstonesFinder.
+func (f *BucketIndexBlocksFinder) GetTombstones(ctx context.Context, userID string, minT, maxT int64) (mimir_tsdb.Tombstones, error) {
+	if f.State() != services.Running {
+		return nil, errBucketIndexBlocksFinderNotRunning
+	}
+
+	idx, err := f.loader.GetIndex(ctx, userID)
+	if errors.Is(err, bucketindex.ErrIndexNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return idx.Tombstones.Overlapping(minT, maxT), nil
+}
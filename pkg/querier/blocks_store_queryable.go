@@ -209,7 +209,7 @@ func NewBlocksStoreQueryableFromConfig(querierCfg Config, gatewayCfg storegatewa
 	}
 
 	// Blocks finder doesn't use chunks, but we pass config for consistency.
-	cachingBucket, err := mimir_tsdb.CreateCachingBucket(nil, storageCfg.BucketStore.ChunksCache, storageCfg.BucketStore.MetadataCache, bucketClient, logger, prometheus.WrapRegistererWith(prometheus.Labels{"component": "querier"}, reg))
+	cachingBucket, err := mimir_tsdb.CreateCachingBucket(nil, nil, storageCfg.BucketStore.ChunksCache, storageCfg.BucketStore.MetadataCache, bucketClient, logger, prometheus.WrapRegistererWith(prometheus.Labels{"component": "querier"}, reg))
 	if err != nil {
 		return nil, errors.Wrap(err, "create caching bucket")
 	}
@@ -293,6 +293,20 @@ func (q *BlocksStoreQueryable) stopping(_ error) error {
 	return services.StopManagerAndAwaitStopped(context.Background(), q.subservices)
 }
 
+// GetTombstones implements TombstonesFinder.
+func (q *BlocksStoreQueryable) GetTombstones(ctx context.Context, userID string, minT, maxT int64) (mimir_tsdb.Tombstones, error) {
+	finder, ok := q.finder.(TombstonesFinder)
+	if !ok {
+		return nil, nil
+	}
+	return finder.GetTombstones(ctx, userID, minT, maxT)
+}
+
+// BlocksFinder returns the finder used to look up the blocks to query.
+func (q *BlocksStoreQueryable) BlocksFinder() BlocksFinder {
+	return q.finder
+}
+
 // Querier returns a new Querier on the storage.
 func (q *BlocksStoreQueryable) Querier(mint, maxt int64) (storage.Querier, error) {
 	if s := q.State(); s != services.Running {
@@ -365,7 +379,7 @@ func (q *blocksStoreQuerier) LabelNames(ctx context.Context, matchers ...*labels
 		convertedMatchers = convertMatchersToLabelMatcher(matchers)
 	)
 
-	queryF := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
+	queryF := func(clients map[BlocksStoreClient][]ulid.ULID, _ map[ulid.ULID]struct{}, minT, maxT int64) ([]ulid.ULID, error) {
 		nameSets, warnings, queriedBlocks, err := q.fetchLabelNamesFromStore(ctx, clients, minT, maxT, tenantID, convertedMatchers)
 		if err != nil {
 			return nil, err
@@ -377,7 +391,7 @@ func (q *blocksStoreQuerier) LabelNames(ctx context.Context, matchers ...*labels
 		return queriedBlocks, nil
 	}
 
-	if err := q.queryWithConsistencyCheck(ctx, spanLog, minT, maxT, tenantID, nil, queryF); err != nil {
+	if err := q.queryWithConsistencyCheck(ctx, spanLog, minT, maxT, tenantID, nil, block.ResolutionRaw, queryF); err != nil {
 		return nil, nil, err
 	}
 
@@ -409,7 +423,7 @@ func (q *blocksStoreQuerier) LabelValues(ctx context.Context, name string, match
 		resWarnings  annotations.Annotations
 	)
 
-	queryF := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
+	queryF := func(clients map[BlocksStoreClient][]ulid.ULID, _ map[ulid.ULID]struct{}, minT, maxT int64) ([]ulid.ULID, error) {
 		valueSets, warnings, queriedBlocks, err := q.fetchLabelValuesFromStore(ctx, name, clients, minT, maxT, tenantID, matchers...)
 		if err != nil {
 			return nil, err
@@ -421,7 +435,7 @@ func (q *blocksStoreQuerier) LabelValues(ctx context.Context, name string, match
 		return queriedBlocks, nil
 	}
 
-	if err := q.queryWithConsistencyCheck(ctx, spanLog, minT, maxT, tenantID, nil, queryF); err != nil {
+	if err := q.queryWithConsistencyCheck(ctx, spanLog, minT, maxT, tenantID, nil, block.ResolutionRaw, queryF); err != nil {
 		return nil, nil, err
 	}
 
@@ -452,21 +466,74 @@ func (q *blocksStoreQuerier) selectSorted(ctx context.Context, sp *storage.Selec
 		return storage.ErrSeriesSet(err)
 	}
 
-	queryF := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error) {
-		seriesSets, queriedBlocks, warnings, startStreamingChunks, chunkEstimator, err := q.fetchSeriesFromStores(ctx, sp, clients, minT, maxT, tenantID, convertedMatchers)
+	resolution, aggregation := selectDownsampling(sp)
+
+	fetchF := func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64, matchers []storepb.LabelMatcher) ([]storage.SeriesSet, []ulid.ULID, error) {
+		seriesSets, queriedBlocks, warnings, startStreamingChunks, chunkEstimator, err := q.fetchSeriesFromStores(ctx, sp, clients, minT, maxT, tenantID, matchers)
 		if err != nil {
-			return nil, err
+			return nil, nil, err
 		}
 
-		resSeriesSets = append(resSeriesSets, seriesSets...)
 		resWarnings.Merge(warnings)
 		streamStarters = append(streamStarters, startStreamingChunks)
 		chunkEstimators = append(chunkEstimators, chunkEstimator)
 
+		return seriesSets, queriedBlocks, nil
+	}
+
+	queryF := func(clients map[BlocksStoreClient][]ulid.ULID, downsampledBlocks map[ulid.ULID]struct{}, minT, maxT int64) ([]ulid.ULID, error) {
+		rawClients, downsampledClients := splitClientsByResolution(clients, downsampledBlocks)
+
+		var queriedBlocks []ulid.ULID
+		if len(rawClients) > 0 {
+			seriesSets, queried, err := fetchF(rawClients, minT, maxT, convertedMatchers)
+			if err != nil {
+				return nil, err
+			}
+
+			resSeriesSets = append(resSeriesSets, seriesSets...)
+			queriedBlocks = append(queriedBlocks, queried...)
+		}
+
+		if len(downsampledClients) > 0 {
+			// Each aggregate is fetched separately, and a downsampled block has only been queried
+			// if the series of all aggregates have been fetched from it.
+			var aggregateSets []storage.SeriesSet
+			queriedAggregates := map[ulid.ULID]int{}
+			aggregates := downsampledAggregates(aggregation)
+
+			for _, aggregate := range aggregates {
+				seriesSets, queried, err := fetchF(downsampledClients, minT, maxT, aggregateMatchers(convertedMatchers, aggregate))
+				if err != nil {
+					return nil, err
+				}
+
+				for i, set := range seriesSets {
+					seriesSets[i] = newDownsampledSeriesSet(set)
+				}
+				aggregateSets = append(aggregateSets, storage.NewMergeSeriesSet(seriesSets, storage.ChainedSeriesMerge))
+				for _, id := range queried {
+					queriedAggregates[id]++
+				}
+			}
+
+			if aggregation == aggregationAvg {
+				resSeriesSets = append(resSeriesSets, newAvgSeriesSet(aggregateSets[0], aggregateSets[1]))
+			} else {
+				resSeriesSets = append(resSeriesSets, aggregateSets[0])
+			}
+
+			for id, count := range queriedAggregates {
+				if count == len(aggregates) {
+					queriedBlocks = append(queriedBlocks, id)
+				}
+			}
+		}
+
 		return queriedBlocks, nil
 	}
 
-	err = q.queryWithConsistencyCheck(ctx, spanLog, minT, maxT, tenantID, shard, queryF)
+	err = q.queryWithConsistencyCheck(ctx, spanLog, minT, maxT, tenantID, shard, resolution, queryF)
 	if err != nil {
 		return storage.ErrSeriesSet(err)
 	}
@@ -498,10 +565,14 @@ func (q *blocksStoreQuerier) selectSorted(ctx context.Context, sp *storage.Selec
 		resWarnings)
 }
 
-type queryFunc func(clients map[BlocksStoreClient][]ulid.ULID, minT, maxT int64) ([]ulid.ULID, error)
+// queryFunc queries the blocks from the store-gateway clients. downsampledBlocks are the IDs of the
+// blocks queried at a downsampled resolution.
+type queryFunc func(clients map[BlocksStoreClient][]ulid.ULID, downsampledBlocks map[ulid.ULID]struct{}, minT, maxT int64) ([]ulid.ULID, error)
 
+// queryWithConsistencyCheck queries the blocks of the tenant within minT and maxT, retrying the blocks which
+// haven't been queried. Blocks are queried at the coarsest resolution not coarser than the input one.
 func (q *blocksStoreQuerier) queryWithConsistencyCheck(
-	ctx context.Context, spanLog *spanlogger.SpanLogger, minT, maxT int64, tenantID string, shard *sharding.ShardSelector, queryF queryFunc,
+	ctx context.Context, spanLog *spanlogger.SpanLogger, minT, maxT int64, tenantID string, shard *sharding.ShardSelector, resolution int64, queryF queryFunc,
 ) error {
 	now := time.Now()
 
@@ -527,6 +598,11 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(
 
 	q.metrics.blocksFound.Add(float64(len(knownBlocks)))
 
+	knownBlocks, downsampledBlocks := selectBlocksByResolution(knownBlocks, resolution)
+	if len(downsampledBlocks) > 0 {
+		spanLog.DebugLog("msg", "querying downsampled blocks", "resolution", resolution, "downsampled", len(downsampledBlocks))
+	}
+
 	if shard != nil && shard.ShardCount > 0 {
 		spanLog.DebugLog("msg", "filtering blocks due to sharding", "blocksBeforeFiltering", knownBlocks.String(), "shardID", shard.LabelValue())
 
@@ -569,7 +645,7 @@ func (q *blocksStoreQuerier) queryWithConsistencyCheck(
 
 		// Fetch series from stores. If an error occur we do not retry because retries
 		// are only meant to cover missing blocks.
-		queriedBlocks, err := queryF(clients, minT, maxT)
+		queriedBlocks, err := queryF(clients, downsampledBlocks, minT, maxT)
 		if err != nil {
 			return err
 		}
@@ -3,20 +3,29 @@
 package querier
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"sort"
+	"sync"
+	"time"
 
 	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/tenant"
 	jsoniter "github.com/json-iterator/go"
 	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"golang.org/x/exp/slices"
+	"golang.org/x/sync/errgroup"
 
 	"github.com/grafana/mimir/pkg/cardinality"
 	"github.com/grafana/mimir/pkg/distributor"
 	ingester_client "github.com/grafana/mimir/pkg/ingester/client"
 	"github.com/grafana/mimir/pkg/querier/api"
 	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/limiter"
 	util_math "github.com/grafana/mimir/pkg/util/math"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
@@ -128,6 +137,327 @@ func ActiveSeriesCardinalityHandler(d Distributor, limits *validation.Overrides)
 	})
 }
 
+// SeriesGrowthCardinalityHandler creates handler for the series growth cardinality endpoint. It returns the per-metric
+// series counts now and at the beginning of each requested window, along with the series churned (seen in the largest
+// window but no longer receiving samples) per metric and per value of the requested label names. Series counts are
+// looked up through the queryable, which queries both the ingesters and the store-gateways, while the in-memory series
+// counts are the ingesters head statistics. The total number of series in the blocks at the beginning of each window
+// is read from the bucket index through the finder, if not nil.
+func SeriesGrowthCardinalityHandler(d Distributor, queryable storage.Queryable, finder BlocksFinder, limits *validation.Overrides) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		// Guarantee request's context is for a single tenant id
+		tenantID, err := tenant.TenantID(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if !limits.CardinalityAnalysisEnabled(tenantID) {
+			http.Error(w, fmt.Sprintf("cardinality analysis is disabled for the tenant: %v", tenantID), http.StatusBadRequest)
+			return
+		}
+
+		req, err := cardinality.DecodeSeriesGrowthRequest(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if err := validateSeriesGrowthWindows(tenantID, req.Windows, limits); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		seriesLimiter := newSeriesGrowthLimiter(limits.MaxFetchedSeriesPerQuery(tenantID))
+		res, err := seriesGrowthCardinality(ctx, d, queryable, finder, seriesLimiter, tenantID, req, time.Now())
+		if validation.IsLimitError(err) {
+			http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+			return
+		}
+		if err != nil {
+			respondFromError(err, w)
+			return
+		}
+		util.WriteJSONResponse(w, res)
+	})
+}
+
+const (
+	// seriesGrowthLookback is the time range in which a series must have samples to be counted
+	// at a given point in time. It matches the default PromQL lookback delta.
+	seriesGrowthLookback = 5 * time.Minute
+
+	// seriesGrowthConcurrency is the max number of concurrent series lookups of a request.
+	seriesGrowthConcurrency = 2
+)
+
+// validateSeriesGrowthWindows checks that the series looked up over the windows don't exceed the tenant's query limits,
+// so that the series counts aren't silently computed over a shorter time range.
+func validateSeriesGrowthWindows(tenantID string, windows []time.Duration, limits *validation.Overrides) error {
+	maxWindow := slices.Max(windows)
+	if maxLookback := limits.MaxQueryLookback(tenantID); maxLookback > 0 && maxWindow+seriesGrowthLookback > maxLookback {
+		return fmt.Errorf("the window %s exceeds the max query lookback %s", model.Duration(maxWindow), model.Duration(maxLookback))
+	}
+	if maxLength := limits.MaxLabelsQueryLength(tenantID); maxLength > 0 && maxWindow > maxLength {
+		return fmt.Errorf("the window %s exceeds the max labels query length %s", model.Duration(maxWindow), model.Duration(maxLength))
+	}
+	if maxLength := limits.MaxPartialQueryLength(tenantID); maxLength > 0 && maxWindow > maxLength {
+		return fmt.Errorf("the window %s exceeds the max query length %s", model.Duration(maxWindow), model.Duration(maxLength))
+	}
+	return nil
+}
+
+// seriesGrowthLimiter enforces the max fetched series per query limit on the unique series looked up over all
+// the time ranges of a request.
+type seriesGrowthLimiter struct {
+	maxSeries int
+
+	mtx    sync.Mutex
+	series map[uint64]struct{}
+}
+
+func newSeriesGrowthLimiter(maxSeries int) *seriesGrowthLimiter {
+	return &seriesGrowthLimiter{maxSeries: maxSeries, series: map[uint64]struct{}{}}
+}
+
+func (l *seriesGrowthLimiter) addSeries(lbls labels.Labels) error {
+	if l.maxSeries <= 0 {
+		return nil
+	}
+
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	l.series[lbls.Hash()] = struct{}{}
+	if len(l.series) > l.maxSeries {
+		return limiter.NewMaxSeriesHitLimitError(uint64(l.maxSeries))
+	}
+	return nil
+}
+
+// seriesCounts holds the number of series, in total and by metric name and by label value.
+type seriesCounts struct {
+	total        uint64
+	byMetric     map[string]uint64
+	byLabelValue map[model.LabelName]map[string]uint64
+}
+
+func seriesGrowthCardinality(ctx context.Context, d Distributor, queryable storage.Queryable, finder BlocksFinder, seriesLimiter *seriesGrowthLimiter, tenantID string, req *cardinality.SeriesGrowthRequest, now time.Time) (*api.SeriesGrowthCardinalityResponse, error) {
+	// Look up the series now, at the beginning of each window, and over the largest window.
+	maxWindow := slices.Max(req.Windows)
+	ranges := make([][2]time.Time, 0, len(req.Windows)+2)
+	ranges = append(ranges, [2]time.Time{now.Add(-seriesGrowthLookback), now})
+	for _, window := range req.Windows {
+		ranges = append(ranges, [2]time.Time{now.Add(-window - seriesGrowthLookback), now.Add(-window)})
+	}
+	ranges = append(ranges, [2]time.Time{now.Add(-maxWindow), now})
+
+	// The in-memory series are looked up in the ingesters concurrently to the series lookups,
+	// which are limited to seriesGrowthConcurrency.
+	var (
+		inMemoryTotal uint64
+		inMemory      *ingester_client.LabelValuesCardinalityResponse
+	)
+	g, gCtx := errgroup.WithContext(ctx)
+	g.SetLimit(1 + seriesGrowthConcurrency)
+	g.Go(func() (err error) {
+		inMemoryTotal, inMemory, err = d.LabelValuesCardinality(gCtx, append([]model.LabelName{labels.MetricName}, req.LabelNames...), req.Matchers, cardinality.InMemoryMethod)
+		return err
+	})
+
+	counts := make([]seriesCounts, len(ranges))
+	for i := range ranges {
+		i := i
+		g.Go(func() (err error) {
+			counts[i], err = countSeries(gCtx, queryable, seriesLimiter, ranges[i][0], ranges[i][1], req.Matchers, req.LabelNames)
+			return err
+		})
+	}
+
+	if err := g.Wait(); err != nil {
+		return nil, err
+	}
+
+	blocksSeries, err := countBlocksSeries(ctx, finder, tenantID, req.Windows, now)
+	if err != nil {
+		return nil, err
+	}
+
+	inMemoryByLabelValue := map[string]map[string]uint64{}
+	for _, item := range inMemory.GetItems() {
+		inMemoryByLabelValue[item.LabelName] = item.LabelValueSeries
+	}
+
+	current, windows, seen := counts[0], counts[1:len(counts)-1], counts[len(counts)-1]
+	return &api.SeriesGrowthCardinalityResponse{
+		SeriesCountTotal:         current.total,
+		InMemorySeriesCountTotal: inMemoryTotal,
+		Windows:                  formatSeriesGrowthWindows(req.Windows),
+		BlocksSeriesCount:        blocksSeries,
+		Metrics:                  toMetricsSeriesGrowth(req.Windows, current, windows, seen, inMemoryByLabelValue[labels.MetricName], req.Limit),
+		LabelValuesChurn:         toLabelValuesChurn(req.LabelNames, current, seen, inMemoryByLabelValue, req.Limit),
+	}, nil
+}
+
+// countBlocksSeries returns the total number of series in the blocks containing samples at the beginning of each
+// window, from the blocks stats stored in the bucket index. The blocks marked for deletion are skipped, because
+// their series are in the blocks they have been compacted into. It returns nil if the finder is nil.
+func countBlocksSeries(ctx context.Context, finder BlocksFinder, tenantID string, windows []time.Duration, now time.Time) ([]api.SeriesGrowthBlocksWindow, error) {
+	if finder == nil {
+		return nil, nil
+	}
+
+	counts := make([]api.SeriesGrowthBlocksWindow, 0, len(windows))
+	for _, window := range windows {
+		start := now.Add(-window)
+		blocks, deletionMarks, err := finder.GetBlocks(ctx, tenantID, start.Add(-seriesGrowthLookback).UnixMilli(), start.UnixMilli())
+		if err != nil {
+			return nil, err
+		}
+
+		count := api.SeriesGrowthBlocksWindow{Window: model.Duration(window).String()}
+		for _, b := range blocks {
+			if _, deleted := deletionMarks[b.ID]; !deleted {
+				count.SeriesCount += b.NumSeries
+			}
+		}
+		counts = append(counts, count)
+	}
+	return counts, nil
+}
+
+// countSeries counts the series with samples between start and end matching the matchers.
+func countSeries(ctx context.Context, queryable storage.Queryable, seriesLimiter *seriesGrowthLimiter, start, end time.Time, matchers []*labels.Matcher, labelNames []model.LabelName) (seriesCounts, error) {
+	counts := seriesCounts{
+		byMetric:     map[string]uint64{},
+		byLabelValue: make(map[model.LabelName]map[string]uint64, len(labelNames)),
+	}
+	for _, name := range labelNames {
+		counts.byLabelValue[name] = map[string]uint64{}
+	}
+
+	q, err := queryable.Querier(start.UnixMilli(), end.UnixMilli())
+	if err != nil {
+		return counts, err
+	}
+	defer q.Close()
+
+	hints := &storage.SelectHints{
+		Start: start.UnixMilli(),
+		End:   end.UnixMilli(),
+		Func:  "series", // There is no need to fetch the chunks.
+	}
+
+	set := q.Select(ctx, false, hints, matchers...)
+	for set.Next() {
+		lbls := set.At().Labels()
+		if err := seriesLimiter.addSeries(lbls); err != nil {
+			return counts, err
+		}
+
+		counts.total++
+		counts.byMetric[lbls.Get(labels.MetricName)]++
+		for _, name := range labelNames {
+			if value := lbls.Get(string(name)); value != "" {
+				counts.byLabelValue[name][value]++
+			}
+		}
+	}
+	return counts, set.Err()
+}
+
+func formatSeriesGrowthWindows(windows []time.Duration) []string {
+	formatted := make([]string, 0, len(windows))
+	for _, window := range windows {
+		formatted = append(formatted, model.Duration(window).String())
+	}
+	return formatted
+}
+
+// churnedSeries returns the number of series seen in the largest window which are no longer receiving samples.
+func churnedSeries(seen, current uint64) uint64 {
+	if seen < current {
+		return 0
+	}
+	return seen - current
+}
+
+// toMetricsSeriesGrowth builds the per-metric series growth, sorted by the growth in the first window in
+// DESC order and by metric name in ASC order.
+func toMetricsSeriesGrowth(windows []time.Duration, current seriesCounts, windowCounts []seriesCounts, seen seriesCounts, inMemory map[string]uint64, limit int) []api.MetricSeriesGrowth {
+	names := map[string]struct{}{}
+	for _, counts := range append([]seriesCounts{current, seen}, windowCounts...) {
+		for name := range counts.byMetric {
+			names[name] = struct{}{}
+		}
+	}
+	for name := range inMemory {
+		names[name] = struct{}{}
+	}
+
+	metrics := make([]api.MetricSeriesGrowth, 0, len(names))
+	for name := range names {
+		metric := api.MetricSeriesGrowth{
+			MetricName:          name,
+			SeriesCount:         current.byMetric[name],
+			InMemorySeriesCount: inMemory[name],
+			ChurnedSeriesCount:  churnedSeries(seen.byMetric[name], current.byMetric[name]),
+			Windows:             make([]api.SeriesGrowthWindow, 0, len(windows)),
+		}
+		for i, window := range windows {
+			metric.Windows = append(metric.Windows, api.SeriesGrowthWindow{
+				Window:      model.Duration(window).String(),
+				SeriesCount: windowCounts[i].byMetric[name],
+				Growth:      int64(current.byMetric[name]) - int64(windowCounts[i].byMetric[name]),
+			})
+		}
+		metrics = append(metrics, metric)
+	}
+
+	sort.Slice(metrics, func(i, j int) bool {
+		left, right := metrics[i], metrics[j]
+		if left.Windows[0].Growth != right.Windows[0].Growth {
+			return left.Windows[0].Growth > right.Windows[0].Growth
+		}
+		return left.MetricName < right.MetricName
+	})
+
+	return metrics[:util_math.Min(len(metrics), limit)]
+}
+
+// toLabelValuesChurn builds the churned series per label value, sorted by churned series count in DESC order,
+// and by label name and value in ASC order. Label values without churned series are not returned.
+func toLabelValuesChurn(labelNames []model.LabelName, current, seen seriesCounts, inMemory map[string]map[string]uint64, limit int) []api.LabelValueChurnItem {
+	items := []api.LabelValueChurnItem{}
+	for _, name := range labelNames {
+		for value, seenCount := range seen.byLabelValue[name] {
+			churned := churnedSeries(seenCount, current.byLabelValue[name][value])
+			if churned == 0 {
+				continue
+			}
+			items = append(items, api.LabelValueChurnItem{
+				LabelName:           string(name),
+				LabelValue:          value,
+				SeriesCount:         seenCount,
+				InMemorySeriesCount: inMemory[string(name)][value],
+				ChurnedSeriesCount:  churned,
+			})
+		}
+	}
+
+	sort.Slice(items, func(i, j int) bool {
+		left, right := items[i], items[j]
+		if left.ChurnedSeriesCount != right.ChurnedSeriesCount {
+			return left.ChurnedSeriesCount > right.ChurnedSeriesCount
+		}
+		if left.LabelName != right.LabelName {
+			return left.LabelName < right.LabelName
+		}
+		return left.LabelValue < right.LabelValue
+	})
+
+	return items[:util_math.Min(len(items), limit)]
+}
+
 func respondFromError(err error, w http.ResponseWriter) {
 	httpResp, ok := httpgrpc.HTTPResponseFromError(errors.Cause(err))
 	if !ok {
@@ -12,12 +12,15 @@ import (
 	"net/url"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/user"
+	"github.com/oklog/ulid"
 	"github.com/prometheus/common/model"
 	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
@@ -26,6 +29,8 @@ import (
 	pkg_distributor "github.com/grafana/mimir/pkg/distributor"
 	"github.com/grafana/mimir/pkg/ingester/client"
 	"github.com/grafana/mimir/pkg/querier/api"
+	"github.com/grafana/mimir/pkg/storage/series"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
@@ -334,6 +339,32 @@ func TestLabelValuesCardinalityHandler_Success(t *testing.T) {
 				}},
 			},
 		},
+		"should return the series count of the label name without any label value if the limit is 0": {
+			getRequestParams: "?label_names[]=__name__&count_method=inmemory&limit=0",
+			postRequestForm: url.Values{
+				"label_names[]": []string{"__name__"},
+				"count_method":  []string{"inmemory"},
+				"limit":         []string{"0"},
+			},
+			labelNames: []model.LabelName{"__name__"},
+			matcher:    []*labels.Matcher(nil),
+			scope:      cardinality.InMemoryMethod,
+			labelValuesCardinality: &client.LabelValuesCardinalityResponse{
+				Items: []*client.LabelValueSeriesCount{{
+					LabelName:        labels.MetricName,
+					LabelValueSeries: map[string]uint64{"test_1": 10, "test_2": 5},
+				}},
+			},
+			expectedResponse: api.LabelValuesCardinalityResponse{
+				SeriesCountTotal: seriesCountTotal,
+				Labels: []api.LabelNamesCardinality{{
+					LabelName:        "__name__",
+					LabelValuesCount: 2,
+					SeriesCount:      15,
+					Cardinality:      []api.LabelValuesCardinality{},
+				}},
+			},
+		},
 		"should return the label values cardinality for the specified label name with matching selector": {
 			getRequestParams: "?label_names[]=__name__&selector={__name__='test_1'}",
 			postRequestForm: url.Values{
@@ -902,6 +933,172 @@ func BenchmarkActiveSeriesHandler_ServeHTTP(b *testing.B) {
 	}
 }
 
+func TestSeriesGrowthCardinalityHandler(t *testing.T) {
+	now := time.Now()
+	queryable := &mockSeriesGrowthQueryable{series: []mockSeriesGrowthSeries{
+		{lbls: labels.FromStrings(labels.MetricName, "up", "job", "a"), timestamps: []time.Time{now.Add(-time.Minute), now.Add(-24*time.Hour - time.Minute)}},
+		{lbls: labels.FromStrings(labels.MetricName, "up", "job", "b"), timestamps: []time.Time{now.Add(-time.Minute)}},
+		{lbls: labels.FromStrings(labels.MetricName, "up", "job", "c"), timestamps: []time.Time{now.Add(-7*24*time.Hour - time.Minute), now.Add(-48 * time.Hour)}},
+		{lbls: labels.FromStrings(labels.MetricName, "requests", "pod", "p1"), timestamps: []time.Time{now.Add(-24*time.Hour - time.Minute), now.Add(-6 * 24 * time.Hour)}},
+		{lbls: labels.FromStrings(labels.MetricName, "requests", "pod", "p2"), timestamps: []time.Time{now.Add(-time.Minute)}},
+	}}
+
+	matchers := []*labels.Matcher{labels.MustNewMatcher(labels.MatchNotEqual, labels.MetricName, "")}
+	distributor := &mockDistributor{}
+	distributor.On("LabelValuesCardinality", mock.Anything, []model.LabelName{labels.MetricName, "pod"}, matchers, cardinality.InMemoryMethod).Return(uint64(6), &client.LabelValuesCardinalityResponse{Items: []*client.LabelValueSeriesCount{
+		{LabelName: labels.MetricName, LabelValueSeries: map[string]uint64{"up": 4, "requests": 2}},
+		{LabelName: "pod", LabelValueSeries: map[string]uint64{"p1": 1, "p2": 1}},
+	}}, nil)
+
+	// The blocks containing the beginning of the 1d window, and of the 7d window, one of them being marked for deletion.
+	dayBlock := &bucketindex.Block{ID: ulid.MustNew(1, nil), NumSeries: 10}
+	weekBlock := &bucketindex.Block{ID: ulid.MustNew(2, nil), NumSeries: 20}
+	deletedBlock := &bucketindex.Block{ID: ulid.MustNew(3, nil), NumSeries: 30}
+	isDayWindow := mock.MatchedBy(func(maxT int64) bool { return maxT > now.Add(-48*time.Hour).UnixMilli() })
+	isWeekWindow := mock.MatchedBy(func(maxT int64) bool { return maxT <= now.Add(-48*time.Hour).UnixMilli() })
+	finder := &blocksFinderMock{}
+	finder.On("GetBlocks", mock.Anything, "team-a", mock.Anything, isDayWindow).Return(bucketindex.Blocks{dayBlock}, map[ulid.ULID]*bucketindex.BlockDeletionMark{}, nil)
+	finder.On("GetBlocks", mock.Anything, "team-a", mock.Anything, isWeekWindow).Return(bucketindex.Blocks{weekBlock, deletedBlock}, map[ulid.ULID]*bucketindex.BlockDeletionMark{deletedBlock.ID: {ID: deletedBlock.ID}}, nil)
+
+	overrides, err := validation.NewOverrides(validation.Limits{CardinalityAnalysisEnabled: true}, nil)
+	require.NoError(t, err)
+	handler := SeriesGrowthCardinalityHandler(distributor, queryable, finder, overrides)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, createRequest("/ignored-url?selector={__name__!=\"\"}&windows[]=1d&windows[]=7d&label_names[]=pod", "team-a"))
+	require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+	response := api.SeriesGrowthCardinalityResponse{}
+	require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+
+	assert.Equal(t, api.SeriesGrowthCardinalityResponse{
+		SeriesCountTotal:         3,
+		InMemorySeriesCountTotal: 6,
+		Windows:                  []string{"1d", "1w"},
+		BlocksSeriesCount: []api.SeriesGrowthBlocksWindow{
+			{Window: "1d", SeriesCount: 10},
+			{Window: "1w", SeriesCount: 20},
+		},
+		Metrics: []api.MetricSeriesGrowth{
+			{
+				MetricName:          "up",
+				SeriesCount:         2,
+				InMemorySeriesCount: 4,
+				ChurnedSeriesCount:  1,
+				Windows: []api.SeriesGrowthWindow{
+					{Window: "1d", SeriesCount: 1, Growth: 1},
+					{Window: "1w", SeriesCount: 1, Growth: 1},
+				},
+			},
+			{
+				MetricName:          "requests",
+				SeriesCount:         1,
+				InMemorySeriesCount: 2,
+				ChurnedSeriesCount:  1,
+				Windows: []api.SeriesGrowthWindow{
+					{Window: "1d", SeriesCount: 1, Growth: 0},
+					{Window: "1w", SeriesCount: 0, Growth: 1},
+				},
+			},
+		},
+		LabelValuesChurn: []api.LabelValueChurnItem{
+			{LabelName: "pod", LabelValue: "p1", SeriesCount: 1, InMemorySeriesCount: 1, ChurnedSeriesCount: 1},
+		},
+	}, response)
+
+	t.Run("should limit the number of returned items", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, createRequest("/ignored-url?selector={__name__!=\"\"}&windows[]=1d&windows[]=7d&label_names[]=pod&limit=1", "team-a"))
+		require.Equal(t, http.StatusOK, recorder.Code, recorder.Body.String())
+
+		response := api.SeriesGrowthCardinalityResponse{}
+		require.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		require.Len(t, response.Metrics, 1)
+		assert.Equal(t, "up", response.Metrics[0].MetricName)
+		assert.Len(t, response.LabelValuesChurn, 1)
+	})
+
+	t.Run("should fail if cardinality analysis is disabled", func(t *testing.T) {
+		overrides, err := validation.NewOverrides(validation.Limits{CardinalityAnalysisEnabled: false}, nil)
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		SeriesGrowthCardinalityHandler(distributor, queryable, nil, overrides).ServeHTTP(recorder, createRequest("/ignored-url?selector={job=\"a\"}", "team-a"))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("should fail on invalid request", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, createRequest("/ignored-url?selector={job=\"a\"}&windows[]=invalid", "team-a"))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("should fail without selector", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, createRequest("/ignored-url?windows[]=1d", "team-a"))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("should fail if a window exceeds the query limits", func(t *testing.T) {
+		for name, limits := range map[string]validation.Limits{
+			"max query lookback":      {CardinalityAnalysisEnabled: true, MaxQueryLookback: model.Duration(7 * 24 * time.Hour)},
+			"max labels query length": {CardinalityAnalysisEnabled: true, MaxLabelsQueryLength: model.Duration(24 * time.Hour)},
+			"max query length":        {CardinalityAnalysisEnabled: true, MaxPartialQueryLength: model.Duration(24 * time.Hour)},
+		} {
+			t.Run(name, func(t *testing.T) {
+				overrides, err := validation.NewOverrides(limits, nil)
+				require.NoError(t, err)
+
+				recorder := httptest.NewRecorder()
+				SeriesGrowthCardinalityHandler(distributor, queryable, nil, overrides).ServeHTTP(recorder, createRequest("/ignored-url?selector={job=\"a\"}&windows[]=1d&windows[]=7d", "team-a"))
+				assert.Equal(t, http.StatusBadRequest, recorder.Code)
+				assert.Contains(t, recorder.Body.String(), name)
+			})
+		}
+	})
+
+	t.Run("should fail if the series looked up over all the windows exceed the max fetched series", func(t *testing.T) {
+		overrides, err := validation.NewOverrides(validation.Limits{CardinalityAnalysisEnabled: true, MaxFetchedSeriesPerQuery: 4}, nil)
+		require.NoError(t, err)
+
+		recorder := httptest.NewRecorder()
+		SeriesGrowthCardinalityHandler(distributor, queryable, nil, overrides).ServeHTTP(recorder, createRequest("/ignored-url?selector={__name__!=\"\"}&windows[]=1d&windows[]=7d&label_names[]=pod", "team-a"))
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+	})
+
+	t.Run("should return the distributor error", func(t *testing.T) {
+		distributor := &mockDistributor{}
+		distributor.On("LabelValuesCardinality", mock.Anything, mock.Anything, mock.Anything, mock.Anything).Return(uint64(0), &client.LabelValuesCardinalityResponse{}, httpgrpc.Errorf(http.StatusTooManyRequests, "too many requests"))
+
+		recorder := httptest.NewRecorder()
+		SeriesGrowthCardinalityHandler(distributor, queryable, nil, overrides).ServeHTTP(recorder, createRequest("/ignored-url?selector={job=\"a\"}", "team-a"))
+		assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+	})
+}
+
+type mockSeriesGrowthSeries struct {
+	lbls       labels.Labels
+	timestamps []time.Time
+}
+
+// mockSeriesGrowthQueryable returns the series matching the matchers and having samples in the queried time range.
+type mockSeriesGrowthQueryable struct {
+	series []mockSeriesGrowthSeries
+}
+
+func (m *mockSeriesGrowthQueryable) Querier(mint, maxt int64) (storage.Querier, error) {
+	var selected []storage.Series
+	for _, s := range m.series {
+		for _, ts := range s.timestamps {
+			if ts.UnixMilli() >= mint && ts.UnixMilli() <= maxt {
+				selected = append(selected, series.NewConcreteSeries(s.lbls, nil, nil))
+				break
+			}
+		}
+	}
+	return &mockExportQuerier{series: selected}, nil
+}
+
 // createEnabledHandler creates a cardinalityHandler that can be either a LabelNamesCardinalityHandler or a LabelValuesCardinalityHandler
 func createEnabledHandler(t testing.TB, cardinalityHandler func(Distributor, *validation.Overrides) http.Handler, distributor *mockDistributor) http.Handler {
 	limits := validation.Limits{CardinalityAnalysisEnabled: true}
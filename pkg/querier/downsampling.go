@@ -0,0 +1,365 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"github.com/oklog/ulid"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/util/annotations"
+
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/storegateway/storepb"
+)
+
+// aggregationAvg is the average of the samples downsampled in each window, which isn't stored in
+// downsampled blocks but computed from the sum and count aggregates.
+const aggregationAvg = "avg"
+
+// downsamplingResolutions are the resolutions of downsampled blocks, from the coarsest.
+var downsamplingResolutions = []int64{block.Resolution1h, block.Resolution5m}
+
+// selectDownsampling returns the coarsest resolution which satisfies the query, and the aggregate of the
+// downsampled series to query. A resolution satisfies the query if it's not coarser than the query step,
+// and at least two downsampled samples are expected within the range of the selector, so that a sample is
+// always found even if they're not evenly spaced. The raw resolution is returned if the function applied to
+// the selector can't be answered from downsampled series.
+func selectDownsampling(sp *storage.SelectHints) (int64, string) {
+	if sp == nil || sp.Step <= 0 || sp.Func == "series" {
+		return block.ResolutionRaw, ""
+	}
+
+	aggregation, ok := downsampledAggregation(sp)
+	if !ok {
+		return block.ResolutionRaw, ""
+	}
+
+	for _, resolution := range downsamplingResolutions {
+		if resolution <= sp.Step && 2*resolution <= sp.Range {
+			return resolution, aggregation
+		}
+	}
+
+	return block.ResolutionRaw, ""
+}
+
+// downsampledAggregation returns the aggregate of the downsampled series which approximates the raw
+// series the best for the function applied to the selector, and whether there's one.
+func downsampledAggregation(sp *storage.SelectHints) (string, bool) {
+	// Instant selectors pick the latest sample of each series, which isn't stored in downsampled blocks.
+	if sp.Range == 0 {
+		return "", false
+	}
+
+	switch sp.Func {
+	case "min_over_time":
+		return block.AggregationMin, true
+	case "max_over_time":
+		return block.AggregationMax, true
+	case "sum_over_time":
+		return block.AggregationSum, true
+	case "avg_over_time":
+		return aggregationAvg, true
+	default:
+		// Other functions depend on the raw samples: for example count_over_time() or changes(), but also
+		// rate() and increase(), because no aggregate accounts for the counter resets within a window.
+		return "", false
+	}
+}
+
+// downsampledAggregates returns the aggregates to query from downsampled blocks for the aggregation.
+func downsampledAggregates(aggregation string) []string {
+	if aggregation == aggregationAvg {
+		return []string{block.AggregationSum, block.AggregationCount}
+	}
+	return []string{aggregation}
+}
+
+// selectBlocksByResolution returns the blocks to query for the resolution, and the IDs of the downsampled
+// ones. Each raw block is replaced by the coarsest block downsampled from it, which resolution isn't coarser
+// than the input one. Downsampled blocks are otherwise never queried, because their series have an additional
+// aggregation label, so the raw resolution is used for any query which doesn't handle them.
+//
+// Since the bucket index doesn't store the source blocks, a downsampled block is matched to a raw block by
+// time range and compactor shard, and must have been created after the raw block. Raw blocks which aren't
+// the only ones with their time range and shard, for example because they're about to be deduplicated,
+// are not replaced.
+func selectBlocksByResolution(blocks bucketindex.Blocks, resolution int64) (bucketindex.Blocks, map[ulid.ULID]struct{}) {
+	type blockKey struct {
+		minTime, maxTime int64
+		shardID          string
+	}
+
+	hasDownsampled := false
+	rawBlocks := map[blockKey][]*bucketindex.Block{}
+	for _, b := range blocks {
+		if b.Resolution != block.ResolutionRaw {
+			hasDownsampled = true
+			continue
+		}
+		key := blockKey{minTime: b.MinTime, maxTime: b.MaxTime, shardID: b.CompactorShardID}
+		rawBlocks[key] = append(rawBlocks[key], b)
+	}
+	if !hasDownsampled {
+		return blocks, nil
+	}
+
+	replacements := map[ulid.ULID]*bucketindex.Block{}
+	for _, b := range blocks {
+		if b.Resolution == block.ResolutionRaw || b.Resolution > resolution {
+			continue
+		}
+
+		raw := rawBlocks[blockKey{minTime: b.MinTime, maxTime: b.MaxTime, shardID: b.CompactorShardID}]
+		if len(raw) != 1 || b.ID.Compare(raw[0].ID) <= 0 {
+			continue
+		}
+
+		// Prefer the coarsest resolution, and the most recent block to keep the output stable.
+		curr, ok := replacements[raw[0].ID]
+		if !ok || b.Resolution > curr.Resolution || (b.Resolution == curr.Resolution && b.ID.Compare(curr.ID) > 0) {
+			replacements[raw[0].ID] = b
+		}
+	}
+
+	result := make(bucketindex.Blocks, 0, len(blocks))
+	var downsampledIDs map[ulid.ULID]struct{}
+	for _, b := range blocks {
+		if b.Resolution != block.ResolutionRaw {
+			continue
+		}
+
+		if replacement, ok := replacements[b.ID]; ok {
+			if downsampledIDs == nil {
+				downsampledIDs = map[ulid.ULID]struct{}{}
+			}
+			downsampledIDs[replacement.ID] = struct{}{}
+			result = append(result, replacement)
+			continue
+		}
+
+		result = append(result, b)
+	}
+
+	return result, downsampledIDs
+}
+
+// splitClientsByResolution splits the blocks to query from each store-gateway into the raw and downsampled ones.
+func splitClientsByResolution(clients map[BlocksStoreClient][]ulid.ULID, downsampledBlocks map[ulid.ULID]struct{}) (raw, downsampled map[BlocksStoreClient][]ulid.ULID) {
+	if len(downsampledBlocks) == 0 {
+		return clients, nil
+	}
+
+	raw = map[BlocksStoreClient][]ulid.ULID{}
+	downsampled = map[BlocksStoreClient][]ulid.ULID{}
+	for client, blockIDs := range clients {
+		for _, id := range blockIDs {
+			if _, ok := downsampledBlocks[id]; ok {
+				downsampled[client] = append(downsampled[client], id)
+			} else {
+				raw[client] = append(raw[client], id)
+			}
+		}
+	}
+
+	return raw, downsampled
+}
+
+// aggregateMatchers returns the matchers to select the series of an aggregate from downsampled blocks.
+func aggregateMatchers(matchers []storepb.LabelMatcher, aggregate string) []storepb.LabelMatcher {
+	result := make([]storepb.LabelMatcher, 0, len(matchers)+1)
+	result = append(result, matchers...)
+	return append(result, storepb.LabelMatcher{Type: storepb.LabelMatcher_EQ, Name: block.AggregationLabel, Value: aggregate})
+}
+
+// newDownsampledSeriesSet returns a SeriesSet of the downsampled series of an aggregate, without the
+// aggregation label, so that they can be merged with the raw series. The order of the series is kept,
+// given they all had the same aggregation label.
+func newDownsampledSeriesSet(set storage.SeriesSet) storage.SeriesSet {
+	return &downsampledSeriesSet{SeriesSet: set}
+}
+
+type downsampledSeriesSet struct {
+	storage.SeriesSet
+}
+
+func (s *downsampledSeriesSet) At() storage.Series {
+	series := s.SeriesSet.At()
+	return &downsampledSeries{
+		Series: series,
+		lbls:   labels.NewBuilder(series.Labels()).Del(block.AggregationLabel).Labels(),
+	}
+}
+
+type downsampledSeries struct {
+	storage.Series
+
+	lbls labels.Labels
+}
+
+func (s *downsampledSeries) Labels() labels.Labels {
+	return s.lbls
+}
+
+// newAvgSeriesSet returns a SeriesSet of the average of each window of the downsampled series, computed from
+// their sum and count aggregates. Both input sets must be sorted and have no aggregation label.
+// Series without both aggregates are dropped.
+func newAvgSeriesSet(sum, count storage.SeriesSet) storage.SeriesSet {
+	return &avgSeriesSet{sum: sum, count: count}
+}
+
+type avgSeriesSet struct {
+	sum, count storage.SeriesSet
+
+	// The input sets are advanced on the first call to Next(), since store-gateway streams
+	// may not have been started when the set is created.
+	started        bool
+	sumOK, countOK bool
+	curr           storage.Series
+}
+
+func (s *avgSeriesSet) Next() bool {
+	if !s.started {
+		s.started = true
+		s.sumOK = s.sum.Next()
+		s.countOK = s.count.Next()
+	}
+
+	for s.sumOK && s.countOK {
+		sum, count := s.sum.At(), s.count.At()
+
+		switch c := labels.Compare(sum.Labels(), count.Labels()); {
+		case c < 0:
+			s.sumOK = s.sum.Next()
+		case c > 0:
+			s.countOK = s.count.Next()
+		default:
+			s.curr = &avgSeries{sum: sum, count: count}
+			s.sumOK = s.sum.Next()
+			s.countOK = s.count.Next()
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *avgSeriesSet) At() storage.Series {
+	return s.curr
+}
+
+func (s *avgSeriesSet) Err() error {
+	if err := s.sum.Err(); err != nil {
+		return err
+	}
+	return s.count.Err()
+}
+
+func (s *avgSeriesSet) Warnings() annotations.Annotations {
+	var warnings annotations.Annotations
+	warnings.Merge(s.sum.Warnings())
+	warnings.Merge(s.count.Warnings())
+	return warnings
+}
+
+type avgSeries struct {
+	sum, count storage.Series
+}
+
+func (s *avgSeries) Labels() labels.Labels {
+	return s.sum.Labels()
+}
+
+func (s *avgSeries) Iterator(it chunkenc.Iterator) chunkenc.Iterator {
+	if avg, ok := it.(*avgIterator); ok {
+		avg.reset(s.sum.Iterator(avg.sum), s.count.Iterator(avg.count))
+		return avg
+	}
+
+	avg := &avgIterator{}
+	avg.reset(s.sum.Iterator(nil), s.count.Iterator(nil))
+	return avg
+}
+
+// avgIterator iterates the samples of the sum and count aggregates with the same timestamp,
+// dividing the sum by the count.
+type avgIterator struct {
+	sum, count chunkenc.Iterator
+
+	valid bool
+	t     int64
+	v     float64
+}
+
+func (it *avgIterator) reset(sum, count chunkenc.Iterator) {
+	*it = avgIterator{sum: sum, count: count}
+}
+
+func (it *avgIterator) Next() chunkenc.ValueType {
+	if it.sum.Next() != chunkenc.ValFloat {
+		return it.done()
+	}
+	return it.align()
+}
+
+func (it *avgIterator) Seek(t int64) chunkenc.ValueType {
+	if it.valid && it.t >= t {
+		return chunkenc.ValFloat
+	}
+	if it.sum.Seek(t) != chunkenc.ValFloat {
+		return it.done()
+	}
+	return it.align()
+}
+
+// align advances the iterators until they're at samples with the same timestamp, starting
+// from the current sum sample.
+func (it *avgIterator) align() chunkenc.ValueType {
+	for {
+		sumT, sum := it.sum.At()
+		if it.count.Seek(sumT) != chunkenc.ValFloat {
+			return it.done()
+		}
+
+		countT, count := it.count.At()
+		if countT == sumT {
+			it.valid, it.t, it.v = true, sumT, sum/count
+			return chunkenc.ValFloat
+		}
+
+		if it.sum.Seek(countT) != chunkenc.ValFloat {
+			return it.done()
+		}
+	}
+}
+
+func (it *avgIterator) done() chunkenc.ValueType {
+	it.valid = false
+	return chunkenc.ValNone
+}
+
+func (it *avgIterator) At() (int64, float64) {
+	return it.t, it.v
+}
+
+func (it *avgIterator) AtHistogram() (int64, *histogram.Histogram) {
+	return it.t, nil
+}
+
+func (it *avgIterator) AtFloatHistogram() (int64, *histogram.FloatHistogram) {
+	return it.t, nil
+}
+
+func (it *avgIterator) AtT() int64 {
+	return it.t
+}
+
+func (it *avgIterator) Err() error {
+	if err := it.sum.Err(); err != nil {
+		return err
+	}
+	return it.count.Err()
+}
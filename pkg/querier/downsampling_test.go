@@ -0,0 +1,409 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+
+	"github.com/grafana/mimir/pkg/storage/series"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
+	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
+	"github.com/grafana/mimir/pkg/storegateway/storepb"
+)
+
+func TestSelectDownsampling(t *testing.T) {
+	var (
+		minute = time.Minute.Milliseconds()
+		hour   = time.Hour.Milliseconds()
+	)
+
+	tests := map[string]struct {
+		hints               *storage.SelectHints
+		expectedResolution  int64
+		expectedAggregation string
+	}{
+		"no hints": {
+			expectedResolution: block.ResolutionRaw,
+		},
+		"instant query": {
+			hints:              &storage.SelectHints{Range: 10 * hour, Func: "max_over_time"},
+			expectedResolution: block.ResolutionRaw,
+		},
+		"series request": {
+			hints:              &storage.SelectHints{Step: hour, Func: "series"},
+			expectedResolution: block.ResolutionRaw,
+		},
+		"range selector with a step finer than any resolution": {
+			hints:              &storage.SelectHints{Step: minute, Range: 10 * hour, Func: "max_over_time"},
+			expectedResolution: block.ResolutionRaw,
+		},
+		"range selector with a range too short for any resolution": {
+			hints:              &storage.SelectHints{Step: hour, Range: 5 * minute, Func: "max_over_time"},
+			expectedResolution: block.ResolutionRaw,
+		},
+		"range selector satisfied by the 5m resolution": {
+			hints:               &storage.SelectHints{Step: hour, Range: 30 * minute, Func: "max_over_time"},
+			expectedResolution:  block.Resolution5m,
+			expectedAggregation: block.AggregationMax,
+		},
+		"range selector satisfied by the 1h resolution": {
+			hints:               &storage.SelectHints{Step: 2 * hour, Range: 2 * hour, Func: "min_over_time"},
+			expectedResolution:  block.Resolution1h,
+			expectedAggregation: block.AggregationMin,
+		},
+		"range selector with a step too short for the 1h resolution": {
+			hints:               &storage.SelectHints{Step: 30 * minute, Range: 2 * hour, Func: "sum_over_time"},
+			expectedResolution:  block.Resolution5m,
+			expectedAggregation: block.AggregationSum,
+		},
+		"range selector averaged over time": {
+			hints:               &storage.SelectHints{Step: 2 * hour, Range: 2 * hour, Func: "avg_over_time"},
+			expectedResolution:  block.Resolution1h,
+			expectedAggregation: aggregationAvg,
+		},
+		"range selector of a function which depends on the raw samples": {
+			hints:              &storage.SelectHints{Step: 2 * hour, Range: 2 * hour, Func: "count_over_time"},
+			expectedResolution: block.ResolutionRaw,
+		},
+		"range selector of a counter function": {
+			hints:              &storage.SelectHints{Step: 2 * hour, Range: 2 * hour, Func: "rate"},
+			expectedResolution: block.ResolutionRaw,
+		},
+		"instant selector": {
+			hints:              &storage.SelectHints{Step: 2 * hour, Func: "max"},
+			expectedResolution: block.ResolutionRaw,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			resolution, aggregation := selectDownsampling(testData.hints)
+			assert.Equal(t, testData.expectedResolution, resolution)
+			assert.Equal(t, testData.expectedAggregation, aggregation)
+		})
+	}
+}
+
+func TestSelectBlocksByResolution(t *testing.T) {
+	var (
+		raw1           = &bucketindex.Block{ID: ulid.MustNew(1, nil), MinTime: 0, MaxTime: 10}
+		raw2           = &bucketindex.Block{ID: ulid.MustNew(2, nil), MinTime: 10, MaxTime: 20, CompactorShardID: "1_of_2"}
+		raw3           = &bucketindex.Block{ID: ulid.MustNew(3, nil), MinTime: 10, MaxTime: 20, CompactorShardID: "2_of_2"}
+		raw4           = &bucketindex.Block{ID: ulid.MustNew(4, nil), MinTime: 20, MaxTime: 30}
+		raw4Duplicate  = &bucketindex.Block{ID: ulid.MustNew(5, nil), MinTime: 20, MaxTime: 30}
+		raw1Downsample = &bucketindex.Block{ID: ulid.MustNew(10, nil), MinTime: 0, MaxTime: 10, Resolution: block.Resolution5m}
+		raw1Newer      = &bucketindex.Block{ID: ulid.MustNew(11, nil), MinTime: 0, MaxTime: 10, Resolution: block.Resolution5m}
+		raw1Coarser    = &bucketindex.Block{ID: ulid.MustNew(12, nil), MinTime: 0, MaxTime: 10, Resolution: block.Resolution1h}
+		raw2Downsample = &bucketindex.Block{ID: ulid.MustNew(13, nil), MinTime: 10, MaxTime: 20, CompactorShardID: "1_of_2", Resolution: block.Resolution5m}
+		raw4Downsample = &bucketindex.Block{ID: ulid.MustNew(14, nil), MinTime: 20, MaxTime: 30, Resolution: block.Resolution5m}
+		// Downsampled from a raw block which has been replaced by a more recent one, with the same time range.
+		raw3Outdated = &bucketindex.Block{ID: ulid.MustNew(0, nil), MinTime: 10, MaxTime: 20, CompactorShardID: "2_of_2", Resolution: block.Resolution5m}
+	)
+
+	blocks := bucketindex.Blocks{raw1, raw2, raw3, raw4, raw4Duplicate, raw1Downsample, raw1Newer, raw1Coarser, raw2Downsample, raw4Downsample, raw3Outdated}
+
+	tests := map[string]struct {
+		blocks              bucketindex.Blocks
+		resolution          int64
+		expectedBlocks      bucketindex.Blocks
+		expectedDownsampled map[ulid.ULID]struct{}
+	}{
+		"no downsampled blocks": {
+			blocks:         bucketindex.Blocks{raw1, raw2},
+			resolution:     block.Resolution1h,
+			expectedBlocks: bucketindex.Blocks{raw1, raw2},
+		},
+		"raw resolution": {
+			blocks:         blocks,
+			resolution:     block.ResolutionRaw,
+			expectedBlocks: bucketindex.Blocks{raw1, raw2, raw3, raw4, raw4Duplicate},
+		},
+		"5m resolution": {
+			blocks:              blocks,
+			resolution:          block.Resolution5m,
+			expectedBlocks:      bucketindex.Blocks{raw1Newer, raw2Downsample, raw3, raw4, raw4Duplicate},
+			expectedDownsampled: map[ulid.ULID]struct{}{raw1Newer.ID: {}, raw2Downsample.ID: {}},
+		},
+		"1h resolution": {
+			blocks:              blocks,
+			resolution:          block.Resolution1h,
+			expectedBlocks:      bucketindex.Blocks{raw1Coarser, raw2Downsample, raw3, raw4, raw4Duplicate},
+			expectedDownsampled: map[ulid.ULID]struct{}{raw1Coarser.ID: {}, raw2Downsample.ID: {}},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			actualBlocks, actualDownsampled := selectBlocksByResolution(testData.blocks, testData.resolution)
+			assert.Equal(t, testData.expectedBlocks, actualBlocks)
+			assert.Equal(t, testData.expectedDownsampled, actualDownsampled)
+		})
+	}
+}
+
+func TestAvgSeriesSet(t *testing.T) {
+	newSeriesSet := func(aggregate string, series ...storage.Series) storage.SeriesSet {
+		return newDownsampledSeriesSet(newConcreteSeriesSet(aggregate, series...))
+	}
+	newSeries := func(job string, samples ...model.SamplePair) storage.Series {
+		return series.NewConcreteSeries(labels.FromStrings(labels.MetricName, "up", "job", job), samples, nil)
+	}
+
+	sum := newSeriesSet(block.AggregationSum,
+		newSeries("a", model.SamplePair{Timestamp: 10, Value: 10}, model.SamplePair{Timestamp: 20, Value: 30}, model.SamplePair{Timestamp: 30, Value: 8}),
+		newSeries("b", model.SamplePair{Timestamp: 10, Value: 1}),
+		newSeries("d", model.SamplePair{Timestamp: 10, Value: 4}),
+	)
+	count := newSeriesSet(block.AggregationCount,
+		newSeries("a", model.SamplePair{Timestamp: 10, Value: 5}, model.SamplePair{Timestamp: 25, Value: 1}, model.SamplePair{Timestamp: 30, Value: 4}),
+		newSeries("c", model.SamplePair{Timestamp: 10, Value: 1}),
+		newSeries("d", model.SamplePair{Timestamp: 10, Value: 2}),
+	)
+
+	// Series and samples without both aggregates are skipped.
+	assert.Equal(t, map[string][]promql.FPoint{
+		`{__name__="up", job="a"}`: {{T: 10, F: 2}, {T: 30, F: 2}},
+		`{__name__="up", job="d"}`: {{T: 10, F: 2}},
+	}, readSeriesSetSamples(t, newAvgSeriesSet(sum, count)))
+
+	t.Run("seek", func(t *testing.T) {
+		sum := newSeriesSet(block.AggregationSum, newSeries("a", model.SamplePair{Timestamp: 10, Value: 10}, model.SamplePair{Timestamp: 20, Value: 30}, model.SamplePair{Timestamp: 30, Value: 8}))
+		count := newSeriesSet(block.AggregationCount, newSeries("a", model.SamplePair{Timestamp: 10, Value: 5}, model.SamplePair{Timestamp: 20, Value: 3}, model.SamplePair{Timestamp: 30, Value: 4}))
+
+		set := newAvgSeriesSet(sum, count)
+		require.True(t, set.Next())
+		it := set.At().Iterator(nil)
+		require.Equal(t, chunkenc.ValFloat, it.Seek(15))
+		ts, v := it.At()
+		assert.Equal(t, int64(20), ts)
+		assert.Equal(t, float64(10), v)
+		require.Equal(t, chunkenc.ValFloat, it.Seek(5))
+		assert.Equal(t, int64(20), it.AtT())
+		require.Equal(t, chunkenc.ValFloat, it.Next())
+		assert.Equal(t, int64(30), it.AtT())
+		require.Equal(t, chunkenc.ValNone, it.Next())
+		require.NoError(t, it.Err())
+		require.False(t, set.Next())
+	})
+}
+
+func TestBlocksStoreQuerier_SelectDownsampled(t *testing.T) {
+	const (
+		minT = int64(0)
+		maxT = int64(100)
+	)
+
+	var (
+		rawBlock         = ulid.MustNew(1, nil)
+		downsampledBlock = ulid.MustNew(3, nil)
+		seriesLabels     = labels.FromStrings(labels.MetricName, "up", "job", "a")
+		aggregateLabels  = func(aggregate string) labels.Labels {
+			return labels.NewBuilder(seriesLabels).Set(block.AggregationLabel, aggregate).Labels()
+		}
+	)
+
+	finder := &blocksFinderMock{}
+	finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{
+		{ID: rawBlock, MinTime: 0, MaxTime: 50},
+		{ID: ulid.MustNew(2, nil), MinTime: 50, MaxTime: 100},
+		{ID: downsampledBlock, MinTime: 50, MaxTime: 100, Resolution: block.Resolution5m},
+	}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+	client := &downsampledStoreGatewayClientMock{
+		storeGatewayClientMock: &storeGatewayClientMock{remoteAddr: "1.1.1.1"},
+		rawResponses: []*storepb.SeriesResponse{
+			mockSeriesResponseWithSamples(seriesLabels, promql.FPoint{T: 10, F: 1}, promql.FPoint{T: 20, F: 2}),
+			mockHintsResponse(rawBlock),
+		},
+		aggregateResponses: map[string][]*storepb.SeriesResponse{
+			block.AggregationSum: {
+				mockSeriesResponseWithSamples(aggregateLabels(block.AggregationSum), promql.FPoint{T: 60, F: 12}, promql.FPoint{T: 90, F: 6}),
+				mockHintsResponse(downsampledBlock),
+			},
+			block.AggregationCount: {
+				mockSeriesResponseWithSamples(aggregateLabels(block.AggregationCount), promql.FPoint{T: 60, F: 4}, promql.FPoint{T: 90, F: 3}),
+				mockHintsResponse(downsampledBlock),
+			},
+		},
+	}
+
+	stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+		map[BlocksStoreClient][]ulid.ULID{client: {rawBlock, downsampledBlock}},
+	}}
+
+	q := &blocksStoreQuerier{
+		minT:        minT,
+		maxT:        maxT,
+		finder:      finder,
+		stores:      stores,
+		consistency: NewBlocksConsistency(0, 0, log.NewNopLogger(), nil),
+		logger:      log.NewNopLogger(),
+		metrics:     newBlocksStoreQueryableMetrics(nil),
+		limits:      &blocksStoreLimitsMock{},
+	}
+
+	ctx := user.InjectOrgID(context.Background(), "user-1")
+	sp := &storage.SelectHints{Start: minT, End: maxT, Step: time.Hour.Milliseconds(), Range: time.Hour.Milliseconds(), Func: "avg_over_time"}
+	set := q.Select(ctx, true, sp, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "up"))
+
+	// The raw samples are merged with the average of the downsampled ones.
+	assert.Equal(t, map[string][]promql.FPoint{
+		seriesLabels.String(): {{T: 10, F: 1}, {T: 20, F: 2}, {T: 60, F: 3}, {T: 90, F: 2}},
+	}, readSeriesSetSamples(t, set))
+	assert.ElementsMatch(t, []string{"", block.AggregationSum, block.AggregationCount}, client.requestedAggregates)
+}
+
+func TestBlocksStoreQuerier_SelectShouldQueryRawBlocksIfDownsampledAggregatesCantAnswerTheQuery(t *testing.T) {
+	const (
+		minT = int64(0)
+		maxT = int64(2 * time.Hour / time.Millisecond)
+	)
+
+	var (
+		rawBlock         = ulid.MustNew(1, nil)
+		downsampledBlock = ulid.MustNew(2, nil)
+		seriesLabels     = labels.FromStrings(labels.MetricName, "metric", "job", "a")
+		aggregateLabels  = func(aggregate string) labels.Labels {
+			return labels.NewBuilder(seriesLabels).Set(block.AggregationLabel, aggregate).Labels()
+		}
+		minute = time.Minute.Milliseconds()
+	)
+
+	tests := map[string]struct {
+		hints      *storage.SelectHints
+		rawSamples []promql.FPoint
+	}{
+		"counter reset within a downsampling window": {
+			hints: &storage.SelectHints{Start: minT, End: maxT, Step: time.Hour.Milliseconds(), Range: time.Hour.Milliseconds(), Func: "increase"},
+			// The counter is reset between the 2nd and the 3rd sample, which are within the same 5m window.
+			rawSamples: []promql.FPoint{{T: 1 * minute, F: 10}, {T: 2 * minute, F: 20}, {T: 3 * minute, F: 5}, {T: 6 * minute, F: 15}},
+		},
+		"instant selector over a changing gauge": {
+			hints:      &storage.SelectHints{Start: minT, End: maxT, Step: time.Hour.Milliseconds()},
+			rawSamples: []promql.FPoint{{T: 1 * minute, F: 10}, {T: 2 * minute, F: 50}, {T: 3 * minute, F: 1}, {T: 6 * minute, F: 30}},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			finder := &blocksFinderMock{}
+			finder.On("GetBlocks", mock.Anything, "user-1", minT, maxT).Return(bucketindex.Blocks{
+				{ID: rawBlock, MinTime: minT, MaxTime: maxT},
+				{ID: downsampledBlock, MinTime: minT, MaxTime: maxT, Resolution: block.Resolution5m},
+			}, map[ulid.ULID]*bucketindex.BlockDeletionMark(nil), nil)
+
+			// The downsampled aggregates can't be told apart from the raw samples if they're wrongly queried.
+			aggregateResponses := map[string][]*storepb.SeriesResponse{}
+			for _, aggregate := range []string{block.AggregationCount, block.AggregationMin, block.AggregationMax, block.AggregationSum} {
+				aggregateResponses[aggregate] = []*storepb.SeriesResponse{
+					mockSeriesResponseWithSamples(aggregateLabels(aggregate), promql.FPoint{T: 5 * minute, F: -1}, promql.FPoint{T: 10 * minute, F: -1}),
+					mockHintsResponse(downsampledBlock),
+				}
+			}
+
+			client := &downsampledStoreGatewayClientMock{
+				storeGatewayClientMock: &storeGatewayClientMock{remoteAddr: "1.1.1.1"},
+				rawResponses: []*storepb.SeriesResponse{
+					mockSeriesResponseWithSamples(seriesLabels, testData.rawSamples...),
+					mockHintsResponse(rawBlock),
+				},
+				aggregateResponses: aggregateResponses,
+			}
+
+			stores := &blocksStoreSetMock{mockedResponses: []interface{}{
+				map[BlocksStoreClient][]ulid.ULID{client: {rawBlock}},
+			}}
+
+			q := &blocksStoreQuerier{
+				minT:        minT,
+				maxT:        maxT,
+				finder:      finder,
+				stores:      stores,
+				consistency: NewBlocksConsistency(0, 0, log.NewNopLogger(), nil),
+				logger:      log.NewNopLogger(),
+				metrics:     newBlocksStoreQueryableMetrics(nil),
+				limits:      &blocksStoreLimitsMock{},
+			}
+
+			ctx := user.InjectOrgID(context.Background(), "user-1")
+			set := q.Select(ctx, true, testData.hints, labels.MustNewMatcher(labels.MatchEqual, labels.MetricName, "metric"))
+
+			// The query must get the same samples as if the series weren't downsampled.
+			assert.Equal(t, map[string][]promql.FPoint{seriesLabels.String(): testData.rawSamples}, readSeriesSetSamples(t, set))
+			assert.Equal(t, []string{""}, client.requestedAggregates)
+		})
+	}
+}
+
+// downsampledStoreGatewayClientMock returns the mocked series of the aggregate selected by the request,
+// or the raw series if no aggregate is selected.
+type downsampledStoreGatewayClientMock struct {
+	*storeGatewayClientMock
+
+	rawResponses        []*storepb.SeriesResponse
+	aggregateResponses  map[string][]*storepb.SeriesResponse
+	requestedAggregates []string
+}
+
+func (m *downsampledStoreGatewayClientMock) Series(ctx context.Context, req *storepb.SeriesRequest, _ ...grpc.CallOption) (storegatewaypb.StoreGateway_SeriesClient, error) {
+	aggregate := ""
+	for _, matcher := range req.Matchers {
+		if matcher.Name == block.AggregationLabel {
+			aggregate = matcher.Value
+		}
+	}
+	m.requestedAggregates = append(m.requestedAggregates, aggregate)
+
+	responses := m.rawResponses
+	if aggregate != "" {
+		responses = m.aggregateResponses[aggregate]
+	}
+
+	return &storeGatewaySeriesClientMock{
+		ClientStream:    grpcClientStreamMock{ctx: ctx},
+		mockedResponses: responses,
+	}, nil
+}
+
+// newConcreteSeriesSet returns a sorted SeriesSet of the series, with the aggregation label.
+func newConcreteSeriesSet(aggregate string, input ...storage.Series) storage.SeriesSet {
+	var withAggregate []storage.Series
+	for _, s := range input {
+		withAggregate = append(withAggregate, &downsampledSeries{
+			Series: s,
+			lbls:   labels.NewBuilder(s.Labels()).Set(block.AggregationLabel, aggregate).Labels(),
+		})
+	}
+	return series.NewConcreteSeriesSetFromSortedSeries(withAggregate)
+}
+
+func readSeriesSetSamples(t *testing.T, set storage.SeriesSet) map[string][]promql.FPoint {
+	samples := map[string][]promql.FPoint{}
+	for set.Next() {
+		s := set.At()
+		it := s.Iterator(nil)
+		for it.Next() == chunkenc.ValFloat {
+			ts, v := it.At()
+			samples[s.Labels().String()] = append(samples[s.Labels().String()], promql.FPoint{T: ts, F: v})
+		}
+		require.NoError(t, it.Err())
+	}
+	require.NoError(t, set.Err())
+	return samples
+}
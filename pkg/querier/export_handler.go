@@ -0,0 +1,317 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/apache/arrow/go/v14/arrow/memory"
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+
+	"github.com/grafana/mimir/pkg/util"
+	"github.com/grafana/mimir/pkg/util/globalerror"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+const (
+	exportFormatCSV   = "csv"
+	exportFormatArrow = "arrow"
+
+	// exportPageInterval is the max time range of the samples exported by a single request to the export API.
+	exportPageInterval = time.Hour
+
+	// ExportNextStartHeader is set on the responses of the export API when the requested time range
+	// has not been fully exported, to the start timestamp of the next page.
+	ExportNextStartHeader = "X-Mimir-Export-Next-Start"
+
+	csvContentType   = "text/csv; charset=utf-8"
+	arrowContentType = "application/vnd.apache.arrow.stream"
+)
+
+// exportBatch holds the samples of a page, one row per sample.
+type exportBatch struct {
+	series     []string
+	timestamps []int64
+	values     []float64
+}
+
+// exportEncoder encodes the exported samples.
+type exportEncoder interface {
+	contentType() string
+
+	// encode writes the samples of the batch and the end of the export.
+	encode(b *exportBatch) error
+}
+
+// ExportHandler returns a handler exporting the raw float samples of the series matching the match[] selectors
+// between start and end, either as CSV or as Apache Arrow IPC stream. Each request exports a single page of
+// at most one hour, and the ExportNextStartHeader response header is set to the start of the next page,
+// if any. The samples of a page are queried at once, so the per-tenant query limits apply to each page,
+// and the response size is limited by the per-tenant max export bytes limit.
+func ExportHandler(queryable storage.Queryable, limits *validation.Overrides, logger log.Logger) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		tenantIDs, err := tenant.TenantIDs(ctx)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		if err := r.ParseForm(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		matcherSets, err := parseExportMatchers(r.Form["match[]"])
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		start, end, err := parseExportTimeRange(r)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		var buf bytes.Buffer
+		enc, err := newExportEncoder(r.FormValue("format"), &buf)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		// Samples older than the max query lookback are never exported.
+		if maxLookback := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, limits.MaxQueryLookback); maxLookback > 0 {
+			start = max(start, time.Now().Add(-maxLookback).UnixMilli())
+		}
+
+		// The page is never longer than the max query length, so that it can be queried at once.
+		pageInterval := exportPageInterval
+		if maxLength := validation.SmallestPositiveNonZeroDurationPerTenant(tenantIDs, limits.MaxPartialQueryLength); maxLength > 0 {
+			pageInterval = min(pageInterval, maxLength)
+		}
+
+		pageEnd := min(end, start+pageInterval.Milliseconds()-1)
+		batch := &exportBatch{}
+		if start <= end {
+			batch, err = queryExportBatch(ctx, queryable, matcherSets, start, pageEnd)
+			if validation.IsLimitError(err) {
+				http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+				return
+			}
+			if err != nil {
+				respondFromError(err, w)
+				return
+			}
+		}
+
+		if err := enc.encode(batch); err != nil {
+			level.Error(util_log.WithContext(ctx, logger)).Log("msg", "failed to encode the exported samples", "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if maxBytes := validation.SmallestPositiveNonZeroIntPerTenant(tenantIDs, limits.MaxExportBytes); maxBytes > 0 && buf.Len() > maxBytes {
+			err := globalerror.MaxExportBytes.MessageWithPerTenantLimitConfig(
+				fmt.Sprintf("the export of the page between %s and %s exceeded the limit of %d bytes", formatExportTime(start), formatExportTime(pageEnd), maxBytes),
+				validation.MaxExportBytesFlag,
+			)
+			http.Error(w, err, http.StatusUnprocessableEntity)
+			return
+		}
+
+		if pageEnd < end {
+			w.Header().Set(ExportNextStartHeader, formatExportTime(pageEnd+1))
+		}
+		w.Header().Set("Content-Type", enc.contentType())
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write(buf.Bytes())
+	})
+}
+
+// queryExportBatch returns the samples of the series matching any of the matcher sets between mint and maxt,
+// both included. The series are queried with a single querier, so that the query limits apply to the whole page.
+func queryExportBatch(ctx context.Context, queryable storage.Queryable, matcherSets [][]*labels.Matcher, mint, maxt int64) (*exportBatch, error) {
+	q, err := queryable.Querier(mint, maxt)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	hints := &storage.SelectHints{Start: mint, End: maxt}
+	sets := make([]storage.SeriesSet, 0, len(matcherSets))
+	for _, matchers := range matcherSets {
+		sets = append(sets, q.Select(ctx, true, hints, matchers...))
+	}
+
+	// Series matching multiple selectors are exported once.
+	set := sets[0]
+	if len(sets) > 1 {
+		set = storage.NewMergeSeriesSet(sets, storage.ChainedSeriesMerge)
+	}
+
+	batch := &exportBatch{}
+	var it chunkenc.Iterator
+	for set.Next() {
+		series := set.At()
+		lbls := series.Labels().String()
+
+		it = series.Iterator(it)
+		for vt := it.Seek(mint); vt != chunkenc.ValNone; vt = it.Next() {
+			if vt != chunkenc.ValFloat {
+				// Native histograms are not supported by the export formats.
+				continue
+			}
+			t, v := it.At()
+			if t > maxt {
+				break
+			}
+			batch.series = append(batch.series, lbls)
+			batch.timestamps = append(batch.timestamps, t)
+			batch.values = append(batch.values, v)
+		}
+		if err := it.Err(); err != nil {
+			return nil, err
+		}
+	}
+	if err := set.Err(); err != nil {
+		return nil, err
+	}
+	return batch, nil
+}
+
+// formatExportTime formats the timestamp in milliseconds as a Unix timestamp in seconds, which is
+// accepted by the start and end parameters of the export API.
+func formatExportTime(ts int64) string {
+	return strconv.FormatFloat(float64(ts)/1000, 'f', -1, 64)
+}
+
+func parseExportMatchers(selectors []string) ([][]*labels.Matcher, error) {
+	if len(selectors) == 0 {
+		return nil, fmt.Errorf("at least one match[] selector must be provided")
+	}
+
+	matcherSets := make([][]*labels.Matcher, 0, len(selectors))
+	for _, s := range selectors {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return nil, fmt.Errorf("invalid match[] selector %q: %w", s, err)
+		}
+		matcherSets = append(matcherSets, matchers)
+	}
+	return matcherSets, nil
+}
+
+func parseExportTimeRange(r *http.Request) (int64, int64, error) {
+	if r.FormValue("start") == "" || r.FormValue("end") == "" {
+		return 0, 0, fmt.Errorf("the start and end parameters are required")
+	}
+
+	start, err := util.ParseTimeParam(r, "start", 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	end, err := util.ParseTimeParam(r, "end", 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	if end < start {
+		return 0, 0, fmt.Errorf("the end timestamp must not be before the start timestamp")
+	}
+	return start, end, nil
+}
+
+func newExportEncoder(format string, w io.Writer) (exportEncoder, error) {
+	switch format {
+	case "", exportFormatCSV:
+		return &csvExportEncoder{csv: csv.NewWriter(w)}, nil
+	case exportFormatArrow:
+		return &arrowExportEncoder{w: w}, nil
+	default:
+		return nil, fmt.Errorf("unsupported export format %q: supported formats are %s and %s", format, exportFormatCSV, exportFormatArrow)
+	}
+}
+
+// csvExportEncoder encodes the samples as CSV rows with the series labels, the timestamp in milliseconds
+// and the value, after a header row.
+type csvExportEncoder struct {
+	csv *csv.Writer
+}
+
+func (c *csvExportEncoder) contentType() string {
+	return csvContentType
+}
+
+func (c *csvExportEncoder) encode(b *exportBatch) error {
+	if err := c.csv.Write([]string{"series", "timestamp", "value"}); err != nil {
+		return err
+	}
+
+	record := make([]string, 3)
+	for i := range b.timestamps {
+		record[0] = b.series[i]
+		record[1] = strconv.FormatInt(b.timestamps[i], 10)
+		record[2] = strconv.FormatFloat(b.values[i], 'f', -1, 64)
+		if err := c.csv.Write(record); err != nil {
+			return err
+		}
+	}
+	c.csv.Flush()
+	return c.csv.Error()
+}
+
+// exportArrowSchema is the schema of the Arrow record batches returned by the export API.
+var exportArrowSchema = arrow.NewSchema([]arrow.Field{
+	{Name: "series", Type: arrow.BinaryTypes.String},
+	{Name: "timestamp", Type: arrow.FixedWidthTypes.Timestamp_ms},
+	{Name: "value", Type: arrow.PrimitiveTypes.Float64},
+}, nil)
+
+// arrowExportEncoder encodes the samples as an Arrow IPC stream, made of a single record batch
+// with the series, timestamp and value columns.
+type arrowExportEncoder struct {
+	w io.Writer
+}
+
+func (a *arrowExportEncoder) contentType() string {
+	return arrowContentType
+}
+
+func (a *arrowExportEncoder) encode(b *exportBatch) error {
+	builder := array.NewRecordBuilder(memory.DefaultAllocator, exportArrowSchema)
+	defer builder.Release()
+
+	builder.Field(0).(*array.StringBuilder).AppendValues(b.series, nil)
+	timestamps := builder.Field(1).(*array.TimestampBuilder)
+	for _, ts := range b.timestamps {
+		timestamps.Append(arrow.Timestamp(ts))
+	}
+	builder.Field(2).(*array.Float64Builder).AppendValues(b.values, nil)
+
+	record := builder.NewRecord()
+	defer record.Release()
+
+	writer := ipc.NewWriter(a.w, ipc.WithSchema(exportArrowSchema))
+	if err := writer.Write(record); err != nil {
+		return err
+	}
+	return writer.Close()
+}
@@ -0,0 +1,252 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"bytes"
+	"context"
+	"encoding/csv"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/apache/arrow/go/v14/arrow"
+	"github.com/apache/arrow/go/v14/arrow/array"
+	"github.com/apache/arrow/go/v14/arrow/ipc"
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/series"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestExportHandler(t *testing.T) {
+	// Samples every 30 minutes over 3 hours, so that the export spans multiple pages.
+	var samples []model.SamplePair
+	for ts := time.Hour; ts <= 4*time.Hour; ts += 30 * time.Minute {
+		samples = append(samples, model.SamplePair{Timestamp: model.Time(ts.Milliseconds()), Value: model.SampleValue(ts.Minutes())})
+	}
+
+	queryable := &mockExportQueryable{series: []storage.Series{
+		series.NewConcreteSeries(labels.FromStrings(labels.MetricName, "up", "job", "a"), samples, nil),
+		series.NewConcreteSeries(labels.FromStrings(labels.MetricName, "up", "job", "b"), samples, nil),
+		series.NewConcreteSeries(labels.FromStrings(labels.MetricName, "other", "job", "a"), samples, nil),
+		series.NewConcreteSeries(labels.FromStrings(labels.MetricName, "other", "job", "b"), samples, nil),
+	}}
+
+	// expectedRows returns the CSV rows of the samples of the series between start and end, both included.
+	expectedRows := func(seriesLabels []string, start, end time.Duration) [][]string {
+		var rows [][]string
+		for _, lbls := range seriesLabels {
+			for _, s := range samples {
+				if s.Timestamp < model.Time(start.Milliseconds()) || s.Timestamp > model.Time(end.Milliseconds()) {
+					continue
+				}
+				rows = append(rows, []string{lbls, strconv.FormatInt(int64(s.Timestamp), 10), s.Value.String()})
+			}
+		}
+		return rows
+	}
+
+	export := func(t *testing.T, limits validation.Limits, params url.Values) *httptest.ResponseRecorder {
+		overrides, err := validation.NewOverrides(limits, nil)
+		require.NoError(t, err)
+
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/export?"+params.Encode(), nil)
+		req = req.WithContext(user.InjectOrgID(context.Background(), "user-1"))
+
+		rec := httptest.NewRecorder()
+		ExportHandler(queryable, overrides, log.NewNopLogger()).ServeHTTP(rec, req)
+		return rec
+	}
+
+	parseCSV := func(t *testing.T, body string) [][]string {
+		records, err := csv.NewReader(strings.NewReader(body)).ReadAll()
+		require.NoError(t, err)
+		require.NotEmpty(t, records)
+		assert.Equal(t, []string{"series", "timestamp", "value"}, records[0])
+		return records[1:]
+	}
+
+	t.Run("should export the first page of the samples of the matching series as CSV", func(t *testing.T) {
+		rec := export(t, defaultLimitsConfig(), url.Values{"match[]": {`up`}, "start": {"3600"}, "end": {"14400"}})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, csvContentType, rec.Header().Get("Content-Type"))
+		assert.Equal(t, "7200", rec.Header().Get(ExportNextStartHeader))
+
+		assert.ElementsMatch(t, expectedRows([]string{`{__name__="up", job="a"}`, `{__name__="up", job="b"}`}, time.Hour, 2*time.Hour-time.Millisecond), parseCSV(t, rec.Body.String()))
+	})
+
+	t.Run("should export all the samples following the next page start", func(t *testing.T) {
+		params := url.Values{"match[]": {`up`}, "start": {"3600"}, "end": {"14400"}}
+
+		var rows [][]string
+		for pages := 1; ; pages++ {
+			rec := export(t, defaultLimitsConfig(), params)
+			require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+			rows = append(rows, parseCSV(t, rec.Body.String())...)
+
+			next := rec.Header().Get(ExportNextStartHeader)
+			if next == "" {
+				assert.Equal(t, 4, pages)
+				break
+			}
+			params.Set("start", next)
+		}
+
+		assert.ElementsMatch(t, expectedRows([]string{`{__name__="up", job="a"}`, `{__name__="up", job="b"}`}, time.Hour, 4*time.Hour), rows)
+	})
+
+	t.Run("should export only the samples in the requested time range", func(t *testing.T) {
+		rec := export(t, defaultLimitsConfig(), url.Values{"match[]": {`up{job="a"}`}, "start": {"5400"}, "end": {"8999"}})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "", rec.Header().Get(ExportNextStartHeader))
+
+		assert.ElementsMatch(t, expectedRows([]string{`{__name__="up", job="a"}`}, 90*time.Minute, 150*time.Minute-time.Second), parseCSV(t, rec.Body.String()))
+	})
+
+	t.Run("should export series matching multiple selectors once", func(t *testing.T) {
+		rec := export(t, defaultLimitsConfig(), url.Values{"match[]": {`up`, `{job="a"}`}, "start": {"3600"}, "end": {"7199"}})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+
+		assert.ElementsMatch(t, expectedRows([]string{`{__name__="up", job="a"}`, `{__name__="up", job="b"}`, `{__name__="other", job="a"}`}, time.Hour, 2*time.Hour-time.Millisecond), parseCSV(t, rec.Body.String()))
+	})
+
+	t.Run("should export only the CSV header if no series match", func(t *testing.T) {
+		rec := export(t, defaultLimitsConfig(), url.Values{"match[]": {`missing`}, "start": {"3600"}, "end": {"7199"}})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "series,timestamp,value\n", rec.Body.String())
+	})
+
+	t.Run("should limit the page to the max query length", func(t *testing.T) {
+		limits := defaultLimitsConfig()
+		limits.MaxPartialQueryLength = model.Duration(30 * time.Minute)
+
+		rec := export(t, limits, url.Values{"match[]": {`up{job="a"}`}, "start": {"3600"}, "end": {"14400"}})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "5400", rec.Header().Get(ExportNextStartHeader))
+
+		assert.ElementsMatch(t, expectedRows([]string{`{__name__="up", job="a"}`}, time.Hour, 90*time.Minute-time.Millisecond), parseCSV(t, rec.Body.String()))
+	})
+
+	t.Run("should not export samples older than the max query lookback", func(t *testing.T) {
+		limits := defaultLimitsConfig()
+		limits.MaxQueryLookback = model.Duration(time.Hour)
+
+		rec := export(t, limits, url.Values{"match[]": {`up`}, "start": {"3600"}, "end": {"14400"}})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, "", rec.Header().Get(ExportNextStartHeader))
+		assert.Empty(t, parseCSV(t, rec.Body.String()))
+	})
+
+	t.Run("should export the samples as an Arrow IPC stream", func(t *testing.T) {
+		rec := export(t, defaultLimitsConfig(), url.Values{"match[]": {`up{job="a"}`}, "start": {"3600"}, "end": {"7199"}, "format": {"arrow"}})
+		require.Equal(t, http.StatusOK, rec.Code, rec.Body.String())
+		assert.Equal(t, arrowContentType, rec.Header().Get("Content-Type"))
+
+		reader, err := ipc.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		require.NoError(t, err)
+		defer reader.Release()
+
+		assert.True(t, exportArrowSchema.Equal(reader.Schema()))
+
+		var rows [][]string
+		for reader.Next() {
+			record := reader.Record()
+			seriesCol := record.Column(0).(*array.String)
+			timestampCol := record.Column(1).(*array.Timestamp)
+			valueCol := record.Column(2).(*array.Float64)
+			for i := 0; i < int(record.NumRows()); i++ {
+				rows = append(rows, []string{
+					seriesCol.Value(i),
+					strconv.FormatInt(int64(timestampCol.Value(i)), 10),
+					model.SampleValue(valueCol.Value(i)).String(),
+				})
+			}
+		}
+		require.NoError(t, reader.Err())
+
+		assert.Equal(t, arrow.Millisecond, reader.Schema().Field(1).Type.(*arrow.TimestampType).Unit)
+		assert.ElementsMatch(t, expectedRows([]string{`{__name__="up", job="a"}`}, time.Hour, 2*time.Hour-time.Millisecond), rows)
+	})
+
+	t.Run("should fail if the export of the page exceeds the limit", func(t *testing.T) {
+		limits := defaultLimitsConfig()
+		limits.MaxExportBytes = 10
+
+		rec := export(t, limits, url.Values{"match[]": {`up`}, "start": {"3600"}, "end": {"14400"}})
+		assert.Equal(t, http.StatusUnprocessableEntity, rec.Code)
+		assert.Contains(t, rec.Body.String(), "exceeded the limit of 10 bytes")
+		assert.Contains(t, rec.Body.String(), "err-mimir-max-export-bytes")
+	})
+
+	for name, params := range map[string]url.Values{
+		"missing match[]":        {"start": {"3600"}, "end": {"14400"}},
+		"invalid match[]":        {"match[]": {`up{`}, "start": {"3600"}, "end": {"14400"}},
+		"missing start":          {"match[]": {`up`}, "end": {"14400"}},
+		"missing end":            {"match[]": {`up`}, "start": {"3600"}},
+		"invalid start":          {"match[]": {`up`}, "start": {"foo"}, "end": {"14400"}},
+		"end before start":       {"match[]": {`up`}, "start": {"14400"}, "end": {"3600"}},
+		"unsupported format":     {"match[]": {`up`}, "start": {"3600"}, "end": {"14400"}, "format": {"parquet"}},
+		"format case mismatched": {"match[]": {`up`}, "start": {"3600"}, "end": {"14400"}, "format": {"CSV"}},
+	} {
+		t.Run("should fail on "+name, func(t *testing.T) {
+			rec := export(t, defaultLimitsConfig(), params)
+			assert.Equal(t, http.StatusBadRequest, rec.Code, rec.Body.String())
+		})
+	}
+}
+
+// mockExportQueryable returns the series matching the selectors.
+type mockExportQueryable struct {
+	series []storage.Series
+}
+
+func (m *mockExportQueryable) Querier(_, _ int64) (storage.Querier, error) {
+	return &mockExportQuerier{series: m.series}, nil
+}
+
+type mockExportQuerier struct {
+	series []storage.Series
+}
+
+func (m *mockExportQuerier) Select(_ context.Context, _ bool, _ *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	var selected []storage.Series
+	for _, s := range m.series {
+		if matchesAll(s.Labels(), matchers) {
+			selected = append(selected, s)
+		}
+	}
+	return series.NewConcreteSeriesSetFromUnsortedSeries(selected)
+}
+
+func matchesAll(lbls labels.Labels, matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (m *mockExportQuerier) LabelValues(context.Context, string, ...*labels.Matcher) ([]string, annotations.Annotations, error) {
+	return nil, nil, nil
+}
+
+func (m *mockExportQuerier) LabelNames(context.Context, ...*labels.Matcher) ([]string, annotations.Annotations, error) {
+	return nil, nil, nil
+}
+
+func (m *mockExportQuerier) Close() error {
+	return nil
+}
@@ -29,6 +29,7 @@ import (
 	"github.com/grafana/mimir/pkg/querier/stats"
 	"github.com/grafana/mimir/pkg/storage/chunk"
 	"github.com/grafana/mimir/pkg/storage/lazyquery"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/activitytracker"
 	"github.com/grafana/mimir/pkg/util/limiter"
@@ -127,7 +128,10 @@ func New(cfg Config, limits *validation.Overrides, distributor Distributor, stor
 
 	distributorQueryable := newDistributorQueryable(distributor, limits, queryMetrics, logger)
 
-	queryable := newQueryable(distributorQueryable, storeQueryable, cfg, limits, queryMetrics, logger)
+	// The store queryable, if any, is also used to lookup the series deletion tombstones.
+	tombstonesFinder, _ := storeQueryable.(TombstonesFinder)
+
+	queryable := newQueryable(distributorQueryable, storeQueryable, tombstonesFinder, cfg, limits, queryMetrics, logger)
 	exemplarQueryable := newDistributorExemplarQueryable(distributor, logger)
 
 	lazyQueryable := storage.QueryableFunc(func(minT int64, maxT int64) (storage.Querier, error) {
@@ -176,6 +180,7 @@ func (q *chunkQuerier) Select(ctx context.Context, sortSeries bool, hints *stora
 func newQueryable(
 	distributor storage.Queryable,
 	blockStore storage.Queryable,
+	tombstonesFinder TombstonesFinder,
 	cfg Config,
 	limits *validation.Overrides,
 	queryMetrics *stats.QueryMetrics,
@@ -185,6 +190,7 @@ func newQueryable(
 		return multiQuerier{
 			distributor:        distributor,
 			blockStore:         blockStore,
+			tombstonesFinder:   tombstonesFinder,
 			queryMetrics:       queryMetrics,
 			cfg:                cfg,
 			minT:               minT,
@@ -205,6 +211,9 @@ type multiQuerier struct {
 	cfg          Config
 	minT, maxT   int64
 
+	// tombstonesFinder is optional, and used to filter out deleted series.
+	tombstonesFinder TombstonesFinder
+
 	maxQueryIntoFuture time.Duration
 	limits             *validation.Overrides
 
@@ -321,8 +330,16 @@ func (mq multiQuerier) Select(ctx context.Context, _ bool, sp *storage.SelectHin
 		return storage.ErrSeriesSet(NewMaxQueryLengthError(endTime.Sub(startTime), maxQueryLength))
 	}
 
+	var tombstones mimir_tsdb.Tombstones
+	if mq.tombstonesFinder != nil {
+		tombstones, err = mq.tombstonesFinder.GetTombstones(ctx, userID, startMs, endMs)
+		if err != nil {
+			return storage.ErrSeriesSet(err)
+		}
+	}
+
 	if len(queriers) == 1 {
-		return queriers[0].Select(ctx, true, sp, matchers...)
+		return mq.filterDeletedSeries(queriers[0].Select(ctx, true, sp, matchers...), tombstones, startMs, endMs)
 	}
 
 	sets := make(chan storage.SeriesSet, len(queriers))
@@ -345,7 +362,16 @@ func (mq multiQuerier) Select(ctx context.Context, _ bool, sp *storage.SelectHin
 	// we have all the sets from different sources (chunk from store, chunks from ingesters,
 	// time series from store and time series from ingesters).
 	// mergeSeriesSets will return sorted set.
-	return mq.mergeSeriesSets(result)
+	return mq.filterDeletedSeries(mq.mergeSeriesSets(result), tombstones, startMs, endMs)
+}
+
+// filterDeletedSeries filters out of the input set the samples deleted by the input tombstones.
+func (mq multiQuerier) filterDeletedSeries(set storage.SeriesSet, tombstones mimir_tsdb.Tombstones, minT, maxT int64) storage.SeriesSet {
+	filtered, err := newTombstonesSeriesSet(set, tombstones, minT, maxT)
+	if err != nil {
+		return storage.ErrSeriesSet(err)
+	}
+	return filtered
 }
 
 // LabelValues implements storage.Querier.
@@ -358,6 +384,17 @@ func (mq multiQuerier) LabelValues(ctx context.Context, name string, matchers ..
 		return nil, nil, err
 	}
 
+	values, warnings, err := labelValues(ctx, queriers, name, matchers...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	values, err = mq.filterDeletedLabelValues(ctx, queriers, values, name, matchers)
+	return values, warnings, err
+}
+
+// labelValues returns the merged label values of the input queriers.
+func labelValues(ctx context.Context, queriers []storage.Querier, name string, matchers ...*labels.Matcher) ([]string, annotations.Annotations, error) {
 	if len(queriers) == 1 {
 		return queriers[0].LabelValues(ctx, name, matchers...)
 	}
@@ -405,6 +442,17 @@ func (mq multiQuerier) LabelNames(ctx context.Context, matchers ...*labels.Match
 		return nil, nil, err
 	}
 
+	names, warnings, err := labelNames(ctx, queriers, matchers...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	names, err = mq.filterDeletedLabelNames(ctx, queriers, names, matchers)
+	return names, warnings, err
+}
+
+// labelNames returns the merged label names of the input queriers.
+func labelNames(ctx context.Context, queriers []storage.Querier, matchers ...*labels.Matcher) ([]string, annotations.Annotations, error) {
 	if len(queriers) == 1 {
 		return queriers[0].LabelNames(ctx, matchers...)
 	}
@@ -0,0 +1,225 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+
+	"github.com/grafana/dskit/tenant"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/tombstones"
+	"github.com/prometheus/prometheus/util/annotations"
+	"golang.org/x/exp/slices"
+
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+)
+
+// TombstonesFinder is the interface used to find the series deletion tombstones of a tenant.
+type TombstonesFinder interface {
+	// GetTombstones returns the series deletion tombstones of userID overlapping the
+	// range minT and maxT (milliseconds, both included).
+	GetTombstones(ctx context.Context, userID string, minT, maxT int64) (mimir_tsdb.Tombstones, error)
+}
+
+// newTombstonesSeriesSet returns a SeriesSet which filters out the samples of all series matching
+// the input tombstones. Series whose samples are all deleted within the queried range are dropped.
+func newTombstonesSeriesSet(set storage.SeriesSet, tombstones mimir_tsdb.Tombstones, minT, maxT int64) (storage.SeriesSet, error) {
+	if len(tombstones) == 0 {
+		return set, nil
+	}
+
+	deletions, err := mimir_tsdb.NewSeriesDeletions(tombstones)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tombstonesSeriesSet{
+		SeriesSet: set,
+		deletions: deletions,
+		minT:      minT,
+		maxT:      maxT,
+	}, nil
+}
+
+type tombstonesSeriesSet struct {
+	storage.SeriesSet
+
+	deletions  []mimir_tsdb.SeriesDeletion
+	minT, maxT int64
+
+	curr storage.Series
+}
+
+func (s *tombstonesSeriesSet) Next() bool {
+	for s.SeriesSet.Next() {
+		series := s.SeriesSet.At()
+
+		var intervals tombstones.Intervals
+		for _, d := range s.deletions {
+			if d.Matches(series.Labels()) {
+				intervals = intervals.Add(tombstones.Interval{Mint: d.MinTime, Maxt: d.MaxTime})
+			}
+		}
+
+		if len(intervals) == 0 {
+			s.curr = series
+			return true
+		}
+
+		// Skip the series if all its samples within the queried range have been deleted.
+		if coversRange(intervals, s.minT, s.maxT) {
+			continue
+		}
+
+		s.curr = &tombstonesSeries{Series: series, intervals: intervals}
+		return true
+	}
+
+	return false
+}
+
+func (s *tombstonesSeriesSet) At() storage.Series {
+	return s.curr
+}
+
+func (s *tombstonesSeriesSet) Err() error {
+	return s.SeriesSet.Err()
+}
+
+func (s *tombstonesSeriesSet) Warnings() annotations.Annotations {
+	return s.SeriesSet.Warnings()
+}
+
+// tombstonesSeries wraps a series to skip the samples within the deleted intervals.
+type tombstonesSeries struct {
+	storage.Series
+
+	intervals tombstones.Intervals
+}
+
+func (s *tombstonesSeries) Iterator(it chunkenc.Iterator) chunkenc.Iterator {
+	if deleted, ok := it.(*tsdb.DeletedIterator); ok {
+		deleted.Iter = s.Series.Iterator(deleted.Iter)
+		deleted.Intervals = s.intervals
+		return deleted
+	}
+
+	return &tsdb.DeletedIterator{Iter: s.Series.Iterator(nil), Intervals: s.intervals}
+}
+
+// coversRange returns whether the input intervals fully cover the range minT and maxT (both included).
+func coversRange(intervals tombstones.Intervals, minT, maxT int64) bool {
+	for _, itv := range intervals {
+		if itv.Mint <= minT && maxT <= itv.Maxt {
+			return true
+		}
+	}
+	return false
+}
+
+// filterDeletedLabelValues removes from the input values of the label name the ones which only belong to series
+// deleted by tombstones within the queried range. Only the values of the series matching the tombstones selectors
+// are checked, looking up whether any series not deleted still has them.
+func (mq multiQuerier) filterDeletedLabelValues(ctx context.Context, queriers []storage.Querier, values []string, name string, matchers []*labels.Matcher) ([]string, error) {
+	selectors, err := mq.tombstonesSelectors(ctx)
+	if err != nil || len(selectors) == 0 {
+		return values, err
+	}
+
+	var candidates []string
+	for _, selector := range selectors {
+		deleted, _, err := labelValues(ctx, queriers, name, append(slices.Clone(matchers), selector...)...)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, deleted...)
+	}
+
+	return mq.removeDeleted(ctx, values, candidates, func(value string) []*labels.Matcher {
+		return append(slices.Clone(matchers), labels.MustNewMatcher(labels.MatchEqual, name, value))
+	})
+}
+
+// filterDeletedLabelNames removes from the input label names the ones which only belong to series deleted by
+// tombstones within the queried range. Only the label names of the series matching the tombstones selectors
+// are checked, looking up whether any series not deleted still has them.
+func (mq multiQuerier) filterDeletedLabelNames(ctx context.Context, queriers []storage.Querier, names []string, matchers []*labels.Matcher) ([]string, error) {
+	selectors, err := mq.tombstonesSelectors(ctx)
+	if err != nil || len(selectors) == 0 {
+		return names, err
+	}
+
+	var candidates []string
+	for _, selector := range selectors {
+		deleted, _, err := labelNames(ctx, queriers, append(slices.Clone(matchers), selector...)...)
+		if err != nil {
+			return nil, err
+		}
+		candidates = append(candidates, deleted...)
+	}
+
+	return mq.removeDeleted(ctx, names, candidates, func(name string) []*labels.Matcher {
+		return append(slices.Clone(matchers), labels.MustNewMatcher(labels.MatchNotEqual, name, ""))
+	})
+}
+
+// removeDeleted removes from the input items the candidates for which no series matching the matchers
+// returned by seriesMatchers is left once the tombstones have been applied.
+func (mq multiQuerier) removeDeleted(ctx context.Context, items, candidates []string, seriesMatchers func(string) []*labels.Matcher) ([]string, error) {
+	slices.Sort(candidates)
+	candidates = slices.Compact(candidates)
+
+	deleted := map[string]struct{}{}
+	for _, candidate := range candidates {
+		set := mq.Select(ctx, true, &storage.SelectHints{Start: mq.minT, End: mq.maxT, Func: "series"}, seriesMatchers(candidate)...)
+		if set.Next() {
+			continue
+		}
+		if err := set.Err(); err != nil {
+			return nil, err
+		}
+		deleted[candidate] = struct{}{}
+	}
+
+	if len(deleted) == 0 {
+		return items, nil
+	}
+
+	filtered := make([]string, 0, len(items))
+	for _, item := range items {
+		if _, ok := deleted[item]; !ok {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered, nil
+}
+
+// tombstonesSelectors returns the series selectors of the tombstones overlapping the queried range.
+func (mq multiQuerier) tombstonesSelectors(ctx context.Context) ([][]*labels.Matcher, error) {
+	if mq.tombstonesFinder == nil {
+		return nil, nil
+	}
+
+	userID, err := tenant.TenantID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tombstones, err := mq.tombstonesFinder.GetTombstones(ctx, userID, mq.minT, mq.maxT)
+	if err != nil {
+		return nil, err
+	}
+
+	var selectors [][]*labels.Matcher
+	for _, t := range tombstones {
+		parsed, err := t.ParseSelectors()
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, parsed...)
+	}
+	return selectors, nil
+}
@@ -0,0 +1,205 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querier
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/grafana/dskit/user"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/storage/series"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestTombstonesSeriesSet(t *testing.T) {
+	samples := []model.SamplePair{{Timestamp: 10, Value: 1}, {Timestamp: 20, Value: 2}, {Timestamp: 30, Value: 3}, {Timestamp: 40, Value: 4}}
+
+	newSeriesSet := func() storage.SeriesSet {
+		return series.NewConcreteSeriesSetFromSortedSeries([]storage.Series{
+			series.NewConcreteSeries(labels.FromStrings("job", "a"), samples, nil),
+			series.NewConcreteSeries(labels.FromStrings("job", "b"), samples, nil),
+			series.NewConcreteSeries(labels.FromStrings("job", "c"), samples, nil),
+		})
+	}
+
+	newTombstone := func(selector string, minT, maxT int64) *mimir_tsdb.Tombstone {
+		tombstone, err := mimir_tsdb.NewTombstone([]string{selector}, minT, maxT, time.Now())
+		require.NoError(t, err)
+		return tombstone
+	}
+
+	tests := map[string]struct {
+		tombstones mimir_tsdb.Tombstones
+		expected   map[string][]int64
+	}{
+		"no tombstones": {
+			expected: map[string][]int64{
+				`{job="a"}`: {10, 20, 30, 40},
+				`{job="b"}`: {10, 20, 30, 40},
+				`{job="c"}`: {10, 20, 30, 40},
+			},
+		},
+		"tombstone covering the whole queried range": {
+			tombstones: mimir_tsdb.Tombstones{newTombstone(`{job="b"}`, 0, 50)},
+			expected: map[string][]int64{
+				`{job="a"}`: {10, 20, 30, 40},
+				`{job="c"}`: {10, 20, 30, 40},
+			},
+		},
+		"tombstones covering part of the queried range": {
+			tombstones: mimir_tsdb.Tombstones{newTombstone(`{job=~"a|b"}`, 15, 30), newTombstone(`{job="b"}`, 40, 100)},
+			expected: map[string][]int64{
+				`{job="a"}`: {10, 40},
+				`{job="b"}`: {10},
+				`{job="c"}`: {10, 20, 30, 40},
+			},
+		},
+		"multiple tombstones covering the whole queried range together": {
+			tombstones: mimir_tsdb.Tombstones{newTombstone(`{job="c"}`, 0, 25), newTombstone(`{job="c"}`, 26, 50)},
+			expected: map[string][]int64{
+				`{job="a"}`: {10, 20, 30, 40},
+				`{job="b"}`: {10, 20, 30, 40},
+			},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			set, err := newTombstonesSeriesSet(newSeriesSet(), testData.tombstones, 0, 50)
+			require.NoError(t, err)
+
+			actual := map[string][]int64{}
+			for set.Next() {
+				var timestamps []int64
+				it := set.At().Iterator(nil)
+				for it.Next() != chunkenc.ValNone {
+					timestamps = append(timestamps, it.AtT())
+				}
+				require.NoError(t, it.Err())
+
+				actual[set.At().Labels().String()] = timestamps
+			}
+			require.NoError(t, set.Err())
+
+			assert.Equal(t, testData.expected, actual)
+		})
+	}
+}
+
+func TestMultiQuerier_LabelNamesAndValues_ShouldHonorTombstones(t *testing.T) {
+	var (
+		ctx  = user.InjectOrgID(context.Background(), "user-1")
+		now  = time.Now()
+		minT = now.Add(-2 * time.Hour).UnixMilli()
+		maxT = now.Add(-time.Hour).UnixMilli()
+	)
+
+	head, err := tsdb.NewHead(nil, nil, nil, nil, tsdb.DefaultHeadOptions(), nil)
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		_ = head.Close()
+	})
+
+	app := head.Appender(ctx)
+	for _, lbls := range []labels.Labels{
+		labels.FromStrings(model.MetricNameLabel, "up", "job", "a"),
+		labels.FromStrings(model.MetricNameLabel, "up", "job", "b", "email", "user@example.com"),
+		labels.FromStrings(model.MetricNameLabel, "requests", "job", "b"),
+	} {
+		for ts := minT; ts <= maxT; ts += time.Minute.Milliseconds() {
+			_, err := app.Append(0, lbls, ts, 1)
+			require.NoError(t, err)
+		}
+	}
+	require.NoError(t, app.Commit())
+
+	blockStore := storage.QueryableFunc(func(mint, maxt int64) (storage.Querier, error) {
+		return tsdb.NewBlockQuerier(head, mint, maxt)
+	})
+
+	newTombstones := func(selector string, minT, maxT int64) mimir_tsdb.Tombstones {
+		tombstone, err := mimir_tsdb.NewTombstone([]string{selector}, minT, maxT, time.Now())
+		require.NoError(t, err)
+		return mimir_tsdb.Tombstones{tombstone}
+	}
+
+	tests := map[string]struct {
+		tombstones     mimir_tsdb.Tombstones
+		matchers       []*labels.Matcher
+		expectedNames  []string
+		expectedValues []string
+	}{
+		"no tombstones": {
+			expectedNames:  []string{"__name__", "email", "job"},
+			expectedValues: []string{"a", "b"},
+		},
+		"tombstone deleting the only series with a label name": {
+			tombstones:     newTombstones(`{email="user@example.com"}`, minT, maxT),
+			expectedNames:  []string{"__name__", "job"},
+			expectedValues: []string{"a", "b"},
+		},
+		"tombstone deleting some of the series with a label value": {
+			tombstones:     newTombstones(`{__name__="up", job="b"}`, minT, maxT),
+			expectedNames:  []string{"__name__", "job"},
+			expectedValues: []string{"a", "b"},
+		},
+		"tombstone deleting all the series with a label value": {
+			tombstones:     newTombstones(`{job="b"}`, minT, maxT),
+			expectedNames:  []string{"__name__", "job"},
+			expectedValues: []string{"a"},
+		},
+		"tombstone deleting all the series with a label value, with matchers": {
+			tombstones:     newTombstones(`{job="b"}`, minT, maxT),
+			matchers:       []*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, model.MetricNameLabel, "up")},
+			expectedNames:  []string{"__name__", "job"},
+			expectedValues: []string{"a"},
+		},
+		"tombstone partially covering the queried range": {
+			tombstones:     newTombstones(`{job="b"}`, minT, maxT-time.Minute.Milliseconds()),
+			expectedNames:  []string{"__name__", "email", "job"},
+			expectedValues: []string{"a", "b"},
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := Config{}
+			flagext.DefaultValues(&cfg)
+			cfg.QueryStoreAfter = 0
+
+			overrides, err := validation.NewOverrides(defaultLimitsConfig(), nil)
+			require.NoError(t, err)
+
+			queryable := newQueryable(nil, blockStore, staticTombstonesFinder(testData.tombstones), cfg, overrides, stats.NewQueryMetrics(nil), log.NewNopLogger())
+			q, err := queryable.Querier(minT, maxT)
+			require.NoError(t, err)
+
+			names, _, err := q.LabelNames(ctx, testData.matchers...)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedNames, names)
+
+			values, _, err := q.LabelValues(ctx, "job", testData.matchers...)
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedValues, values)
+		})
+	}
+}
+
+type staticTombstonesFinder mimir_tsdb.Tombstones
+
+func (f staticTombstonesFinder) GetTombstones(_ context.Context, _ string, minT, maxT int64) (mimir_tsdb.Tombstones, error) {
+	return mimir_tsdb.Tombstones(f).Overlapping(minT, maxT), nil
+}
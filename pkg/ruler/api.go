@@ -30,6 +30,7 @@ import (
 	"github.com/grafana/mimir/pkg/mimirpb"
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
 	"github.com/grafana/mimir/pkg/ruler/rulestore"
+	"github.com/grafana/mimir/pkg/ruler/ruletest"
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 )
 
@@ -71,15 +72,37 @@ type RuleGroup struct {
 	// In order to preserve rule ordering, while exposing type (alerting or recording)
 	// specific properties, both alerting and recording rules are exposed in the
 	// same array.
-	Rules          []rule    `json:"rules"`
-	Interval       float64   `json:"interval"`
-	LastEvaluation time.Time `json:"lastEvaluation"`
-	EvaluationTime float64   `json:"evaluationTime"`
-	SourceTenants  []string  `json:"sourceTenants"`
+	Rules          []rule      `json:"rules"`
+	Interval       float64     `json:"interval"`
+	LastEvaluation time.Time   `json:"lastEvaluation"`
+	EvaluationTime float64     `json:"evaluationTime"`
+	SourceTenants  []string    `json:"sourceTenants"`
+	QueryStats     *queryStats `json:"queryStats,omitempty"`
 }
 
 type rule interface{}
 
+// queryStats holds the statistics of the queries run by the last evaluation of a rule or rule group.
+type queryStats struct {
+	WallTime           float64 `json:"wallTime"`
+	FetchedSeriesCount uint64  `json:"fetchedSeriesCount"`
+	FetchedChunksCount uint64  `json:"fetchedChunksCount"`
+	FetchedChunkBytes  uint64  `json:"fetchedChunkBytes"`
+}
+
+// newQueryStats returns nil if no query statistics have been tracked.
+func newQueryStats(s RuleQueryStats) *queryStats {
+	if s == (RuleQueryStats{}) {
+		return nil
+	}
+	return &queryStats{
+		WallTime:           s.WallTime.Seconds(),
+		FetchedSeriesCount: s.FetchedSeriesCount,
+		FetchedChunksCount: s.FetchedChunksCount,
+		FetchedChunkBytes:  s.FetchedChunkBytes,
+	}
+}
+
 type alertingRule struct {
 	// State can be "pending", "firing", "inactive".
 	State          string        `json:"state"`
@@ -95,6 +118,7 @@ type alertingRule struct {
 	Type           v1.RuleType   `json:"type"`
 	LastEvaluation time.Time     `json:"lastEvaluation"`
 	EvaluationTime float64       `json:"evaluationTime"`
+	QueryStats     *queryStats   `json:"queryStats,omitempty"`
 }
 
 type recordingRule struct {
@@ -106,6 +130,7 @@ type recordingRule struct {
 	Type           v1.RuleType   `json:"type"`
 	LastEvaluation time.Time     `json:"lastEvaluation"`
 	EvaluationTime float64       `json:"evaluationTime"`
+	QueryStats     *queryStats   `json:"queryStats,omitempty"`
 }
 
 func respondError(logger log.Logger, w http.ResponseWriter, status int, errorType v1.ErrorType, msg string) {
@@ -203,6 +228,7 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 			LastEvaluation: g.GetEvaluationTimestamp(),
 			EvaluationTime: g.GetEvaluationDuration().Seconds(),
 			SourceTenants:  g.Group.GetSourceTenants(),
+			QueryStats:     newQueryStats(g.QueryStats),
 		}
 
 		for i, rl := range g.ActiveRules {
@@ -225,6 +251,7 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 					LastEvaluation: rl.GetEvaluationTimestamp(),
 					EvaluationTime: rl.GetEvaluationDuration().Seconds(),
 					Type:           v1.RuleTypeAlerting,
+					QueryStats:     newQueryStats(rl.QueryStats),
 				}
 			} else {
 				grp.Rules[i] = recordingRule{
@@ -236,6 +263,7 @@ func (a *API) PrometheusRules(w http.ResponseWriter, req *http.Request) {
 					LastEvaluation: rl.GetEvaluationTimestamp(),
 					EvaluationTime: rl.GetEvaluationDuration().Seconds(),
 					Type:           v1.RuleTypeRecording,
+					QueryStats:     newQueryStats(rl.QueryStats),
 				}
 			}
 		}
@@ -611,6 +639,78 @@ func (a *API) DeleteRuleGroup(w http.ResponseWriter, req *http.Request) {
 	respondAccepted(w, logger)
 }
 
+// maxTestRulesPayloadSize is the maximum size of the body of a rules test request.
+const maxTestRulesPayloadSize = 1 << 20
+
+// TestRules runs the rules unit tests in the request body, in the format used by "promtool test rules"
+// but with the rule groups under test included in the body, and returns a report of the results.
+// The rule groups are validated as if they were uploaded, and evaluated in an isolated in-memory storage.
+func (a *API) TestRules(w http.ResponseWriter, req *http.Request) {
+	logger, ctx := spanlogger.NewWithLogger(req.Context(), a.logger, "API.TestRules")
+	defer logger.Finish()
+
+	userID, err := tenant.TenantID(ctx)
+	if err != nil || userID == "" {
+		level.Error(logger).Log("msg", "error extracting org id from context", "err", err)
+		respondServerError(logger, w, "no valid org id found")
+		return
+	}
+
+	payload, err := io.ReadAll(http.MaxBytesReader(w, req.Body, maxTestRulesPayloadSize))
+	if err != nil {
+		level.Error(logger).Log("msg", "unable to read rules test payload", "err", err.Error())
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			http.Error(w, fmt.Sprintf("the rules test payload exceeds the maximum size of %d bytes", maxTestRulesPayloadSize), http.StatusRequestEntityTooLarge)
+			return
+		}
+		respondInvalidRequest(logger, w, err.Error())
+		return
+	}
+
+	file := ruletest.TestFile{}
+	if err := yaml.Unmarshal(payload, &file); err != nil {
+		level.Error(logger).Log("msg", "unable to unmarshal rules test payload", "err", err.Error())
+		respondInvalidRequest(logger, w, "unable to decode rules test: "+err.Error())
+		return
+	}
+
+	var errs []string
+	for _, rg := range file.Groups {
+		for _, err := range a.ruler.manager.ValidateRuleGroup(rg) {
+			errs = append(errs, err.Error())
+		}
+		if err := a.ruler.AssertMaxRulesPerRuleGroup(userID, len(rg.Rules)); err != nil {
+			errs = append(errs, err.Error())
+		}
+	}
+	if len(errs) > 0 {
+		respondInvalidRequest(logger, w, strings.Join(errs, ", "))
+		return
+	}
+
+	report, err := ruletest.Run(ctx, file, logger)
+	if err != nil {
+		respondInvalidRequest(logger, w, err.Error())
+		return
+	}
+
+	b, err := json.Marshal(&response{
+		Status: "success",
+		Data:   report,
+	})
+	if err != nil {
+		level.Error(logger).Log("msg", "error marshaling json response", "err", err)
+		respondServerError(logger, w, "unable to marshal the requested data")
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if n, err := w.Write(b); err != nil {
+		level.Error(logger).Log("msg", "error writing response", "bytesWritten", n, "err", err)
+	}
+}
+
 // alertStateDescToPrometheusAlert converts AlertStateDesc to Alert. The returned data structure is suitable
 // to be exported by the user-facing API.
 func alertStateDescToPrometheusAlert(d *AlertStateDesc) *Alert {
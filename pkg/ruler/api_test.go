@@ -31,6 +31,7 @@ import (
 	"gopkg.in/yaml.v3"
 
 	"github.com/grafana/mimir/pkg/ruler/rulespb"
+	"github.com/grafana/mimir/pkg/ruler/ruletest"
 	"github.com/grafana/mimir/pkg/util/validation"
 )
 
@@ -1199,6 +1200,118 @@ func TestAPI_DeleteRuleGroup(t *testing.T) {
 	})
 }
 
+func TestAPI_TestRules(t *testing.T) {
+	cfg := defaultRulerConfig(t)
+
+	r := prepareRuler(t, cfg, newMockRuleStore(make(map[string]rulespb.RuleGroupList)), withStart(), withLimits(validation.MockOverrides(func(defaults *validation.Limits, _ map[string]*validation.Limits) {
+		defaults.RulerMaxRulesPerRuleGroup = 2
+	})))
+	a := NewAPI(r, r.directStore, log.NewNopLogger())
+
+	tc := map[string]struct {
+		input          string
+		expectedStatus int
+		expectedBody   string
+	}{
+		"passing tests": {
+			input: `
+groups:
+  - name: test
+    rules:
+      - alert: InstanceDown
+        expr: up == 0
+        for: 1m
+tests:
+  - input_series:
+      - series: 'up{job="test"}'
+        values: '0 0 0'
+    alert_rule_test:
+      - alertname: InstanceDown
+        eval_time: 2m
+        exp_alerts:
+          - exp_labels:
+              job: test
+`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","data":{"passed":true,"tests":[{"name":"test #1","passed":true}]},"errorType":"","error":""}`,
+		},
+		"failing tests": {
+			input: `
+groups:
+  - name: test
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+tests:
+  - name: failing
+    input_series:
+      - series: 'up{job="test"}'
+        values: '1'
+    promql_expr_test:
+      - expr: job:up:sum
+        eval_time: 0m
+        exp_samples:
+          - labels: 'job:up:sum{job="test"}'
+            value: 2
+`,
+			expectedStatus: http.StatusOK,
+			expectedBody:   `{"status":"success","data":{"passed":false,"tests":[{"name":"failing","passed":false,"errors":["expr: \"job:up:sum\", time: 0s, exp: [{__name__=\"job:up:sum\", job=\"test\"} 2], got: [{__name__=\"job:up:sum\", job=\"test\"} 1]"]}]},"errorType":"","error":""}`,
+		},
+		"invalid rule group": {
+			input: `
+groups:
+  - name: test
+    rules:
+      - record: invalid metric name
+        expr: up
+`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"error","data":null,"errorType":"bad_data","error":"5:17: group \"test\", rule 0, \"invalid metric name\": invalid recording rule name: invalid metric name"}`,
+		},
+		"exceeding the rules per rule group limit": {
+			input: `
+groups:
+  - name: test
+    rules:
+      - record: first
+        expr: up
+      - record: second
+        expr: up
+      - record: third
+        expr: up
+`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"error","data":null,"errorType":"bad_data","error":"per-user rules per rule group limit (limit: 2 actual: 3) exceeded"}`,
+		},
+		"invalid payload": {
+			input:          `groups: {`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"error","data":null,"errorType":"bad_data","error":"unable to decode rules test: yaml: line 1: did not find expected node content"}`,
+		},
+		"too many test groups": {
+			input:          "tests:\n" + strings.Repeat("  - name: test\n", ruletest.MaxTestGroupsPerFile+1),
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   `{"status":"error","data":null,"errorType":"bad_data","error":"the file has 101 test groups, while the maximum allowed is 100"}`,
+		},
+		"payload exceeding the max size": {
+			input:          "groups: []\n" + strings.Repeat("#", maxTestRulesPayloadSize),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+			expectedBody:   "the rules test payload exceeds the maximum size of 1048576 bytes\n",
+		},
+	}
+
+	for name, tt := range tc {
+		t.Run(name, func(t *testing.T) {
+			req := requestFor(t, http.MethodPost, "https://localhost:8080/ruler/test", strings.NewReader(tt.input), "user1")
+			w := httptest.NewRecorder()
+
+			a.TestRules(w, req)
+			require.Equal(t, tt.expectedStatus, w.Code)
+			require.Equal(t, tt.expectedBody, w.Body.String())
+		})
+	}
+}
+
 func TestRuler_LimitsPerGroup(t *testing.T) {
 	cfg := defaultRulerConfig(t)
 
@@ -1407,3 +1520,12 @@ func requestFor(t *testing.T, method string, url string, body io.Reader, userID
 
 	return req.WithContext(ctx)
 }
+
+func TestNewQueryStats(t *testing.T) {
+	assert.Nil(t, newQueryStats(RuleQueryStats{}))
+
+	stats := newQueryStats(RuleQueryStats{WallTime: 1500 * time.Millisecond, FetchedSeriesCount: 1, FetchedChunksCount: 2, FetchedChunkBytes: 3})
+	data, err := json.Marshal(stats)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"wallTime": 1.5, "fetchedSeriesCount": 1, "fetchedChunksCount": 2, "fetchedChunkBytes": 3}`, string(data))
+}
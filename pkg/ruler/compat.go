@@ -150,6 +150,7 @@ type RulesLimits interface {
 	RulerRecordingRulesEvaluationEnabled(userID string) bool
 	RulerAlertingRulesEvaluationEnabled(userID string) bool
 	RulerSyncRulesOnChangesEnabled(userID string) bool
+	RulerMaxIndependentRuleEvaluationConcurrencyPerTenant(userID string) int
 }
 
 func MetricsQueryFunc(qf rules.QueryFunc, queries, failedQueries prometheus.Counter) rules.QueryFunc {
@@ -287,6 +288,7 @@ func DefaultTenantManagerFactory(
 	})
 	var rulerQuerySeconds *prometheus.CounterVec
 	var zeroFetchedSeriesQueries *prometheus.CounterVec
+	var fetchedSeries, fetchedChunks, fetchedChunkBytes *prometheus.CounterVec
 	if cfg.EnableQueryStats {
 		rulerQuerySeconds = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
 			Name: "cortex_ruler_query_seconds_total",
@@ -296,23 +298,43 @@ func DefaultTenantManagerFactory(
 			Name: "cortex_ruler_queries_zero_fetched_series_total",
 			Help: "Number of queries that did not fetch any series by ruler.",
 		}, []string{"user"})
+		fetchedSeries = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_query_fetched_series_total",
+			Help: "Total number of series fetched by the queries run by the ruler.",
+		}, []string{"user"})
+		fetchedChunks = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_query_fetched_chunks_total",
+			Help: "Total number of chunks fetched by the queries run by the ruler.",
+		}, []string{"user"})
+		fetchedChunkBytes = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_query_fetched_chunk_bytes_total",
+			Help: "Total size in bytes of the chunks fetched by the queries run by the ruler.",
+		}, []string{"user"})
 	}
+	concurrencyMetrics := newRuleConcurrencyMetrics(reg)
+
 	return func(ctx context.Context, userID string, notifier *notifier.Manager, logger log.Logger, reg prometheus.Registerer) RulesManager {
 		var queryTime prometheus.Counter
 		var zeroFetchedSeriesCount prometheus.Counter
+		var queryStats *ruleQueryStatsTracker
 		if rulerQuerySeconds != nil {
 			queryTime = rulerQuerySeconds.WithLabelValues(userID)
 			zeroFetchedSeriesCount = zeroFetchedSeriesQueries.WithLabelValues(userID)
+			queryStats = newRuleQueryStatsTracker(fetchedSeries.WithLabelValues(userID), fetchedChunks.WithLabelValues(userID), fetchedChunkBytes.WithLabelValues(userID))
 		}
 		var wrappedQueryFunc rules.QueryFunc
 
 		wrappedQueryFunc = MetricsQueryFunc(queryFunc, totalQueries, failedQueries)
+		wrappedQueryFunc = recordRuleQueryStats(wrappedQueryFunc, queryStats)
 		wrappedQueryFunc = RecordAndReportRuleQueryMetrics(wrappedQueryFunc, queryTime, zeroFetchedSeriesCount, logger)
 
-		return rules.NewManager(&rules.ManagerOptions{
+		// The independent rules are queried concurrently with the same query function used by the rules manager.
+		concurrencyController := newRuleConcurrencyController(userID, overrides, wrappedQueryFunc, concurrencyMetrics)
+
+		manager := rules.NewManager(&rules.ManagerOptions{
 			Appendable:                 NewPusherAppendable(p, userID, totalWrites, failedWrites),
 			Queryable:                  embeddedQueryable,
-			QueryFunc:                  wrappedQueryFunc,
+			QueryFunc:                  concurrencyController.QueryFunc(wrappedQueryFunc),
 			Context:                    user.InjectOrgID(ctx, userID),
 			GroupEvaluationContextFunc: FederatedGroupContextFunc,
 			ExternalURL:                cfg.ExternalURL.URL,
@@ -329,6 +351,12 @@ func DefaultTenantManagerFactory(
 				return overrides.EvaluationDelay(userID)
 			},
 		})
+
+		return &managerWithEvalIterationFunc{
+			Manager:           manager,
+			evalIterationFunc: concurrencyController.EvalIterationFunc,
+			queryStats:        queryStats,
+		}
 	}
 }
 
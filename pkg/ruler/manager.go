@@ -362,6 +362,22 @@ func (r *DefaultMultiTenantManager) GetRules(userID string) []*promRules.Group {
 	return nil
 }
 
+// ruleQueryStatsProvider is implemented by the RulesManager which track the query statistics of their rules.
+type ruleQueryStatsProvider interface {
+	RuleQueryStats(group *promRules.Group, rule promRules.Rule) RuleQueryStats
+}
+
+func (r *DefaultMultiTenantManager) GetRuleQueryStats(userID string, group *promRules.Group, rule promRules.Rule) RuleQueryStats {
+	r.userManagerMtx.RLock()
+	mngr, exists := r.userManagers[userID]
+	r.userManagerMtx.RUnlock()
+
+	if provider, ok := mngr.(ruleQueryStatsProvider); exists && ok {
+		return provider.RuleQueryStats(group, rule)
+	}
+	return RuleQueryStats{}
+}
+
 func (r *DefaultMultiTenantManager) Stop() {
 	r.notifiersMtx.Lock()
 	for _, n := range r.notifiers {
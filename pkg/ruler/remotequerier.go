@@ -31,6 +31,7 @@ import (
 	"golang.org/x/exp/slices"
 	"google.golang.org/grpc"
 
+	"github.com/grafana/mimir/pkg/querier/api"
 	"github.com/grafana/mimir/pkg/util/spanlogger"
 	"github.com/grafana/mimir/pkg/util/version"
 )
@@ -275,6 +276,7 @@ func (q *RemoteQuerier) createRequest(ctx context.Context, query string, ts time
 			{Key: textproto.CanonicalMIMEHeaderKey("Content-Type"), Values: []string{mimeTypeFormPost}},
 			{Key: textproto.CanonicalMIMEHeaderKey("Content-Length"), Values: []string{strconv.Itoa(len(body))}},
 			{Key: textproto.CanonicalMIMEHeaderKey("Accept"), Values: []string{acceptHeader}},
+			{Key: textproto.CanonicalMIMEHeaderKey(api.QueryPriorityClassHeader), Values: []string{api.QueryPriorityClassAlerting}},
 		},
 	}
 
@@ -27,6 +27,7 @@ import (
 	"google.golang.org/grpc/codes"
 
 	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/querier/api"
 )
 
 type mockHTTPGRPCClient func(ctx context.Context, req *httpgrpc.HTTPRequest, _ ...grpc.CallOption) (*httpgrpc.HTTPResponse, error)
@@ -103,6 +104,7 @@ func TestRemoteQuerier_QueryReq(t *testing.T) {
 			require.Equal(t, http.MethodPost, inReq.Method)
 			require.Equal(t, "query=qs&time="+url.QueryEscape(tm.Format(time.RFC3339Nano)), string(inReq.Body))
 			require.Equal(t, "/prometheus/api/v1/query", inReq.Url)
+			require.Equal(t, api.QueryPriorityClassAlerting, getHeader(inReq.Headers, api.QueryPriorityClassHeader))
 
 			acceptHeader := getHeader(inReq.Headers, "Accept")
 
@@ -0,0 +1,301 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"go.uber.org/atomic"
+)
+
+const (
+	// Names of the series written by alerting rules.
+	alertMetricName         = "ALERTS"
+	alertForStateMetricName = "ALERTS_FOR_STATE"
+)
+
+// ruleDependencyGraph tracks, for each rule of a rule group, the other rules of the same group whose output it selects.
+type ruleDependencyGraph [][]int
+
+// newRuleDependencyGraph builds the dependency graph of the input rules. A rule depends on another rule if any of
+// its selectors may select the series written by it, including when both rules write series with the same metric
+// name. The output of a rule is only known by the labels set by the rule, so the selectors' matchers on any other
+// label are assumed to match: for example, selectors without a metric name matcher depend on all the other rules.
+func newRuleDependencyGraph(rs []rules.Rule) ruleDependencyGraph {
+	outputs := make([][]labels.Labels, len(rs))
+	for i, rule := range rs {
+		outputs[i] = ruleOutputLabels(rule)
+	}
+
+	graph := make(ruleDependencyGraph, len(rs))
+	for i, rule := range rs {
+		selectors := parser.ExtractSelectors(rule.Query())
+
+		for j := range rs {
+			if i != j && selectsAnyOutput(selectors, outputs[j]) {
+				graph[i] = append(graph[i], j)
+			}
+		}
+	}
+
+	return graph
+}
+
+// independent returns whether the rule at the input index doesn't depend on the output of any other rule of the
+// group. Such rule can be evaluated concurrently with the other rules of the group, because its result is the same
+// whenever the other rules write their output, and its own output is still written when the rules are evaluated
+// sequentially.
+func (g ruleDependencyGraph) independent(idx int) bool {
+	return len(g[idx]) == 0
+}
+
+// ruleOutputLabels returns the labels set by the rule on the series it writes. Recording rules set their name
+// and labels, while alerting rules set the alert name on the ALERTS and ALERTS_FOR_STATE series, and may set
+// other labels depending on their templates.
+func ruleOutputLabels(rule rules.Rule) []labels.Labels {
+	if _, ok := rule.(*rules.AlertingRule); ok {
+		return []labels.Labels{
+			labels.FromStrings(labels.MetricName, alertMetricName, labels.AlertName, rule.Name()),
+			labels.FromStrings(labels.MetricName, alertForStateMetricName, labels.AlertName, rule.Name()),
+		}
+	}
+
+	builder := labels.NewBuilder(rule.Labels())
+	builder.Set(labels.MetricName, rule.Name())
+	return []labels.Labels{builder.Labels()}
+}
+
+// selectsAnyOutput returns whether any of the selectors may select series with any of the output labels.
+func selectsAnyOutput(selectors [][]*labels.Matcher, outputs []labels.Labels) bool {
+	for _, matchers := range selectors {
+		for _, output := range outputs {
+			if mayMatchOutput(matchers, output) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mayMatchOutput returns whether the matchers may match series with the output labels. Matchers on labels
+// which aren't part of the output may match any value.
+func mayMatchOutput(matchers []*labels.Matcher, output labels.Labels) bool {
+	for _, m := range matchers {
+		if value := output.Get(m.Name); value != "" && !m.Matches(value) {
+			return false
+		}
+	}
+	return true
+}
+
+type ruleConcurrencyMetrics struct {
+	slotsInUse         *prometheus.GaugeVec
+	attemptsStarted    *prometheus.CounterVec
+	attemptsIncomplete *prometheus.CounterVec
+	attemptsCompleted  *prometheus.CounterVec
+}
+
+func newRuleConcurrencyMetrics(reg prometheus.Registerer) *ruleConcurrencyMetrics {
+	return &ruleConcurrencyMetrics{
+		slotsInUse: promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+			Name: "cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use",
+			Help: "Current number of concurrency slots used to evaluate independent rules concurrently.",
+		}, []string{"user"}),
+		attemptsStarted: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total",
+			Help: "Total number of attempts to evaluate an independent rule concurrently with the other rules of its rule group.",
+		}, []string{"user"}),
+		attemptsIncomplete: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total",
+			Help: "Total number of independent rules evaluated sequentially because no concurrency slot was available.",
+		}, []string{"user"}),
+		attemptsCompleted: promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+			Name: "cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total",
+			Help: "Total number of independent rules evaluated concurrently with the other rules of their rule group.",
+		}, []string{"user"}),
+	}
+}
+
+// ruleConcurrencyController evaluates the independent rules of a tenant's rule groups concurrently, bounded by
+// the tenant's concurrency limit.
+//
+// The Prometheus rules manager evaluates the rules of each group sequentially, so the concurrent evaluation is
+// achieved by running the queries of the independent rules before the group is evaluated, and returning their
+// results to the rules once the group evaluation gets to them. The rules which depend on the output of any other rule
+// of the group are still queried when their turn comes, as they would be by the sequential evaluation.
+type ruleConcurrencyController struct {
+	userID    string
+	limits    RulesLimits
+	queryFunc rules.QueryFunc
+
+	slotsInUse         atomic.Int64
+	slotsInUseGauge    prometheus.Gauge
+	attemptsStarted    prometheus.Counter
+	attemptsIncomplete prometheus.Counter
+	attemptsCompleted  prometheus.Counter
+}
+
+func newRuleConcurrencyController(userID string, limits RulesLimits, queryFunc rules.QueryFunc, metrics *ruleConcurrencyMetrics) *ruleConcurrencyController {
+	return &ruleConcurrencyController{
+		userID:             userID,
+		limits:             limits,
+		queryFunc:          queryFunc,
+		slotsInUseGauge:    metrics.slotsInUse.WithLabelValues(userID),
+		attemptsStarted:    metrics.attemptsStarted.WithLabelValues(userID),
+		attemptsIncomplete: metrics.attemptsIncomplete.WithLabelValues(userID),
+		attemptsCompleted:  metrics.attemptsCompleted.WithLabelValues(userID),
+	}
+}
+
+// EvalIterationFunc is a rules.GroupEvalIterationFunc which starts the evaluation of the group's independent
+// rules concurrently, and then evaluates the group.
+func (c *ruleConcurrencyController) EvalIterationFunc(ctx context.Context, g *rules.Group, evalTimestamp time.Time) {
+	groupRules := g.Rules()
+	if len(groupRules) < 2 || c.limits.RulerMaxIndependentRuleEvaluationConcurrencyPerTenant(c.userID) <= 0 {
+		rules.DefaultEvalIterationFunc(ctx, g, evalTimestamp)
+		return
+	}
+
+	graph := newRuleDependencyGraph(groupRules)
+	queryTime := evalTimestamp.Add(-g.EvaluationDelay())
+	results := &prefetchedQueries{}
+
+	for i, rule := range groupRules {
+		if !graph.independent(i) {
+			continue
+		}
+
+		c.attemptsStarted.Inc()
+		if !c.tryAcquireSlot() {
+			c.attemptsIncomplete.Inc()
+			continue
+		}
+
+		query := results.add(rule.Query().String(), queryTime)
+		// The query is run on behalf of the rule, as it would be by the rule evaluation.
+		ruleCtx := rules.NewOriginContext(ctx, rules.NewRuleDetail(rule))
+		go func() {
+			// The slot is released before the result is returned to the rule.
+			defer close(query.done)
+			defer c.releaseSlot()
+
+			query.vector, query.err = c.queryFunc(ruleCtx, query.qs, query.t)
+			c.attemptsCompleted.Inc()
+		}()
+	}
+
+	rules.DefaultEvalIterationFunc(context.WithValue(ctx, prefetchedQueriesContextKey, results), g, evalTimestamp)
+}
+
+// QueryFunc wraps the input rules.QueryFunc to return the results of the queries run concurrently by
+// EvalIterationFunc, if any.
+func (c *ruleConcurrencyController) QueryFunc(qf rules.QueryFunc) rules.QueryFunc {
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		if results, ok := ctx.Value(prefetchedQueriesContextKey).(*prefetchedQueries); ok {
+			if query := results.take(qs, t); query != nil {
+				<-query.done
+				return query.vector, query.err
+			}
+		}
+		return qf(ctx, qs, t)
+	}
+}
+
+func (c *ruleConcurrencyController) tryAcquireSlot() bool {
+	limit := int64(c.limits.RulerMaxIndependentRuleEvaluationConcurrencyPerTenant(c.userID))
+
+	for {
+		inUse := c.slotsInUse.Load()
+		if inUse >= limit {
+			return false
+		}
+		if c.slotsInUse.CompareAndSwap(inUse, inUse+1) {
+			c.slotsInUseGauge.Inc()
+			return true
+		}
+	}
+}
+
+func (c *ruleConcurrencyController) releaseSlot() {
+	c.slotsInUse.Dec()
+	c.slotsInUseGauge.Dec()
+}
+
+const prefetchedQueriesContextKey contextKey = 2
+
+// prefetchedQuery is a query run ahead of the evaluation of its rule. The result is set before done is closed.
+type prefetchedQuery struct {
+	qs   string
+	t    time.Time
+	done chan struct{}
+
+	vector promql.Vector
+	err    error
+}
+
+// prefetchedQueries holds the queries run ahead of the evaluation of a rule group. Each query is returned
+// once, since rules may modify the result of their query.
+type prefetchedQueries struct {
+	mtx     sync.Mutex
+	queries []*prefetchedQuery
+}
+
+func (p *prefetchedQueries) add(qs string, t time.Time) *prefetchedQuery {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	query := &prefetchedQuery{qs: qs, t: t, done: make(chan struct{})}
+	p.queries = append(p.queries, query)
+	return query
+}
+
+func (p *prefetchedQueries) take(qs string, t time.Time) *prefetchedQuery {
+	p.mtx.Lock()
+	defer p.mtx.Unlock()
+
+	for i, query := range p.queries {
+		if query.qs == qs && query.t.Equal(t) {
+			p.queries = append(p.queries[:i], p.queries[i+1:]...)
+			return query
+		}
+	}
+	return nil
+}
+
+// managerWithEvalIterationFunc is a RulesManager which evaluates rule groups with a custom rules.GroupEvalIterationFunc
+// when none is passed to Update(), and which exposes the query statistics of its rules, if tracked.
+type managerWithEvalIterationFunc struct {
+	*rules.Manager
+
+	evalIterationFunc rules.GroupEvalIterationFunc
+	queryStats        *ruleQueryStatsTracker
+}
+
+func (m *managerWithEvalIterationFunc) Update(interval time.Duration, files []string, externalLabels labels.Labels, externalURL string, groupEvalIterationFunc rules.GroupEvalIterationFunc) error {
+	if groupEvalIterationFunc == nil {
+		groupEvalIterationFunc = m.evalIterationFunc
+	}
+	err := m.Manager.Update(interval, files, externalLabels, externalURL, groupEvalIterationFunc)
+
+	// Forget about the rules which are no longer evaluated.
+	if m.queryStats != nil {
+		m.queryStats.retain(m.RuleGroups())
+	}
+	return err
+}
+
+// RuleQueryStats returns the statistics of the queries run by the last evaluation of the rule.
+func (m *managerWithEvalIterationFunc) RuleQueryStats(group *rules.Group, rule rules.Rule) RuleQueryStats {
+	if m.queryStats == nil {
+		return RuleQueryStats{}
+	}
+	return m.queryStats.ruleStats(group, rule)
+}
@@ -0,0 +1,336 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/util/teststorage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/atomic"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/util/validation"
+)
+
+func TestRuleDependencyGraph(t *testing.T) {
+	groupRules := []rules.Rule{
+		/* 0 */ newTestRecordingRule(t, "job:a:sum", `sum by (job) (a)`),
+		/* 1 */ newTestRecordingRule(t, "job:b:sum", `sum by (job) (b)`),
+		/* 2 */ newTestRecordingRule(t, "job:ab:ratio", `job:a:sum / job:b:sum`),
+		/* 3 */ newTestAlertingRule(t, "HighRatio", `job:ab:ratio > 1`),
+		/* 4 */ newTestRecordingRule(t, "job:c:sum", `sum by (job) (c)`),
+		/* 5 */ newTestRecordingRule(t, "alerts:count", `count(ALERTS{alertstate="firing"})`),
+		/* 6 */ newTestRecordingRule(t, "job:any:count", `count by (job) ({job="test"})`),
+		/* 7 */ newTestRecordingRule(t, "job:regex:sum", `sum by (job) ({__name__=~"job:[ab]:sum"})`),
+		/* 8 */ newTestRecordingRule(t, "job:later:sum", `sum by (job) (job:last:sum)`),
+		/* 9 */ newTestRecordingRule(t, "job:last:sum", `sum by (job) (d)`),
+		/* 10 */ newTestRecordingRuleWithLabels(t, "job:a:sum", `sum by (job) (e)`, labels.FromStrings("env", "prod")),
+		/* 11 */ newTestRecordingRule(t, "job:prod:sum", `sum by (job) (job:a:sum{env="prod"})`),
+		/* 12 */ newTestRecordingRule(t, "job:dev:sum", `sum by (job) (job:a:sum{env="dev"})`),
+		/* 13 */ newTestRecordingRule(t, "alerts:missing:count", `count(ALERTS{alertname="Missing"})`),
+	}
+
+	graph := newRuleDependencyGraph(groupRules)
+
+	assert.Equal(t, ruleDependencyGraph{
+		nil,
+		nil,
+		{0, 1, 10},
+		{2},
+		nil,
+		{3},
+		{0, 1, 2, 3, 4, 5, 7, 8, 9, 10, 11, 12, 13},
+		{0, 1, 10},
+		{9},
+		nil,
+		nil,
+		{0, 10},
+		{0},
+		nil,
+	}, graph)
+
+	var independent []int
+	for i := range groupRules {
+		if graph.independent(i) {
+			independent = append(independent, i)
+		}
+	}
+
+	// Rules depending on the following rules are not independent, because the following rules may write
+	// their output while the query of the dependent rule is run concurrently.
+	assert.Equal(t, []int{0, 1, 4, 9, 10, 13}, independent)
+}
+
+func TestRuleConcurrencyController_EvalIterationFuncShouldWriteTheSameSeriesAsSequentialEvaluation(t *testing.T) {
+	const userID = "user-1"
+
+	var (
+		start    = time.Unix(0, 0)
+		interval = time.Minute
+	)
+
+	newGroupRules := func() []rules.Rule {
+		return []rules.Rule{
+			newTestRecordingRule(t, "job:later:sum", `sum by (job) (job:a:sum)`),
+			newTestRecordingRule(t, "job:a:sum", `sum by (job) (a)`),
+			newTestRecordingRuleWithLabels(t, "job:a:sum", `sum by (job) (b)`, labels.FromStrings("source", "b")),
+			newTestRecordingRule(t, "job:a:count", `count(job:a:sum)`),
+			newTestRecordingRuleWithLabels(t, "job:c:sum", `sum by (job) (c)`, labels.FromStrings("env", "prod")),
+			newTestRecordingRule(t, "job:c:dev", `sum by (job) (job:c:sum{env="dev"})`),
+			newTestRecordingRule(t, "job:c:prod", `sum by (job) (job:c:sum{env="prod"})`),
+			newTestAlertingRule(t, "HighCount", `job:a:count > 1`),
+			newTestRecordingRule(t, "alerts:count", `count(ALERTS)`),
+		}
+	}
+
+	// evaluate runs some iterations of the rule group with the input concurrency, and returns the written series.
+	evaluate := func(t *testing.T, concurrency int) map[string][]promql.FPoint {
+		storage := teststorage.New(t)
+		t.Cleanup(func() { require.NoError(t, storage.Close()) })
+
+		app := storage.Appender(context.Background())
+		for ts := start.Add(-5 * time.Minute); !ts.After(start.Add(10 * interval)); ts = ts.Add(15 * time.Second) {
+			for i, name := range []string{"a", "b", "c"} {
+				_, err := app.Append(0, labels.FromStrings(labels.MetricName, name, "job", "test"), ts.UnixMilli(), float64(ts.Unix()*int64(i+1)))
+				require.NoError(t, err)
+			}
+		}
+		require.NoError(t, app.Commit())
+
+		engine := promql.NewEngine(promql.EngineOpts{
+			MaxSamples:    1e6,
+			Timeout:       time.Minute,
+			LookbackDelta: 5 * time.Minute,
+		})
+
+		limits := validation.MockOverrides(func(defaults *validation.Limits, _ map[string]*validation.Limits) {
+			defaults.RulerMaxIndependentRuleEvaluationConcurrencyPerTenant = concurrency
+		})
+		queryFunc := rules.EngineQueryFunc(engine, storage)
+		controller := newRuleConcurrencyController(userID, limits, queryFunc, newRuleConcurrencyMetrics(nil))
+
+		group := rules.NewGroup(rules.GroupOptions{
+			Name:     "group",
+			File:     "file",
+			Interval: interval,
+			Rules:    newGroupRules(),
+			Opts: &rules.ManagerOptions{
+				Appendable: storage,
+				Queryable:  storage,
+				QueryFunc:  controller.QueryFunc(queryFunc),
+				NotifyFunc: func(context.Context, string, ...*rules.Alert) {},
+				Context:    context.Background(),
+				Logger:     log.NewNopLogger(),
+			},
+		})
+
+		for i := 0; i < 10; i++ {
+			controller.EvalIterationFunc(context.Background(), group, start.Add(time.Duration(i)*interval))
+		}
+		for _, rule := range group.Rules() {
+			require.Equal(t, rules.HealthGood, rule.Health(), rule.Name())
+		}
+
+		querier, err := storage.Querier(math.MinInt64, math.MaxInt64)
+		require.NoError(t, err)
+		t.Cleanup(func() { require.NoError(t, querier.Close()) })
+
+		written := map[string][]promql.FPoint{}
+		set := querier.Select(context.Background(), true, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, "job:.+|ALERTS.*|alerts:.+"))
+		for set.Next() {
+			it := set.At().Iterator(nil)
+			for it.Next() == chunkenc.ValFloat {
+				ts, v := it.At()
+				written[set.At().Labels().String()] = append(written[set.At().Labels().String()], promql.FPoint{T: ts, F: v})
+			}
+			require.NoError(t, it.Err())
+		}
+		require.NoError(t, set.Err())
+		return written
+	}
+
+	// With no concurrency, the rules are evaluated sequentially.
+	expected := evaluate(t, 0)
+	require.NotEmpty(t, expected)
+
+	for i := 0; i < 5; i++ {
+		assert.Equal(t, expected, evaluate(t, 10))
+	}
+}
+
+func TestRuleConcurrencyController_EvalIterationFunc(t *testing.T) {
+	const userID = "user-1"
+
+	groupRules := []rules.Rule{
+		newTestRecordingRule(t, "job:a:sum", `sum by (job) (a)`),
+		newTestRecordingRule(t, "job:b:sum", `sum by (job) (b)`),
+		newTestRecordingRule(t, "job:c:sum", `sum by (job) (c)`),
+		newTestRecordingRule(t, "job:ab:ratio", `job:a:sum / job:b:sum`),
+	}
+
+	tests := map[string]struct {
+		concurrency               int
+		expectedMaxInflight       int64
+		expectedAttemptsStarted   int
+		expectedAttemptsCompleted int
+	}{
+		"concurrency disabled": {
+			concurrency:         0,
+			expectedMaxInflight: 1,
+		},
+		"concurrency enough for all independent rules": {
+			concurrency:               3,
+			expectedMaxInflight:       3,
+			expectedAttemptsStarted:   3,
+			expectedAttemptsCompleted: 3,
+		},
+		"concurrency lower than the number of independent rules": {
+			concurrency:               1,
+			expectedMaxInflight:       1,
+			expectedAttemptsStarted:   3,
+			expectedAttemptsCompleted: 1,
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			limits := validation.MockOverrides(func(defaults *validation.Limits, _ map[string]*validation.Limits) {
+				defaults.RulerMaxIndependentRuleEvaluationConcurrencyPerTenant = testData.concurrency
+			})
+
+			var (
+				mtx      sync.Mutex
+				queries  []string
+				written  = map[string]bool{}
+				inflight atomic.Int64
+				maxSeen  atomic.Int64
+			)
+
+			queryFunc := func(_ context.Context, qs string, _ time.Time) (promql.Vector, error) {
+				curr := inflight.Inc()
+				defer inflight.Dec()
+				for {
+					prev := maxSeen.Load()
+					if curr <= prev || maxSeen.CompareAndSwap(prev, curr) {
+						break
+					}
+				}
+
+				// Give the other queries a chance to run concurrently.
+				time.Sleep(50 * time.Millisecond)
+
+				mtx.Lock()
+				defer mtx.Unlock()
+				queries = append(queries, qs)
+
+				// The dependent rule must be queried after the output of its dependencies has been written.
+				if qs == `job:a:sum / job:b:sum` {
+					assert.True(t, written["job:a:sum"])
+					assert.True(t, written["job:b:sum"])
+				}
+
+				return promql.Vector{{Metric: labels.FromStrings("job", "test"), F: 1}}, nil
+			}
+
+			pusher := newPusherMock()
+			pusher.On("Push", mock.Anything, mock.Anything).Run(func(args mock.Arguments) {
+				req := args.Get(1).(*mimirpb.WriteRequest)
+				mtx.Lock()
+				defer mtx.Unlock()
+				for _, series := range req.Timeseries {
+					written[mimirpb.FromLabelAdaptersToLabels(series.Labels).Get(labels.MetricName)] = true
+				}
+			}).Return(&mimirpb.WriteResponse{}, nil)
+
+			reg := prometheus.NewPedanticRegistry()
+			controller := newRuleConcurrencyController(userID, limits, queryFunc, newRuleConcurrencyMetrics(reg))
+
+			group := rules.NewGroup(rules.GroupOptions{
+				Name:              "group",
+				File:              "file",
+				Interval:          time.Minute,
+				Rules:             groupRules,
+				EvalIterationFunc: controller.EvalIterationFunc,
+				Opts: &rules.ManagerOptions{
+					Appendable: NewPusherAppendable(pusher, userID, prometheus.NewCounter(prometheus.CounterOpts{}), prometheus.NewCounter(prometheus.CounterOpts{})),
+					QueryFunc:  controller.QueryFunc(queryFunc),
+					Context:    context.Background(),
+					Logger:     log.NewNopLogger(),
+				},
+			})
+
+			controller.EvalIterationFunc(context.Background(), group, time.Now())
+
+			// Each rule has been queried exactly once, and evaluated successfully.
+			assert.ElementsMatch(t, []string{`sum by (job) (a)`, `sum by (job) (b)`, `sum by (job) (c)`, `job:a:sum / job:b:sum`}, queries)
+			for _, rule := range groupRules {
+				assert.Equal(t, rules.HealthGood, rule.Health(), rule.Name())
+			}
+			assert.Equal(t, testData.expectedMaxInflight, maxSeen.Load())
+
+			assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+				# HELP cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total Total number of independent rules evaluated concurrently with the other rules of their rule group.
+				# TYPE cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total counter
+				cortex_ruler_independent_rule_evaluation_concurrency_attempts_completed_total{user="user-1"} `+strconv.Itoa(testData.expectedAttemptsCompleted)+`
+				# HELP cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total Total number of independent rules evaluated sequentially because no concurrency slot was available.
+				# TYPE cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total counter
+				cortex_ruler_independent_rule_evaluation_concurrency_attempts_incomplete_total{user="user-1"} `+strconv.Itoa(testData.expectedAttemptsStarted-testData.expectedAttemptsCompleted)+`
+				# HELP cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total Total number of attempts to evaluate an independent rule concurrently with the other rules of its rule group.
+				# TYPE cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total counter
+				cortex_ruler_independent_rule_evaluation_concurrency_attempts_started_total{user="user-1"} `+strconv.Itoa(testData.expectedAttemptsStarted)+`
+				# HELP cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use Current number of concurrency slots used to evaluate independent rules concurrently.
+				# TYPE cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use gauge
+				cortex_ruler_independent_rule_evaluation_concurrency_slots_in_use{user="user-1"} 0
+			`)))
+		})
+	}
+}
+
+func TestPrefetchedQueries(t *testing.T) {
+	ts := time.Now()
+	queries := &prefetchedQueries{}
+
+	first := queries.add("up", ts)
+	second := queries.add("up", ts)
+
+	assert.Nil(t, queries.take("up", ts.Add(time.Second)))
+	assert.Nil(t, queries.take("down", ts))
+
+	// The same query is returned once for each time it has been added.
+	assert.Same(t, first, queries.take("up", ts))
+	assert.Same(t, second, queries.take("up", ts))
+	assert.Nil(t, queries.take("up", ts))
+}
+
+func newTestRecordingRule(t *testing.T, name, expr string) rules.Rule {
+	return newTestRecordingRuleWithLabels(t, name, expr, labels.EmptyLabels())
+}
+
+func newTestRecordingRuleWithLabels(t *testing.T, name, expr string, lbls labels.Labels) rules.Rule {
+	parsed, err := parser.ParseExpr(expr)
+	require.NoError(t, err)
+	return rules.NewRecordingRule(name, parsed, lbls)
+}
+
+func newTestAlertingRule(t *testing.T, name, expr string) rules.Rule {
+	parsed, err := parser.ParseExpr(expr)
+	require.NoError(t, err)
+	return rules.NewAlertingRule(name, parsed, 0, 0, labels.EmptyLabels(), labels.EmptyLabels(), labels.EmptyLabels(), "", true, log.NewNopLogger())
+}
@@ -0,0 +1,166 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+)
+
+// ruleQueryStatsKey identifies a rule of a tenant. Rules are identified by their content, because the
+// rules manager doesn't expose the position of the evaluated rule in its group.
+type ruleQueryStatsKey struct {
+	groupFile string
+	groupName string
+	kind      string
+	name      string
+	query     string
+	labels    string
+}
+
+func newRuleQueryStatsKey(groupFile, groupName string, rule rules.RuleDetail) ruleQueryStatsKey {
+	return ruleQueryStatsKey{
+		groupFile: groupFile,
+		groupName: groupName,
+		kind:      rule.Kind,
+		name:      rule.Name,
+		query:     rule.Query,
+		labels:    rule.Labels.String(),
+	}
+}
+
+// ruleEvaluationQueryStats holds the statistics of the queries run by a rule evaluation.
+type ruleEvaluationQueryStats struct {
+	evalTime time.Time
+	stats    RuleQueryStats
+}
+
+// ruleQueryStatsTracker keeps track of the statistics of the queries run by the last evaluation of each rule
+// of a tenant, and of the total amount of data fetched by the tenant's rules.
+type ruleQueryStatsTracker struct {
+	fetchedSeries     prometheus.Counter
+	fetchedChunks     prometheus.Counter
+	fetchedChunkBytes prometheus.Counter
+
+	mtx   sync.Mutex
+	rules map[ruleQueryStatsKey]ruleEvaluationQueryStats
+}
+
+func newRuleQueryStatsTracker(fetchedSeries, fetchedChunks, fetchedChunkBytes prometheus.Counter) *ruleQueryStatsTracker {
+	return &ruleQueryStatsTracker{
+		fetchedSeries:     fetchedSeries,
+		fetchedChunks:     fetchedChunks,
+		fetchedChunkBytes: fetchedChunkBytes,
+		rules:             map[ruleQueryStatsKey]ruleEvaluationQueryStats{},
+	}
+}
+
+// record tracks the statistics of a query run at evalTime. The query is attributed to the rule
+// found in the context, if any.
+func (t *ruleQueryStatsTracker) record(ctx context.Context, evalTime time.Time, wallTime time.Duration, stats *querier_stats.Stats) {
+	queryStats := RuleQueryStats{
+		WallTime:           wallTime,
+		FetchedSeriesCount: stats.LoadFetchedSeries(),
+		FetchedChunksCount: stats.LoadFetchedChunks(),
+		FetchedChunkBytes:  stats.LoadFetchedChunkBytes(),
+	}
+
+	t.fetchedSeries.Add(float64(queryStats.FetchedSeriesCount))
+	t.fetchedChunks.Add(float64(queryStats.FetchedChunksCount))
+	t.fetchedChunkBytes.Add(float64(queryStats.FetchedChunkBytes))
+
+	groupFile, groupName, ok := ruleGroupFromContext(ctx)
+	rule := rules.FromOriginContext(ctx)
+	if !ok || rule.Kind == "" {
+		return
+	}
+	key := newRuleQueryStatsKey(groupFile, groupName, rule)
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	// Add up the queries run by the same evaluation of the rule.
+	if prev, ok := t.rules[key]; ok && prev.evalTime.Equal(evalTime) {
+		queryStats = prev.stats.add(queryStats)
+	}
+	t.rules[key] = ruleEvaluationQueryStats{evalTime: evalTime, stats: queryStats}
+}
+
+// ruleStats returns the statistics of the queries run by the last evaluation of the rule.
+func (t *ruleQueryStatsTracker) ruleStats(group *rules.Group, rule rules.Rule) RuleQueryStats {
+	key := newRuleQueryStatsKey(group.File(), group.Name(), rules.NewRuleDetail(rule))
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	return t.rules[key].stats
+}
+
+// retain removes the statistics of the rules not in the input groups.
+func (t *ruleQueryStatsTracker) retain(groups []*rules.Group) {
+	keys := map[ruleQueryStatsKey]struct{}{}
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			keys[newRuleQueryStatsKey(g.File(), g.Name(), rules.NewRuleDetail(r))] = struct{}{}
+		}
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for key := range t.rules {
+		if _, ok := keys[key]; !ok {
+			delete(t.rules, key)
+		}
+	}
+}
+
+func (s RuleQueryStats) add(other RuleQueryStats) RuleQueryStats {
+	return RuleQueryStats{
+		WallTime:           s.WallTime + other.WallTime,
+		FetchedSeriesCount: s.FetchedSeriesCount + other.FetchedSeriesCount,
+		FetchedChunksCount: s.FetchedChunksCount + other.FetchedChunksCount,
+		FetchedChunkBytes:  s.FetchedChunkBytes + other.FetchedChunkBytes,
+	}
+}
+
+// ruleGroupFromContext returns the file and name of the rule group being evaluated, which the rules
+// manager injects in the query origin.
+func ruleGroupFromContext(ctx context.Context) (file, name string, ok bool) {
+	origin, _ := ctx.Value(promql.QueryOrigin{}).(map[string]interface{})
+	group, ok := origin["ruleGroup"].(map[string]string)
+	if !ok {
+		return "", "", false
+	}
+	return group["file"], group["name"], true
+}
+
+// recordRuleQueryStats wraps the input rules.QueryFunc to track the statistics of each query in the tracker.
+// The statistics are only collected when the context holds a querier stats object, which is injected by
+// RecordAndReportRuleQueryMetrics.
+func recordRuleQueryStats(qf rules.QueryFunc, tracker *ruleQueryStatsTracker) rules.QueryFunc {
+	if tracker == nil {
+		return qf
+	}
+
+	return func(ctx context.Context, qs string, t time.Time) (promql.Vector, error) {
+		stats := querier_stats.FromContext(ctx)
+		if stats == nil {
+			return qf(ctx, qs, t)
+		}
+
+		start := time.Now()
+		defer func() {
+			tracker.record(ctx, t, time.Since(start), stats)
+		}()
+
+		return qf(ctx, qs, t)
+	}
+}
@@ -0,0 +1,144 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruler
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/grafana/dskit/test"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/notifier"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	querier_stats "github.com/grafana/mimir/pkg/querier/stats"
+	"github.com/grafana/mimir/pkg/ruler/rulespb"
+)
+
+func TestRuleQueryStatsTracker(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+	fetchedSeries := prometheus.NewCounter(prometheus.CounterOpts{Name: "fetched_series"})
+	fetchedChunks := prometheus.NewCounter(prometheus.CounterOpts{Name: "fetched_chunks"})
+	fetchedChunkBytes := prometheus.NewCounter(prometheus.CounterOpts{Name: "fetched_chunk_bytes"})
+	reg.MustRegister(fetchedSeries, fetchedChunks, fetchedChunkBytes)
+
+	tracker := newRuleQueryStatsTracker(fetchedSeries, fetchedChunks, fetchedChunkBytes)
+
+	first := rules.RuleDetail{Name: "first", Query: "up", Kind: rules.KindRecording}
+	second := rules.RuleDetail{Name: "second", Query: "up == 0", Kind: rules.KindAlerting, Labels: labels.FromStrings("severity", "page")}
+
+	groupCtx := promql.NewOriginContext(context.Background(), map[string]interface{}{
+		"ruleGroup": map[string]string{"file": "file", "name": "group"},
+	})
+
+	record := func(ctx context.Context, evalTime time.Time, series uint64) {
+		stats := &querier_stats.Stats{}
+		stats.AddFetchedSeries(series)
+		stats.AddFetchedChunks(2 * series)
+		stats.AddFetchedChunkBytes(100 * series)
+		tracker.record(ctx, evalTime, time.Second, stats)
+	}
+
+	now := time.Now()
+	record(rules.NewOriginContext(groupCtx, first), now, 1)
+	record(rules.NewOriginContext(groupCtx, second), now, 2)
+	// Queries run by the same evaluation are added up.
+	record(rules.NewOriginContext(groupCtx, second), now, 3)
+	// Queries not run by a rule are only tracked in the metrics.
+	record(groupCtx, now, 4)
+	record(rules.NewOriginContext(context.Background(), first), now, 5)
+
+	get := func(rule rules.RuleDetail) RuleQueryStats {
+		tracker.mtx.Lock()
+		defer tracker.mtx.Unlock()
+		return tracker.rules[newRuleQueryStatsKey("file", "group", rule)].stats
+	}
+
+	assert.Equal(t, RuleQueryStats{WallTime: time.Second, FetchedSeriesCount: 1, FetchedChunksCount: 2, FetchedChunkBytes: 100}, get(first))
+	assert.Equal(t, RuleQueryStats{WallTime: 2 * time.Second, FetchedSeriesCount: 5, FetchedChunksCount: 10, FetchedChunkBytes: 500}, get(second))
+
+	// The statistics of a new evaluation replace the previous ones.
+	record(rules.NewOriginContext(groupCtx, second), now.Add(time.Minute), 6)
+	assert.Equal(t, RuleQueryStats{WallTime: time.Second, FetchedSeriesCount: 6, FetchedChunksCount: 12, FetchedChunkBytes: 600}, get(second))
+
+	require.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP fetched_chunk_bytes
+		# TYPE fetched_chunk_bytes counter
+		fetched_chunk_bytes 2100
+		# HELP fetched_chunks
+		# TYPE fetched_chunks counter
+		fetched_chunks 42
+		# HELP fetched_series
+		# TYPE fetched_series counter
+		fetched_series 21
+	`)))
+
+	// The statistics of the rules no longer evaluated are removed.
+	tracker.retain(nil)
+	assert.Empty(t, tracker.rules)
+}
+
+func TestDefaultTenantManagerFactory_RuleQueryStats(t *testing.T) {
+	const userID = "tenant-1"
+
+	cfg := defaultRulerConfig(t)
+	cfg.EnableQueryStats = true
+	options := applyPrepareOptions(t, cfg.Ring.Common.InstanceID)
+	notifierManager := notifier.NewManager(&notifier.Options{Do: func(_ context.Context, _ *http.Client, _ *http.Request) (*http.Response, error) { return nil, nil }}, options.logger)
+	ruleFiles := writeRuleGroupToFiles(t, cfg.RulePath, options.logger, userID, rulespb.RuleGroupDesc{
+		Name: "group",
+		Rules: []*rulespb.RuleDesc{
+			createRecordingRule("job:up:sum", "sum by (job) (up)"),
+			createAlertingRule("UpIsDown", "up == 0"),
+		},
+	})
+
+	// Each query fetches as many series as the length of the query.
+	queryFunc := func(ctx context.Context, qs string, _ time.Time) (promql.Vector, error) {
+		querier_stats.FromContext(ctx).AddFetchedSeries(uint64(len(qs)))
+		return promql.Vector{}, nil
+	}
+
+	pusher := newPusherMock()
+	pusher.MockPush(&mimirpb.WriteResponse{}, nil)
+	reg := prometheus.NewPedanticRegistry()
+	managerFactory := DefaultTenantManagerFactory(cfg, pusher, newMockQueryable(), queryFunc, options.limits, reg)
+	manager := managerFactory(context.Background(), userID, notifierManager, options.logger, nil)
+
+	require.NoError(t, manager.Update(time.Millisecond, ruleFiles, labels.EmptyLabels(), "", nil))
+	go manager.Run()
+	defer manager.Stop()
+
+	provider, ok := manager.(ruleQueryStatsProvider)
+	require.True(t, ok)
+
+	test.Poll(t, 5*time.Second, []uint64{uint64(len("sum by (job) (up)")), uint64(len("up == 0"))}, func() interface{} {
+		var series []uint64
+		for _, g := range manager.RuleGroups() {
+			for _, r := range g.Rules() {
+				series = append(series, provider.RuleQueryStats(g, r).FetchedSeriesCount)
+			}
+		}
+		return series
+	})
+
+	families, err := reg.Gather()
+	require.NoError(t, err)
+	var fetchedSeries float64
+	for _, f := range families {
+		if f.GetName() == "cortex_ruler_query_fetched_series_total" {
+			require.Len(t, f.GetMetric(), 1)
+			fetchedSeries = f.GetMetric()[0].GetCounter().GetValue()
+		}
+	}
+	assert.Greater(t, fetchedSeries, float64(0))
+}
@@ -184,7 +184,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.Var(&cfg.EnabledTenants, "ruler.enabled-tenants", "Comma separated list of tenants whose rules this ruler can evaluate. If specified, only these tenants will be handled by ruler, otherwise this ruler can process rules from all tenants. Subject to sharding.")
 	f.Var(&cfg.DisabledTenants, "ruler.disabled-tenants", "Comma separated list of tenants whose rules this ruler cannot evaluate. If specified, a ruler that would normally pick the specified tenant(s) for processing will ignore them instead. Subject to sharding.")
 
-	f.BoolVar(&cfg.EnableQueryStats, "ruler.query-stats-enabled", false, "Report the wall time for ruler queries to complete as a per-tenant metric and as an info level log message.")
+	f.BoolVar(&cfg.EnableQueryStats, "ruler.query-stats-enabled", false, "Report the wall time and the amount of data fetched by ruler queries as per-tenant metrics and as an info level log message, and expose the query statistics of each rule and rule group in the rules API.")
 
 	cfg.RingCheckPeriod = 5 * time.Second
 }
@@ -259,6 +259,10 @@ type MultiTenantManager interface {
 	// GetRules fetches rules for a particular tenant (userID).
 	GetRules(userID string) []*promRules.Group
 
+	// GetRuleQueryStats returns the statistics of the queries run by the last evaluation of a tenant's rule.
+	// The statistics are empty if they're not tracked.
+	GetRuleQueryStats(userID string, group *promRules.Group, rule promRules.Rule) RuleQueryStats
+
 	// Stop stops all Manager components.
 	Stop()
 
@@ -1079,6 +1083,14 @@ func (r *Ruler) getLocalRules(ctx context.Context, userID string, req RulesReque
 			EvaluationTimestamp: group.GetLastEvaluation(),
 			EvaluationDuration:  group.GetEvaluationTime(),
 		}
+
+		// The statistics of the group include all its rules, regardless of the filters.
+		ruleQueryStats := make(map[promRules.Rule]RuleQueryStats, len(group.Rules()))
+		for _, rule := range group.Rules() {
+			ruleQueryStats[rule] = r.manager.GetRuleQueryStats(userID, group, rule)
+			groupDesc.QueryStats = groupDesc.QueryStats.add(ruleQueryStats[rule])
+		}
+
 		for _, r := range group.Rules() {
 			if ruleSet.IsFiltered(r.Name()) {
 				continue
@@ -1126,6 +1138,7 @@ func (r *Ruler) getLocalRules(ctx context.Context, userID string, req RulesReque
 					Alerts:              alerts,
 					EvaluationTimestamp: rule.GetEvaluationTimestamp(),
 					EvaluationDuration:  rule.GetEvaluationDuration(),
+					QueryStats:          ruleQueryStats[r],
 				}
 			case *promRules.RecordingRule:
 				if !getRecordingRules {
@@ -1141,6 +1154,7 @@ func (r *Ruler) getLocalRules(ctx context.Context, userID string, req RulesReque
 					LastError:           lastError,
 					EvaluationTimestamp: rule.GetEvaluationTimestamp(),
 					EvaluationDuration:  rule.GetEvaluationDuration(),
+					QueryStats:          ruleQueryStats[r],
 				}
 			default:
 				return nil, errors.Errorf("failed to assert type of rule '%v'", rule.Name())
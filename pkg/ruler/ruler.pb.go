@@ -260,6 +260,7 @@ type GroupStateDesc struct {
 	ActiveRules         []*RuleStateDesc       `protobuf:"bytes,2,rep,name=active_rules,json=activeRules,proto3" json:"active_rules,omitempty"`
 	EvaluationTimestamp time.Time              `protobuf:"bytes,3,opt,name=evaluationTimestamp,proto3,stdtime" json:"evaluationTimestamp"`
 	EvaluationDuration  time.Duration          `protobuf:"bytes,4,opt,name=evaluationDuration,proto3,stdduration" json:"evaluationDuration"`
+	QueryStats          RuleQueryStats         `protobuf:"bytes,5,opt,name=queryStats,proto3" json:"queryStats"`
 }
 
 func (m *GroupStateDesc) Reset()      { *m = GroupStateDesc{} }
@@ -322,6 +323,13 @@ func (m *GroupStateDesc) GetEvaluationDuration() time.Duration {
 	return 0
 }
 
+func (m *GroupStateDesc) GetQueryStats() RuleQueryStats {
+	if m != nil {
+		return m.QueryStats
+	}
+	return RuleQueryStats{}
+}
+
 // RuleStateDesc is a proto representation of a Prometheus Rule
 type RuleStateDesc struct {
 	Rule                *rulespb.RuleDesc `protobuf:"bytes,1,opt,name=rule,proto3" json:"rule,omitempty"`
@@ -331,6 +339,7 @@ type RuleStateDesc struct {
 	Alerts              []*AlertStateDesc `protobuf:"bytes,5,rep,name=alerts,proto3" json:"alerts,omitempty"`
 	EvaluationTimestamp time.Time         `protobuf:"bytes,6,opt,name=evaluationTimestamp,proto3,stdtime" json:"evaluationTimestamp"`
 	EvaluationDuration  time.Duration     `protobuf:"bytes,7,opt,name=evaluationDuration,proto3,stdduration" json:"evaluationDuration"`
+	QueryStats          RuleQueryStats    `protobuf:"bytes,8,opt,name=queryStats,proto3" json:"queryStats"`
 }
 
 func (m *RuleStateDesc) Reset()      { *m = RuleStateDesc{} }
@@ -414,6 +423,13 @@ func (m *RuleStateDesc) GetEvaluationDuration() time.Duration {
 	return 0
 }
 
+func (m *RuleStateDesc) GetQueryStats() RuleQueryStats {
+	if m != nil {
+		return m.QueryStats
+	}
+	return RuleQueryStats{}
+}
+
 type AlertStateDesc struct {
 	State           string                                              `protobuf:"bytes,1,opt,name=state,proto3" json:"state,omitempty"`
 	Labels          []github_com_grafana_mimir_pkg_mimirpb.LabelAdapter `protobuf:"bytes,2,rep,name=labels,proto3,customtype=github.com/grafana/mimir/pkg/mimirpb.LabelAdapter" json:"labels"`
@@ -515,6 +531,74 @@ func (m *AlertStateDesc) GetKeepFiringSince() time.Time {
 	return time.Time{}
 }
 
+// RuleQueryStats holds the statistics of the queries run to evaluate a rule or a rule group
+type RuleQueryStats struct {
+	WallTime           time.Duration `protobuf:"bytes,1,opt,name=wallTime,proto3,stdduration" json:"wallTime"`
+	FetchedSeriesCount uint64        `protobuf:"varint,2,opt,name=fetchedSeriesCount,proto3" json:"fetchedSeriesCount,omitempty"`
+	FetchedChunksCount uint64        `protobuf:"varint,3,opt,name=fetchedChunksCount,proto3" json:"fetchedChunksCount,omitempty"`
+	FetchedChunkBytes  uint64        `protobuf:"varint,4,opt,name=fetchedChunkBytes,proto3" json:"fetchedChunkBytes,omitempty"`
+}
+
+func (m *RuleQueryStats) Reset()      { *m = RuleQueryStats{} }
+func (*RuleQueryStats) ProtoMessage() {}
+func (*RuleQueryStats) Descriptor() ([]byte, []int) {
+	return fileDescriptor_9ecbec0a4cfddea6, []int{7}
+}
+func (m *RuleQueryStats) XXX_Unmarshal(b []byte) error {
+	return m.Unmarshal(b)
+}
+func (m *RuleQueryStats) XXX_Marshal(b []byte, deterministic bool) ([]byte, error) {
+	if deterministic {
+		return xxx_messageInfo_RuleQueryStats.Marshal(b, m, deterministic)
+	} else {
+		b = b[:cap(b)]
+		n, err := m.MarshalToSizedBuffer(b)
+		if err != nil {
+			return nil, err
+		}
+		return b[:n], nil
+	}
+}
+func (m *RuleQueryStats) XXX_Merge(src proto.Message) {
+	xxx_messageInfo_RuleQueryStats.Merge(m, src)
+}
+func (m *RuleQueryStats) XXX_Size() int {
+	return m.Size()
+}
+func (m *RuleQueryStats) XXX_DiscardUnknown() {
+	xxx_messageInfo_RuleQueryStats.DiscardUnknown(m)
+}
+
+var xxx_messageInfo_RuleQueryStats proto.InternalMessageInfo
+
+func (m *RuleQueryStats) GetWallTime() time.Duration {
+	if m != nil {
+		return m.WallTime
+	}
+	return 0
+}
+
+func (m *RuleQueryStats) GetFetchedSeriesCount() uint64 {
+	if m != nil {
+		return m.FetchedSeriesCount
+	}
+	return 0
+}
+
+func (m *RuleQueryStats) GetFetchedChunksCount() uint64 {
+	if m != nil {
+		return m.FetchedChunksCount
+	}
+	return 0
+}
+
+func (m *RuleQueryStats) GetFetchedChunkBytes() uint64 {
+	if m != nil {
+		return m.FetchedChunkBytes
+	}
+	return 0
+}
+
 func init() {
 	proto.RegisterEnum("ruler.RulesRequest_RuleType", RulesRequest_RuleType_name, RulesRequest_RuleType_value)
 	proto.RegisterType((*RulesRequest)(nil), "ruler.RulesRequest")
@@ -524,67 +608,74 @@ func init() {
 	proto.RegisterType((*GroupStateDesc)(nil), "ruler.GroupStateDesc")
 	proto.RegisterType((*RuleStateDesc)(nil), "ruler.RuleStateDesc")
 	proto.RegisterType((*AlertStateDesc)(nil), "ruler.AlertStateDesc")
+	proto.RegisterType((*RuleQueryStats)(nil), "ruler.RuleQueryStats")
 }
 
 func init() { proto.RegisterFile("ruler.proto", fileDescriptor_9ecbec0a4cfddea6) }
 
 var fileDescriptor_9ecbec0a4cfddea6 = []byte{
-	// 871 bytes of a gzipped FileDescriptorProto
-	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x55, 0xcf, 0x6f, 0x1b, 0x45,
-	0x14, 0xde, 0x71, 0xe2, 0x1f, 0xfb, 0x9c, 0xa6, 0xc9, 0x24, 0xc0, 0xd6, 0x94, 0x4d, 0x64, 0x2e,
-	0x11, 0x52, 0xd6, 0x60, 0x22, 0x10, 0x12, 0x02, 0x1c, 0xb5, 0x45, 0x48, 0x08, 0x55, 0xeb, 0xc2,
-	0xd5, 0x1a, 0xdb, 0xe3, 0xcd, 0xa8, 0xeb, 0xdd, 0x65, 0x66, 0xd6, 0x22, 0x27, 0xf8, 0x13, 0x7a,
-	0xe4, 0xcc, 0x89, 0xbf, 0x83, 0x53, 0x8f, 0x11, 0xa7, 0x0a, 0xa1, 0x42, 0x9c, 0x0b, 0xc7, 0xfe,
-	0x09, 0x68, 0xde, 0xec, 0xd6, 0x76, 0x1b, 0x10, 0x16, 0x70, 0x89, 0xe7, 0xcd, 0xfb, 0xbe, 0xef,
-	0xcd, 0xfb, 0xe6, 0xed, 0x04, 0x9a, 0x32, 0x8f, 0xb9, 0x0c, 0x32, 0x99, 0xea, 0x94, 0x56, 0x31,
-	0x68, 0x1d, 0x47, 0x42, 0x9f, 0xe5, 0xc3, 0x60, 0x94, 0x4e, 0x3b, 0x51, 0x1a, 0xa5, 0x1d, 0xcc,
-	0x0e, 0xf3, 0x09, 0x46, 0x18, 0xe0, 0xca, 0xb2, 0x5a, 0x7e, 0x94, 0xa6, 0x51, 0xcc, 0x17, 0xa8,
-	0x71, 0x2e, 0x99, 0x16, 0x69, 0x52, 0xe4, 0x0f, 0x5e, 0xcc, 0x6b, 0x31, 0xe5, 0x4a, 0xb3, 0x69,
-	0x56, 0x00, 0xde, 0x5e, 0xae, 0x27, 0xd9, 0x84, 0x25, 0xac, 0x33, 0x15, 0x53, 0x21, 0x3b, 0xd9,
-	0xc3, 0xc8, 0xae, 0xb2, 0xa1, 0xfd, 0x2d, 0x18, 0xef, 0xfd, 0x2d, 0x03, 0xbb, 0xc0, 0xbf, 0x2a,
-	0x1b, 0xda, 0x5f, 0xcb, 0x6b, 0xff, 0x4c, 0x60, 0x2b, 0x34, 0x71, 0xc8, 0xbf, 0xce, 0xb9, 0xd2,
-	0xf4, 0x04, 0x6a, 0x13, 0x11, 0x6b, 0x2e, 0x3d, 0x72, 0x48, 0x8e, 0xb6, 0xbb, 0xb7, 0x03, 0xeb,
-	0xc7, 0x32, 0x08, 0x83, 0x07, 0xe7, 0x19, 0x0f, 0x0b, 0x2c, 0x7d, 0x1d, 0x5c, 0x03, 0x1b, 0x24,
-	0x6c, 0xca, 0xbd, 0xca, 0xe1, 0xc6, 0x91, 0x1b, 0x36, 0xcc, 0xc6, 0x17, 0x6c, 0xca, 0xe9, 0x1b,
-	0x00, 0x98, 0x8c, 0x64, 0x9a, 0x67, 0xde, 0x06, 0x66, 0x11, 0xfe, 0xa9, 0xd9, 0xa0, 0x14, 0x36,
-	0x27, 0x22, 0xe6, 0xde, 0x26, 0x26, 0x70, 0xdd, 0xfe, 0x10, 0x1a, 0x65, 0x0d, 0xda, 0x84, 0x7a,
-	0x2f, 0x39, 0x37, 0xe1, 0x8e, 0x43, 0x77, 0x60, 0xab, 0x17, 0x73, 0xa9, 0x45, 0x12, 0xe1, 0x0e,
-	0xa1, 0xbb, 0x70, 0x23, 0xe4, 0xa3, 0x54, 0x8e, 0xcb, 0xad, 0x4a, 0xfb, 0x23, 0xb8, 0x51, 0x1c,
-	0x57, 0x65, 0x69, 0xa2, 0x38, 0x3d, 0x86, 0x1a, 0x16, 0x57, 0x1e, 0x39, 0xdc, 0x38, 0x6a, 0x76,
-	0x5f, 0x29, 0x9a, 0xc2, 0x03, 0xf4, 0x35, 0xd3, 0xfc, 0x0e, 0x57, 0xa3, 0xb0, 0x00, 0xb5, 0x8f,
-	0x61, 0xa7, 0x7f, 0x9e, 0x8c, 0x56, 0x7c, 0xb9, 0x05, 0x8d, 0x5c, 0x71, 0x39, 0x10, 0x63, 0x2b,
-	0xe2, 0x86, 0x75, 0x13, 0x7f, 0x36, 0x56, 0xed, 0x3d, 0xd8, 0x5d, 0x82, 0xdb, 0x92, 0xed, 0x1f,
-	0x2a, 0xb0, 0xbd, 0x2a, 0x4f, 0xdf, 0x82, 0xaa, 0xb5, 0xc0, 0x38, 0xdb, 0xec, 0xee, 0x07, 0xf6,
-	0x22, 0xc2, 0xd2, 0x09, 0x3c, 0x83, 0x85, 0xd0, 0xf7, 0x61, 0x8b, 0x8d, 0xb4, 0x98, 0xf1, 0x01,
-	0x82, 0xd0, 0xd3, 0x92, 0x62, 0x2f, 0x63, 0x71, 0xec, 0xa6, 0x45, 0x62, 0x7d, 0xfa, 0x15, 0xec,
-	0xf1, 0x19, 0x8b, 0x73, 0x9c, 0xb7, 0x07, 0xe5, 0x5c, 0x79, 0x1b, 0x58, 0xb2, 0x15, 0xd8, 0xc9,
-	0x0b, 0xca, 0xc9, 0x0b, 0x9e, 0x23, 0x4e, 0x1b, 0x8f, 0x9f, 0x1e, 0x38, 0x8f, 0x7e, 0x3b, 0x20,
-	0xe1, 0x75, 0x02, 0xb4, 0x0f, 0x74, 0xb1, 0x7d, 0xa7, 0x98, 0x67, 0x6f, 0x13, 0x65, 0x6f, 0xbd,
-	0x24, 0x5b, 0x02, 0xac, 0xea, 0xf7, 0x46, 0xf5, 0x1a, 0x7a, 0xfb, 0xd7, 0x8a, 0xbd, 0xa9, 0x85,
-	0x47, 0x6f, 0xc2, 0xa6, 0x69, 0xb1, 0xb0, 0xe8, 0xe6, 0x92, 0x45, 0xd8, 0x2a, 0x26, 0xe9, 0x3e,
-	0x54, 0x95, 0x61, 0x78, 0x95, 0x43, 0x72, 0xe4, 0x86, 0x36, 0xa0, 0xaf, 0x42, 0xed, 0x8c, 0xb3,
-	0x58, 0x9f, 0x61, 0xb3, 0x6e, 0x58, 0x44, 0xf4, 0x36, 0xb8, 0x31, 0x53, 0xfa, 0xae, 0x94, 0xa9,
-	0xc4, 0x03, 0xbb, 0xe1, 0x62, 0xc3, 0x8c, 0x06, 0x33, 0x03, 0xa5, 0xbc, 0xea, 0xca, 0x68, 0xe0,
-	0x94, 0x2d, 0x8d, 0x86, 0x05, 0xfd, 0x95, 0xbd, 0xb5, 0xff, 0xc7, 0xde, 0xfa, 0xbf, 0xb3, 0xf7,
-	0xa7, 0x2a, 0x6c, 0xaf, 0xf6, 0xb1, 0xb0, 0x8e, 0x2c, 0x5b, 0x37, 0x81, 0x5a, 0xcc, 0x86, 0x3c,
-	0x2e, 0xe7, 0x6c, 0x2f, 0x18, 0xa5, 0x52, 0xf3, 0x6f, 0xb2, 0x61, 0xf0, 0xb9, 0xd9, 0xbf, 0xcf,
-	0x84, 0x3c, 0xfd, 0xc0, 0xd4, 0xfa, 0xe5, 0xe9, 0xc1, 0x3b, 0xff, 0xe4, 0x71, 0xb2, 0xbc, 0xde,
-	0x98, 0x65, 0x9a, 0xcb, 0xb0, 0x50, 0xa7, 0x19, 0x34, 0x59, 0x92, 0xa4, 0x1a, 0x8f, 0xa7, 0xf0,
-	0x29, 0xf8, 0xef, 0x8b, 0x2d, 0x97, 0x30, 0xfd, 0x1a, 0x5f, 0x38, 0x5e, 0x3c, 0x09, 0x6d, 0x40,
-	0x7b, 0xe0, 0x16, 0x5f, 0x17, 0xd3, 0x5e, 0x75, 0x8d, 0xbb, 0x6b, 0x58, 0x5a, 0x4f, 0xd3, 0x8f,
-	0xa1, 0x31, 0x11, 0x92, 0x8f, 0x8d, 0xc2, 0x3a, 0xb7, 0x5f, 0x47, 0x56, 0x4f, 0xd3, 0xbb, 0xd0,
-	0x94, 0x5c, 0xa5, 0xf1, 0xcc, 0x6a, 0xd4, 0xd7, 0xd0, 0x80, 0x92, 0xd8, 0xd3, 0xf4, 0x1e, 0x6c,
-	0x99, 0x61, 0x1e, 0x28, 0x9e, 0x68, 0xa3, 0xd3, 0x58, 0x47, 0xc7, 0x30, 0xfb, 0x3c, 0xd1, 0xf6,
-	0x38, 0x33, 0x16, 0x8b, 0xf1, 0x20, 0x4f, 0xb4, 0x88, 0x3d, 0x77, 0x1d, 0x19, 0x24, 0x7e, 0x69,
-	0x78, 0xf4, 0x3e, 0xec, 0x3e, 0xe4, 0x3c, 0x1b, 0x4c, 0x84, 0x14, 0x49, 0x34, 0x50, 0x22, 0x19,
-	0x71, 0x0f, 0xd6, 0x10, 0xbb, 0x69, 0xe8, 0xf7, 0x90, 0xdd, 0x37, 0xe4, 0xee, 0xb7, 0x50, 0x35,
-	0x9f, 0xbf, 0xa4, 0x27, 0x76, 0xa1, 0xe8, 0xde, 0x35, 0xff, 0x92, 0x5a, 0xfb, 0xab, 0x9b, 0xc5,
-	0x2b, 0xec, 0xd0, 0x4f, 0xc0, 0x7d, 0xfe, 0x38, 0xd3, 0xd7, 0x0a, 0xd0, 0x8b, 0xaf, 0x7b, 0xcb,
-	0x7b, 0x39, 0x51, 0x2a, 0x9c, 0x9e, 0x5c, 0x5c, 0xfa, 0xce, 0x93, 0x4b, 0xdf, 0x79, 0x76, 0xe9,
-	0x93, 0xef, 0xe6, 0x3e, 0xf9, 0x71, 0xee, 0x93, 0xc7, 0x73, 0x9f, 0x5c, 0xcc, 0x7d, 0xf2, 0xfb,
-	0xdc, 0x27, 0x7f, 0xcc, 0x7d, 0xe7, 0xd9, 0xdc, 0x27, 0x8f, 0xae, 0x7c, 0xe7, 0xe2, 0xca, 0x77,
-	0x9e, 0x5c, 0xf9, 0xce, 0xb0, 0x86, 0x5d, 0xbe, 0xfb, 0x67, 0x00, 0x00, 0x00, 0xff, 0xff, 0x3c,
-	0xcd, 0xed, 0x61, 0x4f, 0x08, 0x00, 0x00,
+	// 970 bytes of a gzipped FileDescriptorProto
+	0x1f, 0x8b, 0x08, 0x00, 0x00, 0x00, 0x00, 0x00, 0x02, 0xff, 0xb4, 0x55, 0x4f, 0x6f, 0x1b, 0x45,
+	0x14, 0xf7, 0xc6, 0x7f, 0xf7, 0x39, 0x4d, 0x93, 0x49, 0x0a, 0x5b, 0x53, 0x36, 0x91, 0xb9, 0x44,
+	0x88, 0x6c, 0x20, 0x44, 0x20, 0x04, 0x02, 0xec, 0xfe, 0x41, 0x48, 0x08, 0x95, 0x75, 0xe1, 0x6a,
+	0x8d, 0xed, 0xb1, 0x3d, 0xca, 0x7a, 0x77, 0x3b, 0x33, 0x1b, 0xf0, 0x09, 0x3e, 0x42, 0x8f, 0xf0,
+	0x0d, 0xf8, 0x1c, 0x9c, 0x7a, 0x8c, 0x38, 0x55, 0x1c, 0x1a, 0xe2, 0x5c, 0x38, 0xf6, 0x23, 0xa0,
+	0x79, 0xb3, 0x1b, 0xaf, 0x9b, 0x80, 0x62, 0xa9, 0xbd, 0xd8, 0xfb, 0xde, 0xfb, 0xfd, 0x7e, 0x33,
+	0xf3, 0x7b, 0x6f, 0x77, 0xa0, 0x2e, 0x92, 0x80, 0x09, 0x2f, 0x16, 0x91, 0x8a, 0x48, 0x19, 0x83,
+	0xc6, 0xde, 0x88, 0xab, 0x71, 0xd2, 0xf3, 0xfa, 0xd1, 0x64, 0x7f, 0x14, 0x8d, 0xa2, 0x7d, 0xac,
+	0xf6, 0x92, 0x21, 0x46, 0x18, 0xe0, 0x93, 0x61, 0x35, 0xdc, 0x51, 0x14, 0x8d, 0x02, 0x36, 0x47,
+	0x0d, 0x12, 0x41, 0x15, 0x8f, 0xc2, 0xb4, 0xbe, 0xfd, 0x72, 0x5d, 0xf1, 0x09, 0x93, 0x8a, 0x4e,
+	0xe2, 0x14, 0xf0, 0x7e, 0x7e, 0x3d, 0x41, 0x87, 0x34, 0xa4, 0xfb, 0x13, 0x3e, 0xe1, 0x62, 0x3f,
+	0x3e, 0x1a, 0x99, 0xa7, 0xb8, 0x67, 0xfe, 0x53, 0xc6, 0x47, 0xff, 0xcb, 0xc0, 0x53, 0xe0, 0xaf,
+	0x8c, 0x7b, 0xe6, 0xdf, 0xf0, 0x9a, 0x7f, 0x5a, 0xb0, 0xea, 0xeb, 0xd8, 0x67, 0x8f, 0x13, 0x26,
+	0x15, 0x39, 0x84, 0xca, 0x90, 0x07, 0x8a, 0x09, 0xc7, 0xda, 0xb1, 0x76, 0xd7, 0x0e, 0xee, 0x78,
+	0xc6, 0x8f, 0x3c, 0x08, 0x83, 0x47, 0xd3, 0x98, 0xf9, 0x29, 0x96, 0xbc, 0x05, 0xb6, 0x86, 0x75,
+	0x43, 0x3a, 0x61, 0xce, 0xca, 0x4e, 0x71, 0xd7, 0xf6, 0x6b, 0x3a, 0xf1, 0x2d, 0x9d, 0x30, 0xf2,
+	0x36, 0x00, 0x16, 0x47, 0x22, 0x4a, 0x62, 0xa7, 0x88, 0x55, 0x84, 0x7f, 0xa5, 0x13, 0x84, 0x40,
+	0x69, 0xc8, 0x03, 0xe6, 0x94, 0xb0, 0x80, 0xcf, 0xcd, 0xcf, 0xa0, 0x96, 0xad, 0x41, 0xea, 0x50,
+	0x6d, 0x85, 0x53, 0x1d, 0xae, 0x17, 0xc8, 0x3a, 0xac, 0xb6, 0x02, 0x26, 0x14, 0x0f, 0x47, 0x98,
+	0xb1, 0xc8, 0x06, 0xdc, 0xf0, 0x59, 0x3f, 0x12, 0x83, 0x2c, 0xb5, 0xd2, 0xfc, 0x1c, 0x6e, 0xa4,
+	0xdb, 0x95, 0x71, 0x14, 0x4a, 0x46, 0xf6, 0xa0, 0x82, 0x8b, 0x4b, 0xc7, 0xda, 0x29, 0xee, 0xd6,
+	0x0f, 0x6e, 0xa5, 0x87, 0xc2, 0x0d, 0x74, 0x14, 0x55, 0xec, 0x1e, 0x93, 0x7d, 0x3f, 0x05, 0x35,
+	0xf7, 0x60, 0xbd, 0x33, 0x0d, 0xfb, 0x0b, 0xbe, 0xdc, 0x86, 0x5a, 0x22, 0x99, 0xe8, 0xf2, 0x81,
+	0x11, 0xb1, 0xfd, 0xaa, 0x8e, 0xbf, 0x1e, 0xc8, 0xe6, 0x26, 0x6c, 0xe4, 0xe0, 0x66, 0xc9, 0xe6,
+	0xe9, 0x0a, 0xac, 0x2d, 0xca, 0x93, 0x77, 0xa1, 0x6c, 0x2c, 0xd0, 0xce, 0xd6, 0x0f, 0xb6, 0x3c,
+	0xd3, 0x08, 0x3f, 0x73, 0x02, 0xf7, 0x60, 0x20, 0xe4, 0x63, 0x58, 0xa5, 0x7d, 0xc5, 0x8f, 0x59,
+	0x17, 0x41, 0xe8, 0x69, 0x46, 0x31, 0xcd, 0x98, 0x6f, 0xbb, 0x6e, 0x90, 0xb8, 0x3e, 0xf9, 0x01,
+	0x36, 0xd9, 0x31, 0x0d, 0x12, 0x9c, 0xb7, 0x47, 0xd9, 0x5c, 0x39, 0x45, 0x5c, 0xb2, 0xe1, 0x99,
+	0xc9, 0xf3, 0xb2, 0xc9, 0xf3, 0x2e, 0x10, 0xed, 0xda, 0xd3, 0xe7, 0xdb, 0x85, 0x27, 0xa7, 0xdb,
+	0x96, 0x7f, 0x95, 0x00, 0xe9, 0x00, 0x99, 0xa7, 0xef, 0xa5, 0xf3, 0xec, 0x94, 0x50, 0xf6, 0xf6,
+	0x25, 0xd9, 0x0c, 0x60, 0x54, 0x7f, 0xd5, 0xaa, 0x57, 0xd0, 0xc9, 0xa7, 0x00, 0x8f, 0x13, 0x26,
+	0xa6, 0xfa, 0x2c, 0xd2, 0x29, 0xa3, 0xd8, 0xad, 0xdc, 0x19, 0xbf, 0xbb, 0x28, 0xb6, 0x4b, 0x5a,
+	0xc8, 0xcf, 0xc1, 0x9b, 0xbf, 0x15, 0x4d, 0x9b, 0xe7, 0x06, 0xbf, 0x03, 0x25, 0xcd, 0x4d, 0xfd,
+	0xbd, 0x99, 0xf3, 0x17, 0x7d, 0xc2, 0x22, 0xd9, 0x82, 0xb2, 0xd4, 0x0c, 0x67, 0x65, 0xc7, 0xda,
+	0xb5, 0x7d, 0x13, 0x90, 0x37, 0xa0, 0x32, 0x66, 0x34, 0x50, 0x63, 0x74, 0xca, 0xf6, 0xd3, 0x88,
+	0xdc, 0x01, 0x3b, 0xa0, 0x52, 0xdd, 0x17, 0x22, 0x12, 0x78, 0x5a, 0xdb, 0x9f, 0x27, 0xf4, 0x5c,
+	0x51, 0x3d, 0x8d, 0x7a, 0xef, 0xf9, 0xb9, 0xc2, 0x11, 0xcd, 0xcd, 0x95, 0x01, 0xfd, 0x57, 0x6f,
+	0x2a, 0xaf, 0xa7, 0x37, 0xd5, 0x57, 0xd9, 0x9b, 0xda, 0x72, 0xbd, 0xf9, 0xa3, 0x0c, 0x6b, 0x8b,
+	0x26, 0xcc, 0x7d, 0xb7, 0xf2, 0xbe, 0x0f, 0xa1, 0x12, 0xd0, 0x1e, 0x0b, 0xb2, 0x09, 0xdf, 0xf4,
+	0xfa, 0x91, 0x50, 0xec, 0xa7, 0xb8, 0xe7, 0x7d, 0xa3, 0xf3, 0x0f, 0x29, 0x17, 0xed, 0x4f, 0xb4,
+	0xfe, 0x5f, 0xcf, 0xb7, 0x3f, 0xb8, 0xce, 0x67, 0xd1, 0xf0, 0x5a, 0x03, 0x1a, 0x2b, 0x26, 0xfc,
+	0x54, 0x9d, 0xc4, 0x50, 0xa7, 0x61, 0x18, 0x29, 0x3c, 0x9b, 0xc4, 0x8f, 0xd0, 0xab, 0x5f, 0x2c,
+	0xbf, 0x84, 0x3e, 0xaf, 0x36, 0x95, 0xe1, 0xd4, 0x58, 0xbe, 0x09, 0x48, 0x0b, 0xec, 0xf4, 0xbd,
+	0xa6, 0x2a, 0x1d, 0xf8, 0xeb, 0x35, 0xbe, 0x66, 0x68, 0x2d, 0x45, 0xbe, 0x80, 0xda, 0x90, 0x0b,
+	0x36, 0xd0, 0x0a, 0xcb, 0x8c, 0x4e, 0x15, 0x59, 0x2d, 0x45, 0xee, 0x43, 0x5d, 0x30, 0x19, 0x05,
+	0xc7, 0x46, 0xa3, 0xba, 0x84, 0x06, 0x64, 0xc4, 0x96, 0x22, 0x0f, 0x60, 0x55, 0xbf, 0x09, 0x5d,
+	0xc9, 0x42, 0xa5, 0x75, 0x6a, 0xcb, 0xe8, 0x68, 0x66, 0x87, 0x85, 0xca, 0x6c, 0xe7, 0x98, 0x06,
+	0x7c, 0xd0, 0x4d, 0x42, 0xc5, 0x03, 0xc7, 0x5e, 0x46, 0x06, 0x89, 0xdf, 0x6b, 0x1e, 0x79, 0x08,
+	0x1b, 0x47, 0x8c, 0xc5, 0xdd, 0x21, 0x17, 0x3c, 0x1c, 0x75, 0x25, 0x0f, 0xfb, 0xcc, 0x81, 0x25,
+	0xc4, 0x6e, 0x6a, 0xfa, 0x03, 0x64, 0x77, 0x34, 0xb9, 0x79, 0x6a, 0xc1, 0xda, 0xe2, 0xa4, 0x6b,
+	0xef, 0x7f, 0xa4, 0x41, 0xa0, 0xe9, 0xe9, 0x57, 0xe6, 0x5a, 0xef, 0xd7, 0x05, 0x89, 0x78, 0x40,
+	0x86, 0x4c, 0xf5, 0xc7, 0x6c, 0xd0, 0x61, 0x82, 0x33, 0x79, 0x37, 0x4a, 0x42, 0x85, 0x9f, 0xa2,
+	0x92, 0x7f, 0x45, 0x25, 0x87, 0xbf, 0x3b, 0x4e, 0xc2, 0xa3, 0x14, 0x5f, 0x5c, 0xc0, 0xe7, 0x2a,
+	0xe4, 0x3d, 0xd8, 0xc8, 0x67, 0xdb, 0x53, 0xc5, 0x24, 0x4e, 0x60, 0xc9, 0xbf, 0x5c, 0x38, 0xf8,
+	0x19, 0xca, 0xfa, 0x80, 0x82, 0x1c, 0x9a, 0x07, 0x49, 0x36, 0xaf, 0xb8, 0xee, 0x1b, 0x5b, 0x8b,
+	0xc9, 0xf4, 0x86, 0x2b, 0x90, 0x2f, 0xc1, 0xbe, 0xb8, 0xf8, 0xc8, 0x9b, 0x29, 0xe8, 0xe5, 0x9b,
+	0xb3, 0xe1, 0x5c, 0x2e, 0x64, 0x0a, 0xed, 0xc3, 0x93, 0x33, 0xb7, 0xf0, 0xec, 0xcc, 0x2d, 0xbc,
+	0x38, 0x73, 0xad, 0x5f, 0x66, 0xae, 0xf5, 0xfb, 0xcc, 0xb5, 0x9e, 0xce, 0x5c, 0xeb, 0x64, 0xe6,
+	0x5a, 0x7f, 0xcf, 0x5c, 0xeb, 0x9f, 0x99, 0x5b, 0x78, 0x31, 0x73, 0xad, 0x27, 0xe7, 0x6e, 0xe1,
+	0xe4, 0xdc, 0x2d, 0x3c, 0x3b, 0x77, 0x0b, 0xbd, 0x0a, 0x7a, 0xfd, 0xe1, 0xbf, 0x01, 0x00, 0x00,
+	0xff, 0xff, 0x57, 0x72, 0x29, 0xa7, 0xab, 0x09, 0x00, 0x00,
 }
 
 func (x RulesRequest_RuleType) String() string {
@@ -757,6 +848,9 @@ func (this *GroupStateDesc) Equal(that interface{}) bool {
 	if this.EvaluationDuration != that1.EvaluationDuration {
 		return false
 	}
+	if !this.QueryStats.Equal(&that1.QueryStats) {
+		return false
+	}
 	return true
 }
 func (this *RuleStateDesc) Equal(that interface{}) bool {
@@ -804,6 +898,9 @@ func (this *RuleStateDesc) Equal(that interface{}) bool {
 	if this.EvaluationDuration != that1.EvaluationDuration {
 		return false
 	}
+	if !this.QueryStats.Equal(&that1.QueryStats) {
+		return false
+	}
 	return true
 }
 func (this *AlertStateDesc) Equal(that interface{}) bool {
@@ -867,6 +964,39 @@ func (this *AlertStateDesc) Equal(that interface{}) bool {
 	}
 	return true
 }
+func (this *RuleQueryStats) Equal(that interface{}) bool {
+	if that == nil {
+		return this == nil
+	}
+
+	that1, ok := that.(*RuleQueryStats)
+	if !ok {
+		that2, ok := that.(RuleQueryStats)
+		if ok {
+			that1 = &that2
+		} else {
+			return false
+		}
+	}
+	if that1 == nil {
+		return this == nil
+	} else if this == nil {
+		return false
+	}
+	if this.WallTime != that1.WallTime {
+		return false
+	}
+	if this.FetchedSeriesCount != that1.FetchedSeriesCount {
+		return false
+	}
+	if this.FetchedChunksCount != that1.FetchedChunksCount {
+		return false
+	}
+	if this.FetchedChunkBytes != that1.FetchedChunkBytes {
+		return false
+	}
+	return true
+}
 func (this *RulesRequest) GoString() string {
 	if this == nil {
 		return "nil"
@@ -915,7 +1045,7 @@ func (this *GroupStateDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 8)
+	s := make([]string, 0, 9)
 	s = append(s, "&ruler.GroupStateDesc{")
 	if this.Group != nil {
 		s = append(s, "Group: "+fmt.Sprintf("%#v", this.Group)+",\n")
@@ -925,6 +1055,7 @@ func (this *GroupStateDesc) GoString() string {
 	}
 	s = append(s, "EvaluationTimestamp: "+fmt.Sprintf("%#v", this.EvaluationTimestamp)+",\n")
 	s = append(s, "EvaluationDuration: "+fmt.Sprintf("%#v", this.EvaluationDuration)+",\n")
+	s = append(s, "QueryStats: "+strings.Replace(this.QueryStats.GoString(), `&`, ``, 1)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -932,7 +1063,7 @@ func (this *RuleStateDesc) GoString() string {
 	if this == nil {
 		return "nil"
 	}
-	s := make([]string, 0, 11)
+	s := make([]string, 0, 12)
 	s = append(s, "&ruler.RuleStateDesc{")
 	if this.Rule != nil {
 		s = append(s, "Rule: "+fmt.Sprintf("%#v", this.Rule)+",\n")
@@ -945,6 +1076,7 @@ func (this *RuleStateDesc) GoString() string {
 	}
 	s = append(s, "EvaluationTimestamp: "+fmt.Sprintf("%#v", this.EvaluationTimestamp)+",\n")
 	s = append(s, "EvaluationDuration: "+fmt.Sprintf("%#v", this.EvaluationDuration)+",\n")
+	s = append(s, "QueryStats: "+strings.Replace(this.QueryStats.GoString(), `&`, ``, 1)+",\n")
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
@@ -967,6 +1099,19 @@ func (this *AlertStateDesc) GoString() string {
 	s = append(s, "}")
 	return strings.Join(s, "")
 }
+func (this *RuleQueryStats) GoString() string {
+	if this == nil {
+		return "nil"
+	}
+	s := make([]string, 0, 8)
+	s = append(s, "&ruler.RuleQueryStats{")
+	s = append(s, "WallTime: "+fmt.Sprintf("%#v", this.WallTime)+",\n")
+	s = append(s, "FetchedSeriesCount: "+fmt.Sprintf("%#v", this.FetchedSeriesCount)+",\n")
+	s = append(s, "FetchedChunksCount: "+fmt.Sprintf("%#v", this.FetchedChunksCount)+",\n")
+	s = append(s, "FetchedChunkBytes: "+fmt.Sprintf("%#v", this.FetchedChunkBytes)+",\n")
+	s = append(s, "}")
+	return strings.Join(s, "")
+}
 func valueToGoStringRuler(v interface{}, typ string) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -1263,21 +1408,31 @@ func (m *GroupStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
-	n1, err1 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
-	if err1 != nil {
-		return 0, err1
+	{
+		size, err := m.QueryStats.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintRuler(dAtA, i, uint64(size))
 	}
-	i -= n1
-	i = encodeVarintRuler(dAtA, i, uint64(n1))
 	i--
-	dAtA[i] = 0x22
-	n2, err2 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
+	dAtA[i] = 0x2a
+	n2, err2 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
 	if err2 != nil {
 		return 0, err2
 	}
 	i -= n2
 	i = encodeVarintRuler(dAtA, i, uint64(n2))
 	i--
+	dAtA[i] = 0x22
+	n3, err3 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
+	if err3 != nil {
+		return 0, err3
+	}
+	i -= n3
+	i = encodeVarintRuler(dAtA, i, uint64(n3))
+	i--
 	dAtA[i] = 0x1a
 	if len(m.ActiveRules) > 0 {
 		for iNdEx := len(m.ActiveRules) - 1; iNdEx >= 0; iNdEx-- {
@@ -1328,20 +1483,30 @@ func (m *RuleStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
-	n4, err4 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
-	if err4 != nil {
-		return 0, err4
+	{
+		size, err := m.QueryStats.MarshalToSizedBuffer(dAtA[:i])
+		if err != nil {
+			return 0, err
+		}
+		i -= size
+		i = encodeVarintRuler(dAtA, i, uint64(size))
+	}
+	i--
+	dAtA[i] = 0x42
+	n6, err6 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.EvaluationDuration, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration):])
+	if err6 != nil {
+		return 0, err6
 	}
-	i -= n4
-	i = encodeVarintRuler(dAtA, i, uint64(n4))
+	i -= n6
+	i = encodeVarintRuler(dAtA, i, uint64(n6))
 	i--
 	dAtA[i] = 0x3a
-	n5, err5 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
-	if err5 != nil {
-		return 0, err5
+	n7, err7 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.EvaluationTimestamp, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.EvaluationTimestamp):])
+	if err7 != nil {
+		return 0, err7
 	}
-	i -= n5
-	i = encodeVarintRuler(dAtA, i, uint64(n5))
+	i -= n7
+	i = encodeVarintRuler(dAtA, i, uint64(n7))
 	i--
 	dAtA[i] = 0x32
 	if len(m.Alerts) > 0 {
@@ -1414,53 +1579,53 @@ func (m *AlertStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	_ = i
 	var l int
 	_ = l
-	n7, err7 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.KeepFiringSince, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.KeepFiringSince):])
-	if err7 != nil {
-		return 0, err7
-	}
-	i -= n7
-	i = encodeVarintRuler(dAtA, i, uint64(n7))
-	i--
-	dAtA[i] = 0x52
-	n8, err8 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ValidUntil, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ValidUntil):])
-	if err8 != nil {
-		return 0, err8
-	}
-	i -= n8
-	i = encodeVarintRuler(dAtA, i, uint64(n8))
-	i--
-	dAtA[i] = 0x4a
-	n9, err9 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.LastSentAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.LastSentAt):])
+	n9, err9 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.KeepFiringSince, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.KeepFiringSince):])
 	if err9 != nil {
 		return 0, err9
 	}
 	i -= n9
 	i = encodeVarintRuler(dAtA, i, uint64(n9))
 	i--
-	dAtA[i] = 0x42
-	n10, err10 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ResolvedAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ResolvedAt):])
+	dAtA[i] = 0x52
+	n10, err10 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ValidUntil, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ValidUntil):])
 	if err10 != nil {
 		return 0, err10
 	}
 	i -= n10
 	i = encodeVarintRuler(dAtA, i, uint64(n10))
 	i--
-	dAtA[i] = 0x3a
-	n11, err11 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.FiredAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.FiredAt):])
+	dAtA[i] = 0x4a
+	n11, err11 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.LastSentAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.LastSentAt):])
 	if err11 != nil {
 		return 0, err11
 	}
 	i -= n11
 	i = encodeVarintRuler(dAtA, i, uint64(n11))
 	i--
-	dAtA[i] = 0x32
-	n12, err12 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ActiveAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ActiveAt):])
+	dAtA[i] = 0x42
+	n12, err12 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ResolvedAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ResolvedAt):])
 	if err12 != nil {
 		return 0, err12
 	}
 	i -= n12
 	i = encodeVarintRuler(dAtA, i, uint64(n12))
 	i--
+	dAtA[i] = 0x3a
+	n13, err13 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.FiredAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.FiredAt):])
+	if err13 != nil {
+		return 0, err13
+	}
+	i -= n13
+	i = encodeVarintRuler(dAtA, i, uint64(n13))
+	i--
+	dAtA[i] = 0x32
+	n14, err14 := github_com_gogo_protobuf_types.StdTimeMarshalTo(m.ActiveAt, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdTime(m.ActiveAt):])
+	if err14 != nil {
+		return 0, err14
+	}
+	i -= n14
+	i = encodeVarintRuler(dAtA, i, uint64(n14))
+	i--
 	dAtA[i] = 0x2a
 	if m.Value != 0 {
 		i -= 8
@@ -1506,6 +1671,52 @@ func (m *AlertStateDesc) MarshalToSizedBuffer(dAtA []byte) (int, error) {
 	return len(dAtA) - i, nil
 }
 
+func (m *RuleQueryStats) Marshal() (dAtA []byte, err error) {
+	size := m.Size()
+	dAtA = make([]byte, size)
+	n, err := m.MarshalToSizedBuffer(dAtA[:size])
+	if err != nil {
+		return nil, err
+	}
+	return dAtA[:n], nil
+}
+
+func (m *RuleQueryStats) MarshalTo(dAtA []byte) (int, error) {
+	size := m.Size()
+	return m.MarshalToSizedBuffer(dAtA[:size])
+}
+
+func (m *RuleQueryStats) MarshalToSizedBuffer(dAtA []byte) (int, error) {
+	i := len(dAtA)
+	_ = i
+	var l int
+	_ = l
+	if m.FetchedChunkBytes != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.FetchedChunkBytes))
+		i--
+		dAtA[i] = 0x20
+	}
+	if m.FetchedChunksCount != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.FetchedChunksCount))
+		i--
+		dAtA[i] = 0x18
+	}
+	if m.FetchedSeriesCount != 0 {
+		i = encodeVarintRuler(dAtA, i, uint64(m.FetchedSeriesCount))
+		i--
+		dAtA[i] = 0x10
+	}
+	n15, err15 := github_com_gogo_protobuf_types.StdDurationMarshalTo(m.WallTime, dAtA[i-github_com_gogo_protobuf_types.SizeOfStdDuration(m.WallTime):])
+	if err15 != nil {
+		return 0, err15
+	}
+	i -= n15
+	i = encodeVarintRuler(dAtA, i, uint64(n15))
+	i--
+	dAtA[i] = 0xa
+	return len(dAtA) - i, nil
+}
+
 func encodeVarintRuler(dAtA []byte, offset int, v uint64) int {
 	offset -= sovRuler(v)
 	base := offset
@@ -1606,6 +1817,8 @@ func (m *GroupStateDesc) Size() (n int) {
 	n += 1 + l + sovRuler(uint64(l))
 	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration)
 	n += 1 + l + sovRuler(uint64(l))
+	l = m.QueryStats.Size()
+	n += 1 + l + sovRuler(uint64(l))
 	return n
 }
 
@@ -1641,6 +1854,8 @@ func (m *RuleStateDesc) Size() (n int) {
 	n += 1 + l + sovRuler(uint64(l))
 	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.EvaluationDuration)
 	n += 1 + l + sovRuler(uint64(l))
+	l = m.QueryStats.Size()
+	n += 1 + l + sovRuler(uint64(l))
 	return n
 }
 
@@ -1684,6 +1899,26 @@ func (m *AlertStateDesc) Size() (n int) {
 	return n
 }
 
+func (m *RuleQueryStats) Size() (n int) {
+	if m == nil {
+		return 0
+	}
+	var l int
+	_ = l
+	l = github_com_gogo_protobuf_types.SizeOfStdDuration(m.WallTime)
+	n += 1 + l + sovRuler(uint64(l))
+	if m.FetchedSeriesCount != 0 {
+		n += 1 + sovRuler(uint64(m.FetchedSeriesCount))
+	}
+	if m.FetchedChunksCount != 0 {
+		n += 1 + sovRuler(uint64(m.FetchedChunksCount))
+	}
+	if m.FetchedChunkBytes != 0 {
+		n += 1 + sovRuler(uint64(m.FetchedChunkBytes))
+	}
+	return n
+}
+
 func sovRuler(x uint64) (n int) {
 	return (math_bits.Len64(x|1) + 6) / 7
 }
@@ -1751,6 +1986,7 @@ func (this *GroupStateDesc) String() string {
 		`ActiveRules:` + repeatedStringForActiveRules + `,`,
 		`EvaluationTimestamp:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationTimestamp), "Timestamp", "timestamppb.Timestamp", 1), `&`, ``, 1) + `,`,
 		`EvaluationDuration:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationDuration), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`QueryStats:` + strings.Replace(strings.Replace(this.QueryStats.String(), "RuleQueryStats", "RuleQueryStats", 1), `&`, ``, 1) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1772,6 +2008,7 @@ func (this *RuleStateDesc) String() string {
 		`Alerts:` + repeatedStringForAlerts + `,`,
 		`EvaluationTimestamp:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationTimestamp), "Timestamp", "timestamppb.Timestamp", 1), `&`, ``, 1) + `,`,
 		`EvaluationDuration:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.EvaluationDuration), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`QueryStats:` + strings.Replace(strings.Replace(this.QueryStats.String(), "RuleQueryStats", "RuleQueryStats", 1), `&`, ``, 1) + `,`,
 		`}`,
 	}, "")
 	return s
@@ -1795,6 +2032,19 @@ func (this *AlertStateDesc) String() string {
 	}, "")
 	return s
 }
+func (this *RuleQueryStats) String() string {
+	if this == nil {
+		return "nil"
+	}
+	s := strings.Join([]string{`&RuleQueryStats{`,
+		`WallTime:` + strings.Replace(strings.Replace(fmt.Sprintf("%v", this.WallTime), "Duration", "durationpb.Duration", 1), `&`, ``, 1) + `,`,
+		`FetchedSeriesCount:` + fmt.Sprintf("%v", this.FetchedSeriesCount) + `,`,
+		`FetchedChunksCount:` + fmt.Sprintf("%v", this.FetchedChunksCount) + `,`,
+		`FetchedChunkBytes:` + fmt.Sprintf("%v", this.FetchedChunkBytes) + `,`,
+		`}`,
+	}, "")
+	return s
+}
 func valueToStringRuler(v interface{}) string {
 	rv := reflect.ValueOf(v)
 	if rv.IsNil() {
@@ -2361,6 +2611,39 @@ func (m *GroupStateDesc) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 5:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryStats", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.QueryStats.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRuler(dAtA[iNdEx:])
@@ -2646,6 +2929,39 @@ func (m *RuleStateDesc) Unmarshal(dAtA []byte) error {
 				return err
 			}
 			iNdEx = postIndex
+		case 8:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field QueryStats", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := m.QueryStats.Unmarshal(dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
 		default:
 			iNdEx = preIndex
 			skippy, err := skipRuler(dAtA[iNdEx:])
@@ -3032,6 +3348,149 @@ func (m *AlertStateDesc) Unmarshal(dAtA []byte) error {
 	}
 	return nil
 }
+func (m *RuleQueryStats) Unmarshal(dAtA []byte) error {
+	l := len(dAtA)
+	iNdEx := 0
+	for iNdEx < l {
+		preIndex := iNdEx
+		var wire uint64
+		for shift := uint(0); ; shift += 7 {
+			if shift >= 64 {
+				return ErrIntOverflowRuler
+			}
+			if iNdEx >= l {
+				return io.ErrUnexpectedEOF
+			}
+			b := dAtA[iNdEx]
+			iNdEx++
+			wire |= uint64(b&0x7F) << shift
+			if b < 0x80 {
+				break
+			}
+		}
+		fieldNum := int32(wire >> 3)
+		wireType := int(wire & 0x7)
+		if wireType == 4 {
+			return fmt.Errorf("proto: RuleQueryStats: wiretype end group for non-group")
+		}
+		if fieldNum <= 0 {
+			return fmt.Errorf("proto: RuleQueryStats: illegal tag %d (wire type %d)", fieldNum, wire)
+		}
+		switch fieldNum {
+		case 1:
+			if wireType != 2 {
+				return fmt.Errorf("proto: wrong wireType = %d for field WallTime", wireType)
+			}
+			var msglen int
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				msglen |= int(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+			if msglen < 0 {
+				return ErrInvalidLengthRuler
+			}
+			postIndex := iNdEx + msglen
+			if postIndex < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if postIndex > l {
+				return io.ErrUnexpectedEOF
+			}
+			if err := github_com_gogo_protobuf_types.StdDurationUnmarshal(&m.WallTime, dAtA[iNdEx:postIndex]); err != nil {
+				return err
+			}
+			iNdEx = postIndex
+		case 2:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedSeriesCount", wireType)
+			}
+			m.FetchedSeriesCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedSeriesCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 3:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedChunksCount", wireType)
+			}
+			m.FetchedChunksCount = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedChunksCount |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		case 4:
+			if wireType != 0 {
+				return fmt.Errorf("proto: wrong wireType = %d for field FetchedChunkBytes", wireType)
+			}
+			m.FetchedChunkBytes = 0
+			for shift := uint(0); ; shift += 7 {
+				if shift >= 64 {
+					return ErrIntOverflowRuler
+				}
+				if iNdEx >= l {
+					return io.ErrUnexpectedEOF
+				}
+				b := dAtA[iNdEx]
+				iNdEx++
+				m.FetchedChunkBytes |= uint64(b&0x7F) << shift
+				if b < 0x80 {
+					break
+				}
+			}
+		default:
+			iNdEx = preIndex
+			skippy, err := skipRuler(dAtA[iNdEx:])
+			if err != nil {
+				return err
+			}
+			if skippy < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if (iNdEx + skippy) < 0 {
+				return ErrInvalidLengthRuler
+			}
+			if (iNdEx + skippy) > l {
+				return io.ErrUnexpectedEOF
+			}
+			iNdEx += skippy
+		}
+	}
+
+	if iNdEx > l {
+		return io.ErrUnexpectedEOF
+	}
+	return nil
+}
 func skipRuler(dAtA []byte) (n int, err error) {
 	l := len(dAtA)
 	iNdEx := 0
@@ -35,6 +35,8 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 	prefix := "ruler-storage."
 
 	cfg.StorageBackendConfig.ExtraBackends = []string{local.Name}
+	// Rule groups are stored at rules/<tenant>/...
+	cfg.Encryption.TenantPrefixes = []string{"rules"}
 	cfg.Local.RegisterFlagsWithPrefix(prefix, f)
 	cfg.RegisterFlagsWithPrefixAndDefaultDirectory(prefix, "ruler", f)
 
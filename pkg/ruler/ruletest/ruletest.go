@@ -0,0 +1,541 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+// Provenance-includes-location: https://github.com/prometheus/prometheus/blob/main/cmd/promtool/unittest.go
+// Provenance-includes-license: Apache-2.0
+// Provenance-includes-copyright: The Prometheus Authors.
+
+// Package ruletest runs rules unit tests, in the format used by "promtool test rules", evaluating
+// the rule groups under test against an isolated in-memory storage.
+package ruletest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/regexp"
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+)
+
+const (
+	defaultEvaluationInterval = time.Minute
+
+	// MaxSamplesPerTestGroup is the maximum number of input samples of a test group.
+	MaxSamplesPerTestGroup = 1_000_000
+
+	// MaxEvaluationStepsPerTestGroup is the maximum number of evaluations of the rule groups in a test group.
+	MaxEvaluationStepsPerTestGroup = 100_000
+
+	// MaxEvaluationStepsPerFile is the maximum number of evaluations of the rule groups across all the test groups of a file.
+	MaxEvaluationStepsPerFile = 1_000_000
+
+	// MaxTestGroupsPerFile is the maximum number of test groups of a file.
+	MaxTestGroupsPerFile = 100
+)
+
+var seriesValuesRepetitions = regexp.MustCompile(`x(\d+)`)
+
+// TestFile is a rules unit test file. It's the same format used by "promtool test rules", except that
+// the rule groups under test are included in the file instead of being referenced by path.
+type TestFile struct {
+	Groups             []rulefmt.RuleGroup `yaml:"groups"`
+	EvaluationInterval model.Duration      `yaml:"evaluation_interval,omitempty"`
+	GroupEvalOrder     []string            `yaml:"group_eval_order,omitempty"`
+	Tests              []TestGroup         `yaml:"tests"`
+}
+
+// TestGroup is a group of input series and the tests run against them.
+type TestGroup struct {
+	Name            string            `yaml:"name,omitempty"`
+	Interval        model.Duration    `yaml:"interval,omitempty"`
+	InputSeries     []Series          `yaml:"input_series"`
+	AlertRuleTests  []AlertTestCase   `yaml:"alert_rule_test,omitempty"`
+	PromqlExprTests []PromqlTestCase  `yaml:"promql_expr_test,omitempty"`
+	ExternalLabels  map[string]string `yaml:"external_labels,omitempty"`
+	ExternalURL     string            `yaml:"external_url,omitempty"`
+}
+
+// Series is an input series, in the expanding notation used by promtool, for example "1+1x10".
+type Series struct {
+	Series string `yaml:"series"`
+	Values string `yaml:"values"`
+}
+
+// AlertTestCase is the expected firing alerts of an alerting rule at a given time.
+type AlertTestCase struct {
+	EvalTime  model.Duration `yaml:"eval_time"`
+	Alertname string         `yaml:"alertname"`
+	ExpAlerts []Alert        `yaml:"exp_alerts"`
+}
+
+// Alert is an expected firing alert.
+type Alert struct {
+	ExpLabels      map[string]string `yaml:"exp_labels"`
+	ExpAnnotations map[string]string `yaml:"exp_annotations"`
+}
+
+// PromqlTestCase is the expected result of a PromQL expression at a given time.
+type PromqlTestCase struct {
+	Expr       string         `yaml:"expr"`
+	EvalTime   model.Duration `yaml:"eval_time"`
+	ExpSamples []Sample       `yaml:"exp_samples"`
+}
+
+// Sample is an expected sample of the result of a PromQL expression.
+type Sample struct {
+	Labels    string  `yaml:"labels"`
+	Value     float64 `yaml:"value"`
+	Histogram string  `yaml:"histogram"`
+}
+
+// Report is the result of running the tests of a TestFile.
+type Report struct {
+	Passed bool         `json:"passed"`
+	Tests  []TestResult `json:"tests"`
+}
+
+// TestResult is the result of running a TestGroup.
+type TestResult struct {
+	Name   string   `json:"name"`
+	Passed bool     `json:"passed"`
+	Errors []string `json:"errors,omitempty"`
+}
+
+// Run runs the tests of the file and returns their report. An error is returned if the file is invalid,
+// for example because its rule groups can't be parsed, while test failures are reported in the Report.
+func Run(ctx context.Context, file TestFile, logger log.Logger) (Report, error) {
+	evalInterval := time.Duration(file.EvaluationInterval)
+	if evalInterval <= 0 {
+		evalInterval = defaultEvaluationInterval
+	}
+
+	if len(file.Tests) > MaxTestGroupsPerFile {
+		return Report{}, fmt.Errorf("the file has %d test groups, while the maximum allowed is %d", len(file.Tests), MaxTestGroupsPerFile)
+	}
+
+	// Check the evaluations required by all the test groups upfront, to not run any test of a file which is too expensive.
+	var steps int64
+	for _, tg := range file.Tests {
+		steps += int64(lastEvalTime(tg) / evalInterval)
+	}
+	if steps > MaxEvaluationStepsPerFile {
+		return Report{}, fmt.Errorf("the test groups require %d evaluations, while the maximum allowed is %d", steps, MaxEvaluationStepsPerFile)
+	}
+
+	groups, err := orderGroups(file.Groups, file.GroupEvalOrder)
+	if err != nil {
+		return Report{}, err
+	}
+
+	// Parse the rules once upfront, to reject invalid rule groups before running any test.
+	for _, g := range groups {
+		if _, err := newRules(g, labels.EmptyLabels(), "", logger); err != nil {
+			return Report{}, err
+		}
+	}
+
+	report := Report{Passed: true, Tests: make([]TestResult, 0, len(file.Tests))}
+	for i, tg := range file.Tests {
+		name := tg.Name
+		if name == "" {
+			name = "test #" + strconv.Itoa(i+1)
+		}
+
+		errs := runTestGroup(ctx, tg, groups, evalInterval, logger)
+
+		result := TestResult{Name: name, Passed: len(errs) == 0}
+		for _, err := range errs {
+			result.Errors = append(result.Errors, err.Error())
+		}
+		report.Tests = append(report.Tests, result)
+		report.Passed = report.Passed && result.Passed
+	}
+
+	return report, nil
+}
+
+// orderGroups returns the rule groups in the order they have to be evaluated.
+func orderGroups(groups []rulefmt.RuleGroup, order []string) ([]rulefmt.RuleGroup, error) {
+	if len(order) == 0 {
+		return groups, nil
+	}
+
+	byName := make(map[string]rulefmt.RuleGroup, len(groups))
+	for _, g := range groups {
+		byName[g.Name] = g
+	}
+
+	ordered := make([]rulefmt.RuleGroup, 0, len(groups))
+	seen := make(map[string]struct{}, len(order))
+	for _, name := range order {
+		g, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("group_eval_order: rule group %q doesn't exist", name)
+		}
+		if _, ok := seen[name]; ok {
+			return nil, fmt.Errorf("group_eval_order: rule group %q is listed more than once", name)
+		}
+		seen[name] = struct{}{}
+		ordered = append(ordered, g)
+	}
+
+	// Rule groups not listed are evaluated after the listed ones, in the input order.
+	for _, g := range groups {
+		if _, ok := seen[g.Name]; !ok {
+			ordered = append(ordered, g)
+		}
+	}
+
+	return ordered, nil
+}
+
+func newRules(g rulefmt.RuleGroup, externalLabels labels.Labels, externalURL string, logger log.Logger) ([]rules.Rule, error) {
+	result := make([]rules.Rule, 0, len(g.Rules))
+	for i, r := range g.Rules {
+		expr, err := parser.ParseExpr(r.Expr.Value)
+		if err != nil {
+			return nil, errors.Wrapf(err, "rule group %q, rule %d", g.Name, i)
+		}
+
+		if r.Alert.Value != "" {
+			result = append(result, rules.NewAlertingRule(
+				r.Alert.Value, expr, time.Duration(r.For), time.Duration(r.KeepFiringFor),
+				labels.FromMap(r.Labels), labels.FromMap(r.Annotations), externalLabels, externalURL,
+				true, log.With(logger, "alert", r.Alert.Value),
+			))
+			continue
+		}
+
+		result = append(result, rules.NewRecordingRule(r.Record.Value, expr, labels.FromMap(r.Labels)))
+	}
+	return result, nil
+}
+
+func runTestGroup(ctx context.Context, tg TestGroup, ruleGroups []rulefmt.RuleGroup, evalInterval time.Duration, logger log.Logger) []error {
+	interval := time.Duration(tg.Interval)
+	if interval <= 0 {
+		interval = evalInterval
+	}
+
+	storage := newMemoryStorage()
+	if err := loadInputSeries(ctx, storage, tg.InputSeries, interval); err != nil {
+		return []error{err}
+	}
+
+	maxEvalTime := lastEvalTime(tg)
+	if steps := maxEvalTime / evalInterval; steps > MaxEvaluationStepsPerTestGroup {
+		return []error{fmt.Errorf("the test group requires %d evaluations, while the maximum allowed is %d", steps, MaxEvaluationStepsPerTestGroup)}
+	}
+
+	engine := promql.NewEngine(promql.EngineOpts{
+		Logger:               logger,
+		MaxSamples:           50_000_000,
+		Timeout:              time.Minute,
+		EnableAtModifier:     true,
+		EnableNegativeOffset: true,
+		NoStepSubqueryIntervalFn: func(int64) int64 {
+			return evalInterval.Milliseconds()
+		},
+	})
+
+	opts := &rules.ManagerOptions{
+		QueryFunc:  rules.EngineQueryFunc(engine, storage),
+		Appendable: storage,
+		Queryable:  storage,
+		Context:    ctx,
+		NotifyFunc: func(context.Context, string, ...*rules.Alert) {},
+		Logger:     logger,
+	}
+
+	externalLabels := labels.FromMap(tg.ExternalLabels)
+	groups := make([]*rules.Group, 0, len(ruleGroups))
+	for _, g := range ruleGroups {
+		groupRules, err := newRules(g, externalLabels, tg.ExternalURL, logger)
+		if err != nil {
+			return []error{err}
+		}
+
+		groupInterval := time.Duration(g.Interval)
+		if groupInterval <= 0 {
+			groupInterval = evalInterval
+		}
+
+		groups = append(groups, rules.NewGroup(rules.GroupOptions{
+			Name:     g.Name,
+			File:     "test",
+			Interval: groupInterval,
+			Limit:    g.Limit,
+			Rules:    groupRules,
+			Opts:     opts,
+		}))
+	}
+
+	// Sort the alert test cases by evaluation time, to check them while evaluating the rule groups.
+	alertTests := append([]AlertTestCase(nil), tg.AlertRuleTests...)
+	sort.SliceStable(alertTests, func(i, j int) bool { return alertTests[i].EvalTime < alertTests[j].EvalTime })
+
+	var errs []error
+	mint := time.Unix(0, 0).UTC()
+	for ts := mint; !ts.After(mint.Add(maxEvalTime)); ts = ts.Add(evalInterval) {
+		if err := ctx.Err(); err != nil {
+			return append(errs, err)
+		}
+
+		for _, g := range groups {
+			if ts.Sub(mint)%g.Interval() != 0 {
+				continue
+			}
+
+			g.Eval(ctx, ts)
+			for _, r := range g.Rules() {
+				if err := r.LastError(); err != nil {
+					errs = append(errs, fmt.Errorf("rule: %s, time: %s, err: %v", r.Name(), ts.Sub(mint), err))
+				}
+			}
+		}
+
+		// Check the alert test cases whose evaluation time is within the current evaluation step.
+		for len(alertTests) > 0 && time.Duration(alertTests[0].EvalTime) < ts.Sub(mint)+evalInterval {
+			if err := checkAlerts(alertTests[0], groups); err != nil {
+				errs = append(errs, err)
+			}
+			alertTests = alertTests[1:]
+		}
+	}
+
+	for _, tc := range tg.PromqlExprTests {
+		if err := checkPromqlExpr(ctx, engine, storage, tc, mint); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	return errs
+}
+
+// loadInputSeries expands the input series and appends their samples to the storage.
+// lastEvalTime returns the evaluation time of the last test case of the test group.
+func lastEvalTime(tg TestGroup) time.Duration {
+	var last time.Duration
+	for _, tc := range tg.AlertRuleTests {
+		last = max(last, time.Duration(tc.EvalTime))
+	}
+	for _, tc := range tg.PromqlExprTests {
+		last = max(last, time.Duration(tc.EvalTime))
+	}
+	return last
+}
+
+func loadInputSeries(ctx context.Context, storage *memoryStorage, input []Series, interval time.Duration) error {
+	// The expanding notation can describe a huge number of samples in a few bytes, so the number of samples
+	// is checked before the series are expanded.
+	var numSamples int
+	for _, s := range input {
+		numSamples += len(strings.Fields(s.Values))
+		for _, m := range seriesValuesRepetitions.FindAllStringSubmatch(s.Values, -1) {
+			n, err := strconv.Atoi(m[1])
+			if err != nil || n > MaxSamplesPerTestGroup {
+				return fmt.Errorf("the input series have more than %d samples", MaxSamplesPerTestGroup)
+			}
+			numSamples += n
+		}
+	}
+	if numSamples > MaxSamplesPerTestGroup {
+		return fmt.Errorf("the input series have more than %d samples", MaxSamplesPerTestGroup)
+	}
+
+	app := storage.Appender(ctx)
+	for _, s := range input {
+		lbls, values, err := parser.ParseSeriesDesc(s.Series + " " + s.Values)
+		if err != nil {
+			return errors.Wrapf(err, "failed to parse input series %q", s.Series)
+		}
+
+		for i, v := range values {
+			if v.Omitted {
+				continue
+			}
+
+			t := int64(i) * interval.Milliseconds()
+			if v.Histogram != nil {
+				_, err = app.AppendHistogram(0, lbls, t, nil, v.Histogram)
+			} else {
+				_, err = app.Append(0, lbls, t, v.Value)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return app.Commit()
+}
+
+type alertSample struct {
+	labels      labels.Labels
+	annotations labels.Labels
+}
+
+func (a alertSample) String() string {
+	return fmt.Sprintf("{labels: %s, annotations: %s}", a.labels, a.annotations)
+}
+
+func checkAlerts(tc AlertTestCase, groups []*rules.Group) error {
+	var got []alertSample
+	for _, g := range groups {
+		for _, r := range g.AlertingRules() {
+			if r.Name() != tc.Alertname {
+				continue
+			}
+
+			r.ForEachActiveAlert(func(a *rules.Alert) {
+				if a.State == rules.StateFiring {
+					got = append(got, alertSample{labels: a.Labels.Copy(), annotations: a.Annotations.Copy()})
+				}
+			})
+		}
+	}
+
+	expected := make([]alertSample, 0, len(tc.ExpAlerts))
+	for _, a := range tc.ExpAlerts {
+		// The alertname label is added by the alerting rule, so it's not required in the expected labels.
+		lbls := labels.NewBuilder(labels.FromMap(a.ExpLabels)).Set(labels.AlertName, tc.Alertname).Labels()
+		expected = append(expected, alertSample{labels: lbls, annotations: labels.FromMap(a.ExpAnnotations)})
+	}
+
+	sortAlerts(got)
+	sortAlerts(expected)
+
+	if len(got) == len(expected) {
+		equal := true
+		for i := range got {
+			if !labels.Equal(got[i].labels, expected[i].labels) || !labels.Equal(got[i].annotations, expected[i].annotations) {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("alertname: %s, time: %s, exp: %v, got: %v", tc.Alertname, time.Duration(tc.EvalTime), expected, got)
+}
+
+func sortAlerts(alerts []alertSample) {
+	sort.Slice(alerts, func(i, j int) bool {
+		return labels.Compare(alerts[i].labels, alerts[j].labels) < 0
+	})
+}
+
+type promqlSample struct {
+	labels    labels.Labels
+	value     float64
+	histogram *histogram.FloatHistogram
+}
+
+func (s promqlSample) String() string {
+	if s.histogram != nil {
+		return fmt.Sprintf("%s %s", s.labels, s.histogram)
+	}
+	return fmt.Sprintf("%s %s", s.labels, strconv.FormatFloat(s.value, 'g', -1, 64))
+}
+
+func (s promqlSample) equal(other promqlSample) bool {
+	if !labels.Equal(s.labels, other.labels) {
+		return false
+	}
+	if s.histogram != nil || other.histogram != nil {
+		return s.histogram != nil && other.histogram != nil && s.histogram.Equals(other.histogram)
+	}
+	return s.value == other.value || (math.IsNaN(s.value) && math.IsNaN(other.value))
+}
+
+func checkPromqlExpr(ctx context.Context, engine *promql.Engine, storage *memoryStorage, tc PromqlTestCase, mint time.Time) error {
+	evalTime := mint.Add(time.Duration(tc.EvalTime))
+
+	got, err := queryInstant(ctx, engine, storage, tc.Expr, evalTime)
+	if err != nil {
+		return fmt.Errorf("expr: %q, time: %s, err: %v", tc.Expr, time.Duration(tc.EvalTime), err)
+	}
+
+	expected := make([]promqlSample, 0, len(tc.ExpSamples))
+	for _, s := range tc.ExpSamples {
+		lbls := labels.EmptyLabels()
+		if s.Labels != "" {
+			lbls, err = parser.ParseMetric(s.Labels)
+			if err != nil {
+				return fmt.Errorf("expr: %q, time: %s, err: failed to parse the labels of the expected sample: %v", tc.Expr, time.Duration(tc.EvalTime), err)
+			}
+		}
+
+		sample := promqlSample{labels: lbls, value: s.Value}
+		if s.Histogram != "" {
+			_, values, err := parser.ParseSeriesDesc("{} " + s.Histogram)
+			if err != nil || len(values) != 1 || values[0].Histogram == nil {
+				return fmt.Errorf("expr: %q, time: %s, err: failed to parse the histogram of the expected sample: %q", tc.Expr, time.Duration(tc.EvalTime), s.Histogram)
+			}
+			sample.histogram = values[0].Histogram
+		}
+		expected = append(expected, sample)
+	}
+
+	sortSamples(got)
+	sortSamples(expected)
+
+	if len(got) == len(expected) {
+		equal := true
+		for i := range got {
+			if !got[i].equal(expected[i]) {
+				equal = false
+				break
+			}
+		}
+		if equal {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("expr: %q, time: %s, exp: %v, got: %v", tc.Expr, time.Duration(tc.EvalTime), expected, got)
+}
+
+func queryInstant(ctx context.Context, engine *promql.Engine, storage *memoryStorage, expr string, ts time.Time) ([]promqlSample, error) {
+	q, err := engine.NewInstantQuery(ctx, storage, nil, expr, ts)
+	if err != nil {
+		return nil, err
+	}
+	defer q.Close()
+
+	res := q.Exec(ctx)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+
+	switch v := res.Value.(type) {
+	case promql.Vector:
+		samples := make([]promqlSample, 0, len(v))
+		for _, s := range v {
+			samples = append(samples, promqlSample{labels: s.Metric, value: s.F, histogram: s.H})
+		}
+		return samples, nil
+	case promql.Scalar:
+		return []promqlSample{{labels: labels.EmptyLabels(), value: v.V}}, nil
+	default:
+		return nil, errors.New("the expression must return a vector or a scalar")
+	}
+}
+
+func sortSamples(samples []promqlSample) {
+	sort.Slice(samples, func(i, j int) bool {
+		return labels.Compare(samples[i].labels, samples[j].labels) < 0
+	})
+}
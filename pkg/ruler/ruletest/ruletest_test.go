@@ -0,0 +1,222 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruletest
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
+
+const testRuleGroups = `
+evaluation_interval: 1m
+groups:
+  - name: recording
+    rules:
+      - record: job:http_requests:rate5m
+        expr: sum by (job) (rate(http_requests_total[5m]))
+  - name: alerting
+    rules:
+      - alert: HighRequestRate
+        expr: job:http_requests:rate5m > 1
+        for: 2m
+        labels:
+          severity: page
+        annotations:
+          summary: "High request rate on {{ $labels.job }}"
+`
+
+func TestRun(t *testing.T) {
+	tests := map[string]struct {
+		tests          string
+		expectedReport Report
+	}{
+		"passing tests": {
+			tests: `
+tests:
+  - name: high rate
+    interval: 1m
+    input_series:
+      - series: 'http_requests_total{job="api", instance="a"}'
+        values: '0+120x10'
+      - series: 'http_requests_total{job="web", instance="a"}'
+        values: '0+6x10'
+    promql_expr_test:
+      - expr: job:http_requests:rate5m
+        eval_time: 6m
+        exp_samples:
+          - labels: 'job:http_requests:rate5m{job="api"}'
+            value: 2
+          - labels: 'job:http_requests:rate5m{job="web"}'
+            value: 0.1
+      - expr: count(http_requests_total)
+        eval_time: 1m
+        exp_samples:
+          - value: 2
+    alert_rule_test:
+      - alertname: HighRequestRate
+        eval_time: 2m
+      - alertname: HighRequestRate
+        eval_time: 6m
+        exp_alerts:
+          - exp_labels:
+              job: api
+              severity: page
+            exp_annotations:
+              summary: "High request rate on api"
+`,
+			expectedReport: Report{
+				Passed: true,
+				Tests:  []TestResult{{Name: "high rate", Passed: true}},
+			},
+		},
+		"failing tests": {
+			tests: `
+tests:
+  - input_series:
+      - series: 'http_requests_total{job="api", instance="a"}'
+        values: '0+120x10'
+    promql_expr_test:
+      - expr: job:http_requests:rate5m
+        eval_time: 6m
+        exp_samples:
+          - labels: 'job:http_requests:rate5m{job="api"}'
+            value: 3
+    alert_rule_test:
+      - alertname: HighRequestRate
+        eval_time: 6m
+  - name: passing
+    input_series:
+      - series: 'http_requests_total{job="api", instance="a"}'
+        values: '0 0 0'
+    alert_rule_test:
+      - alertname: HighRequestRate
+        eval_time: 2m
+  - name: invalid series
+    input_series:
+      - series: 'http_requests_total{job="api"'
+        values: '0 0 0'
+  - name: too many samples
+    input_series:
+      - series: 'http_requests_total{job="api"}'
+        values: '0+1x100000000'
+`,
+			expectedReport: Report{
+				Passed: false,
+				Tests: []TestResult{
+					{Name: "test #1", Passed: false, Errors: []string{
+						`alertname: HighRequestRate, time: 6m0s, exp: [], got: [{labels: {alertname="HighRequestRate", job="api", severity="page"}, annotations: {summary="High request rate on api"}}]`,
+						`expr: "job:http_requests:rate5m", time: 6m0s, exp: [{__name__="job:http_requests:rate5m", job="api"} 3], got: [{__name__="job:http_requests:rate5m", job="api"} 2]`,
+					}},
+					{Name: "passing", Passed: true},
+					{Name: "invalid series", Passed: false, Errors: []string{
+						`failed to parse input series "http_requests_total{job=\"api\"": 1:31: parse error: unexpected character inside braces: '0'`,
+					}},
+					{Name: "too many samples", Passed: false, Errors: []string{
+						"the input series have more than 1000000 samples",
+					}},
+				},
+			},
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			var file TestFile
+			require.NoError(t, yaml.Unmarshal([]byte(testRuleGroups+testData.tests), &file))
+
+			report, err := Run(context.Background(), file, log.NewNopLogger())
+			require.NoError(t, err)
+			assert.Equal(t, testData.expectedReport, report)
+		})
+	}
+}
+
+func TestRun_GroupEvalOrder(t *testing.T) {
+	const input = `
+groups:
+  - name: second
+    rules:
+      - record: second
+        expr: first * 2
+  - name: first
+    rules:
+      - record: first
+        expr: vector(1)
+tests:
+  - promql_expr_test:
+      - expr: second
+        eval_time: 0m
+        exp_samples:
+          - labels: 'second'
+            value: 2
+`
+
+	var file TestFile
+	require.NoError(t, yaml.Unmarshal([]byte(input), &file))
+
+	// The dependent rule group is evaluated first, so it doesn't see the output of the other one.
+	report, err := Run(context.Background(), file, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.False(t, report.Passed)
+
+	file.GroupEvalOrder = []string{"first", "second"}
+	report, err = Run(context.Background(), file, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.True(t, report.Passed, report)
+
+	file.GroupEvalOrder = []string{"first", "unknown"}
+	_, err = Run(context.Background(), file, log.NewNopLogger())
+	assert.EqualError(t, err, `group_eval_order: rule group "unknown" doesn't exist`)
+}
+
+func TestRun_Limits(t *testing.T) {
+	tests := map[string]struct {
+		tests       string
+		expectedErr string
+	}{
+		"too many test groups": {
+			tests:       "tests:\n" + strings.Repeat("  - name: test\n", MaxTestGroupsPerFile+1),
+			expectedErr: "the file has 101 test groups, while the maximum allowed is 100",
+		},
+		"too many evaluations across the test groups": {
+			tests: "tests:\n" + strings.Repeat(`
+  - promql_expr_test:
+      - expr: up
+        eval_time: 60d
+`, 12),
+			expectedErr: "the test groups require 1036800 evaluations, while the maximum allowed is 1000000",
+		},
+	}
+
+	for name, testData := range tests {
+		t.Run(name, func(t *testing.T) {
+			var file TestFile
+			require.NoError(t, yaml.Unmarshal([]byte(testRuleGroups+testData.tests), &file))
+
+			_, err := Run(context.Background(), file, log.NewNopLogger())
+			assert.EqualError(t, err, testData.expectedErr)
+		})
+	}
+}
+
+func TestRun_InvalidRules(t *testing.T) {
+	const input = `
+groups:
+  - name: invalid
+    rules:
+      - record: invalid
+        expr: sum(
+`
+
+	var file TestFile
+	require.NoError(t, yaml.Unmarshal([]byte(input), &file))
+
+	_, err := Run(context.Background(), file, log.NewNopLogger())
+	assert.ErrorContains(t, err, `rule group "invalid", rule 0`)
+}
@@ -0,0 +1,237 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ruletest
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/exemplar"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/metadata"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/util/annotations"
+
+	"github.com/grafana/mimir/pkg/mimirpb"
+	"github.com/grafana/mimir/pkg/storage/series"
+)
+
+// memoryStorage is an in-memory storage.Storage-like store of the series of a test group,
+// which is not shared with anything else.
+type memoryStorage struct {
+	mtx    sync.RWMutex
+	series map[string]*memorySeries
+}
+
+type memorySeries struct {
+	lbls       labels.Labels
+	floats     []model.SamplePair
+	histograms []mimirpb.Histogram
+}
+
+func newMemoryStorage() *memoryStorage {
+	return &memoryStorage{series: map[string]*memorySeries{}}
+}
+
+func (s *memoryStorage) appendFloat(lbls labels.Labels, t int64, v float64) {
+	entry := s.getOrCreateSeries(lbls)
+	entry.floats = append(entry.floats, model.SamplePair{Timestamp: model.Time(t), Value: model.SampleValue(v)})
+
+	// Samples are usually appended in order, so only sort when needed.
+	if n := len(entry.floats); n > 1 && entry.floats[n-2].Timestamp > entry.floats[n-1].Timestamp {
+		sort.SliceStable(entry.floats, func(i, j int) bool { return entry.floats[i].Timestamp < entry.floats[j].Timestamp })
+	}
+}
+
+func (s *memoryStorage) appendHistogram(lbls labels.Labels, h mimirpb.Histogram) {
+	entry := s.getOrCreateSeries(lbls)
+	entry.histograms = append(entry.histograms, h)
+
+	if n := len(entry.histograms); n > 1 && entry.histograms[n-2].Timestamp > entry.histograms[n-1].Timestamp {
+		sort.SliceStable(entry.histograms, func(i, j int) bool { return entry.histograms[i].Timestamp < entry.histograms[j].Timestamp })
+	}
+}
+
+// getOrCreateSeries must be called with the lock held.
+func (s *memoryStorage) getOrCreateSeries(lbls labels.Labels) *memorySeries {
+	key := lbls.String()
+	entry, ok := s.series[key]
+	if !ok {
+		entry = &memorySeries{lbls: lbls.Copy()}
+		s.series[key] = entry
+	}
+	return entry
+}
+
+// Appender implements storage.Appendable.
+func (s *memoryStorage) Appender(_ context.Context) storage.Appender {
+	return &memoryAppender{storage: s}
+}
+
+// Querier implements storage.Queryable.
+func (s *memoryStorage) Querier(mint, maxt int64) (storage.Querier, error) {
+	return &memoryQuerier{storage: s, mint: mint, maxt: maxt}, nil
+}
+
+type memoryFloatSample struct {
+	lbls labels.Labels
+	t    int64
+	v    float64
+}
+
+type memoryHistogramSample struct {
+	lbls labels.Labels
+	h    mimirpb.Histogram
+}
+
+// memoryAppender buffers the appended samples until they're committed.
+type memoryAppender struct {
+	storage    *memoryStorage
+	floats     []memoryFloatSample
+	histograms []memoryHistogramSample
+}
+
+func (a *memoryAppender) Append(_ storage.SeriesRef, l labels.Labels, t int64, v float64) (storage.SeriesRef, error) {
+	a.floats = append(a.floats, memoryFloatSample{lbls: l, t: t, v: v})
+	return 0, nil
+}
+
+func (a *memoryAppender) AppendHistogram(_ storage.SeriesRef, l labels.Labels, t int64, h *histogram.Histogram, fh *histogram.FloatHistogram) (storage.SeriesRef, error) {
+	var hp mimirpb.Histogram
+	if h != nil {
+		hp = mimirpb.FromHistogramToHistogramProto(t, h)
+	} else {
+		hp = mimirpb.FromFloatHistogramToHistogramProto(t, fh)
+	}
+	a.histograms = append(a.histograms, memoryHistogramSample{lbls: l, h: hp})
+	return 0, nil
+}
+
+func (a *memoryAppender) AppendExemplar(_ storage.SeriesRef, _ labels.Labels, _ exemplar.Exemplar) (storage.SeriesRef, error) {
+	return 0, errors.New("exemplars are unsupported")
+}
+
+func (a *memoryAppender) UpdateMetadata(_ storage.SeriesRef, _ labels.Labels, _ metadata.Metadata) (storage.SeriesRef, error) {
+	return 0, errors.New("metadata updates are unsupported")
+}
+
+func (a *memoryAppender) AppendCTZeroSample(_ storage.SeriesRef, _ labels.Labels, _, _ int64) (storage.SeriesRef, error) {
+	return 0, errors.New("CT zero samples are unsupported")
+}
+
+func (a *memoryAppender) Commit() error {
+	a.storage.mtx.Lock()
+	defer a.storage.mtx.Unlock()
+
+	for _, s := range a.floats {
+		a.storage.appendFloat(s.lbls, s.t, s.v)
+	}
+	for _, s := range a.histograms {
+		a.storage.appendHistogram(s.lbls, s.h)
+	}
+
+	return a.Rollback()
+}
+
+func (a *memoryAppender) Rollback() error {
+	a.floats = nil
+	a.histograms = nil
+	return nil
+}
+
+type memoryQuerier struct {
+	storage    *memoryStorage
+	mint, maxt int64
+}
+
+func (q *memoryQuerier) Select(_ context.Context, _ bool, hints *storage.SelectHints, matchers ...*labels.Matcher) storage.SeriesSet {
+	mint, maxt := q.mint, q.maxt
+	if hints != nil {
+		mint, maxt = hints.Start, hints.End
+	}
+
+	q.storage.mtx.RLock()
+	defer q.storage.mtx.RUnlock()
+
+	var result []storage.Series
+	for _, entry := range q.storage.series {
+		if !matchesAll(entry.lbls, matchers) {
+			continue
+		}
+
+		var floats []model.SamplePair
+		for _, s := range entry.floats {
+			if int64(s.Timestamp) >= mint && int64(s.Timestamp) <= maxt {
+				floats = append(floats, s)
+			}
+		}
+		var histograms []mimirpb.Histogram
+		for _, h := range entry.histograms {
+			if h.Timestamp >= mint && h.Timestamp <= maxt {
+				histograms = append(histograms, h)
+			}
+		}
+		if len(floats) == 0 && len(histograms) == 0 {
+			continue
+		}
+
+		result = append(result, series.NewConcreteSeries(entry.lbls, floats, histograms))
+	}
+
+	return series.NewConcreteSeriesSetFromUnsortedSeries(result)
+}
+
+func (q *memoryQuerier) LabelValues(_ context.Context, name string, matchers ...*labels.Matcher) ([]string, annotations.Annotations, error) {
+	q.storage.mtx.RLock()
+	defer q.storage.mtx.RUnlock()
+
+	values := map[string]struct{}{}
+	for _, entry := range q.storage.series {
+		if v := entry.lbls.Get(name); v != "" && matchesAll(entry.lbls, matchers) {
+			values[v] = struct{}{}
+		}
+	}
+	return sortedKeys(values), nil, nil
+}
+
+func (q *memoryQuerier) LabelNames(_ context.Context, matchers ...*labels.Matcher) ([]string, annotations.Annotations, error) {
+	q.storage.mtx.RLock()
+	defer q.storage.mtx.RUnlock()
+
+	names := map[string]struct{}{}
+	for _, entry := range q.storage.series {
+		if !matchesAll(entry.lbls, matchers) {
+			continue
+		}
+		entry.lbls.Range(func(l labels.Label) {
+			names[l.Name] = struct{}{}
+		})
+	}
+	return sortedKeys(names), nil, nil
+}
+
+func (q *memoryQuerier) Close() error {
+	return nil
+}
+
+func matchesAll(lbls labels.Labels, matchers []*labels.Matcher) bool {
+	for _, m := range matchers {
+		if !m.Matches(lbls.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+func sortedKeys(m map[string]struct{}) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
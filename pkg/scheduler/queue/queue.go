@@ -41,6 +41,12 @@ type SchedulerRequest struct {
 	StatsEnabled              bool
 	AdditionalQueueDimensions []string
 
+	// Deprioritized requests are dequeued only when there's no other request enqueued for the tenant.
+	Deprioritized bool
+
+	// PriorityClass of the request. The requests of a tenant are dequeued with weighted fairness across priority classes.
+	PriorityClass string
+
 	EnqueueTime time.Time
 
 	Ctx        context.Context
@@ -82,6 +88,7 @@ type RequestQueue struct {
 
 	maxOutstandingPerTenant          int
 	additionalQueueDimensionsEnabled bool
+	priorityClassWeights             map[string]int
 	forgetDelay                      time.Duration
 
 	connectedQuerierWorkers *atomic.Int32
@@ -124,6 +131,7 @@ func NewRequestQueue(
 	log log.Logger,
 	maxOutstandingPerTenant int,
 	additionalQueueDimensionsEnabled bool,
+	priorityClassWeights map[string]int,
 	forgetDelay time.Duration,
 	queueLength *prometheus.GaugeVec,
 	discardedRequests *prometheus.CounterVec,
@@ -133,6 +141,7 @@ func NewRequestQueue(
 		log:                              log,
 		maxOutstandingPerTenant:          maxOutstandingPerTenant,
 		additionalQueueDimensionsEnabled: additionalQueueDimensionsEnabled,
+		priorityClassWeights:             priorityClassWeights,
 		forgetDelay:                      forgetDelay,
 
 		connectedQuerierWorkers: atomic.NewInt32(0),
@@ -163,7 +172,7 @@ func (q *RequestQueue) starting(_ context.Context) error {
 
 func (q *RequestQueue) dispatcherLoop() {
 	stopping := false
-	queueBroker := newQueueBroker(q.maxOutstandingPerTenant, q.additionalQueueDimensionsEnabled, q.forgetDelay)
+	queueBroker := newQueueBroker(q.maxOutstandingPerTenant, q.additionalQueueDimensionsEnabled, q.priorityClassWeights, q.forgetDelay)
 	waitingGetNextRequestForQuerierCalls := list.New()
 
 	for {
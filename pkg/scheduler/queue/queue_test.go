@@ -87,6 +87,7 @@ func BenchmarkConcurrentQueueOperations(b *testing.B) {
 								log.NewNopLogger(),
 								maxOutstandingRequestsPerTenant,
 								true,
+								nil,
 								forgetQuerierDelay,
 								promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"tenant"}),
 								promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"tenant"}),
@@ -215,6 +216,7 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldGetRequestAfterReshardingBe
 	queue := NewRequestQueue(
 		log.NewNopLogger(),
 		1, true,
+		nil,
 		forgetDelay,
 		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
@@ -269,6 +271,7 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldReturnAfterContextCancelled
 		log.NewNopLogger(),
 		1,
 		true,
+		nil,
 		forgetDelay,
 		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
@@ -308,6 +311,7 @@ func TestRequestQueue_GetNextRequestForQuerier_ShouldReturnImmediatelyIfQuerierI
 		log.NewNopLogger(),
 		1,
 		true,
+		nil,
 		forgetDelay,
 		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
@@ -335,6 +339,7 @@ func TestRequestQueue_tryDispatchRequestToQuerier_ShouldReEnqueueAfterFailedSend
 		log.NewNopLogger(),
 		1,
 		true,
+		nil,
 		forgetDelay,
 		promauto.With(nil).NewGaugeVec(prometheus.GaugeOpts{}, []string{"user"}),
 		promauto.With(nil).NewCounterVec(prometheus.CounterOpts{}, []string{"user"}),
@@ -343,7 +348,7 @@ func TestRequestQueue_tryDispatchRequestToQuerier_ShouldReEnqueueAfterFailedSend
 
 	// bypassing queue dispatcher loop for direct usage of the queueBroker and
 	// passing a nextRequestForQuerierCall for a canceled querier connection
-	queueBroker := newQueueBroker(queue.maxOutstandingPerTenant, queue.additionalQueueDimensionsEnabled, queue.forgetDelay)
+	queueBroker := newQueueBroker(queue.maxOutstandingPerTenant, queue.additionalQueueDimensionsEnabled, queue.priorityClassWeights, queue.forgetDelay)
 	queueBroker.addQuerierConnection(querierID)
 
 	tenantMaxQueriers := 0 // no sharding
@@ -17,6 +17,10 @@ type TenantID string
 
 const emptyTenantID = TenantID("")
 
+// priorityClassQueueNamePrefix is the prefix of the names of the queue nodes holding the requests of a priority class.
+// Tenant IDs can't contain a colon, so the priority class nodes never clash with tenant nodes.
+const priorityClassQueueNamePrefix = "priority-class:"
+
 type QuerierID string
 type querierIDSlice []QuerierID
 
@@ -106,11 +110,23 @@ type queueBroker struct {
 
 	maxTenantQueueSize               int
 	additionalQueueDimensionsEnabled bool
+
+	// priorityClassWeights holds the weight of each priority class. Requests are enqueued
+	// in priority class queue nodes only for the priority classes listed here.
+	priorityClassWeights map[string]int
 }
 
-func newQueueBroker(maxTenantQueueSize int, additionalQueueDimensionsEnabled bool, forgetDelay time.Duration) *queueBroker {
+func newQueueBroker(maxTenantQueueSize int, additionalQueueDimensionsEnabled bool, priorityClassWeights map[string]int, forgetDelay time.Duration) *queueBroker {
+	tenantQueuesTree := NewTreeQueue("root")
+	if len(priorityClassWeights) > 0 {
+		tenantQueuesTree.childQueueWeights = make(map[string]int, len(priorityClassWeights))
+		for class, weight := range priorityClassWeights {
+			tenantQueuesTree.childQueueWeights[priorityClassQueueName(class)] = weight
+		}
+	}
+
 	return &queueBroker{
-		tenantQueuesTree: NewTreeQueue("root"),
+		tenantQueuesTree: tenantQueuesTree,
 		tenantQuerierAssignments: tenantQuerierAssignments{
 			queriersByID:       map[QuerierID]*querierConn{},
 			querierIDsSorted:   nil,
@@ -121,6 +137,7 @@ func newQueueBroker(maxTenantQueueSize int, additionalQueueDimensionsEnabled boo
 		},
 		maxTenantQueueSize:               maxTenantQueueSize,
 		additionalQueueDimensionsEnabled: additionalQueueDimensionsEnabled,
+		priorityClassWeights:             priorityClassWeights,
 	}
 }
 
@@ -170,14 +187,28 @@ func (qb *queueBroker) enqueueRequestFront(request *tenantRequest, tenantMaxQuer
 }
 
 func (qb *queueBroker) makeQueuePath(request *tenantRequest) (QueuePath, error) {
+	queuePath := QueuePath{string(request.tenantID)}
+
+	schedulerRequest, ok := request.req.(*SchedulerRequest)
+	if !ok {
+		// request.req is a frontend/v1.request
+		return queuePath, nil
+	}
+
+	if schedulerRequest.Deprioritized {
+		queuePath = append(queuePath, DeprioritizedQueueName)
+	}
+	if _, ok := qb.priorityClassWeights[schedulerRequest.PriorityClass]; ok {
+		queuePath = append(queuePath, priorityClassQueueName(schedulerRequest.PriorityClass))
+	}
 	if qb.additionalQueueDimensionsEnabled {
-		if schedulerRequest, ok := request.req.(*SchedulerRequest); ok {
-			return append(QueuePath{string(request.tenantID)}, schedulerRequest.AdditionalQueueDimensions...), nil
-		}
+		queuePath = append(queuePath, schedulerRequest.AdditionalQueueDimensions...)
 	}
+	return queuePath, nil
+}
 
-	// else request.req is a frontend/v1.request, or additional queue dimensions are disabled
-	return QueuePath{string(request.tenantID)}, nil
+func priorityClassQueueName(class string) string {
+	return priorityClassQueueNamePrefix + class
 }
 
 func (qb *queueBroker) dequeueRequestForQuerier(lastTenantIndex int, querierID QuerierID) (*tenantRequest, *queueTenant, int, error) {
@@ -21,7 +21,7 @@ import (
 )
 
 func TestQueues(t *testing.T) {
-	qb := newQueueBroker(0, true, 0)
+	qb := newQueueBroker(0, true, nil, 0)
 	assert.NotNil(t, qb)
 	assert.NoError(t, isConsistent(qb))
 
@@ -81,7 +81,7 @@ func TestQueues(t *testing.T) {
 
 func TestQueuesRespectMaxTenantQueueSizeWithSubQueues(t *testing.T) {
 	maxTenantQueueSize := 100
-	qb := newQueueBroker(maxTenantQueueSize, true, 0)
+	qb := newQueueBroker(maxTenantQueueSize, true, nil, 0)
 	additionalQueueDimensions := map[int][]string{
 		0: nil,
 		1: {"ingester"},
@@ -141,8 +141,76 @@ func TestQueuesRespectMaxTenantQueueSizeWithSubQueues(t *testing.T) {
 	assert.ErrorIs(t, err, ErrTooManyRequests)
 }
 
+func TestQueuesDequeueDeprioritizedRequestsLast(t *testing.T) {
+	for _, additionalQueueDimensionsEnabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("additional queue dimensions enabled: %t", additionalQueueDimensionsEnabled), func(t *testing.T) {
+			qb := newQueueBroker(10, additionalQueueDimensionsEnabled, nil, 0)
+			qb.addQuerierConnection("querier-1")
+
+			deprioritized := &SchedulerRequest{QueryID: 1, AdditionalQueueDimensions: []string{"ingester"}, Deprioritized: true}
+			first := &SchedulerRequest{QueryID: 2, AdditionalQueueDimensions: []string{"ingester"}}
+			second := &SchedulerRequest{QueryID: 3, AdditionalQueueDimensions: []string{"store-gateway"}}
+
+			for _, req := range []*SchedulerRequest{deprioritized, first, second} {
+				require.NoError(t, qb.enqueueRequestBack(&tenantRequest{tenantID: "tenant-1", req: req}, 0))
+			}
+
+			expectedPath := QueuePath{"tenant-1", DeprioritizedQueueName}
+			if additionalQueueDimensionsEnabled {
+				expectedPath = append(expectedPath, "ingester")
+			}
+			assert.Equal(t, 1, qb.tenantQueuesTree.getNode(expectedPath).LocalQueueLen())
+
+			lastTenantIndex := -1
+			for _, expected := range []*SchedulerRequest{first, second, deprioritized} {
+				req, _, idx, err := qb.dequeueRequestForQuerier(lastTenantIndex, "querier-1")
+				require.NoError(t, err)
+				require.NotNil(t, req)
+				assert.Equal(t, expected, req.req)
+				lastTenantIndex = idx
+			}
+		})
+	}
+}
+
+func TestQueuesDequeueWithPriorityClassWeights(t *testing.T) {
+	qb := newQueueBroker(10, false, map[string]int{"alerting": 2, "ad-hoc": 1}, 0)
+	qb.addQuerierConnection("querier-1")
+
+	adHoc := []*SchedulerRequest{
+		{QueryID: 1, PriorityClass: "ad-hoc"},
+		{QueryID: 2, PriorityClass: "ad-hoc"},
+		{QueryID: 3, PriorityClass: "ad-hoc"},
+	}
+	alerting := []*SchedulerRequest{
+		{QueryID: 4, PriorityClass: "alerting"},
+		{QueryID: 5, PriorityClass: "alerting"},
+		{QueryID: 6, PriorityClass: "alerting"},
+	}
+	// Requests with a priority class whose weight is not configured are enqueued in the tenant queue.
+	unclassified := &SchedulerRequest{QueryID: 7, PriorityClass: "dashboard"}
+
+	for _, req := range append(append(adHoc, alerting...), unclassified) {
+		require.NoError(t, qb.enqueueRequestBack(&tenantRequest{tenantID: "tenant-1", req: req}, 0))
+	}
+
+	assert.Equal(t, 3, qb.tenantQueuesTree.getNode(QueuePath{"tenant-1", "priority-class:ad-hoc"}).LocalQueueLen())
+	assert.Equal(t, 3, qb.tenantQueuesTree.getNode(QueuePath{"tenant-1", "priority-class:alerting"}).LocalQueueLen())
+	assert.Equal(t, 1, qb.tenantQueuesTree.getNode(QueuePath{"tenant-1"}).LocalQueueLen())
+
+	lastTenantIndex := -1
+	for _, expected := range []*SchedulerRequest{unclassified, adHoc[0], alerting[0], alerting[1], adHoc[1], alerting[2], adHoc[2]} {
+		req, _, idx, err := qb.dequeueRequestForQuerier(lastTenantIndex, "querier-1")
+		require.NoError(t, err)
+		require.NotNil(t, req)
+		assert.Equal(t, expected, req.req)
+		lastTenantIndex = idx
+	}
+	assert.True(t, qb.isEmpty())
+}
+
 func TestQueuesOnTerminatingQuerier(t *testing.T) {
-	qb := newQueueBroker(0, true, 0)
+	qb := newQueueBroker(0, true, nil, 0)
 	assert.NotNil(t, qb)
 	assert.NoError(t, isConsistent(qb))
 
@@ -172,7 +240,7 @@ func TestQueuesOnTerminatingQuerier(t *testing.T) {
 }
 
 func TestQueuesWithQueriers(t *testing.T) {
-	qb := newQueueBroker(0, true, 0)
+	qb := newQueueBroker(0, true, nil, 0)
 	assert.NotNil(t, qb)
 	assert.NoError(t, isConsistent(qb))
 
@@ -251,7 +319,7 @@ func TestQueuesConsistency(t *testing.T) {
 
 	for testName, testData := range tests {
 		t.Run(testName, func(t *testing.T) {
-			qb := newQueueBroker(0, true, testData.forgetDelay)
+			qb := newQueueBroker(0, true, nil, testData.forgetDelay)
 			assert.NotNil(t, qb)
 			assert.NoError(t, isConsistent(qb))
 
@@ -302,7 +370,7 @@ func TestQueues_ForgetDelay(t *testing.T) {
 	)
 
 	now := time.Now()
-	qb := newQueueBroker(0, true, forgetDelay)
+	qb := newQueueBroker(0, true, nil, forgetDelay)
 	assert.NotNil(t, qb)
 	assert.NoError(t, isConsistent(qb))
 
@@ -394,7 +462,7 @@ func TestQueues_ForgetDelay_ShouldCorrectlyHandleQuerierReconnectingBeforeForget
 	)
 
 	now := time.Now()
-	qb := newQueueBroker(0, true, forgetDelay)
+	qb := newQueueBroker(0, true, nil, forgetDelay)
 	assert.NotNil(t, qb)
 	assert.NoError(t, isConsistent(qb))
 
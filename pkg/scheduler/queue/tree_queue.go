@@ -11,6 +11,10 @@ type QueueIndex int     //nolint:revive // disallows types beginning with packag
 
 const localQueueIndex = -1
 
+// DeprioritizedQueueName is the name of the child queue nodes holding deprioritized items.
+// A deprioritized child node is dequeued from only when there is no other item enqueued in its parent node.
+const DeprioritizedQueueName = "deprioritized"
+
 // TreeQueue is a hierarchical queue implementation with an arbitrary amount of child queues.
 //
 // TreeQueue internally maintains round-robin fair queuing across all of its queue dimensions.
@@ -22,7 +26,8 @@ const localQueueIndex = -1
 // When dequeuing from a given node, the node will round-robin equally between dequeuing directly
 // from its own local queue and dequeuing recursively from its list of child TreeQueues.
 // No queue at a given level of the tree is dequeued from consecutively unless all others
-// at the same level of the tree are empty down to the leaf node.
+// at the same level of the tree are empty down to the leaf node, or the child queue has been
+// given a weight greater than 1, in which case it is dequeued from up to weight times in a row.
 type TreeQueue struct {
 	// name of the tree node will be set to its segment of the queue path
 	name                   string
@@ -30,6 +35,12 @@ type TreeQueue struct {
 	currentChildQueueIndex int
 	childQueueOrder        []string
 	childQueueMap          map[string]*TreeQueue
+
+	// childQueueWeights holds the weights of child nodes by name; child nodes not listed have weight 1.
+	// The weights are inherited by all descendant nodes.
+	childQueueWeights map[string]int
+	// currentChildQueueDequeues is the number of items consecutively dequeued from the current child node.
+	currentChildQueueDequeues int
 }
 
 func NewTreeQueue(name string) *TreeQueue {
@@ -142,6 +153,7 @@ func (q *TreeQueue) getOrAddNode(childPath QueuePath) (*TreeQueue, error) {
 		// no child node matches next path segment
 		// create next child before recurring
 		childQueue = NewTreeQueue(childPath[0])
+		childQueue.childQueueWeights = q.childQueueWeights
 
 		// add new child queue to ordered list for round-robining;
 		// in order to maintain round-robin order as nodes are created and deleted,
@@ -241,6 +253,12 @@ func (q *TreeQueue) Dequeue() any {
 			// dequeuing from child queue node;
 			// pick the child node whose turn it is and recur
 			childQueueName := q.childQueueOrder[q.currentChildQueueIndex]
+			if childQueueName == DeprioritizedQueueName && q.hasItemsOutsideDeprioritizedQueue() {
+				// skip the deprioritized child node while there's anything else to dequeue
+				q.wrapIndex(true)
+				continue
+			}
+
 			childQueue := q.childQueueMap[childQueueName]
 			v = childQueue.Dequeue()
 
@@ -248,6 +266,9 @@ func (q *TreeQueue) Dequeue() any {
 			if childQueue.IsEmpty() {
 				// deleteNode wraps index for us
 				q.deleteNode(QueuePath{childQueueName})
+			} else if v != nil && q.currentChildQueueDequeues+1 < q.childQueueWeight(childQueueName) {
+				// the child node keeps its turn until it has been dequeued from as many times as its weight
+				q.currentChildQueueDequeues++
 			} else {
 				q.wrapIndex(true)
 			}
@@ -256,6 +277,29 @@ func (q *TreeQueue) Dequeue() any {
 	return v
 }
 
+// hasItemsOutsideDeprioritizedQueue returns whether the node has items enqueued
+// in its local queue or in any child node other than the deprioritized one.
+func (q *TreeQueue) hasItemsOutsideDeprioritizedQueue() bool {
+	if q.LocalQueueLen() > 0 {
+		return true
+	}
+	// empty child nodes are deleted during dequeue, so any other child node has items
+	for name := range q.childQueueMap {
+		if name != DeprioritizedQueueName {
+			return true
+		}
+	}
+	return false
+}
+
+// childQueueWeight returns the weight of the child node with the given name.
+func (q *TreeQueue) childQueueWeight(childQueueName string) int {
+	if weight, ok := q.childQueueWeights[childQueueName]; ok && weight > 1 {
+		return weight
+	}
+	return 1
+}
+
 // deleteNode removes a child node from the tree and the childQueueOrder and corrects the indices.
 func (q *TreeQueue) deleteNode(childPath QueuePath) bool {
 	if len(childPath) == 0 {
@@ -283,6 +327,7 @@ func (q *TreeQueue) deleteNode(childPath QueuePath) bool {
 }
 
 func (q *TreeQueue) wrapIndex(increment bool) {
+	q.currentChildQueueDequeues = 0
 	if increment {
 		q.currentChildQueueIndex++
 	}
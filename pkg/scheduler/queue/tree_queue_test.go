@@ -471,3 +471,68 @@ func getChildPathFromQueueItem(v any) QueuePath {
 	itemPathPrefix := itemPath[1 : len(itemPath)-1] // strip value from the end
 	return itemPathPrefix
 }
+
+func TestDequeueDeprioritizedQueue(t *testing.T) {
+	root := NewTreeQueue("root")
+
+	require.NoError(t, root.EnqueueBackByPath(QueuePath{DeprioritizedQueueName}, "deprioritized-1"))
+	require.NoError(t, root.EnqueueBackByPath(QueuePath{DeprioritizedQueueName}, "deprioritized-2"))
+	require.NoError(t, root.EnqueueBackByPath(QueuePath{}, "local-1"))
+	require.NoError(t, root.EnqueueBackByPath(QueuePath{"a"}, "a-1"))
+	require.NoError(t, root.EnqueueBackByPath(QueuePath{"a"}, "a-2"))
+
+	// the deprioritized node is dequeued from only once all other nodes are empty
+	require.Equal(t, "local-1", root.Dequeue())
+	require.Equal(t, "a-1", root.Dequeue())
+
+	// items enqueued while the deprioritized node is waiting still take precedence
+	require.NoError(t, root.EnqueueBackByPath(QueuePath{"b"}, "b-1"))
+	require.Equal(t, "a-2", root.Dequeue())
+	require.Equal(t, "b-1", root.Dequeue())
+
+	require.Equal(t, "deprioritized-1", root.Dequeue())
+	require.NoError(t, root.EnqueueBackByPath(QueuePath{}, "local-2"))
+	require.Equal(t, "local-2", root.Dequeue())
+	require.Equal(t, "deprioritized-2", root.Dequeue())
+
+	require.True(t, root.IsEmpty())
+	require.Nil(t, root.Dequeue())
+}
+
+func TestDequeueWeightedChildQueues(t *testing.T) {
+	root := NewTreeQueue("root")
+	root.childQueueWeights = map[string]int{"a": 3, "b": 1}
+
+	for _, item := range []string{"a-1", "a-2", "a-3", "a-4"} {
+		require.NoError(t, root.EnqueueBackByPath(QueuePath{"a"}, item))
+	}
+	for _, item := range []string{"b-1", "b-2", "b-3"} {
+		require.NoError(t, root.EnqueueBackByPath(QueuePath{"b"}, item))
+	}
+
+	// the child node with weight 3 is dequeued from up to 3 times in a row
+	for _, expected := range []string{"a-1", "a-2", "a-3", "b-1", "a-4", "b-2", "b-3"} {
+		require.Equal(t, expected, root.Dequeue())
+	}
+
+	require.True(t, root.IsEmpty())
+	require.Nil(t, root.Dequeue())
+}
+
+func TestDequeueWeightedChildQueues_WeightsAreInherited(t *testing.T) {
+	root := NewTreeQueue("root")
+	root.childQueueWeights = map[string]int{"x": 2}
+
+	for _, item := range []string{"x-1", "x-2", "x-3"} {
+		require.NoError(t, root.EnqueueBackByPath(QueuePath{"tenant", "x"}, item))
+	}
+	for _, item := range []string{"y-1", "y-2"} {
+		require.NoError(t, root.EnqueueBackByPath(QueuePath{"tenant", "y"}, item))
+	}
+
+	for _, expected := range []string{"x-1", "x-2", "y-1", "x-3", "y-2"} {
+		require.Equal(t, expected, root.DequeueByPath(QueuePath{"tenant"}))
+	}
+
+	require.True(t, root.IsEmpty())
+}
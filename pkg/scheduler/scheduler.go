@@ -8,8 +8,10 @@ package scheduler
 import (
 	"context"
 	"flag"
+	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
@@ -17,6 +19,7 @@ import (
 	"github.com/go-kit/log"
 	"github.com/go-kit/log/level"
 	"github.com/grafana/dskit/cancellation"
+	"github.com/grafana/dskit/flagext"
 	"github.com/grafana/dskit/grpcclient"
 	"github.com/grafana/dskit/httpgrpc"
 	"github.com/grafana/dskit/middleware"
@@ -34,6 +37,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/grafana/mimir/pkg/frontend/v2/frontendv2pb"
+	"github.com/grafana/mimir/pkg/querier/api"
 	"github.com/grafana/mimir/pkg/scheduler/queue"
 	"github.com/grafana/mimir/pkg/scheduler/schedulerdiscovery"
 	"github.com/grafana/mimir/pkg/scheduler/schedulerpb"
@@ -52,6 +56,9 @@ type Scheduler struct {
 	cfg Config
 	log log.Logger
 
+	// priorityClassWeights holds the weight of each enabled query priority class.
+	priorityClassWeights map[string]int
+
 	limits Limits
 
 	connectedFrontendsMu sync.Mutex
@@ -79,6 +86,9 @@ type Scheduler struct {
 	connectedFrontendClients prometheus.GaugeFunc
 	queueDuration            *prometheus.HistogramVec
 	inflightRequests         prometheus.Summary
+
+	priorityClassQueueLength   *prometheus.GaugeVec
+	priorityClassQueueDuration *prometheus.HistogramVec
 }
 
 type requestKey struct {
@@ -96,9 +106,10 @@ type connectedFrontend struct {
 }
 
 type Config struct {
-	MaxOutstandingPerTenant               int           `yaml:"max_outstanding_requests_per_tenant"`
-	AdditionalQueryQueueDimensionsEnabled bool          `yaml:"additional_query_queue_dimensions_enabled" category:"experimental"`
-	QuerierForgetDelay                    time.Duration `yaml:"querier_forget_delay" category:"experimental"`
+	MaxOutstandingPerTenant               int                    `yaml:"max_outstanding_requests_per_tenant"`
+	AdditionalQueryQueueDimensionsEnabled bool                   `yaml:"additional_query_queue_dimensions_enabled" category:"experimental"`
+	QuerierForgetDelay                    time.Duration          `yaml:"querier_forget_delay" category:"experimental"`
+	PriorityClassWeights                  flagext.StringSliceCSV `yaml:"priority_class_weights" category:"experimental"`
 
 	GRPCClientConfig grpcclient.Config         `yaml:"grpc_client_config" doc:"description=This configures the gRPC client used to report errors back to the query-frontend."`
 	ServiceDiscovery schedulerdiscovery.Config `yaml:",inline"`
@@ -108,23 +119,58 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet, logger log.Logger) {
 	f.IntVar(&cfg.MaxOutstandingPerTenant, "query-scheduler.max-outstanding-requests-per-tenant", 100, "Maximum number of outstanding requests per tenant per query-scheduler. In-flight requests above this limit will fail with HTTP response status code 429.")
 	f.BoolVar(&cfg.AdditionalQueryQueueDimensionsEnabled, "query-scheduler.additional-query-queue-dimensions-enabled", false, "Enqueue query requests with additional queue dimensions to split tenant request queues into subqueues. This enables separate requests to proceed from a tenant's subqueues even when other subqueues are blocked on slow query requests. Must be set on both query-frontend and scheduler to take effect. (default false)")
 	f.DurationVar(&cfg.QuerierForgetDelay, "query-scheduler.querier-forget-delay", 0, "If a querier disconnects without sending notification about graceful shutdown, the query-scheduler will keep the querier in the tenant's shard until the forget delay has passed. This feature is useful to reduce the blast radius when shuffle-sharding is enabled.")
+	f.Var(&cfg.PriorityClassWeights, "query-scheduler.priority-class-weights", fmt.Sprintf("Comma-separated list of <priority class>:<weight> pairs. When set, the query requests of each tenant are enqueued in a subqueue per priority class, taken from the %s header set by internal callers like the ruler or by the clients of the tenants allowed via -query-frontend.query-priority-classes-allowed, and are dequeued with weighted fairness across priority classes. Supported priority classes: %s. Requests without a priority class, or with a priority class not listed, are dequeued with weight 1. For example: alerting:4,dashboard:2,ad-hoc:1,export:1", api.QueryPriorityClassHeader, strings.Join(api.QueryPriorityClasses, ", ")))
 
 	cfg.GRPCClientConfig.RegisterFlagsWithPrefix("query-scheduler.grpc-client-config", f)
 	cfg.ServiceDiscovery.RegisterFlags(f, logger)
 }
 
 func (cfg *Config) Validate() error {
+	if _, err := cfg.parsePriorityClassWeights(); err != nil {
+		return err
+	}
 	return cfg.ServiceDiscovery.Validate()
 }
 
+// parsePriorityClassWeights returns the configured weight of each query priority class.
+func (cfg *Config) parsePriorityClassWeights() (map[string]int, error) {
+	if len(cfg.PriorityClassWeights) == 0 {
+		return nil, nil
+	}
+
+	weights := make(map[string]int, len(cfg.PriorityClassWeights))
+	for _, entry := range cfg.PriorityClassWeights {
+		class, weightStr, ok := strings.Cut(entry, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid query priority class weight %q: expected <priority class>:<weight>", entry)
+		}
+		if !api.IsValidQueryPriorityClass(class) {
+			return nil, fmt.Errorf("invalid query priority class %q: supported priority classes are %s", class, strings.Join(api.QueryPriorityClasses, ", "))
+		}
+		if _, exists := weights[class]; exists {
+			return nil, fmt.Errorf("duplicated weight for query priority class %q", class)
+		}
+		weight, err := strconv.Atoi(weightStr)
+		if err != nil || weight <= 0 {
+			return nil, fmt.Errorf("invalid weight %q for query priority class %q: the weight must be a positive integer", weightStr, class)
+		}
+		weights[class] = weight
+	}
+	return weights, nil
+}
+
 // NewScheduler creates a new Scheduler.
 func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer prometheus.Registerer) (*Scheduler, error) {
-	var err error
+	priorityClassWeights, err := cfg.parsePriorityClassWeights()
+	if err != nil {
+		return nil, err
+	}
 
 	s := &Scheduler{
-		cfg:    cfg,
-		log:    log,
-		limits: limits,
+		cfg:                  cfg,
+		log:                  log,
+		limits:               limits,
+		priorityClassWeights: priorityClassWeights,
 
 		pendingRequests:    map[requestKey]*queue.SchedulerRequest{},
 		connectedFrontends: map[string]*connectedFrontend{},
@@ -148,13 +194,22 @@ func NewScheduler(cfg Config, limits Limits, log log.Logger, registerer promethe
 		Name: "cortex_query_scheduler_enqueue_duration_seconds",
 		Help: "Time spent by requests waiting to join the queue or be rejected.",
 	})
-	s.requestQueue = queue.NewRequestQueue(s.log, cfg.MaxOutstandingPerTenant, cfg.AdditionalQueryQueueDimensionsEnabled, cfg.QuerierForgetDelay, s.queueLength, s.discardedRequests, enqueueDuration)
+	s.requestQueue = queue.NewRequestQueue(s.log, cfg.MaxOutstandingPerTenant, cfg.AdditionalQueryQueueDimensionsEnabled, priorityClassWeights, cfg.QuerierForgetDelay, s.queueLength, s.discardedRequests, enqueueDuration)
 
 	s.queueDuration = promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
 		Name:    "cortex_query_scheduler_queue_duration_seconds",
 		Help:    "Time spent by requests in queue before getting picked up by a querier.",
 		Buckets: prometheus.DefBuckets,
 	}, []string{"user", "additional_queue_dimensions"})
+	s.priorityClassQueueLength = promauto.With(registerer).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "cortex_query_scheduler_priority_class_queue_length",
+		Help: "Number of queries in the queue, by priority class.",
+	}, []string{"priority_class"})
+	s.priorityClassQueueDuration = promauto.With(registerer).NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "cortex_query_scheduler_priority_class_queue_duration_seconds",
+		Help:    "Time spent by requests in queue before getting picked up by a querier, by priority class.",
+		Buckets: prometheus.DefBuckets,
+	}, []string{"priority_class"})
 	s.connectedQuerierClients = promauto.With(registerer).NewGaugeFunc(prometheus.GaugeOpts{
 		Name: "cortex_query_scheduler_connected_querier_clients",
 		Help: "Number of querier worker clients currently connected to the query-scheduler.",
@@ -334,6 +389,8 @@ func (s *Scheduler) enqueueRequest(requestContext context.Context, frontendAddr
 		Request:                   msg.HttpRequest,
 		StatsEnabled:              msg.StatsEnabled,
 		AdditionalQueueDimensions: msg.AdditionalQueueDimensions,
+		Deprioritized:             isDeprioritizedRequest(msg.HttpRequest),
+		PriorityClass:             s.queryPriorityClass(msg.HttpRequest),
 	}
 
 	now := time.Now()
@@ -354,6 +411,10 @@ func (s *Scheduler) enqueueRequest(requestContext context.Context, frontendAddr
 	return s.requestQueue.EnqueueRequestToDispatcher(userID, req, maxQueriers, func() {
 		shouldCancel = false
 
+		if req.PriorityClass != "" {
+			s.priorityClassQueueLength.WithLabelValues(req.PriorityClass).Inc()
+		}
+
 		s.pendingRequestsMu.Lock()
 		s.pendingRequests[requestKey{frontendAddr: frontendAddr, queryID: msg.QueryID}] = req
 		s.pendingRequestsMu.Unlock()
@@ -406,6 +467,10 @@ func (s *Scheduler) QuerierLoop(querier schedulerpb.SchedulerForQuerier_QuerierL
 		queueTime := time.Since(r.EnqueueTime)
 		additionalQueueDimensionLabels := strings.Join(r.AdditionalQueueDimensions, ":")
 		s.queueDuration.WithLabelValues(r.UserID, additionalQueueDimensionLabels).Observe(queueTime.Seconds())
+		if r.PriorityClass != "" {
+			s.priorityClassQueueLength.WithLabelValues(r.PriorityClass).Dec()
+			s.priorityClassQueueDuration.WithLabelValues(r.PriorityClass).Observe(queueTime.Seconds())
+		}
 		r.QueueSpan.Finish()
 
 		/*
@@ -613,3 +678,36 @@ func (s *Scheduler) RingHandler(w http.ResponseWriter, req *http.Request) {
 		</html>`
 	util.WriteHTMLResponse(w, ringDisabledPage)
 }
+
+// isDeprioritizedRequest returns whether the query-frontend asked to enqueue the request with a lower priority.
+func isDeprioritizedRequest(req *httpgrpc.HTTPRequest) bool {
+	if req == nil {
+		return false
+	}
+	for _, header := range req.Headers {
+		if strings.EqualFold(header.Key, api.DeprioritizedQueryHeader) {
+			return len(header.Values) > 0 && header.Values[0] == "true"
+		}
+	}
+	return false
+}
+
+// queryPriorityClass returns the priority class the query-frontend enqueued the request with,
+// or an empty string if the priority class is missing or its weight is not configured.
+func (s *Scheduler) queryPriorityClass(req *httpgrpc.HTTPRequest) string {
+	if req == nil || len(s.priorityClassWeights) == 0 {
+		return ""
+	}
+	for _, header := range req.Headers {
+		if strings.EqualFold(header.Key, api.QueryPriorityClassHeader) {
+			if len(header.Values) == 0 {
+				return ""
+			}
+			if _, ok := s.priorityClassWeights[header.Values[0]]; ok {
+				return header.Values[0]
+			}
+			return ""
+		}
+	}
+	return ""
+}
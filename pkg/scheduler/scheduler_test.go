@@ -32,6 +32,7 @@ import (
 	"google.golang.org/grpc/status"
 
 	"github.com/grafana/mimir/pkg/frontend/v2/frontendv2pb"
+	"github.com/grafana/mimir/pkg/querier/api"
 	"github.com/grafana/mimir/pkg/scheduler/schedulerpb"
 	"github.com/grafana/mimir/pkg/util"
 	"github.com/grafana/mimir/pkg/util/httpgrpcutil"
@@ -49,6 +50,10 @@ func setupScheduler(t *testing.T, reg prometheus.Registerer) (*Scheduler, schedu
 	flagext.DefaultValues(&cfg)
 	cfg.MaxOutstandingPerTenant = testMaxOutstandingPerTenant
 
+	return setupSchedulerWithConfig(t, cfg, reg)
+}
+
+func setupSchedulerWithConfig(t *testing.T, cfg Config, reg prometheus.Registerer) (*Scheduler, schedulerpb.SchedulerForFrontendClient, schedulerpb.SchedulerForQuerierClient) {
 	s, err := NewScheduler(cfg, &limits{queriers: 2}, log.NewNopLogger(), reg)
 	require.NoError(t, err)
 
@@ -502,6 +507,103 @@ func TestSchedulerQueueMetrics(t *testing.T) {
 	`), "cortex_query_scheduler_queue_length"))
 }
 
+func TestSchedulerPriorityClassMetrics(t *testing.T) {
+	reg := prometheus.NewPedanticRegistry()
+
+	cfg := Config{}
+	flagext.DefaultValues(&cfg)
+	cfg.MaxOutstandingPerTenant = testMaxOutstandingPerTenant
+	cfg.PriorityClassWeights = []string{"alerting:2", "ad-hoc:1"}
+
+	scheduler, frontendClient, querierClient := setupSchedulerWithConfig(t, cfg, reg)
+
+	frontendLoop := initFrontendLoop(t, frontendClient, "frontend-12345")
+	for queryID, class := range []string{"alerting", "dashboard", ""} {
+		req := &httpgrpc.HTTPRequest{Method: "GET", Url: "/hello"}
+		if class != "" {
+			req.Headers = []*httpgrpc.Header{{Key: api.QueryPriorityClassHeader, Values: []string{class}}}
+		}
+		frontendToScheduler(t, frontendLoop, &schedulerpb.FrontendToScheduler{
+			Type:        schedulerpb.ENQUEUE,
+			QueryID:     uint64(queryID),
+			UserID:      "test",
+			HttpRequest: req,
+		})
+	}
+
+	// Only the requests of the priority classes with a configured weight are tracked.
+	require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_scheduler_priority_class_queue_length Number of queries in the queue, by priority class.
+		# TYPE cortex_query_scheduler_priority_class_queue_length gauge
+		cortex_query_scheduler_priority_class_queue_length{priority_class="alerting"} 1
+	`), "cortex_query_scheduler_priority_class_queue_length"))
+
+	querierLoop := initQuerierLoop(t, querierClient, "querier-1")
+	for i := 0; i < 3; i++ {
+		_, err := querierLoop.Recv()
+		require.NoError(t, err)
+		require.NoError(t, querierLoop.Send(&schedulerpb.QuerierToScheduler{}))
+	}
+
+	require.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_query_scheduler_priority_class_queue_length Number of queries in the queue, by priority class.
+		# TYPE cortex_query_scheduler_priority_class_queue_length gauge
+		cortex_query_scheduler_priority_class_queue_length{priority_class="alerting"} 0
+	`), "cortex_query_scheduler_priority_class_queue_length"))
+	require.Equal(t, 1, promtest.CollectAndCount(scheduler.priorityClassQueueDuration))
+}
+
+func TestConfig_Validate_PriorityClassWeights(t *testing.T) {
+	tests := map[string]struct {
+		weights         []string
+		expectedWeights map[string]int
+		expectedErr     string
+	}{
+		"empty": {
+			weights: nil,
+		},
+		"valid": {
+			weights:         []string{"alerting:4", "dashboard:2", "ad-hoc:1"},
+			expectedWeights: map[string]int{"alerting": 4, "dashboard": 2, "ad-hoc": 1},
+		},
+		"missing weight": {
+			weights:     []string{"alerting"},
+			expectedErr: `invalid query priority class weight "alerting"`,
+		},
+		"unknown priority class": {
+			weights:     []string{"unknown:1"},
+			expectedErr: `invalid query priority class "unknown"`,
+		},
+		"duplicated priority class": {
+			weights:     []string{"alerting:1", "alerting:2"},
+			expectedErr: `duplicated weight for query priority class "alerting"`,
+		},
+		"invalid weight": {
+			weights:     []string{"alerting:0"},
+			expectedErr: `invalid weight "0" for query priority class "alerting"`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := Config{}
+			flagext.DefaultValues(&cfg)
+			cfg.PriorityClassWeights = testData.weights
+
+			err := cfg.Validate()
+			if testData.expectedErr != "" {
+				require.ErrorContains(t, err, testData.expectedErr)
+				return
+			}
+			require.NoError(t, err)
+
+			weights, err := cfg.parsePriorityClassWeights()
+			require.NoError(t, err)
+			require.Equal(t, testData.expectedWeights, weights)
+		})
+	}
+}
+
 func TestSchedulerQuerierMetrics(t *testing.T) {
 	reg := prometheus.NewPedanticRegistry()
 	_, _, querierClient := setupScheduler(t, reg)
@@ -121,6 +121,8 @@ type Config struct {
 
 	StoragePrefix string `yaml:"storage_prefix"`
 
+	Encryption EncryptionConfig `yaml:"encryption"`
+
 	// Not used internally, meant to allow callers to wrap Buckets
 	// created using this config
 	Middlewares []func(objstore.InstrumentedBucket) (objstore.InstrumentedBucket, error) `yaml:"-"`
@@ -134,6 +136,7 @@ func (cfg *Config) RegisterFlags(f *flag.FlagSet) {
 func (cfg *Config) RegisterFlagsWithPrefixAndDefaultDirectory(prefix, dir string, f *flag.FlagSet) {
 	cfg.StorageBackendConfig.RegisterFlagsWithPrefixAndDefaultDirectory(prefix, dir, f)
 	f.StringVar(&cfg.StoragePrefix, prefix+"storage-prefix", "", "Prefix for all objects stored in the backend storage. For simplicity, it may only contain digits and English alphabet letters.")
+	cfg.Encryption.RegisterFlagsWithPrefix(prefix, f)
 }
 
 func (cfg *Config) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
@@ -148,6 +151,10 @@ func (cfg *Config) Validate() error {
 		}
 	}
 
+	if err := cfg.Encryption.Validate(); err != nil {
+		return err
+	}
+
 	return cfg.StorageBackendConfig.Validate()
 }
 
@@ -181,6 +188,13 @@ func NewClient(ctx context.Context, cfg Config, name string, logger log.Logger,
 		backendClient = NewPrefixedBucketClient(backendClient, cfg.StoragePrefix)
 	}
 
+	if cfg.Encryption.Enabled {
+		backendClient, err = NewEncryptedBucketClient(backendClient, cfg.Encryption)
+		if err != nil {
+			return nil, err
+		}
+	}
+
 	instrumentedClient := objstoretracing.WrapWithTraces(bucketWithMetrics(backendClient, name, reg))
 
 	// Wrap the client with any provided middleware
@@ -162,6 +162,26 @@ func TestClient_ConfigValidation(t *testing.T) {
 			cfg:           Config{StorageBackendConfig: StorageBackendConfig{Backend: Filesystem}, StoragePrefix: "."},
 			expectedError: ErrInvalidCharactersInStoragePrefix,
 		},
+		{
+			name:          "encryption enabled without key path",
+			cfg:           Config{StorageBackendConfig: StorageBackendConfig{Backend: Filesystem}, Encryption: EncryptionConfig{Enabled: true}},
+			expectedError: errEncryptionKeyPathMissing,
+		},
+		{
+			name:          "encryption key ID out of range",
+			cfg:           Config{StorageBackendConfig: StorageBackendConfig{Backend: Filesystem}, Encryption: EncryptionConfig{Enabled: true, KeyPath: "key", KeyID: 256}},
+			expectedError: errEncryptionKeyIDInvalid,
+		},
+		{
+			name:          "previous encryption key without ID",
+			cfg:           Config{StorageBackendConfig: StorageBackendConfig{Backend: Filesystem}, Encryption: EncryptionConfig{Enabled: true, KeyPath: "key", PreviousKeys: []string{"previous-key"}}},
+			expectedError: errInvalidPreviousKey,
+		},
+		{
+			name:          "previous encryption key with the ID of the current key",
+			cfg:           Config{StorageBackendConfig: StorageBackendConfig{Backend: Filesystem}, Encryption: EncryptionConfig{Enabled: true, KeyPath: "key", KeyID: 1, PreviousKeys: []string{"1:previous-key"}}},
+			expectedError: errEncryptionKeyIDReused,
+		},
 		{
 			name:          "unsupported backend",
 			cfg:           Config{StorageBackendConfig: StorageBackendConfig{Backend: "flash drive"}},
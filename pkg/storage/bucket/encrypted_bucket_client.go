@@ -0,0 +1,738 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucket
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/grafana/dskit/flagext"
+	lru "github.com/hashicorp/golang-lru/v2"
+	"github.com/pkg/errors"
+	"github.com/thanos-io/objstore"
+	"golang.org/x/crypto/hkdf"
+)
+
+const (
+	// encryptedObjectMagic is the magic number at the beginning of each encrypted object.
+	encryptedObjectMagic = "MENC"
+
+	encryptedObjectVersion1 = 1
+
+	// encryptionKeySize is the size of the master key, the per-tenant keys and the per-object data keys.
+	encryptionKeySize = 32
+
+	encryptionNonceSize = 12
+	encryptionTagSize   = 16
+
+	// wrappedDataKeySize is the size of the data key encrypted with the tenant key (nonce + ciphertext + tag).
+	wrappedDataKeySize = encryptionNonceSize + encryptionKeySize + encryptionTagSize
+
+	// encryptedObjectHeaderSize is the size of the header of each encrypted object: magic number, version,
+	// master key ID and wrapped data key.
+	encryptedObjectHeaderSize = len(encryptedObjectMagic) + 2 + wrappedDataKeySize
+
+	// maxEncryptionKeyID is the max ID of a master key, which is stored in a single byte in the object header.
+	maxEncryptionKeyID = 255
+
+	// encryptionSegmentSize is the size of the plaintext segments each object is split into. Each segment is
+	// encrypted and authenticated separately, so that range reads only need to decrypt the segments they cover.
+	encryptionSegmentSize = 64 * 1024
+
+	// encryptedSegmentSize is the size of a full encrypted segment.
+	encryptedSegmentSize = encryptionSegmentSize + encryptionTagSize
+
+	// dataKeysCacheSize is the max number of unwrapped data keys kept in memory, to avoid fetching the object
+	// header on each range read.
+	dataKeysCacheSize = 10000
+)
+
+var (
+	errEncryptionKeyPathMissing = errors.New("the encryption key path must be set when the object storage encryption is enabled")
+	errInvalidEncryptionKey     = errors.New("the encryption key must be a base64 encoded 32 bytes key")
+	errEncryptedObjectCorrupted = errors.New("encrypted object is corrupted or has been tampered with")
+	errEncryptedObjectNoTenant  = errors.New("encrypted object is not stored under a tenant prefix")
+	errEncryptionKeyIDInvalid   = errors.Errorf("the encryption key ID must be between 0 and %d", maxEncryptionKeyID)
+	errEncryptionKeyIDReused    = errors.New("the encryption key IDs must be unique")
+	errEncryptionKeyUnknown     = errors.New("encrypted object has been encrypted with an unknown master key")
+	errInvalidPreviousKey       = errors.New("the previous encryption keys must be in the <key-id>:<key-path> format")
+)
+
+// SecretReader reads the encryption master key.
+type SecretReader interface {
+	ReadSecret(path string) ([]byte, error)
+}
+
+type fileSecretReader struct{}
+
+func (fileSecretReader) ReadSecret(path string) ([]byte, error) {
+	return os.ReadFile(path)
+}
+
+// EncryptionConfig configures the client-side envelope encryption of tenant objects.
+type EncryptionConfig struct {
+	Enabled      bool                   `yaml:"enabled" category:"experimental"`
+	KeyPath      string                 `yaml:"key_path" category:"experimental"`
+	KeyID        int                    `yaml:"key_id" category:"experimental"`
+	PreviousKeys flagext.StringSliceCSV `yaml:"previous_keys" category:"experimental"`
+
+	// Reader is used to read the master key from KeyPath. The master key is read from the local
+	// filesystem when not set.
+	Reader SecretReader `yaml:"-"`
+
+	// TenantPrefixes is the list of bucket prefixes under which objects are stored per-tenant
+	// (e.g. <prefix>/<tenant>/...). Objects not stored under any of these prefixes are expected
+	// to be stored at <tenant>/...
+	TenantPrefixes []string `yaml:"-"`
+}
+
+// RegisterFlagsWithPrefix registers flags with the provided prefix.
+func (cfg *EncryptionConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.BoolVar(&cfg.Enabled, prefix+"encryption.enabled", false, "If enabled, all objects stored under a tenant are encrypted on the client side before being uploaded to the object storage, using a per-tenant key derived from the master key. Objects are transparently decrypted on read, and unencrypted objects can still be read.")
+	f.StringVar(&cfg.KeyPath, prefix+"encryption.key-path", "", "Path to the base64 encoded 32 bytes master key used to encrypt objects. When Vault is enabled, the path is read from Vault instead of the local filesystem.")
+	f.IntVar(&cfg.KeyID, prefix+"encryption.key-id", 0, fmt.Sprintf("ID of the master key, between 0 and %d. The ID is stored in each encrypted object, so that the master key can be rotated by configuring a new key with a different ID and moving the previous one to the previous keys.", maxEncryptionKeyID))
+	f.Var(&cfg.PreviousKeys, prefix+"encryption.previous-keys", "Comma-separated list of the previous master keys, in the <key-id>:<key-path> format. The previous keys are only used to decrypt the objects encrypted before the master key has been rotated.")
+}
+
+// Validate the config.
+func (cfg *EncryptionConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.KeyPath == "" {
+		return errEncryptionKeyPathMissing
+	}
+	_, err := cfg.keyPaths()
+	return err
+}
+
+// keyPaths returns the paths of the master keys, by key ID.
+func (cfg *EncryptionConfig) keyPaths() (map[byte]string, error) {
+	if cfg.KeyID < 0 || cfg.KeyID > maxEncryptionKeyID {
+		return nil, errEncryptionKeyIDInvalid
+	}
+
+	paths := map[byte]string{byte(cfg.KeyID): cfg.KeyPath}
+	for _, previous := range cfg.PreviousKeys {
+		id, path, ok := strings.Cut(previous, ":")
+		if !ok || path == "" {
+			return nil, errInvalidPreviousKey
+		}
+		keyID, err := strconv.Atoi(id)
+		if err != nil {
+			return nil, errInvalidPreviousKey
+		}
+		if keyID < 0 || keyID > maxEncryptionKeyID {
+			return nil, errEncryptionKeyIDInvalid
+		}
+		if _, ok := paths[byte(keyID)]; ok {
+			return nil, errEncryptionKeyIDReused
+		}
+		paths[byte(keyID)] = path
+	}
+	return paths, nil
+}
+
+// EncryptedBucketClient is a wrapper around an objstore.Bucket that encrypts every object stored under a tenant
+// with a random per-object data key, which is in turn encrypted (wrapped) with a per-tenant key derived from the
+// master key, and stored in the object header. Objects are split into segments encrypted with AES-GCM, so that
+// range reads only need to fetch and decrypt the segments they cover.
+//
+// Encrypted objects have the following format:
+//
+//	magic (4 bytes) | version (1 byte) | key ID (1 byte) | wrapped data key (60 bytes) | segment 0 | segment 1 | ...
+//
+// The key ID identifies the master key the tenant key has been derived from, so that the master key can be rotated
+// while the objects encrypted with the previous master keys can still be read.
+//
+// Each segment holds up to encryptionSegmentSize bytes of plaintext, followed by the authentication tag. The
+// nonce of each segment is built from its sequence number and a flag marking the last segment, so that segments
+// can't be reordered and objects can't be truncated without being detected.
+type EncryptedBucketClient struct {
+	bucket         objstore.Bucket
+	tenantPrefixes []string
+	keys           *encryptionKeys
+}
+
+// encryptionKeys holds the master keys, and caches the keys derived from them.
+type encryptionKeys struct {
+	// keyID is the ID of the master key used to encrypt new objects.
+	keyID      byte
+	masterKeys map[byte][]byte
+
+	tenantKeysMx sync.Mutex
+	tenantKeys   map[tenantKeyID]cipher.AEAD
+
+	// dataKeys caches the data keys of recently read objects, by object name.
+	dataKeys *lru.Cache[string, cipher.AEAD]
+}
+
+type tenantKeyID struct {
+	keyID  byte
+	tenant string
+}
+
+// NewEncryptedBucketClient makes a new EncryptedBucketClient, reading the master keys as configured.
+func NewEncryptedBucketClient(bucket objstore.Bucket, cfg EncryptionConfig) (*EncryptedBucketClient, error) {
+	reader := cfg.Reader
+	if reader == nil {
+		reader = fileSecretReader{}
+	}
+
+	paths, err := cfg.keyPaths()
+	if err != nil {
+		return nil, err
+	}
+
+	masterKeys := make(map[byte][]byte, len(paths))
+	for keyID, path := range paths {
+		content, err := reader.ReadSecret(path)
+		if err != nil {
+			return nil, errors.Wrapf(err, "read encryption key %d", keyID)
+		}
+
+		masterKey, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(content)))
+		if err != nil || len(masterKey) != encryptionKeySize {
+			return nil, errors.Wrapf(errInvalidEncryptionKey, "encryption key %d", keyID)
+		}
+		masterKeys[keyID] = masterKey
+	}
+
+	dataKeys, err := lru.New[string, cipher.AEAD](dataKeysCacheSize)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EncryptedBucketClient{
+		bucket:         bucket,
+		tenantPrefixes: cfg.TenantPrefixes,
+		keys: &encryptionKeys{
+			keyID:      byte(cfg.KeyID),
+			masterKeys: masterKeys,
+			tenantKeys: map[tenantKeyID]cipher.AEAD{},
+			dataKeys:   dataKeys,
+		},
+	}, nil
+}
+
+// tenantFromObjectName returns the tenant owning the object, or an empty string if the object is not stored
+// under a tenant.
+func (b *EncryptedBucketClient) tenantFromObjectName(name string) string {
+	parts := strings.SplitN(name, objstore.DirDelim, 3)
+	if len(parts) < 2 || parts[0] == MimirInternalsPrefix {
+		return ""
+	}
+
+	for _, prefix := range b.tenantPrefixes {
+		if parts[0] == prefix {
+			return parts[1]
+		}
+	}
+	return parts[0]
+}
+
+// tenantKey returns the cipher used to wrap the data keys of the tenant's objects, derived from the master key
+// with the given ID.
+func (k *encryptionKeys) tenantKey(keyID byte, tenant string) (cipher.AEAD, error) {
+	k.tenantKeysMx.Lock()
+	defer k.tenantKeysMx.Unlock()
+
+	id := tenantKeyID{keyID: keyID, tenant: tenant}
+	if aead, ok := k.tenantKeys[id]; ok {
+		return aead, nil
+	}
+
+	masterKey, ok := k.masterKeys[keyID]
+	if !ok {
+		return nil, errors.Wrapf(errEncryptionKeyUnknown, "key ID %d", keyID)
+	}
+
+	key := make([]byte, encryptionKeySize)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, masterKey, nil, []byte("mimir-tenant-key:"+tenant)), key); err != nil {
+		return nil, err
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	k.tenantKeys[id] = aead
+	return aead, nil
+}
+
+// Close implements objstore.Bucket.
+func (b *EncryptedBucketClient) Close() error {
+	return b.bucket.Close()
+}
+
+// Upload the contents of the reader as an object into the bucket. Objects stored under a tenant are encrypted.
+func (b *EncryptedBucketClient) Upload(ctx context.Context, name string, r io.Reader) error {
+	tenant := b.tenantFromObjectName(name)
+	if tenant == "" {
+		return b.bucket.Upload(ctx, name, r)
+	}
+
+	tenantKey, err := b.keys.tenantKey(b.keys.keyID, tenant)
+	if err != nil {
+		return err
+	}
+
+	dataKey := make([]byte, encryptionKeySize)
+	if _, err := rand.Read(dataKey); err != nil {
+		return err
+	}
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, 0, encryptedObjectHeaderSize)
+	header = append(header, encryptedObjectMagic...)
+	header = append(header, encryptedObjectVersion1, b.keys.keyID)
+
+	nonce := make([]byte, encryptionNonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return err
+	}
+	header = append(header, nonce...)
+	header = tenantKey.Seal(header, nonce, dataKey, dataKeyAdditionalData(name))
+
+	// The object may be overwritten, so make sure a stale data key isn't used for reads.
+	b.keys.dataKeys.Remove(name)
+
+	err = b.bucket.Upload(ctx, name, &encryptingReader{
+		src:   bufio.NewReaderSize(r, encryptionSegmentSize+1),
+		aead:  aead,
+		plain: make([]byte, encryptionSegmentSize),
+		out:   header,
+	})
+	if err != nil {
+		return err
+	}
+
+	b.keys.dataKeys.Add(name, aead)
+	return nil
+}
+
+// Delete implements objstore.Bucket.
+func (b *EncryptedBucketClient) Delete(ctx context.Context, name string) error {
+	b.keys.dataKeys.Remove(name)
+	return b.bucket.Delete(ctx, name)
+}
+
+// Name implements objstore.Bucket.
+func (b *EncryptedBucketClient) Name() string {
+	return b.bucket.Name()
+}
+
+// Iter implements objstore.Bucket.
+func (b *EncryptedBucketClient) Iter(ctx context.Context, dir string, f func(string) error, options ...objstore.IterOption) error {
+	return b.bucket.Iter(ctx, dir, f, options...)
+}
+
+// Get returns a reader for the given object name. Encrypted objects are decrypted while reading.
+func (b *EncryptedBucketClient) Get(ctx context.Context, name string) (io.ReadCloser, error) {
+	rc, err := b.bucket.Get(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, encryptedObjectHeaderSize)
+	n, err := io.ReadFull(rc, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		_ = rc.Close()
+		return nil, err
+	}
+
+	if !isEncryptedObjectHeader(header[:n]) {
+		// The object hasn't been encrypted, so we just return its content.
+		return readCloser{Reader: io.MultiReader(bytes.NewReader(header[:n]), rc), Closer: rc}, nil
+	}
+
+	aead, err := b.unwrapDataKey(name, header)
+	if err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+
+	r, err := newDecryptingReader(rc, aead, 0, -1)
+	if err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+	return readCloser{Reader: r, Closer: rc}, nil
+}
+
+// GetRange returns a new range reader for the given object name and range. For encrypted objects, only the
+// segments covering the requested range are fetched and decrypted.
+func (b *EncryptedBucketClient) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	if off < 0 {
+		return nil, errors.Errorf("invalid range offset %d", off)
+	}
+
+	aead, cached := b.keys.dataKeys.Get(name)
+	if !cached {
+		header, encrypted, err := b.readHeader(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		if !encrypted {
+			return b.bucket.GetRange(ctx, name, off, length)
+		}
+		if aead, err = b.unwrapDataKey(name, header); err != nil {
+			return nil, err
+		}
+	}
+
+	rc, err := b.getDecryptedRange(ctx, name, aead, off, length)
+	if err != nil && cached && errors.Is(err, errEncryptedObjectCorrupted) {
+		// The object may have been overwritten since its data key has been cached,
+		// so we try again reading the data key from the object header.
+		b.keys.dataKeys.Remove(name)
+		return b.GetRange(ctx, name, off, length)
+	}
+	return rc, err
+}
+
+func (b *EncryptedBucketClient) getDecryptedRange(ctx context.Context, name string, aead cipher.AEAD, off, length int64) (io.ReadCloser, error) {
+	if length == 0 {
+		return io.NopCloser(bytes.NewReader(nil)), nil
+	}
+
+	firstSegment := off / encryptionSegmentSize
+	start := int64(encryptedObjectHeaderSize) + firstSegment*encryptedSegmentSize
+
+	// When the end of the range is known, we only fetch the segments covering it, plus 1 extra byte
+	// used to detect whether the last fetched segment is the last segment of the object.
+	segments, rangeLength := int64(-1), int64(-1)
+	if length > 0 {
+		segments = (off+length-1)/encryptionSegmentSize - firstSegment + 1
+		rangeLength = segments*encryptedSegmentSize + 1
+	}
+
+	rc, err := b.bucket.GetRange(ctx, name, start, rangeLength)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := newDecryptingReader(rc, aead, uint64(firstSegment), segments)
+	if err != nil {
+		_ = rc.Close()
+		return nil, err
+	}
+
+	if _, err := io.CopyN(io.Discard, r, off-firstSegment*encryptionSegmentSize); err != nil && !errors.Is(err, io.EOF) {
+		_ = rc.Close()
+		return nil, err
+	}
+
+	var reader io.Reader = r
+	if length > 0 {
+		reader = io.LimitReader(r, length)
+	}
+	return readCloser{Reader: reader, Closer: rc}, nil
+}
+
+// readHeader reads the header of the object, and returns whether the object is encrypted.
+func (b *EncryptedBucketClient) readHeader(ctx context.Context, name string) ([]byte, bool, error) {
+	rc, err := b.bucket.GetRange(ctx, name, 0, int64(encryptedObjectHeaderSize))
+	if err != nil {
+		return nil, false, err
+	}
+	defer func() { _ = rc.Close() }()
+
+	header := make([]byte, encryptedObjectHeaderSize)
+	n, err := io.ReadFull(rc, header)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, false, err
+	}
+	return header, isEncryptedObjectHeader(header[:n]), nil
+}
+
+// unwrapDataKey decrypts the data key stored in the header of an encrypted object, and caches it.
+func (b *EncryptedBucketClient) unwrapDataKey(name string, header []byte) (cipher.AEAD, error) {
+	tenant := b.tenantFromObjectName(name)
+	if tenant == "" {
+		return nil, errors.Wrapf(errEncryptedObjectNoTenant, "object %s", name)
+	}
+
+	keyID := header[len(encryptedObjectMagic)+1]
+	tenantKey, err := b.keys.tenantKey(keyID, tenant)
+	if err != nil {
+		return nil, errors.Wrapf(err, "object %s", name)
+	}
+
+	wrapped := header[len(encryptedObjectMagic)+2:]
+	dataKey, err := tenantKey.Open(nil, wrapped[:encryptionNonceSize], wrapped[encryptionNonceSize:], dataKeyAdditionalData(name))
+	if err != nil {
+		return nil, errors.Wrapf(errEncryptedObjectCorrupted, "unwrap data key of object %s", name)
+	}
+
+	aead, err := newAEAD(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	b.keys.dataKeys.Add(name, aead)
+	return aead, nil
+}
+
+// Exists implements objstore.Bucket.
+func (b *EncryptedBucketClient) Exists(ctx context.Context, name string) (bool, error) {
+	return b.bucket.Exists(ctx, name)
+}
+
+// IsObjNotFoundErr implements objstore.Bucket.
+func (b *EncryptedBucketClient) IsObjNotFoundErr(err error) bool {
+	return b.bucket.IsObjNotFoundErr(err)
+}
+
+// IsAccessDeniedErr implements objstore.Bucket.
+func (b *EncryptedBucketClient) IsAccessDeniedErr(err error) bool {
+	return b.bucket.IsAccessDeniedErr(err)
+}
+
+// Attributes returns attributes of the specified object. The size of encrypted objects is the size of their
+// plaintext content.
+//
+// Whether an object stored under a tenant is encrypted can only be known from its header, so unless the data key
+// of the object is cached, this costs an additional range request to the object storage. The data key is then
+// cached, so that the following range reads of the object don't fetch the header again. Callers frequently
+// reading the attributes of the same objects should cache them, like the caching bucket does.
+func (b *EncryptedBucketClient) Attributes(ctx context.Context, name string) (objstore.ObjectAttributes, error) {
+	attrs, err := b.bucket.Attributes(ctx, name)
+	if err != nil || b.tenantFromObjectName(name) == "" {
+		// Objects not stored under a tenant are never encrypted.
+		return attrs, err
+	}
+
+	if _, cached := b.keys.dataKeys.Get(name); !cached {
+		header, encrypted, err := b.readHeader(ctx, name)
+		if err != nil {
+			return objstore.ObjectAttributes{}, err
+		}
+		if !encrypted {
+			return attrs, nil
+		}
+		if _, err := b.unwrapDataKey(name, header); err != nil {
+			return objstore.ObjectAttributes{}, err
+		}
+	}
+
+	size, err := plaintextSize(attrs.Size)
+	if err != nil {
+		return objstore.ObjectAttributes{}, errors.Wrapf(err, "object %s", name)
+	}
+	attrs.Size = size
+	return attrs, nil
+}
+
+// ReaderWithExpectedErrs implements objstore.Bucket.
+func (b *EncryptedBucketClient) ReaderWithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.BucketReader {
+	return b.WithExpectedErrs(fn)
+}
+
+// WithExpectedErrs implements objstore.Bucket.
+func (b *EncryptedBucketClient) WithExpectedErrs(fn objstore.IsOpFailureExpectedFunc) objstore.Bucket {
+	if ib, ok := b.bucket.(objstore.InstrumentedBucket); ok {
+		return &EncryptedBucketClient{
+			bucket:         ib.WithExpectedErrs(fn),
+			tenantPrefixes: b.tenantPrefixes,
+			keys:           b.keys,
+		}
+	}
+
+	return b
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func isEncryptedObjectHeader(header []byte) bool {
+	return len(header) == encryptedObjectHeaderSize &&
+		string(header[:len(encryptedObjectMagic)]) == encryptedObjectMagic &&
+		header[len(encryptedObjectMagic)] == encryptedObjectVersion1
+}
+
+// dataKeyAdditionalData binds the wrapped data key to the object name, so that an encrypted object can't be
+// copied to a different location and decrypted from there.
+func dataKeyAdditionalData(name string) []byte {
+	return []byte(encryptedObjectMagic + name)
+}
+
+// segmentNonce returns the nonce of the segment with the given sequence number. Since each object is encrypted
+// with a random data key, the nonces don't need to be random.
+func segmentNonce(seq uint64, last bool) []byte {
+	nonce := make([]byte, encryptionNonceSize)
+	binary.BigEndian.PutUint64(nonce, seq)
+	if last {
+		nonce[encryptionNonceSize-1] = 1
+	}
+	return nonce
+}
+
+// plaintextSize returns the size of the plaintext content of an encrypted object of the given size.
+func plaintextSize(size int64) (int64, error) {
+	body := size - int64(encryptedObjectHeaderSize)
+	if body < encryptionTagSize {
+		return 0, errEncryptedObjectCorrupted
+	}
+
+	segments := (body + encryptedSegmentSize - 1) / encryptedSegmentSize
+	if body-(segments-1)*encryptedSegmentSize < encryptionTagSize {
+		return 0, errEncryptedObjectCorrupted
+	}
+	return body - segments*encryptionTagSize, nil
+}
+
+// encryptingReader reads the object header followed by the encrypted segments of the plaintext read from src.
+type encryptingReader struct {
+	src   *bufio.Reader
+	aead  cipher.AEAD
+	plain []byte
+	out   []byte
+	seq   uint64
+	done  bool
+}
+
+func (r *encryptingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.nextSegment(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *encryptingReader) nextSegment() error {
+	n, err := io.ReadFull(r.src, r.plain)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+
+	last := n < len(r.plain)
+	if !last {
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	r.out = r.aead.Seal(r.out[:0], segmentNonce(r.seq, last), r.plain[:n], nil)
+	r.seq++
+	r.done = last
+	return nil
+}
+
+// decryptingReader decrypts the segments read from src, starting from the segment with the given sequence number.
+type decryptingReader struct {
+	src  *bufio.Reader
+	aead cipher.AEAD
+	seq  uint64
+	// remaining is the number of segments left to read, or -1 if segments should be read until the last one.
+	remaining int64
+
+	in   []byte
+	out  []byte
+	done bool
+}
+
+// newDecryptingReader makes a new decryptingReader, decrypting the first segment right away so that a wrong
+// data key is detected before returning.
+func newDecryptingReader(src io.Reader, aead cipher.AEAD, seq uint64, segments int64) (*decryptingReader, error) {
+	r := &decryptingReader{
+		src:       bufio.NewReaderSize(src, encryptedSegmentSize+1),
+		aead:      aead,
+		seq:       seq,
+		remaining: segments,
+		in:        make([]byte, encryptedSegmentSize),
+	}
+
+	if err := r.nextSegment(true); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+func (r *decryptingReader) Read(p []byte) (int, error) {
+	for len(r.out) == 0 {
+		if r.done {
+			return 0, io.EOF
+		}
+		if err := r.nextSegment(false); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.out)
+	r.out = r.out[n:]
+	return n, nil
+}
+
+func (r *decryptingReader) nextSegment(first bool) error {
+	if r.remaining == 0 {
+		r.done = true
+		return nil
+	}
+
+	n, err := io.ReadFull(r.src, r.in)
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return err
+	}
+	if n == 0 && first && r.seq > 0 {
+		// The requested range starts after the end of the object.
+		r.done = true
+		return nil
+	}
+
+	last := n < len(r.in)
+	if !last {
+		if _, err := r.src.Peek(1); errors.Is(err, io.EOF) {
+			last = true
+		} else if err != nil {
+			return err
+		}
+	}
+
+	r.out, err = r.aead.Open(r.out[:0], segmentNonce(r.seq, last), r.in[:n], nil)
+	if err != nil {
+		return errors.Wrapf(errEncryptedObjectCorrupted, "decrypt segment %d", r.seq)
+	}
+
+	r.seq++
+	r.done = last
+	if r.remaining > 0 {
+		r.remaining--
+	}
+	return nil
+}
+
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
@@ -0,0 +1,368 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucket
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket/filesystem"
+	util_log "github.com/grafana/mimir/pkg/util/log"
+)
+
+func writeEncryptionKey(t *testing.T) string {
+	key := make([]byte, encryptionKeySize)
+	_, err := rand.Read(key)
+	require.NoError(t, err)
+
+	path := filepath.Join(t.TempDir(), "key")
+	require.NoError(t, os.WriteFile(path, []byte(base64.StdEncoding.EncodeToString(key)+"\n"), 0o600))
+	return path
+}
+
+func newEncryptedBucketClient(t *testing.T, tenantPrefixes ...string) (*EncryptedBucketClient, *objstore.InMemBucket) {
+	inmem := objstore.NewInMemBucket()
+	client, err := NewEncryptedBucketClient(inmem, EncryptionConfig{Enabled: true, KeyPath: writeEncryptionKey(t), TenantPrefixes: tenantPrefixes})
+	require.NoError(t, err)
+	return client, inmem
+}
+
+type staticSecretReader map[string][]byte
+
+func (r staticSecretReader) ReadSecret(path string) ([]byte, error) {
+	if content, ok := r[path]; ok {
+		return content, nil
+	}
+	return nil, fmt.Errorf("secret %s not found", path)
+}
+
+func TestNewEncryptedBucketClient(t *testing.T) {
+	reader := staticSecretReader{
+		"valid":   []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, encryptionKeySize))),
+		"short":   []byte(base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{1}, 16))),
+		"invalid": []byte("not base64"),
+	}
+
+	_, err := NewEncryptedBucketClient(objstore.NewInMemBucket(), EncryptionConfig{KeyPath: "valid", Reader: reader})
+	assert.NoError(t, err)
+
+	_, err = NewEncryptedBucketClient(objstore.NewInMemBucket(), EncryptionConfig{KeyPath: "short", Reader: reader})
+	assert.ErrorIs(t, err, errInvalidEncryptionKey)
+
+	_, err = NewEncryptedBucketClient(objstore.NewInMemBucket(), EncryptionConfig{KeyPath: "invalid", Reader: reader})
+	assert.ErrorIs(t, err, errInvalidEncryptionKey)
+
+	_, err = NewEncryptedBucketClient(objstore.NewInMemBucket(), EncryptionConfig{KeyPath: "missing", Reader: reader})
+	assert.Error(t, err)
+
+	_, err = NewEncryptedBucketClient(objstore.NewInMemBucket(), EncryptionConfig{KeyPath: filepath.Join(t.TempDir(), "missing")})
+	assert.Error(t, err)
+}
+
+func TestEncryptedBucketClient_UploadAndRead(t *testing.T) {
+	ctx := context.Background()
+
+	for _, size := range []int{0, 1, encryptionSegmentSize - 1, encryptionSegmentSize, encryptionSegmentSize + 1, 2 * encryptionSegmentSize, 3*encryptionSegmentSize + 100} {
+		t.Run(fmt.Sprintf("size=%d", size), func(t *testing.T) {
+			client, inmem := newEncryptedBucketClient(t)
+			name := "user-1/01HM4Z5C0000000000000000/chunks/000001"
+
+			data := make([]byte, size)
+			_, err := rand.Read(data)
+			require.NoError(t, err)
+
+			require.NoError(t, client.Upload(ctx, name, bytes.NewReader(data)))
+
+			// The object should have been encrypted.
+			raw := inmem.Objects()[name]
+			assert.True(t, bytes.HasPrefix(raw, []byte(encryptedObjectMagic)))
+			if size > 0 {
+				assert.False(t, bytes.Contains(raw, data))
+			}
+
+			rawAttrs, err := inmem.Attributes(ctx, name)
+			require.NoError(t, err)
+			decryptedSize, err := plaintextSize(rawAttrs.Size)
+			require.NoError(t, err)
+			assert.Equal(t, int64(size), decryptedSize)
+
+			attrs, err := client.Attributes(ctx, name)
+			require.NoError(t, err)
+			assert.Equal(t, int64(size), attrs.Size)
+
+			assert.Equal(t, data, readAll(t, func() (io.ReadCloser, error) { return client.Get(ctx, name) }))
+
+			ranges := [][2]int64{
+				{0, 1},
+				{0, int64(size)},
+				{0, int64(size) + 10},
+				{int64(size) / 2, int64(size) / 3},
+				{encryptionSegmentSize - 10, 20},
+				{encryptionSegmentSize, encryptionSegmentSize},
+				{int64(size) - 1, 1},
+				{int64(size), 10},
+				{int64(size) / 2, -1},
+			}
+			for _, r := range ranges {
+				off, length := r[0], r[1]
+				if off < 0 || length == 0 {
+					continue
+				}
+
+				end := int64(size)
+				if length > 0 && off+length < end {
+					end = off + length
+				}
+				var expected []byte
+				if off < end {
+					expected = data[off:end]
+				}
+
+				actual := readAll(t, func() (io.ReadCloser, error) { return client.GetRange(ctx, name, off, length) })
+				assert.Equal(t, len(expected), len(actual), "off=%d length=%d", off, length)
+				assert.True(t, bytes.Equal(expected, actual), "off=%d length=%d", off, length)
+			}
+		})
+	}
+}
+
+func TestEncryptedBucketClient_ObjectsNotOwnedByTenants(t *testing.T) {
+	ctx := context.Background()
+	client, inmem := newEncryptedBucketClient(t, "rules")
+
+	for name, encrypted := range map[string]bool{
+		"user-1/bucket-index.json.gz":              true,
+		"rules/user-1/namespace/group":             true,
+		"user-1":                                   false,
+		MimirInternalsPrefix + "/usage-stats.json": false,
+	} {
+		require.NoError(t, client.Upload(ctx, name, bytes.NewReader([]byte("content"))))
+		assert.Equal(t, encrypted, !bytes.Equal([]byte("content"), inmem.Objects()[name]), name)
+		assert.Equal(t, []byte("content"), readAll(t, func() (io.ReadCloser, error) { return client.Get(ctx, name) }), name)
+	}
+
+	assert.Equal(t, "user-1", client.tenantFromObjectName("rules/user-1/namespace/group"))
+	assert.Equal(t, "user-1", client.tenantFromObjectName("rules/user-1"))
+	assert.Equal(t, "", client.tenantFromObjectName("rules"))
+}
+
+func TestEncryptedBucketClient_UnencryptedObjects(t *testing.T) {
+	ctx := context.Background()
+	client, inmem := newEncryptedBucketClient(t)
+
+	// Objects uploaded before the encryption has been enabled should still be readable.
+	name := "user-1/meta.json"
+	data := bytes.Repeat([]byte("0123456789"), encryptionSegmentSize/5)
+	require.NoError(t, inmem.Upload(ctx, name, bytes.NewReader(data)))
+	require.NoError(t, inmem.Upload(ctx, "user-1/empty", bytes.NewReader(nil)))
+
+	assert.Equal(t, data, readAll(t, func() (io.ReadCloser, error) { return client.Get(ctx, name) }))
+	assert.Equal(t, data[100:200], readAll(t, func() (io.ReadCloser, error) { return client.GetRange(ctx, name, 100, 100) }))
+	assert.Empty(t, readAll(t, func() (io.ReadCloser, error) { return client.Get(ctx, "user-1/empty") }))
+
+	attrs, err := client.Attributes(ctx, name)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(data)), attrs.Size)
+}
+
+func TestEncryptedBucketClient_TamperedObjects(t *testing.T) {
+	ctx := context.Background()
+	data := bytes.Repeat([]byte("x"), 2*encryptionSegmentSize+10)
+	name := "user-1/block/index"
+
+	newTamperedBucket := func(t *testing.T, tamper func(raw []byte) []byte) *EncryptedBucketClient {
+		client, inmem := newEncryptedBucketClient(t)
+		require.NoError(t, client.Upload(ctx, name, bytes.NewReader(data)))
+		require.NoError(t, inmem.Upload(ctx, name, bytes.NewReader(tamper(inmem.Objects()[name]))))
+		// Make sure the data key is read again from the object header.
+		client.keys.dataKeys.Purge()
+		return client
+	}
+
+	tests := map[string]func(raw []byte) []byte{
+		"flipped byte in the header": func(raw []byte) []byte {
+			raw[len(encryptedObjectMagic)+5] ^= 0xff
+			return raw
+		},
+		"flipped byte in a segment": func(raw []byte) []byte {
+			raw[encryptedObjectHeaderSize+10] ^= 0xff
+			return raw
+		},
+		"truncated at a segment boundary": func(raw []byte) []byte {
+			return raw[:encryptedObjectHeaderSize+2*encryptedSegmentSize]
+		},
+		"swapped segments": func(raw []byte) []byte {
+			first := append([]byte(nil), raw[encryptedObjectHeaderSize:encryptedObjectHeaderSize+encryptedSegmentSize]...)
+			copy(raw[encryptedObjectHeaderSize:], raw[encryptedObjectHeaderSize+encryptedSegmentSize:encryptedObjectHeaderSize+2*encryptedSegmentSize])
+			copy(raw[encryptedObjectHeaderSize+encryptedSegmentSize:], first)
+			return raw
+		},
+	}
+
+	for testName, tamper := range tests {
+		t.Run(testName, func(t *testing.T) {
+			client := newTamperedBucket(t, tamper)
+
+			_, err := readAllWithErr(func() (io.ReadCloser, error) { return client.Get(ctx, name) })
+			assert.ErrorIs(t, err, errEncryptedObjectCorrupted)
+
+			_, err = readAllWithErr(func() (io.ReadCloser, error) { return client.GetRange(ctx, name, 0, int64(len(data))) })
+			assert.ErrorIs(t, err, errEncryptedObjectCorrupted)
+		})
+	}
+
+	t.Run("object copied to another tenant", func(t *testing.T) {
+		client, inmem := newEncryptedBucketClient(t)
+		require.NoError(t, client.Upload(ctx, name, bytes.NewReader(data)))
+		require.NoError(t, inmem.Upload(ctx, "user-2/block/index", bytes.NewReader(inmem.Objects()[name])))
+
+		_, err := readAllWithErr(func() (io.ReadCloser, error) { return client.Get(ctx, "user-2/block/index") })
+		assert.ErrorIs(t, err, errEncryptedObjectCorrupted)
+	})
+}
+
+func TestEncryptedBucketClient_OverwrittenObject(t *testing.T) {
+	ctx := context.Background()
+	name := "user-1/bucket-index.json.gz"
+
+	// Simulate two clients sharing the same bucket, so that the data key cached
+	// by the first one becomes stale when the second one overwrites the object.
+	inmem := objstore.NewInMemBucket()
+	cfg := EncryptionConfig{Enabled: true, KeyPath: writeEncryptionKey(t)}
+	first, err := NewEncryptedBucketClient(inmem, cfg)
+	require.NoError(t, err)
+	second, err := NewEncryptedBucketClient(inmem, cfg)
+	require.NoError(t, err)
+
+	require.NoError(t, first.Upload(ctx, name, bytes.NewReader([]byte("first"))))
+	assert.Equal(t, []byte("first"), readAll(t, func() (io.ReadCloser, error) { return first.GetRange(ctx, name, 0, 5) }))
+
+	require.NoError(t, second.Upload(ctx, name, bytes.NewReader([]byte("second"))))
+	assert.Equal(t, []byte("second"), readAll(t, func() (io.ReadCloser, error) { return first.GetRange(ctx, name, 0, 6) }))
+	assert.Equal(t, []byte("second"), readAll(t, func() (io.ReadCloser, error) { return first.Get(ctx, name) }))
+}
+
+func TestEncryptedBucketClient_KeyRotation(t *testing.T) {
+	ctx := context.Background()
+	inmem := objstore.NewInMemBucket()
+	firstKey, secondKey := writeEncryptionKey(t), writeEncryptionKey(t)
+
+	first, err := NewEncryptedBucketClient(inmem, EncryptionConfig{Enabled: true, KeyPath: firstKey})
+	require.NoError(t, err)
+	require.NoError(t, first.Upload(ctx, "user-1/first", bytes.NewReader([]byte("first"))))
+
+	// After the rotation, new objects are encrypted with the new key, and the objects encrypted
+	// with the previous key can still be read.
+	rotated, err := NewEncryptedBucketClient(inmem, EncryptionConfig{Enabled: true, KeyPath: secondKey, KeyID: 1, PreviousKeys: []string{"0:" + firstKey}})
+	require.NoError(t, err)
+	require.NoError(t, rotated.Upload(ctx, "user-1/second", bytes.NewReader([]byte("second"))))
+	assert.Equal(t, byte(1), inmem.Objects()["user-1/second"][len(encryptedObjectMagic)+1])
+
+	assert.Equal(t, []byte("first"), readAll(t, func() (io.ReadCloser, error) { return rotated.Get(ctx, "user-1/first") }))
+	assert.Equal(t, []byte("second"), readAll(t, func() (io.ReadCloser, error) { return rotated.GetRange(ctx, "user-1/second", 0, 6) }))
+
+	// Once the previous key is removed, the objects encrypted with it can't be read anymore.
+	removed, err := NewEncryptedBucketClient(inmem, EncryptionConfig{Enabled: true, KeyPath: secondKey, KeyID: 1})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("second"), readAll(t, func() (io.ReadCloser, error) { return removed.Get(ctx, "user-1/second") }))
+	_, err = readAllWithErr(func() (io.ReadCloser, error) { return removed.Get(ctx, "user-1/first") })
+	assert.ErrorIs(t, err, errEncryptionKeyUnknown)
+}
+
+// getRangeCountingBucket counts the range requests to the wrapped bucket.
+type getRangeCountingBucket struct {
+	objstore.Bucket
+	getRanges int
+}
+
+func (b *getRangeCountingBucket) GetRange(ctx context.Context, name string, off, length int64) (io.ReadCloser, error) {
+	b.getRanges++
+	return b.Bucket.GetRange(ctx, name, off, length)
+}
+
+func TestEncryptedBucketClient_Attributes(t *testing.T) {
+	ctx := context.Background()
+	bkt := &getRangeCountingBucket{Bucket: objstore.NewInMemBucket()}
+	client, err := NewEncryptedBucketClient(bkt, EncryptionConfig{Enabled: true, KeyPath: writeEncryptionKey(t)})
+	require.NoError(t, err)
+
+	require.NoError(t, client.Upload(ctx, "user-1/object", bytes.NewReader([]byte("content"))))
+	require.NoError(t, client.Upload(ctx, "object", bytes.NewReader([]byte("content"))))
+
+	// The header of objects not stored under a tenant shouldn't be read.
+	attrs, err := client.Attributes(ctx, "object")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), attrs.Size)
+	assert.Equal(t, 0, bkt.getRanges)
+
+	// The header of encrypted objects should only be read if their data key isn't cached,
+	// and then the following range reads shouldn't read it again.
+	attrs, err = client.Attributes(ctx, "user-1/object")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), attrs.Size)
+	assert.Equal(t, 0, bkt.getRanges)
+
+	client.keys.dataKeys.Purge()
+	attrs, err = client.Attributes(ctx, "user-1/object")
+	require.NoError(t, err)
+	assert.Equal(t, int64(7), attrs.Size)
+	assert.Equal(t, 1, bkt.getRanges)
+
+	assert.Equal(t, []byte("content"), readAll(t, func() (io.ReadCloser, error) { return client.GetRange(ctx, "user-1/object", 0, 7) }))
+	assert.Equal(t, 2, bkt.getRanges)
+}
+
+func TestNewClient_WithEncryption(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	cfg := Config{
+		StorageBackendConfig: StorageBackendConfig{
+			Backend:    Filesystem,
+			Filesystem: filesystem.Config{Directory: dir},
+		},
+		StoragePrefix: "prefix",
+		Encryption:    EncryptionConfig{Enabled: true, KeyPath: writeEncryptionKey(t)},
+	}
+
+	client, err := NewClient(ctx, cfg, "test", util_log.Logger, nil)
+	require.NoError(t, err)
+
+	require.NoError(t, client.Upload(ctx, "user-1/file", bytes.NewBufferString("content")))
+	assert.Equal(t, []byte("content"), readAll(t, func() (io.ReadCloser, error) { return client.Get(ctx, "user-1/file") }))
+
+	raw, err := os.ReadFile(filepath.Join(dir, "prefix", "user-1", "file"))
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(raw, []byte(encryptedObjectMagic)))
+
+	// A client without encryption can't read the content.
+	cfg.Encryption = EncryptionConfig{}
+	client, err = NewClient(ctx, cfg, "test", util_log.Logger, nil)
+	require.NoError(t, err)
+	assert.NotEqual(t, []byte("content"), readAll(t, func() (io.ReadCloser, error) { return client.Get(ctx, "user-1/file") }))
+}
+
+func readAll(t *testing.T, get func() (io.ReadCloser, error)) []byte {
+	b, err := readAllWithErr(get)
+	require.NoError(t, err)
+	return b
+}
+
+func readAllWithErr(get func() (io.ReadCloser, error)) ([]byte, error) {
+	rc, err := get()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = rc.Close() }()
+	return io.ReadAll(rc)
+}
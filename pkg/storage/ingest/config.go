@@ -5,12 +5,29 @@ package ingest
 import (
 	"errors"
 	"flag"
+	"fmt"
+	"math"
+	"strings"
 	"time"
+
+	"golang.org/x/exp/slices"
+)
+
+const (
+	ProducerCompressionNone   = "none"
+	ProducerCompressionSnappy = "snappy"
+	ProducerCompressionLZ4    = "lz4"
+	ProducerCompressionZstd   = "zstd"
 )
 
 var (
-	ErrMissingKafkaAddress = errors.New("the Kafka address has not been configured")
-	ErrMissingKafkaTopic   = errors.New("the Kafka topic has not been configured")
+	ErrMissingKafkaAddress          = errors.New("the Kafka address has not been configured")
+	ErrMissingKafkaTopic            = errors.New("the Kafka topic has not been configured")
+	ErrInvalidProducerCompression   = fmt.Errorf("the Kafka producer compression is invalid, supported values are: %s", strings.Join(supportedProducerCompressions, ", "))
+	ErrInvalidProducerLinger        = errors.New("the Kafka producer linger must be a non-negative duration")
+	ErrInvalidProducerBatchMaxBytes = fmt.Errorf("the Kafka producer batch max bytes must be greater than 0 and less than or equal to %d", math.MaxInt32)
+
+	supportedProducerCompressions = []string{ProducerCompressionNone, ProducerCompressionSnappy, ProducerCompressionLZ4, ProducerCompressionZstd}
 )
 
 type Config struct {
@@ -48,6 +65,10 @@ type KafkaConfig struct {
 
 	LastProducedOffsetPollInterval time.Duration `yaml:"last_produced_offset_poll_interval"`
 	LastProducedOffsetRetryTimeout time.Duration `yaml:"last_produced_offset_retry_timeout"`
+
+	ProducerCompression   string        `yaml:"producer_compression"`
+	ProducerLinger        time.Duration `yaml:"producer_linger"`
+	ProducerBatchMaxBytes int           `yaml:"producer_batch_max_bytes"`
 }
 
 func (cfg *KafkaConfig) RegisterFlags(f *flag.FlagSet) {
@@ -63,6 +84,10 @@ func (cfg *KafkaConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet)
 
 	f.DurationVar(&cfg.LastProducedOffsetPollInterval, prefix+".last-produced-offset-poll-interval", time.Second, "How frequently to poll the last produced offset, used to enforce strong read consistency.")
 	f.DurationVar(&cfg.LastProducedOffsetRetryTimeout, prefix+".last-produced-offset-retry-timeout", 10*time.Second, "How long to retry a failed request to get the last produced offset.")
+
+	f.StringVar(&cfg.ProducerCompression, prefix+".producer-compression", ProducerCompressionNone, fmt.Sprintf("The compression codec used to compress the record batches written to Kafka. Records are transparently decompressed when read back. Supported values: %s.", strings.Join(supportedProducerCompressions, ", ")))
+	f.DurationVar(&cfg.ProducerLinger, prefix+".producer-linger", 50*time.Millisecond, "How long the Kafka client waits for more records to batch together before writing them to a partition. A higher value allows to batch more write requests together, improving the compression ratio, at the cost of a higher write latency. 0 to disable lingering.")
+	f.IntVar(&cfg.ProducerBatchMaxBytes, prefix+".producer-batch-max-bytes", 16_000_000, "The upper bound of the size of a record batch written to a partition.")
 }
 
 func (cfg *KafkaConfig) Validate() error {
@@ -72,6 +97,15 @@ func (cfg *KafkaConfig) Validate() error {
 	if cfg.Topic == "" {
 		return ErrMissingKafkaTopic
 	}
+	if !slices.Contains(supportedProducerCompressions, cfg.ProducerCompression) {
+		return ErrInvalidProducerCompression
+	}
+	if cfg.ProducerLinger < 0 {
+		return ErrInvalidProducerLinger
+	}
+	if cfg.ProducerBatchMaxBytes <= 0 || cfg.ProducerBatchMaxBytes > math.MaxInt32 {
+		return ErrInvalidProducerBatchMaxBytes
+	}
 
 	return nil
 }
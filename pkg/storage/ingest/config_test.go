@@ -3,7 +3,9 @@
 package ingest
 
 import (
+	"math"
 	"testing"
+	"time"
 
 	"github.com/grafana/dskit/flagext"
 	"github.com/stretchr/testify/assert"
@@ -31,6 +33,50 @@ func TestConfig_Validate(t *testing.T) {
 			},
 			expectedErr: ErrMissingKafkaTopic,
 		},
+		"should fail if ingest storage is enabled and the producer compression is invalid": {
+			setup: func(cfg *Config) {
+				cfg.Enabled = true
+				cfg.KafkaConfig.Address = "localhost"
+				cfg.KafkaConfig.Topic = "test"
+				cfg.KafkaConfig.ProducerCompression = "gzip"
+			},
+			expectedErr: ErrInvalidProducerCompression,
+		},
+		"should fail if ingest storage is enabled and the producer linger is negative": {
+			setup: func(cfg *Config) {
+				cfg.Enabled = true
+				cfg.KafkaConfig.Address = "localhost"
+				cfg.KafkaConfig.Topic = "test"
+				cfg.KafkaConfig.ProducerLinger = -time.Second
+			},
+			expectedErr: ErrInvalidProducerLinger,
+		},
+		"should fail if ingest storage is enabled and the producer batch max bytes is not positive": {
+			setup: func(cfg *Config) {
+				cfg.Enabled = true
+				cfg.KafkaConfig.Address = "localhost"
+				cfg.KafkaConfig.Topic = "test"
+				cfg.KafkaConfig.ProducerBatchMaxBytes = 0
+			},
+			expectedErr: ErrInvalidProducerBatchMaxBytes,
+		},
+		"should fail if ingest storage is enabled and the producer batch max bytes overflows an int32": {
+			setup: func(cfg *Config) {
+				cfg.Enabled = true
+				cfg.KafkaConfig.Address = "localhost"
+				cfg.KafkaConfig.Topic = "test"
+				cfg.KafkaConfig.ProducerBatchMaxBytes = math.MaxInt32 + 1
+			},
+			expectedErr: ErrInvalidProducerBatchMaxBytes,
+		},
+		"should pass if ingest storage is enabled and the producer compression is zstd": {
+			setup: func(cfg *Config) {
+				cfg.Enabled = true
+				cfg.KafkaConfig.Address = "localhost"
+				cfg.KafkaConfig.Topic = "test"
+				cfg.KafkaConfig.ProducerCompression = ProducerCompressionZstd
+			},
+		},
 		"should pass if ingest storage is enabled and required config is set": {
 			setup: func(cfg *Config) {
 				cfg.Enabled = true
@@ -89,6 +89,14 @@ func (w *partitionOffsetWatcher) Notify(lastConsumedOffset int64) {
 	}
 }
 
+// LastConsumedOffset returns the last consumed offset, or -1 if nothing has been consumed yet.
+func (w *partitionOffsetWatcher) LastConsumedOffset() int64 {
+	w.mx.Lock()
+	defer w.mx.Unlock()
+
+	return w.lastConsumedOffset
+}
+
 // Wait until the given offset has been consumed or the context is canceled.
 func (w *partitionOffsetWatcher) Wait(ctx context.Context, waitForOffset int64) error {
 	// A negative offset is used to signal the partition is empty,
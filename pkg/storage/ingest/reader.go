@@ -57,6 +57,9 @@ type PartitionReader struct {
 	consumedOffsetWatcher *partitionOffsetWatcher
 	offsetReader          *partitionOffsetReader
 
+	// rewinding is true while a rewind of the partition is in progress.
+	rewinding *atomic.Bool
+
 	logger log.Logger
 	reg    prometheus.Registerer
 }
@@ -74,6 +77,7 @@ func newPartitionReader(kafkaCfg KafkaConfig, partitionID int32, consumer record
 		metrics:               newReaderMetrics(partitionID, reg),
 		commitInterval:        time.Second,
 		consumedOffsetWatcher: newPartitionOffsetWatcher(),
+		rewinding:             atomic.NewBool(false),
 		logger:                log.With(logger, "partition", partitionID),
 		reg:                   reg,
 	}
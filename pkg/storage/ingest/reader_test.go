@@ -238,6 +238,78 @@ func TestPartitionReader_WaitReadConsistency(t *testing.T) {
 	})
 }
 
+func TestPartitionReader_Rewind(t *testing.T) {
+	const (
+		topicName   = "test"
+		partitionID = 1
+	)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	t.Cleanup(func() { cancel(errors.New("test done")) })
+
+	_, clusterAddr := testkafka.CreateCluster(t, partitionID+1, topicName)
+
+	consumer := newTestConsumer(10)
+	reader := startReader(ctx, t, clusterAddr, topicName, partitionID, consumer)
+
+	writeClient := newKafkaProduceClient(t, clusterAddr)
+	produceTenantRecord := func(tenantID string, content []byte) {
+		res := writeClient.ProduceSync(ctx, &kgo.Record{Key: []byte(tenantID), Value: content, Topic: topicName, Partition: partitionID})
+		require.NoError(t, res.FirstErr())
+	}
+
+	produceTenantRecord("user-1", []byte("1"))
+	produceTenantRecord("user-2", []byte("2"))
+	produceTenantRecord("user-1", []byte("3"))
+
+	_, err := consumer.waitRecords(3, 5*time.Second, 0)
+	require.NoError(t, err)
+	require.NoError(t, reader.consumedOffsetWatcher.Wait(ctx, 2))
+
+	t.Run("dry run", func(t *testing.T) {
+		res, err := reader.Rewind(ctx, RewindRequest{FromOffset: 0, DryRun: true})
+		require.NoError(t, err)
+		assert.Equal(t, RewindResult{Partition: partitionID, DryRun: true, StartOffset: 0, EndOffset: 2, RecordsRead: 3, RecordsReplayed: 3}, res)
+
+		_, err = consumer.waitRecords(0, time.Second, 0)
+		assert.NoError(t, err)
+	})
+
+	t.Run("filter by tenant", func(t *testing.T) {
+		res, err := reader.Rewind(ctx, RewindRequest{FromOffset: 0, Tenants: []string{"user-1"}})
+		require.NoError(t, err)
+		assert.Equal(t, RewindResult{Partition: partitionID, StartOffset: 0, EndOffset: 2, RecordsRead: 3, RecordsReplayed: 2}, res)
+
+		records, err := consumer.waitRecords(2, time.Second, 0)
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("1"), []byte("3")}, records)
+	})
+
+	t.Run("from offset", func(t *testing.T) {
+		res, err := reader.Rewind(ctx, RewindRequest{FromOffset: 2})
+		require.NoError(t, err)
+		assert.Equal(t, RewindResult{Partition: partitionID, StartOffset: 2, EndOffset: 2, RecordsRead: 1, RecordsReplayed: 1}, res)
+
+		records, err := consumer.waitRecords(1, time.Second, 0)
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{[]byte("3")}, records)
+	})
+
+	t.Run("nothing to rewind", func(t *testing.T) {
+		res, err := reader.Rewind(ctx, RewindRequest{FromOffset: 3})
+		require.NoError(t, err)
+		assert.Equal(t, RewindResult{Partition: partitionID, StartOffset: 3, EndOffset: 2}, res)
+	})
+
+	t.Run("rewind already in progress", func(t *testing.T) {
+		reader.rewinding.Store(true)
+		t.Cleanup(func() { reader.rewinding.Store(false) })
+
+		_, err := reader.Rewind(ctx, RewindRequest{FromOffset: 0})
+		assert.ErrorIs(t, err, ErrRewindInProgress)
+	})
+}
+
 func newKafkaProduceClient(t *testing.T, addrs string) *kgo.Client {
 	writeClient, err := kgo.NewClient(
 		kgo.SeedBrokers(addrs),
@@ -0,0 +1,159 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package ingest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/services"
+	"github.com/pkg/errors"
+	"github.com/twmb/franz-go/pkg/kadm"
+	"github.com/twmb/franz-go/pkg/kgo"
+)
+
+var ErrRewindInProgress = errors.New("a rewind of the partition is already in progress")
+
+// RewindRequest describes the records of a partition to consume again.
+type RewindRequest struct {
+	// FromOffset is the offset of the first record to consume again. Ignored if FromTime is set.
+	FromOffset int64
+
+	// FromTime, if set, selects the first record produced at or after this time.
+	FromTime time.Time
+
+	// Tenants restricts the replay to the records of these tenants. All tenants are replayed if empty.
+	Tenants []string
+
+	// DryRun reports the records which would be consumed again, without consuming them.
+	DryRun bool
+}
+
+// RewindResult summarizes the records consumed again by a rewind.
+type RewindResult struct {
+	Partition int32 `json:"partition"`
+	DryRun    bool  `json:"dry_run"`
+
+	// StartOffset and EndOffset are the offsets of the first and last records of the replayed range.
+	// The range is empty if EndOffset is lower than StartOffset.
+	StartOffset int64 `json:"start_offset"`
+	EndOffset   int64 `json:"end_offset"`
+
+	// RecordsRead is the number of records read in the replayed range.
+	RecordsRead int `json:"records_read"`
+
+	// RecordsReplayed is the number of records of the requested tenants which have been (or would be,
+	// in dry-run mode) consumed again.
+	RecordsReplayed int `json:"records_replayed"`
+}
+
+// Rewind consumes again the records of the partition from the requested offset or time up until the last
+// record consumed by the reader. The records are replayed by a dedicated Kafka client while the reader keeps
+// consuming new records, and the committed offset isn't changed.
+//
+// Records are replayed through the same consumer used by the reader, so client errors (e.g. samples which
+// already exist in the TSDB with the same timestamp) are skipped, while server errors interrupt the rewind.
+func (r *PartitionReader) Rewind(ctx context.Context, req RewindRequest) (RewindResult, error) {
+	result := RewindResult{Partition: r.partitionID, DryRun: req.DryRun, EndOffset: -1}
+
+	if state := r.Service.State(); state != services.Running {
+		return result, fmt.Errorf("partition reader service is not running (state: %s)", state.String())
+	}
+
+	if !r.rewinding.CompareAndSwap(false, true) {
+		return result, ErrRewindInProgress
+	}
+	defer r.rewinding.Store(false)
+
+	startOffset := req.FromOffset
+	if !req.FromTime.IsZero() {
+		var err error
+		if startOffset, err = r.fetchOffsetAfterTime(ctx, req.FromTime); err != nil {
+			return result, err
+		}
+	}
+	if startOffset < 0 {
+		return result, fmt.Errorf("invalid start offset %d", startOffset)
+	}
+
+	// Records after the last consumed offset will be consumed by the reader anyway.
+	result.StartOffset = startOffset
+	result.EndOffset = r.consumedOffsetWatcher.LastConsumedOffset()
+	if result.EndOffset < result.StartOffset {
+		return result, nil
+	}
+
+	level.Info(r.logger).Log("msg", "rewinding partition", "start_offset", result.StartOffset, "end_offset", result.EndOffset, "tenants", fmt.Sprintf("%v", req.Tenants), "dry_run", req.DryRun)
+
+	client, err := kgo.NewClient(
+		kgo.ClientID(r.kafkaCfg.ClientID),
+		kgo.SeedBrokers(r.kafkaCfg.Address),
+		kgo.DialTimeout(r.kafkaCfg.DialTimeout),
+		kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{
+			r.kafkaCfg.Topic: {r.partitionID: kgo.NewOffset().At(startOffset)},
+		}),
+		kgo.FetchMaxBytes(100_000_000),
+		kgo.FetchMaxPartitionBytes(50_000_000),
+	)
+	if err != nil {
+		return result, errors.Wrap(err, "creating kafka rewind client")
+	}
+	defer client.Close()
+
+	tenants := make(map[string]struct{}, len(req.Tenants))
+	for _, t := range req.Tenants {
+		tenants[t] = struct{}{}
+	}
+
+	for done := false; !done; {
+		fetches := client.PollFetches(ctx)
+		if err := fetches.Err(); err != nil {
+			return result, errors.Wrap(err, "fetching records to rewind")
+		}
+
+		var records []record
+		fetches.EachRecord(func(rec *kgo.Record) {
+			if done || rec.Offset > result.EndOffset {
+				done = true
+				return
+			}
+			done = rec.Offset == result.EndOffset
+
+			result.RecordsRead++
+			if _, ok := tenants[string(rec.Key)]; len(tenants) > 0 && !ok {
+				return
+			}
+			result.RecordsReplayed++
+			records = append(records, record{tenantID: string(rec.Key), content: rec.Value})
+		})
+
+		if req.DryRun || len(records) == 0 {
+			continue
+		}
+		if err := r.consumer.consume(ctx, records); err != nil {
+			return result, errors.Wrap(err, "consuming rewound records")
+		}
+	}
+
+	level.Info(r.logger).Log("msg", "rewound partition", "start_offset", result.StartOffset, "end_offset", result.EndOffset, "records_read", result.RecordsRead, "records_replayed", result.RecordsReplayed, "dry_run", req.DryRun)
+	return result, nil
+}
+
+// fetchOffsetAfterTime returns the offset of the first record produced at or after the input time.
+func (r *PartitionReader) fetchOffsetAfterTime(ctx context.Context, t time.Time) (int64, error) {
+	offsets, err := kadm.NewClient(r.client).ListOffsetsAfterMilli(ctx, t.UnixMilli(), r.kafkaCfg.Topic)
+	if err != nil {
+		return 0, errors.Wrap(err, "unable to list offsets")
+	}
+
+	offset, ok := offsets.Lookup(r.kafkaCfg.Topic, r.partitionID)
+	if !ok {
+		return 0, fmt.Errorf("unable to find the offset of partition %d", r.partitionID)
+	}
+	if offset.Err != nil {
+		return 0, errors.Wrap(offset.Err, "unable to list offsets")
+	}
+	return offset.Offset, nil
+}
@@ -41,8 +41,9 @@ type Writer struct {
 	writers   map[int32]*kgo.Client
 
 	// Metrics.
-	writeLatency    prometheus.Summary
-	writeBytesTotal prometheus.Counter
+	writeLatency     prometheus.Summary
+	writeBytesTotal  prometheus.Counter
+	compressionRatio *prometheus.HistogramVec
 
 	// The following settings can only be overridden in tests.
 	maxInflightProduceRequests int
@@ -68,6 +69,11 @@ func NewWriter(kafkaCfg KafkaConfig, logger log.Logger, reg prometheus.Registere
 			Name: "cortex_ingest_storage_writer_sent_bytes_total",
 			Help: "Total number of bytes sent to the ingest storage.",
 		}),
+		compressionRatio: promauto.With(reg).NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "cortex_ingest_storage_writer_produce_batch_compression_ratio",
+			Help:    "Ratio between the uncompressed and compressed size of the record batches written to the ingest storage.",
+			Buckets: []float64{1, 1.5, 2, 3, 4, 6, 8, 12, 16},
+		}, []string{"codec"}),
 	}
 
 	w.Service = services.NewIdleService(nil, w.stopping)
@@ -183,6 +189,7 @@ func (w *Writer) newKafkaWriter(partitionID int32) (*kgo.Client, error) {
 
 	opts := append(
 		commonKafkaClientOptions(w.kafkaCfg, metrics, logger),
+		kgo.WithHooks(&writerBatchHook{compressionRatio: w.compressionRatio}),
 		kgo.RequiredAcks(kgo.AllISRAcks()),
 		kgo.DefaultProduceTopic(w.kafkaCfg.Topic),
 
@@ -190,12 +197,16 @@ func (w *Writer) newKafkaWriter(partitionID int32) (*kgo.Client, error) {
 		kgo.RecordPartitioner(newKafkaStaticPartitioner(int(partitionID))),
 
 		// Set the upper bounds the size of a record batch.
-		kgo.ProducerBatchMaxBytes(16_000_000),
+		kgo.ProducerBatchMaxBytes(int32(w.kafkaCfg.ProducerBatchMaxBytes)),
+
+		// Compress the record batches. The Kafka client reading the records transparently decompresses them.
+		kgo.ProducerBatchCompression(producerCompressionCodec(w.kafkaCfg.ProducerCompression)),
 
 		// By default, the Kafka client allows 1 Produce in-flight request per broker. Disabling write idempotency
 		// (which we don't need), we can increase the max number of in-flight Produce requests per broker. A higher
 		// number of in-flight requests, in addition to short buffering ("linger") in client side before firing the
-		// next Produce request allows us to reduce the end-to-end latency.
+		// next Produce request allows us to reduce the end-to-end latency. A longer linger batches more write requests
+		// together, improving the compression ratio, at the cost of a higher write latency.
 		//
 		// The result of the multiplication of producer linger and max in-flight requests should match the maximum
 		// Produce latency expected by the Kafka backend in a steady state. For example, 50ms * 20 requests = 1s,
@@ -203,7 +214,7 @@ func (w *Writer) newKafkaWriter(partitionID int32) (*kgo.Client, error) {
 		// doesn't take longer than 1s to process them (if it takes longer, the client will buffer data and stop
 		// issuing new Produce requests until some previous ones complete).
 		kgo.DisableIdempotentWrite(),
-		kgo.ProducerLinger(50*time.Millisecond),
+		kgo.ProducerLinger(w.kafkaCfg.ProducerLinger),
 		kgo.MaxProduceRequestsInflightPerBroker(w.maxInflightProduceRequests),
 
 		// Unlimited number of Produce retries but a deadline on the max time a record can take to be delivered.
@@ -226,6 +237,51 @@ func (w *Writer) newKafkaWriter(partitionID int32) (*kgo.Client, error) {
 	return kgo.NewClient(opts...)
 }
 
+// producerCompressionCodec returns the Kafka compression codec for the input config value.
+func producerCompressionCodec(compression string) kgo.CompressionCodec {
+	switch compression {
+	case ProducerCompressionSnappy:
+		return kgo.SnappyCompression()
+	case ProducerCompressionLZ4:
+		return kgo.Lz4Compression()
+	case ProducerCompressionZstd:
+		return kgo.ZstdCompression()
+	default:
+		return kgo.NoCompression()
+	}
+}
+
+// writerBatchHook tracks the compression ratio of the record batches written by the Kafka client.
+type writerBatchHook struct {
+	compressionRatio *prometheus.HistogramVec
+}
+
+// OnProduceBatchWritten implements kgo.HookProduceBatchWritten.
+func (h *writerBatchHook) OnProduceBatchWritten(_ kgo.BrokerMetadata, _ string, _ int32, metrics kgo.ProduceBatchMetrics) {
+	if metrics.CompressedBytes <= 0 {
+		return
+	}
+
+	h.compressionRatio.WithLabelValues(batchCompressionCodecName(metrics.CompressionType)).Observe(float64(metrics.UncompressedBytes) / float64(metrics.CompressedBytes))
+}
+
+// batchCompressionCodecName returns the name of the compression codec used by a record batch,
+// as reported by kgo.ProduceBatchMetrics.
+func batchCompressionCodecName(compressionType uint8) string {
+	switch compressionType {
+	case 1:
+		return "gzip"
+	case 2:
+		return ProducerCompressionSnappy
+	case 3:
+		return ProducerCompressionLZ4
+	case 4:
+		return ProducerCompressionZstd
+	default:
+		return ProducerCompressionNone
+	}
+}
+
 type kafkaStaticPartitioner struct {
 	partitionID int
 }
@@ -16,6 +16,7 @@ import (
 	"github.com/grafana/dskit/services"
 	"github.com/prometheus/client_golang/prometheus"
 	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"github.com/twmb/franz-go/pkg/kgo"
@@ -321,6 +322,104 @@ func TestWriter_WriteSync(t *testing.T) {
 
 		wg.Wait()
 	})
+
+	t.Run("should compress the record batches with the configured codec", func(t *testing.T) {
+		t.Parallel()
+
+		for _, compression := range supportedProducerCompressions {
+			compression := compression
+
+			t.Run(compression, func(t *testing.T) {
+				t.Parallel()
+
+				var (
+					receivedCodecsMx sync.Mutex
+					receivedCodecs   []uint8
+				)
+
+				cluster, clusterAddr := testkafka.CreateCluster(t, numPartitions, topicName)
+				kafkaCfg := createTestKafkaConfig(clusterAddr, topicName)
+				kafkaCfg.ProducerCompression = compression
+				writer, reg := createTestWriter(t, kafkaCfg)
+
+				cluster.ControlKey(int16(kmsg.Produce), func(request kmsg.Request) (kmsg.Response, error, bool) {
+					for _, topic := range request.(*kmsg.ProduceRequest).Topics {
+						for _, partition := range topic.Partitions {
+							b := kmsg.RecordBatch{}
+							require.NoError(t, b.ReadFrom(partition.Records))
+
+							receivedCodecsMx.Lock()
+							receivedCodecs = append(receivedCodecs, uint8(b.Attributes&0b111))
+							receivedCodecsMx.Unlock()
+						}
+					}
+
+					return nil, nil, false
+				})
+
+				// Write a request large enough to be worth compressing.
+				var timeseries []mimirpb.PreallocTimeseries
+				for i := 0; i < 100; i++ {
+					timeseries = append(timeseries, mockPreallocTimeseries(fmt.Sprintf("series_%d", i)))
+				}
+				require.NoError(t, writer.WriteSync(ctx, partitionID, tenantID, &mimirpb.WriteRequest{Timeseries: timeseries, Source: mimirpb.API}))
+
+				receivedCodecsMx.Lock()
+				assert.Equal(t, []uint8{producerCompressionCodecType(compression)}, receivedCodecs)
+				receivedCodecsMx.Unlock()
+
+				// Read back from Kafka. The records are transparently decompressed.
+				consumer, err := kgo.NewClient(kgo.SeedBrokers(clusterAddr), kgo.ConsumePartitions(map[string]map[int32]kgo.Offset{topicName: {int32(partitionID): kgo.NewOffset().AtStart()}}))
+				require.NoError(t, err)
+				t.Cleanup(consumer.Close)
+
+				fetchCtx, cancel := context.WithTimeout(ctx, time.Second)
+				t.Cleanup(cancel)
+
+				fetches := consumer.PollFetches(fetchCtx)
+				require.NoError(t, fetches.Err())
+				require.Len(t, fetches.Records(), 1)
+
+				received := mimirpb.WriteRequest{}
+				require.NoError(t, received.Unmarshal(fetches.Records()[0].Value))
+				require.Len(t, received.Timeseries, len(timeseries))
+
+				// Check the compression ratio metric.
+				families, err := reg.Gather()
+				require.NoError(t, err)
+
+				var ratio *dto.Histogram
+				for _, f := range families {
+					if f.GetName() == "cortex_ingest_storage_writer_produce_batch_compression_ratio" {
+						require.Len(t, f.GetMetric(), 1)
+						assert.Equal(t, compression, f.GetMetric()[0].GetLabel()[0].GetValue())
+						ratio = f.GetMetric()[0].GetHistogram()
+					}
+				}
+				require.NotNil(t, ratio)
+				require.Equal(t, uint64(1), ratio.GetSampleCount())
+				if compression == ProducerCompressionNone {
+					assert.Equal(t, float64(1), ratio.GetSampleSum())
+				} else {
+					assert.Greater(t, ratio.GetSampleSum(), float64(1))
+				}
+			})
+		}
+	})
+}
+
+// producerCompressionCodecType returns the compression type of the record batch attributes for the input codec.
+func producerCompressionCodecType(compression string) uint8 {
+	switch compression {
+	case ProducerCompressionSnappy:
+		return 2
+	case ProducerCompressionLZ4:
+		return 3
+	case ProducerCompressionZstd:
+		return 4
+	default:
+		return 0
+	}
 }
 
 func mockPreallocTimeseries(metricName string) mimirpb.PreallocTimeseries {
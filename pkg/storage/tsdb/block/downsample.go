@@ -0,0 +1,475 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package block
+
+import (
+	"context"
+	"crypto/rand"
+	"math"
+	"path/filepath"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/runutil"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/prometheus/prometheus/tsdb/index"
+)
+
+const (
+	// ResolutionRaw is the resolution of blocks which haven't been downsampled.
+	ResolutionRaw = int64(0)
+	// Resolution5m is the resolution, in milliseconds, of blocks downsampled to 5 minutes.
+	Resolution5m = int64(5 * time.Minute / time.Millisecond)
+	// Resolution1h is the resolution, in milliseconds, of blocks downsampled to 1 hour.
+	Resolution1h = int64(time.Hour / time.Millisecond)
+
+	// AggregationLabel is the name of the label holding the aggregate stored by each series of a downsampled block.
+	AggregationLabel = "__aggregation__"
+
+	AggregationCount = "count"
+	AggregationMax   = "max"
+	AggregationMin   = "min"
+	AggregationSum   = "sum"
+
+	// downsampledChunkSamples is the max number of samples in each chunk of a downsampled block.
+	downsampledChunkSamples = 120
+
+	numAggregations = 4
+)
+
+// Aggregations is the list of aggregates stored by downsampled blocks, sorted by name.
+var Aggregations = []string{AggregationCount, AggregationMax, AggregationMin, AggregationSum}
+
+// Downsample writes to dir a new block with the aggregates of the samples of the block in srcDir over windows
+// of the given resolution, and returns its meta. The source block can either be a raw block, whose series are
+// downsampled to a series for each aggregate, or a block downsampled to a finer resolution, whose aggregates are
+// downsampled again.
+//
+// Each series of the downsampled block has the AggregationLabel set to the aggregate it stores, and a sample for
+// each window with samples, at the timestamp of the last sample in the window. Native histograms and stale
+// markers aren't downsampled.
+func Downsample(ctx context.Context, logger log.Logger, srcDir, dir string, resolution int64) (_ *Meta, err error) {
+	srcMeta, err := ReadMetaFromDir(srcDir)
+	if err != nil {
+		return nil, errors.Wrap(err, "read source block meta")
+	}
+	if resolution <= srcMeta.Thanos.Downsample.Resolution {
+		return nil, errors.Errorf("cannot downsample block %s with resolution %d to resolution %d", srcMeta.ULID, srcMeta.Thanos.Downsample.Resolution, resolution)
+	}
+
+	b, err := tsdb.OpenBlock(logger, srcDir, nil)
+	if err != nil {
+		return nil, errors.Wrap(err, "open source block")
+	}
+	defer runutil.CloseWithErrCapture(&err, b, "close source block")
+
+	indexr, err := b.Index()
+	if err != nil {
+		return nil, errors.Wrap(err, "open source block index")
+	}
+	defer runutil.CloseWithErrCapture(&err, indexr, "close source block index reader")
+
+	chunkr, err := b.Chunks()
+	if err != nil {
+		return nil, errors.Wrap(err, "open source block chunks")
+	}
+	defer runutil.CloseWithErrCapture(&err, chunkr, "close source block chunk reader")
+
+	id := ulid.MustNew(ulid.Now(), rand.Reader)
+	bdir := filepath.Join(dir, id.String())
+
+	d := &downsampler{
+		indexr:        indexr,
+		chunkr:        chunkr,
+		resolution:    resolution,
+		srcResolution: srcMeta.Thanos.Downsample.Resolution,
+	}
+
+	chunkw, err := chunks.NewWriter(filepath.Join(bdir, ChunksDirname))
+	if err != nil {
+		return nil, errors.Wrap(err, "open chunk writer")
+	}
+	series, stats, err := d.writeChunks(ctx, chunkw)
+	if closeErr := chunkw.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "close chunk writer")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	indexw, err := index.NewWriter(ctx, filepath.Join(bdir, IndexFilename))
+	if err != nil {
+		return nil, errors.Wrap(err, "open index writer")
+	}
+	err = d.writeIndex(ctx, indexw, series)
+	if closeErr := indexw.Close(); err == nil && closeErr != nil {
+		err = errors.Wrap(closeErr, "close index writer")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	meta := &Meta{
+		BlockMeta: tsdb.BlockMeta{
+			ULID:    id,
+			MinTime: srcMeta.MinTime,
+			MaxTime: srcMeta.MaxTime,
+			Stats:   stats,
+			// The downsampled block holds the data of the same source blocks.
+			Compaction: srcMeta.Compaction,
+			Version:    TSDBVersion1,
+		},
+		Thanos: ThanosMeta{
+			Version:           ThanosVersion1,
+			Labels:            srcMeta.Thanos.Labels,
+			Downsample:        ThanosDownsample{Resolution: resolution},
+			Source:            CompactorSource,
+			SegmentFiles:      GetSegmentFiles(bdir),
+			AppliedTombstones: srcMeta.Thanos.AppliedTombstones,
+		},
+	}
+	if err := meta.WriteToDir(logger, bdir); err != nil {
+		return nil, err
+	}
+
+	return meta, nil
+}
+
+// downsampledSeries holds the chunks written for a series of the source block, for each aggregate.
+type downsampledSeries struct {
+	ref    storage.SeriesRef
+	chunks [numAggregations][]chunks.Meta
+}
+
+type downsampler struct {
+	indexr        tsdb.IndexReader
+	chunkr        tsdb.ChunkReader
+	resolution    int64
+	srcResolution int64
+}
+
+// writeChunks downsamples the series of the source block, and writes their chunks. It returns
+// the source series for which at least a chunk has been written, in the source block order.
+func (d *downsampler) writeChunks(ctx context.Context, chunkw *chunks.Writer) ([]downsampledSeries, tsdb.BlockStats, error) {
+	var (
+		stats   tsdb.BlockStats
+		out     []downsampledSeries
+		builder labels.ScratchBuilder
+		chks    []chunks.Meta
+		it      chunkenc.Iterator
+	)
+
+	allPostingsName, allPostingsValue := index.AllPostingsKey()
+	postings, err := d.indexr.Postings(ctx, allPostingsName, allPostingsValue)
+	if err != nil {
+		return nil, stats, errors.Wrap(err, "read postings")
+	}
+	postings = d.indexr.SortedPostings(postings)
+
+	for postings.Next() {
+		if err := ctx.Err(); err != nil {
+			return nil, stats, err
+		}
+
+		ref := postings.At()
+		if err := d.indexr.Series(ref, &builder, &chks); err != nil {
+			return nil, stats, errors.Wrapf(err, "read series %d", ref)
+		}
+
+		// A raw series is downsampled to all aggregates, while an aggregate of a downsampled series
+		// is downsampled to the same aggregate.
+		outputs := [numAggregations]bool{true, true, true, true}
+		if d.srcResolution > ResolutionRaw {
+			outputs = [numAggregations]bool{}
+			aggr := aggregationIndex(builder.Labels().Get(AggregationLabel))
+			if aggr < 0 {
+				return nil, stats, errors.Errorf("series %s of downsampled block has no valid %s label", builder.Labels(), AggregationLabel)
+			}
+			outputs[aggr] = true
+		}
+
+		agg := newWindowAggregator(d.resolution, d.srcResolution == ResolutionRaw)
+		for _, chk := range chks {
+			c, iterable, err := d.chunkr.ChunkOrIterable(chk)
+			if err != nil {
+				return nil, stats, errors.Wrapf(err, "read chunk of series %s", builder.Labels())
+			}
+			if iterable != nil {
+				it = iterable.Iterator(it)
+			} else {
+				it = c.Iterator(it)
+			}
+
+			for typ := it.Next(); typ != chunkenc.ValNone; typ = it.Next() {
+				if typ != chunkenc.ValFloat {
+					continue
+				}
+				t, v := it.At()
+				if value.IsStaleNaN(v) {
+					continue
+				}
+				if err := agg.add(t, v, outputs); err != nil {
+					return nil, stats, errors.Wrapf(err, "downsample series %s", builder.Labels())
+				}
+			}
+			if err := it.Err(); err != nil {
+				return nil, stats, errors.Wrapf(err, "iterate chunk of series %s", builder.Labels())
+			}
+		}
+		if err := agg.flush(outputs); err != nil {
+			return nil, stats, errors.Wrapf(err, "downsample series %s", builder.Labels())
+		}
+
+		s := downsampledSeries{ref: ref}
+		written := false
+		for i, chunks := range agg.chunks {
+			if len(chunks) == 0 {
+				continue
+			}
+			if err := chunkw.WriteChunks(chunks...); err != nil {
+				return nil, stats, errors.Wrap(err, "write chunks")
+			}
+			for j := range chunks {
+				stats.NumSamples += uint64(chunks[j].Chunk.NumSamples())
+				stats.NumChunks++
+				// The chunk data isn't needed anymore, only the reference is.
+				chunks[j].Chunk = nil
+			}
+			s.chunks[i] = chunks
+			stats.NumSeries++
+			written = true
+		}
+		if written {
+			out = append(out, s)
+		}
+	}
+	if err := postings.Err(); err != nil {
+		return nil, stats, errors.Wrap(err, "iterate postings")
+	}
+
+	return out, stats, nil
+}
+
+// writeIndex writes the index of the downsampled series, sorted by labels.
+func (d *downsampler) writeIndex(ctx context.Context, indexw *index.Writer, series []downsampledSeries) error {
+	if err := d.writeSymbols(indexw); err != nil {
+		return err
+	}
+
+	var (
+		builder labels.ScratchBuilder
+		nextRef storage.SeriesRef
+	)
+
+	addSeries := func(s downsampledSeries, aggr int) error {
+		if len(s.chunks[aggr]) == 0 {
+			return nil
+		}
+		if err := d.indexr.Series(s.ref, &builder, nil); err != nil {
+			return errors.Wrapf(err, "read series %d", s.ref)
+		}
+
+		lset := builder.Labels()
+		if d.srcResolution == ResolutionRaw {
+			lset = labels.NewBuilder(lset).Set(AggregationLabel, Aggregations[aggr]).Labels()
+		}
+		if err := indexw.AddSeries(nextRef, lset, s.chunks[aggr]...); err != nil {
+			return errors.Wrapf(err, "add series %s", lset)
+		}
+		nextRef++
+		return nil
+	}
+
+	// The aggregation label sorts before any other label but the empty one, so the series of a raw block
+	// are sorted by aggregate first, and then by the source series order. The series of a downsampled block
+	// keep their labels, so they're sorted in the source series order.
+	if d.srcResolution == ResolutionRaw {
+		for aggr := range Aggregations {
+			for _, s := range series {
+				if err := ctx.Err(); err != nil {
+					return err
+				}
+				if err := addSeries(s, aggr); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	}
+
+	for _, s := range series {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		for aggr := range Aggregations {
+			if err := addSeries(s, aggr); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// writeSymbols writes the symbols of the source block, merged with the aggregation label and values.
+func (d *downsampler) writeSymbols(indexw *index.Writer) error {
+	extra := append([]string{AggregationLabel}, Aggregations...)
+
+	symbols := d.indexr.Symbols()
+	last := ""
+	add := func(s string) error {
+		if s == last {
+			return nil
+		}
+		last = s
+		return indexw.AddSymbol(s)
+	}
+
+	for symbols.Next() {
+		for len(extra) > 0 && extra[0] < symbols.At() {
+			if err := add(extra[0]); err != nil {
+				return errors.Wrap(err, "add symbol")
+			}
+			extra = extra[1:]
+		}
+		if err := add(symbols.At()); err != nil {
+			return errors.Wrap(err, "add symbol")
+		}
+	}
+	if err := symbols.Err(); err != nil {
+		return errors.Wrap(err, "read symbols")
+	}
+
+	for _, s := range extra {
+		if err := add(s); err != nil {
+			return errors.Wrap(err, "add symbol")
+		}
+	}
+	return nil
+}
+
+func aggregationIndex(name string) int {
+	for i, aggr := range Aggregations {
+		if aggr == name {
+			return i
+		}
+	}
+	return -1
+}
+
+// windowAggregator aggregates the samples of a series over windows of a resolution,
+// and appends the aggregates of each window to a chunk for each aggregate.
+type windowAggregator struct {
+	resolution int64
+	// raw is whether the aggregated samples are raw samples, rather than aggregates of a downsampled series.
+	raw bool
+
+	// The current window, the timestamp of its last sample, and its aggregates.
+	window     int64
+	last       int64
+	count, sum float64
+	min, max   float64
+	hasSamples bool
+
+	chunks    [numAggregations][]chunks.Meta
+	appenders [numAggregations]chunkenc.Appender
+}
+
+func newWindowAggregator(resolution int64, raw bool) *windowAggregator {
+	return &windowAggregator{resolution: resolution, raw: raw}
+}
+
+// add adds a sample to the aggregates, which must be added in timestamp order.
+func (a *windowAggregator) add(t int64, v float64, outputs [numAggregations]bool) error {
+	window := windowOf(t, a.resolution)
+	if a.hasSamples && window != a.window {
+		if err := a.flush(outputs); err != nil {
+			return err
+		}
+	}
+
+	if !a.hasSamples {
+		a.window = window
+		a.count, a.sum, a.min, a.max = 0, 0, v, v
+		a.hasSamples = true
+	}
+
+	a.last = t
+	a.count++
+	a.sum += v
+	if v < a.min || math.IsNaN(a.min) {
+		a.min = v
+	}
+	if v > a.max || math.IsNaN(a.max) {
+		a.max = v
+	}
+	return nil
+}
+
+// flush appends the aggregates of the current window to the chunks of the enabled outputs.
+func (a *windowAggregator) flush(outputs [numAggregations]bool) error {
+	if !a.hasSamples {
+		return nil
+	}
+	a.hasSamples = false
+
+	for i, enabled := range outputs {
+		if !enabled {
+			continue
+		}
+
+		var v float64
+		switch Aggregations[i] {
+		case AggregationCount:
+			// The count of raw samples is the number of samples, while the count of the counts
+			// of a downsampled series is their sum.
+			v = a.sum
+			if a.raw {
+				v = a.count
+			}
+		case AggregationMax:
+			v = a.max
+		case AggregationMin:
+			v = a.min
+		case AggregationSum:
+			v = a.sum
+		}
+
+		if err := a.append(i, a.last, v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (a *windowAggregator) append(aggr int, t int64, v float64) error {
+	chks := a.chunks[aggr]
+	if len(chks) == 0 || chks[len(chks)-1].Chunk.NumSamples() >= downsampledChunkSamples {
+		c := chunkenc.NewXORChunk()
+		app, err := c.Appender()
+		if err != nil {
+			return err
+		}
+		a.appenders[aggr] = app
+		chks = append(chks, chunks.Meta{Chunk: c, MinTime: t})
+	}
+
+	a.appenders[aggr].Append(t, v)
+	chks[len(chks)-1].MaxTime = t
+	a.chunks[aggr] = chks
+	return nil
+}
+
+// windowOf returns the index of the window of the given resolution the timestamp belongs to.
+func windowOf(t, resolution int64) int64 {
+	if t < 0 {
+		return (t+1)/resolution - 1
+	}
+	return t / resolution
+}
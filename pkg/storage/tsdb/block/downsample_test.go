@@ -0,0 +1,160 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package block
+
+import (
+	"context"
+	"math"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/histogram"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/model/value"
+	"github.com/prometheus/prometheus/tsdb"
+	"github.com/prometheus/prometheus/tsdb/chunkenc"
+	"github.com/prometheus/prometheus/tsdb/chunks"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDownsample(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+
+	// Series "a" has a sample every minute for 2 hours, with value equal to the minute, and a stale marker
+	// at the end. Series "b" has a sample every 30 seconds for the first 10 minutes only.
+	var samplesA, samplesB []chunks.Sample
+	for m := int64(0); m < 120; m++ {
+		samplesA = append(samplesA, floatSample{t: m * time.Minute.Milliseconds(), f: float64(m)})
+	}
+	samplesA = append(samplesA, floatSample{t: 120 * time.Minute.Milliseconds(), f: math.Float64frombits(value.StaleNaN)})
+	for ts := int64(0); ts < 10*time.Minute.Milliseconds(); ts += 30 * time.Second.Milliseconds() {
+		samplesB = append(samplesB, floatSample{t: ts, f: 1})
+	}
+
+	chunkA1, err := chunks.ChunkFromSamples(samplesA[:60])
+	require.NoError(t, err)
+	chunkA2, err := chunks.ChunkFromSamples(samplesA[60:])
+	require.NoError(t, err)
+	chunkB, err := chunks.ChunkFromSamples(samplesB)
+	require.NoError(t, err)
+
+	raw, err := GenerateBlockFromSpec("user-1", dir, SeriesSpecs{
+		{Labels: labels.FromStrings(labels.MetricName, "up", "job", "a"), Chunks: []chunks.Meta{chunkA1, chunkA2}},
+		{Labels: labels.FromStrings(labels.MetricName, "up", "job", "b"), Chunks: []chunks.Meta{chunkB}},
+	})
+	require.NoError(t, err)
+	raw.Thanos.Labels = map[string]string{"__compactor_shard_id__": "1_of_2"}
+	raw.Thanos.AppliedTombstones = []string{"tombstone-1"}
+	require.NoError(t, raw.WriteToDir(log.NewNopLogger(), filepath.Join(dir, raw.ULID.String())))
+
+	downsampled5m, err := Downsample(ctx, log.NewNopLogger(), filepath.Join(dir, raw.ULID.String()), dir, Resolution5m)
+	require.NoError(t, err)
+
+	t.Run("the downsampled block has the meta of the source block", func(t *testing.T) {
+		assert.NotEqual(t, raw.ULID, downsampled5m.ULID)
+		assert.Equal(t, raw.MinTime, downsampled5m.MinTime)
+		assert.Equal(t, raw.MaxTime, downsampled5m.MaxTime)
+		assert.Equal(t, raw.Compaction, downsampled5m.Compaction)
+		assert.Equal(t, raw.Thanos.Labels, downsampled5m.Thanos.Labels)
+		assert.Equal(t, raw.Thanos.AppliedTombstones, downsampled5m.Thanos.AppliedTombstones)
+		assert.Equal(t, Resolution5m, downsampled5m.Thanos.Downsample.Resolution)
+		assert.Equal(t, uint64(8), downsampled5m.Stats.NumSeries)
+
+		meta, err := ReadMetaFromDir(filepath.Join(dir, downsampled5m.ULID.String()))
+		require.NoError(t, err)
+		assert.Equal(t, downsampled5m, meta)
+	})
+
+	t.Run("raw series are downsampled to a series for each aggregate", func(t *testing.T) {
+		var expectedCountA, expectedMaxA, expectedMinA, expectedSumA []floatSample
+		for w := int64(0); w < 24; w++ {
+			last := (5*w + 4) * time.Minute.Milliseconds()
+			expectedCountA = append(expectedCountA, floatSample{t: last, f: 5})
+			expectedMaxA = append(expectedMaxA, floatSample{t: last, f: float64(5*w + 4)})
+			expectedMinA = append(expectedMinA, floatSample{t: last, f: float64(5 * w)})
+			expectedSumA = append(expectedSumA, floatSample{t: last, f: float64(25*w + 10)})
+		}
+		lastB1, lastB2 := (4*time.Minute + 30*time.Second).Milliseconds(), (9*time.Minute + 30*time.Second).Milliseconds()
+
+		assert.Equal(t, map[string][]floatSample{
+			`{__aggregation__="count", __name__="up", job="a"}`: expectedCountA,
+			`{__aggregation__="max", __name__="up", job="a"}`:   expectedMaxA,
+			`{__aggregation__="min", __name__="up", job="a"}`:   expectedMinA,
+			`{__aggregation__="sum", __name__="up", job="a"}`:   expectedSumA,
+			`{__aggregation__="count", __name__="up", job="b"}`: {{t: lastB1, f: 10}, {t: lastB2, f: 10}},
+			`{__aggregation__="max", __name__="up", job="b"}`:   {{t: lastB1, f: 1}, {t: lastB2, f: 1}},
+			`{__aggregation__="min", __name__="up", job="b"}`:   {{t: lastB1, f: 1}, {t: lastB2, f: 1}},
+			`{__aggregation__="sum", __name__="up", job="b"}`:   {{t: lastB1, f: 10}, {t: lastB2, f: 10}},
+		}, readFloatSamples(t, filepath.Join(dir, downsampled5m.ULID.String())))
+	})
+
+	t.Run("downsampled series are downsampled again to the same aggregate", func(t *testing.T) {
+		downsampled1h, err := Downsample(ctx, log.NewNopLogger(), filepath.Join(dir, downsampled5m.ULID.String()), dir, Resolution1h)
+		require.NoError(t, err)
+		assert.Equal(t, Resolution1h, downsampled1h.Thanos.Downsample.Resolution)
+		assert.Equal(t, raw.Compaction, downsampled1h.Compaction)
+
+		first, second := 59*time.Minute.Milliseconds(), 119*time.Minute.Milliseconds()
+		expected := map[string][]floatSample{
+			`{__aggregation__="count", __name__="up", job="a"}`: {{t: first, f: 60}, {t: second, f: 60}},
+			`{__aggregation__="max", __name__="up", job="a"}`:   {{t: first, f: 59}, {t: second, f: 119}},
+			`{__aggregation__="min", __name__="up", job="a"}`:   {{t: first, f: 0}, {t: second, f: 60}},
+			`{__aggregation__="sum", __name__="up", job="a"}`:   {{t: first, f: 1770}, {t: second, f: 5370}},
+			`{__aggregation__="count", __name__="up", job="b"}`: {{t: (9*time.Minute + 30*time.Second).Milliseconds(), f: 20}},
+			`{__aggregation__="max", __name__="up", job="b"}`:   {{t: (9*time.Minute + 30*time.Second).Milliseconds(), f: 1}},
+			`{__aggregation__="min", __name__="up", job="b"}`:   {{t: (9*time.Minute + 30*time.Second).Milliseconds(), f: 1}},
+			`{__aggregation__="sum", __name__="up", job="b"}`:   {{t: (9*time.Minute + 30*time.Second).Milliseconds(), f: 20}},
+		}
+		assert.Equal(t, expected, readFloatSamples(t, filepath.Join(dir, downsampled1h.ULID.String())))
+
+		// Downsampling the raw block to the same resolution gives the same result.
+		downsampledRaw1h, err := Downsample(ctx, log.NewNopLogger(), filepath.Join(dir, raw.ULID.String()), dir, Resolution1h)
+		require.NoError(t, err)
+		assert.Equal(t, expected, readFloatSamples(t, filepath.Join(dir, downsampledRaw1h.ULID.String())))
+	})
+
+	t.Run("should fail to downsample a block to a resolution not coarser than its own", func(t *testing.T) {
+		_, err := Downsample(ctx, log.NewNopLogger(), filepath.Join(dir, downsampled5m.ULID.String()), dir, Resolution5m)
+		require.Error(t, err)
+	})
+}
+
+type floatSample struct {
+	t int64
+	f float64
+}
+
+func (s floatSample) T() int64                      { return s.t }
+func (s floatSample) F() float64                    { return s.f }
+func (s floatSample) H() *histogram.Histogram       { return nil }
+func (s floatSample) FH() *histogram.FloatHistogram { return nil }
+func (s floatSample) Type() chunkenc.ValueType      { return chunkenc.ValFloat }
+
+// readFloatSamples returns the float samples of each series of the block in blockDir.
+func readFloatSamples(t *testing.T, blockDir string) map[string][]floatSample {
+	b, err := tsdb.OpenBlock(nil, blockDir, nil)
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, b.Close()) })
+
+	q, err := tsdb.NewBlockQuerier(b, b.MinTime(), b.MaxTime())
+	require.NoError(t, err)
+	t.Cleanup(func() { require.NoError(t, q.Close()) })
+
+	samples := map[string][]floatSample{}
+	set := q.Select(context.Background(), true, nil, labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".+"))
+	for set.Next() {
+		series := set.At()
+		it := series.Iterator(nil)
+		for it.Next() == chunkenc.ValFloat {
+			ts, v := it.At()
+			samples[series.Labels().String()] = append(samples[series.Labels().String()], floatSample{t: ts, f: v})
+		}
+		require.NoError(t, it.Err())
+	}
+	require.NoError(t, set.Err())
+	return samples
+}
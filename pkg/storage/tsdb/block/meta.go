@@ -73,6 +73,10 @@ type ThanosMeta struct {
 	// Useful to avoid API call to get size of each file, as well as for debugging purposes.
 	// Optional, added in v0.17.0.
 	Files []File `json:"files,omitempty"`
+
+	// AppliedTombstones is the list of series deletion tombstone IDs whose deletions have been
+	// applied to this block. Optional.
+	AppliedTombstones []string `json:"applied_tombstones,omitempty"`
 }
 
 type Matchers []*labels.Matcher
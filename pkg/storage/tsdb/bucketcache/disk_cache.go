@@ -0,0 +1,180 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketcache
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/cache"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+
+	"github.com/grafana/mimir/pkg/util/disklru"
+)
+
+var _ cache.Cache = (*DiskCache)(nil)
+
+// DiskCache is a size-bounded LRU cache storing each item in a file on the local disk, optionally
+// in front of another cache. Items are always stored in both caches, while they're fetched from the
+// next cache only if they're missing on disk. Items fetched from the next cache are stored on disk
+// with a default TTL. Items are stored in a disklru.Cache, which writes them to disk asynchronously.
+type DiskCache struct {
+	next       cache.Cache
+	name       string
+	logger     log.Logger
+	cache      *disklru.Cache
+	defaultTTL time.Duration
+
+	requests  *prometheus.CounterVec
+	hits      *prometheus.CounterVec
+	evicted   prometheus.Counter
+	corrupted prometheus.Counter
+}
+
+// NewDiskCache makes a new DiskCache storing up to maxSizeBytes in dir. The next cache is optional.
+// The items fetched from the next cache are stored on disk with the defaultTTL.
+func NewDiskCache(name string, next cache.Cache, dir string, maxSizeBytes uint64, defaultTTL time.Duration, logger log.Logger, reg prometheus.Registerer) (*DiskCache, error) {
+	c := &DiskCache{
+		next:       next,
+		name:       name,
+		logger:     logger,
+		defaultTTL: defaultTTL,
+	}
+
+	c.requests = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name:        "cortex_cache_disk_requests_total",
+		Help:        "Total number of requests to the on-disk cache, by tenant.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, []string{"user"})
+	c.hits = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name:        "cortex_cache_disk_hits_total",
+		Help:        "Total number of requests to the on-disk cache that were a hit, by tenant.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, []string{"user"})
+	c.evicted = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "cortex_cache_disk_items_evicted_total",
+		Help:        "Total number of items evicted from the on-disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+	c.corrupted = promauto.With(reg).NewCounter(prometheus.CounterOpts{
+		Name:        "cortex_cache_disk_items_corrupted_total",
+		Help:        "Total number of items read from the on-disk cache which failed the checksum verification and have been discarded.",
+		ConstLabels: prometheus.Labels{"name": name},
+	})
+
+	dc, err := disklru.New(disklru.Config{Dir: dir, MaxSizeBytes: maxSizeBytes}, disklru.Hooks{
+		OnRemoved: func(_ string, _ uint64, evicted bool) {
+			if evicted {
+				c.evicted.Inc()
+			}
+		},
+		OnCorrupted: func(string) { c.corrupted.Inc() },
+	}, log.With(logger, "name", name))
+	if err != nil {
+		return nil, errors.Wrap(err, "load on-disk cache items")
+	}
+	c.cache = dc
+
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "cortex_cache_disk_items",
+		Help:        "Current number of items in the on-disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		return float64(c.cache.Len())
+	})
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "cortex_cache_disk_size_bytes",
+		Help:        "Current byte size of the items in the on-disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		return float64(c.cache.Size())
+	})
+	promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name:        "cortex_cache_disk_max_size_bytes",
+		Help:        "Maximum number of bytes to be held in the on-disk cache.",
+		ConstLabels: prometheus.Labels{"name": name},
+	}, func() float64 {
+		return float64(maxSizeBytes)
+	})
+
+	level.Info(logger).Log("msg", "created on-disk cache", "name", name, "dir", dir, "maxSizeBytes", maxSizeBytes, "items", c.cache.Len(), "curSize", c.cache.Size())
+	return c, nil
+}
+
+// StoreAsync queues the data to be stored on disk, and stores it in the next cache.
+func (c *DiskCache) StoreAsync(data map[string][]byte, ttl time.Duration) {
+	expiresAt := time.Now().Add(ttl)
+	for key, val := range data {
+		c.cache.Set("", key, val, expiresAt)
+	}
+
+	if c.next != nil {
+		c.next.StoreAsync(data, ttl)
+	}
+}
+
+// Fetch fetches the keys from disk, and the missing ones from the next cache.
+func (c *DiskCache) Fetch(ctx context.Context, keys []string, opts ...cache.Option) map[string][]byte {
+	found := make(map[string][]byte, len(keys))
+	var misses []string
+
+	for _, key := range keys {
+		tenantID := tenantFromCachingKey(key)
+		c.requests.WithLabelValues(tenantID).Inc()
+
+		if val, ok := c.cache.Get("", key); ok {
+			c.hits.WithLabelValues(tenantID).Inc()
+			found[key] = val
+			continue
+		}
+		misses = append(misses, key)
+	}
+
+	if c.next == nil || len(misses) == 0 {
+		return found
+	}
+
+	// We don't know the TTL of the items fetched from the next cache, so we use the default one.
+	expiresAt := time.Now().Add(c.defaultTTL)
+	for key, val := range c.next.Fetch(ctx, misses, opts...) {
+		c.cache.Set("", key, val, expiresAt)
+		found[key] = val
+	}
+	return found
+}
+
+// Delete deletes the item with the given key from disk and from the next cache.
+func (c *DiskCache) Delete(ctx context.Context, key string) error {
+	c.cache.Remove("", key)
+
+	if c.next != nil {
+		return c.next.Delete(ctx, key)
+	}
+	return nil
+}
+
+func (c *DiskCache) Name() string {
+	return "disk-" + c.name
+}
+
+// tenantFromCachingKey returns the tenant of the object referenced by a caching key built by
+// composeCachingKey, assuming the object name is prefixed by the tenant ID. Returns an empty
+// string if the key doesn't reference a tenant object.
+func tenantFromCachingKey(key string) string {
+	for _, part := range strings.Split(key, ":") {
+		if idx := strings.IndexByte(part, '/'); idx > 0 {
+			return part[:idx]
+		}
+	}
+	return ""
+}
+
+// Stop stops the worker writing the items to disk.
+func (c *DiskCache) Stop() {
+	c.cache.Stop()
+}
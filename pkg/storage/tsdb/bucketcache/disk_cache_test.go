@@ -0,0 +1,183 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package bucketcache
+
+import (
+	"bytes"
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/cache"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/util/disklru"
+)
+
+func TestDiskCache_StoreAndFetch(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	next := cache.NewMockCache()
+
+	reg := prometheus.NewPedanticRegistry()
+	c, err := NewDiskCache("test", next, dir, 1024*1024, time.Hour, log.NewNopLogger(), reg)
+	require.NoError(t, err)
+	t.Cleanup(c.Stop)
+
+	key1 := cachingKeyObjectSubrange("", "user-1/block/chunks/000001", 0, 16000)
+	key2 := cachingKeyObjectSubrange("", "user-2/block/chunks/000001", 0, 16000)
+	key3 := cachingKeyObjectSubrange("", "user-2/block/chunks/000002", 0, 16000)
+
+	c.StoreAsync(map[string][]byte{key1: []byte("data-1")}, time.Hour)
+	next.StoreAsync(map[string][]byte{key2: []byte("data-2")}, time.Hour)
+	c.cache.Flush()
+
+	// The item stored via the on-disk cache should have been stored in the next cache too.
+	assert.Equal(t, map[string][]byte{key1: []byte("data-1")}, next.Fetch(ctx, []string{key1}))
+
+	// The item only in the next cache should be fetched from it, and stored on disk.
+	assert.Equal(t, map[string][]byte{key1: []byte("data-1"), key2: []byte("data-2")}, c.Fetch(ctx, []string{key1, key2, key3}))
+	c.cache.Flush()
+	next.Flush()
+	assert.Equal(t, map[string][]byte{key1: []byte("data-1"), key2: []byte("data-2")}, c.Fetch(ctx, []string{key1, key2, key3}))
+
+	assert.NoError(t, promtest.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_cache_disk_requests_total Total number of requests to the on-disk cache, by tenant.
+		# TYPE cortex_cache_disk_requests_total counter
+		cortex_cache_disk_requests_total{name="test",user="user-1"} 2
+		cortex_cache_disk_requests_total{name="test",user="user-2"} 4
+
+		# HELP cortex_cache_disk_hits_total Total number of requests to the on-disk cache that were a hit, by tenant.
+		# TYPE cortex_cache_disk_hits_total counter
+		cortex_cache_disk_hits_total{name="test",user="user-1"} 2
+		cortex_cache_disk_hits_total{name="test",user="user-2"} 1
+
+		# HELP cortex_cache_disk_items Current number of items in the on-disk cache.
+		# TYPE cortex_cache_disk_items gauge
+		cortex_cache_disk_items{name="test"} 2
+	`), "cortex_cache_disk_requests_total", "cortex_cache_disk_hits_total", "cortex_cache_disk_items"))
+
+	// A new cache on the same directory should load the previously stored items.
+	reloaded, err := NewDiskCache("test", nil, dir, 1024*1024, time.Hour, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	t.Cleanup(reloaded.Stop)
+	assert.Equal(t, c.cache.Size(), reloaded.cache.Size())
+	assert.Equal(t, map[string][]byte{key1: []byte("data-1"), key2: []byte("data-2")}, reloaded.Fetch(ctx, []string{key1, key2, key3}))
+
+	// Deleted items should be removed from disk.
+	require.NoError(t, reloaded.Delete(ctx, key1))
+	assert.Equal(t, map[string][]byte{key2: []byte("data-2")}, reloaded.Fetch(ctx, []string{key1, key2}))
+}
+
+func TestDiskCache_FetchedItemsExpire(t *testing.T) {
+	ctx := context.Background()
+	next := cache.NewMockCache()
+
+	c, err := NewDiskCache("test", next, t.TempDir(), 1024*1024, 10*time.Millisecond, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	t.Cleanup(c.Stop)
+
+	next.StoreAsync(map[string][]byte{"key-1": []byte("data-1")}, time.Hour)
+	assert.Equal(t, map[string][]byte{"key-1": []byte("data-1")}, c.Fetch(ctx, []string{"key-1"}))
+	c.cache.Flush()
+	require.NoError(t, next.Delete(ctx, "key-1"))
+	assert.Equal(t, map[string][]byte{"key-1": []byte("data-1")}, c.Fetch(ctx, []string{"key-1"}))
+
+	// The item fetched from the next cache should be stored on disk with the default TTL.
+	time.Sleep(20 * time.Millisecond)
+	assert.Empty(t, c.Fetch(ctx, []string{"key-1"}))
+	assert.Equal(t, 0, c.cache.Len())
+}
+
+func TestDiskCache_EvictedAndCorruptedItems(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	value := bytes.Repeat([]byte("x"), 100)
+	itemSize := disklru.ItemSize("key-1", value)
+
+	c, err := NewDiskCache("test", nil, dir, 2*itemSize+itemSize/2, time.Hour, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+	t.Cleanup(c.Stop)
+
+	c.StoreAsync(map[string][]byte{"key-1": value, "key-2": value}, time.Hour)
+	c.cache.Flush()
+	c.StoreAsync(map[string][]byte{"key-3": value}, time.Hour)
+	c.cache.Flush()
+	assert.Len(t, c.Fetch(ctx, []string{"key-1", "key-2", "key-3"}), 2)
+	assert.Equal(t, float64(1), promtest.ToFloat64(c.evicted))
+
+	// Simulate a torn write of the items.
+	var files []string
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	}))
+	require.Len(t, files, 2)
+	for _, path := range files {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		content[len(content)-1] ^= 0xff
+		require.NoError(t, os.WriteFile(path, content, 0o600))
+	}
+
+	assert.Empty(t, c.Fetch(ctx, []string{"key-1", "key-2", "key-3"}))
+	assert.Equal(t, float64(2), promtest.ToFloat64(c.corrupted))
+}
+
+func TestDiskCache_CachingBucket(t *testing.T) {
+	const cfgName = "chunks"
+
+	ctx := context.Background()
+	dir := t.TempDir()
+	name := "user-1/block/chunks/000001"
+	data := make([]byte, 64000)
+	for ix := 0; ix < len(data); ix++ {
+		data[ix] = byte(ix)
+	}
+
+	inmem := objstore.NewInMemBucket()
+	require.NoError(t, inmem.Upload(ctx, name, bytes.NewReader(data)))
+
+	// The attributes are cached in the remote cache, which survives restarts.
+	attributesCache := cache.NewMockCache()
+	newCachingBucket := func() (*CachingBucket, *DiskCache) {
+		c, err := NewDiskCache("chunks-cache", nil, dir, 1024*1024, time.Hour, log.NewNopLogger(), nil)
+		require.NoError(t, err)
+		t.Cleanup(c.Stop)
+
+		cfg := NewCachingBucketConfig()
+		cfg.CacheGetRange(cfgName, c, isTSDBChunkFile, 16000, attributesCache, time.Hour, time.Hour, 3)
+		cachingBucket, err := NewCachingBucket("", inmem, cfg, nil, nil)
+		require.NoError(t, err)
+		return cachingBucket, c
+	}
+
+	cachingBucket, diskCache := newCachingBucket()
+	verifyGetRange(ctx, t, cachingBucket, name, 1000, 20000, 20000)
+	diskCache.cache.Flush()
+	assert.Equal(t, float64(32000), promtest.ToFloat64(cachingBucket.fetchedGetRangeBytes.WithLabelValues(originBucket, cfgName)))
+
+	// After a restart, the subranges should be served from disk.
+	cachingBucket, _ = newCachingBucket()
+	verifyGetRange(ctx, t, cachingBucket, name, 1000, 20000, 20000)
+	assert.Equal(t, float64(0), promtest.ToFloat64(cachingBucket.fetchedGetRangeBytes.WithLabelValues(originBucket, cfgName)))
+	assert.Equal(t, float64(32000), promtest.ToFloat64(cachingBucket.fetchedGetRangeBytes.WithLabelValues(originCache, cfgName)))
+}
+
+func TestTenantFromCachingKey(t *testing.T) {
+	assert.Equal(t, "user-1", tenantFromCachingKey(cachingKeyObjectSubrange("", "user-1/block/chunks/000001", 0, 16000)))
+	assert.Equal(t, "user-1", tenantFromCachingKey(cachingKeyObjectSubrange("bucket", "user-1/block/chunks/000001", 0, 16000)))
+	assert.Equal(t, "user-1", tenantFromCachingKey(cachingKeyAttributes("", "user-1/block/chunks/000001")))
+	assert.Equal(t, "", tenantFromCachingKey("key"))
+}
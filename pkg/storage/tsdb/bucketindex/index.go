@@ -42,6 +42,9 @@ type Index struct {
 	// List of block deletion marks.
 	BlockDeletionMarks BlockDeletionMarks `json:"block_deletion_marks"`
 
+	// List of series deletion tombstones.
+	Tombstones mimir_tsdb.Tombstones `json:"tombstones,omitempty"`
+
 	// UpdatedAt is a unix timestamp (seconds precision) of when the index has been updated
 	// (written in the storage) the last time.
 	UpdatedAt int64 `json:"updated_at"`
@@ -90,6 +93,14 @@ type Block struct {
 
 	// Block's compactor shard ID, copied from tsdb.CompactorShardIDExternalLabel label.
 	CompactorShardID string `json:"compactor_shard_id,omitempty"`
+
+	// Resolution of the block's samples (millis precision), copied from the block's downsampling meta.
+	// Raw blocks have 0 resolution.
+	Resolution int64 `json:"resolution,omitempty"`
+
+	// NumSeries is the number of series in the block, copied from the block's stats. It's 0 for the blocks
+	// added to the index before the stats were stored in it.
+	NumSeries uint64 `json:"num_series,omitempty"`
 }
 
 // Within returns whether the block contains samples within the provided range.
@@ -113,10 +124,12 @@ func (m *Block) ThanosMeta() *block.Meta {
 			MinTime: m.MinTime,
 			MaxTime: m.MaxTime,
 			Version: block.TSDBVersion1,
+			Stats:   tsdb.BlockStats{NumSeries: m.NumSeries},
 		},
 		Thanos: block.ThanosMeta{
 			Version:      block.ThanosVersion1,
 			SegmentFiles: m.thanosMetaSegmentFiles(),
+			Downsample:   block.ThanosDownsample{Resolution: m.Resolution},
 		},
 	}
 }
@@ -153,6 +166,8 @@ func BlockFromThanosMeta(meta block.Meta) *Block {
 		SegmentsFormat:   segmentsFormat,
 		SegmentsNum:      segmentsNum,
 		CompactorShardID: meta.Thanos.Labels[mimir_tsdb.CompactorShardIDExternalLabel],
+		Resolution:       meta.Thanos.Downsample.Resolution,
+		NumSeries:        meta.Stats.NumSeries,
 	}
 }
 
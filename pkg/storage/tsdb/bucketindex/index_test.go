@@ -160,6 +160,24 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				SegmentsNum:    0,
 			},
 		},
+		"meta.json with stats": {
+			meta: block.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+					Stats:   tsdb.BlockStats{NumSeries: 100},
+				},
+				Thanos: block.ThanosMeta{},
+			},
+			expected: Block{
+				ID:             blockID,
+				MinTime:        10,
+				MaxTime:        20,
+				SegmentsFormat: SegmentsFormatUnknown,
+				NumSeries:      100,
+			},
+		},
 		"meta.json with SegmentFiles": {
 			meta: block.Meta{
 				BlockMeta: tsdb.BlockMeta{
@@ -272,6 +290,24 @@ func TestBlockFromThanosMeta(t *testing.T) {
 				CompactorShardID: "some weird value",
 			},
 		},
+		"meta.json of a downsampled block": {
+			meta: block.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+				},
+				Thanos: block.ThanosMeta{
+					Downsample: block.ThanosDownsample{Resolution: block.Resolution5m},
+				},
+			},
+			expected: Block{
+				ID:         blockID,
+				MinTime:    10,
+				MaxTime:    20,
+				Resolution: block.Resolution5m,
+			},
+		},
 	}
 
 	for testName, testData := range tests {
@@ -378,6 +414,26 @@ func TestBlock_ThanosMeta(t *testing.T) {
 				},
 			},
 		},
+		"downsampled block": {
+			block: Block{
+				ID:         blockID,
+				MinTime:    10,
+				MaxTime:    20,
+				Resolution: block.Resolution1h,
+			},
+			expected: &block.Meta{
+				BlockMeta: tsdb.BlockMeta{
+					ULID:    blockID,
+					MinTime: 10,
+					MaxTime: 20,
+					Version: block.TSDBVersion1,
+				},
+				Thanos: block.ThanosMeta{
+					Version:    block.ThanosVersion1,
+					Downsample: block.ThanosDownsample{Resolution: block.Resolution1h},
+				},
+			},
+		},
 	}
 
 	for testName, testData := range tests {
@@ -20,6 +20,7 @@ import (
 	"github.com/thanos-io/objstore"
 
 	"github.com/grafana/mimir/pkg/storage/bucket"
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
 )
 
@@ -48,11 +49,13 @@ func NewUpdater(bkt objstore.Bucket, userID string, cfgProvider bucket.TenantCon
 func (w *Updater) UpdateIndex(ctx context.Context, old *Index) (*Index, map[ulid.ULID]error, error) {
 	var oldBlocks []*Block
 	var oldBlockDeletionMarks []*BlockDeletionMark
+	var oldTombstones mimir_tsdb.Tombstones
 
 	// Use the old index if provided, and it is using the latest version format.
 	if old != nil && old.Version == IndexVersion2 {
 		oldBlocks = old.Blocks
 		oldBlockDeletionMarks = old.BlockDeletionMarks
+		oldTombstones = old.Tombstones
 	}
 
 	blocks, partials, err := w.updateBlocks(ctx, oldBlocks)
@@ -65,10 +68,16 @@ func (w *Updater) UpdateIndex(ctx context.Context, old *Index) (*Index, map[ulid
 		return nil, nil, err
 	}
 
+	tombstones, err := w.updateTombstones(ctx, oldTombstones)
+	if err != nil {
+		return nil, nil, err
+	}
+
 	return &Index{
 		Version:            IndexVersion2,
 		Blocks:             blocks,
 		BlockDeletionMarks: blockDeletionMarks,
+		Tombstones:         tombstones,
 		UpdatedAt:          time.Now().Unix(),
 	}, partials, nil
 }
@@ -232,3 +241,45 @@ func (w *Updater) updateBlockDeletionMarkIndexEntry(ctx context.Context, id ulid
 
 	return BlockDeletionMarkFromThanosMarker(&m), nil
 }
+
+func (w *Updater) updateTombstones(ctx context.Context, old mimir_tsdb.Tombstones) (mimir_tsdb.Tombstones, error) {
+	// Find all tombstones in the storage.
+	discovered, err := mimir_tsdb.ListTombstones(ctx, w.bkt)
+	if err != nil {
+		return nil, err
+	}
+
+	// Since tombstones are immutable, all tombstones already existing in the index can just be copied.
+	var out mimir_tsdb.Tombstones
+	for _, t := range old {
+		if _, ok := discovered[t.ID]; ok {
+			out = append(out, t)
+			delete(discovered, t.ID)
+		}
+	}
+
+	// Remaining tombstones are new ones and we have to fetch them.
+	for id := range discovered {
+		t, err := mimir_tsdb.ReadTombstone(ctx, w.bkt, id, w.logger)
+		if errors.Is(err, mimir_tsdb.ErrTombstoneNotFound) {
+			// This could happen if the tombstone is deleted between the "list objects" and now.
+			level.Warn(w.logger).Log("msg", "skipped missing tombstone when updating bucket index", "tombstone", id)
+			continue
+		}
+		if errors.Is(err, mimir_tsdb.ErrTombstoneCorrupted) {
+			level.Error(w.logger).Log("msg", "skipped corrupted tombstone when updating bucket index", "tombstone", id, "err", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		out = append(out, t)
+	}
+
+	if len(out) > 0 {
+		level.Info(w.logger).Log("msg", "updated series deletion tombstones", "count", len(discovered), "total_tombstones", len(out))
+	}
+
+	return out, nil
+}
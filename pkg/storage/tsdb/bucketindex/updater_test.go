@@ -156,6 +156,41 @@ func TestUpdater_UpdateIndex_ShouldSkipCorruptedDeletionMarks(t *testing.T) {
 	assert.Empty(t, partials)
 }
 
+func TestUpdater_UpdateIndex_ShouldIncludeTombstones(t *testing.T) {
+	const userID = "user-1"
+
+	bkt, _ := testutil.PrepareFilesystemBucket(t)
+
+	ctx := context.Background()
+	logger := log.NewNopLogger()
+
+	tombstone1, err := mimir_tsdb.NewTombstone([]string{`{job="a"}`}, 10, 20, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, mimir_tsdb.WriteTombstone(ctx, bkt, userID, nil, tombstone1))
+
+	w := NewUpdater(bkt, userID, nil, logger)
+	idx, _, err := w.UpdateIndex(ctx, nil)
+	require.NoError(t, err)
+	assert.Equal(t, mimir_tsdb.Tombstones{tombstone1}, idx.Tombstones)
+
+	// Add a tombstone and a corrupted one, and update the index.
+	tombstone2, err := mimir_tsdb.NewTombstone([]string{`{job="b"}`}, 10, 20, time.Now())
+	require.NoError(t, err)
+	require.NoError(t, mimir_tsdb.WriteTombstone(ctx, bkt, userID, nil, tombstone2))
+	require.NoError(t, bkt.Upload(ctx, path.Join(userID, mimir_tsdb.TombstoneFilepath("corrupted")), bytes.NewReader([]byte("invalid!}"))))
+
+	idx, _, err = w.UpdateIndex(ctx, idx)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, mimir_tsdb.Tombstones{tombstone1, tombstone2}, idx.Tombstones)
+
+	// Delete a tombstone, and update the index.
+	require.NoError(t, bkt.Delete(ctx, path.Join(userID, mimir_tsdb.TombstoneFilepath(tombstone1.ID))))
+
+	idx, _, err = w.UpdateIndex(ctx, idx)
+	require.NoError(t, err)
+	assert.Equal(t, mimir_tsdb.Tombstones{tombstone2}, idx.Tombstones)
+}
+
 func TestUpdater_UpdateIndex_NoTenantInTheBucket(t *testing.T) {
 	const userID = "user-1"
 
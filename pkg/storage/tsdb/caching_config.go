@@ -6,12 +6,14 @@
 package tsdb
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"path/filepath"
 	"strings"
 	"time"
 
+	"github.com/alecthomas/units"
 	"github.com/go-kit/log"
 	"github.com/grafana/dskit/cache"
 	"github.com/grafana/dskit/tenant"
@@ -28,7 +30,12 @@ import (
 // subrangeSize is the size of each subrange that bucket objects are split into for better caching
 const subrangeSize int64 = 16000
 
-var supportedCacheBackends = []string{cache.BackendMemcached, cache.BackendRedis}
+var (
+	supportedCacheBackends = []string{cache.BackendMemcached, cache.BackendRedis}
+
+	errInvalidChunksDiskCacheDir     = errors.New("the chunks on-disk cache directory must be set")
+	errInvalidChunksDiskCacheMaxSize = errors.New("the chunks on-disk cache max size must be greater than 0")
+)
 
 type ChunksCacheConfig struct {
 	cache.BackendConfig `yaml:",inline"`
@@ -37,6 +44,8 @@ type ChunksCacheConfig struct {
 	AttributesTTL              time.Duration `yaml:"attributes_ttl" category:"advanced"`
 	AttributesInMemoryMaxItems int           `yaml:"attributes_in_memory_max_items" category:"advanced"`
 	SubrangeTTL                time.Duration `yaml:"subrange_ttl" category:"advanced"`
+
+	Disk ChunksDiskCacheConfig `yaml:"disk"`
 }
 
 func (cfg *ChunksCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
@@ -49,12 +58,43 @@ func (cfg *ChunksCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix st
 	f.DurationVar(&cfg.AttributesTTL, prefix+"attributes-ttl", 168*time.Hour, "TTL for caching object attributes for chunks. If the metadata cache is configured, attributes will be stored under this cache backend, otherwise attributes are stored in the chunks cache backend.")
 	f.IntVar(&cfg.AttributesInMemoryMaxItems, prefix+"attributes-in-memory-max-items", 50000, "Maximum number of object attribute items to keep in a first level in-memory LRU cache. Metadata will be stored and fetched in-memory before hitting the cache backend. 0 to disable the in-memory cache.")
 	f.DurationVar(&cfg.SubrangeTTL, prefix+"subrange-ttl", 24*time.Hour, "TTL for caching individual chunks subranges.")
+
+	cfg.Disk.RegisterFlagsWithPrefix(f, prefix+"disk.")
 }
 
 func (cfg *ChunksCacheConfig) Validate() error {
+	if err := cfg.Disk.Validate(); err != nil {
+		return err
+	}
 	return cfg.BackendConfig.Validate()
 }
 
+// ChunksDiskCacheConfig configures the store-gateway chunks cache on the local disk.
+type ChunksDiskCacheConfig struct {
+	Enabled      bool   `yaml:"enabled" category:"experimental"`
+	Dir          string `yaml:"dir" category:"experimental"`
+	MaxSizeBytes uint64 `yaml:"max_size_bytes" category:"experimental"`
+}
+
+func (cfg *ChunksDiskCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix string) {
+	f.BoolVar(&cfg.Enabled, prefix+"enabled", false, "If enabled, the store-gateway caches the chunks subranges on the local disk, in front of the chunks cache backend if configured.")
+	f.StringVar(&cfg.Dir, prefix+"dir", "./chunks-cache/", "Directory to store the chunks subranges cached on disk.")
+	f.Uint64Var(&cfg.MaxSizeBytes, prefix+"max-size-bytes", uint64(10*units.Gibibyte), "Maximum size in bytes of the chunks subranges cached on disk (shared between all tenants).")
+}
+
+func (cfg *ChunksDiskCacheConfig) Validate() error {
+	if !cfg.Enabled {
+		return nil
+	}
+	if cfg.Dir == "" {
+		return errInvalidChunksDiskCacheDir
+	}
+	if cfg.MaxSizeBytes == 0 {
+		return errInvalidChunksDiskCacheMaxSize
+	}
+	return nil
+}
+
 type MetadataCacheConfig struct {
 	cache.BackendConfig `yaml:",inline"`
 
@@ -94,7 +134,9 @@ func (cfg *MetadataCacheConfig) Validate() error {
 	return cfg.BackendConfig.Validate()
 }
 
-func CreateCachingBucket(chunksCache cache.Cache, chunksConfig ChunksCacheConfig, metadataConfig MetadataCacheConfig, bkt objstore.Bucket, logger log.Logger, reg prometheus.Registerer) (objstore.Bucket, error) {
+// CreateCachingBucket wraps the bucket with the configured caches. The chunksDiskCache, if not nil, is used
+// only for the chunks subranges, in front of the chunksCache.
+func CreateCachingBucket(chunksCache, chunksDiskCache cache.Cache, chunksConfig ChunksCacheConfig, metadataConfig MetadataCacheConfig, bkt objstore.Bucket, logger log.Logger, reg prometheus.Registerer) (objstore.Bucket, error) {
 	cfg := bucketcache.NewCachingBucketConfig()
 	cachingConfigured := false
 
@@ -118,25 +160,35 @@ func CreateCachingBucket(chunksCache cache.Cache, chunksConfig ChunksCacheConfig
 		cfg.CacheIter("chunks-iter", metadataCache, isChunksDir, metadataConfig.ChunksListTTL, codec)
 	}
 
-	if chunksCache != nil {
-		// If the chunks cache is configured, we will use it for the attributes of chunk files instead of
-		// the metadata cache.
+	if chunksCache != nil || chunksDiskCache != nil {
 		cachingConfigured = true
-		chunksCache = cache.NewSpanlessTracingCache(chunksCache, logger, tenant.NewMultiResolver())
+
+		// The on-disk cache, if configured, only caches the chunks subranges, in front of the chunks cache.
+		var subrangesCache, attributesCache cache.Cache
+		if chunksCache != nil {
+			chunksCache = cache.NewSpanlessTracingCache(chunksCache, logger, tenant.NewMultiResolver())
+			subrangesCache, attributesCache = chunksCache, chunksCache
+		}
+		if chunksDiskCache != nil {
+			subrangesCache = cache.NewSpanlessTracingCache(chunksDiskCache, logger, tenant.NewMultiResolver())
+		}
 
 		// Use the metadata cache for attributes if configured, otherwise fallback to chunks cache.
 		// If in-memory cache is enabled, wrap the attributes cache with the in-memory LRU cache.
-		attributesCache := chunksCache
 		if metadataCache != nil {
 			attributesCache = metadataCache
 		}
+		if attributesCache == nil {
+			// Only the on-disk cache is configured, so the attributes are only cached in-memory, if enabled.
+			attributesCache = noopCache{}
+		}
 		if chunksConfig.AttributesInMemoryMaxItems > 0 {
 			attributesCache, err = cache.WrapWithLRUCache(attributesCache, "chunks-attributes-cache", prometheus.WrapRegistererWithPrefix("cortex_", reg), chunksConfig.AttributesInMemoryMaxItems, chunksConfig.AttributesTTL)
 			if err != nil {
 				return nil, errors.Wrapf(err, "wrap metadata cache with in-memory cache")
 			}
 		}
-		cfg.CacheGetRange("chunks", chunksCache, isTSDBChunkFile, subrangeSize, attributesCache, chunksConfig.AttributesTTL, chunksConfig.SubrangeTTL, chunksConfig.MaxGetRangeRequests)
+		cfg.CacheGetRange("chunks", subrangesCache, isTSDBChunkFile, subrangeSize, attributesCache, chunksConfig.AttributesTTL, chunksConfig.SubrangeTTL, chunksConfig.MaxGetRangeRequests)
 	}
 
 	if !cachingConfigured {
@@ -151,6 +203,17 @@ func CreateCachingBucket(chunksCache cache.Cache, chunksConfig ChunksCacheConfig
 	return bucketcache.NewCachingBucket("", bkt, cfg, logger, reg)
 }
 
+// noopCache is a cache.Cache which doesn't store anything.
+type noopCache struct{}
+
+func (noopCache) StoreAsync(map[string][]byte, time.Duration) {}
+
+func (noopCache) Fetch(context.Context, []string, ...cache.Option) map[string][]byte { return nil }
+
+func (noopCache) Delete(context.Context, string) error { return nil }
+
+func (noopCache) Name() string { return "noop" }
+
 var chunksMatcher = regexp.MustCompile(`^.*/chunks/\d+$`)
 
 func isTSDBChunkFile(name string) bool { return chunksMatcher.MatchString(name) }
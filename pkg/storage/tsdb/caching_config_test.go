@@ -6,11 +6,19 @@
 package tsdb
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/cache"
 	"github.com/oklog/ulid"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
 )
 
 func TestIsTenantDir(t *testing.T) {
@@ -39,3 +47,45 @@ func TestIsBlockIndexFile(t *testing.T) {
 	assert.True(t, isBlockIndexFile(fmt.Sprintf("%s/index", blockID.String())))
 	assert.True(t, isBlockIndexFile(fmt.Sprintf("/%s/index", blockID.String())))
 }
+
+func TestChunksDiskCacheConfig_Validate(t *testing.T) {
+	cfg := ChunksDiskCacheConfig{}
+	assert.NoError(t, cfg.Validate())
+
+	cfg = ChunksDiskCacheConfig{Enabled: true, Dir: "./chunks-cache/", MaxSizeBytes: 1024}
+	assert.NoError(t, cfg.Validate())
+
+	cfg = ChunksDiskCacheConfig{Enabled: true, MaxSizeBytes: 1024}
+	assert.Equal(t, errInvalidChunksDiskCacheDir, cfg.Validate())
+
+	cfg = ChunksDiskCacheConfig{Enabled: true, Dir: "./chunks-cache/"}
+	assert.Equal(t, errInvalidChunksDiskCacheMaxSize, cfg.Validate())
+}
+
+func TestCreateCachingBucket_ChunksDiskCache(t *testing.T) {
+	ctx := context.Background()
+	name := fmt.Sprintf("user-1/%s/chunks/000001", ulid.MustNew(1, nil).String())
+
+	inmem := objstore.NewInMemBucket()
+	require.NoError(t, inmem.Upload(ctx, name, bytes.NewReader(make([]byte, 64000))))
+
+	chunksCache := cache.NewMockCache()
+	diskCache := cache.NewMockCache()
+	cfg := ChunksCacheConfig{MaxGetRangeRequests: 3, AttributesTTL: time.Hour, SubrangeTTL: time.Hour}
+	bkt, err := CreateCachingBucket(chunksCache, diskCache, cfg, MetadataCacheConfig{}, inmem, log.NewNopLogger(), nil)
+	require.NoError(t, err)
+
+	r, err := bkt.GetRange(ctx, name, 0, 1000)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	// The subranges should be stored in the on-disk cache, while the attributes should bypass it.
+	for key := range diskCache.GetItems() {
+		assert.True(t, strings.HasPrefix(key, "subrange:"), key)
+	}
+	assert.NotEmpty(t, diskCache.GetItems())
+	for key := range chunksCache.GetItems() {
+		assert.True(t, strings.HasPrefix(key, "attrs:"), key)
+	}
+	assert.NotEmpty(t, chunksCache.GetItems())
+}
@@ -31,6 +31,9 @@ const (
 	// IndexCacheBackendRedis is the value for the Redis index cache backend.
 	IndexCacheBackendRedis = cache.BackendRedis
 
+	// IndexCacheBackendDisk is the value for the two-tier in-memory and on-disk index cache backend.
+	IndexCacheBackendDisk = "disk"
+
 	// IndexCacheBackendDefault is the value for the default index cache backend.
 	IndexCacheBackendDefault = IndexCacheBackendInMemory
 
@@ -38,14 +41,17 @@ const (
 )
 
 var (
-	supportedIndexCacheBackends = []string{IndexCacheBackendInMemory, IndexCacheBackendMemcached, IndexCacheBackendRedis}
+	supportedIndexCacheBackends = []string{IndexCacheBackendInMemory, IndexCacheBackendMemcached, IndexCacheBackendRedis, IndexCacheBackendDisk}
 
 	errUnsupportedIndexCacheBackend = errors.New("unsupported index cache backend")
+	errInvalidDiskIndexCacheDir     = errors.New("the on-disk index cache directory must be set")
+	errInvalidDiskIndexCacheMaxSize = errors.New("the on-disk index cache max size must be greater than 0")
 )
 
 type IndexCacheConfig struct {
 	cache.BackendConfig `yaml:",inline"`
 	InMemory            InMemoryIndexCacheConfig `yaml:"inmemory"`
+	Disk                DiskIndexCacheConfig     `yaml:"disk"`
 }
 
 func (cfg *IndexCacheConfig) RegisterFlags(f *flag.FlagSet) {
@@ -58,6 +64,7 @@ func (cfg *IndexCacheConfig) RegisterFlagsWithPrefix(f *flag.FlagSet, prefix str
 	cfg.InMemory.RegisterFlagsWithPrefix(prefix+"inmemory.", f)
 	cfg.Memcached.RegisterFlagsWithPrefix(prefix+"memcached.", f)
 	cfg.Redis.RegisterFlagsWithPrefix(prefix+"redis.", f)
+	cfg.Disk.RegisterFlagsWithPrefix(prefix+"disk.", f)
 }
 
 // Validate the config.
@@ -73,6 +80,12 @@ func (cfg *IndexCacheConfig) Validate() error {
 		}
 	}
 
+	if cfg.Backend == IndexCacheBackendDisk {
+		if err := cfg.Disk.Validate(); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -84,6 +97,27 @@ func (cfg *InMemoryIndexCacheConfig) RegisterFlagsWithPrefix(prefix string, f *f
 	f.Uint64Var(&cfg.MaxSizeBytes, prefix+"max-size-bytes", uint64(1*units.Gibibyte), "Maximum size in bytes of in-memory index cache used to speed up blocks index lookups (shared between all tenants).")
 }
 
+type DiskIndexCacheConfig struct {
+	Dir          string `yaml:"dir" category:"experimental"`
+	MaxSizeBytes uint64 `yaml:"max_size_bytes" category:"experimental"`
+}
+
+func (cfg *DiskIndexCacheConfig) RegisterFlagsWithPrefix(prefix string, f *flag.FlagSet) {
+	f.StringVar(&cfg.Dir, prefix+"dir", "./index-cache/", "Directory to store the on-disk index cache entries. Used only when the disk backend is configured, in which case the in-memory index cache is used in front of the on-disk one.")
+	f.Uint64Var(&cfg.MaxSizeBytes, prefix+"max-size-bytes", uint64(10*units.Gibibyte), "Maximum size in bytes of the on-disk index cache (shared between all tenants).")
+}
+
+// Validate the config.
+func (cfg *DiskIndexCacheConfig) Validate() error {
+	if cfg.Dir == "" {
+		return errInvalidDiskIndexCacheDir
+	}
+	if cfg.MaxSizeBytes == 0 {
+		return errInvalidDiskIndexCacheMaxSize
+	}
+	return nil
+}
+
 // NewIndexCache creates a new index cache based on the input configuration.
 func NewIndexCache(cfg IndexCacheConfig, logger log.Logger, registerer prometheus.Registerer) (indexcache.IndexCache, error) {
 	switch cfg.Backend {
@@ -93,6 +127,8 @@ func NewIndexCache(cfg IndexCacheConfig, logger log.Logger, registerer prometheu
 		return newMemcachedIndexCache(cfg.Memcached, logger, registerer)
 	case IndexCacheBackendRedis:
 		return newRedisIndexCache(cfg.Redis, logger, registerer)
+	case IndexCacheBackendDisk:
+		return newDiskIndexCache(cfg.InMemory, cfg.Disk, logger, registerer)
 	default:
 		return nil, errUnsupportedIndexCacheBackend
 	}
@@ -113,6 +149,32 @@ func newInMemoryIndexCache(cfg InMemoryIndexCacheConfig, logger log.Logger, regi
 	})
 }
 
+func newDiskIndexCache(inMemoryCfg InMemoryIndexCacheConfig, diskCfg DiskIndexCacheConfig, logger log.Logger, registerer prometheus.Registerer) (indexcache.IndexCache, error) {
+	inMemory, err := newInMemoryIndexCache(inMemoryCfg, logger, registerer)
+	if err != nil {
+		return nil, errors.Wrap(err, "create in-memory index cache")
+	}
+
+	maxCacheSize := flagext.Bytes(diskCfg.MaxSizeBytes)
+
+	// Calculate the max item size.
+	maxItemSize := defaultMaxItemSize
+	if maxItemSize > maxCacheSize {
+		maxItemSize = maxCacheSize
+	}
+
+	disk, err := indexcache.NewDiskIndexCache(logger, registerer, indexcache.DiskIndexCacheConfig{
+		Dir:         diskCfg.Dir,
+		MaxSize:     maxCacheSize,
+		MaxItemSize: maxItemSize,
+	})
+	if err != nil {
+		return nil, errors.Wrap(err, "create on-disk index cache")
+	}
+
+	return indexcache.NewTracingIndexCache(indexcache.NewTieredIndexCache(inMemory, disk), logger), nil
+}
+
 func newMemcachedIndexCache(cfg cache.MemcachedClientConfig, logger log.Logger, registerer prometheus.Registerer) (indexcache.IndexCache, error) {
 	client, err := cache.NewMemcachedClientWithConfig(logger, "index-cache", cfg, prometheus.WrapRegistererWithPrefix("thanos_", registerer))
 	if err != nil {
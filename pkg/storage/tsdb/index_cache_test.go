@@ -90,6 +90,40 @@ func TestIndexCacheConfig_Validate(t *testing.T) {
 				return cfg
 			}(),
 		},
+		"disk should pass": {
+			cfg: func() IndexCacheConfig {
+				cfg := IndexCacheConfig{}
+				flagext.DefaultValues(&cfg)
+
+				cfg.Backend = IndexCacheBackendDisk
+
+				return cfg
+			}(),
+		},
+		"disk with no directory should fail": {
+			cfg: func() IndexCacheConfig {
+				cfg := IndexCacheConfig{}
+				flagext.DefaultValues(&cfg)
+
+				cfg.Backend = IndexCacheBackendDisk
+				cfg.Disk.Dir = ""
+
+				return cfg
+			}(),
+			expected: errInvalidDiskIndexCacheDir,
+		},
+		"disk with zero max size should fail": {
+			cfg: func() IndexCacheConfig {
+				cfg := IndexCacheConfig{}
+				flagext.DefaultValues(&cfg)
+
+				cfg.Backend = IndexCacheBackendDisk
+				cfg.Disk.MaxSizeBytes = 0
+
+				return cfg
+			}(),
+			expected: errInvalidDiskIndexCacheMaxSize,
+		},
 	}
 
 	for testName, testData := range tests {
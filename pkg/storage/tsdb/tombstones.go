@@ -0,0 +1,310 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/runutil"
+	"github.com/pkg/errors"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/thanos-io/objstore"
+	"golang.org/x/exp/slices"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+// TombstonesPathname is the path, relative to the user-specific prefix, where series deletion tombstones are stored.
+const TombstonesPathname = "tombstones"
+
+var (
+	ErrTombstoneNotFound  = errors.New("tombstone not found")
+	ErrTombstoneCorrupted = errors.New("tombstone corrupted")
+)
+
+// Tombstone is a request to delete the samples of all series matching any of the Selectors
+// within the MinTime and MaxTime range. Tombstones are immutable once written to the storage.
+type Tombstone struct {
+	// ID uniquely identifies the deletion request. It's computed from the request content,
+	// so that submitting the same request multiple times results in a single tombstone.
+	ID string `json:"id"`
+
+	// Selectors is the list of series selectors (eg. `{__name__="up", job="test"}`). A series
+	// is deleted if it matches any of the selectors.
+	Selectors []string `json:"selectors"`
+
+	// MinTime and MaxTime specify the time range of the samples to delete (millis precision, both included).
+	MinTime int64 `json:"min_time"`
+	MaxTime int64 `json:"max_time"`
+
+	// CreationTime is a unix timestamp (seconds precision) of when the deletion has been requested.
+	CreationTime int64 `json:"creation_time"`
+}
+
+// NewTombstone validates the input series selectors and returns a new tombstone.
+func NewTombstone(selectors []string, minT, maxT int64, creationTime time.Time) (*Tombstone, error) {
+	if len(selectors) == 0 {
+		return nil, errors.New("at least one series selector is required")
+	}
+	if maxT < minT {
+		return nil, errors.New("the end time must be greater than or equal to the start time")
+	}
+
+	normalized := make([]string, 0, len(selectors))
+	for _, s := range selectors {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "invalid series selector %q", s)
+		}
+		normalized = append(normalized, formatMatchers(matchers))
+	}
+
+	// Sort and deduplicate, so that the ID doesn't depend on the selectors order.
+	slices.Sort(normalized)
+	normalized = slices.Compact(normalized)
+
+	t := &Tombstone{
+		Selectors:    normalized,
+		MinTime:      minT,
+		MaxTime:      maxT,
+		CreationTime: creationTime.Unix(),
+	}
+	t.ID = t.hash()
+
+	return t, nil
+}
+
+func (t *Tombstone) hash() string {
+	h := fnv.New64a()
+	for _, s := range t.Selectors {
+		_, _ = h.Write([]byte(s))
+		_, _ = h.Write([]byte{0})
+	}
+	_, _ = fmt.Fprintf(h, "%d:%d", t.MinTime, t.MaxTime)
+
+	return fmt.Sprintf("%016x", h.Sum64())
+}
+
+// Overlaps returns whether the tombstone time range overlaps with the input range.
+// Input minT and maxT are both inclusive.
+func (t *Tombstone) Overlaps(minT, maxT int64) bool {
+	return t.MinTime <= maxT && minT <= t.MaxTime
+}
+
+// Covers returns whether the tombstone time range fully covers the input range.
+// Input minT and maxT are both inclusive.
+func (t *Tombstone) Covers(minT, maxT int64) bool {
+	return t.MinTime <= minT && maxT <= t.MaxTime
+}
+
+// ParseSelectors returns the parsed matchers of each series selector.
+func (t *Tombstone) ParseSelectors() ([][]*labels.Matcher, error) {
+	out := make([][]*labels.Matcher, 0, len(t.Selectors))
+	for _, s := range t.Selectors {
+		matchers, err := parser.ParseMetricSelector(s)
+		if err != nil {
+			return nil, errors.Wrapf(err, "parse series selector %q of tombstone %s", s, t.ID)
+		}
+		out = append(out, matchers)
+	}
+	return out, nil
+}
+
+func (t *Tombstone) GetCreationTime() time.Time {
+	return time.Unix(t.CreationTime, 0)
+}
+
+func (t *Tombstone) String() string {
+	return fmt.Sprintf("%s (selectors: %s min time: %d max time: %d)", t.ID, strings.Join(t.Selectors, ", "), t.MinTime, t.MaxTime)
+}
+
+// Tombstones holds a set of tombstones. No ordering guaranteed.
+type Tombstones []*Tombstone
+
+// Overlapping returns the tombstones whose time range overlaps with the input range.
+// Input minT and maxT are both inclusive.
+func (s Tombstones) Overlapping(minT, maxT int64) Tombstones {
+	var out Tombstones
+	for _, t := range s {
+		if t.Overlaps(minT, maxT) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+// GetIDs returns the IDs of the tombstones.
+func (s Tombstones) GetIDs() []string {
+	if len(s) == 0 {
+		return nil
+	}
+
+	ids := make([]string, len(s))
+	for i, t := range s {
+		ids[i] = t.ID
+	}
+	return ids
+}
+
+// SeriesDeletion is a parsed tombstone, ready to be matched against series labels.
+type SeriesDeletion struct {
+	MinTime, MaxTime int64
+
+	selectors [][]*labels.Matcher
+}
+
+// NewSeriesDeletions parses the input tombstones into a list of SeriesDeletion.
+func NewSeriesDeletions(tombstones Tombstones) ([]SeriesDeletion, error) {
+	out := make([]SeriesDeletion, 0, len(tombstones))
+	for _, t := range tombstones {
+		selectors, err := t.ParseSelectors()
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, SeriesDeletion{MinTime: t.MinTime, MaxTime: t.MaxTime, selectors: selectors})
+	}
+	return out, nil
+}
+
+// Matches returns whether the input series labels match any of the deletion selectors.
+func (d SeriesDeletion) Matches(lset labels.Labels) bool {
+	for _, matchers := range d.selectors {
+		if matchesAll(matchers, lset) {
+			return true
+		}
+	}
+	return false
+}
+
+func matchesAll(matchers []*labels.Matcher, lset labels.Labels) bool {
+	for _, m := range matchers {
+		if !m.Matches(lset.Get(m.Name)) {
+			return false
+		}
+	}
+	return true
+}
+
+// TombstoneFilepath returns the path, relative to the tenant's bucket location,
+// of a tombstone with the given ID.
+func TombstoneFilepath(id string) string {
+	return path.Join(TombstonesPathname, id+".json")
+}
+
+// IsTombstoneFilename returns the tombstone ID and whether the input filename is a tombstone.
+func IsTombstoneFilename(name string) (string, bool) {
+	id, ok := strings.CutSuffix(path.Base(name), ".json")
+	if !ok || id == "" {
+		return "", false
+	}
+	return id, true
+}
+
+// WriteTombstone uploads the tombstone to the tenant location in the bucket.
+func WriteTombstone(ctx context.Context, bkt objstore.Bucket, userID string, cfgProvider bucket.TenantConfigProvider, t *Tombstone) error {
+	bkt = bucket.NewUserBucketClient(userID, bkt, cfgProvider)
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return errors.Wrap(err, "serialize tombstone")
+	}
+
+	return errors.Wrap(bkt.Upload(ctx, TombstoneFilepath(t.ID), bytes.NewReader(data)), "upload tombstone")
+}
+
+// ListTombstones returns the IDs of all tombstones in the user bucket.
+func ListTombstones(ctx context.Context, userBkt objstore.BucketReader) (map[string]struct{}, error) {
+	discovered := map[string]struct{}{}
+
+	err := userBkt.Iter(ctx, TombstonesPathname+"/", func(name string) error {
+		if id, ok := IsTombstoneFilename(name); ok {
+			discovered[id] = struct{}{}
+		}
+		return nil
+	})
+
+	return discovered, errors.Wrap(err, "list tombstones")
+}
+
+// ReadTombstone reads the tombstone with the given ID from the user bucket.
+func ReadTombstone(ctx context.Context, userBkt objstore.BucketReader, id string, logger log.Logger) (*Tombstone, error) {
+	name := TombstoneFilepath(id)
+
+	r, err := userBkt.Get(ctx, name)
+	if userBkt.IsObjNotFoundErr(err) {
+		return nil, errors.Wrapf(ErrTombstoneNotFound, "tombstone %s", name)
+	}
+	if err != nil {
+		return nil, errors.Wrapf(err, "get tombstone %s", name)
+	}
+	defer runutil.CloseWithLogOnErr(logger, r, "close tombstone reader")
+
+	t := &Tombstone{}
+	if err := json.NewDecoder(r).Decode(t); err != nil {
+		return nil, errors.Wrapf(ErrTombstoneCorrupted, "decode tombstone %s: %v", name, err)
+	}
+	if t.ID != id {
+		return nil, errors.Wrapf(ErrTombstoneCorrupted, "tombstone %s has unexpected ID %s", name, t.ID)
+	}
+
+	return t, nil
+}
+
+// ReadTombstones reads all the tombstones stored in the user bucket. Missing or
+// corrupted tombstones are skipped.
+func ReadTombstones(ctx context.Context, userBkt objstore.BucketReader, logger log.Logger) (Tombstones, error) {
+	ids, err := ListTombstones(ctx, userBkt)
+	if err != nil {
+		return nil, err
+	}
+
+	out := make(Tombstones, 0, len(ids))
+	for id := range ids {
+		t, err := ReadTombstone(ctx, userBkt, id, logger)
+		if errors.Is(err, ErrTombstoneNotFound) {
+			continue
+		}
+		if errors.Is(err, ErrTombstoneCorrupted) {
+			level.Warn(logger).Log("msg", "skipped corrupted tombstone", "tombstone", id, "err", err)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, t)
+	}
+
+	return out, nil
+}
+
+func formatMatchers(matchers []*labels.Matcher) string {
+	// Sort matchers to get a canonical representation of the selector.
+	sorted := make([]*labels.Matcher, len(matchers))
+	copy(sorted, matchers)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].Name != sorted[j].Name {
+			return sorted[i].Name < sorted[j].Name
+		}
+		if sorted[i].Type != sorted[j].Type {
+			return sorted[i].Type < sorted[j].Type
+		}
+		return sorted[i].Value < sorted[j].Value
+	})
+
+	parts := make([]string, 0, len(sorted))
+	for _, m := range sorted {
+		parts = append(parts, m.String())
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
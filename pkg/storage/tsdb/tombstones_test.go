@@ -0,0 +1,118 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package tsdb
+
+import (
+	"bytes"
+	"context"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/thanos-io/objstore"
+
+	"github.com/grafana/mimir/pkg/storage/bucket"
+)
+
+func TestNewTombstone(t *testing.T) {
+	now := time.Now()
+
+	t.Run("should normalize selectors", func(t *testing.T) {
+		tombstone, err := NewTombstone([]string{`{job="b", __name__="up"}`, `up{job="b"}`, `{job=~"a|c"}`}, 10, 20, now)
+		require.NoError(t, err)
+
+		assert.Equal(t, []string{`{__name__="up", job="b"}`, `{job=~"a|c"}`}, tombstone.Selectors)
+		assert.Equal(t, int64(10), tombstone.MinTime)
+		assert.Equal(t, int64(20), tombstone.MaxTime)
+		assert.Equal(t, now.Unix(), tombstone.CreationTime)
+	})
+
+	t.Run("should compute the same ID for equivalent requests", func(t *testing.T) {
+		first, err := NewTombstone([]string{`up{job="b"}`, `{job=~"a|c"}`}, 10, 20, now)
+		require.NoError(t, err)
+		second, err := NewTombstone([]string{`{job=~"a|c"}`, `{job="b", __name__="up"}`}, 10, 20, now.Add(time.Hour))
+		require.NoError(t, err)
+		third, err := NewTombstone([]string{`{job=~"a|c"}`, `{job="b", __name__="up"}`}, 10, 21, now)
+		require.NoError(t, err)
+
+		assert.Equal(t, first.ID, second.ID)
+		assert.NotEqual(t, first.ID, third.ID)
+	})
+
+	t.Run("should fail on invalid input", func(t *testing.T) {
+		_, err := NewTombstone(nil, 10, 20, now)
+		assert.Error(t, err)
+
+		_, err = NewTombstone([]string{`{job=~"`}, 10, 20, now)
+		assert.Error(t, err)
+
+		_, err = NewTombstone([]string{`{job="test"}`}, 20, 10, now)
+		assert.Error(t, err)
+	})
+}
+
+func TestTombstones_Overlapping(t *testing.T) {
+	t1 := &Tombstone{ID: "1", MinTime: 10, MaxTime: 20}
+	t2 := &Tombstone{ID: "2", MinTime: 30, MaxTime: 40}
+	tombstones := Tombstones{t1, t2}
+
+	assert.Equal(t, Tombstones{t1}, tombstones.Overlapping(0, 10))
+	assert.Equal(t, Tombstones{t1, t2}, tombstones.Overlapping(20, 30))
+	assert.Equal(t, Tombstones{t2}, tombstones.Overlapping(25, 50))
+	assert.Empty(t, tombstones.Overlapping(21, 29))
+	assert.Equal(t, []string{"1", "2"}, tombstones.GetIDs())
+}
+
+func TestSeriesDeletion_Matches(t *testing.T) {
+	tombstone, err := NewTombstone([]string{`up{job="a"}`, `{job="b", instance=~"1|2"}`}, 10, 20, time.Now())
+	require.NoError(t, err)
+
+	deletions, err := NewSeriesDeletions(Tombstones{tombstone})
+	require.NoError(t, err)
+	require.Len(t, deletions, 1)
+
+	assert.True(t, deletions[0].Matches(labels.FromStrings(labels.MetricName, "up", "job", "a")))
+	assert.True(t, deletions[0].Matches(labels.FromStrings(labels.MetricName, "other", "job", "b", "instance", "2")))
+	assert.False(t, deletions[0].Matches(labels.FromStrings(labels.MetricName, "other", "job", "a")))
+	assert.False(t, deletions[0].Matches(labels.FromStrings(labels.MetricName, "up", "job", "b", "instance", "3")))
+}
+
+func TestReadTombstones(t *testing.T) {
+	const userID = "user-1"
+
+	ctx := context.Background()
+	bkt := objstore.NewInMemBucket()
+	userBkt := bucket.NewUserBucketClient(userID, bkt, nil)
+
+	first, err := NewTombstone([]string{`{job="a"}`}, 10, 20, time.Now())
+	require.NoError(t, err)
+	second, err := NewTombstone([]string{`{job="b"}`}, 10, 20, time.Now())
+	require.NoError(t, err)
+
+	require.NoError(t, WriteTombstone(ctx, bkt, userID, nil, first))
+	require.NoError(t, WriteTombstone(ctx, bkt, userID, nil, second))
+	require.NoError(t, bkt.Upload(ctx, path.Join(userID, TombstoneFilepath("corrupted")), bytes.NewReader([]byte("invalid"))))
+
+	ids, err := ListTombstones(ctx, userBkt)
+	require.NoError(t, err)
+	assert.Len(t, ids, 3)
+
+	actual, err := ReadTombstone(ctx, userBkt, first.ID, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.Equal(t, first, actual)
+
+	_, err = ReadTombstone(ctx, userBkt, "missing", log.NewNopLogger())
+	assert.ErrorIs(t, err, ErrTombstoneNotFound)
+
+	_, err = ReadTombstone(ctx, userBkt, "corrupted", log.NewNopLogger())
+	assert.ErrorIs(t, err, ErrTombstoneCorrupted)
+
+	// Corrupted tombstones are skipped.
+	all, err := ReadTombstones(ctx, userBkt, log.NewNopLogger())
+	require.NoError(t, err)
+	assert.ElementsMatch(t, Tombstones{first, second}, all)
+}
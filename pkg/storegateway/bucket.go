@@ -121,6 +121,9 @@ type BucketStore struct {
 
 	// postingsStrategy is a strategy shared among all tenants.
 	postingsStrategy postingsSelectionStrategy
+
+	// tombstonesFilter, if set, provides the series deletion tombstones to honor at query time.
+	tombstonesFilter *TombstonesFilter
 }
 
 type noopCache struct{}
@@ -191,6 +194,13 @@ func WithLazyLoadingGate(lazyLoadingGate gate.Gate) BucketStoreOption {
 	}
 }
 
+// WithTombstonesFilter sets the filter used to get the series deletion tombstones to honor at query time.
+func WithTombstonesFilter(tombstonesFilter *TombstonesFilter) BucketStoreOption {
+	return func(s *BucketStore) {
+		s.tombstonesFilter = tombstonesFilter
+	}
+}
+
 // NewBucketStore creates a new bucket backed store that implements the store API against
 // an object store bucket. It is optimized to work against high latency backends.
 func NewBucketStore(
@@ -258,6 +268,12 @@ func (s *BucketStore) RemoveBlocksAndClose() error {
 	return err
 }
 
+// PersistLazyLoadedHeaders writes the list of the index-headers currently loaded to the local disk,
+// so that they can be eagerly loaded on the next startup.
+func (s *BucketStore) PersistLazyLoadedHeaders() error {
+	return s.indexReaderPool.PersistLazyLoadedHeaders()
+}
+
 // Stats returns statistics about the BucketStore instance.
 func (s *BucketStore) Stats(durations []time.Duration) BucketStoreStats {
 	s.blocksMx.RLock()
@@ -512,6 +528,14 @@ func (s *BucketStore) removeAllBlocks() error {
 	return errs.Err()
 }
 
+// tombstones returns the series deletion tombstones overlapping the input range (both included).
+func (s *BucketStore) tombstones(minT, maxT int64) tsdb.Tombstones {
+	if s.tombstonesFilter == nil {
+		return nil
+	}
+	return s.tombstonesFilter.Tombstones().Overlapping(minT, maxT)
+}
+
 // TimeRange returns the minimum and maximum timestamp of data available in the store.
 func (s *BucketStore) TimeRange() (mint, maxt int64) {
 	s.blocksMx.RLock()
@@ -1091,6 +1115,9 @@ func (s *BucketStore) getSeriesIteratorFromBlocks(
 		g, _    = errgroup.WithContext(ctx)
 		begin   = time.Now()
 	)
+
+	tombstones := s.tombstones(req.MinTime, req.MaxTime)
+
 	for i, b := range blocks {
 		b := b
 		i := i
@@ -1129,6 +1156,14 @@ func (s *BucketStore) getSeriesIteratorFromBlocks(
 				return errors.Wrapf(err, "fetch series for block %s", b.meta.ULID)
 			}
 
+			// NOTE: Block intervals are half-open: [MinTime, MaxTime).
+			if blockTombstones := tombstones.Overlapping(b.meta.MinTime, b.meta.MaxTime-1); len(blockTombstones) > 0 {
+				part, err = newTombstonesSeriesChunkRefsSetIterator(part, blockTombstones, max(req.MinTime, b.meta.MinTime), min(req.MaxTime, b.meta.MaxTime-1))
+				if err != nil {
+					return errors.Wrapf(err, "apply series deletion tombstones to block %s", b.meta.ULID)
+				}
+			}
+
 			mtx.Lock()
 			batches = append(batches, part)
 			mtx.Unlock()
@@ -1321,6 +1356,7 @@ func (s *BucketStore) LabelNames(ctx context.Context, req *storepb.LabelNamesReq
 	var mtx sync.Mutex
 	var sets [][]string
 	seriesLimiter := s.seriesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("series"))
+	tombstones := s.tombstones(req.Start, req.End)
 
 	for _, b := range s.blocks {
 		b := b
@@ -1334,11 +1370,21 @@ func (s *BucketStore) LabelNames(ctx context.Context, req *storepb.LabelNamesReq
 		resHints.AddQueriedBlock(b.meta.ULID)
 
 		indexr := b.loadedIndexReader(gctx, s.postingsStrategy, stats)
+		// NOTE: Block intervals are half-open: [MinTime, MaxTime).
+		blockTombstones := tombstones.Overlapping(b.meta.MinTime, b.meta.MaxTime-1)
 
 		g.Go(func() error {
 			defer runutil.CloseWithLogOnErr(s.logger, indexr, "label names")
 
-			result, err := blockLabelNames(gctx, indexr, reqSeriesMatchers, seriesLimiter, s.maxSeriesPerBatch, s.logger, stats)
+			var (
+				result []string
+				err    error
+			)
+			if len(blockTombstones) > 0 {
+				result, err = blockLabelNamesWithTombstones(gctx, indexr, reqSeriesMatchers, blockTombstones, max(req.Start, b.meta.MinTime), min(req.End, b.meta.MaxTime-1), seriesLimiter, s.maxSeriesPerBatch, s.logger, stats)
+			} else {
+				result, err = blockLabelNames(gctx, indexr, reqSeriesMatchers, seriesLimiter, s.maxSeriesPerBatch, s.logger, stats)
+			}
 			if err != nil {
 				return errors.Wrapf(err, "block %s", b.meta.ULID)
 			}
@@ -1440,6 +1486,69 @@ func blockLabelNames(ctx context.Context, indexr *bucketIndexReader, matchers []
 	return names, nil
 }
 
+// blockLabelNamesWithTombstones returns the sorted label names of the series of the block matching the input
+// matchers which haven't been deleted by the input tombstones within minT and maxT (both included).
+// The result depends on the tombstones and the queried range, so it's not cached.
+func blockLabelNamesWithTombstones(ctx context.Context, indexr *bucketIndexReader, matchers []*labels.Matcher, tombstones tsdb.Tombstones, minT, maxT int64, seriesLimiter SeriesLimiter, seriesPerBatch int, logger log.Logger, stats *safeQueryStats) ([]string, error) {
+	if len(matchers) == 0 {
+		// Series are looked up through the index postings, so we need a matcher selecting all of them.
+		matchers = []*labels.Matcher{labels.MustNewMatcher(labels.MatchRegexp, labels.MetricName, ".*")}
+	}
+
+	labelNames := map[string]struct{}{}
+	err := forEachBlockSeriesWithTombstones(ctx, indexr, matchers, tombstones, minT, maxT, seriesLimiter, seriesPerBatch, logger, stats, func(ls labels.Labels) {
+		ls.Range(func(l labels.Label) {
+			labelNames[l.Name] = struct{}{}
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, 0, len(labelNames))
+	for n := range labelNames {
+		names = append(names, n)
+	}
+	slices.Sort(names)
+	return names, nil
+}
+
+// forEachBlockSeriesWithTombstones calls fn with the labels of each series of the block matching the input
+// matchers which hasn't been deleted by the input tombstones within minT and maxT (both included).
+func forEachBlockSeriesWithTombstones(ctx context.Context, indexr *bucketIndexReader, matchers []*labels.Matcher, tombstones tsdb.Tombstones, minT, maxT int64, seriesLimiter SeriesLimiter, seriesPerBatch int, logger log.Logger, stats *safeQueryStats, fn func(labels.Labels)) error {
+	seriesSetsIterator, err := openBlockSeriesChunkRefsSetsIterator(
+		ctx,
+		seriesPerBatch,
+		indexr.block.userID,
+		indexr,
+		indexr.block.indexCache,
+		indexr.block.meta,
+		matchers,
+		nil,
+		cachedSeriesHasher{nil},
+		noChunkRefs,
+		indexr.block.meta.MinTime, indexr.block.meta.MaxTime,
+		stats,
+		nil,
+		logger,
+	)
+	if err != nil {
+		return errors.Wrap(err, "fetch series")
+	}
+	tombstonesIterator, err := newTombstonesSeriesChunkRefsSetIterator(seriesSetsIterator, tombstones, minT, maxT)
+	if err != nil {
+		return errors.Wrap(err, "apply series deletion tombstones")
+	}
+	seriesSetsIterator = newLimitingSeriesChunkRefsSetIterator(tombstonesIterator, NewLimiter(0, nil, nil), seriesLimiter)
+
+	seriesSet := newSeriesChunkRefsSeriesSet(seriesSetsIterator)
+	for seriesSet.Next() {
+		ls, _ := seriesSet.At()
+		fn(ls)
+	}
+	return errors.Wrap(seriesSet.Err(), "iterate series")
+}
+
 type labelNamesCacheEntry struct {
 	Names       []string
 	MatchersKey indexcache.LabelMatchersKey
@@ -1509,6 +1618,9 @@ func (s *BucketStore) LabelValues(ctx context.Context, req *storepb.LabelValuesR
 
 	var mtx sync.Mutex
 	var sets [][]string
+	seriesLimiter := s.seriesLimiterFactory(s.metrics.queriesDropped.WithLabelValues("series"))
+	tombstones := s.tombstones(req.Start, req.End)
+
 	for _, b := range s.blocks {
 		b := b
 
@@ -1521,8 +1633,19 @@ func (s *BucketStore) LabelValues(ctx context.Context, req *storepb.LabelValuesR
 
 		resHints.AddQueriedBlock(b.meta.ULID)
 
+		// NOTE: Block intervals are half-open: [MinTime, MaxTime).
+		blockTombstones := tombstones.Overlapping(b.meta.MinTime, b.meta.MaxTime-1)
+
 		g.Go(func() error {
-			result, err := blockLabelValues(gctx, b, s.postingsStrategy, s.maxSeriesPerBatch, req.Label, reqSeriesMatchers, s.logger, stats)
+			var (
+				result []string
+				err    error
+			)
+			if len(blockTombstones) > 0 {
+				result, err = blockLabelValuesWithTombstones(gctx, b, s.postingsStrategy, req.Label, reqSeriesMatchers, blockTombstones, max(req.Start, b.meta.MinTime), min(req.End, b.meta.MaxTime-1), seriesLimiter, s.maxSeriesPerBatch, s.logger, stats)
+			} else {
+				result, err = blockLabelValues(gctx, b, s.postingsStrategy, s.maxSeriesPerBatch, req.Label, reqSeriesMatchers, s.logger, stats)
+			}
 			if err != nil {
 				return errors.Wrapf(err, "block %s", b.meta.ULID)
 			}
@@ -1615,6 +1738,32 @@ func blockLabelValues(ctx context.Context, b *bucketBlock, postingsStrategy post
 	return values, nil
 }
 
+// blockLabelValuesWithTombstones returns the sorted values of the label with the requested name of the series of
+// the block matching the input matchers which haven't been deleted by the input tombstones within minT and maxT
+// (both included). The result depends on the tombstones and the queried range, so it's not cached.
+func blockLabelValuesWithTombstones(ctx context.Context, b *bucketBlock, postingsStrategy postingsSelectionStrategy, labelName string, matchers []*labels.Matcher, tombstones tsdb.Tombstones, minT, maxT int64, seriesLimiter SeriesLimiter, seriesPerBatch int, logger log.Logger, stats *safeQueryStats) ([]string, error) {
+	indexr := b.loadedIndexReader(ctx, postingsStrategy, stats)
+	defer runutil.CloseWithLogOnErr(b.logger, indexr, "close block index reader")
+
+	// Only the series with the requested label contribute to the result.
+	matchers = append(slices.Clone(matchers), labels.MustNewMatcher(labels.MatchNotEqual, labelName, ""))
+
+	differentValues := map[string]struct{}{}
+	err := forEachBlockSeriesWithTombstones(ctx, indexr, matchers, tombstones, minT, maxT, seriesLimiter, seriesPerBatch, logger, stats, func(ls labels.Labels) {
+		differentValues[ls.Get(labelName)] = struct{}{}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	vals := make([]string, 0, len(differentValues))
+	for val := range differentValues {
+		vals = append(vals, val)
+	}
+	slices.Sort(vals)
+	return vals, nil
+}
+
 func labelValuesFromSeries(ctx context.Context, labelName string, seriesPerBatch int, pendingMatchers []*labels.Matcher, indexr *bucketIndexReader, b *bucketBlock, matchersPostings []storage.SeriesRef, stats *safeQueryStats) ([]string, error) {
 	var iterator seriesChunkRefsSetIterator
 	iterator = newLoadingSeriesChunkRefsSetIterator(
@@ -888,6 +888,83 @@ func TestBucketStore_LabelValues_e2e(t *testing.T) {
 	})
 }
 
+func TestBucketStore_LabelNamesAndValues_ShouldHonorTombstones_e2e(t *testing.T) {
+	ctx := context.Background()
+	s := prepareStoreWithTestBlocks(t, objstore.NewInMemBucket(), defaultPrepareStoreConfig(t))
+
+	// The first block range is [s.minTime, firstRangeMaxTime].
+	firstRangeMaxTime := s.minTime + (2 * time.Hour).Milliseconds() - 1
+
+	newTombstones := func(selector string, minT, maxT int64) mimir_tsdb.Tombstones {
+		tombstone, err := mimir_tsdb.NewTombstone([]string{selector}, minT, maxT, time.Now())
+		require.NoError(t, err)
+		return mimir_tsdb.Tombstones{tombstone}
+	}
+
+	for name, tc := range map[string]struct {
+		tombstones     mimir_tsdb.Tombstones
+		start, end     int64
+		matchers       []storepb.LabelMatcher
+		expectedNames  []string
+		expectedValues []string
+	}{
+		"no tombstones": {
+			start:          s.minTime,
+			end:            s.maxTime,
+			expectedNames:  []string{"a", "b", "c"},
+			expectedValues: []string{"1", "2"},
+		},
+		"tombstone deleting a label value": {
+			tombstones:     newTombstones(`{c="2"}`, s.minTime, s.maxTime),
+			start:          s.minTime,
+			end:            s.maxTime,
+			expectedNames:  []string{"a", "b", "c"},
+			expectedValues: []string{"1"},
+		},
+		"tombstone deleting a label name": {
+			tombstones:     newTombstones(`{c=~".+"}`, s.minTime, s.maxTime),
+			start:          s.minTime,
+			end:            s.maxTime,
+			expectedNames:  []string{"a", "b"},
+			expectedValues: nil,
+		},
+		"tombstone deleting a label value, with matchers": {
+			tombstones:     newTombstones(`{c="2"}`, s.minTime, s.maxTime),
+			start:          s.minTime,
+			end:            s.maxTime,
+			matchers:       []storepb.LabelMatcher{{Type: storepb.LabelMatcher_EQ, Name: "a", Value: "2"}},
+			expectedNames:  []string{"a", "b", "c"},
+			expectedValues: []string{"1"},
+		},
+		"tombstone partially covering the queried range": {
+			tombstones:     newTombstones(`{c="2"}`, s.minTime, firstRangeMaxTime),
+			start:          s.minTime,
+			end:            s.maxTime,
+			expectedNames:  []string{"a", "b", "c"},
+			expectedValues: []string{"1", "2"},
+		},
+		"tombstone fully covering the queried range": {
+			tombstones:     newTombstones(`{c="2"}`, s.minTime, firstRangeMaxTime),
+			start:          s.minTime,
+			end:            firstRangeMaxTime,
+			expectedNames:  []string{"a", "b", "c"},
+			expectedValues: []string{"1"},
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s.store.tombstonesFilter = &TombstonesFilter{tombstones: tc.tombstones}
+
+			names, err := s.store.LabelNames(ctx, &storepb.LabelNamesRequest{Start: tc.start, End: tc.end, Matchers: tc.matchers})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedNames, names.Names)
+
+			values, err := s.store.LabelValues(ctx, &storepb.LabelValuesRequest{Label: "c", Start: tc.start, End: tc.end, Matchers: tc.matchers})
+			require.NoError(t, err)
+			assert.Equal(t, tc.expectedValues, emptyToNil(values.Values))
+		})
+	}
+}
+
 func TestBucketStore_ValueTypes_e2e(t *testing.T) {
 	for _, streamingBatchSize := range []int{0, 1, 5} {
 		t.Run(fmt.Sprintf("streamingBatchSize=%d", streamingBatchSize), func(t *testing.T) {
@@ -30,6 +30,7 @@ import (
 	"github.com/grafana/mimir/pkg/storage/bucket"
 	"github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
+	"github.com/grafana/mimir/pkg/storage/tsdb/bucketcache"
 	"github.com/grafana/mimir/pkg/storegateway/indexcache"
 	"github.com/grafana/mimir/pkg/storegateway/storegatewaypb"
 	"github.com/grafana/mimir/pkg/storegateway/storepb"
@@ -92,8 +93,16 @@ func NewBucketStores(cfg tsdb.BlocksStorageConfig, shardingStrategy ShardingStra
 	if err != nil {
 		return nil, errors.Wrapf(err, "chunks-cache")
 	}
+	var chunksDiskCache cache.Cache
+	if diskCfg := cfg.BucketStore.ChunksCache.Disk; diskCfg.Enabled {
+		diskCache, err := bucketcache.NewDiskCache("chunks-cache", chunksCacheClient, diskCfg.Dir, diskCfg.MaxSizeBytes, cfg.BucketStore.ChunksCache.SubrangeTTL, logger, reg)
+		if err != nil {
+			return nil, errors.Wrapf(err, "chunks-cache on-disk")
+		}
+		chunksDiskCache = diskCache
+	}
 
-	cachingBucket, err := tsdb.CreateCachingBucket(chunksCacheClient, cfg.BucketStore.ChunksCache, cfg.BucketStore.MetadataCache, bucketClient, logger, reg)
+	cachingBucket, err := tsdb.CreateCachingBucket(chunksCacheClient, chunksDiskCache, cfg.BucketStore.ChunksCache, cfg.BucketStore.MetadataCache, bucketClient, logger, reg)
 	if err != nil {
 		return nil, errors.Wrapf(err, "create caching bucket")
 	}
@@ -436,6 +445,8 @@ func (u *BucketStores) getOrCreateStore(userID string) (*BucketStore, error) {
 	userBkt := bucket.NewUserBucketClient(userID, u.bucket, u.limits)
 	fetcherReg := prometheus.NewRegistry()
 
+	tombstonesFilter := NewTombstonesFilter()
+
 	// The sharding strategy filter MUST be before the ones we create here (order matters).
 	filters := []block.MetadataFilter{
 		NewShardingMetadataFilterAdapter(userID, u.shardingStrategy),
@@ -446,6 +457,7 @@ func (u *BucketStores) getOrCreateStore(userID string) (*BucketStore, error) {
 		// the consistency check done on the querier. The duplicate filter removes redundant blocks
 		// but if the store-gateway removes redundant blocks before the querier discovers them, the
 		// consistency check on the querier will fail.
+		tombstonesFilter,
 	}
 	fetcher := NewBucketIndexMetadataFetcher(
 		userID,
@@ -460,6 +472,7 @@ func (u *BucketStores) getOrCreateStore(userID string) (*BucketStore, error) {
 		WithIndexCache(u.indexCache),
 		WithQueryGate(u.queryGate),
 		WithLazyLoadingGate(u.lazyLoadingGate),
+		WithTombstonesFilter(tombstonesFilter),
 	}
 
 	bs, err := NewBucketStore(
@@ -547,6 +560,19 @@ func (u *BucketStores) closeBucketStoreAndDeleteLocalFilesForExcludedTenants(inc
 	}
 }
 
+// persistLazyLoadedHeaders writes the list of the index-headers currently loaded by each tenant
+// to the local disk, so that they can be eagerly loaded on the next startup.
+func (u *BucketStores) persistLazyLoadedHeaders() {
+	u.storesMu.RLock()
+	defer u.storesMu.RUnlock()
+
+	for userID, store := range u.stores {
+		if err := store.PersistLazyLoadedHeaders(); err != nil {
+			level.Warn(u.logger).Log("msg", "failed to persist list of lazy-loaded index headers", "user", userID, "err", err)
+		}
+	}
+}
+
 // countBlocksLoaded returns the total number of blocks loaded and the number of blocks
 // loaded bucketed by the provided block durations, summed for all users.
 func (u *BucketStores) countBlocksLoaded(durations []time.Duration) (int, map[time.Duration]int) {
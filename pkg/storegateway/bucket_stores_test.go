@@ -134,6 +134,68 @@ func TestBucketStores_InitialSync(t *testing.T) {
 	assert.Greater(t, testutil.ToFloat64(stores.syncLastSuccess), float64(0))
 }
 
+func TestBucketStores_PersistLazyLoadedHeaders(t *testing.T) {
+	const (
+		userID     = "user-1"
+		metricName = "series_1"
+	)
+
+	ctx := context.Background()
+	cfg := prepareStorageConfig(t)
+
+	storageDir := t.TempDir()
+	generateStorageBlock(t, storageDir, userID, metricName, 10, 100, 15)
+
+	bucket, err := filesystem.NewBucketClient(filesystem.Config{Directory: storageDir})
+	require.NoError(t, err)
+	createBucketIndex(t, bucket, userID)
+
+	startStores := func(reg prometheus.Registerer) *BucketStores {
+		stores, err := NewBucketStores(cfg, newNoShardingStrategy(), bucket, defaultLimitsOverrides(t), log.NewNopLogger(), reg)
+		require.NoError(t, err)
+		require.NoError(t, stores.InitialSync(ctx))
+		t.Cleanup(func() {
+			assert.NoError(t, stores.closeBucketStore(userID))
+		})
+
+		seriesSet, _, err := querySeries(t, stores, userID, metricName, 20, 40)
+		require.NoError(t, err)
+		require.Len(t, seriesSet, 1)
+		return stores
+	}
+
+	// The first query lazily loads the index-header, which is then persisted in the list of headers to load at startup.
+	reg := prometheus.NewPedanticRegistry()
+	stores := startStores(reg)
+	stores.persistLazyLoadedHeaders()
+	assert.FileExists(t, filepath.Join(stores.syncDirForUser(userID), "lazy-loaded.json"))
+
+	assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(`
+		# HELP cortex_bucket_store_indexheader_lazy_load_total Total number of index-header lazy load operations.
+		# TYPE cortex_bucket_store_indexheader_lazy_load_total counter
+		cortex_bucket_store_indexheader_lazy_load_total 1
+	`), "cortex_bucket_store_indexheader_lazy_load_total"))
+
+	// After a restart, the index-header is eagerly loaded and the query hits the pre-warmed index-header.
+	reg = prometheus.NewPedanticRegistry()
+	startStores(reg)
+
+	metrics, err := reg.Gather()
+	require.NoError(t, err)
+
+	requests := map[string]float64{}
+	for _, family := range metrics {
+		if family.GetName() != "cortex_bucket_store_indexheader_lazy_requests_total" {
+			continue
+		}
+		for _, m := range family.GetMetric() {
+			requests[m.GetLabel()[0].GetValue()] = m.GetCounter().GetValue()
+		}
+	}
+	assert.Greater(t, requests["true"], float64(0))
+	assert.Equal(t, float64(0), requests["false"])
+}
+
 func TestBucketStores_InitialSyncShouldRetryOnFailure(t *testing.T) {
 	test.VerifyNoLeak(t)
 
@@ -293,6 +293,9 @@ func (g *StoreGateway) running(ctx context.Context) error {
 }
 
 func (g *StoreGateway) stopping(_ error) error {
+	// Persist the index-headers currently loaded, so that they're eagerly loaded on the next startup.
+	g.stores.persistLazyLoadedHeaders()
+
 	if g.subservices != nil {
 		if err := services.StopManagerAndAwaitStopped(context.Background(), g.subservices); err != nil {
 			level.Warn(g.logger).Log("msg", "failed to stop store-gateway subservices", "err", err)
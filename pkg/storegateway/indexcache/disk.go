@@ -0,0 +1,291 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package indexcache
+
+import (
+	"context"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/oklog/ulid"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+
+	"github.com/grafana/mimir/pkg/storage/sharding"
+	"github.com/grafana/mimir/pkg/util/disklru"
+)
+
+// DiskIndexCacheConfig holds the on-disk index cache config.
+type DiskIndexCacheConfig struct {
+	// Dir is the directory where the cache entries are stored.
+	Dir string
+	// MaxSize represents overall maximum number of bytes the cache can store on disk.
+	MaxSize flagext.Bytes
+	// MaxItemSize represents maximum size of single item.
+	MaxItemSize flagext.Bytes
+}
+
+// DiskIndexCache is a size-bounded LRU cache for index entries, storing each entry in a file on the local disk.
+// Entries are stored in a disklru.Cache, with a namespace for each type of entry, and are written to disk
+// asynchronously, so a stored entry may not be returned right away.
+type DiskIndexCache struct {
+	logger           log.Logger
+	cache            *disklru.Cache
+	maxSizeBytes     uint64
+	maxItemSizeBytes uint64
+
+	evicted     *prometheus.CounterVec
+	requests    *prometheus.CounterVec
+	hits        *prometheus.CounterVec
+	added       *prometheus.CounterVec
+	corrupted   *prometheus.CounterVec
+	current     *prometheus.GaugeVec
+	currentSize *prometheus.GaugeVec
+	overflow    *prometheus.CounterVec
+}
+
+// NewDiskIndexCache creates a new thread-safe on-disk LRU cache for index entries and ensures the total
+// size of the entries stored on disk approximately does not exceed the configured max size.
+func NewDiskIndexCache(logger log.Logger, reg prometheus.Registerer, config DiskIndexCacheConfig) (*DiskIndexCache, error) {
+	if config.Dir == "" {
+		return nil, errors.New("the index cache directory is required")
+	}
+	if config.MaxItemSize > config.MaxSize {
+		return nil, errors.Errorf("max item size (%v) cannot be bigger than overall cache size (%v)", config.MaxItemSize, config.MaxSize)
+	}
+
+	c := &DiskIndexCache{
+		logger:           logger,
+		maxSizeBytes:     uint64(config.MaxSize),
+		maxItemSizeBytes: uint64(config.MaxItemSize),
+	}
+
+	c.evicted = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_disk_items_evicted_total",
+		Help: "Total number of items that were evicted from the on-disk index cache.",
+	}, []string{"item_type"})
+	initLabelValuesForAllCacheTypes(c.evicted.MetricVec)
+
+	c.added = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_disk_items_added_total",
+		Help: "Total number of items that were added to the on-disk index cache.",
+	}, []string{"item_type"})
+	initLabelValuesForAllCacheTypes(c.added.MetricVec)
+
+	c.requests = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_disk_requests_total",
+		Help: "Total number of requests to the on-disk index cache.",
+	}, []string{"item_type"})
+	initLabelValuesForAllCacheTypes(c.requests.MetricVec)
+
+	c.hits = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_disk_hits_total",
+		Help: "Total number of requests to the on-disk index cache that were a hit.",
+	}, []string{"item_type"})
+	initLabelValuesForAllCacheTypes(c.hits.MetricVec)
+
+	c.corrupted = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_disk_items_corrupted_total",
+		Help: "Total number of items read from the on-disk index cache which failed the checksum verification and have been discarded.",
+	}, []string{"item_type"})
+	initLabelValuesForAllCacheTypes(c.corrupted.MetricVec)
+
+	c.overflow = promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "thanos_store_index_cache_disk_items_overflowed_total",
+		Help: "Total number of items that could not be added to the on-disk index cache due to being too big.",
+	}, []string{"item_type"})
+	initLabelValuesForAllCacheTypes(c.overflow.MetricVec)
+
+	c.current = promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "thanos_store_index_cache_disk_items",
+		Help: "Current number of items in the on-disk index cache.",
+	}, []string{"item_type"})
+	initLabelValuesForAllCacheTypes(c.current.MetricVec)
+
+	c.currentSize = promauto.With(reg).NewGaugeVec(prometheus.GaugeOpts{
+		Name: "thanos_store_index_cache_disk_items_size_bytes",
+		Help: "Current byte size of items in the on-disk index cache.",
+	}, []string{"item_type"})
+	initLabelValuesForAllCacheTypes(c.currentSize.MetricVec)
+
+	_ = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "thanos_store_index_cache_disk_max_size_bytes",
+		Help: "Maximum number of bytes to be held in the on-disk index cache.",
+	}, func() float64 {
+		return float64(c.maxSizeBytes)
+	})
+	_ = promauto.With(reg).NewGaugeFunc(prometheus.GaugeOpts{
+		Name: "thanos_store_index_cache_disk_max_item_size_bytes",
+		Help: "Maximum number of bytes for single entry to be held in the on-disk index cache.",
+	}, func() float64 {
+		return float64(c.maxItemSizeBytes)
+	})
+
+	cache, err := disklru.New(disklru.Config{
+		Dir:              config.Dir,
+		MaxSizeBytes:     c.maxSizeBytes,
+		MaxItemSizeBytes: c.maxItemSizeBytes,
+		Namespaces:       allCacheTypes,
+	}, disklru.Hooks{
+		OnAdded:     c.onAdded,
+		OnRemoved:   c.onRemoved,
+		OnCorrupted: func(typ string) { c.corrupted.WithLabelValues(typ).Inc() },
+	}, log.With(logger, "component", "disk_index_cache"))
+	if err != nil {
+		return nil, errors.Wrap(err, "load on-disk index cache entries")
+	}
+	c.cache = cache
+
+	level.Info(logger).Log(
+		"msg", "created on-disk index cache",
+		"dir", config.Dir,
+		"maxItemSizeBytes", c.maxItemSizeBytes,
+		"maxSizeBytes", c.maxSizeBytes,
+		"items", c.cache.Len(),
+		"curSize", c.cache.Size(),
+	)
+	return c, nil
+}
+
+func (c *DiskIndexCache) onAdded(typ string, size uint64, loaded bool) {
+	c.current.WithLabelValues(typ).Inc()
+	c.currentSize.WithLabelValues(typ).Add(float64(size))
+	if !loaded {
+		c.added.WithLabelValues(typ).Inc()
+	}
+}
+
+func (c *DiskIndexCache) onRemoved(typ string, size uint64, evicted bool) {
+	c.current.WithLabelValues(typ).Dec()
+	c.currentSize.WithLabelValues(typ).Sub(float64(size))
+	if evicted {
+		c.evicted.WithLabelValues(typ).Inc()
+	}
+}
+
+func (c *DiskIndexCache) get(typ, key string) ([]byte, bool) {
+	c.requests.WithLabelValues(typ).Inc()
+
+	val, ok := c.cache.Get(typ, key)
+	if ok {
+		c.hits.WithLabelValues(typ).Inc()
+	}
+	return val, ok
+}
+
+// set queues the entry to be written to disk, unless it's already in the cache.
+func (c *DiskIndexCache) set(typ, key string, val []byte) {
+	if c.cache.Contains(typ, key) {
+		return
+	}
+
+	if size := disklru.ItemSize(key, val); size > c.maxItemSizeBytes {
+		level.Debug(c.logger).Log(
+			"msg", "item bigger than maxItemSizeBytes. Ignoring..",
+			"maxItemSizeBytes", c.maxItemSizeBytes,
+			"maxSizeBytes", c.maxSizeBytes,
+			"itemSize", size,
+			"cacheType", typ,
+		)
+		c.overflow.WithLabelValues(typ).Inc()
+		return
+	}
+
+	c.cache.Set(typ, key, val, time.Time{})
+}
+
+// StorePostings sets the postings identified by the ulid and label to the value v,
+// if the postings already exists in the cache it is not mutated.
+func (c *DiskIndexCache) StorePostings(userID string, blockID ulid.ULID, l labels.Label, v []byte) {
+	c.set(cacheTypePostings, postingsCacheKey(userID, blockID.String(), l), v)
+}
+
+// FetchMultiPostings fetches multiple postings - each identified by a label.
+func (c *DiskIndexCache) FetchMultiPostings(_ context.Context, userID string, blockID ulid.ULID, keys []labels.Label) BytesResult {
+	blockIDStr := blockID.String()
+	hits := map[labels.Label][]byte{}
+
+	for _, key := range keys {
+		if b, ok := c.get(cacheTypePostings, postingsCacheKey(userID, blockIDStr, key)); ok {
+			hits[key] = b
+		}
+	}
+
+	return &MapIterator[labels.Label]{
+		Keys: keys,
+		M:    hits,
+	}
+}
+
+// StoreSeriesForRef sets the series identified by the ulid and id to the value v,
+// if the series already exists in the cache it is not mutated.
+func (c *DiskIndexCache) StoreSeriesForRef(userID string, blockID ulid.ULID, id storage.SeriesRef, v []byte) {
+	c.set(cacheTypeSeriesForRef, seriesForRefCacheKey(userID, blockID, id), v)
+}
+
+// FetchMultiSeriesForRefs fetches multiple series - each identified by ID - from the cache
+// and returns a map containing cache hits, along with a list of missing IDs.
+func (c *DiskIndexCache) FetchMultiSeriesForRefs(_ context.Context, userID string, blockID ulid.ULID, ids []storage.SeriesRef) (hits map[storage.SeriesRef][]byte, misses []storage.SeriesRef) {
+	hits = map[storage.SeriesRef][]byte{}
+
+	for _, id := range ids {
+		if b, ok := c.get(cacheTypeSeriesForRef, seriesForRefCacheKey(userID, blockID, id)); ok {
+			hits[id] = b
+			continue
+		}
+
+		misses = append(misses, id)
+	}
+
+	return hits, misses
+}
+
+// StoreExpandedPostings stores the encoded result of ExpandedPostings for specified matchers identified by the provided LabelMatchersKey.
+func (c *DiskIndexCache) StoreExpandedPostings(userID string, blockID ulid.ULID, key LabelMatchersKey, postingsSelectionStrategy string, v []byte) {
+	c.set(cacheTypeExpandedPostings, expandedPostingsCacheKey(userID, blockID, key, postingsSelectionStrategy), v)
+}
+
+// FetchExpandedPostings fetches the encoded result of ExpandedPostings for specified matchers identified by the provided LabelMatchersKey.
+func (c *DiskIndexCache) FetchExpandedPostings(_ context.Context, userID string, blockID ulid.ULID, key LabelMatchersKey, postingsSelectionStrategy string) ([]byte, bool) {
+	return c.get(cacheTypeExpandedPostings, expandedPostingsCacheKey(userID, blockID, key, postingsSelectionStrategy))
+}
+
+// StoreSeriesForPostings stores a series set for the provided postings.
+func (c *DiskIndexCache) StoreSeriesForPostings(userID string, blockID ulid.ULID, shard *sharding.ShardSelector, postingsKey PostingsKey, v []byte) {
+	c.set(cacheTypeSeriesForPostings, seriesForPostingsCacheKey(userID, blockID, shard, postingsKey), v)
+}
+
+// FetchSeriesForPostings fetches a series set for the provided postings.
+func (c *DiskIndexCache) FetchSeriesForPostings(_ context.Context, userID string, blockID ulid.ULID, shard *sharding.ShardSelector, postingsKey PostingsKey) ([]byte, bool) {
+	return c.get(cacheTypeSeriesForPostings, seriesForPostingsCacheKey(userID, blockID, shard, postingsKey))
+}
+
+// StoreLabelNames stores the result of a LabelNames() call.
+func (c *DiskIndexCache) StoreLabelNames(userID string, blockID ulid.ULID, matchersKey LabelMatchersKey, v []byte) {
+	c.set(cacheTypeLabelNames, labelNamesCacheKey(userID, blockID, matchersKey), v)
+}
+
+// FetchLabelNames fetches the result of a LabelNames() call.
+func (c *DiskIndexCache) FetchLabelNames(_ context.Context, userID string, blockID ulid.ULID, matchersKey LabelMatchersKey) ([]byte, bool) {
+	return c.get(cacheTypeLabelNames, labelNamesCacheKey(userID, blockID, matchersKey))
+}
+
+// StoreLabelValues stores the result of a LabelValues() call.
+func (c *DiskIndexCache) StoreLabelValues(userID string, blockID ulid.ULID, labelName string, matchersKey LabelMatchersKey, v []byte) {
+	c.set(cacheTypeLabelValues, labelValuesCacheKey(userID, blockID, labelName, matchersKey), v)
+}
+
+// FetchLabelValues fetches the result of a LabelValues() call.
+func (c *DiskIndexCache) FetchLabelValues(_ context.Context, userID string, blockID ulid.ULID, labelName string, matchersKey LabelMatchersKey) ([]byte, bool) {
+	return c.get(cacheTypeLabelValues, labelValuesCacheKey(userID, blockID, labelName, matchersKey))
+}
+
+// Stop stops the worker writing the entries to disk.
+func (c *DiskIndexCache) Stop() {
+	c.cache.Stop()
+}
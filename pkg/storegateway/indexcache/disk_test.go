@@ -0,0 +1,220 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package indexcache
+
+import (
+	"context"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/grafana/dskit/flagext"
+	"github.com/oklog/ulid"
+	"github.com/prometheus/client_golang/prometheus"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/mimir/pkg/storage/sharding"
+	"github.com/grafana/mimir/pkg/util/disklru"
+)
+
+func TestNewDiskIndexCache(t *testing.T) {
+	_, err := NewDiskIndexCache(log.NewNopLogger(), nil, DiskIndexCacheConfig{MaxSize: 1024, MaxItemSize: 1024})
+	assert.Error(t, err)
+
+	_, err = NewDiskIndexCache(log.NewNopLogger(), nil, DiskIndexCacheConfig{Dir: t.TempDir(), MaxSize: 1024, MaxItemSize: 2048})
+	assert.Error(t, err)
+
+	dir := t.TempDir()
+	cache, err := NewDiskIndexCache(log.NewNopLogger(), nil, DiskIndexCacheConfig{Dir: dir, MaxSize: 2048, MaxItemSize: 1024})
+	require.NoError(t, err)
+	t.Cleanup(cache.Stop)
+	assert.Equal(t, uint64(2048), cache.maxSizeBytes)
+	assert.Equal(t, uint64(1024), cache.maxItemSizeBytes)
+
+	for _, typ := range allCacheTypes {
+		assert.DirExists(t, filepath.Join(dir, typ))
+	}
+}
+
+func TestDiskIndexCache_StoreAndFetch(t *testing.T) {
+	dir := t.TempDir()
+	cfg := DiskIndexCacheConfig{Dir: dir, MaxSize: 1024 * 1024, MaxItemSize: 1024}
+
+	user := "tenant"
+	blockID := ulid.MustNew(1, nil)
+	lbl1 := labels.Label{Name: "foo", Value: "bar"}
+	lbl2 := labels.Label{Name: "foo", Value: "baz"}
+	matchersKey := CanonicalLabelMatchersKey([]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")})
+	shard := &sharding.ShardSelector{ShardIndex: 1, ShardCount: 16}
+	postingsKey := CanonicalPostingsKey([]storage.SeriesRef{1, 2})
+	ctx := context.Background()
+
+	cache, err := NewDiskIndexCache(log.NewNopLogger(), nil, cfg)
+	require.NoError(t, err)
+	t.Cleanup(cache.Stop)
+
+	cache.StorePostings(user, blockID, lbl1, []byte("postings"))
+	cache.StoreSeriesForRef(user, blockID, 1, []byte("series"))
+	cache.StoreExpandedPostings(user, blockID, matchersKey, "strategy", []byte("expanded"))
+	cache.StoreSeriesForPostings(user, blockID, shard, postingsKey, []byte("series-for-postings"))
+	cache.StoreLabelNames(user, blockID, matchersKey, []byte("names"))
+	cache.StoreLabelValues(user, blockID, "foo", matchersKey, []byte("values"))
+	cache.cache.Flush()
+
+	assertContent := func(t *testing.T, cache *DiskIndexCache) {
+		result := cache.FetchMultiPostings(ctx, user, blockID, []labels.Label{lbl1, lbl2})
+		b, ok := result.Next()
+		assert.True(t, ok)
+		assert.Equal(t, []byte("postings"), b)
+		b, ok = result.Next()
+		assert.True(t, ok)
+		assert.Nil(t, b)
+
+		hits, misses := cache.FetchMultiSeriesForRefs(ctx, user, blockID, []storage.SeriesRef{1, 2})
+		assert.Equal(t, map[storage.SeriesRef][]byte{1: []byte("series")}, hits)
+		assert.Equal(t, []storage.SeriesRef{2}, misses)
+
+		b, ok = cache.FetchExpandedPostings(ctx, user, blockID, matchersKey, "strategy")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("expanded"), b)
+		_, ok = cache.FetchExpandedPostings(ctx, user, blockID, matchersKey, "other")
+		assert.False(t, ok)
+
+		b, ok = cache.FetchSeriesForPostings(ctx, user, blockID, shard, postingsKey)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("series-for-postings"), b)
+
+		b, ok = cache.FetchLabelNames(ctx, user, blockID, matchersKey)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("names"), b)
+
+		b, ok = cache.FetchLabelValues(ctx, user, blockID, "foo", matchersKey)
+		assert.True(t, ok)
+		assert.Equal(t, []byte("values"), b)
+	}
+
+	assertContent(t, cache)
+
+	// A new cache on the same directory should load the previously stored entries.
+	reg := prometheus.NewPedanticRegistry()
+	reloaded, err := NewDiskIndexCache(log.NewNopLogger(), reg, cfg)
+	require.NoError(t, err)
+	t.Cleanup(reloaded.Stop)
+	assert.Equal(t, cache.cache.Size(), reloaded.cache.Size())
+	assert.Equal(t, 6, reloaded.cache.Len())
+	for _, typ := range allCacheTypes {
+		assert.Equal(t, float64(1), promtest.ToFloat64(reloaded.current.WithLabelValues(typ)), typ)
+	}
+
+	assertContent(t, reloaded)
+}
+
+func TestDiskIndexCache_Eviction(t *testing.T) {
+	user := "tenant"
+	blockID := ulid.MustNew(1, nil)
+	value := []byte(strings.Repeat("x", 100))
+	entrySize := disklru.ItemSize(seriesForRefCacheKey(user, blockID, 1), value)
+
+	dir := t.TempDir()
+	cache, err := NewDiskIndexCache(log.NewNopLogger(), nil, DiskIndexCacheConfig{
+		Dir:         dir,
+		MaxSize:     flagext.Bytes(2*entrySize + entrySize/2),
+		MaxItemSize: flagext.Bytes(entrySize),
+	})
+	require.NoError(t, err)
+	t.Cleanup(cache.Stop)
+
+	cache.StoreSeriesForRef(user, blockID, 1, value)
+	cache.StoreSeriesForRef(user, blockID, 2, value)
+	cache.cache.Flush()
+
+	// Fetch the first entry, so that the second one is the least recently used.
+	hits, _ := cache.FetchMultiSeriesForRefs(context.Background(), user, blockID, []storage.SeriesRef{1})
+	assert.Len(t, hits, 1)
+
+	cache.StoreSeriesForRef(user, blockID, 3, value)
+	cache.cache.Flush()
+
+	hits, misses := cache.FetchMultiSeriesForRefs(context.Background(), user, blockID, []storage.SeriesRef{1, 2, 3})
+	assert.Len(t, hits, 2)
+	assert.Equal(t, []storage.SeriesRef{2}, misses)
+	assert.Equal(t, 2*entrySize, cache.cache.Size())
+	assert.Equal(t, float64(1), promtest.ToFloat64(cache.evicted.WithLabelValues(cacheTypeSeriesForRef)))
+	assert.Equal(t, float64(3), promtest.ToFloat64(cache.added.WithLabelValues(cacheTypeSeriesForRef)))
+	assert.Equal(t, float64(2), promtest.ToFloat64(cache.current.WithLabelValues(cacheTypeSeriesForRef)))
+	assert.Equal(t, float64(2*entrySize), promtest.ToFloat64(cache.currentSize.WithLabelValues(cacheTypeSeriesForRef)))
+
+	// The file of the evicted entry should have been removed.
+	assert.Len(t, entryFiles(t, filepath.Join(dir, cacheTypeSeriesForRef)), 2)
+
+	// Items bigger than the max item size should not be stored.
+	cache.StoreSeriesForRef(user, blockID, 4, append(value, 'x'))
+	cache.cache.Flush()
+	assert.Equal(t, float64(1), promtest.ToFloat64(cache.overflow.WithLabelValues(cacheTypeSeriesForRef)))
+	assert.Equal(t, 2, cache.cache.Len())
+}
+
+func TestDiskIndexCache_CorruptedEntries(t *testing.T) {
+	user := "tenant"
+	blockID := ulid.MustNew(1, nil)
+	lbl := labels.Label{Name: "foo", Value: "bar"}
+
+	dir := t.TempDir()
+	cfg := DiskIndexCacheConfig{Dir: dir, MaxSize: 1024 * 1024, MaxItemSize: 1024}
+
+	cache, err := NewDiskIndexCache(log.NewNopLogger(), nil, cfg)
+	require.NoError(t, err)
+	t.Cleanup(cache.Stop)
+	cache.StorePostings(user, blockID, lbl, []byte("postings"))
+	cache.cache.Flush()
+
+	// Simulate a torn write.
+	files := entryFiles(t, filepath.Join(dir, cacheTypePostings))
+	require.Len(t, files, 1)
+	content, err := os.ReadFile(files[0])
+	require.NoError(t, err)
+	content[len(content)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(files[0], content, 0o600))
+
+	cache, err = NewDiskIndexCache(log.NewNopLogger(), nil, cfg)
+	require.NoError(t, err)
+	t.Cleanup(cache.Stop)
+	assert.Equal(t, 1, cache.cache.Len())
+
+	result := cache.FetchMultiPostings(context.Background(), user, blockID, []labels.Label{lbl})
+	b, ok := result.Next()
+	assert.True(t, ok)
+	assert.Nil(t, b)
+
+	assert.Equal(t, float64(1), promtest.ToFloat64(cache.corrupted.WithLabelValues(cacheTypePostings)))
+	assert.Equal(t, float64(0), promtest.ToFloat64(cache.hits.WithLabelValues(cacheTypePostings)))
+	assert.Equal(t, float64(0), promtest.ToFloat64(cache.current.WithLabelValues(cacheTypePostings)))
+	assert.Equal(t, 0, cache.cache.Len())
+	assert.NoFileExists(t, files[0])
+
+	// The entry can be stored again.
+	cache.StorePostings(user, blockID, lbl, []byte("postings"))
+	cache.cache.Flush()
+	result = cache.FetchMultiPostings(context.Background(), user, blockID, []labels.Label{lbl})
+	b, _ = result.Next()
+	assert.Equal(t, []byte("postings"), b)
+}
+
+// entryFiles returns the paths of the files of the entries stored in dir.
+func entryFiles(t *testing.T, dir string) []string {
+	var files []string
+	require.NoError(t, filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() {
+			files = append(files, path)
+		}
+		return err
+	}))
+	return files
+}
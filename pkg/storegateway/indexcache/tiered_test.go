@@ -0,0 +1,100 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package indexcache
+
+import (
+	"context"
+	"testing"
+
+	"github.com/go-kit/log"
+	"github.com/oklog/ulid"
+	promtest "github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/storage"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTieredIndexCache(t *testing.T) {
+	newCaches := func(t *testing.T) (*InMemoryIndexCache, *DiskIndexCache, *TieredIndexCache) {
+		inMemory, err := NewInMemoryIndexCacheWithConfig(log.NewNopLogger(), nil, InMemoryIndexCacheConfig{MaxSize: 1024 * 1024, MaxItemSize: 1024})
+		require.NoError(t, err)
+		disk, err := NewDiskIndexCache(log.NewNopLogger(), nil, DiskIndexCacheConfig{Dir: t.TempDir(), MaxSize: 1024 * 1024, MaxItemSize: 1024})
+		require.NoError(t, err)
+		t.Cleanup(disk.Stop)
+		return inMemory, disk, NewTieredIndexCache(inMemory, disk)
+	}
+
+	user := "tenant"
+	blockID := ulid.MustNew(1, nil)
+	ctx := context.Background()
+
+	t.Run("postings", func(t *testing.T) {
+		inMemory, disk, cache := newCaches(t)
+		lbl1 := labels.Label{Name: "foo", Value: "1"}
+		lbl2 := labels.Label{Name: "foo", Value: "2"}
+		lbl3 := labels.Label{Name: "foo", Value: "3"}
+
+		cache.StorePostings(user, blockID, lbl1, []byte("1"))
+		disk.StorePostings(user, blockID, lbl2, []byte("2"))
+		disk.cache.Flush()
+
+		result := cache.FetchMultiPostings(ctx, user, blockID, []labels.Label{lbl1, lbl2, lbl3})
+		assert.Equal(t, 3, result.Remaining())
+		for _, expected := range [][]byte{[]byte("1"), []byte("2"), nil} {
+			b, ok := result.Next()
+			assert.True(t, ok)
+			assert.Equal(t, expected, b)
+		}
+		_, ok := result.Next()
+		assert.False(t, ok)
+
+		// The first tier should have been looked up for all keys, the second one only for the misses.
+		assert.Equal(t, float64(3), promtest.ToFloat64(inMemory.requests.WithLabelValues(cacheTypePostings)))
+		assert.Equal(t, float64(2), promtest.ToFloat64(disk.requests.WithLabelValues(cacheTypePostings)))
+
+		// The entry found in the second tier should have been added to the first one.
+		b, _ := inMemory.FetchMultiPostings(ctx, user, blockID, []labels.Label{lbl2}).Next()
+		assert.Equal(t, []byte("2"), b)
+	})
+
+	t.Run("series for refs", func(t *testing.T) {
+		inMemory, disk, cache := newCaches(t)
+
+		cache.StoreSeriesForRef(user, blockID, 1, []byte("1"))
+		disk.StoreSeriesForRef(user, blockID, 2, []byte("2"))
+		disk.cache.Flush()
+
+		hits, misses := cache.FetchMultiSeriesForRefs(ctx, user, blockID, []storage.SeriesRef{1, 2, 3})
+		assert.Equal(t, map[storage.SeriesRef][]byte{1: []byte("1"), 2: []byte("2")}, hits)
+		assert.Equal(t, []storage.SeriesRef{3}, misses)
+		assert.Equal(t, float64(2), promtest.ToFloat64(disk.requests.WithLabelValues(cacheTypeSeriesForRef)))
+
+		hits, _ = inMemory.FetchMultiSeriesForRefs(ctx, user, blockID, []storage.SeriesRef{2})
+		assert.Equal(t, map[storage.SeriesRef][]byte{2: []byte("2")}, hits)
+	})
+
+	t.Run("expanded postings", func(t *testing.T) {
+		inMemory, disk, cache := newCaches(t)
+		key := CanonicalLabelMatchersKey([]*labels.Matcher{labels.MustNewMatcher(labels.MatchEqual, "foo", "bar")})
+
+		cache.StoreExpandedPostings(user, blockID, key, "strategy", []byte("1"))
+		disk.cache.Flush()
+		b, ok := disk.FetchExpandedPostings(ctx, user, blockID, key, "strategy")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("1"), b)
+
+		disk.StoreExpandedPostings(user, blockID, key, "other", []byte("2"))
+		disk.cache.Flush()
+		b, ok = cache.FetchExpandedPostings(ctx, user, blockID, key, "other")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("2"), b)
+
+		b, ok = inMemory.FetchExpandedPostings(ctx, user, blockID, key, "other")
+		assert.True(t, ok)
+		assert.Equal(t, []byte("2"), b)
+
+		_, ok = cache.FetchExpandedPostings(ctx, user, blockID, key, "missing")
+		assert.False(t, ok)
+	})
+}
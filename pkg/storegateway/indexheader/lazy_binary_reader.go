@@ -39,11 +39,22 @@ type LazyBinaryReaderMetrics struct {
 	unloadCount       prometheus.Counter
 	unloadFailedCount prometheus.Counter
 	loadDuration      prometheus.Histogram
+
+	// Requests to the index-header, by whether it was eagerly loaded at startup or not.
+	prewarmedRequests    prometheus.Counter
+	notPrewarmedRequests prometheus.Counter
 }
 
 // NewLazyBinaryReaderMetrics makes new LazyBinaryReaderMetrics.
 func NewLazyBinaryReaderMetrics(reg prometheus.Registerer) *LazyBinaryReaderMetrics {
+	requests := promauto.With(reg).NewCounterVec(prometheus.CounterOpts{
+		Name: "indexheader_lazy_requests_total",
+		Help: "Total number of requests to lazy index-headers, partitioned by whether the index-header was eagerly loaded at startup and not unloaded since.",
+	}, []string{"prewarmed"})
+
 	return &LazyBinaryReaderMetrics{
+		prewarmedRequests:    requests.WithLabelValues("true"),
+		notPrewarmedRequests: requests.WithLabelValues("false"),
 		loadCount: promauto.With(reg).NewCounter(prometheus.CounterOpts{
 			Name: "indexheader_lazy_load_total",
 			Help: "Total number of index-header lazy load operations.",
@@ -87,6 +98,9 @@ type LazyBinaryReader struct {
 	// Keep track of the last time it was used.
 	usedAt *atomic.Int64
 
+	// Whether the index-header has been eagerly loaded at startup and not unloaded since.
+	prewarmed *atomic.Bool
+
 	blockID ulid.ULID
 }
 
@@ -128,6 +142,7 @@ func NewLazyBinaryReader(
 		filepath:        path,
 		metrics:         metrics,
 		usedAt:          atomic.NewInt64(time.Now().UnixNano()),
+		prewarmed:       atomic.NewBool(false),
 		onClosed:        onClosed,
 		readerFactory:   readerFactory,
 		blockID:         id,
@@ -219,18 +234,35 @@ func (r *LazyBinaryReader) LabelNames() ([]string, error) {
 
 // EagerLoad attempts to eagerly load this index header.
 func (r *LazyBinaryReader) EagerLoad() {
-	_, wg, err := r.getOrLoadReader()
+	_, wg, err := r.acquireReader()
 	if err != nil {
 		level.Warn(r.logger).Log("msg", "eager loading of lazy loaded index-header failed; skipping", "err", err)
 		return
 	}
+	r.prewarmed.Store(true)
 	wg.Done()
 }
 
-// getOrLoadReader ensures the underlying binary index-header reader has been successfully loaded.
+// getOrLoadReader ensures the underlying binary index-header reader has been successfully loaded,
+// and tracks the request in the metrics.
 // Returns the reader, wait group that should be used to signal that usage of reader is finished, and an error on failure.
 // Must be called without lock.
 func (r *LazyBinaryReader) getOrLoadReader() (Reader, *sync.WaitGroup, error) {
+	reader, wg, err := r.acquireReader()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if r.prewarmed.Load() {
+		r.metrics.prewarmedRequests.Inc()
+	} else {
+		r.metrics.notPrewarmedRequests.Inc()
+	}
+	return reader, wg, nil
+}
+
+// acquireReader is like getOrLoadReader, but doesn't track the request in the metrics.
+func (r *LazyBinaryReader) acquireReader() (Reader, *sync.WaitGroup, error) {
 	r.readerMx.RLock()
 	defer r.readerMx.RUnlock()
 
@@ -334,6 +366,7 @@ func (r *LazyBinaryReader) unloadIfIdleSince(ts int64) error {
 	}
 
 	r.reader = nil
+	r.prewarmed.Store(false)
 	return nil
 }
 
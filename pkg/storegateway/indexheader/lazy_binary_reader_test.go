@@ -247,6 +247,17 @@ func TestNewLazyBinaryReader_EagerLoadLazyLoadedIndexHeaders(t *testing.T) {
 		require.Equal(t, []string{"a"}, labelNames)
 		require.Equal(t, float64(1), promtestutil.ToFloat64(r.metrics.loadCount))
 		require.Equal(t, float64(0), promtestutil.ToFloat64(r.metrics.unloadCount))
+
+		// The requests hit the eagerly loaded index-header.
+		require.Equal(t, float64(2), promtestutil.ToFloat64(r.metrics.prewarmedRequests))
+		require.Equal(t, float64(0), promtestutil.ToFloat64(r.metrics.notPrewarmedRequests))
+
+		// Once unloaded, the index-header is no longer considered pre-warmed.
+		require.NoError(t, r.unloadIfIdleSince(0))
+		_, err = r.LabelNames()
+		require.NoError(t, err)
+		require.Equal(t, float64(2), promtestutil.ToFloat64(r.metrics.prewarmedRequests))
+		require.Equal(t, float64(1), promtestutil.ToFloat64(r.metrics.notPrewarmedRequests))
 	})
 }
 
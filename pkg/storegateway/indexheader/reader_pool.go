@@ -62,6 +62,9 @@ type ReaderPool struct {
 	lazyReadersMx           sync.Mutex
 	lazyReaders             map[*LazyBinaryReader]struct{}
 	preShutdownLoadedBlocks *lazyLoadedHeadersSnapshot
+
+	// Where to persist the list of lazy-loaded index-headers. Empty if eager loading is disabled.
+	lazyLoadedSnapshotConfig LazyLoadedHeadersSnapshotConfig
 }
 
 // LazyLoadedHeadersSnapshotConfig stores information needed to track lazy loaded index headers.
@@ -105,6 +108,9 @@ func NewReaderPool(logger log.Logger, indexHeaderConfig Config, lazyLoadingGate
 	}
 
 	p := newReaderPool(logger, indexHeaderConfig, lazyLoadingGate, metrics, snapshot)
+	if eagerLoadingEnabled {
+		p.lazyLoadedSnapshotConfig = lazyLoadedSnapshotConfig
+	}
 
 	// Start a goroutine to close idle readers (only if required).
 	if p.lazyReaderEnabled && p.lazyReaderIdleTimeout > 0 {
@@ -130,12 +136,7 @@ func NewReaderPool(logger log.Logger, indexHeaderConfig Config, lazyLoadingGate
 				case <-tickerIdleReader.C:
 					p.closeIdleReaders()
 				case <-lazyLoadC:
-					snapshot := lazyLoadedHeadersSnapshot{
-						IndexHeaderLastUsedTime: p.LoadedBlocks(),
-						UserID:                  lazyLoadedSnapshotConfig.UserID,
-					}
-
-					if err := snapshot.persist(lazyLoadedSnapshotConfig.Path); err != nil {
+					if err := p.PersistLazyLoadedHeaders(); err != nil {
 						level.Warn(p.logger).Log("msg", "failed to persist list of lazy-loaded index headers", "err", err)
 					}
 				}
@@ -293,6 +294,20 @@ func (p *ReaderPool) onLazyReaderClosed(r *LazyBinaryReader) {
 	delete(p.lazyReaders, r)
 }
 
+// PersistLazyLoadedHeaders writes the list of the currently loaded index-headers to disk, so that they
+// can be eagerly loaded on the next startup. It's a no-op if eager loading is disabled.
+func (p *ReaderPool) PersistLazyLoadedHeaders() error {
+	if p.lazyLoadedSnapshotConfig.Path == "" {
+		return nil
+	}
+
+	snapshot := lazyLoadedHeadersSnapshot{
+		IndexHeaderLastUsedTime: p.LoadedBlocks(),
+		UserID:                  p.lazyLoadedSnapshotConfig.UserID,
+	}
+	return snapshot.persist(p.lazyLoadedSnapshotConfig.Path)
+}
+
 // LoadedBlocks returns a new map of lazy-loaded block IDs and the last time they were used in milliseconds.
 func (p *ReaderPool) LoadedBlocks() map[ulid.ULID]int64 {
 	p.lazyReadersMx.Lock()
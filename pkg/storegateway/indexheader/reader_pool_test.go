@@ -277,6 +277,43 @@ func TestReaderPool_PersistLazyLoadedBlock(t *testing.T) {
 	require.JSONEq(t, `{"index_header_last_used_time":{},"user_id":"anonymous"}`, string(persistedData), "index_header_last_used_time should be cleared")
 }
 
+func TestReaderPool_PersistLazyLoadedHeaders(t *testing.T) {
+	for _, eagerLoadingEnabled := range []bool{true, false} {
+		t.Run(fmt.Sprintf("eager loading enabled: %t", eagerLoadingEnabled), func(t *testing.T) {
+			ctx, tmpDir, bkt, blockID, metrics := prepareReaderPool(t)
+			snapshotDir := t.TempDir()
+
+			indexHeaderConfig := Config{
+				LazyLoadingEnabled:         true,
+				LazyLoadingIdleTimeout:     time.Minute,
+				EagerLoadingStartupEnabled: eagerLoadingEnabled,
+			}
+			pool := NewReaderPool(log.NewNopLogger(), indexHeaderConfig, gate.NewNoop(), metrics, LazyLoadedHeadersSnapshotConfig{Path: snapshotDir, UserID: "anonymous"})
+			defer pool.Close()
+
+			r, err := pool.NewBinaryReader(ctx, log.NewNopLogger(), bkt, tmpDir, blockID, 3, indexHeaderConfig, false)
+			require.NoError(t, err)
+			defer func() { require.NoError(t, r.Close()) }()
+
+			_, err = r.LabelNames()
+			require.NoError(t, err)
+
+			require.NoError(t, pool.PersistLazyLoadedHeaders())
+
+			persistedFile := filepath.Join(snapshotDir, lazyLoadedHeadersListFileName)
+			if !eagerLoadingEnabled {
+				require.NoFileExists(t, persistedFile)
+				return
+			}
+
+			snapshot, err := loadLazyLoadedHeadersSnapshot(persistedFile)
+			require.NoError(t, err)
+			require.Equal(t, "anonymous", snapshot.UserID)
+			require.Contains(t, snapshot.IndexHeaderLastUsedTime, blockID)
+		})
+	}
+}
+
 func prepareReaderPool(t *testing.T) (context.Context, string, *filesystem.Bucket, ulid.ULID, *ReaderPoolMetrics) {
 	ctx := context.Background()
 
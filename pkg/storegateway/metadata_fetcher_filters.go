@@ -7,6 +7,7 @@ package storegateway
 
 import (
 	"context"
+	"sync"
 	"time"
 
 	"github.com/go-kit/log"
@@ -14,6 +15,7 @@ import (
 	"github.com/prometheus/prometheus/model/timestamp"
 	"github.com/thanos-io/objstore"
 
+	mimir_tsdb "github.com/grafana/mimir/pkg/storage/tsdb"
 	"github.com/grafana/mimir/pkg/storage/tsdb/block"
 	"github.com/grafana/mimir/pkg/storage/tsdb/bucketindex"
 )
@@ -81,6 +83,39 @@ func (f *IgnoreDeletionMarkFilter) FilterWithBucketIndex(_ context.Context, meta
 	return nil
 }
 
+// TombstonesFilter doesn't filter out any block, but keeps track of the series deletion
+// tombstones listed in the bucket index, so that they can be honored at query time.
+type TombstonesFilter struct {
+	mtx        sync.RWMutex
+	tombstones mimir_tsdb.Tombstones
+}
+
+func NewTombstonesFilter() *TombstonesFilter {
+	return &TombstonesFilter{}
+}
+
+// Tombstones returns the series deletion tombstones found in the bucket index during the last sync.
+func (f *TombstonesFilter) Tombstones() mimir_tsdb.Tombstones {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return f.tombstones
+}
+
+// Filter implements block.MetadataFilter. Tombstones are only available through the bucket index.
+func (f *TombstonesFilter) Filter(context.Context, map[ulid.ULID]*block.Meta, block.GaugeVec) error {
+	return nil
+}
+
+// FilterWithBucketIndex implements MetadataFilterWithBucketIndex.
+func (f *TombstonesFilter) FilterWithBucketIndex(_ context.Context, _ map[ulid.ULID]*block.Meta, idx *bucketindex.Index, _ block.GaugeVec) error {
+	f.mtx.Lock()
+	defer f.mtx.Unlock()
+
+	f.tombstones = idx.Tombstones
+	return nil
+}
+
 const minTimeExcludedMeta = "min-time-excluded"
 
 // minTimeMetaFilter filters out blocks that contain the most recent data (based on block MinTime).
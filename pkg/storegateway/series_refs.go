@@ -1245,6 +1245,99 @@ func (m *filteringSeriesChunkRefsSetIterator) Err() error {
 	return m.from.Err()
 }
 
+// tombstonesSeriesChunkRefsSetIterator drops the chunks of a single block which have been fully deleted by
+// series deletion tombstones, and the series left without chunks. When chunks are skipped, series are
+// dropped only if the tombstones cover the whole queried range. Partially deleted chunks are returned
+// as is, and their deleted samples are filtered out by the querier.
+type tombstonesSeriesChunkRefsSetIterator struct {
+	from       seriesChunkRefsSetIterator
+	deletions  []tsdb.SeriesDeletion
+	minT, maxT int64
+
+	current seriesChunkRefsSet
+}
+
+func newTombstonesSeriesChunkRefsSetIterator(from seriesChunkRefsSetIterator, tombstones tsdb.Tombstones, minT, maxT int64) (*tombstonesSeriesChunkRefsSetIterator, error) {
+	deletions, err := tsdb.NewSeriesDeletions(tombstones)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tombstonesSeriesChunkRefsSetIterator{
+		from:      from,
+		deletions: deletions,
+		minT:      minT,
+		maxT:      maxT,
+	}, nil
+}
+
+func (m *tombstonesSeriesChunkRefsSetIterator) Next() bool {
+	if !m.from.Next() {
+		return false
+	}
+
+	next := m.from.At()
+	writeIdx := 0
+
+	for _, series := range next.series {
+		var deleted []tsdb.SeriesDeletion
+		for _, d := range m.deletions {
+			if d.Matches(series.lset) {
+				deleted = append(deleted, d)
+			}
+		}
+
+		if len(deleted) > 0 {
+			if len(series.refs) == 0 {
+				if deletionsCover(deleted, m.minT, m.maxT) {
+					continue
+				}
+			} else {
+				// Do not filter the refs in place, because the slice may be shared (eg. with the cache).
+				refs := make([]seriesChunkRef, 0, len(series.refs))
+				for _, ref := range series.refs {
+					if !deletionsCover(deleted, ref.minTime, ref.maxTime) {
+						refs = append(refs, ref)
+					}
+				}
+				if len(refs) == 0 {
+					continue
+				}
+				series.refs = refs
+			}
+		}
+
+		next.series[writeIdx] = series
+		writeIdx++
+	}
+	next.series = next.series[:writeIdx]
+
+	if next.len() == 0 {
+		next.release()
+		return m.Next()
+	}
+	m.current = next
+	return true
+}
+
+func (m *tombstonesSeriesChunkRefsSetIterator) At() seriesChunkRefsSet {
+	return m.current
+}
+
+func (m *tombstonesSeriesChunkRefsSetIterator) Err() error {
+	return m.from.Err()
+}
+
+// deletionsCover returns whether any of the input deletions fully covers the range minT and maxT (both included).
+func deletionsCover(deletions []tsdb.SeriesDeletion, minT, maxT int64) bool {
+	for _, d := range deletions {
+		if d.MinTime <= minT && maxT <= d.MaxTime {
+			return true
+		}
+	}
+	return false
+}
+
 // cachedSeriesForPostingsID contains enough information to be able to tell whether a cache entry
 // is the right cache entry that we are looking for. We store only the postingsKey in the
 // cache key because the encoded postings are too big. We store the encoded postings within
@@ -1357,12 +1450,21 @@ func (b cachedSeriesHasher) CachedHash(seriesID storage.SeriesRef, stats *queryS
 func (b cachedSeriesHasher) Hash(id storage.SeriesRef, lset labels.Labels, stats *queryStats) uint64 {
 	hash, ok := b.CachedHash(id, stats)
 	if !ok {
-		hash = labels.StableHash(lset)
+		hash = seriesShardingHash(lset)
 		b.cache.Store(id, hash)
 	}
 	return hash
 }
 
+// seriesShardingHash returns the hash used to shard the series. The aggregation label of downsampled
+// series is excluded, so that they belong to the same shard as their raw series.
+func seriesShardingHash(lset labels.Labels) uint64 {
+	if lset.Has(block.AggregationLabel) {
+		lset = labels.NewBuilder(lset).Del(block.AggregationLabel).Labels()
+	}
+	return labels.StableHash(lset)
+}
+
 func shardOwned(shard *sharding.ShardSelector, hasher seriesHasher, id storage.SeriesRef, lset labels.Labels, stats *queryStats) bool {
 	if shard == nil {
 		return true
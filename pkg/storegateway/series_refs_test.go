@@ -10,6 +10,7 @@ import (
 	"math/rand"
 	"sort"
 	"testing"
+	"time"
 
 	"github.com/go-kit/log"
 	"github.com/oklog/ulid"
@@ -25,6 +26,7 @@ import (
 
 	"github.com/grafana/mimir/pkg/storage/sharding"
 	"github.com/grafana/mimir/pkg/storage/tsdb"
+	"github.com/grafana/mimir/pkg/storage/tsdb/block"
 	"github.com/grafana/mimir/pkg/storegateway/indexcache"
 	"github.com/grafana/mimir/pkg/util/pool"
 	"github.com/grafana/mimir/pkg/util/test"
@@ -941,6 +943,73 @@ func TestDeduplicatingSeriesChunkRefsSetIterator_PropagatesErrors(t *testing.T)
 	assert.ErrorContains(t, chainedSet.Err(), "something went wrong")
 }
 
+func TestTombstonesSeriesChunkRefsSetIterator(t *testing.T) {
+	// Chunks have minTime and maxTime equal to their index.
+	c := generateSeriesChunksRanges(ulid.MustNew(1, nil), 6)
+
+	series1 := labels.FromStrings("job", "a")
+	series2 := labels.FromStrings("job", "b")
+	series3 := labels.FromStrings("job", "c")
+
+	newTombstone := func(selector string, minT, maxT int64) *tsdb.Tombstone {
+		tombstone, err := tsdb.NewTombstone([]string{selector}, minT, maxT, time.Now())
+		require.NoError(t, err)
+		return tombstone
+	}
+
+	t.Run("with chunks", func(t *testing.T) {
+		source := newSliceSeriesChunkRefsSetIterator(nil,
+			seriesChunkRefsSet{series: []seriesChunkRefs{
+				{lset: series1, refs: []seriesChunkRef{c[0], c[1], c[2]}},
+				{lset: series2, refs: []seriesChunkRef{c[3], c[4], c[5]}},
+			}},
+			seriesChunkRefsSet{series: []seriesChunkRefs{
+				{lset: series3, refs: []seriesChunkRef{c[0], c[1]}},
+			}},
+		)
+
+		it, err := newTombstonesSeriesChunkRefsSetIterator(source, tsdb.Tombstones{
+			newTombstone(`{job=~"a|b"}`, 1, 4),
+			newTombstone(`{job="c"}`, 0, 1),
+		}, 0, 5)
+		require.NoError(t, err)
+
+		sets := readAllSeriesChunkRefsSet(it)
+		require.NoError(t, it.Err())
+		require.Len(t, sets, 1)
+		require.Len(t, sets[0].series, 2)
+
+		assert.Equal(t, series1, sets[0].series[0].lset)
+		assert.Equal(t, []seriesChunkRef{c[0]}, sets[0].series[0].refs)
+		assert.Equal(t, series2, sets[0].series[1].lset)
+		assert.Equal(t, []seriesChunkRef{c[5]}, sets[0].series[1].refs)
+	})
+
+	t.Run("without chunks", func(t *testing.T) {
+		source := newSliceSeriesChunkRefsSetIterator(nil,
+			seriesChunkRefsSet{series: []seriesChunkRefs{
+				{lset: series1},
+				{lset: series2},
+				{lset: series3},
+			}},
+		)
+
+		it, err := newTombstonesSeriesChunkRefsSetIterator(source, tsdb.Tombstones{
+			newTombstone(`{job="a"}`, 1, 5),
+			newTombstone(`{job="b"}`, 0, 5),
+		}, 0, 5)
+		require.NoError(t, err)
+
+		sets := readAllSeriesChunkRefsSet(it)
+		require.NoError(t, it.Err())
+		require.Len(t, sets, 1)
+		require.Len(t, sets[0].series, 2)
+
+		assert.Equal(t, series1, sets[0].series[0].lset)
+		assert.Equal(t, series3, sets[0].series[1].lset)
+	})
+}
+
 func TestLimitingSeriesChunkRefsSetIterator(t *testing.T) {
 	blockID := ulid.MustNew(1, nil)
 	testCases := map[string]struct {
@@ -2620,6 +2689,18 @@ func TestCachedSeriesHasher_Hash_ShouldGuaranteeSeriesShardingConsistencyOverThe
 	}
 }
 
+func TestCachedSeriesHasher_Hash_ShouldShardDownsampledSeriesWithTheirRawSeries(t *testing.T) {
+	hasher := cachedSeriesHasher{hashcache.NewSeriesHashCache(1024 * 1024).GetBlockCache("test")}
+
+	raw := labels.FromStrings(labels.MetricName, "up", "job", "a")
+	rawHash := hasher.Hash(0, raw, &queryStats{})
+
+	for i, aggregate := range block.Aggregations {
+		downsampled := labels.NewBuilder(raw).Set(block.AggregationLabel, aggregate).Labels()
+		assert.Equal(t, rawHash, hasher.Hash(storage.SeriesRef(i+1), downsampled, &queryStats{}), "series: %s", downsampled.String())
+	}
+}
+
 func generatePostings(numPostings int) []storage.SeriesRef {
 	postings := make([]storage.SeriesRef, numPostings)
 	for i := range postings {
@@ -0,0 +1,495 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+// Package disklru implements a size-bounded LRU cache storing each item in a file on the local disk.
+package disklru
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"hash/crc32"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	lru "github.com/hashicorp/golang-lru/v2/simplelru"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/blake2b"
+)
+
+const (
+	itemVersion    = 1
+	itemHeaderSize = 1 + 4 + 8 + 4 // version + CRC32 checksum + expiration + key length.
+	tmpFileSuffix  = ".tmp"
+
+	// writeQueueSize is the max number of items waiting to be written to disk. Items stored while
+	// the queue is full are dropped.
+	writeQueueSize = 1000
+)
+
+var (
+	castagnoliTable = crc32.MakeTable(crc32.Castagnoli)
+
+	// ErrCorrupted is returned when an item fails the checksum verification.
+	ErrCorrupted = errors.New("corrupted cache item")
+	// ErrExpired is returned when an item is expired.
+	ErrExpired = errors.New("expired cache item")
+)
+
+// Config holds the config of a Cache.
+type Config struct {
+	// Dir is the directory where the items are stored.
+	Dir string
+	// MaxSizeBytes is the overall maximum number of bytes the cache can store on disk.
+	MaxSizeBytes uint64
+	// MaxItemSizeBytes is the maximum size of a single item on disk. Defaults to MaxSizeBytes if 0.
+	MaxItemSizeBytes uint64
+	// Namespaces are the namespaces of the items. The items of each namespace are stored in a subdirectory
+	// named after the namespace. Items are stored directly in Dir, with an empty namespace, if none is set.
+	Namespaces []string
+}
+
+// Hooks are notified about the changes of the cache content, for example to track them in metrics.
+// They're called with the lock of the cache held, so they must be cheap. Any of them can be nil.
+type Hooks struct {
+	// OnAdded is called when an item is added to the cache, either because it has been written or
+	// because it has been loaded from disk at startup.
+	OnAdded func(namespace string, size uint64, loaded bool)
+	// OnRemoved is called when an item is removed from the cache, either because it has been evicted
+	// to make room for other items, or because it has been replaced, deleted or discarded.
+	OnRemoved func(namespace string, size uint64, evicted bool)
+	// OnCorrupted is called when an item read from disk fails the checksum verification.
+	OnCorrupted func(namespace string)
+}
+
+// Cache is a size-bounded LRU cache storing each item in a file on the local disk.
+//
+// Items are written to disk asynchronously, by a single worker consuming a bounded queue, and the lock of
+// the cache is never held while reading, writing or removing files. Items are written to a temporary file
+// which is then renamed, and each item embeds its key, its expiration and a checksum of its content which
+// are verified when the item is read back, so that a torn or partially written file (e.g. after a crash)
+// is never returned. The items found on disk at startup are loaded back into the cache.
+type Cache struct {
+	cfg    Config
+	hooks  Hooks
+	logger log.Logger
+
+	mtx     sync.Mutex
+	lru     *lru.LRU[string, item]
+	curSize uint64
+	lastGen uint64
+
+	queue  chan write
+	stopCh chan struct{}
+	done   chan struct{}
+}
+
+// item tracks an item stored on disk.
+type item struct {
+	namespace string
+	size      uint64
+	// gen identifies the item among the items stored over time at the same path.
+	gen uint64
+}
+
+// write is an item waiting to be written to disk, or a flush request if flushed is set.
+type write struct {
+	namespace string
+	key       string
+	val       []byte
+	expiresAt time.Time
+	flushed   chan struct{}
+}
+
+// New makes a new Cache, loads the items found on disk and starts the worker writing the items to disk.
+func New(cfg Config, hooks Hooks, logger log.Logger) (*Cache, error) {
+	if cfg.MaxItemSizeBytes == 0 || cfg.MaxItemSizeBytes > cfg.MaxSizeBytes {
+		cfg.MaxItemSizeBytes = cfg.MaxSizeBytes
+	}
+	if len(cfg.Namespaces) == 0 {
+		cfg.Namespaces = []string{""}
+	}
+
+	c := &Cache{
+		cfg:    cfg,
+		hooks:  hooks,
+		logger: logger,
+		queue:  make(chan write, writeQueueSize),
+		stopCh: make(chan struct{}),
+		done:   make(chan struct{}),
+	}
+
+	// Initialize LRU cache with a high size limit since we will manage evictions ourselves
+	// based on stored size using `RemoveOldest` method.
+	l, err := lru.NewLRU[string, item](int(^uint(0)>>1), nil)
+	if err != nil {
+		return nil, err
+	}
+	c.lru = l
+
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+
+	go c.run()
+	return c, nil
+}
+
+// load adds the items found on disk to the cache, from the least to the most recently
+// written one. Leftover temporary files, and items exceeding the cache size, are removed.
+func (c *Cache) load() error {
+	type fileItem struct {
+		path  string
+		item  item
+		mtime int64
+	}
+	var files []fileItem
+
+	for _, ns := range c.cfg.Namespaces {
+		nsDir := filepath.Join(c.cfg.Dir, ns)
+		if err := os.MkdirAll(nsDir, os.ModePerm); err != nil {
+			return err
+		}
+
+		err := filepath.WalkDir(nsDir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil || d.IsDir() {
+				return err
+			}
+
+			relPath, err := filepath.Rel(c.cfg.Dir, path)
+			if err != nil {
+				return err
+			}
+			if strings.HasSuffix(relPath, tmpFileSuffix) {
+				c.removeFile(relPath)
+				return nil
+			}
+
+			info, err := d.Info()
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			files = append(files, fileItem{
+				path:  relPath,
+				item:  item{namespace: ns, size: uint64(info.Size())},
+				mtime: info.ModTime().UnixNano(),
+			})
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].mtime < files[j].mtime
+	})
+
+	var evicted []string
+	for _, f := range files {
+		if f.item.size > c.cfg.MaxItemSizeBytes {
+			c.removeFile(f.path)
+			continue
+		}
+		evicted = append(evicted, c.ensureFits(f.item.size)...)
+		c.add(f.path, f.item, true)
+	}
+	c.removeFiles(evicted)
+	return nil
+}
+
+// ItemSize returns the size on disk of the item with the given key and value.
+func ItemSize(key string, val []byte) uint64 {
+	return uint64(itemHeaderSize + len(key) + len(val))
+}
+
+// itemPath returns the path of the file storing the item with the given key, relative to the cache directory.
+// Items are spread across 256 subdirectories to keep the size of each directory bounded.
+func itemPath(namespace, key string) string {
+	hash := blake2b.Sum256([]byte(key))
+	name := hex.EncodeToString(hash[:])
+	return filepath.Join(namespace, name[:2], name)
+}
+
+// Len returns the number of items in the cache.
+func (c *Cache) Len() int {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.lru.Len()
+}
+
+// Size returns the size in bytes of the items in the cache.
+func (c *Cache) Size() uint64 {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.curSize
+}
+
+// Contains returns whether the cache contains the item with the given key, without updating its recency.
+func (c *Cache) Contains(namespace, key string) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+	return c.lru.Contains(itemPath(namespace, key))
+}
+
+// Get returns the value of the item with the given key.
+func (c *Cache) Get(namespace, key string) ([]byte, bool) {
+	path := itemPath(namespace, key)
+
+	c.mtx.Lock()
+	it, ok := c.lru.Get(path)
+	c.mtx.Unlock()
+	if !ok {
+		return nil, false
+	}
+
+	// The file is read without holding the lock. If the item is evicted or replaced in the meanwhile,
+	// the file may be gone or store another item, which is just a cache miss.
+	content, err := os.ReadFile(filepath.Join(c.cfg.Dir, path))
+	if err == nil {
+		var val []byte
+		if val, err = decodeItem(content, key, time.Now()); err == nil {
+			return val, true
+		}
+	}
+
+	switch {
+	case errors.Is(err, ErrCorrupted):
+		if c.hooks.OnCorrupted != nil {
+			c.hooks.OnCorrupted(namespace)
+		}
+		level.Warn(c.logger).Log("msg", "discarding corrupted on-disk cache item", "path", path, "err", err)
+	case !errors.Is(err, ErrExpired) && !errors.Is(err, fs.ErrNotExist):
+		level.Warn(c.logger).Log("msg", "failed to read on-disk cache item, discarding it", "path", path, "err", err)
+	}
+
+	// The item is removed only if it hasn't been replaced while it was read.
+	if c.removeIf(path, func(cur item) bool { return cur.gen == it.gen }) && !errors.Is(err, fs.ErrNotExist) {
+		c.removeFile(path)
+	}
+	return nil, false
+}
+
+// Set queues the item with the given key to be written to disk, replacing the existing one, if any.
+// A zero expiresAt means the item never expires. The item is dropped if it's bigger than the max item
+// size or if the write queue is full. The value is retained until the item has been written.
+func (c *Cache) Set(namespace, key string, val []byte, expiresAt time.Time) {
+	if ItemSize(key, val) > c.cfg.MaxItemSizeBytes {
+		return
+	}
+
+	select {
+	case c.queue <- write{namespace: namespace, key: key, val: val, expiresAt: expiresAt}:
+	default:
+		level.Debug(c.logger).Log("msg", "on-disk cache write queue is full, dropping item", "namespace", namespace)
+	}
+}
+
+// Remove removes the item with the given key.
+func (c *Cache) Remove(namespace, key string) {
+	path := itemPath(namespace, key)
+	if c.removeIf(path, func(item) bool { return true }) {
+		c.removeFile(path)
+	}
+}
+
+// Flush waits until the items queued before the call have been written to disk.
+func (c *Cache) Flush() {
+	flushed := make(chan struct{})
+	select {
+	case c.queue <- write{flushed: flushed}:
+	case <-c.done:
+		return
+	}
+
+	select {
+	case <-flushed:
+	case <-c.done:
+	}
+}
+
+// Stop stops the worker writing the items to disk. The items still in the queue are not written.
+func (c *Cache) Stop() {
+	close(c.stopCh)
+	<-c.done
+}
+
+func (c *Cache) run() {
+	defer close(c.done)
+
+	for {
+		select {
+		case w := <-c.queue:
+			if w.flushed != nil {
+				close(w.flushed)
+				continue
+			}
+			c.write(w)
+		case <-c.stopCh:
+			return
+		}
+	}
+}
+
+func (c *Cache) write(w write) {
+	path := itemPath(w.namespace, w.key)
+	content := encodeItem(w.key, w.val, w.expiresAt)
+	size := uint64(len(content))
+
+	if err := c.writeFile(path, content); err != nil {
+		level.Warn(c.logger).Log("msg", "failed to write on-disk cache item", "path", path, "err", err)
+		return
+	}
+
+	c.mtx.Lock()
+	// The file of the previous item, if any, has been replaced, so only its accounting is removed.
+	if prev, ok := c.lru.Peek(path); ok {
+		c.lru.Remove(path)
+		c.removed(prev, false)
+	}
+	evicted := c.ensureFits(size)
+	c.lastGen++
+	c.add(path, item{namespace: w.namespace, size: size, gen: c.lastGen}, false)
+	c.mtx.Unlock()
+
+	c.removeFiles(evicted)
+}
+
+// writeFile atomically writes the content to the file at path, so that a concurrent reader or a crash
+// never observes a partially written item at that path.
+func (c *Cache) writeFile(path string, content []byte) error {
+	fullPath := filepath.Join(c.cfg.Dir, path)
+	if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(filepath.Dir(fullPath), filepath.Base(fullPath)+".*"+tmpFileSuffix)
+	if err != nil {
+		return err
+	}
+	tmpPath := f.Name()
+
+	_, err = f.Write(content)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, fullPath)
+	}
+	if err != nil {
+		_ = os.Remove(tmpPath)
+	}
+	return err
+}
+
+// add adds the item to the cache. Must be called with the lock held.
+func (c *Cache) add(path string, it item, loaded bool) {
+	c.lru.Add(path, it)
+	c.curSize += it.size
+	if c.hooks.OnAdded != nil {
+		c.hooks.OnAdded(it.namespace, it.size, loaded)
+	}
+}
+
+// removed updates the accounting of an item removed from the cache. Must be called with the lock held.
+func (c *Cache) removed(it item, evicted bool) {
+	c.curSize -= it.size
+	if c.hooks.OnRemoved != nil {
+		c.hooks.OnRemoved(it.namespace, it.size, evicted)
+	}
+}
+
+// removeIf removes the item at path from the cache if the condition holds, and returns whether it has
+// been removed. The file of the item is not removed.
+func (c *Cache) removeIf(path string, cond func(item) bool) bool {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	it, ok := c.lru.Peek(path)
+	if !ok || !cond(it) {
+		return false
+	}
+	c.lru.Remove(path)
+	c.removed(it, false)
+	return true
+}
+
+// ensureFits evicts the least recently used items until an item of the given size fits into the cache,
+// and returns the paths of the files of the evicted items, which must be removed by the caller once the
+// lock is released. Must be called with the lock held.
+func (c *Cache) ensureFits(size uint64) []string {
+	var evicted []string
+	for c.curSize+size > c.cfg.MaxSizeBytes {
+		path, it, ok := c.lru.RemoveOldest()
+		if !ok {
+			break
+		}
+		c.removed(it, true)
+		evicted = append(evicted, path)
+	}
+	return evicted
+}
+
+func (c *Cache) removeFiles(paths []string) {
+	for _, path := range paths {
+		c.removeFile(path)
+	}
+}
+
+func (c *Cache) removeFile(path string) {
+	if err := os.Remove(filepath.Join(c.cfg.Dir, path)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		level.Warn(c.logger).Log("msg", "failed to remove on-disk cache item", "path", path, "err", err)
+	}
+}
+
+// encodeItem encodes a cache item in the on-disk format:
+// version (1 byte) | CRC32 of the following bytes (4 bytes) | expiration in ms, 0 if none (8 bytes) | key length (4 bytes) | key | value.
+func encodeItem(key string, val []byte, expiresAt time.Time) []byte {
+	var expiresAtMs int64
+	if !expiresAt.IsZero() {
+		expiresAtMs = expiresAt.UnixMilli()
+	}
+
+	content := make([]byte, itemHeaderSize, itemHeaderSize+len(key)+len(val))
+	content[0] = itemVersion
+	binary.BigEndian.PutUint64(content[5:13], uint64(expiresAtMs))
+	binary.BigEndian.PutUint32(content[13:17], uint32(len(key)))
+	content = append(content, key...)
+	content = append(content, val...)
+	binary.BigEndian.PutUint32(content[1:5], crc32.Checksum(content[5:], castagnoliTable))
+	return content
+}
+
+// decodeItem decodes a cache item in the on-disk format, verifying its checksum, key and expiration.
+func decodeItem(content []byte, key string, now time.Time) ([]byte, error) {
+	if len(content) < itemHeaderSize {
+		return nil, errors.Wrap(ErrCorrupted, "item too short")
+	}
+	if content[0] != itemVersion {
+		return nil, errors.Wrapf(ErrCorrupted, "unexpected version %d", content[0])
+	}
+	if crc32.Checksum(content[5:], castagnoliTable) != binary.BigEndian.Uint32(content[1:5]) {
+		return nil, errors.Wrap(ErrCorrupted, "checksum mismatch")
+	}
+
+	keyLen := int(binary.BigEndian.Uint32(content[13:17]))
+	if len(content) < itemHeaderSize+keyLen {
+		return nil, errors.Wrap(ErrCorrupted, "item too short")
+	}
+	if string(content[itemHeaderSize:itemHeaderSize+keyLen]) != key {
+		// The file is valid but stores a different key with the same hash.
+		return nil, errors.New("key mismatch")
+	}
+	if expiresAtMs := int64(binary.BigEndian.Uint64(content[5:13])); expiresAtMs > 0 && now.UnixMilli() >= expiresAtMs {
+		return nil, ErrExpired
+	}
+	return content[itemHeaderSize+keyLen:], nil
+}
@@ -0,0 +1,249 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package disklru
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type hooksRecorder struct {
+	added, loaded, removed, evicted, corrupted map[string]int
+}
+
+func newHooksRecorder() *hooksRecorder {
+	return &hooksRecorder{
+		added:     map[string]int{},
+		loaded:    map[string]int{},
+		removed:   map[string]int{},
+		evicted:   map[string]int{},
+		corrupted: map[string]int{},
+	}
+}
+
+func (r *hooksRecorder) hooks() Hooks {
+	return Hooks{
+		OnAdded: func(ns string, _ uint64, loaded bool) {
+			if loaded {
+				r.loaded[ns]++
+			} else {
+				r.added[ns]++
+			}
+		},
+		OnRemoved: func(ns string, _ uint64, evicted bool) {
+			if evicted {
+				r.evicted[ns]++
+			} else {
+				r.removed[ns]++
+			}
+		},
+		OnCorrupted: func(ns string) { r.corrupted[ns]++ },
+	}
+}
+
+func newTestCache(t *testing.T, cfg Config, hooks Hooks) *Cache {
+	c, err := New(cfg, hooks, log.NewNopLogger())
+	require.NoError(t, err)
+	t.Cleanup(c.Stop)
+	return c
+}
+
+func TestCache_SetAndGet(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Dir: dir, MaxSizeBytes: 1024 * 1024, Namespaces: []string{"a", "b"}}
+	rec := newHooksRecorder()
+	c := newTestCache(t, cfg, rec.hooks())
+
+	c.Set("a", "key-1", []byte("value-1"), time.Time{})
+	c.Set("b", "key-1", []byte("value-2"), time.Time{})
+	c.Flush()
+
+	val, ok := c.Get("a", "key-1")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value-1"), val)
+	val, ok = c.Get("b", "key-1")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value-2"), val)
+	_, ok = c.Get("a", "key-2")
+	assert.False(t, ok)
+
+	assert.True(t, c.Contains("a", "key-1"))
+	assert.False(t, c.Contains("a", "key-2"))
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, ItemSize("key-1", []byte("value-1"))+ItemSize("key-1", []byte("value-2")), c.Size())
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, rec.added)
+	assert.FileExists(t, filepath.Join(dir, itemPath("a", "key-1")))
+
+	// Replacing an item keeps its file, and only updates the accounting.
+	c.Set("a", "key-1", []byte("value-3"), time.Time{})
+	c.Flush()
+	val, ok = c.Get("a", "key-1")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value-3"), val)
+	assert.Equal(t, 2, c.Len())
+	assert.Equal(t, map[string]int{"a": 1}, rec.removed)
+
+	// A new cache on the same directory should load the previously stored items.
+	reloadedRec := newHooksRecorder()
+	reloaded := newTestCache(t, cfg, reloadedRec.hooks())
+	assert.Equal(t, c.Size(), reloaded.Size())
+	assert.Equal(t, map[string]int{"a": 1, "b": 1}, reloadedRec.loaded)
+	val, ok = reloaded.Get("b", "key-1")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value-2"), val)
+
+	// Removed items should be removed from disk.
+	reloaded.Remove("a", "key-1")
+	_, ok = reloaded.Get("a", "key-1")
+	assert.False(t, ok)
+	assert.NoFileExists(t, filepath.Join(dir, itemPath("a", "key-1")))
+}
+
+func TestCache_Eviction(t *testing.T) {
+	dir := t.TempDir()
+	value := bytes.Repeat([]byte("x"), 100)
+	itemSize := ItemSize("key-1", value)
+
+	rec := newHooksRecorder()
+	c := newTestCache(t, Config{Dir: dir, MaxSizeBytes: 2*itemSize + itemSize/2}, rec.hooks())
+
+	c.Set("", "key-1", value, time.Time{})
+	c.Set("", "key-2", value, time.Time{})
+	c.Flush()
+
+	// Fetch the first item, so that the second one is the least recently used.
+	_, ok := c.Get("", "key-1")
+	assert.True(t, ok)
+
+	c.Set("", "key-3", value, time.Time{})
+	c.Flush()
+
+	for key, expected := range map[string]bool{"key-1": true, "key-2": false, "key-3": true} {
+		_, ok := c.Get("", key)
+		assert.Equal(t, expected, ok, key)
+	}
+	assert.Equal(t, 2*itemSize, c.Size())
+	assert.Equal(t, map[string]int{"": 1}, rec.evicted)
+	assert.NoFileExists(t, filepath.Join(dir, itemPath("", "key-2")))
+
+	// Items bigger than the max item size should not be stored.
+	c.Set("", "key-4", bytes.Repeat([]byte("x"), 3*int(itemSize)), time.Time{})
+	c.Flush()
+	assert.False(t, c.Contains("", "key-4"))
+
+	// A smaller cache on the same directory should only load the most recently written items.
+	reloaded := newTestCache(t, Config{Dir: dir, MaxSizeBytes: itemSize}, Hooks{})
+	assert.Equal(t, 1, reloaded.Len())
+	_, ok = reloaded.Get("", "key-3")
+	assert.True(t, ok)
+}
+
+func TestCache_CorruptedAndExpiredItems(t *testing.T) {
+	dir := t.TempDir()
+	cfg := Config{Dir: dir, MaxSizeBytes: 1024 * 1024}
+
+	c := newTestCache(t, cfg, Hooks{})
+	c.Set("", "corrupted", []byte("data"), time.Time{})
+	c.Set("", "expired", []byte("data"), time.Now().Add(-time.Second))
+	c.Flush()
+
+	// Simulate a leftover temporary file and a torn write.
+	tmpPath := filepath.Join(dir, itemPath("", "corrupted")+".123"+tmpFileSuffix)
+	require.NoError(t, os.WriteFile(tmpPath, []byte("partial"), 0o600))
+
+	path := filepath.Join(dir, itemPath("", "corrupted"))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	content[len(content)-1] ^= 0xff
+	require.NoError(t, os.WriteFile(path, content, 0o600))
+
+	rec := newHooksRecorder()
+	c = newTestCache(t, cfg, rec.hooks())
+	assert.NoFileExists(t, tmpPath)
+	assert.Equal(t, 2, c.Len())
+
+	for _, key := range []string{"corrupted", "expired"} {
+		_, ok := c.Get("", key)
+		assert.False(t, ok, key)
+	}
+	assert.Equal(t, map[string]int{"": 1}, rec.corrupted)
+	assert.Equal(t, 0, c.Len())
+	assert.Equal(t, uint64(0), c.Size())
+	assert.NoFileExists(t, path)
+	assert.NoFileExists(t, filepath.Join(dir, itemPath("", "expired")))
+
+	// An item whose file is gone is a cache miss, and is removed from the cache.
+	c.Set("", "missing", []byte("data"), time.Time{})
+	c.Flush()
+	require.NoError(t, os.Remove(filepath.Join(dir, itemPath("", "missing"))))
+	_, ok := c.Get("", "missing")
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCache_RemoveIfReplaced(t *testing.T) {
+	c := newTestCache(t, Config{Dir: t.TempDir(), MaxSizeBytes: 1024 * 1024}, Hooks{})
+	path := itemPath("", "key")
+
+	c.Set("", "key", []byte("value-1"), time.Time{})
+	c.Flush()
+	c.mtx.Lock()
+	prev, _ := c.lru.Peek(path)
+	c.mtx.Unlock()
+
+	// A failed read of the previous item must not remove the item which replaced it.
+	c.Set("", "key", []byte("value-2"), time.Time{})
+	c.Flush()
+	assert.False(t, c.removeIf(path, func(cur item) bool { return cur.gen == prev.gen }))
+
+	val, ok := c.Get("", "key")
+	assert.True(t, ok)
+	assert.Equal(t, []byte("value-2"), val)
+}
+
+func TestCache_WritesWithoutWorker(t *testing.T) {
+	c, err := New(Config{Dir: t.TempDir(), MaxSizeBytes: 1024 * 1024}, Hooks{}, log.NewNopLogger())
+	require.NoError(t, err)
+	c.Stop()
+
+	// Items are dropped once the queue is full, and flushing a stopped cache doesn't block.
+	for i := 0; i < writeQueueSize+1; i++ {
+		c.Set("", "key", []byte("value"), time.Time{})
+	}
+	c.Flush()
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestDecodeItem(t *testing.T) {
+	now := time.Now()
+	content := encodeItem("key", []byte("value"), now.Add(time.Minute))
+
+	val, err := decodeItem(content, "key", now)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), val)
+
+	_, err = decodeItem(content, "key", now.Add(time.Minute))
+	assert.ErrorIs(t, err, ErrExpired)
+
+	_, err = decodeItem(content, "other", now)
+	assert.Error(t, err)
+	assert.NotErrorIs(t, err, ErrCorrupted)
+
+	_, err = decodeItem(content[:len(content)-1], "key", now)
+	assert.ErrorIs(t, err, ErrCorrupted)
+
+	_, err = decodeItem(content[:itemHeaderSize-1], "key", now)
+	assert.ErrorIs(t, err, ErrCorrupted)
+
+	// Items without expiration never expire.
+	val, err = decodeItem(encodeItem("key", []byte("value"), time.Time{}), "key", now.Add(time.Hour))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("value"), val)
+}
@@ -47,6 +47,8 @@ const (
 	MaxPartialQueryLengthFlag                = "querier.max-partial-query-length"
 	MaxTotalQueryLengthFlag                  = "query-frontend.max-total-query-length"
 	MaxQueryExpressionSizeBytesFlag          = "query-frontend.max-query-expression-size-bytes"
+	MaxQueryCostFlag                         = "query-frontend.max-query-cost"
+	MaxExportBytesFlag                       = "querier.max-export-bytes"
 	RequestRateFlag                          = "distributor.request-rate-limit"
 	RequestBurstSizeFlag                     = "distributor.request-burst-size"
 	IngestionRateFlag                        = "distributor.ingestion-rate-limit"
@@ -65,6 +67,9 @@ const (
 var (
 	errInvalidIngestStorageReadConsistency         = fmt.Errorf("invalid ingest storage read consistency (supported values: %s)", strings.Join(api.ReadConsistencies, ", "))
 	errInvalidMaxEstimatedChunksPerQueryMultiplier = errors.New("invalid value for -" + MaxEstimatedChunksPerQueryMultiplierFlag + ": must be 0 or greater than or equal to 1")
+	errInvalidRetentionRule                        = errors.New("invalid compactor_retention_rules")
+	errInvalidQueryPriorityClass                   = fmt.Errorf("invalid query priority class in query_priority_classes_allowed (supported values: %s)", strings.Join(api.QueryPriorityClasses, ", "))
+	errQueryCostRequiresCardinalityAnalysis        = errors.New("the query cost limits require the cardinality analysis to be enabled (-querier.cardinality-analysis-enabled)")
 )
 
 // LimitError is a marker interface for the errors that do not comply with the specified limits.
@@ -118,6 +123,7 @@ type Limits struct {
 	MetricRelabelConfigs                        []*relabel.Config   `yaml:"metric_relabel_configs,omitempty" json:"metric_relabel_configs,omitempty" doc:"nocli|description=List of metric relabel configurations. Note that in most situations, it is more effective to use metrics relabeling directly in the Prometheus server, e.g. remote_write.write_relabel_configs. Labels available during the relabeling phase and cleaned afterwards: __meta_tenant_id" category:"experimental"`
 	MetricRelabelingEnabled                     bool                `yaml:"metric_relabeling_enabled" json:"metric_relabeling_enabled" category:"experimental"`
 	ServiceOverloadStatusCodeOnRateLimitEnabled bool                `yaml:"service_overload_status_code_on_rate_limit_enabled" json:"service_overload_status_code_on_rate_limit_enabled" category:"experimental"`
+	ReplayBufferMaxBytesPerTenant               int                 `yaml:"replay_buffer_max_bytes_per_tenant" json:"replay_buffer_max_bytes_per_tenant" category:"experimental"`
 	// Ingester enforced limits.
 	// Series
 	MaxGlobalSeriesPerUser   int `yaml:"max_global_series_per_user" json:"max_global_series_per_user"`
@@ -154,17 +160,22 @@ type Limits struct {
 	QueryShardingMaxRegexpSizeBytes      int            `yaml:"query_sharding_max_regexp_size_bytes" json:"query_sharding_max_regexp_size_bytes"`
 	SplitInstantQueriesByInterval        model.Duration `yaml:"split_instant_queries_by_interval" json:"split_instant_queries_by_interval" category:"experimental"`
 	QueryIngestersWithin                 model.Duration `yaml:"query_ingesters_within" json:"query_ingesters_within" category:"advanced"`
+	MaxExportBytes                       int            `yaml:"max_export_bytes" json:"max_export_bytes" category:"experimental"`
 
 	// Query-frontend limits.
-	MaxTotalQueryLength                    model.Duration  `yaml:"max_total_query_length" json:"max_total_query_length"`
-	ResultsCacheTTL                        model.Duration  `yaml:"results_cache_ttl" json:"results_cache_ttl"`
-	ResultsCacheTTLForOutOfOrderTimeWindow model.Duration  `yaml:"results_cache_ttl_for_out_of_order_time_window" json:"results_cache_ttl_for_out_of_order_time_window"`
-	ResultsCacheTTLForCardinalityQuery     model.Duration  `yaml:"results_cache_ttl_for_cardinality_query" json:"results_cache_ttl_for_cardinality_query"`
-	ResultsCacheTTLForLabelsQuery          model.Duration  `yaml:"results_cache_ttl_for_labels_query" json:"results_cache_ttl_for_labels_query"`
-	ResultsCacheForUnalignedQueryEnabled   bool            `yaml:"cache_unaligned_requests" json:"cache_unaligned_requests" category:"advanced"`
-	MaxQueryExpressionSizeBytes            int             `yaml:"max_query_expression_size_bytes" json:"max_query_expression_size_bytes"`
-	BlockedQueries                         []*BlockedQuery `yaml:"blocked_queries,omitempty" json:"blocked_queries,omitempty" doc:"nocli|description=List of queries to block." category:"experimental"`
-	AlignQueriesWithStep                   bool            `yaml:"align_queries_with_step" json:"align_queries_with_step"`
+	MaxTotalQueryLength                    model.Duration         `yaml:"max_total_query_length" json:"max_total_query_length"`
+	ResultsCacheTTL                        model.Duration         `yaml:"results_cache_ttl" json:"results_cache_ttl"`
+	ResultsCacheTTLForOutOfOrderTimeWindow model.Duration         `yaml:"results_cache_ttl_for_out_of_order_time_window" json:"results_cache_ttl_for_out_of_order_time_window"`
+	ResultsCacheTTLForCardinalityQuery     model.Duration         `yaml:"results_cache_ttl_for_cardinality_query" json:"results_cache_ttl_for_cardinality_query"`
+	ResultsCacheTTLForLabelsQuery          model.Duration         `yaml:"results_cache_ttl_for_labels_query" json:"results_cache_ttl_for_labels_query"`
+	ResultsCacheForUnalignedQueryEnabled   bool                   `yaml:"cache_unaligned_requests" json:"cache_unaligned_requests" category:"advanced"`
+	ResultsCacheInstantQueryAlignment      model.Duration         `yaml:"results_cache_instant_query_alignment" json:"results_cache_instant_query_alignment" category:"experimental"`
+	MaxQueryExpressionSizeBytes            int                    `yaml:"max_query_expression_size_bytes" json:"max_query_expression_size_bytes"`
+	MaxQueryCost                           int                    `yaml:"max_query_cost" json:"max_query_cost" category:"experimental"`
+	QueryCostDeprioritizationThreshold     int                    `yaml:"query_cost_deprioritization_threshold" json:"query_cost_deprioritization_threshold" category:"experimental"`
+	QueryPriorityClassesAllowed            flagext.StringSliceCSV `yaml:"query_priority_classes_allowed" json:"query_priority_classes_allowed" category:"experimental"`
+	BlockedQueries                         []*BlockedQuery        `yaml:"blocked_queries,omitempty" json:"blocked_queries,omitempty" doc:"nocli|description=List of queries to block." category:"experimental"`
+	AlignQueriesWithStep                   bool                   `yaml:"align_queries_with_step" json:"align_queries_with_step"`
 
 	// Cardinality
 	CardinalityAnalysisEnabled                    bool `yaml:"cardinality_analysis_enabled" json:"cardinality_analysis_enabled"`
@@ -173,27 +184,32 @@ type Limits struct {
 	ActiveSeriesResultsMaxSizeBytes               int  `yaml:"active_series_results_max_size_bytes" json:"active_series_results_max_size_bytes" category:"experimental"`
 
 	// Ruler defaults and limits.
-	RulerEvaluationDelay                 model.Duration `yaml:"ruler_evaluation_delay_duration" json:"ruler_evaluation_delay_duration"`
-	RulerTenantShardSize                 int            `yaml:"ruler_tenant_shard_size" json:"ruler_tenant_shard_size"`
-	RulerMaxRulesPerRuleGroup            int            `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
-	RulerMaxRuleGroupsPerTenant          int            `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
-	RulerRecordingRulesEvaluationEnabled bool           `yaml:"ruler_recording_rules_evaluation_enabled" json:"ruler_recording_rules_evaluation_enabled" category:"experimental"`
-	RulerAlertingRulesEvaluationEnabled  bool           `yaml:"ruler_alerting_rules_evaluation_enabled" json:"ruler_alerting_rules_evaluation_enabled" category:"experimental"`
-	RulerSyncRulesOnChangesEnabled       bool           `yaml:"ruler_sync_rules_on_changes_enabled" json:"ruler_sync_rules_on_changes_enabled" category:"advanced"`
+	RulerEvaluationDelay                                  model.Duration `yaml:"ruler_evaluation_delay_duration" json:"ruler_evaluation_delay_duration"`
+	RulerTenantShardSize                                  int            `yaml:"ruler_tenant_shard_size" json:"ruler_tenant_shard_size"`
+	RulerMaxRulesPerRuleGroup                             int            `yaml:"ruler_max_rules_per_rule_group" json:"ruler_max_rules_per_rule_group"`
+	RulerMaxRuleGroupsPerTenant                           int            `yaml:"ruler_max_rule_groups_per_tenant" json:"ruler_max_rule_groups_per_tenant"`
+	RulerRecordingRulesEvaluationEnabled                  bool           `yaml:"ruler_recording_rules_evaluation_enabled" json:"ruler_recording_rules_evaluation_enabled" category:"experimental"`
+	RulerAlertingRulesEvaluationEnabled                   bool           `yaml:"ruler_alerting_rules_evaluation_enabled" json:"ruler_alerting_rules_evaluation_enabled" category:"experimental"`
+	RulerSyncRulesOnChangesEnabled                        bool           `yaml:"ruler_sync_rules_on_changes_enabled" json:"ruler_sync_rules_on_changes_enabled" category:"advanced"`
+	RulerMaxIndependentRuleEvaluationConcurrencyPerTenant int            `yaml:"ruler_max_independent_rule_evaluation_concurrency_per_tenant" json:"ruler_max_independent_rule_evaluation_concurrency_per_tenant" category:"experimental"`
 
 	// Store-gateway.
 	StoreGatewayTenantShardSize int `yaml:"store_gateway_tenant_shard_size" json:"store_gateway_tenant_shard_size"`
 
 	// Compactor.
-	CompactorBlocksRetentionPeriod        model.Duration `yaml:"compactor_blocks_retention_period" json:"compactor_blocks_retention_period"`
-	CompactorSplitAndMergeShards          int            `yaml:"compactor_split_and_merge_shards" json:"compactor_split_and_merge_shards"`
-	CompactorSplitGroups                  int            `yaml:"compactor_split_groups" json:"compactor_split_groups"`
-	CompactorTenantShardSize              int            `yaml:"compactor_tenant_shard_size" json:"compactor_tenant_shard_size"`
-	CompactorPartialBlockDeletionDelay    model.Duration `yaml:"compactor_partial_block_deletion_delay" json:"compactor_partial_block_deletion_delay"`
-	CompactorBlockUploadEnabled           bool           `yaml:"compactor_block_upload_enabled" json:"compactor_block_upload_enabled"`
-	CompactorBlockUploadValidationEnabled bool           `yaml:"compactor_block_upload_validation_enabled" json:"compactor_block_upload_validation_enabled"`
-	CompactorBlockUploadVerifyChunks      bool           `yaml:"compactor_block_upload_verify_chunks" json:"compactor_block_upload_verify_chunks"`
-	CompactorBlockUploadMaxBlockSizeBytes int64          `yaml:"compactor_block_upload_max_block_size_bytes" json:"compactor_block_upload_max_block_size_bytes" category:"advanced"`
+	CompactorBlocksRetentionPeriod        model.Duration   `yaml:"compactor_blocks_retention_period" json:"compactor_blocks_retention_period"`
+	CompactorSplitAndMergeShards          int              `yaml:"compactor_split_and_merge_shards" json:"compactor_split_and_merge_shards"`
+	CompactorSplitGroups                  int              `yaml:"compactor_split_groups" json:"compactor_split_groups"`
+	CompactorTenantShardSize              int              `yaml:"compactor_tenant_shard_size" json:"compactor_tenant_shard_size"`
+	CompactorPartialBlockDeletionDelay    model.Duration   `yaml:"compactor_partial_block_deletion_delay" json:"compactor_partial_block_deletion_delay"`
+	CompactorBlockUploadEnabled           bool             `yaml:"compactor_block_upload_enabled" json:"compactor_block_upload_enabled"`
+	CompactorBlockUploadValidationEnabled bool             `yaml:"compactor_block_upload_validation_enabled" json:"compactor_block_upload_validation_enabled"`
+	CompactorBlockUploadVerifyChunks      bool             `yaml:"compactor_block_upload_verify_chunks" json:"compactor_block_upload_verify_chunks"`
+	CompactorBlockUploadMaxBlockSizeBytes int64            `yaml:"compactor_block_upload_max_block_size_bytes" json:"compactor_block_upload_max_block_size_bytes" category:"advanced"`
+	CompactorSeriesDeletionEnabled        bool             `yaml:"compactor_series_deletion_enabled" json:"compactor_series_deletion_enabled" category:"experimental"`
+	CompactorDownsample5mAfter            model.Duration   `yaml:"compactor_downsample_5m_after" json:"compactor_downsample_5m_after" category:"experimental"`
+	CompactorDownsample1hAfter            model.Duration   `yaml:"compactor_downsample_1h_after" json:"compactor_downsample_1h_after" category:"experimental"`
+	CompactorRetentionRules               []*RetentionRule `yaml:"compactor_retention_rules,omitempty" json:"compactor_retention_rules,omitempty" doc:"nocli|description=List of retention rules, each one made of a series selector and a retention period. The compactor rewrites the blocks whose whole time range is older than the retention period of a rule without the series matching its selector. The retention period of a rule should be shorter than the blocks retention period, which still applies to all series." category:"experimental"`
 
 	// This config doesn't have a CLI flag registered here because they're registered in
 	// their own original config struct.
@@ -214,10 +230,18 @@ type Limits struct {
 	AlertmanagerMaxDispatcherAggregationGroups int `yaml:"alertmanager_max_dispatcher_aggregation_groups" json:"alertmanager_max_dispatcher_aggregation_groups"`
 	AlertmanagerMaxAlertsCount                 int `yaml:"alertmanager_max_alerts_count" json:"alertmanager_max_alerts_count"`
 	AlertmanagerMaxAlertsSizeBytes             int `yaml:"alertmanager_max_alerts_size_bytes" json:"alertmanager_max_alerts_size_bytes"`
+	AlertmanagerMaxNotificationHistoryEntries  int `yaml:"alertmanager_max_notification_history_entries" json:"alertmanager_max_notification_history_entries" category:"experimental"`
 
 	// OpenTelemetry
 	OTelMetricSuffixesEnabled bool `yaml:"otel_metric_suffixes_enabled" json:"otel_metric_suffixes_enabled" category:"advanced"`
 
+	// Remote-write 2.0
+	CreatedTimestampZeroIngestionEnabled bool `yaml:"created_timestamp_zero_ingestion_enabled" json:"created_timestamp_zero_ingestion_enabled" category:"experimental"`
+
+	// InfluxDB line protocol
+	InfluxMetricNameSeparator string `yaml:"influx_metric_name_separator" json:"influx_metric_name_separator" category:"experimental"`
+	InfluxValueFieldName      string `yaml:"influx_value_field_name" json:"influx_value_field_name" category:"experimental"`
+
 	// Ingest storage.
 	IngestStorageReadConsistency string `yaml:"ingest_storage_read_consistency" json:"ingest_storage_read_consistency" category:"experimental" doc:"hidden"`
 
@@ -236,6 +260,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.StringVar(&l.HAClusterLabel, "distributor.ha-tracker.cluster", "cluster", "Prometheus label to look for in samples to identify a Prometheus HA cluster.")
 	f.StringVar(&l.HAReplicaLabel, "distributor.ha-tracker.replica", "__replica__", "Prometheus label to look for in samples to identify a Prometheus HA replica.")
 	f.IntVar(&l.HAMaxClusters, HATrackerMaxClustersFlag, 100, "Maximum number of clusters that HA tracker will keep track of for a single tenant. 0 to disable the limit.")
+	f.IntVar(&l.ReplayBufferMaxBytesPerTenant, "distributor.replay-buffer.max-bytes-per-tenant", 0, "Maximum size, in bytes, of the write requests queued in the distributor replay buffer for a single tenant while ingesters are unavailable. The replay buffer must be enabled with -distributor.replay-buffer.enabled. 0 to disable the replay buffer for the tenant.")
 	f.Var(&l.DropLabels, "distributor.drop-label", "This flag can be used to specify label names that to drop during sample ingestion within the distributor and can be repeated in order to drop multiple labels.")
 	f.IntVar(&l.MaxLabelNameLength, MaxLabelNameLengthFlag, 1024, "Maximum length accepted for label names")
 	f.IntVar(&l.MaxLabelValueLength, MaxLabelValueLengthFlag, 2048, "Maximum length accepted for label value. This setting also applies to the metric name")
@@ -249,6 +274,9 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&l.MetricRelabelingEnabled, "distributor.metric-relabeling-enabled", true, "Enable metric relabeling for the tenant. This configuration option can be used to forcefully disable metric relabeling on a per-tenant basis.")
 	f.BoolVar(&l.ServiceOverloadStatusCodeOnRateLimitEnabled, "distributor.service-overload-status-code-on-rate-limit-enabled", false, "If enabled, rate limit errors will be reported to the client with HTTP status code 529 (Service is overloaded). If disabled, status code 429 (Too Many Requests) is used. Enabling -distributor.retry-after-header.enabled before utilizing this option is strongly recommended as it helps prevent premature request retries by the client.")
 	f.BoolVar(&l.OTelMetricSuffixesEnabled, "distributor.otel-metric-suffixes-enabled", false, "Whether to enable automatic suffixes to names of metrics ingested through OTLP.")
+	f.BoolVar(&l.CreatedTimestampZeroIngestionEnabled, "distributor.created-timestamp-zero-ingestion-enabled", false, "Whether to ingest a zero sample at the created timestamp of each series received through the remote-write 2.0 protocol, if the created timestamp is earlier than the first sample of the series.")
+	f.StringVar(&l.InfluxMetricNameSeparator, "distributor.influx.metric-name-separator", "_", "Separator between the measurement and the field name in the names of metrics ingested through the InfluxDB line protocol endpoint.")
+	f.StringVar(&l.InfluxValueFieldName, "distributor.influx.value-field-name", "value", "Name of the InfluxDB line protocol field which is ingested as a metric named after the measurement only, without the field name suffix.")
 
 	f.IntVar(&l.MaxGlobalSeriesPerUser, MaxSeriesPerUserFlag, 150000, "The maximum number of in-memory series per tenant, across the cluster before replication. 0 to disable.")
 	f.IntVar(&l.MaxGlobalSeriesPerMetric, MaxSeriesPerMetricFlag, 0, "The maximum number of in-memory series per metric name, across the cluster before replication. 0 to disable.")
@@ -267,6 +295,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Float64Var(&l.MaxEstimatedChunksPerQueryMultiplier, MaxEstimatedChunksPerQueryMultiplierFlag, 0, "Maximum number of chunks estimated to be fetched in a single query from ingesters and long-term storage, as a multiple of -"+MaxChunksPerQueryFlag+". This limit is enforced in the querier. Must be greater than or equal to 1, or 0 to disable.")
 	f.IntVar(&l.MaxFetchedSeriesPerQuery, MaxSeriesPerQueryFlag, 0, "The maximum number of unique series for which a query can fetch samples from each ingesters and storage. This limit is enforced in the querier, ruler and store-gateway. 0 to disable")
 	f.IntVar(&l.MaxFetchedChunkBytesPerQuery, MaxChunkBytesPerQueryFlag, 0, "The maximum size of all chunks in bytes that a query can fetch from each ingester and storage. This limit is enforced in the querier and ruler. 0 to disable.")
+	f.IntVar(&l.MaxExportBytes, MaxExportBytesFlag, 0, "The maximum size in bytes of the response of a single request to the export API, which exports a page of samples. This limit is enforced in the querier. 0 to disable.")
 	f.Var(&l.MaxPartialQueryLength, MaxPartialQueryLengthFlag, "Limit the time range for partial queries at the querier level.")
 	f.Var(&l.MaxQueryLookback, "querier.max-query-lookback", "Limit how long back data (series and metadata) can be queried, up until <lookback> duration ago. This limit is enforced in the query-frontend, querier and ruler. If the requested time range is outside the allowed range, the request will not fail but will be manipulated to only query data within the allowed time range. 0 to disable.")
 	f.IntVar(&l.MaxQueryParallelism, "querier.max-query-parallelism", 14, "Maximum number of split (by time) or partial (by shard) queries that will be scheduled in parallel by the query-frontend for a single input query. This limit is introduced to have a fairer query scheduling and avoid a single query over a large time range saturating all available queriers.")
@@ -294,6 +323,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&l.RulerRecordingRulesEvaluationEnabled, "ruler.recording-rules-evaluation-enabled", true, "Controls whether recording rules evaluation is enabled. This configuration option can be used to forcefully disable recording rules evaluation on a per-tenant basis.")
 	f.BoolVar(&l.RulerAlertingRulesEvaluationEnabled, "ruler.alerting-rules-evaluation-enabled", true, "Controls whether alerting rules evaluation is enabled. This configuration option can be used to forcefully disable alerting rules evaluation on a per-tenant basis.")
 	f.BoolVar(&l.RulerSyncRulesOnChangesEnabled, "ruler.sync-rules-on-changes-enabled", true, "True to enable a re-sync of the configured rule groups as soon as they're changed via ruler's config API. This re-sync is in addition of the periodic syncing. When enabled, it may take up to few tens of seconds before a configuration change triggers the re-sync.")
+	f.IntVar(&l.RulerMaxIndependentRuleEvaluationConcurrencyPerTenant, "ruler.max-independent-rule-evaluation-concurrency-per-tenant", 0, "Maximum number of rules per tenant which are evaluated concurrently with the other rules of their rule group. A rule can be evaluated concurrently if it doesn't depend on the output of any other rule in the rule group. 0 to evaluate the rules of each rule group sequentially.")
 
 	f.Var(&l.CompactorBlocksRetentionPeriod, "compactor.blocks-retention-period", "Delete blocks containing samples older than the specified retention period. Also used by query-frontend to avoid querying beyond the retention period. 0 to disable.")
 	f.IntVar(&l.CompactorSplitAndMergeShards, "compactor.split-and-merge-shards", 0, "The number of shards to use when splitting blocks. 0 to disable splitting.")
@@ -305,6 +335,9 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&l.CompactorBlockUploadValidationEnabled, "compactor.block-upload-validation-enabled", true, "Enable block upload validation for the tenant.")
 	f.BoolVar(&l.CompactorBlockUploadVerifyChunks, "compactor.block-upload-verify-chunks", true, "Verify chunks when uploading blocks via the upload API for the tenant.")
 	f.Int64Var(&l.CompactorBlockUploadMaxBlockSizeBytes, "compactor.block-upload-max-block-size-bytes", 0, "Maximum size in bytes of a block that is allowed to be uploaded or validated. 0 = no limit.")
+	f.BoolVar(&l.CompactorSeriesDeletionEnabled, "compactor.series-deletion-enabled", false, "Enable the series deletion API for the tenant. When enabled, the compactor rewrites blocks to physically remove the series matching the tenant's deletion requests.")
+	f.Var(&l.CompactorDownsample5mAfter, "compactor.downsample-5m-after", "Downsample blocks to a 5 minutes resolution once they're older than this period, since their max time. Queriers use the downsampled blocks for queries whose step is 5 minutes or more. 0 to disable.")
+	f.Var(&l.CompactorDownsample1hAfter, "compactor.downsample-1h-after", "Downsample blocks to a 1 hour resolution once they're older than this period, since their max time. Queriers use the downsampled blocks for queries whose step is 1 hour or more. 0 to disable.")
 
 	// Query-frontend.
 	f.Var(&l.MaxTotalQueryLength, MaxTotalQueryLengthFlag, "Limit the total query time range (end - start time). This limit is enforced in the query-frontend on the received query.")
@@ -315,7 +348,11 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.Var(&l.ResultsCacheTTLForCardinalityQuery, "query-frontend.results-cache-ttl-for-cardinality-query", "Time to live duration for cached cardinality query results. The value 0 disables the cache.")
 	f.Var(&l.ResultsCacheTTLForLabelsQuery, "query-frontend.results-cache-ttl-for-labels-query", "Time to live duration for cached label names and label values query results. The value 0 disables the cache.")
 	f.BoolVar(&l.ResultsCacheForUnalignedQueryEnabled, "query-frontend.cache-unaligned-requests", false, "Cache requests that are not step-aligned.")
+	f.Var(&l.ResultsCacheInstantQueryAlignment, "query-frontend.results-cache-instant-query-alignment", fmt.Sprintf("Cache the results of instant queries whose evaluation timestamp is aligned to this resolution. Results are cached for -%s, unless the evaluation timestamp is more recent than -query-frontend.max-cache-freshness or falls into the out-of-order time window. The value 0 disables the cache for instant queries.", resultsCacheTTLFlag))
 	f.IntVar(&l.MaxQueryExpressionSizeBytes, MaxQueryExpressionSizeBytesFlag, 0, "Max size of the raw query, in bytes. 0 to not apply a limit to the size of the query.")
+	f.IntVar(&l.MaxQueryCost, MaxQueryCostFlag, 0, "Maximum estimated cost of a query. The cost is the number of in-memory series matching the query selectors in ingesters, multiplied by the number of data sources queried (ingesters and each block in the long-term storage). Queries exceeding this limit are rejected by the query-frontend. Requires -query-frontend.query-cost-estimation-enabled and -querier.cardinality-analysis-enabled. 0 to disable.")
+	f.IntVar(&l.QueryCostDeprioritizationThreshold, "query-frontend.query-cost-deprioritization-threshold", 0, "Estimated query cost above which queries are enqueued with a lower priority than the other queries of the tenant in the query-scheduler. Requires -query-frontend.query-cost-estimation-enabled and -querier.cardinality-analysis-enabled. 0 to disable.")
+	f.Var(&l.QueryPriorityClassesAllowed, "query-frontend.query-priority-classes-allowed", fmt.Sprintf("Comma-separated list of query priority classes the tenant's clients are allowed to set on their queries via the %s header. The header is ignored if the priority class is not allowed. Supported priority classes: %s.", api.QueryPriorityClassHeader, strings.Join(api.QueryPriorityClasses, ", ")))
 	f.BoolVar(&l.AlignQueriesWithStep, alignQueriesWithStepFlag, false, "Mutate incoming queries to align their start and end with their step to improve result caching.")
 
 	// Store-gateway.
@@ -337,6 +374,7 @@ func (l *Limits) RegisterFlags(f *flag.FlagSet) {
 	f.IntVar(&l.AlertmanagerMaxDispatcherAggregationGroups, "alertmanager.max-dispatcher-aggregation-groups", 0, "Maximum number of aggregation groups in Alertmanager's dispatcher that a tenant can have. Each active aggregation group uses single goroutine. When the limit is reached, dispatcher will not dispatch alerts that belong to additional aggregation groups, but existing groups will keep working properly. 0 = no limit.")
 	f.IntVar(&l.AlertmanagerMaxAlertsCount, "alertmanager.max-alerts-count", 0, "Maximum number of alerts that a single tenant can have. Inserting more alerts will fail with a log message and metric increment. 0 = no limit.")
 	f.IntVar(&l.AlertmanagerMaxAlertsSizeBytes, "alertmanager.max-alerts-size-bytes", 0, "Maximum total size of alerts that a single tenant can have, alert size is the sum of the bytes of its labels, annotations and generatorURL. Inserting more alerts will fail with a log message and metric increment. 0 = no limit.")
+	f.IntVar(&l.AlertmanagerMaxNotificationHistoryEntries, "alertmanager.max-notification-history-entries", 0, "Maximum number of entries of the notification history of a single tenant, which records every attempt to send a notification. The history is replicated across the Alertmanager replicas of the tenant. 0 to disable the notification history.")
 
 	// Ingest storage.
 	f.StringVar(&l.IngestStorageReadConsistency, "ingest-storage.read-consistency", api.ReadConsistencyEventual, fmt.Sprintf("The default consistency level to enforce to queries when using the ingest storage. Supports values: %s.", strings.Join(api.ReadConsistencies, ", ")))
@@ -411,6 +449,23 @@ func (l *Limits) validate() error {
 		return errInvalidIngestStorageReadConsistency
 	}
 
+	for _, rule := range l.CompactorRetentionRules {
+		if err := rule.validate(); err != nil {
+			return err
+		}
+	}
+
+	for _, class := range l.QueryPriorityClassesAllowed {
+		if !api.IsValidQueryPriorityClass(class) {
+			return errInvalidQueryPriorityClass
+		}
+	}
+
+	// The query cost is estimated issuing cardinality requests.
+	if (l.MaxQueryCost > 0 || l.QueryCostDeprioritizationThreshold > 0) && !l.CardinalityAnalysisEnabled {
+		return errQueryCostRequiresCardinalityAnalysis
+	}
+
 	return nil
 }
 
@@ -600,6 +655,11 @@ func (o *Overrides) MaxFetchedChunkBytesPerQuery(userID string) int {
 	return o.getOverridesForUser(userID).MaxFetchedChunkBytesPerQuery
 }
 
+// MaxExportBytes returns the maximum size in bytes of the response of a single export request.
+func (o *Overrides) MaxExportBytes(userID string) int {
+	return o.getOverridesForUser(userID).MaxExportBytes
+}
+
 // MaxQueryLookback returns the max lookback period of queries.
 func (o *Overrides) MaxQueryLookback(userID string) time.Duration {
 	return time.Duration(o.getOverridesForUser(userID).MaxQueryLookback)
@@ -620,6 +680,21 @@ func (o *Overrides) MaxQueryExpressionSizeBytes(userID string) int {
 	return o.getOverridesForUser(userID).MaxQueryExpressionSizeBytes
 }
 
+// MaxQueryCost returns the max estimated cost of a query.
+func (o *Overrides) MaxQueryCost(userID string) int {
+	return o.getOverridesForUser(userID).MaxQueryCost
+}
+
+// QueryCostDeprioritizationThreshold returns the estimated query cost above which queries are deprioritized.
+func (o *Overrides) QueryCostDeprioritizationThreshold(userID string) int {
+	return o.getOverridesForUser(userID).QueryCostDeprioritizationThreshold
+}
+
+// QueryPriorityClassesAllowed returns the query priority classes the tenant's clients are allowed to set on their queries.
+func (o *Overrides) QueryPriorityClassesAllowed(userID string) []string {
+	return o.getOverridesForUser(userID).QueryPriorityClassesAllowed
+}
+
 // BlockedQueries returns the blocked queries.
 func (o *Overrides) BlockedQueries(userID string) []*BlockedQuery {
 	return o.getOverridesForUser(userID).BlockedQueries
@@ -781,6 +856,26 @@ func (o *Overrides) CompactorBlockUploadMaxBlockSizeBytes(userID string) int64 {
 	return o.getOverridesForUser(userID).CompactorBlockUploadMaxBlockSizeBytes
 }
 
+// CompactorDownsample5mAfter returns the age after which blocks are downsampled to 5 minutes for a given user.
+func (o *Overrides) CompactorDownsample5mAfter(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).CompactorDownsample5mAfter)
+}
+
+// CompactorDownsample1hAfter returns the age after which blocks are downsampled to 1 hour for a given user.
+func (o *Overrides) CompactorDownsample1hAfter(userID string) time.Duration {
+	return time.Duration(o.getOverridesForUser(userID).CompactorDownsample1hAfter)
+}
+
+// CompactorRetentionRules returns the retention rules of the series matching a selector for a given user.
+func (o *Overrides) CompactorRetentionRules(userID string) []*RetentionRule {
+	return o.getOverridesForUser(userID).CompactorRetentionRules
+}
+
+// CompactorSeriesDeletionEnabled returns whether the series deletion API is enabled for a certain tenant.
+func (o *Overrides) CompactorSeriesDeletionEnabled(tenantID string) bool {
+	return o.getOverridesForUser(tenantID).CompactorSeriesDeletionEnabled
+}
+
 // MetricRelabelConfigs returns the metric relabel configs for a given user.
 func (o *Overrides) MetricRelabelConfigs(userID string) []*relabel.Config {
 	return o.getOverridesForUser(userID).MetricRelabelConfigs
@@ -825,6 +920,11 @@ func (o *Overrides) RulerSyncRulesOnChangesEnabled(userID string) bool {
 	return o.getOverridesForUser(userID).RulerSyncRulesOnChangesEnabled
 }
 
+// RulerMaxIndependentRuleEvaluationConcurrencyPerTenant returns the maximum number of rules evaluated concurrently for a given user.
+func (o *Overrides) RulerMaxIndependentRuleEvaluationConcurrencyPerTenant(userID string) int {
+	return o.getOverridesForUser(userID).RulerMaxIndependentRuleEvaluationConcurrencyPerTenant
+}
+
 // StoreGatewayTenantShardSize returns the store-gateway shard size for a given user.
 func (o *Overrides) StoreGatewayTenantShardSize(userID string) int {
 	return o.getOverridesForUser(userID).StoreGatewayTenantShardSize
@@ -835,6 +935,11 @@ func (o *Overrides) MaxHAClusters(user string) int {
 	return o.getOverridesForUser(user).HAMaxClusters
 }
 
+// ReplayBufferMaxBytesPerTenant returns the maximum size of the write requests queued in the distributor replay buffer for a user.
+func (o *Overrides) ReplayBufferMaxBytesPerTenant(user string) int {
+	return o.getOverridesForUser(user).ReplayBufferMaxBytesPerTenant
+}
+
 // S3SSEType returns the per-tenant S3 SSE type.
 func (o *Overrides) S3SSEType(user string) string {
 	return o.getOverridesForUser(user).S3SSEType
@@ -934,6 +1039,10 @@ func (o *Overrides) AlertmanagerMaxAlertsSizeBytes(userID string) int {
 	return o.getOverridesForUser(userID).AlertmanagerMaxAlertsSizeBytes
 }
 
+func (o *Overrides) AlertmanagerMaxNotificationHistoryEntries(userID string) int {
+	return o.getOverridesForUser(userID).AlertmanagerMaxNotificationHistoryEntries
+}
+
 func (o *Overrides) ResultsCacheTTL(user string) time.Duration {
 	return time.Duration(o.getOverridesForUser(user).ResultsCacheTTL)
 }
@@ -946,6 +1055,10 @@ func (o *Overrides) ResultsCacheTTLForCardinalityQuery(user string) time.Duratio
 	return time.Duration(o.getOverridesForUser(user).ResultsCacheTTLForCardinalityQuery)
 }
 
+func (o *Overrides) ResultsCacheInstantQueryAlignment(user string) time.Duration {
+	return time.Duration(o.getOverridesForUser(user).ResultsCacheInstantQueryAlignment)
+}
+
 func (o *Overrides) ResultsCacheTTLForLabelsQuery(user string) time.Duration {
 	return time.Duration(o.getOverridesForUser(user).ResultsCacheTTLForLabelsQuery)
 }
@@ -958,6 +1071,18 @@ func (o *Overrides) OTelMetricSuffixesEnabled(tenantID string) bool {
 	return o.getOverridesForUser(tenantID).OTelMetricSuffixesEnabled
 }
 
+func (o *Overrides) CreatedTimestampZeroIngestionEnabled(tenantID string) bool {
+	return o.getOverridesForUser(tenantID).CreatedTimestampZeroIngestionEnabled
+}
+
+func (o *Overrides) InfluxMetricNameSeparator(tenantID string) string {
+	return o.getOverridesForUser(tenantID).InfluxMetricNameSeparator
+}
+
+func (o *Overrides) InfluxValueFieldName(tenantID string) string {
+	return o.getOverridesForUser(tenantID).InfluxValueFieldName
+}
+
 func (o *Overrides) AlignQueriesWithStep(userID string) bool {
 	return o.getOverridesForUser(userID).AlignQueriesWithStep
 }
@@ -650,6 +650,29 @@ metric_relabel_configs:
 			cfg:         `max_estimated_fetched_chunks_per_query_multiplier: -0.1`,
 			expectedErr: errInvalidMaxEstimatedChunksPerQueryMultiplier.Error(),
 		},
+		"should fail on max_query_cost without cardinality analysis": {
+			cfg:         `max_query_cost: 1000`,
+			expectedErr: errQueryCostRequiresCardinalityAnalysis.Error(),
+		},
+		"should fail on query_cost_deprioritization_threshold without cardinality analysis": {
+			cfg:         `query_cost_deprioritization_threshold: 1000`,
+			expectedErr: errQueryCostRequiresCardinalityAnalysis.Error(),
+		},
+		"should pass on max_query_cost with cardinality analysis": {
+			cfg: `
+max_query_cost: 1000
+cardinality_analysis_enabled: true
+`,
+			expectedErr: "",
+		},
+		"should pass on valid query_priority_classes_allowed": {
+			cfg:         `query_priority_classes_allowed: dashboard,ad-hoc,export`,
+			expectedErr: "",
+		},
+		"should fail on invalid query_priority_classes_allowed": {
+			cfg:         `query_priority_classes_allowed: dashboard,invalid`,
+			expectedErr: errInvalidQueryPriorityClass.Error(),
+		},
 		"should pass on max_estimated_fetched_chunks_per_query_multiplier = 0": {
 			cfg:         `max_estimated_fetched_chunks_per_query_multiplier: 0`,
 			expectedErr: "",
@@ -670,6 +693,29 @@ metric_relabel_configs:
 			cfg:         `ingest_storage_read_consistency: xyz`,
 			expectedErr: errInvalidIngestStorageReadConsistency.Error(),
 		},
+		"should pass on valid compactor_retention_rules": {
+			cfg: `
+compactor_retention_rules:
+  - selector: '{env="dev"}'
+    period: 14d
+`,
+			expectedErr: "",
+		},
+		"should fail on compactor_retention_rules with an invalid selector": {
+			cfg: `
+compactor_retention_rules:
+  - selector: '{env="dev"'
+    period: 14d
+`,
+			expectedErr: errInvalidRetentionRule.Error(),
+		},
+		"should fail on compactor_retention_rules without period": {
+			cfg: `
+compactor_retention_rules:
+  - selector: '{env="dev"}'
+`,
+			expectedErr: errInvalidRetentionRule.Error(),
+		},
 	}
 
 	for testName, testData := range tests {
@@ -695,6 +741,10 @@ func TestUnmarshalJSON_ShouldValidateConfig(t *testing.T) {
 			cfg:         `{"metric_relabel_configs": [null]}`,
 			expectedErr: "invalid metric_relabel_configs",
 		},
+		"should fail on invalid compactor_retention_rules": {
+			cfg:         `{"compactor_retention_rules": [null]}`,
+			expectedErr: errInvalidRetentionRule.Error(),
+		},
 	}
 
 	for testName, testData := range tests {
@@ -0,0 +1,29 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package validation
+
+import (
+	"fmt"
+
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+// RetentionRule is the retention period of the series matching a selector.
+type RetentionRule struct {
+	Selector string         `yaml:"selector" json:"selector"`
+	Period   model.Duration `yaml:"period" json:"period"`
+}
+
+func (r *RetentionRule) validate() error {
+	if r == nil {
+		return errInvalidRetentionRule
+	}
+	if _, err := parser.ParseMetricSelector(r.Selector); err != nil {
+		return fmt.Errorf("%w: invalid selector %q: %s", errInvalidRetentionRule, r.Selector, err)
+	}
+	if r.Period <= 0 {
+		return fmt.Errorf("%w: the period of selector %q must be greater than 0", errInvalidRetentionRule, r.Selector)
+	}
+	return nil
+}
@@ -39,6 +39,7 @@ type ProxyConfig struct {
 	PassThroughNonRegisteredRoutes bool
 	SkipRecentSamples              time.Duration
 	BackendSkipTLSVerify           bool
+	RecordFile                     string
 }
 
 func (cfg *ProxyConfig) RegisterFlags(f *flag.FlagSet) {
@@ -55,6 +56,7 @@ func (cfg *ProxyConfig) RegisterFlags(f *flag.FlagSet) {
 	f.BoolVar(&cfg.UseRelativeError, "proxy.compare-use-relative-error", false, "Use relative error tolerance when comparing floating point values.")
 	f.DurationVar(&cfg.SkipRecentSamples, "proxy.compare-skip-recent-samples", 2*time.Minute, "The window from now to skip comparing samples. 0 to disable.")
 	f.BoolVar(&cfg.PassThroughNonRegisteredRoutes, "proxy.passthrough-non-registered-routes", false, "Passthrough requests for non-registered routes to preferred backend.")
+	f.StringVar(&cfg.RecordFile, "proxy.record-file", "", "Path of a file where the requests received for the registered routes are appended, to replay them later with -replay.file. Credentials are not recorded. Empty to disable recording.")
 }
 
 type Route struct {
@@ -96,6 +98,28 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, routes []Route, registerer pro
 		routes:     routes,
 	}
 
+	var err error
+	p.backends, err = newProxyBackends(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	if cfg.CompareResponses && len(p.backends) != 2 {
+		return nil, fmt.Errorf("when enabling comparison of results number of backends should be 2 exactly")
+	}
+
+	// At least 2 backends are suggested
+	if len(p.backends) < 2 {
+		level.Warn(p.logger).Log("msg", "The proxy is running with only 1 backend. At least 2 backends are required to fulfil the purpose of the proxy and compare results.")
+	}
+
+	return p, nil
+}
+
+// newProxyBackends parses the configured backend endpoints.
+func newProxyBackends(cfg ProxyConfig) ([]*ProxyBackend, error) {
+	var backends []*ProxyBackend
+
 	// Parse the backend endpoints (comma separated).
 	parts := strings.Split(cfg.BackendEndpoints, ",")
 
@@ -122,18 +146,18 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, routes []Route, registerer pro
 			preferred = preferredIdx == idx
 		}
 
-		p.backends = append(p.backends, NewProxyBackend(name, u, cfg.BackendReadTimeout, preferred, cfg.BackendSkipTLSVerify))
+		backends = append(backends, NewProxyBackend(name, u, cfg.BackendReadTimeout, preferred, cfg.BackendSkipTLSVerify))
 	}
 
 	// At least 1 backend is required
-	if len(p.backends) < 1 {
+	if len(backends) < 1 {
 		return nil, errMinBackends
 	}
 
 	// If the preferred backend is configured, then it must exist among the actual backends.
 	if cfg.PreferredBackend != "" {
 		exists := false
-		for _, b := range p.backends {
+		for _, b := range backends {
 			if b.preferred {
 				exists = true
 				break
@@ -145,19 +169,18 @@ func NewProxy(cfg ProxyConfig, logger log.Logger, routes []Route, registerer pro
 		}
 	}
 
-	if cfg.CompareResponses && len(p.backends) != 2 {
-		return nil, fmt.Errorf("when enabling comparison of results number of backends should be 2 exactly")
-	}
-
-	// At least 2 backends are suggested
-	if len(p.backends) < 2 {
-		level.Warn(p.logger).Log("msg", "The proxy is running with only 1 backend. At least 2 backends are required to fulfil the purpose of the proxy and compare results.")
-	}
-
-	return p, nil
+	return backends, nil
 }
 
 func (p *Proxy) Start() error {
+	var recorder *QueryRecorder
+	if p.cfg.RecordFile != "" {
+		var err error
+		if recorder, err = NewQueryRecorder(p.cfg.RecordFile); err != nil {
+			return err
+		}
+	}
+
 	// Setup server first, so we can fail early if the ports are in use.
 	serv, err := server.New(server.Config{
 		// HTTP configs
@@ -188,6 +211,9 @@ func (p *Proxy) Start() error {
 		Log: p.logger,
 	})
 	if err != nil {
+		if recorder != nil {
+			_ = recorder.Close()
+		}
 		return err
 	}
 
@@ -204,7 +230,7 @@ func (p *Proxy) Start() error {
 		if p.cfg.CompareResponses {
 			comparator = route.ResponseComparator
 		}
-		router.Path(route.Path).Methods(route.Methods...).Handler(NewProxyEndpoint(p.backends, route.RouteName, p.metrics, p.logger, comparator))
+		router.Path(route.Path).Methods(route.Methods...).Handler(NewProxyEndpoint(p.backends, route.RouteName, p.metrics, p.logger, comparator, recorder))
 	}
 
 	if p.cfg.PassThroughNonRegisteredRoutes {
@@ -226,6 +252,12 @@ func (p *Proxy) Start() error {
 		if err := p.server.Run(); err != nil {
 			level.Error(p.logger).Log("msg", "Proxy server failed", "err", err)
 		}
+
+		if recorder != nil {
+			if err := recorder.Close(); err != nil {
+				level.Warn(p.logger).Log("msg", "Unable to close the record file", "err", err)
+			}
+		}
 	}()
 
 	level.Info(p.logger).Log("msg", "The proxy is up and running.", "httpPort", p.cfg.ServerHTTPServicePort, "grpcPort", p.cfg.ServerGRPCServicePort)
@@ -28,6 +28,9 @@ type ProxyEndpoint struct {
 	logger     log.Logger
 	comparator ResponsesComparator
 
+	// Optional recorder of the received requests.
+	recorder *QueryRecorder
+
 	// Whether for this endpoint there's a preferred backend configured.
 	hasPreferredBackend bool
 
@@ -35,7 +38,7 @@ type ProxyEndpoint struct {
 	routeName string
 }
 
-func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *ProxyMetrics, logger log.Logger, comparator ResponsesComparator) *ProxyEndpoint {
+func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *ProxyMetrics, logger log.Logger, comparator ResponsesComparator, recorder *QueryRecorder) *ProxyEndpoint {
 	hasPreferredBackend := false
 	for _, backend := range backends {
 		if backend.preferred {
@@ -50,6 +53,7 @@ func NewProxyEndpoint(backends []*ProxyBackend, routeName string, metrics *Proxy
 		metrics:             metrics,
 		logger:              logger,
 		comparator:          comparator,
+		recorder:            recorder,
 		hasPreferredBackend: hasPreferredBackend,
 	}
 }
@@ -104,6 +108,12 @@ func (p *ProxyEndpoint) executeBackendRequests(req *http.Request, resCh chan *ba
 
 	level.Debug(p.logger).Log("msg", "Received request", "path", req.URL.Path, "query", query)
 
+	if p.recorder != nil {
+		if err := p.recorder.Record(req, p.routeName, query); err != nil {
+			level.Warn(p.logger).Log("msg", "Unable to record request", "err", err)
+		}
+	}
+
 	wg.Add(len(p.backends))
 	for _, b := range p.backends {
 		b := b
@@ -166,7 +176,7 @@ func (p *ProxyEndpoint) executeBackendRequests(req *http.Request, resCh chan *ba
 			expectedResponse, actualResponse = actualResponse, expectedResponse
 		}
 
-		result, err := p.compareResponses(expectedResponse, actualResponse)
+		result, err := compareResponses(p.comparator, expectedResponse, actualResponse)
 		if result == ComparisonFailed {
 			level.Error(p.logger).Log(
 				"msg", "response comparison failed",
@@ -224,7 +234,7 @@ func (p *ProxyEndpoint) waitBackendResponseForDownstream(resCh chan *backendResp
 	return responses[0]
 }
 
-func (p *ProxyEndpoint) compareResponses(expectedResponse, actualResponse *backendResponse) (ComparisonResult, error) {
+func compareResponses(comparator ResponsesComparator, expectedResponse, actualResponse *backendResponse) (ComparisonResult, error) {
 	if expectedResponse.err != nil {
 		return ComparisonFailed, fmt.Errorf("skipped comparison of response because the request to the preferred backend failed: %w", expectedResponse.err)
 	}
@@ -245,7 +255,7 @@ func (p *ProxyEndpoint) compareResponses(expectedResponse, actualResponse *backe
 		return ComparisonSkipped, fmt.Errorf("skipped comparison of response because the response from the secondary backend contained an unexpected content type '%s', expected 'application/json'", actualResponse.contentType)
 	}
 
-	return p.comparator.Compare(expectedResponse.body, actualResponse.body)
+	return comparator.Compare(expectedResponse.body, actualResponse.body)
 }
 
 type backendResponse struct {
@@ -0,0 +1,107 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// RecordedQuery is a request received by the proxy, as stored in the record file.
+type RecordedQuery struct {
+	Time      time.Time `json:"time"`
+	RouteName string    `json:"route"`
+	Method    string    `json:"method"`
+	Path      string    `json:"path"`
+	// Query holds the URL-encoded request parameters, from both the URL query and the form body.
+	Query string `json:"query"`
+	User  string `json:"user,omitempty"`
+}
+
+// QueryRecorder appends the requests received by the proxy to a file, one JSON-encoded RecordedQuery per line.
+// The requests credentials are never recorded.
+type QueryRecorder struct {
+	mtx     sync.Mutex
+	file    *os.File
+	writer  *bufio.Writer
+	encoder *json.Encoder
+}
+
+func NewQueryRecorder(path string) (*QueryRecorder, error) {
+	file, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening record file")
+	}
+
+	writer := bufio.NewWriter(file)
+	return &QueryRecorder{
+		file:    file,
+		writer:  writer,
+		encoder: json.NewEncoder(writer),
+	}, nil
+}
+
+// Record appends the input request to the record file. The query must contain the already parsed request parameters.
+func (r *QueryRecorder) Record(req *http.Request, routeName, query string) error {
+	entry := RecordedQuery{
+		Time:      time.Now().UTC(),
+		RouteName: routeName,
+		Method:    req.Method,
+		Path:      req.URL.Path,
+		Query:     query,
+		User:      req.Header.Get("X-Scope-OrgID"),
+	}
+
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := r.encoder.Encode(entry); err != nil {
+		return err
+	}
+
+	// Flush on every request, so that the record file can be consumed while the proxy is running.
+	return r.writer.Flush()
+}
+
+func (r *QueryRecorder) Close() error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if err := r.writer.Flush(); err != nil {
+		_ = r.file.Close()
+		return err
+	}
+	return r.file.Close()
+}
+
+// ReadRecordedQueries reads all requests stored in the input record file.
+func ReadRecordedQueries(path string) ([]RecordedQuery, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, errors.Wrap(err, "opening record file")
+	}
+	defer file.Close()
+
+	var (
+		entries []RecordedQuery
+		decoder = json.NewDecoder(bufio.NewReader(file))
+	)
+
+	for {
+		var entry RecordedQuery
+		if err := decoder.Decode(&entry); errors.Is(err, io.EOF) {
+			return entries, nil
+		} else if err != nil {
+			return nil, errors.Wrapf(err, "decoding record file entry %d", len(entries)+1)
+		}
+
+		entries = append(entries, entry)
+	}
+}
@@ -0,0 +1,304 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/go-kit/log/level"
+	"github.com/grafana/dskit/flagext"
+	"github.com/pkg/errors"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/prometheus/promql/parser"
+	"golang.org/x/time/rate"
+)
+
+const (
+	ReplayReportFormatJSON = "json"
+	ReplayReportFormatHTML = "html"
+)
+
+var (
+	replayReportFormats = []string{ReplayReportFormatJSON, ReplayReportFormatHTML}
+
+	errReplayReportFileMissing  = errors.New("the replay report file must be set when replaying recorded requests")
+	errReplayInvalidRate        = errors.New("the replay rate must be greater than 0")
+	errReplayInvalidConcurrency = errors.New("the replay concurrency must be greater than 0")
+	errReplayBackends           = errors.New("replaying recorded requests requires exactly 2 backends and the -backend.preferred flag to be set")
+)
+
+type ReplayConfig struct {
+	File                    string
+	Rate                    float64
+	Concurrency             int
+	ReportFile              string
+	ReportFormat            string
+	NoisyFunctions          flagext.StringSliceCSV
+	NoisyFunctionsTolerance float64
+}
+
+func (cfg *ReplayConfig) RegisterFlags(f *flag.FlagSet) {
+	cfg.NoisyFunctions = []string{"rate", "irate", "increase"}
+
+	f.StringVar(&cfg.File, "replay.file", "", "Path of a file of requests recorded with -proxy.record-file. When set, the query-tee replays the recorded requests against the 2 configured backends, writes a report of the responses comparison and exits, instead of running the proxy.")
+	f.Float64Var(&cfg.Rate, "replay.rate", 10, "Maximum number of recorded requests replayed per second.")
+	f.IntVar(&cfg.Concurrency, "replay.concurrency", 10, "Maximum number of recorded requests replayed concurrently.")
+	f.StringVar(&cfg.ReportFile, "replay.report-file", "", "Path of the file where the report of the replayed requests is written.")
+	f.StringVar(&cfg.ReportFormat, "replay.report-format", ReplayReportFormatJSON, fmt.Sprintf("Format of the report of the replayed requests. Supported values: %v.", replayReportFormats))
+	f.Var(&cfg.NoisyFunctions, "replay.noisy-functions", "Comma separated list of PromQL functions whose results are known to be noisy at the boundaries of the range. The samples at the boundaries of the results of queries using any of these functions are compared with -replay.noisy-functions-tolerance.")
+	f.Float64Var(&cfg.NoisyFunctionsTolerance, "replay.noisy-functions-tolerance", 0.01, "The relative tolerance to apply when comparing the first and last sample of each series, and the samples of instant queries, in the results of queries using any of the -replay.noisy-functions. 0 to disable.")
+}
+
+func (cfg *ReplayConfig) Validate() error {
+	if cfg.File == "" {
+		return nil
+	}
+
+	if cfg.ReportFile == "" {
+		return errReplayReportFileMissing
+	}
+	if cfg.ReportFormat != ReplayReportFormatJSON && cfg.ReportFormat != ReplayReportFormatHTML {
+		return fmt.Errorf("unsupported replay report format %q, supported values: %v", cfg.ReportFormat, replayReportFormats)
+	}
+	if cfg.Rate <= 0 {
+		return errReplayInvalidRate
+	}
+	if cfg.Concurrency <= 0 {
+		return errReplayInvalidConcurrency
+	}
+	return nil
+}
+
+// Replayer sends the requests recorded by the proxy to the preferred and the secondary backend,
+// and compares their responses.
+type Replayer struct {
+	cfg       ReplayConfig
+	expected  *ProxyBackend
+	actual    *ProxyBackend
+	routes    map[string]Route
+	noisyFunc map[string]struct{}
+	metrics   *ProxyMetrics
+	logger    log.Logger
+}
+
+func NewReplayer(cfg ReplayConfig, proxyCfg ProxyConfig, routes []Route, logger log.Logger, registerer prometheus.Registerer) (*Replayer, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+
+	backends, err := newProxyBackends(proxyCfg)
+	if err != nil {
+		return nil, err
+	}
+	if len(backends) != 2 || proxyCfg.PreferredBackend == "" {
+		return nil, errReplayBackends
+	}
+
+	r := &Replayer{
+		cfg:       cfg,
+		expected:  backends[0],
+		actual:    backends[1],
+		routes:    make(map[string]Route, len(routes)),
+		noisyFunc: make(map[string]struct{}, len(cfg.NoisyFunctions)),
+		metrics:   NewProxyMetrics(registerer),
+		logger:    logger,
+	}
+	if r.actual.preferred {
+		r.expected, r.actual = r.actual, r.expected
+	}
+
+	for _, route := range routes {
+		r.routes[route.RouteName] = route
+	}
+	for _, name := range cfg.NoisyFunctions {
+		r.noisyFunc[name] = struct{}{}
+	}
+
+	return r, nil
+}
+
+// Run replays all recorded requests at the configured rate, and returns the report of the comparison of the responses.
+func (r *Replayer) Run(ctx context.Context) (*ReplayReport, error) {
+	entries, err := ReadRecordedQueries(r.cfg.File)
+	if err != nil {
+		return nil, err
+	}
+
+	level.Info(r.logger).Log("msg", "Replaying recorded requests", "requests", len(entries), "expected", r.expected.name, "actual", r.actual.name)
+
+	var (
+		report  = newReplayReport(r.expected.name, r.actual.name)
+		limiter = rate.NewLimiter(rate.Limit(r.cfg.Rate), 1)
+		sem     = make(chan struct{}, r.cfg.Concurrency)
+		wg      = sync.WaitGroup{}
+	)
+
+	for _, entry := range entries {
+		if err := limiter.Wait(ctx); err != nil {
+			wg.Wait()
+			return nil, err
+		}
+
+		sem <- struct{}{}
+		wg.Add(1)
+
+		go func(entry RecordedQuery) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			r.replay(entry, report)
+		}(entry)
+	}
+
+	wg.Wait()
+	report.finish()
+
+	level.Info(r.logger).Log("msg", "Replay completed", "requests", report.Total, "success", report.Success, "failed", report.Failed, "skipped", report.Skipped)
+	return report, nil
+}
+
+func (r *Replayer) replay(entry RecordedQuery, report *ReplayReport) {
+	route, ok := r.routes[entry.RouteName]
+	if !ok {
+		level.Warn(r.logger).Log("msg", "Skipped replay of recorded request for unknown route", "route-name", entry.RouteName, "path", entry.Path)
+		report.add(entry, "", ComparisonSkipped, nil, nil)
+		return
+	}
+
+	params, err := url.ParseQuery(entry.Query)
+	if err != nil {
+		level.Warn(r.logger).Log("msg", "Skipped replay of recorded request with invalid query", "route-name", entry.RouteName, "err", err)
+		report.add(entry, "", ComparisonSkipped, nil, nil)
+		return
+	}
+
+	var (
+		query      = params.Get("query")
+		comparator = route.ResponseComparator
+		opts       *SampleComparisonOptions
+	)
+
+	// Relax the comparison of the samples at the boundaries of the results of queries using noisy functions.
+	if samplesComparator, ok := comparator.(*SamplesComparator); ok {
+		samplesOpts := samplesComparator.opts
+		if r.cfg.NoisyFunctionsTolerance > 0 && r.usesNoisyFunctions(query) {
+			samplesOpts.BoundaryTolerance = r.cfg.NoisyFunctionsTolerance
+		}
+
+		comparator = samplesComparator.WithOptions(samplesOpts)
+		opts = &samplesOpts
+	} else if comparator == nil {
+		comparator = statusCodeComparator{}
+	}
+
+	var (
+		expected, actual *backendResponse
+		wg               sync.WaitGroup
+	)
+
+	wg.Add(2)
+	go func() {
+		defer wg.Done()
+		expected = r.replayTo(r.expected, entry)
+	}()
+	go func() {
+		defer wg.Done()
+		actual = r.replayTo(r.actual, entry)
+	}()
+	wg.Wait()
+
+	result, err := compareResponses(comparator, expected, actual)
+	r.metrics.responsesComparedTotal.WithLabelValues(entry.RouteName, string(result)).Inc()
+
+	if result != ComparisonFailed {
+		report.add(entry, queryPattern(query), result, nil, nil)
+		return
+	}
+
+	level.Debug(r.logger).Log("msg", "response comparison failed", "route-name", entry.RouteName, "query", entry.Query, "user", entry.User, "err", err)
+
+	var diffs []SampleDiff
+	if opts != nil && expected.err == nil && actual.err == nil {
+		diffs = collectSampleDiffs(expected.body, actual.body, *opts)
+	}
+	report.add(entry, queryPattern(query), result, err, diffs)
+}
+
+func (r *Replayer) replayTo(backend *ProxyBackend, entry RecordedQuery) *backendResponse {
+	var (
+		body  io.ReadCloser
+		start = time.Now()
+		res   = &backendResponse{backend: backend}
+	)
+
+	req, err := http.NewRequest(entry.Method, entry.Path, nil)
+	if err != nil {
+		res.err = err
+		return res
+	}
+
+	if entry.Method == http.MethodGet {
+		req.URL.RawQuery = entry.Query
+	} else {
+		body = io.NopCloser(bytes.NewReader([]byte(entry.Query)))
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if entry.User != "" {
+		req.Header.Set("X-Scope-OrgID", entry.User)
+	}
+
+	status, resBody, resp, err := backend.ForwardRequest(req, body)
+	res.status = status
+	res.body = resBody
+	res.err = err
+	if resp != nil {
+		res.contentType = resp.Header.Get("Content-Type")
+	}
+
+	r.metrics.requestDuration.WithLabelValues(backend.name, entry.Method, entry.RouteName, strconv.Itoa(res.statusCode())).Observe(time.Since(start).Seconds())
+	return res
+}
+
+// usesNoisyFunctions returns whether the input PromQL query calls any of the configured noisy functions.
+func (r *Replayer) usesNoisyFunctions(query string) bool {
+	if query == "" || len(r.noisyFunc) == 0 {
+		return false
+	}
+
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return false
+	}
+
+	found := false
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		if call, ok := node.(*parser.Call); ok {
+			if _, noisy := r.noisyFunc[call.Func.Name]; noisy {
+				found = true
+			}
+		}
+		return nil
+	})
+
+	return found
+}
+
+// statusCodeComparator is used to compare the responses of the routes not supporting results comparison,
+// which are then compared by status code only.
+type statusCodeComparator struct{}
+
+func (statusCodeComparator) Compare(_, _ []byte) (ComparisonResult, error) {
+	return ComparisonSuccess, nil
+}
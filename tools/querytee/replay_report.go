@@ -0,0 +1,384 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"encoding/json"
+	"html/template"
+	"io"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/model/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const (
+	// Max number of mismatching requests kept in the report for each query pattern.
+	maxMismatchesPerGroup = 10
+
+	// Max number of sample diffs kept in the report for each mismatching request.
+	maxSampleDiffsPerMismatch = 50
+)
+
+// ReplayReport is the report of the comparison of the responses to the replayed requests.
+type ReplayReport struct {
+	Started         time.Time        `json:"started"`
+	Finished        time.Time        `json:"finished"`
+	ExpectedBackend string           `json:"expected_backend"`
+	ActualBackend   string           `json:"actual_backend"`
+	Total           int              `json:"total"`
+	Success         int              `json:"success"`
+	Failed          int              `json:"failed"`
+	Skipped         int              `json:"skipped"`
+	Groups          []*MismatchGroup `json:"mismatch_groups"`
+
+	mtx    sync.Mutex
+	groups map[string]*MismatchGroup
+}
+
+// MismatchGroup groups the mismatching requests by route and query pattern.
+type MismatchGroup struct {
+	RouteName string `json:"route"`
+	// Pattern is the PromQL query with the label matchers values stripped. Empty for requests without a PromQL query.
+	Pattern    string     `json:"pattern,omitempty"`
+	Count      int        `json:"count"`
+	Mismatches []Mismatch `json:"mismatches"`
+}
+
+type Mismatch struct {
+	Time  time.Time `json:"time"`
+	User  string    `json:"user,omitempty"`
+	Query string    `json:"query"`
+	Error string    `json:"error"`
+	// Diffs contains the samples not matching between the two backends, up to maxSampleDiffsPerMismatch.
+	Diffs []SampleDiff `json:"diffs,omitempty"`
+}
+
+// SampleDiff is a sample not matching between the two backends. The Expected or Actual
+// value is empty if the sample is missing from the respective response.
+type SampleDiff struct {
+	Metric    string     `json:"metric"`
+	Timestamp model.Time `json:"timestamp"`
+	Expected  string     `json:"expected"`
+	Actual    string     `json:"actual"`
+}
+
+func newReplayReport(expectedBackend, actualBackend string) *ReplayReport {
+	return &ReplayReport{
+		Started:         time.Now(),
+		ExpectedBackend: expectedBackend,
+		ActualBackend:   actualBackend,
+		groups:          map[string]*MismatchGroup{},
+	}
+}
+
+func (r *ReplayReport) add(entry RecordedQuery, pattern string, result ComparisonResult, err error, diffs []SampleDiff) {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.Total++
+	switch result {
+	case ComparisonSuccess:
+		r.Success++
+		return
+	case ComparisonSkipped:
+		r.Skipped++
+		return
+	}
+
+	r.Failed++
+
+	key := entry.RouteName + "\x00" + pattern
+	group, ok := r.groups[key]
+	if !ok {
+		group = &MismatchGroup{RouteName: entry.RouteName, Pattern: pattern}
+		r.groups[key] = group
+		r.Groups = append(r.Groups, group)
+	}
+
+	group.Count++
+	if len(group.Mismatches) >= maxMismatchesPerGroup {
+		return
+	}
+
+	mismatch := Mismatch{
+		Time:  entry.Time,
+		User:  entry.User,
+		Query: entry.Query,
+		Diffs: diffs,
+	}
+	if err != nil {
+		mismatch.Error = err.Error()
+	}
+	group.Mismatches = append(group.Mismatches, mismatch)
+}
+
+func (r *ReplayReport) finish() {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	r.Finished = time.Now()
+
+	// Show the most frequent mismatches first.
+	sort.SliceStable(r.Groups, func(i, j int) bool {
+		return r.Groups[i].Count > r.Groups[j].Count
+	})
+}
+
+// WriteFile writes the report to the input path, in the input format.
+func (r *ReplayReport) WriteFile(path, format string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return errors.Wrap(err, "creating report file")
+	}
+
+	if err := r.Write(file, format); err != nil {
+		_ = file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (r *ReplayReport) Write(w io.Writer, format string) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	switch format {
+	case ReplayReportFormatJSON:
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(r)
+	case ReplayReportFormatHTML:
+		return replayReportTemplate.Execute(w, r)
+	default:
+		return errors.Errorf("unsupported replay report format %q", format)
+	}
+}
+
+// queryPattern returns the input PromQL query with the values of the label matchers, except
+// the metric name, replaced by a placeholder, so that similar queries can be grouped together.
+func queryPattern(query string) string {
+	if query == "" {
+		return ""
+	}
+
+	expr, err := parser.ParseExpr(query)
+	if err != nil {
+		return query
+	}
+
+	parser.Inspect(expr, func(node parser.Node, _ []parser.Node) error {
+		if selector, ok := node.(*parser.VectorSelector); ok {
+			for i, m := range selector.LabelMatchers {
+				if m.Name == labels.MetricName {
+					continue
+				}
+
+				// The matcher is only used to print the pattern, so there's no need to compile the regexp.
+				selector.LabelMatchers[i] = &labels.Matcher{Type: m.Type, Name: m.Name, Value: "?"}
+			}
+		}
+		return nil
+	})
+
+	return expr.String()
+}
+
+type diffSeries struct {
+	metric  model.Metric
+	samples []model.SamplePair
+}
+
+// collectSampleDiffs returns the samples not matching between the input query responses.
+// Instant query results are compared as series with a single sample.
+func collectSampleDiffs(expectedBody, actualBody []byte, opts SampleComparisonOptions) []SampleDiff {
+	expected, ok := parseDiffSeries(expectedBody)
+	if !ok {
+		return nil
+	}
+	actual, ok := parseDiffSeries(actualBody)
+	if !ok {
+		return nil
+	}
+
+	var diffs []SampleDiff
+	addDiff := func(metric model.Metric, ts model.Time, expectedValue, actualValue string) bool {
+		diffs = append(diffs, SampleDiff{Metric: metric.String(), Timestamp: ts, Expected: expectedValue, Actual: actualValue})
+		return len(diffs) < maxSampleDiffsPerMismatch
+	}
+
+	actualByFingerprint := make(map[model.Fingerprint]diffSeries, len(actual))
+	for _, s := range actual {
+		actualByFingerprint[s.metric.Fingerprint()] = s
+	}
+
+	for _, e := range expected {
+		fp := e.metric.Fingerprint()
+		a := actualByFingerprint[fp]
+		delete(actualByFingerprint, fp)
+
+		actualSamples := make(map[model.Time]model.SampleValue, len(a.samples))
+		for _, sample := range a.samples {
+			actualSamples[sample.Timestamp] = sample.Value
+		}
+
+		for i, sample := range e.samples {
+			actualValue, found := actualSamples[sample.Timestamp]
+			delete(actualSamples, sample.Timestamp)
+
+			if !found {
+				if !addDiff(e.metric, sample.Timestamp, sample.Value.String(), "") {
+					return diffs
+				}
+				continue
+			}
+
+			if opts.SkipRecentSamples > 0 && time.Since(sample.Timestamp.Time()) < opts.SkipRecentSamples {
+				continue
+			}
+
+			sampleOpts := opts
+			if i == 0 || i == len(e.samples)-1 {
+				sampleOpts = opts.boundaryOptions()
+			}
+			if !compareSampleValue(sample.Value, actualValue, sampleOpts) {
+				if !addDiff(e.metric, sample.Timestamp, sample.Value.String(), actualValue.String()) {
+					return diffs
+				}
+			}
+		}
+
+		// Samples only returned by the actual backend, in order.
+		for _, sample := range a.samples {
+			if _, unexpected := actualSamples[sample.Timestamp]; unexpected {
+				if !addDiff(e.metric, sample.Timestamp, "", sample.Value.String()) {
+					return diffs
+				}
+			}
+		}
+	}
+
+	// Series only returned by the actual backend, in order.
+	for _, a := range actual {
+		if _, unexpected := actualByFingerprint[a.metric.Fingerprint()]; !unexpected {
+			continue
+		}
+
+		for _, sample := range a.samples {
+			if !addDiff(a.metric, sample.Timestamp, "", sample.Value.String()) {
+				return diffs
+			}
+		}
+	}
+
+	return diffs
+}
+
+func parseDiffSeries(body []byte) ([]diffSeries, bool) {
+	var res SamplesResponse
+	if err := json.Unmarshal(body, &res); err != nil {
+		return nil, false
+	}
+
+	switch res.Data.ResultType {
+	case "matrix":
+		var matrix model.Matrix
+		if err := json.Unmarshal(res.Data.Result, &matrix); err != nil {
+			return nil, false
+		}
+
+		series := make([]diffSeries, 0, len(matrix))
+		for _, s := range matrix {
+			series = append(series, diffSeries{metric: s.Metric, samples: s.Values})
+		}
+		return series, true
+	case "vector":
+		var vector model.Vector
+		if err := json.Unmarshal(res.Data.Result, &vector); err != nil {
+			return nil, false
+		}
+
+		series := make([]diffSeries, 0, len(vector))
+		for _, s := range vector {
+			series = append(series, diffSeries{metric: s.Metric, samples: []model.SamplePair{{Timestamp: s.Timestamp, Value: s.Value}}})
+		}
+		return series, true
+	case "scalar":
+		var scalar model.Scalar
+		if err := json.Unmarshal(res.Data.Result, &scalar); err != nil {
+			return nil, false
+		}
+
+		return []diffSeries{{metric: model.Metric{}, samples: []model.SamplePair{{Timestamp: scalar.Timestamp, Value: scalar.Value}}}}, true
+	default:
+		return nil, false
+	}
+}
+
+var replayReportTemplate = template.Must(template.New("report").Parse(`<!DOCTYPE html>
+<html>
+<head>
+	<meta charset="UTF-8">
+	<title>Query-tee replay report</title>
+	<style>
+		body { font-family: sans-serif; }
+		table { border-collapse: collapse; margin-bottom: 1em; }
+		th, td { border: 1px solid #ccc; padding: 2px 6px; text-align: left; vertical-align: top; }
+		code { white-space: pre-wrap; word-break: break-all; }
+		.missing { color: #999; font-style: italic; }
+	</style>
+</head>
+<body>
+	<h1>Query-tee replay report</h1>
+	<table>
+		<tr><th>Started</th><td>{{ .Started.Format "2006-01-02T15:04:05Z07:00" }}</td></tr>
+		<tr><th>Finished</th><td>{{ .Finished.Format "2006-01-02T15:04:05Z07:00" }}</td></tr>
+		<tr><th>Expected backend</th><td>{{ .ExpectedBackend }}</td></tr>
+		<tr><th>Actual backend</th><td>{{ .ActualBackend }}</td></tr>
+		<tr><th>Requests</th><td>{{ .Total }}</td></tr>
+		<tr><th>Success</th><td>{{ .Success }}</td></tr>
+		<tr><th>Failed</th><td>{{ .Failed }}</td></tr>
+		<tr><th>Skipped</th><td>{{ .Skipped }}</td></tr>
+	</table>
+
+	<h2>Mismatches</h2>
+	{{- if not .Groups }}
+	<p>No mismatches.</p>
+	{{- end }}
+	{{- range .Groups }}
+	<h3>{{ .RouteName }}: {{ .Count }} mismatches</h3>
+	{{- if .Pattern }}
+	<p>Pattern: <code>{{ .Pattern }}</code></p>
+	{{- end }}
+	{{- range .Mismatches }}
+	<table>
+		<tr><th>Recorded</th><td>{{ .Time.Format "2006-01-02T15:04:05Z07:00" }}</td></tr>
+		{{- if .User }}
+		<tr><th>Tenant</th><td>{{ .User }}</td></tr>
+		{{- end }}
+		<tr><th>Request</th><td><code>{{ .Query }}</code></td></tr>
+		<tr><th>Error</th><td><code>{{ .Error }}</code></td></tr>
+	</table>
+	{{- if .Diffs }}
+	<table>
+		<tr><th>Series</th><th>Timestamp</th><th>Expected</th><th>Actual</th></tr>
+		{{- range .Diffs }}
+		<tr>
+			<td><code>{{ .Metric }}</code></td>
+			<td>{{ .Timestamp }}</td>
+			<td>{{ if .Expected }}{{ .Expected }}{{ else }}<span class="missing">missing</span>{{ end }}</td>
+			<td>{{ if .Actual }}{{ .Actual }}{{ else }}<span class="missing">missing</span>{{ end }}</td>
+		</tr>
+		{{- end }}
+	</table>
+	{{- end }}
+	{{- end }}
+	{{- end }}
+</body>
+</html>
+`))
@@ -0,0 +1,272 @@
+// SPDX-License-Identifier: AGPL-3.0-only
+
+package querytee
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-kit/log"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProxy_RecordAndReplay(t *testing.T) {
+	const (
+		upMatrix         = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up"},"values":[[1,"1"],[2,"1"],[3,"1"]]}]}}`
+		upMatrixMismatch = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up"},"values":[[1,"1"],[2,"0"],[3,"1"]]}]}}`
+		rateMatrix       = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"test"},"values":[[1,"100"],[2,"100"],[3,"100"]]}]}}`
+		rateMatrixNoisy  = `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"test"},"values":[[1,"100.5"],[2,"100"],[3,"99.5"]]}]}}`
+	)
+
+	newBackend := func(upRes, rateRes string) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			require.NoError(t, r.ParseForm())
+
+			w.Header().Set("Content-Type", "application/json")
+			if strings.HasPrefix(r.Form.Get("query"), "rate(") {
+				_, _ = w.Write([]byte(rateRes))
+				return
+			}
+			_, _ = w.Write([]byte(upRes))
+		}))
+	}
+
+	preferred := newBackend(upMatrix, rateMatrix)
+	defer preferred.Close()
+	secondary := newBackend(upMatrixMismatch, rateMatrixNoisy)
+	defer secondary.Close()
+
+	routes := []Route{
+		{Path: "/api/v1/query_range", RouteName: "api_v1_query_range", Methods: []string{"GET", "POST"}, ResponseComparator: NewSamplesComparator(SampleComparisonOptions{Tolerance: 0.000001})},
+	}
+
+	recordFile := filepath.Join(t.TempDir(), "queries.jsonl")
+	proxyCfg := ProxyConfig{
+		BackendEndpoints:         preferred.URL + "," + secondary.URL,
+		PreferredBackend:         "0",
+		ServerHTTPServiceAddress: "localhost",
+		ServerHTTPServicePort:    0,
+		ServerGRPCServiceAddress: "localhost",
+		ServerGRPCServicePort:    0,
+		BackendReadTimeout:       time.Second,
+		RecordFile:               recordFile,
+	}
+
+	// Record requests sent through the proxy.
+	p, err := NewProxy(proxyCfg, log.NewNopLogger(), routes, prometheus.NewRegistry())
+	require.NoError(t, err)
+	require.NoError(t, p.Start())
+
+	endpoint := fmt.Sprintf("http://%s/api/v1/query_range", p.server.HTTPListenAddr())
+
+	req, err := http.NewRequest(http.MethodGet, endpoint+"?"+url.Values{"query": {`up{job="a"}`}, "start": {"1"}, "end": {"3"}, "step": {"1"}}.Encode(), nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Scope-OrgID", "user-1")
+	sendRequest(t, req)
+
+	req, err = http.NewRequest(http.MethodPost, endpoint, strings.NewReader(url.Values{"query": {`up{job="b"}`}, "start": {"1"}, "end": {"3"}, "step": {"1"}}.Encode()))
+	require.NoError(t, err)
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("X-Scope-OrgID", "user-2")
+	sendRequest(t, req)
+
+	req, err = http.NewRequest(http.MethodGet, endpoint+"?"+url.Values{"query": {`rate(requests_total[5m])`}, "start": {"1"}, "end": {"3"}, "step": {"1"}}.Encode(), nil)
+	require.NoError(t, err)
+	sendRequest(t, req)
+
+	require.NoError(t, p.Stop())
+	p.Await()
+
+	recorded, err := ReadRecordedQueries(recordFile)
+	require.NoError(t, err)
+	require.Len(t, recorded, 3)
+
+	assert.Equal(t, "api_v1_query_range", recorded[0].RouteName)
+	assert.Equal(t, http.MethodGet, recorded[0].Method)
+	assert.Equal(t, "/api/v1/query_range", recorded[0].Path)
+	assert.Equal(t, "user-1", recorded[0].User)
+	assert.Equal(t, http.MethodPost, recorded[1].Method)
+	assert.Equal(t, "user-2", recorded[1].User)
+	assert.Equal(t, url.Values{"query": {`up{job="b"}`}, "start": {"1"}, "end": {"3"}, "step": {"1"}}.Encode(), recorded[1].Query)
+
+	// Replay the recorded requests.
+	reportFile := filepath.Join(t.TempDir(), "report.json")
+	replayCfg := ReplayConfig{
+		File:                    recordFile,
+		Rate:                    100,
+		Concurrency:             2,
+		ReportFile:              reportFile,
+		ReportFormat:            ReplayReportFormatJSON,
+		NoisyFunctions:          []string{"rate"},
+		NoisyFunctionsTolerance: 0.01,
+	}
+
+	replayer, err := NewReplayer(replayCfg, proxyCfg, routes, log.NewNopLogger(), prometheus.NewRegistry())
+	require.NoError(t, err)
+
+	report, err := replayer.Run(context.Background())
+	require.NoError(t, err)
+
+	assert.Equal(t, 3, report.Total)
+	assert.Equal(t, 1, report.Success)
+	assert.Equal(t, 2, report.Failed)
+	assert.Equal(t, 0, report.Skipped)
+
+	// Mismatching queries are grouped by pattern.
+	require.Len(t, report.Groups, 1)
+	assert.Equal(t, "api_v1_query_range", report.Groups[0].RouteName)
+	assert.Equal(t, `up{job="?"}`, report.Groups[0].Pattern)
+	assert.Equal(t, 2, report.Groups[0].Count)
+	require.Len(t, report.Groups[0].Mismatches, 2)
+
+	for _, mismatch := range report.Groups[0].Mismatches {
+		assert.Equal(t, []SampleDiff{{Metric: "up", Timestamp: model.TimeFromUnix(2), Expected: "1", Actual: "0"}}, mismatch.Diffs)
+	}
+
+	for _, format := range []string{ReplayReportFormatJSON, ReplayReportFormatHTML} {
+		buf := bytes.Buffer{}
+		require.NoError(t, report.Write(&buf, format))
+		assert.Contains(t, buf.String(), "up{job=")
+	}
+	require.NoError(t, report.WriteFile(reportFile, ReplayReportFormatHTML))
+}
+
+func sendRequest(t *testing.T, req *http.Request) {
+	res, err := http.DefaultClient.Do(req)
+	require.NoError(t, err)
+	require.NoError(t, res.Body.Close())
+	require.Equal(t, http.StatusOK, res.StatusCode)
+}
+
+func TestReplayConfig_Validate(t *testing.T) {
+	valid := func() ReplayConfig {
+		return ReplayConfig{File: "queries.jsonl", Rate: 1, Concurrency: 1, ReportFile: "report.json", ReportFormat: ReplayReportFormatJSON}
+	}
+
+	tests := map[string]struct {
+		setup    func(cfg *ReplayConfig)
+		expected error
+	}{
+		"valid config": {
+			setup: func(*ReplayConfig) {},
+		},
+		"replay disabled": {
+			setup: func(cfg *ReplayConfig) { *cfg = ReplayConfig{} },
+		},
+		"missing report file": {
+			setup:    func(cfg *ReplayConfig) { cfg.ReportFile = "" },
+			expected: errReplayReportFileMissing,
+		},
+		"invalid rate": {
+			setup:    func(cfg *ReplayConfig) { cfg.Rate = 0 },
+			expected: errReplayInvalidRate,
+		},
+		"invalid concurrency": {
+			setup:    func(cfg *ReplayConfig) { cfg.Concurrency = 0 },
+			expected: errReplayInvalidConcurrency,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			cfg := valid()
+			testData.setup(&cfg)
+			assert.Equal(t, testData.expected, cfg.Validate())
+		})
+	}
+
+	cfg := valid()
+	cfg.ReportFormat = "xml"
+	assert.Error(t, cfg.Validate())
+}
+
+func TestQueryPattern(t *testing.T) {
+	tests := map[string]string{
+		``:                                       ``,
+		`up`:                                     `up`,
+		`up{job="a",instance=~"b.*"}`:            `up{instance=~"?",job="?"}`,
+		`sum by (job) (rate(foo{job!="a"}[5m]))`: `sum by (job) (rate(foo{job!="?"}[5m]))`,
+		`{__name__="foo",job="a"}`:               `{__name__="foo",job="?"}`,
+		`invalid{`:                               `invalid{`,
+	}
+
+	for query, expected := range tests {
+		t.Run(query, func(t *testing.T) {
+			assert.Equal(t, expected, queryPattern(query))
+		})
+	}
+}
+
+func TestCollectSampleDiffs(t *testing.T) {
+	tests := map[string]struct {
+		expected string
+		actual   string
+		opts     SampleComparisonOptions
+		diffs    []SampleDiff
+	}{
+		"matching matrix": {
+			expected: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"],[2,"2"]]}]}}`,
+			actual:   `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"],[2,"2"]]}]}}`,
+		},
+		"mismatching, missing and unexpected samples in matrix": {
+			expected: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"],[2,"2"],[3,"3"]]}]}}`,
+			actual:   `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"],[2,"5"],[4,"4"]]}]}}`,
+			diffs: []SampleDiff{
+				{Metric: `{foo="bar"}`, Timestamp: model.TimeFromUnix(2), Expected: "2", Actual: "5"},
+				{Metric: `{foo="bar"}`, Timestamp: model.TimeFromUnix(3), Expected: "3"},
+				{Metric: `{foo="bar"}`, Timestamp: model.TimeFromUnix(4), Actual: "4"},
+			},
+		},
+		"missing and unexpected series in matrix": {
+			expected: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"1"]]}]}}`,
+			actual:   `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"baz"},"values":[[1,"1"]]}]}}`,
+			diffs: []SampleDiff{
+				{Metric: `{foo="bar"}`, Timestamp: model.TimeFromUnix(1), Expected: "1"},
+				{Metric: `{foo="baz"}`, Timestamp: model.TimeFromUnix(1), Actual: "1"},
+			},
+		},
+		"boundary samples within the boundary tolerance in matrix": {
+			expected: `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"100"],[2,"100"],[3,"100"]]}]}}`,
+			actual:   `{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"100.5"],[2,"100.5"],[3,"100.5"]]}]}}`,
+			opts:     SampleComparisonOptions{BoundaryTolerance: 0.01},
+			diffs: []SampleDiff{
+				{Metric: `{foo="bar"}`, Timestamp: model.TimeFromUnix(2), Expected: "100", Actual: "100.5"},
+			},
+		},
+		"mismatching vector": {
+			expected: `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"1"]}]}}`,
+			actual:   `{"status":"success","data":{"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"2"]}]}}`,
+			diffs: []SampleDiff{
+				{Metric: `{foo="bar"}`, Timestamp: model.TimeFromUnix(1), Expected: "1", Actual: "2"},
+			},
+		},
+		"mismatching scalar": {
+			expected: `{"status":"success","data":{"resultType":"scalar","result":[1,"1"]}}`,
+			actual:   `{"status":"success","data":{"resultType":"scalar","result":[1,"2"]}}`,
+			diffs: []SampleDiff{
+				{Metric: `{}`, Timestamp: model.TimeFromUnix(1), Expected: "1", Actual: "2"},
+			},
+		},
+		"unsupported response": {
+			expected: `{"status":"error","errorType":"bad_data","error":"invalid query"}`,
+			actual:   `{"status":"success","data":{"resultType":"scalar","result":[1,"2"]}}`,
+		},
+	}
+
+	for testName, testData := range tests {
+		t.Run(testName, func(t *testing.T) {
+			assert.Equal(t, testData.diffs, collectSampleDiffs([]byte(testData.expected), []byte(testData.actual), testData.opts))
+		})
+	}
+}
@@ -35,6 +35,21 @@ type SampleComparisonOptions struct {
 	Tolerance         float64
 	UseRelativeError  bool
 	SkipRecentSamples time.Duration
+
+	// BoundaryTolerance is the relative tolerance applied, in place of Tolerance, to the first and last
+	// sample of each series in a matrix, and to the samples of instant query results. 0 to disable.
+	BoundaryTolerance float64
+}
+
+// boundaryOptions returns the options to use to compare the samples at the boundaries of a range.
+func (o SampleComparisonOptions) boundaryOptions() SampleComparisonOptions {
+	if o.BoundaryTolerance <= 0 {
+		return o
+	}
+
+	o.Tolerance = o.BoundaryTolerance
+	o.UseRelativeError = true
+	return o
 }
 
 func NewSamplesComparator(opts SampleComparisonOptions) *SamplesComparator {
@@ -53,6 +68,19 @@ type SamplesComparator struct {
 	sampleTypesComparator map[string]SamplesComparatorFunc
 }
 
+// WithOptions returns a copy of the comparator using the input comparison options.
+func (s *SamplesComparator) WithOptions(opts SampleComparisonOptions) *SamplesComparator {
+	sampleTypesComparator := make(map[string]SamplesComparatorFunc, len(s.sampleTypesComparator))
+	for samplesType, comparator := range s.sampleTypesComparator {
+		sampleTypesComparator[samplesType] = comparator
+	}
+
+	return &SamplesComparator{
+		opts:                  opts,
+		sampleTypesComparator: sampleTypesComparator,
+	}
+}
+
 // RegisterSamplesComparator helps with registering custom sample types
 func (s *SamplesComparator) RegisterSamplesType(samplesType string, comparator SamplesComparatorFunc) {
 	s.sampleTypesComparator[samplesType] = comparator
@@ -148,7 +176,11 @@ func compareMatrix(expectedRaw, actualRaw json.RawMessage, opts SampleComparison
 
 		for i, expectedSamplePair := range expectedMetric.Values {
 			actualSamplePair := actualMetric.Values[i]
-			err := compareSamplePair(expectedSamplePair, actualSamplePair, opts)
+			sampleOpts := opts
+			if i == 0 || i == expectedMetricLen-1 {
+				sampleOpts = opts.boundaryOptions()
+			}
+			err := compareSamplePair(expectedSamplePair, actualSamplePair, sampleOpts)
 			if err != nil {
 				return errors.Wrapf(err, "sample pair not matching for metric %s", expectedMetric.Metric)
 			}
@@ -194,7 +226,7 @@ func compareVector(expectedRaw, actualRaw json.RawMessage, opts SampleComparison
 		}, model.SamplePair{
 			Timestamp: actualMetric.Timestamp,
 			Value:     actualMetric.Value,
-		}, opts)
+		}, opts.boundaryOptions())
 		if err != nil {
 			return errors.Wrapf(err, "sample pair not matching for metric %s", expectedMetric.Metric)
 		}
@@ -221,7 +253,7 @@ func compareScalar(expectedRaw, actualRaw json.RawMessage, opts SampleComparison
 	}, model.SamplePair{
 		Timestamp: actual.Timestamp,
 		Value:     actual.Value,
-	}, opts)
+	}, opts.boundaryOptions())
 }
 
 func compareSamplePair(expected, actual model.SamplePair, opts SampleComparisonOptions) error {
@@ -239,6 +239,7 @@ func TestCompareSamplesResponse(t *testing.T) {
 		err               error
 		useRelativeError  bool
 		skipRecentSamples time.Duration
+		boundaryTolerance float64
 	}{
 		{
 			name: "difference in response status",
@@ -444,12 +445,63 @@ func TestCompareSamplesResponse(t *testing.T) {
 						}`),
 			skipRecentSamples: time.Hour,
 		},
+		{
+			name: "should not fail if the first and last samples of a series are different, within the boundary tolerance",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"100"],[2,"100"],[3,"100"]]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"100.5"],[2,"100"],[3,"99.5"]]}]}
+						}`),
+			boundaryTolerance: 0.01,
+		},
+		{
+			name: "should fail if the first sample of a series is different, over the boundary tolerance",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"100"],[2,"100"],[3,"100"]]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"102"],[2,"100"],[3,"100"]]}]}
+						}`),
+			boundaryTolerance: 0.01,
+			err:               errors.New(`sample pair not matching for metric {foo="bar"}: expected value 100 for timestamp 1 but got 102`),
+		},
+		{
+			name: "should fail if a sample in the middle of a series is different, within the boundary tolerance",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"100"],[2,"100"],[3,"100"]]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"matrix","result":[{"metric":{"foo":"bar"},"values":[[1,"100"],[2,"100.5"],[3,"100"]]}]}
+						}`),
+			boundaryTolerance: 0.01,
+			err:               errors.New(`sample pair not matching for metric {foo="bar"}: expected value 100 for timestamp 2 but got 100.5`),
+		},
+		{
+			name: "should not fail if instant query samples are different, within the boundary tolerance",
+			expected: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"100"]}]}
+						}`),
+			actual: json.RawMessage(`{
+							"status": "success",
+							"data": {"resultType":"vector","result":[{"metric":{"foo":"bar"},"value":[1,"100.5"]}]}
+						}`),
+			boundaryTolerance: 0.01,
+		},
 	} {
 		t.Run(tc.name, func(t *testing.T) {
 			samplesComparator := NewSamplesComparator(SampleComparisonOptions{
 				Tolerance:         float64(tc.tolerance),
 				UseRelativeError:  bool(tc.useRelativeError),
 				SkipRecentSamples: tc.skipRecentSamples,
+				BoundaryTolerance: tc.boundaryTolerance,
 			})
 			result, err := samplesComparator.Compare(tc.expected, tc.actual)
 			if tc.err == nil {